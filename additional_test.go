@@ -2,6 +2,8 @@ package main
 
 import (
 	"os"
+	"slices"
+	"strings"
 	"testing"
 
 	"github.com/EwenQuim/pluie/config"
@@ -103,6 +105,42 @@ func TestFilterPublicNotes(t *testing.T) {
 	}
 }
 
+func TestFilterVisibleNotes(t *testing.T) {
+	notes := []model.Note{
+		{Slug: "public", IsPublic: true},
+		{Slug: "private", IsPublic: false},
+		{Slug: "draft", IsPublic: false, IsDraft: true},
+		{Slug: "public-draft", IsPublic: true, IsDraft: true},
+	}
+
+	tests := []struct {
+		name            string
+		publicByDefault bool
+		showDrafts      bool
+		expectedSlugs   []string
+	}{
+		{name: "Drafts hidden", expectedSlugs: []string{"public"}},
+		{name: "Drafts hidden with publicByDefault", publicByDefault: true, expectedSlugs: []string{"public", "private"}},
+		{name: "Drafts shown, even private ones", showDrafts: true, expectedSlugs: []string{"public", "draft", "public-draft"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := filterVisibleNotes(slices.Clone(notes), tt.publicByDefault, tt.showDrafts)
+			slugs := make([]string, 0, len(result))
+			for _, note := range result {
+				slugs = append(slugs, note.Slug)
+				if note.IsDraft && !note.IsPublic {
+					t.Errorf("shown draft %s should be made public", note.Slug)
+				}
+			}
+			if !slices.Equal(slugs, tt.expectedSlugs) {
+				t.Errorf("filterVisibleNotes() = %v, want %v", slugs, tt.expectedSlugs)
+			}
+		})
+	}
+}
+
 func TestServerStart(t *testing.T) {
 	// Create a minimal server for testing
 	notes := []model.Note{
@@ -384,3 +422,40 @@ func (m mockDirEntry) Name() string               { return m.name }
 func (m mockDirEntry) IsDir() bool                { return m.isDir }
 func (m mockDirEntry) Type() os.FileMode          { return 0 }
 func (m mockDirEntry) Info() (os.FileInfo, error) { return nil, nil }
+
+func TestServerPatternSearch(t *testing.T) {
+	notes := []model.Note{
+		{Slug: "alpha", Title: "Alpha", IsPublic: true, Content: "TODO(ewen) fix this"},
+	}
+	notesMap := map[string]model.Note{"alpha": notes[0]}
+	cfg := &config.Config{SiteTitle: "Pluie", RegexSearch: true}
+	server := Server{
+		NotesService: engine.NewNotesService(&notesMap, engine.BuildTree(notes), nil),
+		rs:           template.NewResource(cfg),
+		cfg:          cfg,
+	}
+
+	render := func(pattern string, isRegex bool) string {
+		result, err := server.getPatternSearch(pattern, "", pattern, isRegex)
+		if err != nil {
+			t.Fatalf("getPatternSearch() returned error: %v", err)
+		}
+		var sb strings.Builder
+		if err := result.Render(&sb); err != nil {
+			t.Fatalf("Render() returned error: %v", err)
+		}
+		return sb.String()
+	}
+
+	if html := render(`TODO\(\w+\)`, true); !strings.Contains(html, "TODO(ewen)</mark>") {
+		t.Error("expected the regex match")
+	}
+
+	cfg.RegexSearch = false
+	if html := render(`TODO\(\w+\)`, true); !strings.Contains(html, "regex search is disabled") || strings.Contains(html, "<mark") {
+		t.Error("regex search should be refused when disabled")
+	}
+	if html := render("TODO(ewen)", false); !strings.Contains(html, "TODO(ewen)</mark>") {
+		t.Error("phrase search should work with regex disabled")
+	}
+}
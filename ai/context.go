@@ -0,0 +1,246 @@
+// Package ai builds the prompts sent to the chat model from retrieved notes.
+package ai
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/EwenQuim/pluie/engine"
+	"github.com/EwenQuim/pluie/model"
+)
+
+// ContextBudget bounds how much of the retrieved notes goes into the prompt
+type ContextBudget struct {
+	SectionsPerNote int // Best sections kept per note
+	NoteChars       int // Characters allowed per note, all its sections together
+	TotalTokens     int // Tokens allowed for the whole context, estimated with EstimateTokens
+}
+
+// DefaultContextBudget fits the small context windows of local models (2K tokens)
+func DefaultContextBudget() ContextBudget {
+	return ContextBudget{
+		SectionsPerNote: 2,
+		NoteChars:       800,
+		TotalTokens:     1200,
+	}
+}
+
+// ContextBlock is one section of a note selected for the prompt
+type ContextBlock struct {
+	Title   string
+	Slug    string
+	Heading string // Empty for the note introduction
+	Content string
+}
+
+// Label identifies the block in the prompt so answers can cite sections, like "Title > Heading"
+func (b ContextBlock) Label() string {
+	if b.Heading == "" {
+		return b.Title
+	}
+	return b.Title + " > " + b.Heading
+}
+
+// Source is a numbered block of the context of an answer, cited by the answer as [number]
+type Source struct {
+	Slug    string `json:"slug"`
+	Title   string `json:"title"`
+	Heading string `json:"heading,omitempty"`
+	URL     string `json:"url"` // Of the note, at the section of Heading
+}
+
+// Label is the Label of the block of the source
+func (s Source) Label() string {
+	return ContextBlock{Title: s.Title, Heading: s.Heading}.Label()
+}
+
+// Sources returns the sources of the blocks, numbered from 1 in the order of FormatContext
+func Sources(blocks []ContextBlock) []Source {
+	sources := make([]Source, len(blocks))
+	for i, block := range blocks {
+		url := "/" + block.Slug
+		if anchor := engine.SlugifyHeading(block.Heading); anchor != "" {
+			url += "#" + anchor
+		}
+		sources[i] = Source{Slug: block.Slug, Title: block.Title, Heading: block.Heading, URL: url}
+	}
+	return sources
+}
+
+// minBlockChars is the smallest truncated block worth sending
+const minBlockChars = 80
+
+// EstimateTokens estimates the token count of text with the usual 4 characters per token heuristic
+func EstimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
+
+// SelectContext picks the sections of each note most relevant to the query, within the budget.
+// Notes are expected in relevance order: when the total budget runs out, the last ones are dropped.
+func SelectContext(query string, notes []model.Note, budget ContextBudget) []ContextBlock {
+	return SelectMatchedContext(query, notes, nil, budget)
+}
+
+// SelectMatchedContext is SelectContext with the chunks semantic search matched, by slug of
+// their note and best first: they are the sections of their note, the other notes get their
+// sections most relevant to the query.
+func SelectMatchedContext(query string, notes []model.Note, matched map[string][]engine.Chunk, budget ContextBudget) []ContextBlock {
+	terms := queryTerms(query)
+	remainingTokens := budget.TotalTokens
+
+	var blocks []ContextBlock
+	for _, note := range notes {
+		// Best sections get the budget first, then are put back in document order
+		var noteSections []scoredSection
+		noteChars := budget.NoteChars
+		sections := matchedSections(matched[note.Slug], budget.SectionsPerNote)
+		if len(sections) == 0 {
+			sections = selectSections(note.Content, terms, budget.SectionsPerNote)
+		}
+		for _, section := range sections {
+			label := ContextBlock{Title: note.Title, Heading: section.Heading}.Label()
+			overhead := utf8.RuneCountInString(formatBlock(len(blocks)+len(noteSections)+1, label, ""))
+			maxChars := min(noteChars, remainingTokens*4-overhead)
+			if maxChars < minBlockChars && utf8.RuneCountInString(section.Content) > maxChars {
+				continue
+			}
+
+			section.Content = truncate(section.Content, maxChars)
+			noteChars -= utf8.RuneCountInString(section.Content)
+			remainingTokens -= EstimateTokens(formatBlock(len(blocks)+len(noteSections)+1, label, section.Content))
+			noteSections = append(noteSections, section)
+		}
+
+		slices.SortFunc(noteSections, func(a, b scoredSection) int { return a.index - b.index })
+		for _, section := range noteSections {
+			blocks = append(blocks, ContextBlock{
+				Title:   note.Title,
+				Slug:    note.Slug,
+				Heading: section.Heading,
+				Content: section.Content,
+			})
+		}
+
+		if remainingTokens <= 0 {
+			break
+		}
+	}
+
+	return blocks
+}
+
+// FormatContext renders the selected blocks as numbered, labelled sections for the prompt
+func FormatContext(blocks []ContextBlock) string {
+	var sb strings.Builder
+	sb.WriteString("Relevant notes:\n\n")
+	for i, block := range blocks {
+		sb.WriteString(formatBlock(i+1, block.Label(), block.Content))
+	}
+	return sb.String()
+}
+
+// formatBlock renders one numbered context block
+func formatBlock(index int, label, content string) string {
+	return fmt.Sprintf("[%d] %s\n%s\n\n", index, label, content)
+}
+
+// scoredSection is a section with its relevance to the query
+type scoredSection struct {
+	engine.Section
+	score int
+	index int
+}
+
+// selectSections returns the best scoring sections of a note, best first.
+// A note without any matching section contributes its first section, usually its introduction.
+func selectSections(content string, terms []string, limit int) []scoredSection {
+	var candidates []scoredSection
+	for i, section := range engine.SplitSections(content) {
+		if section.Content == "" {
+			continue
+		}
+		candidates = append(candidates, scoredSection{Section: section, score: scoreSection(section, terms), index: i})
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	bestFirst := slices.Clone(candidates)
+	slices.SortStableFunc(bestFirst, func(a, b scoredSection) int {
+		return b.score - a.score
+	})
+
+	if bestFirst[0].score == 0 {
+		// Nothing matches: fall back to the beginning of the note
+		return candidates[:1]
+	}
+
+	var selected []scoredSection
+	for _, candidate := range bestFirst {
+		if candidate.score == 0 || len(selected) == max(limit, 1) {
+			break
+		}
+		selected = append(selected, candidate)
+	}
+	return selected
+}
+
+// matchedSections returns the first chunks as sections, in the order of the note
+func matchedSections(chunks []engine.Chunk, limit int) []scoredSection {
+	var sections []scoredSection
+	for _, chunk := range chunks[:min(len(chunks), max(limit, 1))] {
+		sections = append(sections, scoredSection{
+			Section: engine.Section{Heading: chunk.Heading, Content: chunk.Text},
+			index:   chunk.Start,
+		})
+	}
+	return sections
+}
+
+// scoreSection scores a section by term overlap with the query.
+// A term in the heading counts 3, a term in the body 2, and each extra occurrence 1 (up to 3).
+func scoreSection(section engine.Section, terms []string) int {
+	heading := strings.ToLower(section.Heading)
+	body := strings.ToLower(section.Content)
+
+	score := 0
+	for _, term := range terms {
+		if strings.Contains(heading, term) {
+			score += 3
+		}
+		if count := strings.Count(body, term); count > 0 {
+			score += 2 + min(count-1, 3)
+		}
+	}
+	return score
+}
+
+// queryTerms splits a query into distinct lowercase terms, ignoring very short words
+func queryTerms(query string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	var terms []string
+	for _, field := range fields {
+		if utf8.RuneCountInString(field) >= 3 && !slices.Contains(terms, field) {
+			terms = append(terms, field)
+		}
+	}
+	return terms
+}
+
+// truncate shortens text to at most maxChars characters, cutting on a rune boundary
+func truncate(text string, maxChars int) string {
+	if maxChars <= 0 {
+		return ""
+	}
+	if utf8.RuneCountInString(text) <= maxChars {
+		return text
+	}
+	runes := []rune(text)
+	return strings.TrimSpace(string(runes[:max(maxChars-3, 0)])) + "..."
+}
@@ -0,0 +1,195 @@
+package ai
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/EwenQuim/pluie/engine"
+	"github.com/EwenQuim/pluie/model"
+)
+
+// syntheticNote builds a note with an introduction and one section per heading
+func syntheticNote(title, intro string, sections ...string) model.Note {
+	var sb strings.Builder
+	sb.WriteString(intro + "\n\n")
+	for i := 0; i+1 < len(sections); i += 2 {
+		sb.WriteString("## " + sections[i] + "\n" + sections[i+1] + "\n\n")
+	}
+	return model.Note{Title: title, Slug: strings.ToLower(title), Content: sb.String()}
+}
+
+func headings(blocks []ContextBlock) []string {
+	var result []string
+	for _, block := range blocks {
+		result = append(result, block.Label())
+	}
+	return result
+}
+
+func TestSelectContext_PicksRelevantSections(t *testing.T) {
+	note := syntheticNote("Sourdough",
+		"My notes about bread, collected over the years.",
+		"History", "Bread has been baked for thousands of years.",
+		"Starter feeding", "Feed the starter twice a day with flour and water. A healthy starter doubles.",
+		"Baking temperature", "Bake at 250 degrees with steam, then lower the oven temperature.",
+		"Equipment", "A dutch oven and a scale.",
+	)
+
+	blocks := SelectContext("how often should I feed my starter?", []model.Note{note}, DefaultContextBudget())
+
+	if got := headings(blocks); len(got) != 1 || got[0] != "Sourdough > Starter feeding" {
+		t.Fatalf("expected only the starter section, got %v", got)
+	}
+	if !strings.Contains(blocks[0].Content, "twice a day") {
+		t.Errorf("unexpected content %q", blocks[0].Content)
+	}
+	if blocks[0].Slug != "sourdough" {
+		t.Errorf("unexpected slug %q", blocks[0].Slug)
+	}
+}
+
+func TestSelectContext_KeepsTwoBestSectionsInDocumentOrder(t *testing.T) {
+	note := syntheticNote("Oven",
+		"Intro.",
+		"Temperature for bread", "Bread bakes at high temperature.",
+		"Cleaning", "Clean the oven monthly.",
+		"Bread steam", "Steam helps bread crust, bread loves steam.",
+		"Pizza", "Pizza needs the highest temperature.",
+	)
+
+	blocks := SelectContext("bread temperature steam", []model.Note{note}, DefaultContextBudget())
+
+	expected := []string{"Oven > Temperature for bread", "Oven > Bread steam"}
+	if got := headings(blocks); strings.Join(got, "|") != strings.Join(expected, "|") {
+		t.Errorf("headings = %v, want %v", got, expected)
+	}
+}
+
+func TestSelectContext_FallsBackToIntroduction(t *testing.T) {
+	note := syntheticNote("Garden", "Everything about my vegetable garden.", "Tomatoes", "Water daily.")
+
+	blocks := SelectContext("quantum physics", []model.Note{note}, DefaultContextBudget())
+
+	if len(blocks) != 1 || blocks[0].Heading != "" || blocks[0].Label() != "Garden" {
+		t.Fatalf("expected the introduction block, got %+v", blocks)
+	}
+}
+
+func TestSelectContext_RespectsPerNoteBudget(t *testing.T) {
+	long := strings.Repeat("kubernetes cluster upgrade steps. ", 100)
+	note := syntheticNote("Ops", "Intro.", "Kubernetes upgrade", long, "Kubernetes rollback", long)
+
+	budget := ContextBudget{SectionsPerNote: 2, NoteChars: 300, TotalTokens: 10000}
+	blocks := SelectContext("kubernetes upgrade", []model.Note{note}, budget)
+
+	total := 0
+	for _, block := range blocks {
+		total += len([]rune(block.Content))
+	}
+	if total > budget.NoteChars {
+		t.Errorf("note used %d chars, budget is %d", total, budget.NoteChars)
+	}
+	if len(blocks) == 0 || !strings.HasSuffix(blocks[0].Content, "...") {
+		t.Errorf("expected a truncated block, got %+v", blocks)
+	}
+}
+
+func TestSelectContext_RespectsTotalTokenBudget(t *testing.T) {
+	var notes []model.Note
+	for _, title := range []string{"One", "Two", "Three", "Four", "Five", "Six"} {
+		notes = append(notes, syntheticNote(title, "Intro.", "Golang generics", strings.Repeat("golang generics constraints explained. ", 20)))
+	}
+
+	budget := ContextBudget{SectionsPerNote: 2, NoteChars: 500, TotalTokens: 300}
+	blocks := SelectContext("golang generics", notes, budget)
+
+	formatted := strings.TrimPrefix(FormatContext(blocks), "Relevant notes:\n\n")
+	if tokens := EstimateTokens(formatted); tokens > budget.TotalTokens {
+		t.Errorf("context uses %d tokens, budget is %d", tokens, budget.TotalTokens)
+	}
+	if len(blocks) == 0 || len(blocks) == len(notes) {
+		t.Errorf("expected the budget to keep some but not all notes, got %d blocks", len(blocks))
+	}
+	if blocks[0].Title != "One" {
+		t.Errorf("expected notes to be kept in retrieval order, first is %q", blocks[0].Title)
+	}
+}
+
+func TestSelectContext_IgnoresHeadingsInCodeBlocks(t *testing.T) {
+	note := model.Note{
+		Title:   "Shell",
+		Slug:    "shell",
+		Content: "Intro.\n\n## Scripts\n```sh\n# deploy the app\n./deploy.sh\n```\n\n## Other\nNothing here.",
+	}
+
+	blocks := SelectContext("deploy", []model.Note{note}, DefaultContextBudget())
+
+	if len(blocks) != 1 || blocks[0].Heading != "Scripts" || !strings.Contains(blocks[0].Content, "./deploy.sh") {
+		t.Errorf("expected the Scripts section with its code block, got %+v", blocks)
+	}
+}
+
+func TestSelectMatchedContext_UsesMatchedChunks(t *testing.T) {
+	oven := syntheticNote("Oven",
+		"Intro.",
+		"Cleaning", "Clean the oven monthly, with vinegar.",
+		"Heat", "A stone keeps the heat.",
+	)
+	bread := syntheticNote("Bread", "Bread needs an oven.")
+
+	// Semantic search matched the cleaning section though the query shares no word with it
+	start := strings.Index(oven.Content, "Clean the oven")
+	matched := map[string][]engine.Chunk{
+		"oven": {{Heading: "Cleaning", Start: start, End: start + 37, Text: "Clean the oven monthly, with vinegar."}},
+	}
+	blocks := SelectMatchedContext("how to remove grease", []model.Note{oven, bread}, matched, DefaultContextBudget())
+
+	if got := headings(blocks); len(got) != 2 || got[0] != "Oven > Cleaning" || got[1] != "Bread" {
+		t.Fatalf("expected the matched chunk then the introduction of the other note, got %v", got)
+	}
+	if blocks[0].Content != "Clean the oven monthly, with vinegar." {
+		t.Errorf("unexpected content %q", blocks[0].Content)
+	}
+}
+
+func TestFormatContext(t *testing.T) {
+	blocks := []ContextBlock{
+		{Title: "Sourdough", Heading: "Starter", Content: "Feed it."},
+		{Title: "Garden", Content: "Intro."},
+	}
+
+	expected := "Relevant notes:\n\n[1] Sourdough > Starter\nFeed it.\n\n[2] Garden\nIntro.\n\n"
+	if got := FormatContext(blocks); got != expected {
+		t.Errorf("FormatContext() = %q, want %q", got, expected)
+	}
+}
+
+func TestEstimateTokens(t *testing.T) {
+	tests := map[string]int{
+		"":          0,
+		"abc":       1,
+		"abcd":      1,
+		"abcde":     2,
+		"éééééééé":  2,
+		"one two 3": 3,
+	}
+
+	for text, expected := range tests {
+		if got := EstimateTokens(text); got != expected {
+			t.Errorf("EstimateTokens(%q) = %d, want %d", text, got, expected)
+		}
+	}
+}
+
+func TestSources(t *testing.T) {
+	sources := Sources([]ContextBlock{
+		{Title: "Sourdough", Slug: "baking/sourdough", Content: "Intro"},
+		{Title: "Sourdough", Slug: "baking/sourdough", Heading: "Feeding the starter", Content: "Daily"},
+	})
+	if len(sources) != 2 || sources[0].URL != "/baking/sourdough" || sources[1].URL != "/baking/sourdough#feeding-the-starter" {
+		t.Fatalf("Sources() = %+v, want the notes at the sections of the blocks", sources)
+	}
+	if label := sources[1].Label(); label != "Sourdough > Feeding the starter" {
+		t.Errorf("Label() = %q, want the label of the block", label)
+	}
+}
@@ -0,0 +1,93 @@
+package ai
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// DefaultPromptTemplate is the prompt of the AI answers, used when no other is configured
+const DefaultPromptTemplate = `Answer this question based on the notes below. Cite the sections you use by their number in brackets, like [1].
+
+Question: {{.Query}}
+
+{{.Context}}
+
+Answer concisely:`
+
+// MaxHistoryTurns is the number of previous turns of a conversation sent with a new question
+const MaxHistoryTurns = 6
+
+// Prompt is what is sent to the chat model
+type Prompt struct {
+	System  string // Instructions sent before the question, as a system message, empty for none
+	History []Turn // Previous turns of the conversation, oldest first, sent between the two
+	User    string // Question with its context
+}
+
+// Turn is a previous question of a conversation and the answer of the model
+type Turn struct {
+	Question string `json:"question"`
+	Answer   string `json:"answer"`
+}
+
+// RecentTurns returns the last MaxHistoryTurns turns of history, without the empty ones
+func RecentTurns(history []Turn) []Turn {
+	var turns []Turn
+	for _, turn := range history {
+		if strings.TrimSpace(turn.Question) != "" && strings.TrimSpace(turn.Answer) != "" {
+			turns = append(turns, turn)
+		}
+	}
+	return turns[max(0, len(turns)-MaxHistoryTurns):]
+}
+
+// promptData is what prompt templates can use
+type promptData struct {
+	Query   string // Question asked
+	Context string // Notes selected for the question, see FormatContext
+}
+
+// PromptTemplate builds the prompts of the AI answers from a Go template using {{.Query}} and
+// {{.Context}}
+type PromptTemplate struct {
+	tmpl   *template.Template
+	system string
+}
+
+// ParsePromptTemplate parses a prompt template, DefaultPromptTemplate when text is empty, with
+// an optional system prompt. Templates failing on a sample question are refused, like the
+// ones using other fields than Query and Context.
+func ParsePromptTemplate(text, system string) (*PromptTemplate, error) {
+	if strings.TrimSpace(text) == "" {
+		text = DefaultPromptTemplate
+	}
+	tmpl, err := template.New("prompt").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parsing prompt template: %w", err)
+	}
+
+	prompt := &PromptTemplate{tmpl: tmpl, system: strings.TrimSpace(system)}
+	if _, err := prompt.Build("question", nil); err != nil {
+		return nil, err
+	}
+	return prompt, nil
+}
+
+// DefaultPrompt returns the built-in prompt template, without system prompt
+func DefaultPrompt() *PromptTemplate {
+	prompt, err := ParsePromptTemplate(DefaultPromptTemplate, "")
+	if err != nil {
+		panic(err) // DefaultPromptTemplate is valid, see TestParsePromptTemplate
+	}
+	return prompt
+}
+
+// Build returns the prompt answering query with the selected blocks as context
+func (p *PromptTemplate) Build(query string, blocks []ContextBlock) (Prompt, error) {
+	var sb strings.Builder
+	if err := p.tmpl.Execute(&sb, promptData{Query: query, Context: strings.TrimSpace(FormatContext(blocks))}); err != nil {
+		return Prompt{}, fmt.Errorf("executing prompt template: %w", err)
+	}
+	return Prompt{System: p.system, User: sb.String()}, nil
+}
@@ -0,0 +1,53 @@
+package ai
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParsePromptTemplate(t *testing.T) {
+	blocks := []ContextBlock{{Title: "Sourdough", Slug: "sourdough", Content: "Feed the starter daily."}}
+
+	prompt, err := ParsePromptTemplate("", "")
+	if err != nil {
+		t.Fatalf("ParsePromptTemplate() of the default error: %v", err)
+	}
+	built, err := prompt.Build("How to bake?", blocks)
+	if err != nil || built.System != "" || !strings.Contains(built.User, "Question: How to bake?") || !strings.Contains(built.User, "Feed the starter daily.") {
+		t.Errorf("Build() = %+v, %v, want the default prompt with the question and its context", built, err)
+	}
+
+	prompt, err = ParsePromptTemplate("Context:\n{{.Context}}\nQ: {{.Query}}", "  You are a baker.\n")
+	if err != nil {
+		t.Fatalf("ParsePromptTemplate() error: %v", err)
+	}
+	built, err = prompt.Build("Why rest the dough?", nil)
+	if err != nil || built.User != "Context:\nRelevant notes:\nQ: Why rest the dough?" || built.System != "You are a baker." {
+		t.Errorf("Build() = %+v, %v, want the custom template and the trimmed system prompt", built, err)
+	}
+
+	for _, text := range []string{"Q: {{.Query", "Q: {{.Question}}", "{{template \"missing\"}}"} {
+		if _, err := ParsePromptTemplate(text, ""); err == nil {
+			t.Errorf("ParsePromptTemplate(%q) should fail", text)
+		}
+	}
+
+	DefaultPrompt() // Panics if the default template is invalid
+}
+
+func TestRecentTurns(t *testing.T) {
+	var history []Turn
+	for i := range MaxHistoryTurns + 2 {
+		history = append(history, Turn{Question: fmt.Sprint("question ", i), Answer: fmt.Sprint("answer ", i)})
+	}
+	history = append(history, Turn{Question: "unanswered", Answer: " "})
+
+	turns := RecentTurns(history)
+	if len(turns) != MaxHistoryTurns || turns[0].Question != "question 2" || turns[len(turns)-1].Answer != fmt.Sprint("answer ", MaxHistoryTurns+1) {
+		t.Errorf("RecentTurns() = %+v, want the last %d answered turns", turns, MaxHistoryTurns)
+	}
+	if turns := RecentTurns(nil); len(turns) != 0 {
+		t.Errorf("RecentTurns(nil) = %+v, want none", turns)
+	}
+}
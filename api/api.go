@@ -0,0 +1,169 @@
+// Package api defines the JSON responses of pluie's machine-readable endpoints.
+//
+// Every response is an Envelope carrying the Version of its schema. Within a version, fields are
+// only ever added: removing a field or changing its type bumps Version. Go consumers can import
+// this package to unmarshal the responses directly.
+package api
+
+import "time"
+
+// Version of the response schemas, sent in every Envelope
+const Version = 1
+
+// Envelope wraps the data of every JSON response with the schema version
+type Envelope[T any] struct {
+	Version int `json:"version"`
+	Data    T   `json:"data"`
+}
+
+// Wrap puts data in an Envelope of the current Version
+func Wrap[T any](data T) Envelope[T] {
+	return Envelope[T]{Version: Version, Data: data}
+}
+
+// Health is the response of GET /-/health
+type Health struct {
+	Status string `json:"status"`
+}
+
+// Readiness is the response of GET /-/readyz, its status is 503 until Ready
+type Readiness struct {
+	Ready      bool                `json:"ready"`       // Whether the notes are loaded
+	VaultPath  string              `json:"vault_path"`  // Folder of the notes
+	Notes      int                 `json:"notes"`       // Notes loaded
+	LastReload time.Time           `json:"last_reload"` // When the notes were last loaded, zero before
+	Weaviate   Dependency          `json:"weaviate"`
+	Chat       Dependency          `json:"chat"`
+	Embeddings EmbeddingsReadiness `json:"embeddings"`
+}
+
+// Dependency is the state of an optional dependency: "ok", "degraded", "unavailable" or
+// "disabled". Pluie serves the notes whatever it is.
+type Dependency struct {
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"` // Why it isn't ok, or what it is when it is
+}
+
+// EmbeddingsReadiness is the state of the embedding of the notes: "pending" until the first
+// search starts it, "embedding", "paused", "done" or "disabled"
+type EmbeddingsReadiness struct {
+	Status  string  `json:"status"`
+	Percent float64 `json:"percent"` // Notes embedded or already up to date, out of all the notes
+}
+
+// SwitcherResult is a quick switcher match
+type SwitcherResult struct {
+	Text      string `json:"text"`       // Matched title, alias or heading
+	Kind      string `json:"kind"`       // note, alias or heading
+	Tier      string `json:"tier"`       // prefix, initials or fuzzy
+	Score     int    `json:"score"`      // Higher is better
+	Slug      string `json:"slug"`       // Slug of the note
+	NoteTitle string `json:"note_title"` // Title of the note, differs from Text for aliases and headings
+	URL       string `json:"url"`        // Note URL, with the heading anchor for headings
+}
+
+// SwitcherResults is the response of GET /-/switcher, best match first. Never null.
+type SwitcherResults []SwitcherResult
+
+// EmbeddingStatus is the response of POST /-/embeddings/pause, /-/embeddings/resume and
+// /-/embeddings/reembed
+type EmbeddingStatus struct {
+	TotalNotes    int       `json:"total_notes"`
+	EmbeddedNotes int       `json:"embedded_notes"` // Embedded by the current pass
+	SkippedNotes  int       `json:"skipped_notes"`  // Already embedded with their content
+	IsEmbedding   bool      `json:"is_embedding"`
+	IsPaused      bool      `json:"is_paused"`
+	Rate          float64   `json:"rate_per_minute"`
+	CurrentNote   string    `json:"current_note,omitempty"`
+	LastUpdated   time.Time `json:"last_updated"`
+}
+
+// GraphNode is a note of the link graph
+type GraphNode struct {
+	Slug  string   `json:"slug"`
+	Title string   `json:"title"`
+	Tags  []string `json:"tags"` // Lowercase, never null
+}
+
+// GraphEdge is a wikilink between two notes, by slug
+type GraphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Graph is the response of GET /-/graph.json: the public notes and their wikilinks.
+// Nodes and edges are never null.
+type Graph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+// NoteSummary is a note of GET /-/api/notes
+type NoteSummary struct {
+	Slug     string    `json:"slug"`
+	Title    string    `json:"title"`
+	Tags     []string  `json:"tags"`     // Lowercase, never null
+	Modified time.Time `json:"modified"` // "modified" frontmatter, "date" as a fallback, then the file modification time
+}
+
+// NoteSummaries is the response of GET /-/api/notes: the public notes, by slug. Never null.
+type NoteSummaries []NoteSummary
+
+// NoteLink is a note linking to another
+type NoteLink struct {
+	Slug  string `json:"slug"`
+	Title string `json:"title"`
+}
+
+// Note is the response of GET /-/api/notes/{slug}
+type Note struct {
+	Slug      string         `json:"slug"`
+	Title     string         `json:"title"`
+	Tags      []string       `json:"tags"` // Lowercase, never null
+	Modified  time.Time      `json:"modified"`
+	Metadata  map[string]any `json:"metadata"`  // YAML frontmatter, never null
+	HTML      string         `json:"html"`      // Rendered content, like on the note page
+	Backlinks []NoteLink     `json:"backlinks"` // Notes with wikilinks to this one, never null
+}
+
+// TreeEntry is a folder or a note of GET /-/api/tree
+type TreeEntry struct {
+	Name     string `json:"name"`           // Folder name or note title
+	Path     string `json:"path"`           // Folder path like "projects/clientx", the slug for a note
+	Parent   string `json:"parent"`         // Path of the folder holding it, empty at the root
+	IsFolder bool   `json:"is_folder"`      // False for a note
+	Slug     string `json:"slug,omitempty"` // Notes only
+}
+
+// Tree is the response of GET /-/api/tree: the folders and public notes in the sidebar order,
+// every folder before its content. Flat, as the OpenAPI schema of a recursive type cannot be
+// generated. Never null.
+type Tree []TreeEntry
+
+// Tag is a tag of GET /-/api/tags
+type Tag struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"` // Number of public notes with the tag
+}
+
+// Tags is the response of GET /-/api/tags, by name. Never null.
+type Tags []Tag
+
+// LinkTarget is a note of GET /-/api/link-index, what a [[wikilink]] to it needs
+type LinkTarget struct {
+	Title   string   `json:"title"`
+	Slug    string   `json:"slug"`
+	Aliases []string `json:"aliases"` // "aliases" frontmatter, never null
+	Folder  string   `json:"folder"`  // Vault folder of the note, like "Projects/Client X", empty at the root
+}
+
+// LinkIndex is the response of GET /-/api/link-index: the public notes matching the query,
+// title matches first, then by slug. Never null.
+type LinkIndex []LinkTarget
+
+// Reload is the response of POST /-/reload, and of GET /-/reload for the last successful reload
+type Reload struct {
+	Notes      int       `json:"notes"`       // Notes loaded
+	DurationMS int64     `json:"duration_ms"` // How long loading the notes took, zero for the startup load
+	ReloadedAt time.Time `json:"reloaded_at"` // When the notes were swapped in
+}
@@ -0,0 +1,221 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "write the golden fixtures of the current Version")
+
+// contracts are the responses checked against the golden fixtures of their version.
+// Set every field, so that the fixtures cover them all.
+var contracts = map[string]any{
+	"health": Wrap(Health{Status: "ok"}),
+	"readiness": Wrap(Readiness{
+		Ready:      true,
+		VaultPath:  "/vault",
+		Notes:      120,
+		LastReload: time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC),
+		Weaviate:   Dependency{Status: "unavailable", Detail: "no answer from http://weaviate:8080"},
+		Chat:       Dependency{Status: "ok", Detail: "ollama/llama3"},
+		Embeddings: EmbeddingsReadiness{Status: "embedding", Percent: 60},
+	}),
+	"switcher": Wrap(SwitcherResults{{
+		Text:      "Action items",
+		Kind:      "heading",
+		Tier:      "prefix",
+		Score:     1200,
+		Slug:      "meeting-acme-corp",
+		NoteTitle: "Meeting — Acme Corp",
+		URL:       "/meeting-acme-corp#action-items",
+	}}),
+	"embedding_status": Wrap(EmbeddingStatus{
+		TotalNotes:    120,
+		EmbeddedNotes: 42,
+		SkippedNotes:  30,
+		IsEmbedding:   true,
+		IsPaused:      false,
+		Rate:          12.5,
+		CurrentNote:   "garden",
+		LastUpdated:   time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC),
+	}),
+	"graph": Wrap(Graph{
+		Nodes: []GraphNode{{Slug: "garden", Title: "Garden", Tags: []string{"plants"}}},
+		Edges: []GraphEdge{{From: "recipes/bread", To: "garden"}},
+	}),
+	"notes": Wrap(NoteSummaries{{
+		Slug:     "garden",
+		Title:    "Garden",
+		Tags:     []string{"plants"},
+		Modified: time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC),
+	}}),
+	"note": Wrap(Note{
+		Slug:      "garden",
+		Title:     "Garden",
+		Tags:      []string{"plants"},
+		Modified:  time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC),
+		Metadata:  map[string]any{"tags": []string{"plants"}},
+		HTML:      "<p>Tomatoes and basil.</p>",
+		Backlinks: []NoteLink{{Slug: "recipes/bread", Title: "Bread"}},
+	}),
+	"tree": Wrap(Tree{
+		{Name: "recipes", Path: "recipes", IsFolder: true},
+		{Name: "Bread", Path: "recipes/bread", Parent: "recipes", Slug: "recipes/bread"},
+	}),
+	"tags":   Wrap(Tags{{Name: "plants", Count: 3}}),
+	"reload": Wrap(Reload{Notes: 120, DurationMS: 85, ReloadedAt: time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)}),
+	"link_index": Wrap(LinkIndex{{
+		Title:   "Meeting — Acme Corp",
+		Slug:    "projects/acme/meeting",
+		Aliases: []string{"Acme kickoff"},
+		Folder:  "Projects/Acme",
+	}}),
+}
+
+// TestContracts fails when a response field is removed or changes type without a Version bump.
+// Added fields are compatible: run "go test ./api -update" to add them to the fixtures.
+// After a Version bump, the same command writes the fixtures of the new version.
+func TestContracts(t *testing.T) {
+	for name, response := range contracts {
+		t.Run(name, func(t *testing.T) {
+			actual, err := json.MarshalIndent(response, "", "  ")
+			if err != nil {
+				t.Fatalf("marshaling %s: %v", name, err)
+			}
+			actual = append(actual, '\n')
+
+			golden := filepath.Join("testdata", fmt.Sprintf("v%d", Version), name+".json")
+			expected, err := os.ReadFile(golden)
+			if os.IsNotExist(err) && *update {
+				writeFixture(t, golden, actual)
+				return
+			}
+			if err != nil {
+				t.Fatalf("reading %s: %v. Run \"go test ./api -update\" to write the fixtures of version %d", golden, err, Version)
+			}
+
+			breaking := compatibility(t, expected, actual)
+			for _, problem := range breaking {
+				t.Errorf("%s: %s without a version bump", golden, problem)
+			}
+			if len(breaking) > 0 || bytes.Equal(expected, actual) {
+				return
+			}
+			if *update {
+				writeFixture(t, golden, actual)
+				return
+			}
+			t.Errorf("%s is outdated, run \"go test ./api -update\":\n%s", golden, actual)
+		})
+	}
+}
+
+func writeFixture(t *testing.T, golden string, data []byte) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(golden), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(golden, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// compatibility lists the breaking changes from the expected JSON to the actual one
+func compatibility(t *testing.T, expected, actual []byte) []string {
+	t.Helper()
+	var want, got any
+	if err := json.Unmarshal(expected, &want); err != nil {
+		t.Fatalf("invalid fixture: %v", err)
+	}
+	if err := json.Unmarshal(actual, &got); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	return compareJSON("$", want, got)
+}
+
+// compareJSON reports the fields of want missing from got, and the values whose JSON type changed.
+// Arrays are compared on their first element.
+func compareJSON(path string, want, got any) []string {
+	if jsonType(want) != jsonType(got) {
+		return []string{fmt.Sprintf("%s changed from %s to %s", path, jsonType(want), jsonType(got))}
+	}
+
+	var problems []string
+	switch want := want.(type) {
+	case map[string]any:
+		got := got.(map[string]any)
+		keys := make([]string, 0, len(want))
+		for key := range want {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			value, ok := got[key]
+			if !ok {
+				problems = append(problems, fmt.Sprintf("%s.%s was removed", path, key))
+				continue
+			}
+			problems = append(problems, compareJSON(path+"."+key, want[key], value)...)
+		}
+	case []any:
+		got := got.([]any)
+		if len(want) > 0 && len(got) > 0 {
+			problems = append(problems, compareJSON(path+"[0]", want[0], got[0])...)
+		}
+	}
+	return problems
+}
+
+// jsonType is the JSON type of a decoded value
+func jsonType(value any) string {
+	switch value.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	default:
+		return "null"
+	}
+}
+
+func TestCompareJSON(t *testing.T) {
+	fixture := `{"version": 1, "data": {"name": "a", "count": 1, "items": [{"id": "x"}]}}`
+
+	tests := []struct {
+		name     string
+		actual   string
+		expected []string
+	}{
+		{name: "same", actual: fixture},
+		{name: "added field", actual: `{"version": 1, "data": {"name": "a", "count": 1, "items": [{"id": "x"}], "new": true}}`},
+		{name: "empty array", actual: `{"version": 1, "data": {"name": "a", "count": 1, "items": []}}`},
+		{name: "removed field", actual: `{"version": 1, "data": {"name": "a", "items": [{"id": "x"}]}}`, expected: []string{"$.data.count was removed"}},
+		{name: "changed type", actual: `{"version": 1, "data": {"name": "a", "count": "1", "items": [{"id": 1}]}}`, expected: []string{
+			"$.data.count changed from number to string",
+			"$.data.items[0].id changed from string to number",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := compatibility(t, []byte(fixture), []byte(tt.actual))
+			if strings.Join(problems, "\n") != strings.Join(tt.expected, "\n") {
+				t.Errorf("compatibility() = %q, want %q", problems, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// sessionCookieName is the cookie set by the login page
+	sessionCookieName = "pluie_session"
+	// sessionDuration is how long a login lasts
+	sessionDuration = 30 * 24 * time.Hour
+	// accessTokenParam carries AUTH_TOKEN on the SSE routes, for clients that cannot set headers
+	accessTokenParam = "access_token"
+)
+
+// authMiddleware asks for AUTH_TOKEN or the basic auth credentials on every route, static
+// assets included. A credential is accepted as a header, as the session cookie of the login
+// page, or as the access_token query parameter on the SSE routes. Browsers without one get
+// the login page, other clients a 401.
+func (s *Server) authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.authExempt(r) || s.authenticated(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if r.Method == http.MethodGet && strings.Contains(r.Header.Get("Accept"), "text/html") {
+			s.renderLogin(w, r, http.StatusUnauthorized, r.URL.RequestURI(), "")
+			return
+		}
+		if s.cfg.BasicAuthUser != "" || s.cfg.BasicAuthPass != "" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="pluie"`)
+		}
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	})
+}
+
+// authExempt reports whether the route needs no site credential: the health checks, the login
+// page, the routes checking their own token, and the CORS preflight requests of the API, which
+// browsers send without credentials
+func (s *Server) authExempt(r *http.Request) bool {
+	switch {
+	case r.URL.Path == "/-/health", r.URL.Path == "/-/healthz", r.URL.Path == "/-/readyz", r.URL.Path == "/-/login":
+		return true
+	case r.URL.Path == "/-/metrics":
+		return s.cfg.MetricsToken != ""
+	case r.URL.Path == "/-/export/flashcards.csv":
+		return s.cfg.FlashcardsToken != ""
+	case strings.HasPrefix(r.URL.Path, "/-/embeddings/"):
+		return s.cfg.EmbeddingsToken != ""
+	case r.URL.Path == "/-/reload":
+		return s.cfg.ReloadToken != ""
+	case r.Method == http.MethodOptions && strings.HasPrefix(r.URL.Path, "/-/api/"):
+		return s.cfg.CORSOrigins != ""
+	}
+	return false
+}
+
+// authenticated reports whether the request carries a valid site credential
+func (s *Server) authenticated(r *http.Request) bool {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && s.validToken(token) {
+		return true
+	}
+	if user, pass, ok := r.BasicAuth(); ok && s.validPassword(user, pass) {
+		return true
+	}
+	if cookie, err := r.Cookie(sessionCookieName); err == nil && s.validSession(cookie.Value, time.Now()) {
+		return true
+	}
+	if r.URL.Path == "/-/search-stream" || r.URL.Path == "/-/embedding-progress" {
+		return s.validToken(r.URL.Query().Get(accessTokenParam))
+	}
+	return false
+}
+
+// validToken compares the token to AUTH_TOKEN in constant time
+func (s *Server) validToken(token string) bool {
+	return s.cfg.AuthToken != "" && token != "" && constantTimeEqual(token, s.cfg.AuthToken)
+}
+
+// validPassword compares the credentials to BASIC_AUTH_USER and BASIC_AUTH_PASS in constant
+// time. Nothing matches when one of them is not set.
+func (s *Server) validPassword(user, pass string) bool {
+	if s.cfg.BasicAuthUser == "" || s.cfg.BasicAuthPass == "" {
+		return false
+	}
+	userOK := constantTimeEqual(user, s.cfg.BasicAuthUser)
+	passOK := constantTimeEqual(pass, s.cfg.BasicAuthPass)
+	return userOK && passOK
+}
+
+// constantTimeEqual compares strings without leaking where they differ
+func constantTimeEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// validBearerToken reports whether the Authorization header is "Bearer <token>", compared in
+// constant time
+func validBearerToken(authorization, token string) bool {
+	value, ok := strings.CutPrefix(authorization, "Bearer ")
+	return ok && constantTimeEqual(value, token)
+}
+
+// sessionValue returns the session cookie value expiring at expires: the expiry and its
+// signature by the site credentials, so changing them ends the sessions
+func (s *Server) sessionValue(expires time.Time) string {
+	expiry := strconv.FormatInt(expires.Unix(), 10)
+	return expiry + "." + s.sessionSignature(expiry)
+}
+
+func (s *Server) sessionSignature(expiry string) string {
+	key := sha256.Sum256([]byte("pluie-session\x00" + s.cfg.AuthToken + "\x00" + s.cfg.BasicAuthUser + "\x00" + s.cfg.BasicAuthPass))
+	mac := hmac.New(sha256.New, key[:])
+	mac.Write([]byte(expiry))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// validSession reports whether the session cookie value is signed and not expired at now
+func (s *Server) validSession(value string, now time.Time) bool {
+	expiry, signature, ok := strings.Cut(value, ".")
+	if !ok {
+		return false
+	}
+	unix, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil || now.Unix() >= unix {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(s.sessionSignature(expiry)))
+}
+
+// getLogin shows the login page, or sends signed in browsers where they were going
+func (s *Server) getLogin(w http.ResponseWriter, r *http.Request) {
+	next := safeRedirect(r.URL.Query().Get("next"))
+	if s.authenticated(r) {
+		http.Redirect(w, r, next, http.StatusSeeOther)
+		return
+	}
+	s.renderLogin(w, r, http.StatusOK, next, "")
+}
+
+// postLogin checks the credential of the login form and sets the session cookie
+func (s *Server) postLogin(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	next := safeRedirect(r.PostForm.Get("next"))
+
+	if !s.validToken(r.PostForm.Get("token")) && !s.validPassword(r.PostForm.Get("username"), r.PostForm.Get("password")) {
+		s.renderLogin(w, r, http.StatusUnauthorized, next, "Invalid credentials")
+		return
+	}
+
+	expires := time.Now().Add(sessionDuration)
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    s.sessionValue(expires),
+		Path:     "/",
+		Expires:  expires,
+		MaxAge:   int(sessionDuration.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, next, http.StatusSeeOther)
+}
+
+func (s *Server) renderLogin(w http.ResponseWriter, r *http.Request, status int, next, errorMessage string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if r.Method == http.MethodHead {
+		return
+	}
+	withPassword := s.cfg.BasicAuthUser != "" || s.cfg.BasicAuthPass != ""
+	_ = s.rs.Login(next, errorMessage, s.cfg.AuthToken != "", withPassword).Render(w)
+}
+
+// safeRedirect keeps the login redirects on the site: anything but a local path goes home
+func safeRedirect(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
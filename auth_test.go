@@ -0,0 +1,253 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/EwenQuim/pluie/config"
+	"github.com/EwenQuim/pluie/engine"
+	"github.com/EwenQuim/pluie/template"
+	"github.com/go-fuego/fuego"
+)
+
+func newAuthTestHandler(t *testing.T, cfg *config.Config) (*Server, http.Handler) {
+	t.Helper()
+	dir := t.TempDir()
+	writeTestFile(t, dir, "hello.md", "---\npublish: true\n---\n# Hello\n\nWorld.\n")
+
+	notesMap, tree, tagIndex, err := loadNotes(dir, cfg)
+	if err != nil {
+		t.Fatalf("loadNotes error: %v", err)
+	}
+	server := &Server{
+		NotesService: engine.NewNotesService(notesMap, tree, tagIndex),
+		rs:           template.NewResource(cfg),
+		cfg:          cfg,
+	}
+	fuegoServer := fuego.NewServer()
+	server.registerRoutes(fuegoServer)
+	return server, server.authMiddleware(fuegoServer.Mux)
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	cfg := &config.Config{SiteTitle: "My Garden", AuthToken: "s3cret-token", BasicAuthUser: "alice", BasicAuthPass: "wonderland", MetricsEnabled: true, MetricsToken: "metrics-token", CORSOrigins: "https://editor.example.com"}
+	server, handler := newAuthTestHandler(t, cfg)
+
+	serve := func(r *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	t.Run("no credential gets a 401 with the basic auth challenge", func(t *testing.T) {
+		w := serve(httptest.NewRequest(http.MethodGet, "/hello", nil))
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("status = %d, want 401", w.Code)
+		}
+		if got := w.Header().Get("WWW-Authenticate"); got != `Basic realm="pluie"` {
+			t.Errorf("WWW-Authenticate = %q", got)
+		}
+		if strings.Contains(w.Body.String(), "World.") {
+			t.Error("the note leaked without a credential")
+		}
+	})
+
+	t.Run("browsers get the login page", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/hello?search=x", nil)
+		r.Header.Set("Accept", "text/html,application/xhtml+xml")
+		w := serve(r)
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("status = %d, want 401", w.Code)
+		}
+		body := w.Body.String()
+		for _, want := range []string{`id="login"`, `action="/-/login"`, `name="next" value="/hello?search=x"`, `name="token"`, `name="username"`, `name="password"`} {
+			if !strings.Contains(body, want) {
+				t.Errorf("login page missing %q", want)
+			}
+		}
+	})
+
+	t.Run("bearer token", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/hello", nil)
+		r.Header.Set("Authorization", "Bearer s3cret-token")
+		if w := serve(r); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "World.") {
+			t.Errorf("status = %d, want the note", w.Code)
+		}
+
+		r = httptest.NewRequest(http.MethodGet, "/hello", nil)
+		r.Header.Set("Authorization", "Bearer wrong")
+		if w := serve(r); w.Code != http.StatusUnauthorized {
+			t.Errorf("wrong token status = %d, want 401", w.Code)
+		}
+	})
+
+	t.Run("basic auth", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/hello", nil)
+		r.SetBasicAuth("alice", "wonderland")
+		if w := serve(r); w.Code != http.StatusOK {
+			t.Errorf("status = %d, want 200", w.Code)
+		}
+
+		r = httptest.NewRequest(http.MethodGet, "/hello", nil)
+		r.SetBasicAuth("alice", "wrong")
+		if w := serve(r); w.Code != http.StatusUnauthorized {
+			t.Errorf("wrong password status = %d, want 401", w.Code)
+		}
+	})
+
+	t.Run("static assets are protected", func(t *testing.T) {
+		if w := serve(httptest.NewRequest(http.MethodGet, "/static/tailwind.min.css", nil)); w.Code != http.StatusUnauthorized {
+			t.Errorf("status = %d, want 401", w.Code)
+		}
+	})
+
+	t.Run("SSE routes accept the token as query parameter", func(t *testing.T) {
+		if w := serve(httptest.NewRequest(http.MethodGet, "/-/embedding-progress?access_token=wrong", nil)); w.Code != http.StatusUnauthorized {
+			t.Errorf("wrong token status = %d, want 401", w.Code)
+		}
+		if !server.authenticated(httptest.NewRequest(http.MethodGet, "/-/search-stream?q=x&access_token=s3cret-token", nil)) {
+			t.Error("search stream with the token is not authenticated")
+		}
+		if server.authenticated(httptest.NewRequest(http.MethodGet, "/hello?access_token=s3cret-token", nil)) {
+			t.Error("the query parameter is only accepted on the SSE routes")
+		}
+	})
+
+	t.Run("exempt routes", func(t *testing.T) {
+		for _, path := range []string{"/-/health", "/-/healthz", "/-/readyz"} {
+			if w := serve(httptest.NewRequest(http.MethodGet, path, nil)); w.Code != http.StatusOK {
+				t.Errorf("%s status = %d, want 200", path, w.Code)
+			}
+		}
+		r := httptest.NewRequest(http.MethodGet, "/-/metrics", nil)
+		r.Header.Set("Authorization", "Bearer metrics-token")
+		if w := serve(r); w.Code != http.StatusOK {
+			t.Errorf("metrics with its own token status = %d, want 200", w.Code)
+		}
+
+		// Browsers send the CORS preflight without credentials, the request itself needs them
+		r = httptest.NewRequest(http.MethodOptions, "/-/api/link-index", nil)
+		r.Header.Set("Origin", "https://editor.example.com")
+		if w := serve(r); w.Code != http.StatusNoContent {
+			t.Errorf("API preflight status = %d, want 204", w.Code)
+		}
+		r = httptest.NewRequest(http.MethodGet, "/-/api/link-index", nil)
+		r.Header.Set("Origin", "https://editor.example.com")
+		if w := serve(r); w.Code != http.StatusUnauthorized {
+			t.Errorf("API request without credential status = %d, want 401", w.Code)
+		}
+	})
+
+	t.Run("login form sets a session cookie", func(t *testing.T) {
+		form := url.Values{"token": {"s3cret-token"}, "next": {"/hello"}}
+		r := httptest.NewRequest(http.MethodPost, "/-/login", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := serve(r)
+		if w.Code != http.StatusSeeOther {
+			t.Fatalf("status = %d, want 303", w.Code)
+		}
+		if got := w.Header().Get("Location"); got != "/hello" {
+			t.Errorf("Location = %q, want /hello", got)
+		}
+		cookies := w.Result().Cookies()
+		if len(cookies) != 1 || cookies[0].Name != sessionCookieName || !cookies[0].HttpOnly {
+			t.Fatalf("cookies = %v, want an HttpOnly session cookie", cookies)
+		}
+
+		r = httptest.NewRequest(http.MethodGet, "/hello", nil)
+		r.AddCookie(cookies[0])
+		if w := serve(r); w.Code != http.StatusOK {
+			t.Errorf("status with the cookie = %d, want 200", w.Code)
+		}
+
+		r = httptest.NewRequest(http.MethodGet, "/hello", nil)
+		r.AddCookie(&http.Cookie{Name: sessionCookieName, Value: cookies[0].Value + "x"})
+		if w := serve(r); w.Code != http.StatusUnauthorized {
+			t.Errorf("status with a forged cookie = %d, want 401", w.Code)
+		}
+	})
+
+	t.Run("login form with basic auth credentials", func(t *testing.T) {
+		form := url.Values{"username": {"alice"}, "password": {"wonderland"}}
+		r := httptest.NewRequest(http.MethodPost, "/-/login", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if w := serve(r); w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/" {
+			t.Errorf("status = %d, Location = %q, want 303 to /", w.Code, w.Header().Get("Location"))
+		}
+	})
+
+	t.Run("login form with a wrong credential", func(t *testing.T) {
+		form := url.Values{"token": {"wrong"}, "next": {"/hello"}}
+		r := httptest.NewRequest(http.MethodPost, "/-/login", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := serve(r)
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("status = %d, want 401", w.Code)
+		}
+		if len(w.Result().Cookies()) != 0 {
+			t.Error("a cookie was set for a wrong credential")
+		}
+		if !strings.Contains(w.Body.String(), "Invalid credentials") {
+			t.Error("login page does not show the error")
+		}
+	})
+
+	t.Run("login redirects stay on the site", func(t *testing.T) {
+		for _, next := range []string{"https://evil.example", "//evil.example", "/\\evil.example"} {
+			form := url.Values{"token": {"s3cret-token"}, "next": {next}}
+			r := httptest.NewRequest(http.MethodPost, "/-/login", strings.NewReader(form.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if got := serve(r).Header().Get("Location"); got != "/" {
+				t.Errorf("next %q: Location = %q, want /", next, got)
+			}
+		}
+	})
+}
+
+func TestAuthMiddleware_TokenOnly(t *testing.T) {
+	cfg := &config.Config{SiteTitle: "My Garden", AuthToken: "s3cret-token"}
+	_, handler := newAuthTestHandler(t, cfg)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/hello", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want 401", w.Code)
+	}
+	if got := w.Header().Get("WWW-Authenticate"); got != "" {
+		t.Errorf("WWW-Authenticate = %q, want none without basic auth", got)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/-/login", nil))
+	if body := w.Body.String(); !strings.Contains(body, `name="token"`) || strings.Contains(body, `name="password"`) {
+		t.Error("token-only login page should only ask for the token")
+	}
+}
+
+func TestValidPassword_IncompleteCredentials(t *testing.T) {
+	server := &Server{cfg: &config.Config{BasicAuthUser: "alice"}}
+	if server.validPassword("alice", "") {
+		t.Error("a user without password must accept no basic auth")
+	}
+}
+
+func TestValidSession_Expired(t *testing.T) {
+	server := &Server{cfg: &config.Config{AuthToken: "s3cret-token"}}
+	now := time.Now()
+	value := server.sessionValue(now.Add(time.Hour))
+	if !server.validSession(value, now) {
+		t.Error("session not valid before its expiry")
+	}
+	if server.validSession(value, now.Add(2*time.Hour)) {
+		t.Error("session still valid after its expiry")
+	}
+
+	other := &Server{cfg: &config.Config{AuthToken: "rotated-token"}}
+	if other.validSession(value, now) {
+		t.Error("session still valid after the token changed")
+	}
+}
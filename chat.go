@@ -1,50 +1,353 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/EwenQuim/pluie/ai"
 	"github.com/EwenQuim/pluie/config"
+	"github.com/EwenQuim/pluie/engine"
 	"github.com/tmc/langchaingo/llms"
 	"github.com/tmc/langchaingo/llms/mistral"
 	"github.com/tmc/langchaingo/llms/ollama"
 	"github.com/tmc/langchaingo/llms/openai"
 )
 
-// initializeChatClient creates a chat client based on the configured provider
-func initializeChatClient(cfg *config.Config) (llms.Model, error) {
+// ChatProvider is a chat backend of the failover chain
+type ChatProvider interface {
+	// Name identifies the provider in logs, metrics and the "provider" SSE event, like "ollama/llama3"
+	Name() string
+	// Timeout is the maximum wait for the first token before trying the next provider, 0 for none
+	Timeout() time.Duration
+	// Stream generates the answer to prompt, calling onChunk for every streamed chunk
+	Stream(ctx context.Context, prompt ai.Prompt, onChunk func(ctx context.Context, chunk []byte) error, options ...llms.CallOption) error
+}
+
+// llmProvider is a ChatProvider backed by a langchaingo model
+type llmProvider struct {
+	name    string
+	model   llms.Model
+	timeout time.Duration
+}
+
+func (p llmProvider) Name() string           { return p.name }
+func (p llmProvider) Timeout() time.Duration { return p.timeout }
+
+func (p llmProvider) Stream(ctx context.Context, prompt ai.Prompt, onChunk func(ctx context.Context, chunk []byte) error, options ...llms.CallOption) error {
+	_, err := p.model.GenerateContent(ctx, promptMessages(prompt), append(options, llms.WithStreamingFunc(onChunk))...)
+	return err
+}
+
+// promptMessages returns the messages of prompt with their roles: the system prompt, the turns
+// of the conversation, then the question with its context
+func promptMessages(prompt ai.Prompt) []llms.MessageContent {
+	messages := make([]llms.MessageContent, 0, 2*len(prompt.History)+2)
+	if prompt.System != "" {
+		messages = append(messages, llms.TextParts(llms.ChatMessageTypeSystem, prompt.System))
+	}
+	for _, turn := range prompt.History {
+		messages = append(messages,
+			llms.TextParts(llms.ChatMessageTypeHuman, turn.Question),
+			llms.TextParts(llms.ChatMessageTypeAI, turn.Answer),
+		)
+	}
+	return append(messages, llms.TextParts(llms.ChatMessageTypeHuman, prompt.User))
+}
+
+// promptTemplate returns the template of the prompts of the AI answers, parsed from
+// PROMPT_TEMPLATE, else from the vault's _pluie/prompt.md, else the built-in one. The system
+// prompt is SYSTEM_PROMPT, else the "system" frontmatter of _pluie/prompt.md. An invalid
+// template is logged once and replaced by the built-in one.
+func (s *Server) promptTemplate() *ai.PromptTemplate {
+	text, system, origin := s.cfg.PromptTemplate, s.cfg.SystemPrompt, "PROMPT_TEMPLATE"
+	if note, ok := s.NotesService.SystemNote(engine.SystemNotePrompt); ok {
+		if text == "" {
+			text, origin = note.Content, note.Path
+		}
+		if system == "" {
+			system, _ = note.Metadata["system"].(string)
+		}
+	}
+
+	s.promptMu.Lock()
+	defer s.promptMu.Unlock()
+
+	source := text + "\x00" + system
+	if s.prompt != nil && s.promptSource == source {
+		return s.prompt
+	}
+	prompt, err := ai.ParsePromptTemplate(text, system)
+	if err != nil {
+		slog.Warn("Invalid prompt template, using the built-in one", "template", origin, "error", err)
+		prompt, _ = ai.ParsePromptTemplate("", system)
+	}
+	s.prompt, s.promptSource = prompt, source
+	return prompt
+}
+
+// contextBudget is the budget of the context of the AI answers, with AI_CONTEXT_CHARS per note
+func (s *Server) contextBudget() ai.ContextBudget {
+	budget := ai.DefaultContextBudget()
+	if s.cfg.AIContextChars > 0 {
+		budget.NoteChars = s.cfg.AIContextChars
+	}
+	return budget
+}
+
+// initializeChatChain creates the configured chat providers, in failover order.
+// Providers that cannot be created are skipped.
+func initializeChatChain(cfg *config.Config) (*ChatChain, error) {
+	var providers []ChatProvider
+	for _, providerConfig := range cfg.ChatChain {
+		provider, err := newChatProvider(providerConfig)
+		if err != nil {
+			slog.Warn("Failed to initialize chat provider, skipping it", "provider", providerConfig.Type, "model", providerConfig.Model, "error", err)
+			continue
+		}
+		providers = append(providers, provider)
+	}
+	if len(providers) == 0 {
+		return nil, errors.New("no chat provider could be initialized")
+	}
+
+	return NewChatChain(providers, time.Duration(cfg.ChatCooldownSeconds)*time.Second), nil
+}
+
+// newChatProvider creates the chat client of one provider of the chain
+func newChatProvider(cfg config.ChatProviderConfig) (ChatProvider, error) {
 	slog.Info("Initializing chat client",
-		"provider", cfg.ChatProvider,
-		"model", cfg.ChatModel)
+		"provider", cfg.Type,
+		"model", cfg.Model,
+		"timeout", cfg.Timeout.String())
 
-	switch cfg.ChatProvider {
+	var model llms.Model
+	var err error
+	switch cfg.Type {
 	case "ollama":
 		// Create Ollama client for local models
-		return ollama.New(
-			ollama.WithServerURL(cfg.OllamaURL),
-			ollama.WithModel(cfg.ChatModel),
+		model, err = ollama.New(
+			ollama.WithServerURL(cfg.BaseURL),
+			ollama.WithModel(cfg.Model),
 		)
 
 	case "mistral":
 		// Create Mistral API client
-		if cfg.MistralAPIKey == "" {
-			return nil, fmt.Errorf("MISTRAL_API_KEY is required when using mistral provider")
+		if cfg.APIKey == "" {
+			return nil, fmt.Errorf("an API key is required when using mistral provider, set MISTRAL_API_KEY")
 		}
-		return mistral.New(
-			mistral.WithAPIKey(cfg.MistralAPIKey),
-			mistral.WithModel(cfg.ChatModel),
-		)
+		options := []mistral.Option{mistral.WithAPIKey(cfg.APIKey), mistral.WithModel(cfg.Model)}
+		if cfg.BaseURL != "" {
+			options = append(options, mistral.WithEndpoint(cfg.BaseURL))
+		}
+		model, err = mistral.New(options...)
 
 	case "openai":
 		// Create OpenAI API client
-		if cfg.OpenAIAPIKey == "" {
-			return nil, fmt.Errorf("OPENAI_API_KEY is required when using openai provider")
+		if cfg.APIKey == "" {
+			return nil, fmt.Errorf("an API key is required when using openai provider, set OPENAI_API_KEY")
 		}
-		return openai.New(
-			openai.WithToken(cfg.OpenAIAPIKey),
-			openai.WithModel(cfg.ChatModel),
-		)
+		options := []openai.Option{openai.WithToken(cfg.APIKey), openai.WithModel(cfg.Model)}
+		if cfg.BaseURL != "" {
+			options = append(options, openai.WithBaseURL(cfg.BaseURL))
+		}
+		model, err = openai.New(options...)
+
+	default:
+		return nil, fmt.Errorf("unsupported chat provider: %s", cfg.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return llmProvider{name: cfg.Type + "/" + cfg.Model, model: model, timeout: cfg.Timeout}, nil
+}
+
+// ChatChain tries its providers in order for every request, moving to the next one when a provider
+// fails or times out before streaming anything. A failing provider is skipped for a cooldown period.
+type ChatChain struct {
+	providers []ChatProvider
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	downUntil []time.Time      // Per provider, skipped until then
+	now       func() time.Time // Replaced in tests
+}
+
+// NewChatChain creates a failover chain of providers, tried in the given order
+func NewChatChain(providers []ChatProvider, cooldown time.Duration) *ChatChain {
+	return &ChatChain{
+		providers: providers,
+		cooldown:  cooldown,
+		downUntil: make([]time.Time, len(providers)),
+		now:       time.Now,
 	}
+}
+
+// errClient marks errors of the onProvider and onChunk callbacks, which must not trigger a failover
+type errClient struct{ err error }
+
+func (e errClient) Error() string { return e.err.Error() }
+func (e errClient) Unwrap() error { return e.err }
+
+// Generate streams the answer to prompt from the first provider that answers.
+// onProvider is called with the name of the answering provider, before its first chunk.
+// There is no failover once a provider streamed a chunk, on callback errors, or when ctx is canceled:
+// the client is gone. Returns the name of the last provider tried.
+// With llms.WithModel, every provider is asked for that model, and named after it: a failure
+// then says nothing of the default model, so it doesn't put the provider in cooldown.
+func (c *ChatChain) Generate(ctx context.Context, prompt ai.Prompt, onProvider func(name string) error, onChunk func(ctx context.Context, chunk []byte) error, options ...llms.CallOption) (string, error) {
+	var callOptions llms.CallOptions
+	for _, option := range options {
+		option(&callOptions)
+	}
+
+	var errs []error
+	previous := ""
+	for _, i := range c.available() {
+		provider := c.providers[i]
+		name := providerName(provider, callOptions.Model)
+		if previous != "" {
+			llmFailoversTotal.Inc(previous)
+			slog.Warn("Chat provider failed, trying the next one", "failed", previous, "next", name, "error", errs[len(errs)-1])
+		}
+
+		announce := func(string) error { return onProvider(name) }
+		started, err := c.try(ctx, provider, prompt, announce, onChunk, options)
+		if err == nil {
+			if callOptions.Model == "" {
+				c.setDown(i, time.Time{})
+			}
+			if !started {
+				// Empty answer: still tell which provider answered
+				if err := onProvider(name); err != nil {
+					return name, err
+				}
+			}
+			return name, nil
+		}
+
+		var clientErr errClient
+		if errors.As(err, &clientErr) {
+			return name, clientErr.err
+		}
+		if ctx.Err() != nil {
+			return name, err
+		}
+
+		if callOptions.Model == "" {
+			c.setDown(i, c.now().Add(c.cooldown))
+		}
+		if started {
+			return name, err
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		previous = name
+	}
+
+	if len(errs) == 0 {
+		return "", errors.New("no chat provider available")
+	}
+	return previous, errors.Join(errs...)
+}
+
+// providerName returns the name of provider, "type/model", with model instead of its own when set
+func providerName(provider ChatProvider, model string) string {
+	if model == "" {
+		return provider.Name()
+	}
+	kind, _, _ := strings.Cut(provider.Name(), "/")
+	return kind + "/" + model
+}
+
+// try streams the answer of one provider, reporting whether it streamed at least a chunk
+func (c *ChatChain) try(ctx context.Context, provider ChatProvider, prompt ai.Prompt, onProvider func(name string) error, onChunk func(ctx context.Context, chunk []byte) error, options []llms.CallOption) (bool, error) {
+	attemptCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	const (
+		waiting int32 = iota
+		streaming
+		timedOut
+	)
+	var state atomic.Int32
+	var clientErr error // Callbacks run on the Stream goroutine
+	if timeout := provider.Timeout(); timeout > 0 {
+		timer := time.AfterFunc(timeout, func() {
+			if state.CompareAndSwap(waiting, timedOut) {
+				cancel()
+			}
+		})
+		defer timer.Stop()
+	}
+
+	err := provider.Stream(attemptCtx, prompt, func(ctx context.Context, chunk []byte) error {
+		if state.CompareAndSwap(waiting, streaming) {
+			if err := onProvider(provider.Name()); err != nil {
+				clientErr = err
+				return err
+			}
+		} else if state.Load() == timedOut {
+			return context.Canceled
+		}
+		if err := onChunk(ctx, chunk); err != nil {
+			clientErr = err
+			return err
+		}
+		return nil
+	}, options...)
+
+	if clientErr != nil {
+		return true, errClient{clientErr}
+	}
+	if state.Load() == timedOut && ctx.Err() == nil {
+		return false, fmt.Errorf("no answer within %s", provider.Timeout())
+	}
+	return state.Load() == streaming, err
+}
+
+// available returns the indexes of the providers to try, skipping the ones in cooldown.
+// When every provider is in cooldown, they are all tried anyway.
+func (c *ChatChain) available() []int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	var available, all []int
+	for i := range c.providers {
+		all = append(all, i)
+		if !now.Before(c.downUntil[i]) {
+			available = append(available, i)
+		}
+	}
+	if len(available) == 0 {
+		return all
+	}
+	return available
+}
+
+// Providers returns the names of the providers, in failover order, and whether each one is in
+// cooldown. It never calls them.
+func (c *ChatChain) Providers() (names []string, down []bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	for i, provider := range c.providers {
+		names = append(names, provider.Name())
+		down = append(down, now.Before(c.downUntil[i]))
+	}
+	return names, down
+}
 
-	return nil, fmt.Errorf("unsupported chat provider: %s", cfg.ChatProvider)
+// setDown records until when provider i is skipped, the zero time for a healthy provider
+func (c *ChatChain) setDown(i int, until time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.downUntil[i] = until
 }
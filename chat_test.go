@@ -1,45 +1,295 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/EwenQuim/pluie/ai"
 	"github.com/EwenQuim/pluie/config"
+	"github.com/EwenQuim/pluie/engine"
+	"github.com/EwenQuim/pluie/template"
+	"github.com/tmc/langchaingo/llms"
 )
 
 func TestInitializeChatClientMissingMistralKey(t *testing.T) {
-	cfg := &config.Config{
-		ChatProvider:  "mistral",
-		ChatModel:     "mistral-small",
-		MistralAPIKey: "",
-	}
-
-	_, err := initializeChatClient(cfg)
+	_, err := newChatProvider(config.ChatProviderConfig{
+		Type:   "mistral",
+		Model:  "mistral-small",
+		APIKey: "",
+	})
 	if err == nil {
 		t.Fatal("expected error for missing Mistral API key")
 	}
 }
 
 func TestInitializeChatClientMissingOpenAIKey(t *testing.T) {
-	cfg := &config.Config{
-		ChatProvider: "openai",
-		ChatModel:    "gpt-4",
-		OpenAIAPIKey: "",
-	}
-
-	_, err := initializeChatClient(cfg)
+	_, err := newChatProvider(config.ChatProviderConfig{
+		Type:   "openai",
+		Model:  "gpt-4",
+		APIKey: "",
+	})
 	if err == nil {
 		t.Fatal("expected error for missing OpenAI API key")
 	}
 }
 
 func TestInitializeChatClientUnknownProvider(t *testing.T) {
-	cfg := &config.Config{
-		ChatProvider: "unknown-provider",
-		ChatModel:    "model",
-	}
-
-	_, err := initializeChatClient(cfg)
+	_, err := newChatProvider(config.ChatProviderConfig{
+		Type:  "unknown-provider",
+		Model: "model",
+	})
 	if err == nil {
 		t.Fatal("expected error for unknown provider")
 	}
 }
+
+func TestInitializeChatChainSkipsBrokenProviders(t *testing.T) {
+	cfg := &config.Config{ChatChain: []config.ChatProviderConfig{
+		{Type: "mistral", Model: "mistral-small"}, // No API key
+		{Type: "ollama", Model: "llama3", BaseURL: "http://localhost:11434"},
+	}}
+
+	chain, err := initializeChatChain(cfg)
+	if err != nil {
+		t.Fatalf("initializeChatChain() error: %v", err)
+	}
+	if len(chain.providers) != 1 || chain.providers[0].Name() != "ollama/llama3" {
+		t.Errorf("expected only the ollama provider, got %v", chain.providers)
+	}
+
+	if _, err := initializeChatChain(&config.Config{ChatChain: cfg.ChatChain[:1]}); err == nil {
+		t.Error("expected an error when no provider can be created")
+	}
+}
+
+// fakeChatProvider answers with chunks, or fails with err before streaming anything
+type fakeChatProvider struct {
+	name    string
+	chunks  []string
+	err     error
+	delay   time.Duration // Before the first chunk
+	timeout time.Duration
+	calls   int
+	prompt  ai.Prompt // Of the last call
+}
+
+func (p *fakeChatProvider) Name() string           { return p.name }
+func (p *fakeChatProvider) Timeout() time.Duration { return p.timeout }
+
+func (p *fakeChatProvider) Stream(ctx context.Context, prompt ai.Prompt, onChunk func(ctx context.Context, chunk []byte) error, _ ...llms.CallOption) error {
+	p.calls++
+	p.prompt = prompt
+	if p.delay > 0 {
+		select {
+		case <-time.After(p.delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if p.err != nil {
+		return p.err
+	}
+	for _, chunk := range p.chunks {
+		if err := onChunk(ctx, []byte(chunk)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// generate runs the chain and returns the SSE-like events it produced
+func generate(ctx context.Context, chain *ChatChain, options ...llms.CallOption) (string, []string, error) {
+	var events []string
+	provider, err := chain.Generate(ctx, ai.Prompt{User: "question"},
+		func(name string) error {
+			events = append(events, "provider:"+name)
+			return nil
+		},
+		func(_ context.Context, chunk []byte) error {
+			events = append(events, "token:"+string(chunk))
+			return nil
+		},
+		options...,
+	)
+	return provider, events, err
+}
+
+func TestChatChain_FirstSuccess(t *testing.T) {
+	local := &fakeChatProvider{name: "ollama/llama3", chunks: []string{"Hel", "lo"}}
+	hosted := &fakeChatProvider{name: "mistral/small", chunks: []string{"Hi"}}
+	chain := NewChatChain([]ChatProvider{local, hosted}, time.Minute)
+
+	provider, events, err := generate(context.Background(), chain)
+	if err != nil {
+		t.Fatalf("Generate() error: %v", err)
+	}
+	if provider != "ollama/llama3" || fmt.Sprint(events) != "[provider:ollama/llama3 token:Hel token:lo]" {
+		t.Errorf("Generate() = %q with events %v", provider, events)
+	}
+	if hosted.calls != 0 {
+		t.Error("the next provider should not be called when the first one answers")
+	}
+}
+
+func TestChatChain_FailoverOnError(t *testing.T) {
+	before := llmFailoversTotal.Value("ollama/llama3")
+
+	tests := []struct {
+		name  string
+		local *fakeChatProvider
+	}{
+		{name: "connection error", local: &fakeChatProvider{name: "ollama/llama3", err: errors.New("connection refused")}},
+		{name: "timeout", local: &fakeChatProvider{name: "ollama/llama3", chunks: []string{"late"}, delay: time.Second, timeout: 10 * time.Millisecond}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hosted := &fakeChatProvider{name: "mistral/small", chunks: []string{"Hi"}}
+			chain := NewChatChain([]ChatProvider{tt.local, hosted}, time.Minute)
+
+			provider, events, err := generate(context.Background(), chain)
+			if err != nil {
+				t.Fatalf("Generate() error: %v", err)
+			}
+			if provider != "mistral/small" || fmt.Sprint(events) != "[provider:mistral/small token:Hi]" {
+				t.Errorf("Generate() = %q with events %v", provider, events)
+			}
+		})
+	}
+
+	if got := llmFailoversTotal.Value("ollama/llama3") - before; got != 2 {
+		t.Errorf("expected 2 failovers recorded, got %v", got)
+	}
+
+	// Every provider fails: the errors are joined
+	chain := NewChatChain([]ChatProvider{
+		&fakeChatProvider{name: "a", err: errors.New("a is down")},
+		&fakeChatProvider{name: "b", err: errors.New("b is down")},
+	}, time.Minute)
+	if _, _, err := generate(context.Background(), chain); err == nil || !strings.Contains(err.Error(), "a is down") || !strings.Contains(err.Error(), "b is down") {
+		t.Errorf("expected both errors, got %v", err)
+	}
+}
+
+func TestChatChain_CooldownSkipsDownProvider(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	local := &fakeChatProvider{name: "ollama/llama3", err: errors.New("connection refused")}
+	hosted := &fakeChatProvider{name: "mistral/small", chunks: []string{"Hi"}}
+	chain := NewChatChain([]ChatProvider{local, hosted}, time.Minute)
+	chain.now = func() time.Time { return now }
+
+	for range 3 {
+		if provider, _, err := generate(context.Background(), chain); err != nil || provider != "mistral/small" {
+			t.Fatalf("Generate() = %q, %v", provider, err)
+		}
+	}
+	if local.calls != 1 {
+		t.Errorf("a failing provider should be skipped during its cooldown, called %d times", local.calls)
+	}
+
+	// After the cooldown, the first provider is tried again and recovers
+	now = now.Add(time.Minute)
+	local.err = nil
+	local.chunks = []string{"Back"}
+	if provider, _, err := generate(context.Background(), chain); err != nil || provider != "ollama/llama3" {
+		t.Errorf("Generate() = %q, %v, want the first provider after its cooldown", provider, err)
+	}
+}
+
+func TestChatChain_RequestedModel(t *testing.T) {
+	local := &fakeChatProvider{name: "ollama/tinyllama", err: errors.New("model not found")}
+	hosted := &fakeChatProvider{name: "mistral/small", chunks: []string{"Hi"}}
+	chain := NewChatChain([]ChatProvider{local, hosted}, time.Minute)
+
+	provider, events, err := generate(context.Background(), chain, llms.WithModel("llama3"))
+	if err != nil || provider != "mistral/llama3" || fmt.Sprint(events) != "[provider:mistral/llama3 token:Hi]" {
+		t.Errorf("Generate() = %q, %v with events %v, want the next provider named after the model", provider, err, events)
+	}
+
+	// The default model of the failed provider may be fine
+	if available := chain.available(); len(available) != 2 {
+		t.Errorf("a requested model failing should not put its provider in cooldown, available: %v", available)
+	}
+}
+
+func TestChatChain_NoFailoverOnClientCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	local := &fakeChatProvider{name: "ollama/llama3", chunks: []string{"late"}, delay: time.Second}
+	hosted := &fakeChatProvider{name: "mistral/small", chunks: []string{"Hi"}}
+	chain := NewChatChain([]ChatProvider{local, hosted}, time.Minute)
+
+	time.AfterFunc(10*time.Millisecond, cancel)
+	_, events, err := generate(ctx, chain)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the cancellation error, got %v", err)
+	}
+	if hosted.calls != 0 || len(events) != 0 {
+		t.Errorf("a client cancellation should not fail over, got events %v", events)
+	}
+
+	// The provider is not marked down by the client leaving
+	if available := chain.available(); len(available) != 2 {
+		t.Errorf("expected both providers available, got %v", available)
+	}
+}
+
+func TestServer_PromptTemplate(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "_pluie/prompt.md", "---\nsystem: You are a gardener.\n---\nNotes: {{.Context}}\nAsked: {{.Query}}")
+
+	cfg := &config.Config{Path: dir}
+	notesMap, tree, tagIndex, err := loadNotes(dir, cfg)
+	if err != nil {
+		t.Fatalf("loadNotes() error: %v", err)
+	}
+	server := &Server{NotesService: engine.NewNotesService(notesMap, tree, tagIndex), rs: template.NewResource(cfg), cfg: cfg}
+	build := func() ai.Prompt {
+		t.Helper()
+		prompt, err := server.promptTemplate().Build("When to plant?", nil)
+		if err != nil {
+			t.Fatalf("Build() error: %v", err)
+		}
+		return prompt
+	}
+
+	if prompt := build(); !strings.HasPrefix(prompt.User, "Notes: ") || !strings.HasSuffix(prompt.User, "Asked: When to plant?") || prompt.System != "You are a gardener." {
+		t.Errorf("prompt = %+v, want _pluie/prompt.md and its system prompt", prompt)
+	}
+
+	// The environment wins over the vault
+	cfg.PromptTemplate, cfg.SystemPrompt = "Q: {{.Query}}", "Be brief."
+	if prompt := build(); prompt.User != "Q: When to plant?" || prompt.System != "Be brief." {
+		t.Errorf("prompt = %+v, want PROMPT_TEMPLATE and SYSTEM_PROMPT", prompt)
+	}
+
+	// An invalid template falls back to the built-in one, keeping the system prompt
+	cfg.PromptTemplate = "Q: {{.Question}}"
+	if prompt := build(); !strings.Contains(prompt.User, "Question: When to plant?") || prompt.System != "Be brief." {
+		t.Errorf("prompt = %+v, want the built-in template", prompt)
+	}
+}
+
+func TestPromptMessages(t *testing.T) {
+	messages := promptMessages(ai.Prompt{
+		System:  "Be brief.",
+		History: []ai.Turn{{Question: "What to plant?", Answer: "Tomatoes."}},
+		User:    "When?",
+	})
+
+	var roles, texts []string
+	for _, message := range messages {
+		roles = append(roles, string(message.Role))
+		texts = append(texts, fmt.Sprint(message.Parts))
+	}
+	if fmt.Sprint(roles) != "[system human ai human]" || fmt.Sprint(texts) != "[[Be brief.] [What to plant?] [Tomatoes.] [When?]]" {
+		t.Errorf("promptMessages() = %v %v, want the system prompt, the turns then the question", roles, texts)
+	}
+
+	if messages := promptMessages(ai.Prompt{User: "When?"}); len(messages) != 1 || messages[0].Role != llms.ChatMessageTypeHuman {
+		t.Errorf("promptMessages() without system prompt nor history = %+v, want the question only", messages)
+	}
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"log/slog"
+
+	"github.com/EwenQuim/pluie/engine"
+)
+
+// runCheck logs every diagnostic of the vault as a warning and returns how many were found
+func runCheck(notesService *engine.NotesService) int {
+	diagnostics := notesService.Diagnostics()
+	for _, diagnostic := range diagnostics {
+		slog.Warn(diagnostic.Message, "kind", diagnostic.Kind, "paths", diagnostic.Paths)
+	}
+
+	if len(diagnostics) == 0 {
+		slog.Info("Check completed, no problems found")
+	} else {
+		slog.Info("Check completed", "problems", len(diagnostics))
+	}
+	return len(diagnostics)
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/EwenQuim/pluie/engine"
+	"github.com/EwenQuim/pluie/model"
+)
+
+func TestRunCheck(t *testing.T) {
+	content := strings.Repeat("Attendees discussed the migration plan and the database upgrade schedule. ", 10)
+	notes := []model.Note{
+		{Title: "Meeting notes", Slug: "meeting-notes", Path: "Meeting notes.md", Content: content},
+		{Title: "Meeting notes 1", Slug: "meeting-notes-1", Path: "Meeting notes 1.md", Content: content},
+		{Title: "Other", Slug: "other", Path: "Other.md", Content: "Something else entirely."},
+	}
+	notesMap := make(map[string]model.Note)
+	for _, note := range notes {
+		notesMap[note.Slug] = note
+	}
+
+	if got := runCheck(engine.NewNotesService(&notesMap, engine.BuildTree(notes), nil)); got != 1 {
+		t.Errorf("runCheck() = %d, want 1", got)
+	}
+
+	notesMap = map[string]model.Note{"other": notes[2]}
+	if got := runCheck(engine.NewNotesService(&notesMap, engine.BuildTree(notes[2:]), nil)); got != 0 {
+		t.Errorf("runCheck() = %d, want 0", got)
+	}
+}
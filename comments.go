@@ -0,0 +1,381 @@
+package main
+
+import (
+	"cmp"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/EwenQuim/pluie/engine"
+	"github.com/EwenQuim/pluie/model"
+)
+
+const (
+	// commentNameMaxLength and commentTextMaxLength bound the comments, in characters
+	commentNameMaxLength = 80
+	commentTextMaxLength = 2000
+	// commentsPerNoteMax bounds the comments of a note, the oldest stay
+	commentsPerNoteMax = 500
+	// commentHoneypotField is a form field hidden from readers, only bots fill it
+	commentHoneypotField = "website"
+
+	// commentRateLimit comments per commentRateWindow are accepted from an IP address
+	commentRateLimit  = 5
+	commentRateWindow = 10 * time.Minute
+)
+
+// ErrCommentNotFound is returned when deleting a comment that does not exist
+var ErrCommentNotFound = errors.New("comment not found")
+
+// CommentStore keeps the comments of the notes, by slug. Implementations must be safe for
+// concurrent use.
+type CommentStore interface {
+	// List returns the comments of a note, oldest first
+	List(slug string) ([]model.Comment, error)
+	// Add stores a new comment of a note, setting its ID
+	Add(slug string, comment model.Comment) (model.Comment, error)
+	// Delete removes a comment of a note, ErrCommentNotFound when there is none with id
+	Delete(slug, id string) error
+	// All returns the comments of every note having some, by slug, oldest first
+	All() (map[string][]model.Comment, error)
+}
+
+// FileCommentStore keeps the comments in a folder, a JSON file per note
+type FileCommentStore struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// commentsFile is the content of the file of a note, the slug is kept since it can't be read
+// back from the file name
+type commentsFile struct {
+	Slug     string          `json:"slug"`
+	Comments []model.Comment `json:"comments"`
+}
+
+// NewFileCommentStore creates a store of the comments in dir, created if missing
+func NewFileCommentStore(dir string) (*FileCommentStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("creating comments folder: %w", err)
+	}
+	return &FileCommentStore{dir: dir}, nil
+}
+
+// path returns the file of the comments of slug, named after its hash: slugs have slashes and
+// characters file systems refuse
+func (s *FileCommentStore) path(slug string) string {
+	sum := sha256.Sum256([]byte(slug))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:16])+".json")
+}
+
+func (s *FileCommentStore) List(slug string) ([]model.Comment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	file, err := s.read(s.path(slug))
+	return file.Comments, err
+}
+
+func (s *FileCommentStore) Add(slug string, comment model.Comment) (model.Comment, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return model.Comment{}, fmt.Errorf("generating comment id: %w", err)
+	}
+	comment.ID = hex.EncodeToString(id)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := s.path(slug)
+	file, err := s.read(path)
+	if err != nil {
+		return model.Comment{}, err
+	}
+	if len(file.Comments) >= commentsPerNoteMax {
+		return model.Comment{}, fmt.Errorf("note %s has %d comments, the most accepted", slug, commentsPerNoteMax)
+	}
+	file.Slug = slug
+	file.Comments = append(file.Comments, comment)
+	return comment, s.write(path, file)
+}
+
+func (s *FileCommentStore) Delete(slug, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := s.path(slug)
+	file, err := s.read(path)
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(file.Comments, func(comment model.Comment) bool { return comment.ID == id })
+	if i < 0 {
+		return ErrCommentNotFound
+	}
+	file.Comments = slices.Delete(file.Comments, i, i+1)
+	if len(file.Comments) == 0 {
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("removing comments file: %w", err)
+		}
+		return nil
+	}
+	return s.write(path, file)
+}
+
+func (s *FileCommentStore) All() (map[string][]model.Comment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("listing comments files: %w", err)
+	}
+	all := make(map[string][]model.Comment, len(paths))
+	for _, path := range paths {
+		file, err := s.read(path)
+		if err != nil {
+			slog.Warn("Skipping unreadable comments file", "file", path, "error", err)
+			continue
+		}
+		if len(file.Comments) > 0 {
+			all[file.Slug] = file.Comments
+		}
+	}
+	return all, nil
+}
+
+// read reads a comments file, a missing file has no comments. Must be called with mu held.
+func (s *FileCommentStore) read(path string) (commentsFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return commentsFile{}, nil
+		}
+		return commentsFile{}, fmt.Errorf("reading comments file: %w", err)
+	}
+	var file commentsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return commentsFile{}, fmt.Errorf("parsing comments file %s: %w", path, err)
+	}
+	return file, nil
+}
+
+// write replaces a comments file, through a temporary file so a crash never leaves half of
+// it. Must be called with mu held.
+func (s *FileCommentStore) write(path string, file commentsFile) error {
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling comments: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("writing comments file: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("replacing comments file: %w", err)
+	}
+	return nil
+}
+
+// rateLimiter accepts limit events per window and key, like an IP address
+type rateLimiter struct {
+	mu     sync.Mutex
+	limit  int
+	window time.Duration
+	events map[string][]time.Time // Times of the accepted events of the window, by key
+	now    func() time.Time       // Replaced in tests
+}
+
+func newRateLimiter(limit int, window time.Duration) *rateLimiter {
+	return &rateLimiter{limit: limit, window: window, events: make(map[string][]time.Time), now: time.Now}
+}
+
+// Allow reports whether an event of key is accepted, and counts it if so
+func (l *rateLimiter) Allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	for k, times := range l.events {
+		times = slices.DeleteFunc(times, func(t time.Time) bool { return now.Sub(t) >= l.window })
+		if len(times) == 0 {
+			delete(l.events, k)
+		} else {
+			l.events[k] = times
+		}
+	}
+
+	if len(l.events[key]) >= l.limit {
+		return false
+	}
+	l.events[key] = append(l.events[key], now)
+	return true
+}
+
+// clientIP returns the address the request comes from, without its port. Forwarded headers
+// are ignored, anyone can set them.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// parseComment reads the comment of a form, the name defaulting to "Anonymous"
+func parseComment(name, text string, now time.Time) (model.Comment, error) {
+	name = strings.Join(strings.Fields(name), " ")
+	text = strings.TrimSpace(strings.ReplaceAll(text, "\r\n", "\n"))
+	switch {
+	case text == "":
+		return model.Comment{}, errors.New("the comment is empty")
+	case utf8.RuneCountInString(text) > commentTextMaxLength:
+		return model.Comment{}, fmt.Errorf("the comment is longer than %d characters", commentTextMaxLength)
+	case utf8.RuneCountInString(name) > commentNameMaxLength:
+		return model.Comment{}, fmt.Errorf("the name is longer than %d characters", commentNameMaxLength)
+	}
+	return model.Comment{Name: cmp.Or(name, "Anonymous"), Text: text, CreatedAt: now}, nil
+}
+
+// commentedNote returns the note of slug if readers can comment it: public, never private or
+// shared with a key
+func (s *Server) commentedNote(slug string) (model.Note, bool) {
+	note, ok := s.NotesService.GetNote(slug)
+	return note, ok && engine.IsVisible(note, s.cfg.PublicByDefault)
+}
+
+// getComments renders the comments of a note with the form, loaded by the note page
+func (s *Server) getComments(w http.ResponseWriter, r *http.Request) {
+	note, ok := s.commentedNote(r.PathValue("slug"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	s.renderComments(w, note, "")
+}
+
+// postComment stores the comment of the form. htmx gets the comments section back, with the
+// reason when the comment is refused, other browsers are sent back to the note.
+func (s *Server) postComment(w http.ResponseWriter, r *http.Request) {
+	note, ok := s.commentedNote(r.PathValue("slug"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	htmx := r.Header.Get("HX-Request") == "true"
+	refuse := func(status int, message string) {
+		if htmx {
+			s.renderComments(w, note, message)
+			return
+		}
+		http.Error(w, message, status)
+	}
+
+	// Bots fill every field, they are told it worked
+	if r.PostForm.Get(commentHoneypotField) != "" {
+		slog.Info("Comment honeypot filled, comment dropped", "slug", note.Slug, "ip", clientIP(r))
+	} else {
+		comment, err := parseComment(r.PostForm.Get("name"), r.PostForm.Get("text"), time.Now())
+		if err != nil {
+			refuse(http.StatusBadRequest, "Comment refused: "+err.Error()+".")
+			return
+		}
+		if !s.commentLimiter.Allow(clientIP(r)) {
+			slog.Info("Comment rate limit reached", "slug", note.Slug, "ip", clientIP(r))
+			refuse(http.StatusTooManyRequests, "Too many comments, try again in a few minutes.")
+			return
+		}
+		if _, err := s.comments.Add(note.Slug, comment); err != nil {
+			slog.Error("Failed to store comment", "slug", note.Slug, "error", err)
+			refuse(http.StatusInternalServerError, "The comment could not be saved, try again later.")
+			return
+		}
+		slog.Info("Comment posted", "slug", note.Slug, "name", comment.Name)
+	}
+
+	if htmx {
+		s.renderComments(w, note, "")
+		return
+	}
+	http.Redirect(w, r, "/"+note.Slug+"#comments", http.StatusSeeOther)
+}
+
+func (s *Server) renderComments(w http.ResponseWriter, note model.Note, message string) {
+	comments, err := s.comments.List(note.Slug)
+	if err != nil {
+		slog.Error("Failed to read comments", "slug", note.Slug, "error", err)
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := s.rs.Comments(note, comments, false, message).Render(w); err != nil {
+		slog.Debug("Comments response write failed", "error", err)
+	}
+}
+
+// commentsAdmin reports whether the request may list and delete comments: it needs the site
+// credential, so the admin is disabled without AUTH_TOKEN or basic auth
+func (s *Server) commentsAdmin(r *http.Request) bool {
+	return (s.cfg.AuthToken != "" || s.cfg.BasicAuthUser != "") && s.authenticated(r)
+}
+
+// getCommentsAdmin lists the comments of every note
+func (s *Server) getCommentsAdmin(w http.ResponseWriter, r *http.Request) {
+	if !s.commentsAdmin(r) {
+		http.NotFound(w, r)
+		return
+	}
+	all, err := s.comments.All()
+	if err != nil {
+		slog.Error("Failed to list comments", "error", err)
+		http.Error(w, "failed to list comments", http.StatusInternalServerError)
+		return
+	}
+	page, err := s.rs.CommentsAdmin(s.NotesService, all)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := page.Render(w); err != nil {
+		slog.Debug("Comments admin response write failed", "error", err)
+	}
+}
+
+// deleteComment deletes the comment ?id= of a note, from the admin page. Comments of notes
+// removed since can be deleted too.
+func (s *Server) deleteComment(w http.ResponseWriter, r *http.Request) {
+	if !s.commentsAdmin(r) {
+		http.NotFound(w, r)
+		return
+	}
+	slug, id := r.PathValue("slug"), r.URL.Query().Get("id")
+	err := s.comments.Delete(slug, id)
+	if errors.Is(err, ErrCommentNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to delete comment", "slug", slug, "id", id, "error", err)
+		http.Error(w, "failed to delete comment", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("Comment deleted", "slug", slug, "id", id)
+	w.WriteHeader(http.StatusOK) // htmx replaces the comment with the empty body
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/EwenQuim/pluie/config"
+	"github.com/EwenQuim/pluie/engine"
+	"github.com/EwenQuim/pluie/model"
+	"github.com/EwenQuim/pluie/template"
+	"github.com/go-fuego/fuego"
+)
+
+func TestFileCommentStore(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "comments")
+	store, err := NewFileCommentStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileCommentStore() error: %v", err)
+	}
+
+	first, err := store.Add("notes/q&a", model.Comment{Name: "Ada", Text: "Nice"})
+	if err != nil || first.ID == "" {
+		t.Fatalf("Add() = %+v, %v, want the comment with an id", first, err)
+	}
+	if _, err := store.Add("notes/q&a", model.Comment{Name: "Bob", Text: "Thanks"}); err != nil {
+		t.Fatalf("Add() error: %v", err)
+	}
+	if _, err := store.Add("garden", model.Comment{Name: "Cleo", Text: "Tomatoes"}); err != nil {
+		t.Fatalf("Add() error: %v", err)
+	}
+
+	// A restart finds the comments, a file per note
+	store, err = NewFileCommentStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	comments, err := store.List("notes/q&a")
+	if err != nil || len(comments) != 2 || comments[0].Name != "Ada" || comments[1].Name != "Bob" {
+		t.Errorf("List() = %+v, %v, want the 2 comments, oldest first", comments, err)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*.json")); len(files) != 2 {
+		t.Errorf("files = %v, want one per note", files)
+	}
+
+	if err := store.Delete("notes/q&a", "unknown"); err != ErrCommentNotFound {
+		t.Errorf("Delete() of an unknown comment = %v, want ErrCommentNotFound", err)
+	}
+	if err := store.Delete("notes/q&a", first.ID); err != nil {
+		t.Fatalf("Delete() error: %v", err)
+	}
+	all, err := store.All()
+	if err != nil || len(all) != 2 || len(all["notes/q&a"]) != 1 || all["garden"][0].Text != "Tomatoes" {
+		t.Errorf("All() = %+v, %v, want the remaining comments by slug", all, err)
+	}
+
+	// Concurrent comments are all kept
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Go(func() {
+			if _, err := store.Add("busy", model.Comment{Text: "Hi"}); err != nil {
+				t.Errorf("Add() error: %v", err)
+			}
+		})
+	}
+	wg.Wait()
+	if comments, _ := store.List("busy"); len(comments) != 20 {
+		t.Errorf("List() = %d comments after concurrent adds, want 20", len(comments))
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	limiter := newRateLimiter(2, time.Minute)
+	limiter.now = func() time.Time { return now }
+
+	if !limiter.Allow("a") || !limiter.Allow("a") || limiter.Allow("a") {
+		t.Error("the third event of the window should be refused")
+	}
+	if !limiter.Allow("b") {
+		t.Error("keys should be limited separately")
+	}
+	now = now.Add(time.Minute)
+	if !limiter.Allow("a") {
+		t.Error("events should be accepted again after the window")
+	}
+}
+
+func TestParseComment(t *testing.T) {
+	comment, err := parseComment("  Ada \n Lovelace ", "\r\nFirst line\r\nSecond  \n", time.Now())
+	if err != nil || comment.Name != "Ada Lovelace" || comment.Text != "First line\nSecond" {
+		t.Errorf("parseComment() = %+v, %v", comment, err)
+	}
+	if comment, _ := parseComment("", "Hi", time.Now()); comment.Name != "Anonymous" {
+		t.Errorf("name = %q, want Anonymous without a name", comment.Name)
+	}
+	for _, text := range []string{"", "  \n ", strings.Repeat("a", commentTextMaxLength+1)} {
+		if _, err := parseComment("Ada", text, time.Now()); err == nil {
+			t.Errorf("parseComment() of %d characters should be refused", len(text))
+		}
+	}
+	if _, err := parseComment(strings.Repeat("a", commentNameMaxLength+1), "Hi", time.Now()); err == nil {
+		t.Error("parseComment() with a long name should be refused")
+	}
+}
+
+// newCommentsTestServer serves a public garden note and a private diary note with comments
+func newCommentsTestServer(t *testing.T, cfg *config.Config) (*fuego.Server, CommentStore) {
+	t.Helper()
+	dir := t.TempDir()
+	writeTestFile(t, dir, "Garden.md", "---\npublish: true\n---\nTomatoes\n")
+	writeTestFile(t, dir, "Diary.md", "---\npublish: false\n---\nSecret\n")
+	cfg.Path, cfg.SiteTitle, cfg.CommentsEnabled = dir, "Pluie", true
+
+	notesMap, tree, tagIndex, err := loadNotes(dir, cfg)
+	if err != nil {
+		t.Fatalf("loadNotes() error: %v", err)
+	}
+	store, err := NewFileCommentStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &Server{
+		NotesService:   engine.NewNotesService(notesMap, tree, tagIndex),
+		rs:             template.NewResource(cfg),
+		cfg:            cfg,
+		comments:       store,
+		commentLimiter: newRateLimiter(commentRateLimit, commentRateWindow),
+	}
+	fuegoServer := fuego.NewServer()
+	server.registerRoutes(fuegoServer)
+	return fuegoServer, store
+}
+
+func postCommentForm(server *fuego.Server, path string, form url.Values, htmx bool) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if htmx {
+		r.Header.Set("HX-Request", "true")
+	}
+	w := httptest.NewRecorder()
+	server.Mux.ServeHTTP(w, r)
+	return w
+}
+
+func TestComments(t *testing.T) {
+	server, store := newCommentsTestServer(t, &config.Config{})
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		server.Mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	if w := get("/garden"); !strings.Contains(w.Body.String(), `hx-get="/-/comments/garden"`) {
+		t.Error("the note page should load its comments")
+	}
+
+	w := postCommentForm(server, "/-/comments/garden", url.Values{"name": {"Ada"}, "text": {"<b>Lovely</b> tomatoes"}}, true)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "&lt;b&gt;Lovely&lt;/b&gt; tomatoes") || !strings.Contains(w.Body.String(), "comment-form") {
+		t.Errorf("htmx post: status = %d, want the escaped comment with the form, got:\n%s", w.Code, w.Body.String())
+	}
+	if w := postCommentForm(server, "/-/comments/garden", url.Values{"text": {"From a form"}}, false); w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/garden#comments" {
+		t.Errorf("form post: status = %d, Location = %q, want a redirect to the comments", w.Code, w.Header().Get("Location"))
+	}
+	if w := get("/-/comments/garden"); !strings.Contains(w.Body.String(), "Lovely") || !strings.Contains(w.Body.String(), "Anonymous") {
+		t.Errorf("comments = %s, want both comments", w.Body.String())
+	}
+
+	// Bots filling the honeypot and empty comments are not stored
+	postCommentForm(server, "/-/comments/garden", url.Values{"text": {"Buy now"}, "website": {"http://spam.example"}}, true)
+	if w := postCommentForm(server, "/-/comments/garden", url.Values{"text": {"  "}}, true); !strings.Contains(w.Body.String(), "Comment refused") {
+		t.Errorf("an empty comment should be refused with its reason, got:\n%s", w.Body.String())
+	}
+	if w := postCommentForm(server, "/-/comments/garden", url.Values{"text": {"  "}}, false); w.Code != http.StatusBadRequest {
+		t.Errorf("an empty comment from a form: status = %d, want 400", w.Code)
+	}
+	if comments, _ := store.List("garden"); len(comments) != 2 {
+		t.Errorf("stored %d comments, want 2", len(comments))
+	}
+
+	// Private notes can't be commented, nor their comments read
+	for _, w := range []*httptest.ResponseRecorder{
+		get("/-/comments/diary"),
+		postCommentForm(server, "/-/comments/diary", url.Values{"text": {"Hi"}}, true),
+	} {
+		if w.Code != http.StatusNotFound {
+			t.Errorf("comments of a private note: status = %d, want 404", w.Code)
+		}
+	}
+
+	// Without a site credential there is no admin
+	if w := get("/-/comments"); w.Code != http.StatusNotFound {
+		t.Errorf("admin without AUTH_TOKEN: status = %d, want 404", w.Code)
+	}
+}
+
+func TestComments_Timezone(t *testing.T) {
+	server, store := newCommentsTestServer(t, &config.Config{SiteTimezone: "Asia/Tokyo"})
+	if _, err := store.Add("garden", model.Comment{Name: "Ada", Text: "Late", CreatedAt: time.Date(2024, 3, 1, 22, 30, 0, 0, time.UTC)}); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	server.Mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/-/comments/garden", nil))
+	if !strings.Contains(w.Body.String(), `datetime="2024-03-02T07:30:00+09:00"`) {
+		t.Errorf("expected the date of the comment in the site timezone, got:\n%s", w.Body.String())
+	}
+}
+
+func TestComments_RateLimit(t *testing.T) {
+	server, store := newCommentsTestServer(t, &config.Config{})
+	for range commentRateLimit {
+		postCommentForm(server, "/-/comments/garden", url.Values{"text": {"Hi"}}, true)
+	}
+	w := postCommentForm(server, "/-/comments/garden", url.Values{"text": {"One more"}}, false)
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("status = %d, want 429 past the rate limit", w.Code)
+	}
+	if comments, _ := store.List("garden"); len(comments) != commentRateLimit {
+		t.Errorf("stored %d comments, want %d", len(comments), commentRateLimit)
+	}
+}
+
+func TestComments_Admin(t *testing.T) {
+	server, store := newCommentsTestServer(t, &config.Config{AuthToken: "secret"})
+	comment, err := store.Add("garden", model.Comment{Name: "Ada", Text: "Spam spam"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := func(method, path string, token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		server.Mux.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := request(http.MethodGet, "/-/comments", "secret"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Spam spam") ||
+		!strings.Contains(w.Body.String(), `hx-delete="/-/comments/garden?id=`+comment.ID+`"`) {
+		t.Fatalf("admin: status = %d, want the comments with their delete buttons, got:\n%s", w.Code, w.Body.String())
+	}
+	if w := request(http.MethodDelete, "/-/comments/garden?id="+comment.ID, ""); w.Code == http.StatusOK {
+		t.Error("deleting a comment should need the auth token")
+	}
+	if w := request(http.MethodDelete, "/-/comments/garden?id="+comment.ID, "secret"); w.Code != http.StatusOK {
+		t.Errorf("delete: status = %d, want 200", w.Code)
+	}
+	if w := request(http.MethodDelete, "/-/comments/garden?id="+comment.ID, "secret"); w.Code != http.StatusNotFound {
+		t.Errorf("delete of a deleted comment: status = %d, want 404", w.Code)
+	}
+	if comments, _ := store.List("garden"); len(comments) != 0 {
+		t.Errorf("comments = %+v, want none", comments)
+	}
+}
+
+func TestGenerateStaticSiteComments(t *testing.T) {
+	vaultDir := t.TempDir()
+	writeTestFile(t, vaultDir, "Garden.md", "---\npublish: true\n---\nTomatoes")
+	commentsDir := t.TempDir()
+	store, err := NewFileCommentStore(commentsDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Add("garden", model.Comment{Name: "Ada", Text: "Lovely tomatoes"}); err != nil {
+		t.Fatal(err)
+	}
+
+	outputDir := filepath.Join(t.TempDir(), "output")
+	cfg := testStaticConfig(vaultDir, outputDir)
+	cfg.CommentsEnabled, cfg.CommentsDir = true, commentsDir
+	notesMap, tree, tagIndex, err := loadNotes(vaultDir, cfg)
+	if err != nil {
+		t.Fatalf("loadNotes error: %v", err)
+	}
+	if err := generateStaticSite(engine.NewNotesService(notesMap, tree, tagIndex), cfg); err != nil {
+		t.Fatalf("generateStaticSite error: %v", err)
+	}
+
+	page, err := os.ReadFile(filepath.Join(outputDir, "garden", "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(page), "Lovely tomatoes") || strings.Contains(string(page), "comment-form") || strings.Contains(string(page), "/-/comments/") {
+		t.Errorf("the static note should show its comments read-only, got:\n%s", page)
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"compress/gzip"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// minCompressSize is the size under which a response with a Content-Length is sent as is:
+// compressing it would save less than the headers cost
+const minCompressSize = 1024
+
+// compressibleTypes are the content types worth compressing. Images, fonts and archives are
+// compressed already, and text/event-stream must never be buffered.
+var compressibleTypes = []string{
+	"text/html",
+	"text/css",
+	"text/plain",
+	"text/javascript",
+	"text/xml",
+	"text/csv",
+	"text/markdown",
+	"application/javascript",
+	"application/json",
+	"application/xml",
+	"application/rss+xml",
+	"application/atom+xml",
+	"image/svg+xml",
+}
+
+var gzipWriters = sync.Pool{New: func() any { return gzip.NewWriter(nil) }}
+
+// compressionMiddleware gzips the responses of the compressible types for the clients accepting
+// it, see compressibleTypes. SSE streams and partial responses are sent as is.
+func compressionMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead || r.Header.Get("Range") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		writer := &compressResponseWriter{ResponseWriter: w, acceptsGzip: acceptsGzip(r.Header.Get("Accept-Encoding"))}
+		defer writer.Close()
+		next.ServeHTTP(writer, r)
+	})
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip, like "gzip, br" but not "gzip;q=0"
+func acceptsGzip(acceptEncoding string) bool {
+	for part := range strings.SplitSeq(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if coding = strings.TrimSpace(coding); coding != "gzip" && coding != "*" {
+			continue
+		}
+		if q, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			if value, err := strconv.ParseFloat(q, 64); err == nil && value == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// compressResponseWriter holds the status until the first write, when the content type is known,
+// then compresses the body or sends it as is.
+// It keeps streaming working: SSE handlers need http.Flusher and http.ResponseController.
+type compressResponseWriter struct {
+	http.ResponseWriter
+	acceptsGzip bool
+	status      int          // Status given to WriteHeader, sent on the first write
+	started     bool         // Whether the status was sent
+	gz          *gzip.Writer // Compressing the body, nil when sent as is
+}
+
+func (w *compressResponseWriter) WriteHeader(status int) {
+	if w.started || w.status != 0 {
+		return
+	}
+	// Informational responses, like 103 Early Hints, precede the real one
+	if status >= 100 && status < 200 {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	w.status = status
+}
+
+func (w *compressResponseWriter) Write(b []byte) (int, error) {
+	if !w.started {
+		w.start(b)
+	}
+	if w.gz != nil {
+		return w.gz.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// start sends the status and the headers, compressing the body when its type is worth it
+func (w *compressResponseWriter) start(firstChunk []byte) {
+	w.started = true
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	h := w.Header()
+	if h.Get("Content-Type") == "" && len(firstChunk) > 0 && h.Get("Content-Encoding") == "" {
+		h.Set("Content-Type", http.DetectContentType(firstChunk))
+	}
+	if isCompressible(h.Get("Content-Type")) {
+		h.Add("Vary", "Accept-Encoding")
+		if w.acceptsGzip && h.Get("Content-Encoding") == "" && bodyAllowed(w.status) && !tooSmall(h.Get("Content-Length")) {
+			h.Del("Content-Length")
+			h.Set("Content-Encoding", "gzip")
+			w.gz = gzipWriters.Get().(*gzip.Writer)
+			w.gz.Reset(w.ResponseWriter)
+		}
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+}
+
+func (w *compressResponseWriter) Flush() {
+	_ = w.FlushError()
+}
+
+// FlushError flushes like Flush, reporting the errors to http.ResponseController: SSE handlers
+// stop when the client is gone
+func (w *compressResponseWriter) FlushError() error {
+	if !w.started {
+		w.start(nil)
+	}
+	if w.gz != nil {
+		if err := w.gz.Flush(); err != nil {
+			return err
+		}
+	}
+	return http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Close sends the status of the responses without body and ends the compressed ones
+func (w *compressResponseWriter) Close() {
+	if !w.started {
+		w.start(nil)
+	}
+	if w.gz != nil {
+		_ = w.gz.Close()
+		gzipWriters.Put(w.gz)
+		w.gz = nil
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *compressResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// isCompressible reports whether the content type is one of compressibleTypes
+func isCompressible(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	return slices.Contains(compressibleTypes, strings.ToLower(strings.TrimSpace(mediaType)))
+}
+
+// bodyAllowed reports whether a response with the status has a body
+func bodyAllowed(status int) bool {
+	return status != http.StatusNoContent && status != http.StatusNotModified && status != http.StatusPartialContent
+}
+
+// tooSmall reports whether a Content-Length is below minCompressSize
+func tooSmall(contentLength string) bool {
+	size, err := strconv.Atoi(contentLength)
+	return err == nil && size < minCompressSize
+}
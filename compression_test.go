@@ -0,0 +1,114 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompressionMiddleware(t *testing.T) {
+	page := strings.Repeat("<li>A note of the sidebar</li>", 200)
+	handler := compressionMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/page":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, page)
+		case "/sniffed":
+			io.WriteString(w, "<!DOCTYPE html>"+page)
+		case "/small":
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Content-Length", "2")
+			io.WriteString(w, "{}")
+		case "/image":
+			w.Header().Set("Content-Type", "image/webp")
+			io.WriteString(w, page)
+		case "/not-modified":
+			w.WriteHeader(http.StatusNotModified)
+		}
+	}))
+
+	get := func(path, acceptEncoding string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		if acceptEncoding != "" {
+			r.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	for _, path := range []string{"/page", "/sniffed"} {
+		w := get(path, "gzip, deflate, br")
+		if w.Header().Get("Content-Encoding") != "gzip" || w.Header().Get("Vary") != "Accept-Encoding" {
+			t.Fatalf("%s: expected a gzipped response, got headers %v", path, w.Header())
+		}
+		reader, err := gzip.NewReader(w.Body)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		body, _ := io.ReadAll(reader)
+		if !strings.HasSuffix(string(body), page) {
+			t.Errorf("%s: the gzipped body should be the page", path)
+		}
+	}
+	if w := get("/page", ""); w.Code != http.StatusNotFound || w.Header().Get("Content-Encoding") != "" || w.Body.String() != page {
+		t.Errorf("without Accept-Encoding, expected the page as is with its status, got %d %v", w.Code, w.Header())
+	}
+	if w := get("/page", "gzip;q=0"); w.Header().Get("Content-Encoding") != "" {
+		t.Errorf("gzip;q=0 refuses gzip, got %v", w.Header())
+	}
+	for _, path := range []string{"/small", "/image", "/not-modified"} {
+		if w := get(path, "gzip"); w.Header().Get("Content-Encoding") != "" {
+			t.Errorf("%s should not be compressed, got %v", path, w.Header())
+		}
+	}
+	if w := get("/not-modified", "gzip"); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("expected an empty 304, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+// flushRecorder sends the body received so far on each flush
+type flushRecorder struct {
+	*httptest.ResponseRecorder
+	flushes chan string
+}
+
+func (f flushRecorder) Flush() {
+	f.ResponseRecorder.Flush()
+	f.flushes <- f.Body.String()
+}
+
+func TestCompressionMiddleware_SSE(t *testing.T) {
+	handler := compressionMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "data: first\n\n")
+		http.NewResponseController(w).Flush()
+		io.WriteString(w, "data: second\n\n")
+	}))
+
+	w := flushRecorder{ResponseRecorder: httptest.NewRecorder(), flushes: make(chan string)}
+	r := httptest.NewRequest(http.MethodGet, "/-/search/progress", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	done := make(chan struct{})
+	go func() {
+		handler.ServeHTTP(w, r)
+		close(done)
+	}()
+
+	// The first event reaches the client before the handler ends, as is
+	if first := <-w.flushes; first != "data: first\n\n" {
+		t.Errorf("expected the first event on flush, got %q", first)
+	}
+	<-done
+
+	if w.Header().Get("Content-Encoding") != "" || w.Header().Get("Vary") != "" {
+		t.Errorf("SSE streams should be untouched, got headers %v", w.Header())
+	}
+	if w.Body.String() != "data: first\n\ndata: second\n\n" {
+		t.Errorf("unexpected stream %q", w.Body.String())
+	}
+}
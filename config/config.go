@@ -4,120 +4,372 @@ import (
 	"flag"
 	"log/slog"
 	"os"
+	"path"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 // Config holds all application configuration
 type Config struct {
 	// Runtime settings (can be overridden by CLI flags)
-	Path    string
-	Watch   bool
-	Mode    string
-	Output  string
-	Version bool // Print version and exit
+	Path        string `yaml:"path"`
+	Watch       bool   `yaml:"watch"`
+	Mode        string `yaml:"mode"`
+	Output      string `yaml:"output"`
+	Version     bool   `yaml:"-"` // Print version and exit
+	PrintConfig bool   `yaml:"-"` // Print the effective configuration and exit
+	ConfigFile  string `yaml:"-"` // Config file loaded, "" when none
+	Upload      string `yaml:"-"` // Static mode upload destination, like "s3://bucket/prefix"
+	Prune       bool   `yaml:"-"` // Delete uploaded files that no longer exist locally
+	Minify      bool   `yaml:"-"` // Minify the static pages, which load the sidebar from a shared sidebar.html
+	Preview     bool   `yaml:"-"` // "pluie preview": zero-config preview of the current folder
+	NoOpen      bool   `yaml:"-"` // In preview mode, don't open the browser
+
+	// Import mode settings
+	ImportFrom  string `yaml:"-"` // Export format: "html" or "notion-html"
+	ImportInput string `yaml:"-"` // Export folder or .zip file
+	Force       bool   `yaml:"-"` // Overwrite existing files in the import output, generate every static page again
+
+	// Export mode settings
+	ExportNote           string `yaml:"-"` // Slug of the note the bundle starts from
+	ExportDepth          int    `yaml:"-"` // How many wikilinks away from ExportNote the bundle goes
+	ExportIncludePrivate bool   `yaml:"-"` // Bundle the private notes too, inspect them too in inspect mode
+
+	// Inspect mode settings
+	InspectFormat string `yaml:"-"` // Format of the notes file: "json" or "csv"
+	OrphansOnly   bool   `yaml:"-"` // Only the notes with no backlinks and no outgoing links
 
 	// Server settings
-	Port    string
-	LogJSON bool
+	Port                   string `yaml:"port"`
+	ShutdownTimeoutSeconds int    `yaml:"shutdown_timeout_seconds"` // Seconds the running requests get to finish at shutdown
+	LogJSON                bool   `yaml:"log_json"`
+	MetricsEnabled         bool   `yaml:"metrics_enabled"` // Expose Prometheus metrics on /-/metrics
+	MetricsToken           string `yaml:"metrics_token"`   // When set, /-/metrics requires "Authorization: Bearer <token>"
+	RegexSearch            bool   `yaml:"regex_search"`    // Allow "re:" regex queries on the search page
+
+	// Authentication settings, the whole site needs a credential when one is set
+	AuthToken     string `yaml:"auth_token"`      // Accepted as "Authorization: Bearer <token>" or on the login page
+	BasicAuthUser string `yaml:"basic_auth_user"` // HTTP basic auth user, needs BasicAuthPass
+	BasicAuthPass string `yaml:"basic_auth_pass"`
+
+	// Watcher settings
+	WatchDebounceMS int    `yaml:"watch_debounce_ms"` // Milliseconds without file changes before the watcher reloads the notes
+	ReloadToken     string `yaml:"reload_token"`      // Enables POST and GET /-/reload with "Authorization: Bearer <token>"
 
 	// Site customization
-	SiteTitle           string
-	SiteIcon            string
-	SiteDescription     string
-	HideYamlFrontmatter bool
+	SiteTitle           string `yaml:"site_title"`
+	SiteIcon            string `yaml:"site_icon"`
+	SiteDescription     string `yaml:"site_description"`
+	SiteURL             string `yaml:"site_url"`        // Public URL of the site, like "https://notes.example.com", for the absolute URLs of sitemap.xml and feed.xml
+	FeedSize            int    `yaml:"feed_size"`       // Notes listed in the RSS feed, most recently modified first
+	RobotsDisallow      string `yaml:"robots_disallow"` // Comma-separated paths robots.txt disallows, on top of the search pages, like "/drafts/,/private"
+	HideYamlFrontmatter bool   `yaml:"hide_yaml_frontmatter"`
+	Mermaid             bool   `yaml:"mermaid"`           // Render mermaid code blocks as diagrams with the bundled library
+	ReadingWPM          int    `yaml:"reading_wpm"`       // Words read per minute, for the reading time of the notes
+	RenderCacheSize     int    `yaml:"render_cache_size"` // Rendered notes kept in memory, least recently viewed out first, 0 disables the cache
+	SidebarLazy         bool   `yaml:"sidebar_lazy"`      // Load the folders of the sidebar below the top two levels when opened, for large vaults
+	TOCEmbeds           bool   `yaml:"toc_embeds"`        // List the headings of the embedded notes in the table of contents, linking to their notes
+	SiteTimezone        string `yaml:"site_timezone"`     // IANA name, like "Europe/Paris", used to display and parse dates
+	GitWebURL           string `yaml:"git_web_url"`       // Commit page of the vault forge, "{hash}" replaced by the last commit of a note, like "https://github.com/me/vault/commit/{hash}"
+
+	// Embed settings (/{slug}/embed)
+	EmbedLinkTarget     string `yaml:"embed_link_target"`     // "_top" or "_blank": where links of embedded notes open
+	EmbedFrameAncestors string `yaml:"embed_frame_ancestors"` // Origins allowed to frame embedded notes, space-separated, "*" for any
+
+	// API settings (/-/api/)
+	CORSOrigins string `yaml:"cors_origins"` // Origins allowed to call the API from the browser, comma-separated, "*" for any, none when empty
 
 	// Privacy settings
-	PublicByDefault bool
-	HomeNoteSlug    string
+	PublicByDefault bool              `yaml:"public_by_default"`
+	ForcePublic     bool              `yaml:"-"`           // Every note is public, even with "public: false" frontmatter (preview mode)
+	ShowDrafts      bool              `yaml:"show_drafts"` // The server shows the notes with "draft: true", with a badge, see DraftsShown
+	HomeNoteSlug    string            `yaml:"home_note_slug"`
+	PrivateStatuses string            `yaml:"private_statuses"` // Comma-separated statuses making notes private unless they set "publish: true", like "draft"
+	ShareKeys       string            `yaml:"share_keys"`       // Comma-separated folder=key pairs unlocking the "access: private" folders, like "Clients/Acme=abc123"
+	ShareKeyFolders map[string]string `yaml:"-"`                // Parsed ShareKeys: folder path in the vault -> key
+
+	// Exclusion settings
+	ExcludePaths           string   `yaml:"exclude_paths"`             // Comma-separated globs of vault paths never published, like "Archive/**,*.excalidraw.md"
+	ExcludePathsIgnoreCase bool     `yaml:"exclude_paths_ignore_case"` // Match ExcludePaths case-insensitively
+	ExcludeGlobs           []string `yaml:"-"`                         // Parsed ExcludePaths, invalid globs left out
+
+	// URL settings
+	SlugScheme        string `yaml:"slug_scheme"`        // "v1" (URL-encoded paths) or "v2" (punctuation stripped, v1 URLs redirected)
+	SlugTransliterate bool   `yaml:"slug_transliterate"` // Removes the accents of v1 slugs instead of URL-encoding them, old URLs redirected
+
+	// Attachment settings
+	AttachmentExtensions string `yaml:"attachment_extensions"` // Comma-separated extensions of the vault files notes can embed or link to, like "png,pdf,mp3"
+
+	// Trash settings
+	TrashDays int    `yaml:"trash_days"` // Days a deleted note stays readable at its URL, 0 disables the trash
+	TrashFile string `yaml:"trash_file"` // JSON file persisting the trash across restarts, empty to keep it in memory only
+
+	// View count settings
+	ShowViewCount bool   `yaml:"show_view_count"` // Show "123 views" under the title of the notes
+	ViewsFile     string `yaml:"views_file"`      // JSON file persisting the view counts across restarts, empty to keep them in memory only
+
+	// Comments settings
+	CommentsEnabled bool   `yaml:"comments_enabled"` // Readers can comment public notes, static sites show the comments read-only
+	CommentsDir     string `yaml:"comments_dir"`     // Folder of the comments, a JSON file per note, outside the vault
+
+	// Flashcards export settings (-mode export-flashcards and /-/export/flashcards.csv)
+	FlashcardsTag       string `yaml:"flashcards_tag"`       // Notes with this tag, or one of its subtags, are exported
+	FlashcardsSeparator string `yaml:"flashcards_separator"` // CSV separator: "comma", "semicolon", "tab" or "pipe"
+	FlashcardsToken     string `yaml:"flashcards_token"`     // Enables GET /-/export/flashcards.csv with "Authorization: Bearer <token>"
+
+	// Note statuses
+	NoteStatuses string `yaml:"note_statuses"` // Recognized "status" frontmatter values with their badge color, like "draft:gray,published:green"
 
 	// AI/Chat settings
-	ChatProvider  string // "ollama", "mistral", or "openai"
-	ChatModel     string
-	OllamaURL     string
-	MistralAPIKey string
-	OpenAIAPIKey  string
+	ChatProvider  string `yaml:"chat_provider"` // "ollama", "mistral", or "openai"
+	ChatModel     string `yaml:"chat_model"`
+	OllamaURL     string `yaml:"ollama_url"`
+	MistralAPIKey string `yaml:"mistral_api_key"`
+	OpenAIAPIKey  string `yaml:"openai_api_key"`
+
+	// Chat failover settings
+	ChatProviders       string               `yaml:"chat_providers"`        // Ordered failover chain, like "ollama model=llama3 timeout=10s, mistral model=mistral-small-latest"
+	ChatChain           []ChatProviderConfig `yaml:"-"`                     // Parsed ChatProviders, or the single CHAT_PROVIDER when empty
+	ChatCooldownSeconds int                  `yaml:"chat_cooldown_seconds"` // Seconds a failing provider is skipped before being tried again
+	ChatModels          string               `yaml:"chat_models"`           // Comma-separated models a search can pick instead of ChatModel, with ?model=
+	ChatModelChoices    []string             `yaml:"-"`                     // Parsed ChatModels, empty when there is no choice
+
+	// AI answer prompt settings
+	PromptTemplate string `yaml:"prompt_template"`  // Go template of the prompt with {{.Query}} and {{.Context}}, _pluie/prompt.md or the built-in one when empty
+	SystemPrompt   string `yaml:"system_prompt"`    // Sent before the prompt as a system message, the "system" frontmatter of _pluie/prompt.md when empty
+	AIContextChars int    `yaml:"ai_context_chars"` // Characters of each note allowed in the context of the AI answers
 
 	// Embeddings settings
-	EmbeddingProvider      string // "ollama", "openai", or "mistral"
-	EmbeddingsTrackingFile string
-	EmbeddingModel         string // Mustn't be changed, the embeddings would mean nothing if done so
+	EmbeddingProvider      string `yaml:"embedding_provider"` // "ollama", "openai", or "mistral"
+	EmbeddingsTrackingFile string `yaml:"embeddings_tracking_file"`
+	EmbeddingModel         string `yaml:"-"`                      // Mustn't be changed, the embeddings would mean nothing if done so
+	EmbeddingsRateLimit    int    `yaml:"embeddings_rate_limit"`  // Maximum notes embedded per minute, 0 for no limit
+	EmbeddingsConcurrency  int    `yaml:"embeddings_concurrency"` // Embedding requests sent at the same time
+	EmbeddingsToken        string `yaml:"embeddings_token"`       // Enables POST /-/embeddings/pause, /resume and /reembed with "Authorization: Bearer <token>"
+	ForceReembed           bool   `yaml:"force_reembed"`          // Wipes the embeddings cache at startup, every note is embedded again
+
+	// Vector store settings
+	VectorStore         string `yaml:"vector_store"`          // "auto" (Weaviate when it answers, else embedded), "weaviate" or "embedded"
+	EmbeddingsStoreFile string `yaml:"embeddings_store_file"` // Vectors of the embedded vector store, kept between restarts
 
 	// Weaviate settings
-	WeaviateHost   string
-	WeaviateScheme string
-	WeaviateIndex  string
+	WeaviateHost   string `yaml:"weaviate_host"`
+	WeaviateScheme string `yaml:"weaviate_scheme"`
+	WeaviateIndex  string `yaml:"weaviate_index"`
+
+	// S3 upload settings (static mode with -upload)
+	S3Endpoint        string `yaml:"aws_endpoint_url"` // Custom endpoint for S3-compatible storage (R2, MinIO...)
+	S3Region          string `yaml:"aws_region"`
+	S3AccessKeyID     string `yaml:"aws_access_key_id"`
+	S3SecretAccessKey string `yaml:"aws_secret_access_key"`
 }
 
-// LoadConfig parses CLI flags and creates Config with CLI flags > Env vars > Defaults priority
+// LoadConfig parses CLI flags and creates Config with CLI flags > Env vars > Config file > Defaults priority
 func LoadConfig(loadFlags bool) *Config {
-	// 1. Set defaults
-	cfg := &Config{
+	// 1. Parse CLI flags, they are applied last
+	var flags *cliFlags
+	if loadFlags {
+		flags = parseFlags()
+	}
+
+	// 2. Defaults, the config file then environment variables
+	cfg, err := Load(flags.configFile())
+	if err != nil {
+		slog.Error("Failed to load the configuration", "error", err)
+		os.Exit(1)
+	}
+
+	// 3. Apply CLI flags (override environment)
+	flags.apply(cfg)
+
+	// 4. Preview preset overrides everything but the path
+	if cfg.Preview {
+		cfg.applyPreviewPreset()
+	}
+
+	// 5. Validate with warnings
+	cfg.validate()
+
+	slog.Info("Configuration loaded",
+		slog.Any("config", cfg),
+	)
+
+	return cfg
+}
+
+// defaultConfig returns the configuration before any file, environment variable or flag
+func defaultConfig() *Config {
+	return &Config{
 		Path:                   ".",
 		Watch:                  true,
 		Mode:                   "server",
 		Output:                 "dist",
+		ImportFrom:             "notion-html",
+		ExportDepth:            defaultExportDepth,
+		InspectFormat:          "json",
 		ChatProvider:           "ollama",
 		ChatModel:              "tinyllama",
 		Port:                   "9999",
+		ShutdownTimeoutSeconds: defaultShutdownTimeoutSeconds,
 		LogJSON:                false,
+		MetricsEnabled:         true,
+		RegexSearch:            true,
+		WatchDebounceMS:        defaultWatchDebounceMS,
 		SiteTitle:              "Pluie",
 		SiteIcon:               "/static/pluie.webp",
 		SiteDescription:        "",
 		HideYamlFrontmatter:    false,
+		Mermaid:                true,
+		ReadingWPM:             200,
+		RenderCacheSize:        500,
+		SiteTimezone:           "UTC",
+		EmbedLinkTarget:        "_top",
+		EmbedFrameAncestors:    "*",
+		SlugScheme:             SlugSchemeV1,
+		AttachmentExtensions:   "png,jpg,jpeg,gif,webp,pdf,mp3,wav,ogg,m4a,flac",
+		FeedSize:               20,
 		PublicByDefault:        false,
 		HomeNoteSlug:           "Index",
+		TrashDays:              7,
+		CommentsDir:            "comments",
+		ViewsFile:              "views.json",
+		FlashcardsTag:          "flashcards",
+		FlashcardsSeparator:    "comma",
+		NoteStatuses:           "seed:lime,sprout:green,evergreen:emerald,draft:gray,review:yellow,published:blue",
 		OllamaURL:              "http://ollama-models:11434",
 		MistralAPIKey:          "",
 		OpenAIAPIKey:           "",
+		ChatCooldownSeconds:    60,
+		AIContextChars:         800,
 		EmbeddingProvider:      "ollama",
 		EmbeddingsTrackingFile: "embeddings_tracking.json",
 		EmbeddingModel:         "nomic-embed-text",
+		EmbeddingsConcurrency:  1,
+		VectorStore:            VectorStoreAuto,
+		EmbeddingsStoreFile:    "embeddings_vectors.jsonl",
 		WeaviateHost:           "weaviate-embeddings:9035",
 		WeaviateScheme:         "http",
 		WeaviateIndex:          "Note",
+		S3Region:               "us-east-1",
 	}
+}
 
-	// 2. Apply environment variables (override defaults)
-	cfg.applyEnvironment()
+// cliFlags are the parsed CLI flags
+type cliFlags struct {
+	preview        bool // "pluie preview [flags]" subcommand
+	config         *string
+	path           *string
+	watch          *bool
+	mode           *string
+	output         *string
+	chatModel      *string
+	version        *bool
+	printConfig    *bool
+	upload         *string
+	prune          *bool
+	minify         *bool
+	noOpen         *bool
+	from           *string
+	input          *string
+	force          *bool
+	note           *string
+	depth          *int
+	includePrivate *bool
+	format         *string
+	orphansOnly    *bool
+}
 
-	// 3. Apply CLI flags (override environment)
-	if loadFlags {
-		path := flag.String("path", "", "Path to the obsidian folder")
-		watch := flag.Bool("watch", false, "Enable file watching to auto-reload on changes")
-		mode := flag.String("mode", "", "Mode to run in: server or static")
-		output := flag.String("output", "", "Output folder for static site generation")
-		chatModel := flag.String("model", "", "Chat model to use for AI responses (overrides CHAT_MODEL env var)")
-		versionFlag := flag.Bool("version", false, "Print version and exit")
-		flag.Parse()
-
-		cfg.Version = *versionFlag
-
-		if *path != "" {
-			cfg.Path = *path
-		}
-		if flag.Lookup("watch").Value.String() != flag.Lookup("watch").DefValue {
-			cfg.Watch = *watch
-		}
-		if *mode != "" {
-			cfg.Mode = *mode
-		}
-		if *output != "" {
-			cfg.Output = *output
-		}
-		if *chatModel != "" {
-			cfg.ChatModel = *chatModel
-		}
+// parseFlags parses the command line, exiting on error
+func parseFlags() *cliFlags {
+	f := &cliFlags{
+		config:         flag.String("config", "", "Config file, pluie.yaml or .pluie.yaml of the -path folder when empty"),
+		path:           flag.String("path", "", "Path to the obsidian folder"),
+		watch:          flag.Bool("watch", false, "Enable file watching to auto-reload on changes"),
+		mode:           flag.String("mode", "", "Mode to run in: server, static, check, import, export, export-flashcards or inspect"),
+		output:         flag.String("output", "", "Output folder for static site generation, exported bundle or imported notes, or the flashcards CSV or inspected notes file"),
+		chatModel:      flag.String("model", "", "Chat model to use for AI responses (overrides CHAT_MODEL env var)"),
+		version:        flag.Bool("version", false, "Print version and exit"),
+		printConfig:    flag.Bool("print-config", false, "Print the effective configuration, secrets redacted, and exit"),
+		upload:         flag.String("upload", "", "Upload the static site to a bucket after generation, like s3://bucket/prefix"),
+		prune:          flag.Bool("prune", false, "With -upload, delete remote files that no longer exist locally"),
+		minify:         flag.Bool("minify", false, "Static mode: minify the HTML and load the sidebar from a shared sidebar.html"),
+		noOpen:         flag.Bool("no-open", false, "In preview mode, don't open the browser"),
+		from:           flag.String("from", "", "Import mode: export format, html or notion-html"),
+		input:          flag.String("input", "", "Import mode: export folder or .zip file"),
+		force:          flag.Bool("force", false, "Import mode: overwrite existing files in the output folder. Static mode: generate every page again"),
+		note:           flag.String("note", "", "Export mode: slug of the note the bundle starts from"),
+		depth:          flag.Int("depth", defaultExportDepth, "Export mode: how many wikilinks away from the note the bundle goes"),
+		includePrivate: flag.Bool("include-private", false, "Export mode: bundle the private notes too. Inspect mode: list the private notes too"),
+		format:         flag.String("format", "json", "Inspect mode: format of the notes file, json or csv"),
+		orphansOnly:    flag.Bool("orphans-only", false, "Inspect mode: only list the notes with no backlinks and no outgoing links"),
 	}
 
-	// 4. Validate with warnings
-	cfg.validate()
+	args := os.Args[1:]
+	if len(args) > 0 && args[0] == "preview" {
+		f.preview = true
+		args = args[1:]
+	}
+	_ = flag.CommandLine.Parse(args) // Exits on error
+	return f
+}
 
-	slog.Info("Configuration loaded",
-		slog.Any("config", cfg),
-	)
+// configFile returns the config file to load: -config, else the one of the vault folder
+func (f *cliFlags) configFile() string {
+	if f == nil {
+		return findConfigFile(".")
+	}
+	if *f.config != "" {
+		return *f.config
+	}
+	vault := *f.path
+	if vault == "" {
+		vault = "."
+	}
+	return findConfigFile(vault)
+}
 
-	return cfg
+// apply sets the flags given on the command line, a nil cliFlags sets nothing
+func (f *cliFlags) apply(cfg *Config) {
+	if f == nil {
+		return
+	}
+	cfg.Preview = f.preview
+	cfg.Version = *f.version
+	cfg.PrintConfig = *f.printConfig
+	cfg.Upload = *f.upload
+	cfg.Prune = *f.prune
+	cfg.Minify = *f.minify
+	cfg.NoOpen = *f.noOpen
+	cfg.ImportInput = *f.input
+	cfg.Force = *f.force
+	cfg.ExportNote = *f.note
+	cfg.ExportDepth = *f.depth
+	cfg.ExportIncludePrivate = *f.includePrivate
+	cfg.InspectFormat = *f.format
+	cfg.OrphansOnly = *f.orphansOnly
+
+	if *f.path != "" {
+		cfg.Path = *f.path
+	}
+	if flag.Lookup("watch").Value.String() != flag.Lookup("watch").DefValue {
+		cfg.Watch = *f.watch
+	}
+	if *f.mode != "" {
+		cfg.Mode = *f.mode
+	}
+	if *f.output != "" {
+		cfg.Output = *f.output
+	}
+	if *f.chatModel != "" {
+		cfg.ChatModel = *f.chatModel
+	}
+	if *f.from != "" {
+		cfg.ImportFrom = *f.from
+	}
 }
 
 // applyEnvironment loads configuration from environment variables
@@ -128,35 +380,132 @@ func (c *Config) applyEnvironment() {
 	c.OllamaURL = getEnvOrDefault("OLLAMA_URL", c.OllamaURL)
 	c.MistralAPIKey = getEnvOrDefault("MISTRAL_API_KEY", c.MistralAPIKey)
 	c.OpenAIAPIKey = getEnvOrDefault("OPENAI_API_KEY", c.OpenAIAPIKey)
+	c.ChatProviders = getEnvOrDefault("CHAT_PROVIDERS", c.ChatProviders)
+	c.ChatCooldownSeconds = getEnvInt("CHAT_COOLDOWN_SECONDS", c.ChatCooldownSeconds)
+	c.ChatModels = getEnvOrDefault("CHAT_MODELS", c.ChatModels)
+
+	// AI answer prompt settings
+	c.PromptTemplate = getEnvOrDefault("PROMPT_TEMPLATE", c.PromptTemplate)
+	c.SystemPrompt = getEnvOrDefault("SYSTEM_PROMPT", c.SystemPrompt)
+	c.AIContextChars = getEnvInt("AI_CONTEXT_CHARS", c.AIContextChars)
 
 	// Server settings
 	c.Port = getEnvOrDefault("PORT", c.Port)
+	c.ShutdownTimeoutSeconds = getEnvInt("SHUTDOWN_TIMEOUT_SECONDS", c.ShutdownTimeoutSeconds)
 	c.LogJSON = getEnvBool("LOG_JSON", c.LogJSON)
+	c.MetricsEnabled = getEnvBool("METRICS_ENABLED", c.MetricsEnabled)
+	c.MetricsToken = getEnvOrDefault("METRICS_TOKEN", c.MetricsToken)
+	c.RegexSearch = getEnvBool("REGEX_SEARCH", c.RegexSearch)
+
+	// Authentication settings
+	c.AuthToken = getEnvOrDefault("AUTH_TOKEN", c.AuthToken)
+	c.BasicAuthUser = getEnvOrDefault("BASIC_AUTH_USER", c.BasicAuthUser)
+	c.BasicAuthPass = getEnvOrDefault("BASIC_AUTH_PASS", c.BasicAuthPass)
+
+	// Watcher settings
+	c.WatchDebounceMS = getEnvInt("WATCH_DEBOUNCE_MS", c.WatchDebounceMS)
+	c.ReloadToken = getEnvOrDefault("RELOAD_TOKEN", c.ReloadToken)
 
 	// Site customization
 	c.SiteTitle = getEnvOrDefault("SITE_TITLE", c.SiteTitle)
 	c.SiteIcon = getEnvOrDefault("SITE_ICON", c.SiteIcon)
 	c.SiteDescription = getEnvOrDefault("SITE_DESCRIPTION", c.SiteDescription)
+	c.SiteURL = getEnvOrDefault("SITE_URL", c.SiteURL)
+	c.FeedSize = getEnvInt("FEED_SIZE", c.FeedSize)
+	c.RobotsDisallow = getEnvOrDefault("ROBOTS_DISALLOW", c.RobotsDisallow)
 	c.HideYamlFrontmatter = getEnvBool("HIDE_YAML_FRONTMATTER", c.HideYamlFrontmatter)
+	c.Mermaid = getEnvBool("MERMAID", c.Mermaid)
+	c.ReadingWPM = getEnvInt("READING_WPM", c.ReadingWPM)
+	c.RenderCacheSize = getEnvInt("RENDER_CACHE_SIZE", c.RenderCacheSize)
+	c.SidebarLazy = getEnvBool("SIDEBAR_LAZY", c.SidebarLazy)
+	c.TOCEmbeds = getEnvBool("TOC_EMBEDS", c.TOCEmbeds)
 	c.HomeNoteSlug = getEnvOrDefault("HOME_NOTE_SLUG", c.HomeNoteSlug)
+	c.SiteTimezone = getEnvOrDefault("SITE_TIMEZONE", c.SiteTimezone)
+	c.GitWebURL = getEnvOrDefault("GIT_WEB_URL", c.GitWebURL)
+
+	// Embed settings
+	c.EmbedLinkTarget = getEnvOrDefault("EMBED_LINK_TARGET", c.EmbedLinkTarget)
+	c.EmbedFrameAncestors = getEnvOrDefault("EMBED_FRAME_ANCESTORS", c.EmbedFrameAncestors)
+
+	// API settings
+	c.CORSOrigins = getEnvOrDefault("CORS_ORIGINS", c.CORSOrigins)
 
 	// Privacy settings
 	c.PublicByDefault = getEnvBool("PUBLIC_BY_DEFAULT", c.PublicByDefault)
+	c.ShowDrafts = getEnvBool("SHOW_DRAFTS", c.ShowDrafts)
+	c.PrivateStatuses = getEnvOrDefault("PRIVATE_STATUSES", c.PrivateStatuses)
+	c.ShareKeys = getEnvOrDefault("SHARE_KEYS", c.ShareKeys)
+
+	// Exclusion settings
+	c.ExcludePaths = getEnvOrDefault("EXCLUDE_PATHS", c.ExcludePaths)
+	c.ExcludePathsIgnoreCase = getEnvBool("EXCLUDE_PATHS_IGNORE_CASE", c.ExcludePathsIgnoreCase)
+
+	// URL settings
+	c.SlugScheme = getEnvOrDefault("SLUG_SCHEME", c.SlugScheme)
+	c.SlugTransliterate = getEnvBool("SLUG_TRANSLITERATE", c.SlugTransliterate)
+
+	// Attachment settings
+	c.AttachmentExtensions = getEnvOrDefault("ATTACHMENT_EXTENSIONS", c.AttachmentExtensions)
+
+	// Trash settings
+	c.TrashDays = getEnvInt("TRASH_DAYS", c.TrashDays)
+	c.TrashFile = getEnvOrDefault("TRASH_FILE", c.TrashFile)
+
+	// View count settings
+	c.ShowViewCount = getEnvBool("SHOW_VIEW_COUNT", c.ShowViewCount)
+	c.ViewsFile = getEnvOrDefault("VIEWS_FILE", c.ViewsFile)
+
+	// Comments settings
+	c.CommentsEnabled = getEnvBool("COMMENTS_ENABLED", c.CommentsEnabled)
+	c.CommentsDir = getEnvOrDefault("COMMENTS_DIR", c.CommentsDir)
+
+	// Flashcards export settings
+	c.FlashcardsTag = getEnvOrDefault("FLASHCARDS_TAG", c.FlashcardsTag)
+	c.FlashcardsSeparator = getEnvOrDefault("FLASHCARDS_SEPARATOR", c.FlashcardsSeparator)
+	c.FlashcardsToken = getEnvOrDefault("FLASHCARDS_TOKEN", c.FlashcardsToken)
+
+	// Note statuses
+	c.NoteStatuses = getEnvOrDefault("NOTE_STATUSES", c.NoteStatuses)
 
 	// Embeddings settings
 	c.EmbeddingProvider = getEnvOrDefault("EMBEDDING_PROVIDER", c.EmbeddingProvider)
 	c.EmbeddingsTrackingFile = getEnvOrDefault("EMBEDDINGS_TRACKING_FILE", c.EmbeddingsTrackingFile)
+	c.EmbeddingsRateLimit = getEnvInt("EMBEDDINGS_RATE_LIMIT", c.EmbeddingsRateLimit)
+	c.EmbeddingsConcurrency = getEnvInt("EMBEDDINGS_CONCURRENCY", c.EmbeddingsConcurrency)
+	c.EmbeddingsToken = getEnvOrDefault("EMBEDDINGS_TOKEN", c.EmbeddingsToken)
+	c.ForceReembed = getEnvBool("FORCE_REEMBED", c.ForceReembed)
+
+	// Vector store settings
+	c.VectorStore = getEnvOrDefault("VECTOR_STORE", c.VectorStore)
+	c.EmbeddingsStoreFile = getEnvOrDefault("EMBEDDINGS_STORE_FILE", c.EmbeddingsStoreFile)
 
 	// Weaviate settings
 	c.WeaviateHost = getEnvOrDefault("WEAVIATE_HOST", c.WeaviateHost)
 	c.WeaviateScheme = getEnvOrDefault("WEAVIATE_SCHEME", c.WeaviateScheme)
 	c.WeaviateIndex = getEnvOrDefault("WEAVIATE_INDEX", c.WeaviateIndex)
+
+	// S3 upload settings (standard AWS variable names)
+	c.S3Endpoint = getEnvOrDefault("AWS_ENDPOINT_URL", c.S3Endpoint)
+	c.S3Region = getEnvOrDefault("AWS_REGION", c.S3Region)
+	c.S3AccessKeyID = getEnvOrDefault("AWS_ACCESS_KEY_ID", c.S3AccessKeyID)
+	c.S3SecretAccessKey = getEnvOrDefault("AWS_SECRET_ACCESS_KEY", c.S3SecretAccessKey)
+}
+
+// applyPreviewPreset configures a zero-config preview of any markdown folder:
+// every note is public, no AI features, and the home note is picked from the files
+func (c *Config) applyPreviewPreset() {
+	c.Mode = "server"
+	c.Watch = true
+	c.PublicByDefault = true
+	c.ForcePublic = true
+	c.HomeNoteSlug = ""
+	c.Upload = ""
 }
 
 // validate checks configuration and warns about invalid values
 func (c *Config) validate() {
 	// Mode validation
-	if c.Mode != "server" && c.Mode != "static" {
+	if c.Mode != "server" && c.Mode != "static" && c.Mode != "check" && c.Mode != "import" && c.Mode != "export" && c.Mode != "export-flashcards" && c.Mode != "inspect" {
 		slog.Warn("Invalid MODE, defaulting to 'server'", "provided", c.Mode)
 		c.Mode = "server"
 	}
@@ -173,48 +522,520 @@ func (c *Config) validate() {
 		c.ChatProvider = "ollama"
 	}
 
+	// Chat failover chain validation
+	c.ChatChain = c.parseChatChain()
+	c.ChatModelChoices = parseChatModels(c.ChatModels)
+	if c.ChatCooldownSeconds < 0 {
+		slog.Warn("Invalid CHAT_COOLDOWN_SECONDS, defaulting to 0", "provided", c.ChatCooldownSeconds)
+		c.ChatCooldownSeconds = 0
+	}
+	if c.AIContextChars < 1 {
+		slog.Warn("Invalid AI_CONTEXT_CHARS, defaulting to 800", "provided", c.AIContextChars)
+		c.AIContextChars = 800
+	}
+
 	// Embedding provider validation
 	if c.EmbeddingProvider != "ollama" && c.EmbeddingProvider != "openai" && c.EmbeddingProvider != "mistral" {
 		slog.Warn("Invalid EMBEDDING_PROVIDER, defaulting to 'ollama'", "provided", c.EmbeddingProvider)
 		c.EmbeddingProvider = "ollama"
 	}
 
+	// Import format validation
+	if c.ImportFrom != "html" && c.ImportFrom != "notion-html" {
+		slog.Warn("Invalid import format, defaulting to 'notion-html'", "provided", c.ImportFrom)
+		c.ImportFrom = "notion-html"
+	}
+
+	// Export depth validation
+	if c.ExportDepth < 0 {
+		slog.Warn("Invalid export depth, defaulting to 1", "provided", c.ExportDepth)
+		c.ExportDepth = 1
+	}
+
+	// Upload destination validation
+	if c.Upload != "" && !strings.HasPrefix(c.Upload, "s3://") {
+		slog.Warn("Invalid upload destination, only s3:// is supported, upload disabled", "provided", c.Upload)
+		c.Upload = ""
+	}
+
+	// Site timezone validation
+	if _, err := time.LoadLocation(c.SiteTimezone); err != nil {
+		slog.Warn("Invalid SITE_TIMEZONE, defaulting to 'UTC'", "provided", c.SiteTimezone, "error", err)
+		c.SiteTimezone = "UTC"
+	}
+
+	// Git web URL validation, every commit would link to the same page
+	if c.GitWebURL != "" && !strings.Contains(c.GitWebURL, "{hash}") {
+		slog.Warn("Invalid GIT_WEB_URL, it needs {hash}, commit links disabled", "provided", c.GitWebURL)
+		c.GitWebURL = ""
+	}
+
+	// Embed link target validation
+	if c.EmbedLinkTarget != "_top" && c.EmbedLinkTarget != "_blank" {
+		slog.Warn("Invalid EMBED_LINK_TARGET, defaulting to '_top'", "provided", c.EmbedLinkTarget)
+		c.EmbedLinkTarget = "_top"
+	}
+
+	// Embeddings throttling validation
+	if c.EmbeddingsRateLimit < 0 {
+		slog.Warn("Invalid EMBEDDINGS_RATE_LIMIT, defaulting to no limit", "provided", c.EmbeddingsRateLimit)
+		c.EmbeddingsRateLimit = 0
+	}
+	if c.EmbeddingsConcurrency < 1 {
+		slog.Warn("Invalid EMBEDDINGS_CONCURRENCY, defaulting to 1", "provided", c.EmbeddingsConcurrency)
+		c.EmbeddingsConcurrency = 1
+	}
+
+	// Site URL validation
+	c.SiteURL = strings.TrimSuffix(strings.TrimSpace(c.SiteURL), "/")
+	if c.SiteURL != "" && !strings.HasPrefix(c.SiteURL, "http://") && !strings.HasPrefix(c.SiteURL, "https://") {
+		slog.Warn("Invalid SITE_URL, it must start with http:// or https://, ignoring it", "provided", c.SiteURL)
+		c.SiteURL = ""
+	}
+
+	// Exclusion globs parsing
+	c.ExcludeGlobs = parseExcludeGlobs(c.ExcludePaths)
+
+	// Share keys parsing
+	c.ShareKeyFolders = parseShareKeys(c.ShareKeys)
+
+	if (c.BasicAuthUser == "") != (c.BasicAuthPass == "") {
+		slog.Warn("BASIC_AUTH_USER and BASIC_AUTH_PASS must both be set, basic auth accepts no credential")
+	}
+
+	if c.ShutdownTimeoutSeconds < 1 {
+		slog.Warn("Invalid SHUTDOWN_TIMEOUT_SECONDS, defaulting to 10", "provided", c.ShutdownTimeoutSeconds)
+		c.ShutdownTimeoutSeconds = defaultShutdownTimeoutSeconds
+	}
+
+	if c.WatchDebounceMS < 1 {
+		slog.Warn("Invalid WATCH_DEBOUNCE_MS, defaulting to 500", "provided", c.WatchDebounceMS)
+		c.WatchDebounceMS = defaultWatchDebounceMS
+	}
+
+	if c.FeedSize < 1 {
+		slog.Warn("Invalid FEED_SIZE, defaulting to 20", "provided", c.FeedSize)
+		c.FeedSize = 20
+	}
+
+	if c.ReadingWPM < 1 {
+		slog.Warn("Invalid READING_WPM, defaulting to 200", "provided", c.ReadingWPM)
+		c.ReadingWPM = 200
+	}
+
+	if c.RenderCacheSize < 0 {
+		slog.Warn("Invalid RENDER_CACHE_SIZE, disabling the render cache", "provided", c.RenderCacheSize)
+		c.RenderCacheSize = 0
+	}
+
+	// Slug scheme validation
+	c.SlugScheme = strings.ToLower(c.SlugScheme)
+	if c.SlugScheme != SlugSchemeV1 && c.SlugScheme != SlugSchemeV2 {
+		slog.Warn("Invalid SLUG_SCHEME, defaulting to 'v1'", "provided", c.SlugScheme)
+		c.SlugScheme = SlugSchemeV1
+	}
+
+	// Flashcards export validation
+	c.FlashcardsSeparator = strings.ToLower(c.FlashcardsSeparator)
+	if _, ok := FlashcardsSeparators[c.FlashcardsSeparator]; !ok {
+		slog.Warn("Invalid FLASHCARDS_SEPARATOR, defaulting to 'comma'", "provided", c.FlashcardsSeparator)
+		c.FlashcardsSeparator = "comma"
+	}
+	if c.Mode == "export-flashcards" && c.Output == "dist" {
+		// The static site folder default makes no sense for a file
+		c.Output = "flashcards.csv"
+	}
+
+	// Inspect mode validation
+	c.InspectFormat = strings.ToLower(c.InspectFormat)
+	if c.InspectFormat != "json" && c.InspectFormat != "csv" {
+		slog.Warn("Invalid inspect format, defaulting to 'json'", "provided", c.InspectFormat)
+		c.InspectFormat = "json"
+	}
+	if c.Mode == "inspect" && c.Output == "dist" {
+		c.Output = "notes." + c.InspectFormat
+	}
+
+	// Trash validation
+	if c.TrashDays < 0 {
+		slog.Warn("Invalid TRASH_DAYS, disabling the trash", "provided", c.TrashDays)
+		c.TrashDays = 0
+	}
+
+	// Comments validation, the watcher and the static site must never see the comments as notes
+	if c.CommentsEnabled {
+		if strings.TrimSpace(c.CommentsDir) == "" {
+			slog.Warn("COMMENTS_DIR is empty, comments disabled")
+			c.CommentsEnabled = false
+		} else if insideDir(c.CommentsDir, c.Path) {
+			slog.Warn("COMMENTS_DIR is inside the vault, comments disabled", "provided", c.CommentsDir, "path", c.Path)
+			c.CommentsEnabled = false
+		}
+	}
+
 	// Weaviate scheme validation
 	if c.WeaviateScheme != "http" && c.WeaviateScheme != "https" {
 		slog.Warn("Invalid WEAVIATE_SCHEME, defaulting to 'http'", "provided", c.WeaviateScheme)
 		c.WeaviateScheme = "http"
 	}
+
+	// Vector store validation
+	c.VectorStore = strings.ToLower(c.VectorStore)
+	if c.VectorStore != VectorStoreAuto && c.VectorStore != VectorStoreWeaviate && c.VectorStore != VectorStoreEmbedded {
+		slog.Warn("Invalid VECTOR_STORE, defaulting to 'auto'", "provided", c.VectorStore)
+		c.VectorStore = VectorStoreAuto
+	}
+}
+
+// insideDir reports whether path is dir or one of its subfolders
+func insideDir(path, dir string) bool {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// Vector stores of the semantic search, chosen with VECTOR_STORE
+const (
+	VectorStoreAuto     = "auto"     // Weaviate when it answers at startup, else the embedded store
+	VectorStoreWeaviate = "weaviate" // A Weaviate server, at WEAVIATE_HOST
+	VectorStoreEmbedded = "embedded" // In memory, saved to EMBEDDINGS_STORE_FILE
+)
+
+// Slug schemes, chosen with SLUG_SCHEME
+const (
+	SlugSchemeV1 = "v1" // Lowercase path, spaces as dashes, other characters URL-encoded
+	SlugSchemeV2 = "v2" // Lowercase letters, digits and dashes only, accents and punctuation stripped
+)
+
+// FlashcardsSeparators are the supported FLASHCARDS_SEPARATOR values and their character
+var FlashcardsSeparators = map[string]rune{
+	"comma":     ',',
+	"semicolon": ';',
+	"tab":       '\t',
+	"pipe":      '|',
+}
+
+// ChatProviderConfig is one provider of the chat failover chain
+type ChatProviderConfig struct {
+	Type      string        // "ollama", "mistral", or "openai"
+	BaseURL   string        // Server URL, OLLAMA_URL by default for ollama, the official API otherwise
+	Model     string        // CHAT_MODEL by default
+	APIKeyEnv string        // Environment variable holding the API key, MISTRAL_API_KEY or OPENAI_API_KEY by default
+	APIKey    string        // Resolved from APIKeyEnv
+	Timeout   time.Duration // Maximum wait for the first token before trying the next provider, 0 for none
+}
+
+// parseExcludeGlobs parses ExcludePaths: comma-separated globs of vault paths, where "**"
+// matches any number of folders. Invalid globs are skipped with a warning.
+func parseExcludeGlobs(list string) []string {
+	var globs []string
+	for glob := range strings.SplitSeq(list, ",") {
+		glob = strings.Trim(strings.TrimSpace(glob), "/")
+		if glob == "" {
+			continue
+		}
+		if _, err := path.Match(glob, ""); err != nil {
+			slog.Warn("Invalid EXCLUDE_PATHS glob, skipping", "glob", glob, "error", err)
+			continue
+		}
+		globs = append(globs, glob)
+	}
+	return globs
+}
+
+// parseShareKeys parses ShareKeys: comma-separated folder=key pairs, the folder being its
+// path in the vault. Entries without folder or key are skipped with a warning.
+func parseShareKeys(list string) map[string]string {
+	keys := make(map[string]string)
+	for entry := range strings.SplitSeq(list, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		folder, key, _ := strings.Cut(entry, "=")
+		folder = strings.Trim(strings.TrimSpace(folder), "/")
+		key = strings.TrimSpace(key)
+		if folder == "" || key == "" {
+			slog.Warn("Invalid SHARE_KEYS entry, expected folder=key, skipping", "folder", folder)
+			continue
+		}
+		keys[folder] = key
+	}
+	return keys
+}
+
+// parseChatModels parses ChatModels: comma-separated model names, without duplicates
+func parseChatModels(list string) []string {
+	var models []string
+	for model := range strings.SplitSeq(list, ",") {
+		model = strings.TrimSpace(model)
+		if model != "" && !slices.Contains(models, model) {
+			models = append(models, model)
+		}
+	}
+	return models
+}
+
+// parseChatChain parses ChatProviders: comma-separated providers, each a type followed by
+// space-separated url=, model=, key_env= and timeout= options. Invalid entries are skipped with a warning.
+// Without CHAT_PROVIDERS, the chain is the single CHAT_PROVIDER, without timeout.
+func (c *Config) parseChatChain() []ChatProviderConfig {
+	if strings.TrimSpace(c.ChatProviders) == "" {
+		return []ChatProviderConfig{c.chatProviderDefaults(ChatProviderConfig{Type: c.ChatProvider})}
+	}
+
+	var chain []ChatProviderConfig
+	for entry := range strings.SplitSeq(c.ChatProviders, ",") {
+		fields := strings.Fields(entry)
+		if len(fields) == 0 {
+			continue
+		}
+
+		provider := ChatProviderConfig{Type: strings.ToLower(fields[0])}
+		if provider.Type != "ollama" && provider.Type != "mistral" && provider.Type != "openai" {
+			slog.Warn("Invalid CHAT_PROVIDERS entry, unknown provider type, skipping", "entry", strings.TrimSpace(entry))
+			continue
+		}
+
+		valid := true
+		for _, field := range fields[1:] {
+			key, value, _ := strings.Cut(field, "=")
+			switch key {
+			case "url":
+				provider.BaseURL = value
+			case "model":
+				provider.Model = value
+			case "key_env":
+				provider.APIKeyEnv = value
+			case "timeout":
+				timeout, err := time.ParseDuration(value)
+				if err != nil || timeout < 0 {
+					slog.Warn("Invalid CHAT_PROVIDERS timeout, skipping the provider", "entry", strings.TrimSpace(entry))
+					valid = false
+				}
+				provider.Timeout = timeout
+			default:
+				slog.Warn("Unknown CHAT_PROVIDERS option, ignoring it", "option", field)
+			}
+		}
+		if valid {
+			chain = append(chain, c.chatProviderDefaults(provider))
+		}
+	}
+
+	if len(chain) == 0 {
+		slog.Warn("No valid CHAT_PROVIDERS entry, using CHAT_PROVIDER", "provided", c.ChatProviders)
+		return []ChatProviderConfig{c.chatProviderDefaults(ChatProviderConfig{Type: c.ChatProvider})}
+	}
+	return chain
+}
+
+// chatProviderDefaults fills the unset fields of a provider with the single provider settings
+func (c *Config) chatProviderDefaults(provider ChatProviderConfig) ChatProviderConfig {
+	if provider.Model == "" {
+		provider.Model = c.ChatModel
+	}
+
+	switch provider.Type {
+	case "ollama":
+		if provider.BaseURL == "" {
+			provider.BaseURL = c.OllamaURL
+		}
+	case "mistral":
+		provider.APIKey = c.MistralAPIKey
+	case "openai":
+		provider.APIKey = c.OpenAIAPIKey
+	}
+	if provider.APIKeyEnv != "" {
+		provider.APIKey = os.Getenv(provider.APIKeyEnv)
+	}
+	return provider
 }
 
 // LogValue implements slog.LogValuer to redact sensitive fields when logging
 func (c Config) LogValue() slog.Value {
 	return slog.GroupValue(
+		slog.String("ConfigFile", c.ConfigFile),
 		slog.String("Path", c.Path),
 		slog.Bool("Watch", c.Watch),
 		slog.String("Mode", c.Mode),
 		slog.String("Output", c.Output),
+		slog.String("ImportFrom", c.ImportFrom),
+		slog.String("ImportInput", c.ImportInput),
+		slog.Bool("Force", c.Force),
+		slog.String("ExportNote", c.ExportNote),
+		slog.Int("ExportDepth", c.ExportDepth),
+		slog.Bool("ExportIncludePrivate", c.ExportIncludePrivate),
+		slog.String("InspectFormat", c.InspectFormat),
+		slog.Bool("OrphansOnly", c.OrphansOnly),
 		slog.String("Port", c.Port),
+		slog.Int("ShutdownTimeoutSeconds", c.ShutdownTimeoutSeconds),
 		slog.Bool("LogJSON", c.LogJSON),
+		slog.Bool("MetricsEnabled", c.MetricsEnabled),
+		slog.String("MetricsToken", redact(c.MetricsToken)),
+		slog.Bool("RegexSearch", c.RegexSearch),
+		slog.String("AuthToken", redact(c.AuthToken)),
+		slog.String("BasicAuthUser", c.BasicAuthUser),
+		slog.String("BasicAuthPass", redact(c.BasicAuthPass)),
+		slog.Int("WatchDebounceMS", c.WatchDebounceMS),
+		slog.String("ReloadToken", redact(c.ReloadToken)),
 		slog.String("SiteTitle", c.SiteTitle),
 		slog.String("SiteIcon", c.SiteIcon),
 		slog.String("SiteDescription", c.SiteDescription),
+		slog.String("SiteURL", c.SiteURL),
+		slog.Int("FeedSize", c.FeedSize),
+		slog.String("RobotsDisallow", c.RobotsDisallow),
 		slog.Bool("HideYamlFrontmatter", c.HideYamlFrontmatter),
+		slog.Bool("Mermaid", c.Mermaid),
+		slog.Int("ReadingWPM", c.ReadingWPM),
+		slog.Int("RenderCacheSize", c.RenderCacheSize),
+		slog.Bool("SidebarLazy", c.SidebarLazy),
+		slog.Bool("TOCEmbeds", c.TOCEmbeds),
+		slog.String("SiteTimezone", c.SiteTimezone),
+		slog.String("GitWebURL", c.GitWebURL),
+		slog.String("EmbedLinkTarget", c.EmbedLinkTarget),
+		slog.String("EmbedFrameAncestors", c.EmbedFrameAncestors),
+		slog.String("CORSOrigins", c.CORSOrigins),
 		slog.Bool("PublicByDefault", c.PublicByDefault),
+		slog.Bool("ShowDrafts", c.ShowDrafts),
+		slog.Bool("ForcePublic", c.ForcePublic),
+		slog.Bool("Preview", c.Preview),
 		slog.String("HomeNoteSlug", c.HomeNoteSlug),
+		slog.String("PrivateStatuses", c.PrivateStatuses),
+		slog.String("ShareKeys", redact(c.ShareKeys)),
+		slog.String("ExcludePaths", c.ExcludePaths),
+		slog.Bool("ExcludePathsIgnoreCase", c.ExcludePathsIgnoreCase),
+		slog.String("SlugScheme", c.SlugScheme),
+		slog.Bool("SlugTransliterate", c.SlugTransliterate),
+		slog.String("AttachmentExtensions", c.AttachmentExtensions),
+		slog.Int("TrashDays", c.TrashDays),
+		slog.String("TrashFile", c.TrashFile),
+		slog.Bool("ShowViewCount", c.ShowViewCount),
+		slog.String("ViewsFile", c.ViewsFile),
+		slog.Bool("CommentsEnabled", c.CommentsEnabled),
+		slog.String("CommentsDir", c.CommentsDir),
+		slog.String("FlashcardsTag", c.FlashcardsTag),
+		slog.String("FlashcardsSeparator", c.FlashcardsSeparator),
+		slog.String("FlashcardsToken", redact(c.FlashcardsToken)),
+		slog.String("NoteStatuses", c.NoteStatuses),
 		slog.String("ChatProvider", c.ChatProvider),
 		slog.String("ChatModel", c.ChatModel),
 		slog.String("OllamaURL", c.OllamaURL),
 		slog.String("MistralAPIKey", redact(c.MistralAPIKey)),
 		slog.String("OpenAIAPIKey", redact(c.OpenAIAPIKey)),
+		slog.String("ChatProviders", c.ChatProviders),
+		slog.Int("ChatCooldownSeconds", c.ChatCooldownSeconds),
+		slog.String("ChatModels", c.ChatModels),
+		slog.Bool("PromptTemplate", c.PromptTemplate != ""),
+		slog.Bool("SystemPrompt", c.SystemPrompt != ""),
+		slog.Int("AIContextChars", c.AIContextChars),
 		slog.String("EmbeddingProvider", c.EmbeddingProvider),
 		slog.String("EmbeddingsTrackingFile", c.EmbeddingsTrackingFile),
 		slog.String("EmbeddingModel", c.EmbeddingModel),
+		slog.Int("EmbeddingsRateLimit", c.EmbeddingsRateLimit),
+		slog.Int("EmbeddingsConcurrency", c.EmbeddingsConcurrency),
+		slog.String("EmbeddingsToken", redact(c.EmbeddingsToken)),
+		slog.Bool("ForceReembed", c.ForceReembed),
+		slog.String("VectorStore", c.VectorStore),
+		slog.String("EmbeddingsStoreFile", c.EmbeddingsStoreFile),
 		slog.String("WeaviateHost", c.WeaviateHost),
 		slog.String("WeaviateScheme", c.WeaviateScheme),
 		slog.String("WeaviateIndex", c.WeaviateIndex),
+		slog.String("Upload", c.Upload),
+		slog.Bool("Prune", c.Prune),
+		slog.Bool("Minify", c.Minify),
+		slog.String("S3Endpoint", c.S3Endpoint),
+		slog.String("S3Region", c.S3Region),
+		slog.String("S3AccessKeyID", redact(c.S3AccessKeyID)),
+		slog.String("S3SecretAccessKey", redact(c.S3SecretAccessKey)),
 	)
 }
 
+// DraftsShown reports whether the notes with "draft: true" are served: by the server with
+// SHOW_DRAFTS or in preview mode, never in static sites
+func (c *Config) DraftsShown() bool {
+	return c.Mode == "server" && (c.ShowDrafts || c.ForcePublic)
+}
+
+// ChatModelAllowed reports whether a search can pick model with ?model=: the empty default, or
+// one of CHAT_MODELS
+func (c *Config) ChatModelAllowed(model string) bool {
+	return model == "" || slices.Contains(c.ChatModelChoices, model)
+}
+
+// CORSAllowed reports whether the browser pages of origin may call the API: CORS_ORIGINS lists
+// it, or is "*". Origins are compared without the case and a trailing slash.
+func (c *Config) CORSAllowed(origin string) bool {
+	if origin == "" {
+		return false
+	}
+	for allowed := range strings.SplitSeq(c.CORSOrigins, ",") {
+		allowed = strings.TrimSuffix(strings.TrimSpace(allowed), "/")
+		if allowed == "*" || (allowed != "" && strings.EqualFold(allowed, strings.TrimSuffix(origin, "/"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// Location returns the site timezone, UTC when unset or invalid
+func (c *Config) Location() *time.Location {
+	if c == nil || c.SiteTimezone == "" {
+		return time.UTC
+	}
+	if loc, ok := locationCache.Load(c.SiteTimezone); ok {
+		return loc.(*time.Location)
+	}
+	loc, err := time.LoadLocation(c.SiteTimezone)
+	if err != nil {
+		return time.UTC
+	}
+	locationCache.Store(c.SiteTimezone, loc)
+	return loc
+}
+
+// locationCache avoids reading the zoneinfo database on every render
+var locationCache sync.Map
+
+// defaultExportDepth is the default -depth
+const defaultExportDepth = 1
+
+// defaultShutdownTimeoutSeconds is the default SHUTDOWN_TIMEOUT_SECONDS
+const defaultShutdownTimeoutSeconds = 10
+
+// ShutdownTimeout returns how long the running requests get to finish at shutdown, the default
+// for an unset or invalid ShutdownTimeoutSeconds
+func (c *Config) ShutdownTimeout() time.Duration {
+	if c == nil || c.ShutdownTimeoutSeconds < 1 {
+		return defaultShutdownTimeoutSeconds * time.Second
+	}
+	return time.Duration(c.ShutdownTimeoutSeconds) * time.Second
+}
+
+// defaultWatchDebounceMS is the default WATCH_DEBOUNCE_MS
+const defaultWatchDebounceMS = 500
+
+// WatchDebounce returns how long the watcher waits without file changes before reloading
+// the notes, the default for an unset or invalid WatchDebounceMS
+func (c *Config) WatchDebounce() time.Duration {
+	if c == nil || c.WatchDebounceMS < 1 {
+		return defaultWatchDebounceMS * time.Millisecond
+	}
+	return time.Duration(c.WatchDebounceMS) * time.Millisecond
+}
+
+// AuthEnabled reports whether the whole site needs a credential: an AUTH_TOKEN or a basic
+// auth user or password. A user without password, or the reverse, accepts no basic auth.
+func (c *Config) AuthEnabled() bool {
+	return c != nil && (c.AuthToken != "" || c.BasicAuthUser != "" || c.BasicAuthPass != "")
+}
+
 // redact returns a redacted version of a secret string, showing first/last 4 chars
 func redact(s string) string {
 	if s == "" {
@@ -243,3 +1064,13 @@ func getEnvBool(key string, defaultValue bool) bool {
 	}
 	return defaultValue
 }
+
+// getEnvInt returns the environment variable as an integer or a default if not set/invalid
+func getEnvInt(key string, defaultValue int) int {
+	if envValue := os.Getenv(key); envValue != "" {
+		if parsed, err := strconv.Atoi(envValue); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
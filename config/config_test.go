@@ -1,8 +1,12 @@
 package config
 
 import (
+	"maps"
 	"os"
+	"path/filepath"
+	"slices"
 	"testing"
+	"time"
 )
 
 func TestLoad(t *testing.T) {
@@ -22,6 +26,8 @@ func TestLoad(t *testing.T) {
 				PublicByDefault:     false,
 				HomeNoteSlug:        "Index",
 				HideYamlFrontmatter: false,
+				SiteTimezone:        "UTC",
+				EmbedLinkTarget:     "_top",
 			},
 		},
 		{
@@ -34,6 +40,9 @@ func TestLoad(t *testing.T) {
 				"PUBLIC_BY_DEFAULT":     "true",
 				"HOME_NOTE_SLUG":        "Home",
 				"HIDE_YAML_FRONTMATTER": "true",
+				"SITE_TIMEZONE":         "Europe/Paris",
+				"EMBED_LINK_TARGET":     "_blank",
+				"PRIVATE_STATUSES":      "draft,review",
 			},
 			expected: Config{
 				Port:                "8080",
@@ -43,6 +52,9 @@ func TestLoad(t *testing.T) {
 				PublicByDefault:     true,
 				HomeNoteSlug:        "Home",
 				HideYamlFrontmatter: true,
+				SiteTimezone:        "Europe/Paris",
+				EmbedLinkTarget:     "_blank",
+				PrivateStatuses:     "draft,review",
 			},
 		},
 		{
@@ -60,6 +72,8 @@ func TestLoad(t *testing.T) {
 				PublicByDefault:     true,
 				HomeNoteSlug:        "Index",
 				HideYamlFrontmatter: false,
+				SiteTimezone:        "UTC",
+				EmbedLinkTarget:     "_top",
 			},
 		},
 		{
@@ -67,6 +81,8 @@ func TestLoad(t *testing.T) {
 			envVars: map[string]string{
 				"PUBLIC_BY_DEFAULT":     "invalid",
 				"HIDE_YAML_FRONTMATTER": "not-a-bool",
+				"SITE_TIMEZONE":         "Mars/Olympus_Mons",
+				"EMBED_LINK_TARGET":     "_parent",
 			},
 			expected: Config{
 				Port:                "9999",
@@ -76,6 +92,8 @@ func TestLoad(t *testing.T) {
 				PublicByDefault:     false,
 				HomeNoteSlug:        "Index",
 				HideYamlFrontmatter: false,
+				SiteTimezone:        "UTC",
+				EmbedLinkTarget:     "_top",
 			},
 		},
 		{
@@ -93,6 +111,8 @@ func TestLoad(t *testing.T) {
 				PublicByDefault:     false,
 				HomeNoteSlug:        "Index",
 				HideYamlFrontmatter: false,
+				SiteTimezone:        "UTC",
+				EmbedLinkTarget:     "_top",
 			},
 		},
 	}
@@ -102,7 +122,8 @@ func TestLoad(t *testing.T) {
 			// Clear all environment variables first
 			envVarsToClean := []string{
 				"PORT", "SITE_TITLE", "SITE_ICON", "SITE_DESCRIPTION",
-				"PUBLIC_BY_DEFAULT", "HOME_NOTE_SLUG", "HIDE_YAML_FRONTMATTER",
+				"PUBLIC_BY_DEFAULT", "HOME_NOTE_SLUG", "HIDE_YAML_FRONTMATTER", "SITE_TIMEZONE",
+				"EMBED_LINK_TARGET",
 			}
 			for _, key := range envVarsToClean {
 				os.Unsetenv(key)
@@ -145,6 +166,18 @@ func TestLoad(t *testing.T) {
 			if cfg.HideYamlFrontmatter != tt.expected.HideYamlFrontmatter {
 				t.Errorf("HideYamlFrontmatter = %v, want %v", cfg.HideYamlFrontmatter, tt.expected.HideYamlFrontmatter)
 			}
+			if cfg.SiteTimezone != tt.expected.SiteTimezone {
+				t.Errorf("SiteTimezone = %q, want %q", cfg.SiteTimezone, tt.expected.SiteTimezone)
+			}
+			if cfg.Location().String() != tt.expected.SiteTimezone {
+				t.Errorf("Location() = %q, want %q", cfg.Location(), tt.expected.SiteTimezone)
+			}
+			if cfg.EmbedLinkTarget != tt.expected.EmbedLinkTarget {
+				t.Errorf("EmbedLinkTarget = %q, want %q", cfg.EmbedLinkTarget, tt.expected.EmbedLinkTarget)
+			}
+			if cfg.PrivateStatuses != tt.expected.PrivateStatuses {
+				t.Errorf("PrivateStatuses = %q, want %q", cfg.PrivateStatuses, tt.expected.PrivateStatuses)
+			}
 		})
 	}
 }
@@ -290,3 +323,337 @@ func TestGetEnvBool(t *testing.T) {
 		})
 	}
 }
+
+func TestGetEnvInt(t *testing.T) {
+	tests := []struct {
+		name         string
+		envValue     string
+		defaultValue int
+		expected     int
+	}{
+		{name: "Valid integer", envValue: "30", defaultValue: 0, expected: 30},
+		{name: "Invalid value uses default", envValue: "fast", defaultValue: 2, expected: 2},
+		{name: "Empty value uses default", envValue: "", defaultValue: 1, expected: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TEST_INT", tt.envValue)
+
+			if result := getEnvInt("TEST_INT", tt.defaultValue); result != tt.expected {
+				t.Errorf("getEnvInt(%q, %d) = %d, want %d", tt.envValue, tt.defaultValue, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestValidate_EmbeddingsThrottle(t *testing.T) {
+	cfg := &Config{Mode: "server", Path: ".", ChatProvider: "ollama", EmbeddingProvider: "ollama", ImportFrom: "html", SiteTimezone: "UTC", EmbedLinkTarget: "_top", WeaviateScheme: "http", EmbeddingsRateLimit: -5, EmbeddingsConcurrency: 0}
+	cfg.validate()
+
+	if cfg.EmbeddingsRateLimit != 0 {
+		t.Errorf("EmbeddingsRateLimit = %d, want 0", cfg.EmbeddingsRateLimit)
+	}
+	if cfg.EmbeddingsConcurrency != 1 {
+		t.Errorf("EmbeddingsConcurrency = %d, want 1", cfg.EmbeddingsConcurrency)
+	}
+}
+
+func TestValidate_TrashDays(t *testing.T) {
+	cfg := &Config{Mode: "server", Path: ".", ChatProvider: "ollama", EmbeddingProvider: "ollama", ImportFrom: "html", SiteTimezone: "UTC", EmbedLinkTarget: "_top", WeaviateScheme: "http", EmbeddingsConcurrency: 1, TrashDays: -3}
+	cfg.validate()
+
+	if cfg.TrashDays != 0 {
+		t.Errorf("TrashDays = %d, want 0 (trash disabled)", cfg.TrashDays)
+	}
+}
+
+func TestValidate_CommentsDir(t *testing.T) {
+	vault := t.TempDir()
+	for dir, enabled := range map[string]bool{
+		filepath.Join(t.TempDir(), "comments"): true,
+		filepath.Join(vault, "comments"):       false,
+		vault:                                  false,
+		"":                                     false,
+	} {
+		cfg := &Config{Mode: "server", Path: vault, ChatProvider: "ollama", EmbeddingProvider: "ollama", ImportFrom: "html", SiteTimezone: "UTC", EmbedLinkTarget: "_top", WeaviateScheme: "http", EmbeddingsConcurrency: 1, CommentsEnabled: true, CommentsDir: dir}
+		cfg.validate()
+		if cfg.CommentsEnabled != enabled {
+			t.Errorf("CommentsEnabled = %v with COMMENTS_DIR=%q, want %v", cfg.CommentsEnabled, dir, enabled)
+		}
+	}
+}
+
+func TestValidate_Flashcards(t *testing.T) {
+	cfg := &Config{Mode: "export-flashcards", Output: "dist", Path: ".", ChatProvider: "ollama", EmbeddingProvider: "ollama", ImportFrom: "html", SiteTimezone: "UTC", EmbedLinkTarget: "_top", WeaviateScheme: "http", EmbeddingsConcurrency: 1, FlashcardsSeparator: "Colon"}
+	cfg.validate()
+
+	if cfg.Mode != "export-flashcards" {
+		t.Errorf("Mode = %q, want export-flashcards", cfg.Mode)
+	}
+	if cfg.Output != "flashcards.csv" {
+		t.Errorf("Output = %q, want flashcards.csv instead of the static site folder", cfg.Output)
+	}
+	if cfg.FlashcardsSeparator != "comma" {
+		t.Errorf("FlashcardsSeparator = %q, want comma", cfg.FlashcardsSeparator)
+	}
+
+	cfg.FlashcardsSeparator = "Tab"
+	cfg.validate()
+	if cfg.FlashcardsSeparator != "tab" {
+		t.Errorf("FlashcardsSeparator = %q, want tab", cfg.FlashcardsSeparator)
+	}
+}
+
+func TestValidate_Inspect(t *testing.T) {
+	cfg := &Config{Mode: "inspect", Output: "dist", Path: ".", ChatProvider: "ollama", EmbeddingProvider: "ollama", ImportFrom: "html", SiteTimezone: "UTC", EmbedLinkTarget: "_top", WeaviateScheme: "http", EmbeddingsConcurrency: 1, FlashcardsSeparator: "comma", InspectFormat: "CSV"}
+	cfg.validate()
+
+	if cfg.Mode != "inspect" {
+		t.Errorf("Mode = %q, want inspect", cfg.Mode)
+	}
+	if cfg.InspectFormat != "csv" {
+		t.Errorf("InspectFormat = %q, want csv", cfg.InspectFormat)
+	}
+	if cfg.Output != "notes.csv" {
+		t.Errorf("Output = %q, want notes.csv instead of the static site folder", cfg.Output)
+	}
+
+	cfg.InspectFormat = "xml"
+	cfg.validate()
+	if cfg.InspectFormat != "json" {
+		t.Errorf("InspectFormat = %q, want json", cfg.InspectFormat)
+	}
+}
+
+func TestValidate_SlugScheme(t *testing.T) {
+	tests := []struct {
+		provided string
+		expected string
+	}{
+		{provided: "v1", expected: SlugSchemeV1},
+		{provided: "V2", expected: SlugSchemeV2},
+		{provided: "v3", expected: SlugSchemeV1},
+		{provided: "", expected: SlugSchemeV1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.provided, func(t *testing.T) {
+			cfg := &Config{Mode: "server", Path: ".", ChatProvider: "ollama", EmbeddingProvider: "ollama", ImportFrom: "html", SiteTimezone: "UTC", EmbedLinkTarget: "_top", WeaviateScheme: "http", EmbeddingsConcurrency: 1, FlashcardsSeparator: "comma", SlugScheme: tt.provided}
+			cfg.validate()
+			if cfg.SlugScheme != tt.expected {
+				t.Errorf("SlugScheme = %q, want %q", cfg.SlugScheme, tt.expected)
+			}
+		})
+	}
+}
+
+func TestValidate_SiteURL(t *testing.T) {
+	tests := []struct {
+		provided string
+		expected string
+	}{
+		{provided: "https://notes.example.com/", expected: "https://notes.example.com"},
+		{provided: " http://localhost:9999 ", expected: "http://localhost:9999"},
+		{provided: "notes.example.com", expected: ""},
+		{provided: "", expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.provided, func(t *testing.T) {
+			cfg := &Config{Mode: "server", Path: ".", ChatProvider: "ollama", EmbeddingProvider: "ollama", ImportFrom: "html", SiteTimezone: "UTC", EmbedLinkTarget: "_top", WeaviateScheme: "http", EmbeddingsConcurrency: 1, FlashcardsSeparator: "comma", SlugScheme: "v1", SiteURL: tt.provided}
+			cfg.validate()
+			if cfg.SiteURL != tt.expected {
+				t.Errorf("SiteURL = %q, want %q", cfg.SiteURL, tt.expected)
+			}
+			if cfg.FeedSize != 20 {
+				t.Errorf("FeedSize = %d, want the default 20 when unset", cfg.FeedSize)
+			}
+			if cfg.ReadingWPM != 200 {
+				t.Errorf("ReadingWPM = %d, want the default 200 when unset", cfg.ReadingWPM)
+			}
+		})
+	}
+}
+
+func TestValidate_ShutdownTimeout(t *testing.T) {
+	for provided, expected := range map[int]time.Duration{
+		0:  10 * time.Second,
+		-1: 10 * time.Second,
+		30: 30 * time.Second,
+	} {
+		cfg := &Config{Mode: "server", Path: ".", ChatProvider: "ollama", EmbeddingProvider: "ollama", ImportFrom: "html", SiteTimezone: "UTC", EmbedLinkTarget: "_top", WeaviateScheme: "http", EmbeddingsConcurrency: 1, FlashcardsSeparator: "comma", SlugScheme: "v1", WatchDebounceMS: 500, ShutdownTimeoutSeconds: provided}
+		cfg.validate()
+		if cfg.ShutdownTimeout() != expected {
+			t.Errorf("ShutdownTimeout() = %v with SHUTDOWN_TIMEOUT_SECONDS=%d, want %v", cfg.ShutdownTimeout(), provided, expected)
+		}
+	}
+
+	if got := (&Config{}).ShutdownTimeout(); got != 10*time.Second {
+		t.Errorf("ShutdownTimeout() = %v for an empty config, want 10s", got)
+	}
+}
+
+func TestValidate_WatchDebounce(t *testing.T) {
+	for provided, expected := range map[int]time.Duration{
+		0:    500 * time.Millisecond,
+		-10:  500 * time.Millisecond,
+		1500: 1500 * time.Millisecond,
+	} {
+		cfg := &Config{Mode: "server", Path: ".", ChatProvider: "ollama", EmbeddingProvider: "ollama", ImportFrom: "html", SiteTimezone: "UTC", EmbedLinkTarget: "_top", WeaviateScheme: "http", EmbeddingsConcurrency: 1, FlashcardsSeparator: "comma", SlugScheme: "v1", WatchDebounceMS: provided}
+		cfg.validate()
+		if cfg.WatchDebounce() != expected {
+			t.Errorf("WatchDebounce() = %v with WATCH_DEBOUNCE_MS=%d, want %v", cfg.WatchDebounce(), provided, expected)
+		}
+	}
+
+	// Configs built without LoadConfig get the default too
+	if got := (&Config{}).WatchDebounce(); got != 500*time.Millisecond {
+		t.Errorf("WatchDebounce() = %v for an empty config, want 500ms", got)
+	}
+}
+
+func TestValidate_ExcludeGlobs(t *testing.T) {
+	cfg := &Config{Mode: "server", Path: ".", ChatProvider: "ollama", EmbeddingProvider: "ollama", ImportFrom: "html", SiteTimezone: "UTC", EmbedLinkTarget: "_top", WeaviateScheme: "http", EmbeddingsConcurrency: 1, FlashcardsSeparator: "comma", SlugScheme: "v1", WatchDebounceMS: 500,
+		ExcludePaths: " Archive/** , /Templates/,,*.excalidraw.md,[broken"}
+	cfg.validate()
+
+	expected := []string{"Archive/**", "Templates", "*.excalidraw.md"}
+	if !slices.Equal(cfg.ExcludeGlobs, expected) {
+		t.Errorf("ExcludeGlobs = %q, want %q", cfg.ExcludeGlobs, expected)
+	}
+}
+
+func TestValidate_ShareKeys(t *testing.T) {
+	cfg := &Config{Mode: "server", Path: ".", ChatProvider: "ollama", EmbeddingProvider: "ollama", ImportFrom: "html", SiteTimezone: "UTC", EmbedLinkTarget: "_top", WeaviateScheme: "http", EmbeddingsConcurrency: 1, FlashcardsSeparator: "comma", SlugScheme: "v1", WatchDebounceMS: 500,
+		ShareKeys: " Clients/Acme/ = abc123 ,,Family=xyz,nokey=,=orphan"}
+	cfg.validate()
+
+	expected := map[string]string{"Clients/Acme": "abc123", "Family": "xyz"}
+	if !maps.Equal(cfg.ShareKeyFolders, expected) {
+		t.Errorf("ShareKeyFolders = %q, want %q", cfg.ShareKeyFolders, expected)
+	}
+}
+
+func TestValidate_ChatModels(t *testing.T) {
+	cfg := &Config{Mode: "server", Path: ".", ChatProvider: "ollama", ChatModel: "tinyllama", EmbeddingProvider: "ollama", ImportFrom: "html", SiteTimezone: "UTC", EmbedLinkTarget: "_top", WeaviateScheme: "http", EmbeddingsConcurrency: 1, FlashcardsSeparator: "comma", SlugScheme: "v1", WatchDebounceMS: 500,
+		ChatModels: " llama3 ,,mistral-small, llama3"}
+	cfg.validate()
+
+	if expected := []string{"llama3", "mistral-small"}; !slices.Equal(cfg.ChatModelChoices, expected) {
+		t.Errorf("ChatModelChoices = %q, want %q", cfg.ChatModelChoices, expected)
+	}
+	for model, allowed := range map[string]bool{"": true, "llama3": true, "mistral-small": true, "gpt-4o": false, " llama3": false} {
+		if cfg.ChatModelAllowed(model) != allowed {
+			t.Errorf("ChatModelAllowed(%q) = %v, want %v", model, !allowed, allowed)
+		}
+	}
+}
+
+func TestValidate_GitWebURL(t *testing.T) {
+	for provided, expected := range map[string]string{
+		"https://github.com/me/vault/commit/{hash}": "https://github.com/me/vault/commit/{hash}",
+		"https://github.com/me/vault":               "",
+	} {
+		cfg := &Config{Mode: "server", Path: ".", ChatProvider: "ollama", EmbeddingProvider: "ollama", ImportFrom: "html", SiteTimezone: "UTC", EmbedLinkTarget: "_top", WeaviateScheme: "http", EmbeddingsConcurrency: 1, FlashcardsSeparator: "comma", SlugScheme: "v1", WatchDebounceMS: 500, GitWebURL: provided}
+		cfg.validate()
+		if cfg.GitWebURL != expected {
+			t.Errorf("GitWebURL = %q with GIT_WEB_URL=%q, want %q", cfg.GitWebURL, provided, expected)
+		}
+	}
+}
+
+func TestValidate_ChatChain(t *testing.T) {
+	t.Setenv("HOSTED_KEY", "hosted-secret")
+	base := Config{Mode: "server", Path: ".", ChatProvider: "mistral", ChatModel: "tinyllama", OllamaURL: "http://ollama:11434", MistralAPIKey: "mistral-secret", EmbeddingProvider: "ollama", ImportFrom: "html", SiteTimezone: "UTC", EmbedLinkTarget: "_top", WeaviateScheme: "http", EmbeddingsConcurrency: 1}
+
+	tests := []struct {
+		name      string
+		providers string
+		expected  []ChatProviderConfig
+	}{
+		{
+			name:     "single provider without CHAT_PROVIDERS",
+			expected: []ChatProviderConfig{{Type: "mistral", Model: "tinyllama", APIKey: "mistral-secret"}},
+		},
+		{
+			name:      "ordered chain with defaults",
+			providers: "ollama model=llama3 timeout=10s, openai url=https://llm.example.com/v1 model=gpt-4o key_env=HOSTED_KEY timeout=1m",
+			expected: []ChatProviderConfig{
+				{Type: "ollama", BaseURL: "http://ollama:11434", Model: "llama3", Timeout: 10 * time.Second},
+				{Type: "openai", BaseURL: "https://llm.example.com/v1", Model: "gpt-4o", APIKeyEnv: "HOSTED_KEY", APIKey: "hosted-secret", Timeout: time.Minute},
+			},
+		},
+		{
+			name:      "invalid entries are skipped",
+			providers: "claude model=x, ollama timeout=soon, Mistral",
+			expected:  []ChatProviderConfig{{Type: "mistral", Model: "tinyllama", APIKey: "mistral-secret"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := base
+			cfg.ChatProviders = tt.providers
+			cfg.validate()
+
+			if len(cfg.ChatChain) != len(tt.expected) {
+				t.Fatalf("ChatChain = %+v, want %+v", cfg.ChatChain, tt.expected)
+			}
+			for i := range tt.expected {
+				if cfg.ChatChain[i] != tt.expected[i] {
+					t.Errorf("ChatChain[%d] = %+v, want %+v", i, cfg.ChatChain[i], tt.expected[i])
+				}
+			}
+		})
+	}
+}
+
+func TestApplyPreviewPreset(t *testing.T) {
+	cfg := &Config{
+		Mode:            "static",
+		Watch:           false,
+		PublicByDefault: false,
+		HomeNoteSlug:    "Index",
+		Upload:          "s3://bucket",
+		Path:            "/vault",
+	}
+
+	cfg.applyPreviewPreset()
+
+	if cfg.Mode != "server" || !cfg.Watch {
+		t.Errorf("expected a watching server, got mode %q watch %v", cfg.Mode, cfg.Watch)
+	}
+	if !cfg.PublicByDefault || !cfg.ForcePublic {
+		t.Error("expected every note to be public")
+	}
+	if cfg.HomeNoteSlug != "" || cfg.Upload != "" {
+		t.Errorf("expected home note and upload to be reset, got %q and %q", cfg.HomeNoteSlug, cfg.Upload)
+	}
+	if cfg.Path != "/vault" {
+		t.Errorf("expected the path to be kept, got %q", cfg.Path)
+	}
+}
+
+func TestCORSAllowed(t *testing.T) {
+	cfg := &Config{CORSOrigins: "https://editor.example.com/, http://localhost:5173"}
+	for origin, allowed := range map[string]bool{
+		"https://editor.example.com": true,
+		"HTTPS://Editor.example.com": true,
+		"http://localhost:5173":      true,
+		"http://localhost:3000":      false,
+		"https://evil.example.com":   false,
+		"":                           false,
+	} {
+		if cfg.CORSAllowed(origin) != allowed {
+			t.Errorf("CORSAllowed(%q) = %v, want %v", origin, !allowed, allowed)
+		}
+	}
+
+	if (&Config{}).CORSAllowed("https://editor.example.com") {
+		t.Error("without CORS_ORIGINS, no origin should be allowed")
+	}
+	if !(&Config{CORSOrigins: "*"}).CORSAllowed("https://editor.example.com") {
+		t.Error("with *, any origin should be allowed")
+	}
+}
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configFileNames are the config files looked for at the vault root, in order
+var configFileNames = []string{"pluie.yaml", ".pluie.yaml"}
+
+// Load creates the Config from the defaults, the config file at file and the environment
+// variables, each overriding the previous one. An empty file loads no config file. Unknown keys
+// of the file are ignored with a warning.
+func Load(file string) (*Config, error) {
+	cfg := defaultConfig()
+	if file != "" {
+		if err := cfg.applyFile(file); err != nil {
+			return nil, err
+		}
+		cfg.ConfigFile = file
+	}
+	cfg.applyEnvironment()
+	return cfg, nil
+}
+
+// findConfigFile returns the first config file found in the vault folder, "" when there is none
+func findConfigFile(vault string) string {
+	for _, name := range configFileNames {
+		file := filepath.Join(vault, name)
+		if info, err := os.Stat(file); err == nil && !info.IsDir() {
+			return file
+		}
+	}
+	return ""
+}
+
+// applyFile sets the settings of a YAML config file. Its keys are the environment variables in
+// lowercase, with path, watch, mode and output for the flags, and model for chat_model like the
+// -model flag.
+func (c *Config) applyFile(file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("parsing config file %s: %w", file, err)
+	}
+	if len(doc.Content) == 0 {
+		return nil // Empty file
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("parsing config file %s: line %d: expected a mapping of settings", file, root.Line)
+	}
+
+	known := fileKeys()
+	for i := 0; i < len(root.Content); i += 2 {
+		key := root.Content[i]
+		if key.Value == "model" {
+			key.Value = "chat_model"
+		}
+		if !known[key.Value] {
+			slog.Warn("Unknown key in the config file, ignored", "file", file, "line", key.Line, "key", key.Value)
+		}
+	}
+	if err := root.Decode(c); err != nil {
+		return fmt.Errorf("parsing config file %s: %w", file, err)
+	}
+	return nil
+}
+
+// fileKeys returns the keys a config file can set, from the yaml tags of Config
+func fileKeys() map[string]bool {
+	keys := make(map[string]bool)
+	t := reflect.TypeFor[Config]()
+	for i := range t.NumField() {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		if name != "" && name != "-" {
+			keys[name] = true
+		}
+	}
+	return keys
+}
+
+// String returns the configuration as a config file, secrets redacted, for -print-config
+func (c Config) String() string {
+	for _, secret := range []*string{
+		&c.MetricsToken, &c.AuthToken, &c.BasicAuthPass, &c.ReloadToken, &c.ShareKeys, &c.FlashcardsToken,
+		&c.MistralAPIKey, &c.OpenAIAPIKey, &c.EmbeddingsToken, &c.S3AccessKeyID, &c.S3SecretAccessKey,
+	} {
+		*secret = redact(*secret)
+	}
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return fmt.Sprintf("# marshaling config: %v\n", err)
+	}
+	return string(data)
+}
@@ -0,0 +1,123 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "pluie.yaml")
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestLoad_ConfigFile(t *testing.T) {
+	file := writeConfigFile(t, `
+site_title: From the file
+port: 8080
+public_by_default: true
+watch: false
+model: llama3
+chat_cooldown_seconds: 5
+unknown_setting: ignored
+`)
+	t.Setenv("SITE_TITLE", "From the environment")
+
+	cfg, err := Load(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.SiteTitle != "From the environment" {
+		t.Errorf("SiteTitle = %q, want the environment over the file", cfg.SiteTitle)
+	}
+	if cfg.Port != "8080" || !cfg.PublicByDefault || cfg.Watch || cfg.ChatCooldownSeconds != 5 {
+		t.Errorf("got port %q, public %v, watch %v, cooldown %d, want the file values", cfg.Port, cfg.PublicByDefault, cfg.Watch, cfg.ChatCooldownSeconds)
+	}
+	if cfg.ChatModel != "llama3" {
+		t.Errorf("ChatModel = %q, want model to set it", cfg.ChatModel)
+	}
+	if cfg.HomeNoteSlug != "Index" || cfg.Mode != "server" {
+		t.Errorf("got home %q and mode %q, want the defaults", cfg.HomeNoteSlug, cfg.Mode)
+	}
+	if cfg.ConfigFile != file {
+		t.Errorf("ConfigFile = %q, want %q", cfg.ConfigFile, file)
+	}
+}
+
+func TestLoad_ConfigFileErrors(t *testing.T) {
+	tests := map[string]string{
+		"invalid yaml": "site_title: [",
+		"invalid type": "feed_size: many",
+		"not a map":    "- site_title",
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Load(writeConfigFile(t, content)); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+	if _, err := Load(writeConfigFile(t, "")); err != nil {
+		t.Errorf("expected an empty file to set nothing, got %v", err)
+	}
+}
+
+func TestFindConfigFile(t *testing.T) {
+	vault := t.TempDir()
+	if file := findConfigFile(vault); file != "" {
+		t.Errorf("expected no config file, got %q", file)
+	}
+
+	hidden := filepath.Join(vault, ".pluie.yaml")
+	if err := os.WriteFile(hidden, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if file := findConfigFile(vault); file != hidden {
+		t.Errorf("findConfigFile = %q, want %q", file, hidden)
+	}
+
+	visible := filepath.Join(vault, "pluie.yaml")
+	if err := os.WriteFile(visible, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if file := findConfigFile(vault); file != visible {
+		t.Errorf("findConfigFile = %q, want pluie.yaml first", file)
+	}
+}
+
+func TestConfig_String(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.SiteTitle = "My notes"
+	cfg.AuthToken = "supersecrettoken"
+	cfg.OpenAIAPIKey = "sk-0123456789abcdef"
+
+	out := cfg.String()
+	if strings.Contains(out, "supersecrettoken") || strings.Contains(out, "0123456789abcdef") {
+		t.Errorf("expected the secrets to be redacted, got:\n%s", out)
+	}
+	if !strings.Contains(out, "auth_token: supe********oken") {
+		t.Errorf("expected the redacted token, got:\n%s", out)
+	}
+	if cfg.AuthToken != "supersecrettoken" {
+		t.Error("expected String to leave the config untouched")
+	}
+
+	// The output is a config file giving the same configuration
+	loaded, err := Load(writeConfigFile(t, out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.SiteTitle != "My notes" || loaded.Port != cfg.Port || loaded.FeedSize != cfg.FeedSize || loaded.Mermaid != cfg.Mermaid {
+		t.Errorf("expected the printed config to load back, got:\n%s", loaded.String())
+	}
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/tmc/langchaingo/embeddings"
+	"github.com/tmc/langchaingo/schema"
+	"github.com/tmc/langchaingo/vectorstores"
+)
+
+// EmbeddedStore is the vector store used without Weaviate: the vectors of the notes are kept in
+// memory and searched by brute force, fast enough for the few thousand notes of a vault. They
+// are appended to a file as they are computed, so restarts don't embed the notes again: a JSON
+// header with the embedding model, then one JSON document per note with the vectors of its
+// chunks, the last one of a note replacing the previous ones, or deleting it without chunks.
+type EmbeddedStore struct {
+	embedder embeddings.Embedder
+	file     string
+	model    string
+
+	mu        sync.RWMutex
+	documents map[string]embeddedDocument // By path of the note in the vault, like EmbeddingsTracker
+}
+
+// embeddedStoreHeader starts the file of an EmbeddedStore
+type embeddedStoreHeader struct {
+	Model  string `json:"model"`
+	Format int    `json:"format,omitempty"` // embeddingsFormat of the documents
+}
+
+// embeddedDocument is a note of an EmbeddedStore, without its text: searches only need its slug
+// and the position of its chunks
+type embeddedDocument struct {
+	Path   string          `json:"path"`
+	Slug   string          `json:"slug"`
+	Title  string          `json:"title"`
+	Hash   string          `json:"hash,omitempty"` // computeContentHash of the note embedded
+	Chunks []embeddedChunk `json:"chunks"`
+}
+
+// embeddedChunk is a chunk of a note of an EmbeddedStore, see engine.Chunk
+type embeddedChunk struct {
+	Heading string    `json:"heading,omitempty"`
+	Start   int       `json:"start"`
+	End     int       `json:"end"`
+	Vector  []float32 `json:"vector"` // Of unit length, so the cosine similarity is a dot product
+}
+
+// documentStore is implemented by the vector stores knowing which notes they have, like
+// EmbeddedStore: the notes tracked as embedded but missing from them are embedded again, and
+// the notes they have but not the vault are deleted
+type documentStore interface {
+	HasDocument(path string) bool
+	DocumentPaths() []string
+}
+
+// documentDeleter is implemented by the vector stores able to delete the documents of notes,
+// like EmbeddedStore and WeaviateStore
+type documentDeleter interface {
+	DeleteDocuments(ctx context.Context, paths []string) error
+}
+
+// NewEmbeddedStore returns the store of the vectors of file, empty when there is none yet or
+// when they were computed with another model than model
+func NewEmbeddedStore(embedder embeddings.Embedder, file, model string) (*EmbeddedStore, error) {
+	store := &EmbeddedStore{embedder: embedder, file: file, model: model, documents: make(map[string]embeddedDocument)}
+	if err := store.load(); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// load reads the file of the store, and rewrites it when it has replaced documents, a truncated
+// last document or another model
+func (s *EmbeddedStore) load() error {
+	f, err := os.Open(s.file)
+	if errors.Is(err, os.ErrNotExist) {
+		slog.Info("No embedded vectors file, starting fresh", "file", s.file)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("opening embedded vectors: %w", err)
+	}
+	defer f.Close()
+
+	decoder := json.NewDecoder(f)
+	var header embeddedStoreHeader
+	if err := decoder.Decode(&header); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("reading embedded vectors header: %w", err)
+	}
+	if header.Model != s.model {
+		slog.Warn("Embedding model changed, clearing the embedded vectors", "old_model", header.Model, "new_model", s.model)
+		return s.rewrite()
+	}
+	if header.Format != embeddingsFormat {
+		slog.Info("Embeddings format changed, clearing the embedded vectors", "old_format", header.Format, "new_format", embeddingsFormat)
+		return s.rewrite()
+	}
+
+	records := 0
+	for {
+		var document embeddedDocument
+		if err := decoder.Decode(&document); err != nil {
+			if !errors.Is(err, io.EOF) {
+				slog.Warn("Embedded vectors file is truncated, keeping the documents before", "file", s.file, "error", err)
+				records++ // The file is rewritten without the broken document
+			}
+			break
+		}
+		records++
+		if len(document.Chunks) == 0 {
+			delete(s.documents, document.Path) // Deleted, see DeleteDocuments
+			continue
+		}
+		s.documents[document.Path] = document
+	}
+
+	slog.Info("Loaded embedded vectors", "documents", len(s.documents), "model", s.model)
+	if records > len(s.documents) {
+		return s.rewrite()
+	}
+	return nil
+}
+
+// rewrite replaces the file of the store with its current documents
+func (s *EmbeddedStore) rewrite() error {
+	tmp := s.file + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("creating embedded vectors: %w", err)
+	}
+
+	encoder := json.NewEncoder(f)
+	err = encoder.Encode(embeddedStoreHeader{Model: s.model, Format: embeddingsFormat})
+	for _, document := range s.documents {
+		if err != nil {
+			break
+		}
+		err = encoder.Encode(document)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("writing embedded vectors: %w", err)
+	}
+	if err := os.Rename(tmp, s.file); err != nil {
+		return fmt.Errorf("replacing embedded vectors: %w", err)
+	}
+	return nil
+}
+
+// AddDocuments embeds the documents and appends them to the file of the store. Documents are
+// the chunks of the note at the "path" of their metadata, the returned ids: the documents of a
+// note replace all its previous ones.
+func (s *EmbeddedStore) AddDocuments(ctx context.Context, docs []schema.Document, _ ...vectorstores.Option) ([]string, error) {
+	texts := make([]string, len(docs))
+	for i, doc := range docs {
+		texts[i] = doc.PageContent
+	}
+	vectors, err := s.embedder.EmbedDocuments(ctx, texts)
+	if err != nil {
+		return nil, fmt.Errorf("embedding documents: %w", err)
+	}
+	if len(vectors) != len(docs) {
+		return nil, fmt.Errorf("embedding documents: got %d vectors for %d documents", len(vectors), len(docs))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, encoder, err := s.openAppend()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// Chunks of a note, in the order of the documents
+	var notes []*embeddedDocument
+	byPath := make(map[string]*embeddedDocument)
+	ids := make([]string, len(docs))
+	for i, doc := range docs {
+		path, _ := doc.Metadata["path"].(string)
+		slug, _ := doc.Metadata["slug"].(string)
+		if path == "" {
+			path = slug
+		}
+		ids[i] = path
+
+		document := byPath[path]
+		if document == nil {
+			document = &embeddedDocument{Path: path, Slug: slug}
+			document.Title, _ = doc.Metadata["title"].(string)
+			document.Hash, _ = doc.Metadata["hash"].(string)
+			byPath[path] = document
+			notes = append(notes, document)
+		}
+		chunk := embeddedChunk{Vector: normalizeVector(vectors[i])}
+		chunk.Heading, _ = doc.Metadata["heading"].(string)
+		chunk.Start, _ = doc.Metadata["start"].(int)
+		chunk.End, _ = doc.Metadata["end"].(int)
+		document.Chunks = append(document.Chunks, chunk)
+	}
+
+	for _, document := range notes {
+		if err := encoder.Encode(document); err != nil {
+			return nil, fmt.Errorf("writing embedded vectors: %w", err)
+		}
+		s.documents[document.Path] = *document
+	}
+	return ids, nil
+}
+
+// DeleteDocuments removes the notes at paths from the store, writing them with no chunks so they
+// stay deleted after a restart
+func (s *EmbeddedStore) DeleteDocuments(_ context.Context, paths []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, encoder, err := s.openAppend()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	for _, path := range paths {
+		if _, ok := s.documents[path]; !ok {
+			continue
+		}
+		if err := encoder.Encode(embeddedDocument{Path: path}); err != nil {
+			return fmt.Errorf("writing embedded vectors: %w", err)
+		}
+		delete(s.documents, path)
+	}
+	return nil
+}
+
+// openAppend opens the file of the store to append documents, writing its header if it is new
+func (s *EmbeddedStore) openAppend() (*os.File, *json.Encoder, error) {
+	f, err := os.OpenFile(s.file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, nil, fmt.Errorf("opening embedded vectors: %w", err)
+	}
+
+	encoder := json.NewEncoder(f)
+	if info, err := f.Stat(); err == nil && info.Size() == 0 {
+		if err := encoder.Encode(embeddedStoreHeader{Model: s.model, Format: embeddingsFormat}); err != nil {
+			f.Close()
+			return nil, nil, fmt.Errorf("writing embedded vectors header: %w", err)
+		}
+	}
+	return f, encoder, nil
+}
+
+// SimilaritySearch returns the numDocuments chunks closest to the query, by cosine similarity,
+// with the metadata of their note and their position as metadata, and no text
+func (s *EmbeddedStore) SimilaritySearch(ctx context.Context, query string, numDocuments int, options ...vectorstores.Option) ([]schema.Document, error) {
+	opts := vectorstores.Options{}
+	for _, option := range options {
+		option(&opts)
+	}
+
+	vector, err := s.embedder.EmbedQuery(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("embedding query: %w", err)
+	}
+	vector = normalizeVector(vector)
+
+	s.mu.RLock()
+	results := make([]schema.Document, 0, len(s.documents))
+	for _, document := range s.documents {
+		for _, chunk := range document.Chunks {
+			// Vectors of another model could not be compared
+			if len(chunk.Vector) != len(vector) {
+				continue
+			}
+			score := dotProduct(vector, chunk.Vector)
+			if score < opts.ScoreThreshold {
+				continue
+			}
+			results = append(results, schema.Document{
+				Metadata: map[string]any{
+					"title":   document.Title,
+					"path":    document.Path,
+					"slug":    document.Slug,
+					"hash":    document.Hash,
+					"heading": chunk.Heading,
+					"start":   chunk.Start,
+					"end":     chunk.End,
+				},
+				Score: score,
+			})
+		}
+	}
+	s.mu.RUnlock()
+
+	slices.SortFunc(results, func(a, b schema.Document) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+		if c := strings.Compare(a.Metadata["path"].(string), b.Metadata["path"].(string)); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Metadata["start"].(int), b.Metadata["start"].(int))
+	})
+	if len(results) > numDocuments {
+		results = results[:numDocuments]
+	}
+	return results, nil
+}
+
+// HasDocument reports whether the note at path is in the store
+func (s *EmbeddedStore) HasDocument(path string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.documents[path].Chunks) > 0
+}
+
+// DocumentPaths returns the paths of the notes in the store
+func (s *EmbeddedStore) DocumentPaths() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return slices.Collect(maps.Keys(s.documents))
+}
+
+// normalizeVector returns a copy of vector of unit length, vector itself when it is zero
+func normalizeVector(vector []float32) []float32 {
+	norm := math.Sqrt(float64(dotProduct(vector, vector)))
+	if norm == 0 {
+		return vector
+	}
+	normalized := make([]float32, len(vector))
+	for i, value := range vector {
+		normalized[i] = float32(float64(value) / norm)
+	}
+	return normalized
+}
+
+// dotProduct returns the dot product of two vectors of the same length
+func dotProduct(a, b []float32) float32 {
+	var sum float32
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/EwenQuim/pluie/model"
+	"github.com/tmc/langchaingo/schema"
+	"github.com/tmc/langchaingo/vectorstores"
+)
+
+// wordsEmbedder embeds texts as the number of times they contain each of its words
+type wordsEmbedder struct {
+	words []string
+	calls int
+}
+
+func (e *wordsEmbedder) EmbedDocuments(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i], _ = e.EmbedQuery(ctx, text)
+	}
+	e.calls += len(texts)
+	return vectors, nil
+}
+
+func (e *wordsEmbedder) EmbedQuery(_ context.Context, text string) ([]float32, error) {
+	vector := make([]float32, len(e.words))
+	for i, word := range e.words {
+		vector[i] = float32(strings.Count(strings.ToLower(text), word))
+	}
+	return vector, nil
+}
+
+func embeddedTestDocument(slug, text string) schema.Document {
+	return schema.Document{PageContent: text, Metadata: map[string]any{"title": slug, "path": slug + ".md", "slug": slug}}
+}
+
+func newTestEmbeddedStore(t *testing.T, file, model string) *EmbeddedStore {
+	t.Helper()
+	store, err := NewEmbeddedStore(&wordsEmbedder{words: []string{"tomato", "bread", "boat"}}, file, model)
+	if err != nil {
+		t.Fatalf("NewEmbeddedStore() error: %v", err)
+	}
+	return store
+}
+
+func TestEmbeddedStore_SimilaritySearch(t *testing.T) {
+	store := newTestEmbeddedStore(t, filepath.Join(t.TempDir(), "vectors.jsonl"), "model")
+	_, err := store.AddDocuments(t.Context(), []schema.Document{
+		embeddedTestDocument("garden", "Tomato, tomato and a bit of bread"),
+		embeddedTestDocument("bakery", "Bread, bread, bread"),
+		embeddedTestDocument("harbor", "A boat"),
+	})
+	if err != nil {
+		t.Fatalf("AddDocuments() error: %v", err)
+	}
+
+	docs, err := store.SimilaritySearch(t.Context(), "bread", 2)
+	if err != nil {
+		t.Fatalf("SimilaritySearch() error: %v", err)
+	}
+	if len(docs) != 2 || docs[0].Metadata["slug"] != "bakery" || docs[1].Metadata["slug"] != "garden" {
+		t.Fatalf("SimilaritySearch() = %+v, want bakery then garden", docs)
+	}
+	if docs[0].Score < 0.99 || docs[1].Score >= docs[0].Score {
+		t.Errorf("scores = %v, %v, want the cosine similarities, best first", docs[0].Score, docs[1].Score)
+	}
+
+	docs, err = store.SimilaritySearch(t.Context(), "bread", 10, vectorstores.WithScoreThreshold(0.9))
+	if err != nil || len(docs) != 1 {
+		t.Errorf("SimilaritySearch() with a threshold = %+v, %v, want bakery only", docs, err)
+	}
+}
+
+func TestEmbeddedStore_Persistence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "vectors.jsonl")
+	store := newTestEmbeddedStore(t, file, "model")
+	for _, doc := range []schema.Document{
+		embeddedTestDocument("garden", "Tomato"),
+		embeddedTestDocument("harbor", "A boat"),
+		embeddedTestDocument("garden", "Bread now"),
+	} {
+		if _, err := store.AddDocuments(t.Context(), []schema.Document{doc}); err != nil {
+			t.Fatalf("AddDocuments() error: %v", err)
+		}
+	}
+
+	// A restart finds the vectors, the last ones of a note, without embedding the notes again
+	store = newTestEmbeddedStore(t, file, "model")
+	if !store.HasDocument("garden.md") || !store.HasDocument("harbor.md") || store.HasDocument("bakery.md") {
+		t.Error("the reopened store should have the documents added before")
+	}
+	docs, err := store.SimilaritySearch(t.Context(), "bread", 1)
+	if err != nil || len(docs) != 1 || docs[0].Metadata["slug"] != "garden" || docs[0].Score < 0.99 {
+		t.Errorf("SimilaritySearch() = %+v, %v, want the updated garden", docs, err)
+	}
+	if data, _ := os.ReadFile(file); strings.Count(string(data), "\n") != 3 {
+		t.Errorf("the replaced document should be compacted away, got:\n%s", data)
+	}
+
+	// An interrupted write only loses the document being written
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(`{"path":"bakery.md","vector":[0,1`); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	store = newTestEmbeddedStore(t, file, "model")
+	if !store.HasDocument("garden.md") || !store.HasDocument("harbor.md") || store.HasDocument("bakery.md") {
+		t.Error("a truncated file should keep the documents before the broken one")
+	}
+	if _, err := store.AddDocuments(t.Context(), []schema.Document{embeddedTestDocument("bakery", "Bread")}); err != nil {
+		t.Fatalf("AddDocuments() error: %v", err)
+	}
+	if store = newTestEmbeddedStore(t, file, "model"); !store.HasDocument("bakery.md") {
+		t.Error("documents added after a truncated file should be kept")
+	}
+
+	// Deleted notes stay deleted after a restart
+	if err := store.DeleteDocuments(t.Context(), []string{"harbor.md", "unknown.md"}); err != nil {
+		t.Fatalf("DeleteDocuments() error: %v", err)
+	}
+	if store = newTestEmbeddedStore(t, file, "model"); store.HasDocument("harbor.md") || !store.HasDocument("garden.md") {
+		t.Error("a deleted document should not come back after a restart")
+	}
+
+	// Vectors of another model mean nothing
+	if store = newTestEmbeddedStore(t, file, "other-model"); store.HasDocument("garden.md") {
+		t.Error("changing the embedding model should clear the store")
+	}
+}
+
+func TestEmbedNotesWithProgress_EmbedsMissingDocuments(t *testing.T) {
+	dir := t.TempDir()
+	trackingFile := filepath.Join(dir, "tracking.json")
+	notes := queueNotes("a", "b")
+
+	store := newTestEmbeddedStore(t, filepath.Join(dir, "vectors.jsonl"), "model")
+	manager := NewEmbeddingsManager(t.Context(), store, NewEmbeddingProgress(), nil, trackingFile, "model", nil)
+	if err := manager.embedNotesWithProgress(t.Context(), store, notes, manager.progress); err != nil {
+		t.Fatalf("first pass error: %v", err)
+	}
+
+	// The notes are tracked as embedded, but the new file of the store doesn't have them
+	store = newTestEmbeddedStore(t, filepath.Join(dir, "other-vectors.jsonl"), "model")
+	manager = NewEmbeddingsManager(t.Context(), store, NewEmbeddingProgress(), nil, trackingFile, "model", nil)
+	if err := manager.embedNotesWithProgress(t.Context(), store, notes, manager.progress); err != nil {
+		t.Fatalf("second pass error: %v", err)
+	}
+	if calls := store.embedder.(*wordsEmbedder).calls; calls != 2 {
+		t.Errorf("second pass embedded %d notes, want the 2 missing from the store", calls)
+	}
+}
+
+func TestEmbeddedStore_ReplacesChunksOfNote(t *testing.T) {
+	store := newTestEmbeddedStore(t, filepath.Join(t.TempDir(), "vectors.jsonl"), "model")
+	note := model.Note{Title: "Kitchen", Slug: "kitchen", Path: "kitchen.md", Content: "# Tomatoes\nTomato salad.\n\n# Bread\nBread and more bread."}
+	if _, err := store.AddDocuments(t.Context(), noteChunkDocuments(note)); err != nil {
+		t.Fatalf("AddDocuments() error: %v", err)
+	}
+
+	docs, err := store.SimilaritySearch(t.Context(), "bread", 1)
+	if err != nil || len(docs) != 1 {
+		t.Fatalf("SimilaritySearch() = %+v, %v, want the bread chunk", docs, err)
+	}
+	start, end := docs[0].Metadata["start"].(int), docs[0].Metadata["end"].(int)
+	if docs[0].Metadata["heading"] != "Bread" || note.Content[start:end] != "Bread and more bread." {
+		t.Errorf("chunk metadata = %+v, want the heading and offsets of the bread section", docs[0].Metadata)
+	}
+
+	// The edited note replaces all the chunks of its previous version
+	note.Content = "# Boats\nA boat."
+	if _, err := store.AddDocuments(t.Context(), noteChunkDocuments(note)); err != nil {
+		t.Fatalf("AddDocuments() error: %v", err)
+	}
+	docs, err = store.SimilaritySearch(t.Context(), "bread", 10)
+	if err != nil || len(docs) != 1 || docs[0].Metadata["heading"] != "Boats" || docs[0].Metadata["hash"] != computeContentHash(note) {
+		t.Errorf("SimilaritySearch() = %+v, %v, want only the chunk of the edited note", docs, err)
+	}
+}
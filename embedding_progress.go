@@ -4,11 +4,16 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/EwenQuim/pluie/engine"
 	"github.com/EwenQuim/pluie/model"
 	"github.com/tmc/langchaingo/schema"
 )
@@ -17,8 +22,11 @@ import (
 type EmbeddingProgress struct {
 	mu            sync.RWMutex
 	TotalNotes    int
-	EmbeddedNotes int
+	EmbeddedNotes int // Notes embedded by the current pass
+	SkippedNotes  int // Notes already embedded with their current content, skipped by the pass
 	IsEmbedding   bool
+	IsPaused      bool
+	Rate          float64 // Notes embedded during the last minute
 	CurrentNote   string
 	LastUpdated   time.Time
 	subscribers   []chan EmbeddingStatus
@@ -29,7 +37,10 @@ type EmbeddingProgress struct {
 type EmbeddingStatus struct {
 	TotalNotes    int       `json:"total_notes"`
 	EmbeddedNotes int       `json:"embedded_notes"`
+	SkippedNotes  int       `json:"skipped_notes"`
 	IsEmbedding   bool      `json:"is_embedding"`
+	IsPaused      bool      `json:"is_paused"`
+	Rate          float64   `json:"rate_per_minute"`
 	CurrentNote   string    `json:"current_note,omitempty"`
 	LastUpdated   time.Time `json:"last_updated"`
 }
@@ -50,23 +61,48 @@ func (ep *EmbeddingProgress) GetStatus() EmbeddingStatus {
 	return EmbeddingStatus{
 		TotalNotes:    ep.TotalNotes,
 		EmbeddedNotes: ep.EmbeddedNotes,
+		SkippedNotes:  ep.SkippedNotes,
 		IsEmbedding:   ep.IsEmbedding,
+		IsPaused:      ep.IsPaused,
+		Rate:          ep.Rate,
 		CurrentNote:   ep.CurrentNote,
 		LastUpdated:   ep.LastUpdated,
 	}
 }
 
-// UpdateProgress updates the embedding progress and notifies subscribers
-func (ep *EmbeddingProgress) UpdateProgress(embedded, total int, currentNote string, isEmbedding bool) {
+// UpdateProgress updates the embedding progress and notifies subscribers. Notes skipped because
+// they were already embedded are counted apart from the notes embedded by the pass.
+func (ep *EmbeddingProgress) UpdateProgress(embedded, skipped, total int, currentNote string, isEmbedding bool) {
 	ep.mu.Lock()
 	ep.TotalNotes = total
 	ep.EmbeddedNotes = embedded
+	ep.SkippedNotes = skipped
 	ep.CurrentNote = currentNote
 	ep.IsEmbedding = isEmbedding
 	ep.LastUpdated = time.Now()
 	ep.mu.Unlock()
 
-	// Notify all subscribers
+	embeddingNotesTotal.Set(float64(total))
+	embeddingNotesEmbedded.Set(float64(embedded))
+	embeddingNotesSkipped.Set(float64(skipped))
+	embeddingInProgress.Set(boolToFloat(isEmbedding))
+
+	ep.notify()
+}
+
+// UpdatePace updates the paused flag and the current rate, and notifies subscribers
+func (ep *EmbeddingProgress) UpdatePace(paused bool, rate float64) {
+	ep.mu.Lock()
+	ep.IsPaused = paused
+	ep.Rate = rate
+	ep.LastUpdated = time.Now()
+	ep.mu.Unlock()
+
+	ep.notify()
+}
+
+// notify sends the current status to all subscribers
+func (ep *EmbeddingProgress) notify() {
 	status := ep.GetStatus()
 	ep.subscribersMu.Lock()
 	for _, ch := range ep.subscribers {
@@ -101,8 +137,16 @@ func (ep *EmbeddingProgress) Unsubscribe(ch chan EmbeddingStatus) {
 	}
 }
 
-// embedNotesWithProgress embeds notes into a vector store with progress tracking
+// embeddingsCheckpointInterval is the number of notes embedded between two saves of the tracking file
+const embeddingsCheckpointInterval = 20
+
+// embedNotesWithProgress embeds notes into a vector store with progress tracking.
+// The tracking file is saved as the pass goes, so an interrupted pass resumes where it stopped.
+// Notes embedded with their current content are skipped, and the vectors of the notes gone
+// from the vault are deleted from stores supporting it.
 func (em *EmbeddingsManager) embedNotesWithProgress(ctx context.Context, store VectorStore, notes []model.Note, progress *EmbeddingProgress) error {
+	em.passMu.Lock()
+	defer em.passMu.Unlock()
 	start := time.Now()
 
 	// Load tracking file
@@ -111,91 +155,184 @@ func (em *EmbeddingsManager) embedNotesWithProgress(ctx context.Context, store V
 		return fmt.Errorf("loading embeddings tracker: %w", err)
 	}
 
-	// Filter notes that need embedding
+	// Filter notes that need embedding, drafts shown by the server and private notes never are.
+	// Notes missing from a store knowing its documents are embedded again, like after its file
+	// was deleted.
+	known, _ := store.(documentStore)
 	var notesToEmbed []model.Note
+	current := make(map[string]bool, len(notes))
 	for _, note := range notes {
-		if tracker.needsEmbedding(note) {
+		if note.IsDraft || !engine.IsVisible(note, em.publicByDefault) {
+			continue
+		}
+		current[note.Path] = true
+		if tracker.needsEmbedding(note) || (known != nil && !known.HasDocument(note.Path)) {
 			notesToEmbed = append(notesToEmbed, note)
 		}
 	}
+	removed := em.deleteRemovedNotes(ctx, store, tracker, current)
+
+	// Same order on every pass: a restarted pass continues with the first note not tracked yet
+	slices.SortFunc(notesToEmbed, func(a, b model.Note) int { return strings.Compare(a.Slug, b.Slug) })
 
 	totalNotes := len(notes)
-	alreadyEmbedded := len(tracker.Files)
+	skipped := totalNotes - len(notesToEmbed)
 
 	if len(notesToEmbed) == 0 {
 		slog.Info("No new notes to embed, all notes are up to date",
 			"total_notes", totalNotes,
-			"tracked_notes", alreadyEmbedded)
-		progress.UpdateProgress(alreadyEmbedded, totalNotes, "", false)
+			"tracked_notes", skipped)
+		progress.UpdateProgress(0, skipped, totalNotes, "", false)
+		if removed {
+			return tracker.save(em.embeddingsTrackingFile)
+		}
 		return nil
 	}
 
 	slog.Info("Notes embedding status",
 		"total_notes", totalNotes,
-		"already_embedded", alreadyEmbedded,
+		"skipped_cached", skipped,
 		"to_embed", len(notesToEmbed))
 
 	// Mark as embedding in progress
-	progress.UpdateProgress(alreadyEmbedded, totalNotes, "", true)
+	progress.UpdateProgress(0, skipped, totalNotes, "", true)
 
-	// Add documents to Weaviate one at a time to show real progress
+	// Add documents to the vector store one at a time to show real progress
 	slog.Info("Starting embedding process", "documents", len(notesToEmbed))
 
-	for i, note := range notesToEmbed {
+	var embedded atomic.Int64
+	embed := func(ctx context.Context, note model.Note) error {
 		docStart := time.Now()
 
 		// Update progress with current note
-		progress.UpdateProgress(alreadyEmbedded+i, totalNotes, note.Title, true)
+		progress.UpdateProgress(int(embedded.Load()), skipped, totalNotes, note.Title, true)
 
-		// Combine title and content for better semantic search
-		content := fmt.Sprintf("# %s\n\n%s", note.Title, note.Content)
-		doc := schema.Document{
-			PageContent: content,
-			Metadata: map[string]any{
-				"title": note.Title,
-				"path":  note.Path,
-				"slug":  note.Slug,
-			},
-		}
-
-		_, err = store.AddDocuments(ctx, []schema.Document{doc})
-		if err != nil {
-			progress.UpdateProgress(alreadyEmbedded+i, totalNotes, "", false)
-			return fmt.Errorf("adding document to vector store (title=%s, path=%s): %w", note.Title, note.Path, err)
+		// One document per chunk, all replacing the previous chunks of the note
+		docs := noteChunkDocuments(note)
+		if _, err := store.AddDocuments(ctx, docs); err != nil {
+			return fmt.Errorf("adding documents to vector store (title=%s, path=%s): %w", note.Title, note.Path, err)
 		}
 
 		slog.Info("Document embedded successfully",
-			"document", i+1,
-			"total", len(notesToEmbed),
 			"title", note.Title,
+			"chunks", len(docs),
+			"total", len(notesToEmbed),
 			"duration", time.Since(docStart))
+		return nil
 	}
 
-	// Update tracking file
-	for _, note := range notesToEmbed {
-		// Get file modification time
-		info, err := os.Stat(filepath.Join(".", note.Path))
-		var modTime time.Time
-		if err == nil {
-			modTime = info.ModTime()
-		} else {
-			modTime = time.Now()
+	done := func(note model.Note) {
+		tracker.markAsEmbedded(note, noteModTime(note))
+		count := embedded.Add(1)
+		progress.UpdateProgress(int(count), skipped, totalNotes, note.Title, true)
+		progress.UpdatePace(em.queue.IsPaused(), em.queue.Rate())
+
+		// Checkpoint regularly, and as soon as the pass is paused
+		if count%embeddingsCheckpointInterval == 0 || em.queue.IsPaused() {
+			if err := tracker.save(em.embeddingsTrackingFile); err != nil {
+				slog.Warn("Failed to save embeddings checkpoint", "error", err)
+			}
 		}
-		tracker.markAsEmbedded(note, modTime)
 	}
 
-	// Save tracker
-	if err := tracker.save(em.embeddingsTrackingFile); err != nil {
-		progress.UpdateProgress(alreadyEmbedded+len(notesToEmbed), totalNotes, "", false)
-		return fmt.Errorf("saving tracker: %w", err)
+	runErr := em.queue.Run(ctx, notesToEmbed, embed, done)
+
+	// Save what was embedded, even if the pass failed or was cancelled
+	saveErr := tracker.save(em.embeddingsTrackingFile)
+	progress.UpdateProgress(int(embedded.Load()), skipped, totalNotes, "", false)
+	progress.UpdatePace(em.queue.IsPaused(), 0)
+
+	if runErr != nil {
+		return runErr
+	}
+	if saveErr != nil {
+		return fmt.Errorf("saving tracker: %w", saveErr)
 	}
 
 	slog.Info("Embedding completed",
 		"embedded_notes", len(notesToEmbed),
 		"duration", time.Since(start))
 
-	// Mark as complete
-	progress.UpdateProgress(alreadyEmbedded+len(notesToEmbed), totalNotes, "", false)
-
 	return nil
 }
+
+// deleteRemovedNotes deletes the vectors of the notes tracked or stored but not in current, the
+// paths of the notes to embed: notes removed from the vault, made private or turned into drafts.
+// They stay tracked when the store fails to delete them, to try again on the next pass. Returns
+// whether the tracker changed.
+func (em *EmbeddingsManager) deleteRemovedNotes(ctx context.Context, store VectorStore, tracker *EmbeddingsTracker, current map[string]bool) bool {
+	removed := make(map[string]bool)
+	for path := range tracker.Files {
+		if !current[path] {
+			removed[path] = true
+		}
+	}
+	if known, ok := store.(documentStore); ok {
+		for _, path := range known.DocumentPaths() {
+			if !current[path] {
+				removed[path] = true
+			}
+		}
+	}
+	if len(removed) == 0 {
+		return false
+	}
+
+	paths := slices.Sorted(maps.Keys(removed))
+	if deleter, ok := store.(documentDeleter); ok {
+		if err := deleter.DeleteDocuments(ctx, paths); err != nil {
+			slog.Warn("Failed to delete the vectors of removed notes", "notes", len(paths), "error", err)
+			return false
+		}
+	}
+	for _, path := range paths {
+		delete(tracker.Files, path)
+	}
+	slog.Info("Deleted the vectors of removed notes", "notes", len(paths))
+	return true
+}
+
+// noteChunkDocuments returns the documents of the chunks of a note, see engine.ChunkContent,
+// a single one with its title when it has no text. The title and the heading of a chunk are
+// embedded with its text for better semantic search, and the hash of the note lets searches
+// ignore the chunks of its previous versions.
+func noteChunkDocuments(note model.Note) []schema.Document {
+	chunks := engine.ChunkContent(note.Content)
+	if len(chunks) == 0 {
+		chunks = []engine.Chunk{{}}
+	}
+
+	hash := computeContentHash(note)
+	docs := make([]schema.Document, len(chunks))
+	for i, chunk := range chunks {
+		content := "# " + note.Title
+		if chunk.Heading != "" {
+			content += "\n\n## " + chunk.Heading
+		}
+		if chunk.Text != "" {
+			content += "\n\n" + chunk.Text
+		}
+		docs[i] = schema.Document{
+			PageContent: content,
+			Metadata: map[string]any{
+				"title":   note.Title,
+				"path":    note.Path,
+				"slug":    note.Slug,
+				"heading": chunk.Heading,
+				"start":   chunk.Start,
+				"end":     chunk.End,
+				"hash":    hash,
+			},
+		}
+	}
+	return docs
+}
+
+// noteModTime returns the modification time of the note file, now if it can't be read
+func noteModTime(note model.Note) time.Time {
+	info, err := os.Stat(filepath.Join(".", note.Path))
+	if err != nil {
+		return time.Now()
+	}
+	return info.ModTime()
+}
@@ -8,7 +8,7 @@ import (
 
 func TestEmbeddingProgressGetStatus(t *testing.T) {
 	ep := NewEmbeddingProgress()
-	ep.UpdateProgress(5, 10, "test_note", true)
+	ep.UpdateProgress(5, 0, 10, "test_note", true)
 
 	status := ep.GetStatus()
 	if status.EmbeddedNotes != 5 {
@@ -25,11 +25,31 @@ func TestEmbeddingProgressGetStatus(t *testing.T) {
 	}
 }
 
+func TestEmbeddingProgressUpdatePace(t *testing.T) {
+	ep := NewEmbeddingProgress()
+	ep.UpdateProgress(5, 0, 10, "test_note", true)
+	ch := ep.Subscribe()
+
+	ep.UpdatePace(true, 12)
+
+	select {
+	case status := <-ch:
+		if !status.IsPaused || status.Rate != 12 {
+			t.Errorf("status = %+v, want paused at 12 notes per minute", status)
+		}
+		if status.EmbeddedNotes != 5 || !status.IsEmbedding {
+			t.Errorf("UpdatePace should keep the progress, got %+v", status)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for update")
+	}
+}
+
 func TestEmbeddingProgressSubscribeReceivesUpdates(t *testing.T) {
 	ep := NewEmbeddingProgress()
 	ch := ep.Subscribe()
 
-	ep.UpdateProgress(1, 10, "note1", true)
+	ep.UpdateProgress(1, 0, 10, "note1", true)
 
 	select {
 	case status := <-ch:
@@ -46,7 +66,7 @@ func TestEmbeddingProgressUnsubscribeStopsUpdates(t *testing.T) {
 	ch := ep.Subscribe()
 	ep.Unsubscribe(ch)
 
-	ep.UpdateProgress(1, 10, "note1", true)
+	ep.UpdateProgress(1, 0, 10, "note1", true)
 
 	// Channel should not receive updates after unsubscribe
 	select {
@@ -75,7 +95,7 @@ func TestEmbeddingProgressConcurrentAccess(t *testing.T) {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			ep.UpdateProgress(i, goroutines, "note", true)
+			ep.UpdateProgress(i, 0, goroutines, "note", true)
 		}()
 	}
 
@@ -105,7 +125,7 @@ func TestEmbeddingProgressMultipleSubscribers(t *testing.T) {
 	ch1 := ep.Subscribe()
 	ch2 := ep.Subscribe()
 
-	ep.UpdateProgress(3, 5, "note3", true)
+	ep.UpdateProgress(3, 0, 5, "note3", true)
 
 	// Both subscribers should receive the update
 	for i, ch := range []chan EmbeddingStatus{ch1, ch2} {
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/EwenQuim/pluie/model"
+)
+
+// Embedding queue states, see EmbeddingQueue.State
+const (
+	queueIdle    = "idle"    // No pass running
+	queueRunning = "running" // A pass is embedding notes
+	queuePaused  = "paused"  // No new note starts until Resume, running or not
+)
+
+// EmbeddingQueue runs embedding passes: notes are embedded in order, throttled by a rate limit
+// and a concurrency, and the pass can be paused at note boundaries or cancelled with its context.
+type EmbeddingQueue struct {
+	mu          sync.Mutex
+	running     bool
+	paused      bool
+	resumed     chan struct{} // Closed while not paused, waited on by the pass when paused
+	interval    time.Duration // Minimum delay between the start of two notes, 0 for no limit
+	concurrency int
+	completions []time.Time // Notes embedded during the last minute, for Rate
+}
+
+// NewEmbeddingQueue creates a queue embedding at most ratePerMinute notes per minute (0 for no limit),
+// with up to concurrency notes at the same time
+func NewEmbeddingQueue(ratePerMinute, concurrency int) *EmbeddingQueue {
+	resumed := make(chan struct{})
+	close(resumed)
+
+	var interval time.Duration
+	if ratePerMinute > 0 {
+		interval = time.Minute / time.Duration(ratePerMinute)
+	}
+
+	return &EmbeddingQueue{
+		resumed:     resumed,
+		interval:    interval,
+		concurrency: max(concurrency, 1),
+	}
+}
+
+// Pause stops the pass before its next note. Notes being embedded finish.
+// Returns false if the queue was already paused.
+func (q *EmbeddingQueue) Pause() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.paused {
+		return false
+	}
+	q.paused = true
+	q.resumed = make(chan struct{})
+	return true
+}
+
+// Resume continues the pass with the note it stopped before.
+// Returns false if the queue was not paused.
+func (q *EmbeddingQueue) Resume() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if !q.paused {
+		return false
+	}
+	q.paused = false
+	close(q.resumed)
+	return true
+}
+
+// State returns queueIdle, queueRunning or queuePaused
+func (q *EmbeddingQueue) State() string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	switch {
+	case q.paused:
+		return queuePaused
+	case q.running:
+		return queueRunning
+	}
+	return queueIdle
+}
+
+// IsPaused reports whether the queue is paused
+func (q *EmbeddingQueue) IsPaused() bool {
+	return q.State() == queuePaused
+}
+
+// Rate returns the notes embedded during the last minute
+func (q *EmbeddingQueue) Rate() float64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.pruneCompletions(time.Now())
+	return float64(len(q.completions))
+}
+
+// Run embeds the notes in order with embed, calling done after each success.
+// done calls are never concurrent. The first embed error cancels the pass and is returned,
+// as is the context error when the pass is cancelled.
+func (q *EmbeddingQueue) Run(ctx context.Context, notes []model.Note, embed func(context.Context, model.Note) error, done func(model.Note)) error {
+	q.mu.Lock()
+	q.running = true
+	q.completions = nil
+	q.mu.Unlock()
+	defer func() {
+		q.mu.Lock()
+		q.running = false
+		q.mu.Unlock()
+	}()
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	slots := make(chan struct{}, q.concurrency)
+	var wg sync.WaitGroup
+	var doneMu sync.Mutex
+	var nextStart time.Time
+
+	for _, note := range notes {
+		// Note boundary: wait for the rate limit and a free slot, then for a resume
+		if err := sleepContext(ctx, time.Until(nextStart)); err != nil {
+			break
+		}
+		if err := acquire(ctx, slots); err != nil {
+			break
+		}
+		if err := q.waitResumed(ctx); err != nil {
+			<-slots
+			break
+		}
+		if q.interval > 0 {
+			nextStart = time.Now().Add(q.interval)
+		}
+
+		wg.Go(func() {
+			defer func() { <-slots }()
+			if err := embed(ctx, note); err != nil {
+				cancel(err)
+				return
+			}
+			q.recordCompletion()
+
+			doneMu.Lock()
+			defer doneMu.Unlock()
+			done(note)
+		})
+	}
+	wg.Wait()
+
+	return context.Cause(ctx)
+}
+
+// waitResumed blocks while the queue is paused
+func (q *EmbeddingQueue) waitResumed(ctx context.Context) error {
+	q.mu.Lock()
+	resumed := q.resumed
+	q.mu.Unlock()
+
+	select {
+	case <-resumed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// recordCompletion counts an embedded note for Rate
+func (q *EmbeddingQueue) recordCompletion() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+	q.pruneCompletions(now)
+	q.completions = append(q.completions, now)
+}
+
+// pruneCompletions forgets completions older than a minute. Must be called with mu held.
+func (q *EmbeddingQueue) pruneCompletions(now time.Time) {
+	i := 0
+	for i < len(q.completions) && now.Sub(q.completions[i]) > time.Minute {
+		i++
+	}
+	q.completions = q.completions[i:]
+}
+
+// acquire takes a slot of the semaphore
+func acquire(ctx context.Context, slots chan struct{}) error {
+	select {
+	case slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// sleepContext waits for d, or less if ctx is cancelled
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
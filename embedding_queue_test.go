@@ -0,0 +1,320 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/EwenQuim/pluie/model"
+	"github.com/tmc/langchaingo/schema"
+	"github.com/tmc/langchaingo/vectorstores"
+)
+
+// fakeEmbedder records the slugs it is asked to embed and can block or fail on chosen slugs
+type fakeEmbedder struct {
+	mu      sync.Mutex
+	calls   []string
+	onEmbed func(slug string) // Called before recording the call, if set
+	failOn  string
+}
+
+func (f *fakeEmbedder) embed(ctx context.Context, note model.Note) error {
+	if f.onEmbed != nil {
+		f.onEmbed(note.Slug)
+	}
+	f.mu.Lock()
+	f.calls = append(f.calls, note.Slug)
+	f.mu.Unlock()
+	if note.Slug == f.failOn {
+		return errors.New("embedding failed")
+	}
+	return ctx.Err()
+}
+
+func (f *fakeEmbedder) Calls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.calls)
+}
+
+// AddDocuments makes fakeEmbedder a VectorStore
+func (f *fakeEmbedder) AddDocuments(ctx context.Context, docs []schema.Document, _ ...vectorstores.Option) ([]string, error) {
+	for _, doc := range docs {
+		if err := f.embed(ctx, model.Note{Slug: doc.Metadata["slug"].(string)}); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeEmbedder) SimilaritySearch(context.Context, string, int, ...vectorstores.Option) ([]schema.Document, error) {
+	return nil, nil
+}
+
+func queueNotes(slugs ...string) []model.Note {
+	notes := make([]model.Note, 0, len(slugs))
+	for _, slug := range slugs {
+		notes = append(notes, model.Note{Slug: slug, Path: slug + ".md", Title: slug, Content: "Content of " + slug, IsPublic: true})
+	}
+	return notes
+}
+
+// waitFor polls condition until it is true or fails the test
+func waitFor(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestEmbeddingQueue_States(t *testing.T) {
+	q := NewEmbeddingQueue(0, 1)
+	if q.State() != queueIdle {
+		t.Fatalf("new queue state = %q, want idle", q.State())
+	}
+
+	if !q.Pause() || q.Pause() {
+		t.Error("Pause() should only report a change the first time")
+	}
+	if q.State() != queuePaused {
+		t.Errorf("state = %q, want paused", q.State())
+	}
+	if !q.Resume() || q.Resume() {
+		t.Error("Resume() should only report a change the first time")
+	}
+
+	release := make(chan struct{})
+	embedder := &fakeEmbedder{onEmbed: func(string) { <-release }}
+	result := make(chan error)
+	go func() {
+		result <- q.Run(t.Context(), queueNotes("a"), embedder.embed, func(model.Note) {})
+	}()
+
+	waitFor(t, "the running state", func() bool { return q.State() == queueRunning })
+	close(release)
+	if err := <-result; err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+	if q.State() != queueIdle {
+		t.Errorf("state after the pass = %q, want idle", q.State())
+	}
+}
+
+func TestEmbeddingQueue_PauseAndResume(t *testing.T) {
+	q := NewEmbeddingQueue(0, 1)
+	embedder := &fakeEmbedder{}
+	// Pause while "b" is being embedded: "b" finishes, "c" must not start
+	embedder.onEmbed = func(slug string) {
+		if slug == "b" {
+			q.Pause()
+		}
+	}
+
+	var mu sync.Mutex
+	var done []string
+	result := make(chan error)
+	go func() {
+		result <- q.Run(t.Context(), queueNotes("a", "b", "c", "d"), embedder.embed, func(note model.Note) {
+			mu.Lock()
+			done = append(done, note.Slug)
+			mu.Unlock()
+		})
+	}()
+
+	waitFor(t, "b to be embedded", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(done) == 2
+	})
+
+	// Paused: no request is issued anymore
+	time.Sleep(50 * time.Millisecond)
+	if calls := embedder.Calls(); !slices.Equal(calls, []string{"a", "b"}) {
+		t.Fatalf("calls while paused = %v, want [a b]", calls)
+	}
+	if q.State() != queuePaused {
+		t.Errorf("state = %q, want paused", q.State())
+	}
+
+	// Resume continues with the note it stopped before
+	q.Resume()
+	if err := <-result; err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+	if calls := embedder.Calls(); !slices.Equal(calls, []string{"a", "b", "c", "d"}) {
+		t.Errorf("calls = %v, want [a b c d]", calls)
+	}
+	if !slices.Equal(done, []string{"a", "b", "c", "d"}) {
+		t.Errorf("done = %v, want [a b c d]", done)
+	}
+}
+
+func TestEmbeddingQueue_Cancel(t *testing.T) {
+	q := NewEmbeddingQueue(0, 1)
+	q.Pause()
+
+	ctx, cancel := context.WithCancel(t.Context())
+	result := make(chan error)
+	embedder := &fakeEmbedder{}
+	go func() {
+		result <- q.Run(ctx, queueNotes("a", "b"), embedder.embed, func(model.Note) {})
+	}()
+
+	// A paused pass is still cancellable
+	waitFor(t, "the pass to start", func() bool {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		return q.running
+	})
+	cancel()
+	if err := <-result; !errors.Is(err, context.Canceled) {
+		t.Errorf("Run() error = %v, want context.Canceled", err)
+	}
+	if len(embedder.Calls()) != 0 {
+		t.Errorf("no note should be embedded, got %v", embedder.Calls())
+	}
+}
+
+func TestEmbeddingQueue_Error(t *testing.T) {
+	q := NewEmbeddingQueue(0, 1)
+	embedder := &fakeEmbedder{failOn: "b"}
+
+	var done []string
+	err := q.Run(t.Context(), queueNotes("a", "b", "c"), embedder.embed, func(note model.Note) { done = append(done, note.Slug) })
+	if err == nil || err.Error() != "embedding failed" {
+		t.Fatalf("Run() error = %v, want the embedder error", err)
+	}
+	if calls := embedder.Calls(); !slices.Equal(calls, []string{"a", "b"}) {
+		t.Errorf("calls = %v, the pass should stop at the first error", calls)
+	}
+	if !slices.Equal(done, []string{"a"}) {
+		t.Errorf("done = %v, want [a]", done)
+	}
+}
+
+func TestEmbeddingQueue_RateLimit(t *testing.T) {
+	// 1200 notes per minute: one every 50ms
+	q := NewEmbeddingQueue(1200, 1)
+	embedder := &fakeEmbedder{}
+
+	start := time.Now()
+	if err := q.Run(t.Context(), queueNotes("a", "b", "c"), embedder.embed, func(model.Note) {}); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("3 notes took %v, the rate limit should space them by 50ms", elapsed)
+	}
+	if rate := q.Rate(); rate != 3 {
+		t.Errorf("Rate() = %v, want 3 notes during the last minute", rate)
+	}
+}
+
+func TestEmbeddingQueue_Concurrency(t *testing.T) {
+	q := NewEmbeddingQueue(0, 2)
+	release := make(chan struct{})
+	started := make(chan string, 3)
+	embedder := &fakeEmbedder{onEmbed: func(slug string) {
+		started <- slug
+		<-release
+	}}
+
+	result := make(chan error)
+	go func() {
+		result <- q.Run(t.Context(), queueNotes("a", "b", "c"), embedder.embed, func(model.Note) {})
+	}()
+
+	// Two notes are embedded at the same time, the third waits for a free slot
+	first, second := <-started, <-started
+	if first == second {
+		t.Fatalf("expected two different notes, got %q twice", first)
+	}
+	select {
+	case slug := <-started:
+		t.Fatalf("%q started while both slots were busy", slug)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	if err := <-result; err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+	if len(embedder.Calls()) != 3 {
+		t.Errorf("calls = %v, want 3 notes", embedder.Calls())
+	}
+}
+
+func TestEmbedNotesWithProgress_ResumesAfterRestart(t *testing.T) {
+	trackingFile := t.TempDir() + "/tracking.json"
+	notes := queueNotes("d", "b", "a", "c")
+
+	// First pass stops on "c", like a shutdown in the middle of the pass
+	embedder := &fakeEmbedder{failOn: "c"}
+	manager := NewEmbeddingsManager(t.Context(), embedder, NewEmbeddingProgress(), nil, trackingFile, "model", nil)
+	if err := manager.embedNotesWithProgress(t.Context(), embedder, notes, manager.progress); err == nil {
+		t.Fatal("expected the first pass to fail")
+	}
+	if calls := embedder.Calls(); !slices.Equal(calls, []string{"a", "b", "c"}) {
+		t.Fatalf("first pass calls = %v, notes should be embedded by slug", calls)
+	}
+
+	// After a restart, the pass continues from "c"
+	embedder = &fakeEmbedder{}
+	manager = NewEmbeddingsManager(t.Context(), embedder, NewEmbeddingProgress(), nil, trackingFile, "model", nil)
+	if err := manager.embedNotesWithProgress(t.Context(), embedder, notes, manager.progress); err != nil {
+		t.Fatalf("second pass error: %v", err)
+	}
+	if calls := embedder.Calls(); !slices.Equal(calls, []string{"c", "d"}) {
+		t.Errorf("second pass calls = %v, want [c d]", calls)
+	}
+
+	status := manager.progress.GetStatus()
+	if status.IsEmbedding || status.IsPaused || status.EmbeddedNotes != 2 || status.SkippedNotes != 2 {
+		t.Errorf("unexpected final status %+v", status)
+	}
+}
+
+func TestEmbeddingsManager_PauseCheckpoints(t *testing.T) {
+	trackingFile := t.TempDir() + "/tracking.json"
+	embedder := &fakeEmbedder{}
+	manager := NewEmbeddingsManager(t.Context(), embedder, NewEmbeddingProgress(), nil, trackingFile, "model", nil)
+	embedder.onEmbed = func(slug string) {
+		if slug == "a" {
+			manager.Pause()
+		}
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	result := make(chan error)
+	go func() {
+		result <- manager.embedNotesWithProgress(ctx, embedder, queueNotes("a", "b"), manager.progress)
+	}()
+
+	waitFor(t, "the paused status", func() bool {
+		status := manager.progress.GetStatus()
+		return status.IsPaused && status.EmbeddedNotes == 1
+	})
+
+	// The pause saved the tracking file: "a" would not be embedded again
+	tracker, err := loadEmbeddingsTracker(trackingFile, "model")
+	if err != nil {
+		t.Fatalf("loading tracker: %v", err)
+	}
+	if _, ok := tracker.Files["a.md"]; !ok || len(tracker.Files) != 1 {
+		t.Errorf("tracked files = %v, want only a.md", tracker.Files)
+	}
+
+	cancel()
+	if err := <-result; !errors.Is(err, context.Canceled) {
+		t.Errorf("embedNotesWithProgress() error = %v, want context.Canceled", err)
+	}
+	if calls := embedder.Calls(); !slices.Equal(calls, []string{"a"}) {
+		t.Errorf("calls = %v, want [a]", calls)
+	}
+}
@@ -5,10 +5,15 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"maps"
 	"os"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/EwenQuim/pluie/engine"
@@ -27,10 +32,15 @@ type EmbeddedFile struct {
 
 // EmbeddingsTracker manages the tracking of embedded files
 type EmbeddingsTracker struct {
-	Model string                  `json:"model,omitempty"`
-	Files map[string]EmbeddedFile `json:"files"`
+	Model  string                  `json:"model,omitempty"`
+	Format int                     `json:"format,omitempty"` // embeddingsFormat of the embedded files
+	Files  map[string]EmbeddedFile `json:"files"`
 }
 
+// embeddingsFormat is the version of the documents embedded for a note: 1 for one document per
+// chunk, see noteChunkDocuments, 0 for a single document with the whole note
+const embeddingsFormat = 1
+
 // loadEmbeddingsTracker loads the tracking file or creates a new one.
 // If the configured model differs from the stored model, existing embeddings are cleared.
 func loadEmbeddingsTracker(embeddingsTrackingFile string, currentModel string) (*EmbeddingsTracker, error) {
@@ -43,6 +53,7 @@ func loadEmbeddingsTracker(embeddingsTrackingFile string, currentModel string) (
 	if err != nil {
 		if os.IsNotExist(err) {
 			slog.Info("No existing embeddings tracking file, starting fresh")
+			tracker.Format = embeddingsFormat
 			return tracker, nil
 		}
 		return nil, fmt.Errorf("reading tracking file: %w", err)
@@ -59,7 +70,12 @@ func loadEmbeddingsTracker(embeddingsTrackingFile string, currentModel string) (
 			"new_model", currentModel)
 		tracker.Files = make(map[string]EmbeddedFile)
 	}
+	if len(tracker.Files) > 0 && tracker.Format != embeddingsFormat {
+		slog.Info("Embeddings format changed, embedding every note again", "old_format", tracker.Format, "new_format", embeddingsFormat)
+		tracker.Files = make(map[string]EmbeddedFile)
+	}
 	tracker.Model = currentModel
+	tracker.Format = embeddingsFormat
 
 	slog.Info("Loaded embeddings tracker", "tracked_files", len(tracker.Files), "model", tracker.Model)
 	return tracker, nil
@@ -109,12 +125,18 @@ func (t *EmbeddingsTracker) markAsEmbedded(note model.Note, lastModified time.Ti
 	}
 }
 
-// VectorStore defines the interface for vector storage operations
+// SimilaritySearcher finds the documents closest to a query, all the search needs of a VectorStore
+type SimilaritySearcher interface {
+	// SimilaritySearch performs a similarity search on the vector store
+	SimilaritySearch(ctx context.Context, query string, numDocuments int, options ...vectorstores.Option) ([]schema.Document, error)
+}
+
+// VectorStore defines the interface for vector storage operations, implemented by the Weaviate
+// store and EmbeddedStore
 type VectorStore interface {
 	// AddDocuments adds documents to the vector store
 	AddDocuments(ctx context.Context, docs []schema.Document, options ...vectorstores.Option) ([]string, error)
-	// SimilaritySearch performs a similarity search on the vector store
-	SimilaritySearch(ctx context.Context, query string, numDocuments int, options ...vectorstores.Option) ([]schema.Document, error)
+	SimilaritySearcher
 }
 
 // EmbeddingsManager handles all embeddings-related functionality
@@ -125,17 +147,28 @@ type EmbeddingsManager struct {
 	notesService           *engine.NotesService
 	embeddingsTrackingFile string
 	embeddingModel         string          // Current embedding model for tracker validation
+	queue                  *EmbeddingQueue // Throttled, pausable worker loop of the embedding pass
 	ctx                    context.Context // Shutdown context for cancelling background work
+	passMu                 sync.Mutex      // Held by the embedding pass and Reembed
+	initialized            atomic.Bool     // Set once InitializeLazily started the first pass
+	publicByDefault        bool            // Whether notes without "publish" are public, else never embedded
 }
 
-// NewEmbeddingsManager creates a new EmbeddingsManager
-func NewEmbeddingsManager(ctx context.Context, store VectorStore, progress *EmbeddingProgress, notesService *engine.NotesService, embeddingsTrackingFile string, embeddingModel string) *EmbeddingsManager {
+// errEmbeddingInProgress is returned by Reembed while an embedding pass runs
+var errEmbeddingInProgress = errors.New("an embedding pass is in progress")
+
+// NewEmbeddingsManager creates a new EmbeddingsManager. A nil queue embeds without throttling.
+func NewEmbeddingsManager(ctx context.Context, store VectorStore, progress *EmbeddingProgress, notesService *engine.NotesService, embeddingsTrackingFile string, embeddingModel string, queue *EmbeddingQueue) *EmbeddingsManager {
+	if queue == nil {
+		queue = NewEmbeddingQueue(0, 1)
+	}
 	return &EmbeddingsManager{
 		store:                  store,
 		progress:               progress,
 		notesService:           notesService,
 		embeddingsTrackingFile: embeddingsTrackingFile,
 		embeddingModel:         embeddingModel,
+		queue:                  queue,
 		ctx:                    ctx,
 	}
 }
@@ -149,6 +182,7 @@ func (em *EmbeddingsManager) InitializeLazily() {
 
 	em.initOnce.Do(func() {
 		slog.Info("Lazy-loading embeddings: triggered by search page access")
+		em.initialized.Store(true)
 
 		// Embed notes into vector store in background
 		go func() {
@@ -161,6 +195,75 @@ func (em *EmbeddingsManager) InitializeLazily() {
 	})
 }
 
+// Close waits for the embedding pass, stopped by the end of the context of the manager, so the
+// tracking file is not left behind the store, then closes the store when it holds resources
+func (em *EmbeddingsManager) Close() {
+	if em == nil {
+		return
+	}
+	em.passMu.Lock()
+	defer em.passMu.Unlock()
+
+	if closer, ok := em.store.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			slog.Warn("Failed to close the vector store", "error", err)
+		}
+	}
+}
+
+// Reembed wipes the embeddings cache, the tracking file and the vectors of the notes it tracks,
+// so every note is embedded again: right away when the embeddings were initialized, else on
+// the first search. Returns errEmbeddingInProgress during an embedding pass.
+func (em *EmbeddingsManager) Reembed() error {
+	if !em.passMu.TryLock() {
+		return errEmbeddingInProgress
+	}
+	err := em.clearEmbeddings()
+	em.passMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if em.initialized.Load() {
+		go func() {
+			allNotes := em.notesService.GetAllNotes()
+			if err := em.embedNotesWithProgress(em.ctx, em.store, allNotes, em.progress); err != nil {
+				slog.Error("Error embedding notes again", "error", err)
+			}
+		}()
+	}
+	return nil
+}
+
+// clearEmbeddings deletes the vectors of the notes tracked and stored, and empties the tracking
+// file. Vectors of stores unable to delete them are ignored by searches once their note changes.
+func (em *EmbeddingsManager) clearEmbeddings() error {
+	tracker, err := loadEmbeddingsTracker(em.embeddingsTrackingFile, em.embeddingModel)
+	if err != nil {
+		return fmt.Errorf("loading embeddings tracker: %w", err)
+	}
+
+	paths := slices.Collect(maps.Keys(tracker.Files))
+	if known, ok := em.store.(documentStore); ok {
+		paths = append(paths, known.DocumentPaths()...)
+	}
+	if deleter, ok := em.store.(documentDeleter); ok && len(paths) > 0 {
+		slices.Sort(paths)
+		if err := deleter.DeleteDocuments(em.ctx, slices.Compact(paths)); err != nil {
+			return fmt.Errorf("deleting embeddings: %w", err)
+		}
+	}
+
+	cleared := len(tracker.Files)
+	tracker.Files = make(map[string]EmbeddedFile)
+	if err := tracker.save(em.embeddingsTrackingFile); err != nil {
+		return fmt.Errorf("saving tracker: %w", err)
+	}
+	em.progress.UpdateProgress(0, 0, 0, "", false)
+	slog.Info("Embeddings cache cleared, every note will be embedded again", "tracked_notes", cleared)
+	return nil
+}
+
 // GetStore returns the vector store
 func (em *EmbeddingsManager) GetStore() VectorStore {
 	if em == nil {
@@ -176,3 +279,23 @@ func (em *EmbeddingsManager) GetProgress() *EmbeddingProgress {
 	}
 	return em.progress
 }
+
+// Pause stops the embedding pass before its next note, returns false if it was already paused
+func (em *EmbeddingsManager) Pause() bool {
+	changed := em.queue.Pause()
+	if changed {
+		em.progress.UpdatePace(true, em.queue.Rate())
+		slog.Info("Embeddings paused")
+	}
+	return changed
+}
+
+// Resume continues the embedding pass where it was paused, returns false if it was not paused
+func (em *EmbeddingsManager) Resume() bool {
+	changed := em.queue.Resume()
+	if changed {
+		em.progress.UpdatePace(false, em.queue.Rate())
+		slog.Info("Embeddings resumed")
+	}
+	return changed
+}
@@ -1,13 +1,18 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"os"
 	"path/filepath"
+	"slices"
 	"testing"
 	"time"
 
 	"github.com/EwenQuim/pluie/model"
+	"github.com/tmc/langchaingo/schema"
+	"github.com/tmc/langchaingo/vectorstores"
 )
 
 func TestComputeContentHash(t *testing.T) {
@@ -32,8 +37,9 @@ func TestComputeContentHash(t *testing.T) {
 
 func TestNeedsEmbedding(t *testing.T) {
 	tracker := &EmbeddingsTracker{
-		Model: "nomic-embed-text",
-		Files: make(map[string]EmbeddedFile),
+		Model:  "nomic-embed-text",
+		Format: embeddingsFormat,
+		Files:  make(map[string]EmbeddedFile),
 	}
 
 	note := model.Note{Title: "Test", Content: "content", Path: "test.md"}
@@ -135,7 +141,8 @@ func TestLoadEmbeddingsTrackerSameModel(t *testing.T) {
 
 	// Create tracker with current model
 	oldTracker := &EmbeddingsTracker{
-		Model: "nomic-embed-text",
+		Model:  "nomic-embed-text",
+		Format: embeddingsFormat,
 		Files: map[string]EmbeddedFile{
 			"test.md": {Path: "test.md", ContentHash: "abc123"},
 		},
@@ -155,13 +162,30 @@ func TestLoadEmbeddingsTrackerSameModel(t *testing.T) {
 	}
 }
 
+func TestLoadEmbeddingsTrackerFormatChange(t *testing.T) {
+	trackingFile := filepath.Join(t.TempDir(), "tracking.json")
+
+	// Notes embedded as a whole, before chunks
+	data := `{"model": "nomic-embed-text", "files": {"test.md": {"path": "test.md", "content_hash": "abc123"}}}`
+	os.WriteFile(trackingFile, []byte(data), 0644)
+
+	tracker, err := loadEmbeddingsTracker(trackingFile, "nomic-embed-text")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(tracker.Files) != 0 || tracker.Format != embeddingsFormat {
+		t.Errorf("tracker = %d files, format %d, want every note to embed again in format %d", len(tracker.Files), tracker.Format, embeddingsFormat)
+	}
+}
+
 func TestSaveAndLoadEmbeddingsTracker(t *testing.T) {
 	tmpDir := t.TempDir()
 	trackingFile := filepath.Join(tmpDir, "tracking.json")
 
 	tracker := &EmbeddingsTracker{
-		Model: "nomic-embed-text",
-		Files: make(map[string]EmbeddedFile),
+		Model:  "nomic-embed-text",
+		Format: embeddingsFormat,
+		Files:  make(map[string]EmbeddedFile),
 	}
 
 	note := model.Note{Title: "Test", Content: "content", Path: "test.md"}
@@ -183,3 +207,122 @@ func TestSaveAndLoadEmbeddingsTracker(t *testing.T) {
 		t.Errorf("Path = %q, want %q", loaded.Files["test.md"].Path, "test.md")
 	}
 }
+
+// recordingStore is a VectorStore recording the paths of the notes it adds and deletes
+type recordingStore struct {
+	added   []string
+	deleted []string
+}
+
+func (s *recordingStore) AddDocuments(_ context.Context, docs []schema.Document, _ ...vectorstores.Option) ([]string, error) {
+	for _, doc := range docs {
+		if path := doc.Metadata["path"].(string); !slices.Contains(s.added, path) {
+			s.added = append(s.added, path)
+		}
+	}
+	return nil, nil
+}
+
+func (s *recordingStore) DeleteDocuments(_ context.Context, paths []string) error {
+	s.deleted = append(s.deleted, paths...)
+	return nil
+}
+
+func (s *recordingStore) SimilaritySearch(context.Context, string, int, ...vectorstores.Option) ([]schema.Document, error) {
+	return nil, nil
+}
+
+func TestEmbedNotesWithProgress_SkipsCachedAndDeletesRemoved(t *testing.T) {
+	trackingFile := filepath.Join(t.TempDir(), "tracking.json")
+	store := &recordingStore{}
+	manager := NewEmbeddingsManager(t.Context(), store, NewEmbeddingProgress(), nil, trackingFile, "model", nil)
+	if err := manager.embedNotesWithProgress(t.Context(), store, queueNotes("a", "b", "c"), manager.progress); err != nil {
+		t.Fatalf("first pass error: %v", err)
+	}
+
+	// After a restart, b was edited and c removed from the vault
+	notes := queueNotes("a", "b")
+	notes[1].Content = "Edited"
+	store = &recordingStore{}
+	manager = NewEmbeddingsManager(t.Context(), store, NewEmbeddingProgress(), nil, trackingFile, "model", nil)
+	if err := manager.embedNotesWithProgress(t.Context(), store, notes, manager.progress); err != nil {
+		t.Fatalf("second pass error: %v", err)
+	}
+	if !slices.Equal(store.added, []string{"b.md"}) || !slices.Equal(store.deleted, []string{"c.md"}) {
+		t.Errorf("second pass added %v and deleted %v, want [b.md] and [c.md]", store.added, store.deleted)
+	}
+	if status := manager.progress.GetStatus(); status.EmbeddedNotes != 1 || status.SkippedNotes != 1 || status.TotalNotes != 2 {
+		t.Errorf("status = %+v, want 1 embedded and 1 skipped of 2", status)
+	}
+
+	tracker, err := loadEmbeddingsTracker(trackingFile, "model")
+	if err != nil {
+		t.Fatalf("loading tracker: %v", err)
+	}
+	if _, ok := tracker.Files["c.md"]; ok || len(tracker.Files) != 2 {
+		t.Errorf("tracked files = %v, want a.md and b.md", tracker.Files)
+	}
+}
+
+func TestEmbedNotesWithProgress_SkipsPrivateNotes(t *testing.T) {
+	trackingFile := filepath.Join(t.TempDir(), "tracking.json")
+	notes := queueNotes("a", "b")
+	store := &recordingStore{}
+	manager := NewEmbeddingsManager(t.Context(), store, NewEmbeddingProgress(), nil, trackingFile, "model", nil)
+	manager.publicByDefault = true
+	if err := manager.embedNotesWithProgress(t.Context(), store, notes, manager.progress); err != nil {
+		t.Fatalf("first pass error: %v", err)
+	}
+
+	// b was made private, with notes public only when they say so
+	notes[1].IsPublic = false
+	store = &recordingStore{}
+	manager = NewEmbeddingsManager(t.Context(), store, NewEmbeddingProgress(), nil, trackingFile, "model", nil)
+	if err := manager.embedNotesWithProgress(t.Context(), store, notes, manager.progress); err != nil {
+		t.Fatalf("second pass error: %v", err)
+	}
+	if len(store.added) != 0 || !slices.Equal(store.deleted, []string{"b.md"}) {
+		t.Errorf("second pass added %v and deleted %v, want the private note deleted", store.added, store.deleted)
+	}
+
+	// Notes are public by default again
+	store = &recordingStore{}
+	manager.store, manager.publicByDefault = store, true
+	if err := manager.embedNotesWithProgress(t.Context(), store, notes, manager.progress); err != nil {
+		t.Fatalf("third pass error: %v", err)
+	}
+	if !slices.Equal(store.added, []string{"b.md"}) {
+		t.Errorf("third pass added %v, want b.md", store.added)
+	}
+}
+
+func TestEmbeddingsManager_Reembed(t *testing.T) {
+	trackingFile := filepath.Join(t.TempDir(), "tracking.json")
+	store := &recordingStore{}
+	manager := NewEmbeddingsManager(t.Context(), store, NewEmbeddingProgress(), nil, trackingFile, "model", nil)
+	if err := manager.embedNotesWithProgress(t.Context(), store, queueNotes("a", "b"), manager.progress); err != nil {
+		t.Fatalf("first pass error: %v", err)
+	}
+
+	// Not during a pass
+	manager.passMu.Lock()
+	if err := manager.Reembed(); !errors.Is(err, errEmbeddingInProgress) {
+		t.Errorf("Reembed() during a pass = %v, want errEmbeddingInProgress", err)
+	}
+	manager.passMu.Unlock()
+
+	if err := manager.Reembed(); err != nil {
+		t.Fatalf("Reembed() error: %v", err)
+	}
+	if !slices.Equal(store.deleted, []string{"a.md", "b.md"}) {
+		t.Errorf("deleted = %v, want the tracked notes", store.deleted)
+	}
+
+	store.added = nil
+	if err := manager.embedNotesWithProgress(t.Context(), store, queueNotes("a", "b"), manager.progress); err != nil {
+		t.Fatalf("pass after Reembed() error: %v", err)
+	}
+	if !slices.Equal(store.added, []string{"a.md", "b.md"}) {
+		t.Errorf("pass after Reembed() added %v, want every note", store.added)
+	}
+}
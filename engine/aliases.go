@@ -0,0 +1,97 @@
+package engine
+
+import (
+	"log/slog"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/EwenQuim/pluie/model"
+)
+
+// AliasConflict is an alias claimed by several notes, or by a note while another note has it
+// as title. Wikilinks with the alias resolve to the note Chosen.
+type AliasConflict struct {
+	Alias  string
+	Slugs  []string // Notes claiming the alias, in path order
+	Chosen string   // The note titled like the alias, else the first claiming it
+}
+
+// BuildAliasIndex maps the aliases of the notes to the slug of their note, for the wikilinks
+// that match no title. An alias another note has as title is left out: the title wins.
+// When several notes claim an alias, the first one in path order gets it.
+func BuildAliasIndex(notes []model.Note) (map[string]string, []AliasConflict) {
+	sorted := slices.Clone(notes)
+	slices.SortFunc(sorted, func(a, b model.Note) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+
+	titles := make(map[string]string, len(sorted))
+	claims := make(map[string][]string)
+	for _, note := range sorted {
+		if _, exists := titles[note.Title]; !exists {
+			titles[note.Title] = note.Slug
+		}
+		for _, alias := range note.Aliases {
+			if !slices.Contains(claims[alias], note.Slug) {
+				claims[alias] = append(claims[alias], note.Slug)
+			}
+		}
+	}
+
+	aliases := make(map[string]string, len(claims))
+	var conflicts []AliasConflict
+	for alias, slugs := range claims {
+		if titled, isTitle := titles[alias]; isTitle {
+			if len(slugs) > 1 || slugs[0] != titled {
+				conflicts = append(conflicts, AliasConflict{Alias: alias, Slugs: slugs, Chosen: titled})
+			}
+			continue
+		}
+		aliases[alias] = slugs[0]
+		if len(slugs) > 1 {
+			conflicts = append(conflicts, AliasConflict{Alias: alias, Slugs: slugs, Chosen: slugs[0]})
+		}
+	}
+
+	slices.SortFunc(conflicts, func(a, b AliasConflict) int {
+		return strings.Compare(a.Alias, b.Alias)
+	})
+	return aliases, conflicts
+}
+
+// logAliasConflicts warns about the aliases claimed by several notes
+func logAliasConflicts(conflicts []AliasConflict) {
+	for _, conflict := range conflicts {
+		slog.Warn("Alias claimed by several notes", "alias", conflict.Alias, "notes", conflict.Slugs, "chosen", conflict.Chosen)
+	}
+}
+
+// BuildAliasSlugs maps the slugs the aliases of the notes would have, in the folder of
+// their note and with both slug schemes, to the slug of the note, for redirects. Like
+// BuildLegacySlugs, the decoded form of the slugs is mapped too.
+func BuildAliasSlugs(notes []model.Note) map[string]string {
+	aliases, _ := BuildAliasIndex(notes)
+
+	aliasSlugs := make(map[string]string)
+	for _, note := range notes {
+		folder := path.Dir(strings.Trim(note.Path, "/"))
+		for _, alias := range note.Aliases {
+			if aliases[alias] != note.Slug {
+				continue
+			}
+			aliasPath := path.Join(folder, alias)
+			for _, aliasSlug := range []string{SlugifyNote(aliasPath), SlugifyNoteV2(aliasPath)} {
+				if aliasSlug == note.Slug || aliasSlug == "" {
+					continue
+				}
+				aliasSlugs[aliasSlug] = note.Slug
+				if decoded, err := url.PathUnescape(aliasSlug); err == nil {
+					aliasSlugs[decoded] = note.Slug
+				}
+			}
+		}
+	}
+	return aliasSlugs
+}
@@ -0,0 +1,85 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/EwenQuim/pluie/model"
+)
+
+func aliasTestNotes() []model.Note {
+	return []model.Note{
+		{Title: "Artificial Intelligence", Slug: "ai/artificial-intelligence", Path: "ai/Artificial Intelligence.md", Aliases: []string{"AI", "Machine minds"}},
+		{Title: "AI", Slug: "ai/ai", Path: "ai/AI.md"},
+		{Title: "Robots", Slug: "robots", Path: "Robots.md", Aliases: []string{"Machine minds", "Droids"}},
+		{Title: "Hub", Slug: "hub", Path: "Hub.md", Content: "[[Droids]], [[Machine minds]], [[AI]] and [[Droids#Models|models]]"},
+	}
+}
+
+func TestBuildAliasIndex(t *testing.T) {
+	aliases, conflicts := BuildAliasIndex(aliasTestNotes())
+
+	// "AI" is a title, the alias is left out
+	if len(aliases) != 2 || aliases["Droids"] != "robots" || aliases["Machine minds"] != "robots" {
+		t.Errorf("unexpected aliases %v", aliases)
+	}
+
+	if len(conflicts) != 2 {
+		t.Fatalf("conflicts = %+v, want the AI title and the Machine minds alias", conflicts)
+	}
+	if conflicts[0].Alias != "AI" || conflicts[0].Chosen != "ai/ai" {
+		t.Errorf("the note titled like the alias should win, got %+v", conflicts[0])
+	}
+	if conflicts[1].Alias != "Machine minds" || conflicts[1].Chosen != "robots" || len(conflicts[1].Slugs) != 2 {
+		t.Errorf("the first note in path order should win, got %+v", conflicts[1])
+	}
+}
+
+func TestParseWikiLinks_Aliases(t *testing.T) {
+	notes := aliasTestNotes()
+	tree := BuildTree(notes)
+
+	got := ParseWikiLinks(notes[3].Content, tree)
+	expected := "[Droids](/robots), [Machine minds](/robots), [AI](/ai/ai) and [models](/robots)"
+	if got != expected {
+		t.Errorf("ParseWikiLinks() = %q, want %q", got, expected)
+	}
+}
+
+func TestBuildBackreferences_Aliases(t *testing.T) {
+	notes := BuildBackreferences(aliasTestNotes())
+
+	for _, note := range notes {
+		var referenced bool
+		for _, ref := range note.ReferencedBy {
+			referenced = referenced || ref.Slug == "hub"
+		}
+		// The aliases of ai/artificial-intelligence are both taken by other notes
+		if expected := note.Slug == "robots" || note.Slug == "ai/ai"; referenced != expected {
+			t.Errorf("%s referenced by the hub = %v, want %v", note.Slug, referenced, expected)
+		}
+	}
+}
+
+func TestBuildAliasSlugs(t *testing.T) {
+	notes := aliasTestNotes()
+	notes = append(notes, model.Note{Title: "Café", Slug: "paris/café", Path: "paris/Café.md", Aliases: []string{"Coffee Shop!"}})
+	aliasSlugs := BuildAliasSlugs(notes)
+
+	for aliasSlug, slug := range map[string]string{
+		"droids":               "robots",
+		"machine-minds":        "robots",
+		"paris/coffee-shop":    "paris/café",
+		"paris/coffee-shop%21": "paris/café",
+		"paris/coffee-shop!":   "paris/café",
+	} {
+		if got := aliasSlugs[aliasSlug]; got != slug {
+			t.Errorf("aliasSlugs[%q] = %q, want %q", aliasSlug, got, slug)
+		}
+	}
+	for aliasSlug := range aliasSlugs {
+		if strings.HasPrefix(aliasSlug, "ai/") {
+			t.Errorf("the aliases lost to other notes should not redirect, got %q", aliasSlug)
+		}
+	}
+}
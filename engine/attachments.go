@@ -0,0 +1,319 @@
+package engine
+
+import (
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/EwenQuim/pluie/model"
+)
+
+// AttachmentsPrefix is the URL path attachments are served at, in server and static modes
+const AttachmentsPrefix = "/-/attachments/"
+
+// DefaultAttachmentExtensions is the default ATTACHMENT_EXTENSIONS: pictures, PDFs and audio
+const DefaultAttachmentExtensions = "png,jpg,jpeg,gif,webp,pdf,mp3,wav,ogg,m4a,flac"
+
+// attachmentContentTypes are the Content-Type of the attachments rendered inline: pictures,
+// PDFs and audio. SVG is left out: served from the site origin, its scripts would run.
+var attachmentContentTypes = map[string]string{
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+	".pdf":  "application/pdf",
+	".mp3":  "audio/mpeg",
+	".wav":  "audio/wav",
+	".ogg":  "audio/ogg",
+	".m4a":  "audio/mp4",
+	".flac": "audio/flac",
+}
+
+// refusedAttachmentExtensions are never served as attachments: notes, folder metadata, and
+// files a browser would run the scripts of
+var refusedAttachmentExtensions = []string{".md", ".pluie", ".svg", ".html", ".htm", ".xhtml", ".xml", ".js", ".mjs"}
+
+// AttachmentExtensions are the extensions of the vault files published as attachments,
+// like ".png", see ParseAttachmentExtensions
+type AttachmentExtensions map[string]bool
+
+// markdownAttachmentLinkRegex matches markdown links and images, like [text](target "title"),
+// with the part before the target, the target, and the part after it
+var markdownAttachmentLinkRegex = regexp.MustCompile(`(!?\[[^\]]*\]\()([^)\s]+)((?:\s+"[^"]*")?\))`)
+
+// attachmentEmbedRegex matches ![[target]] and ![[target|hint]] embeds
+var attachmentEmbedRegex = regexp.MustCompile(`!\[\[([^\]|]+)(?:\|([^\]]*))?\]\]`)
+
+// attachmentSizeRegex matches the size hints of image embeds, like "300" or "300x200"
+var attachmentSizeRegex = regexp.MustCompile(`^(\d+)(?:x(\d+))?$`)
+
+// ParseAttachmentExtensions parses a comma-separated extension list like "png,pdf,mp3",
+// DefaultAttachmentExtensions when empty. Notes and files browsers run scripts of, like
+// SVG or HTML, are refused.
+func ParseAttachmentExtensions(list string) AttachmentExtensions {
+	if strings.TrimSpace(list) == "" {
+		list = DefaultAttachmentExtensions
+	}
+
+	extensions := make(AttachmentExtensions)
+	for extension := range strings.SplitSeq(list, ",") {
+		extension = strings.ToLower(strings.TrimSpace(extension))
+		if extension == "" {
+			continue
+		}
+		extension = "." + strings.TrimPrefix(extension, ".")
+		if slices.Contains(refusedAttachmentExtensions, extension) {
+			slog.Warn("Attachment extension refused", "extension", extension)
+			continue
+		}
+		extensions[extension] = true
+	}
+	return extensions
+}
+
+// Has reports whether the file name has one of the extensions
+func (e AttachmentExtensions) Has(name string) bool {
+	return e[strings.ToLower(path.Ext(name))]
+}
+
+// IsAttachment reports whether the file name has the extension of an attachment rendered
+// inline, a picture, a PDF or an audio file
+func IsAttachment(name string) bool {
+	_, ok := attachmentContentTypes[strings.ToLower(path.Ext(name))]
+	return ok
+}
+
+// IsAudioAttachment reports whether the file name has the extension of an audio attachment
+func IsAudioAttachment(name string) bool {
+	return strings.HasPrefix(attachmentContentTypes[strings.ToLower(path.Ext(name))], "audio/")
+}
+
+// AttachmentContentType returns the Content-Type to serve the attachment with. Unknown
+// types are served as binary data rather than sniffed.
+func AttachmentContentType(name string) string {
+	extension := strings.ToLower(path.Ext(name))
+	if contentType, ok := attachmentContentTypes[extension]; ok {
+		return contentType
+	}
+	if contentType := mime.TypeByExtension(extension); contentType != "" {
+		return contentType
+	}
+	return "application/octet-stream"
+}
+
+// AttachmentURL returns the URL an attachment is served at, from its path in the vault
+func AttachmentURL(vaultPath string) string {
+	return AttachmentsPrefix + EscapeSlug(vaultPath)
+}
+
+// ResolveAttachment finds the attachment a note embeds with target, like Obsidian does:
+// a path from the vault root, then a path relative to the folder of the note, then the
+// attachment with that name closest to the vault root. attachments are vault paths.
+func ResolveAttachment(attachments []string, notePath, target string) (string, bool) {
+	target = strings.Trim(strings.TrimSpace(target), "/")
+	if target == "" {
+		return "", false
+	}
+
+	candidates := []string{path.Clean(target), path.Join(noteFolder(notePath), target)}
+	for _, candidate := range candidates {
+		if slices.Contains(attachments, candidate) {
+			return candidate, true
+		}
+	}
+
+	var best string
+	for _, attachment := range attachments {
+		if !strings.HasSuffix(attachment, "/"+target) {
+			continue
+		}
+		if best == "" || strings.Count(attachment, "/") < strings.Count(best, "/") ||
+			(strings.Count(attachment, "/") == strings.Count(best, "/") && attachment < best) {
+			best = attachment
+		}
+	}
+	return best, best != ""
+}
+
+// resolveAttachmentLink finds the attachment a markdown link of a note points to: a path
+// relative to the folder of the note first, then like ResolveAttachment. Links to other
+// sites are left out.
+func resolveAttachmentLink(attachments []string, notePath, link string) (string, bool) {
+	u, err := url.Parse(link)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" {
+		return "", false
+	}
+
+	if !strings.HasPrefix(u.Path, "/") {
+		if candidate := path.Join(noteFolder(notePath), u.Path); slices.Contains(attachments, candidate) {
+			return candidate, true
+		}
+	}
+	return ResolveAttachment(attachments, notePath, u.Path)
+}
+
+// noteFolder returns the vault path of the folder of a note, "" at the root of the vault
+func noteFolder(notePath string) string {
+	folder := strings.Trim(path.Dir(notePath), "/")
+	if folder == "." {
+		return ""
+	}
+	return folder
+}
+
+// ResolveAttachmentEmbeds sets the Attachments of the notes, with the vault path of each
+// ![[attachment]] embed and markdown link to an attachment, like ![](images/cat.png),
+// of their content that resolves, and of their cover image
+func ResolveAttachmentEmbeds(notes []model.Note, attachments []string) {
+	for i := range notes {
+		var resolved map[string]string
+		add := func(target, vaultPath string) {
+			if resolved == nil {
+				resolved = make(map[string]string)
+			}
+			resolved[target] = vaultPath
+		}
+
+		for _, match := range attachmentEmbedRegex.FindAllStringSubmatch(notes[i].Content, -1) {
+			target := attachmentTarget(match[1])
+			if vaultPath, ok := ResolveAttachment(attachments, notes[i].Path, target); ok {
+				add(target, vaultPath)
+			}
+		}
+		for _, match := range markdownAttachmentLinkRegex.FindAllStringSubmatch(notes[i].Content, -1) {
+			if vaultPath, ok := resolveAttachmentLink(attachments, notes[i].Path, match[2]); ok {
+				add(match[2], vaultPath)
+			}
+		}
+		// The cover image of the frontmatter, served like the embeds, see CoverURL
+		if target := CoverTarget(notes[i].Metadata); target != "" {
+			if vaultPath, ok := resolveAttachmentLink(attachments, notes[i].Path, target); ok {
+				add(target, vaultPath)
+			}
+		}
+		notes[i].Attachments = resolved
+	}
+}
+
+// rewriteAttachmentLinks points the markdown links to attachments of content to the URL the
+// attachments are served at, keeping their fragment. attachments maps link targets to vault
+// paths, see ResolveAttachmentEmbeds. Links in code are kept.
+func rewriteAttachmentLinks(content string, attachments map[string]string) string {
+	if len(attachments) == 0 {
+		return content
+	}
+	codeBlocks := findCodeBlocks(content)
+
+	var result strings.Builder
+	last := 0
+	for _, indexes := range markdownAttachmentLinkRegex.FindAllStringSubmatchIndex(content, -1) {
+		target := content[indexes[4]:indexes[5]]
+		vaultPath, ok := attachments[target]
+		if !ok || insideCodeBlock(codeBlocks, indexes[0]) {
+			continue
+		}
+		href := AttachmentURL(vaultPath)
+		if _, fragment, found := strings.Cut(target, "#"); found {
+			href += "#" + fragment
+		}
+
+		result.WriteString(content[last:indexes[4]])
+		result.WriteString(href)
+		last = indexes[5]
+	}
+	result.WriteString(content[last:])
+	return result.String()
+}
+
+// ParseAttachmentEmbeds transforms the ![[attachment]] embeds of content into markdown:
+// an image for pictures and audio files, a link for other files. attachments maps embed targets to vault
+// paths, see ResolveAttachmentEmbeds. A size hint like ![[image.png|300]] becomes a
+// "#300" fragment, turned into a width attribute once rendered. Any other hint is the
+// alternative text. Embeds of unknown attachments become their text, embeds in code are kept.
+func ParseAttachmentEmbeds(content string, attachments map[string]string) string {
+	codeBlocks := findCodeBlocks(content)
+
+	var result strings.Builder
+	last := 0
+	for _, indexes := range attachmentEmbedRegex.FindAllStringSubmatchIndex(content, -1) {
+		start, end := indexes[0], indexes[1]
+		target := attachmentTarget(content[indexes[2]:indexes[3]])
+		if _, resolved := attachments[target]; (!resolved && !IsAttachment(target)) || insideCodeBlock(codeBlocks, start) {
+			continue
+		}
+		var hint string
+		if indexes[4] != -1 {
+			hint = strings.TrimSpace(content[indexes[4]:indexes[5]])
+		}
+
+		result.WriteString(content[last:start])
+		result.WriteString(attachmentMarkdown(target, hint, attachments))
+		last = end
+	}
+	result.WriteString(content[last:])
+	return result.String()
+}
+
+// attachmentMarkdown returns the markdown of an embed of target
+func attachmentMarkdown(target, hint string, attachments map[string]string) string {
+	name := path.Base(target)
+	vaultPath, ok := attachments[target]
+	if !ok {
+		return name
+	}
+	href := AttachmentURL(vaultPath)
+
+	// Audio files are rendered as images too, then turned into players
+	if !IsAttachment(target) || strings.ToLower(path.Ext(target)) == ".pdf" {
+		text := name
+		if hint != "" {
+			text = hint
+		}
+		return fmt.Sprintf("[%s](%s)", text, href)
+	}
+
+	alt := strings.TrimSuffix(name, path.Ext(name))
+	if _, _, ok := AttachmentSize(hint); ok {
+		href += "#" + hint
+	} else if hint != "" {
+		alt = hint
+	}
+	return fmt.Sprintf("![%s](%s)", alt, href)
+}
+
+// AttachmentSize parses the size hint of an image embed, like "300" or "300x200".
+// height is 0 when only the width is given.
+func AttachmentSize(hint string) (width, height int, ok bool) {
+	match := attachmentSizeRegex.FindStringSubmatch(hint)
+	if match == nil {
+		return 0, 0, false
+	}
+	width, _ = strconv.Atoi(match[1])
+	if match[2] != "" {
+		height, _ = strconv.Atoi(match[2])
+	}
+	return width, height, true
+}
+
+// attachmentTarget strips the "#page=3"-like fragment of an embed target
+func attachmentTarget(target string) string {
+	target, _, _ = strings.Cut(target, "#")
+	return strings.TrimSpace(target)
+}
+
+// insideCodeBlock reports whether the offset is inside one of the code blocks
+func insideCodeBlock(blocks []codeBlock, offset int) bool {
+	for _, block := range blocks {
+		if offset >= block.start && offset < block.end {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,153 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/EwenQuim/pluie/model"
+)
+
+func TestResolveAttachment(t *testing.T) {
+	attachments := []string{"cat.png", "assets/deep/cat.png", "assets/dog.png", "journal/photo.jpg", "z/dog.png"}
+
+	tests := []struct {
+		name     string
+		notePath string
+		target   string
+		expected string
+	}{
+		{name: "vault path", notePath: "notes/a.md", target: "assets/dog.png", expected: "assets/dog.png"},
+		{name: "relative to the note", notePath: "journal/2024.md", target: "photo.jpg", expected: "journal/photo.jpg"},
+		{name: "relative to an explored note", notePath: "/journal/2024.md", target: "photo.jpg", expected: "journal/photo.jpg"},
+		{name: "closest to the root", notePath: "notes/a.md", target: "dog.png", expected: "assets/dog.png"},
+		{name: "root wins over subfolders", notePath: "notes/a.md", target: "cat.png", expected: "cat.png"},
+		{name: "partial path", notePath: "a.md", target: "deep/cat.png", expected: "assets/deep/cat.png"},
+		{name: "unknown", notePath: "a.md", target: "bird.png", expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ResolveAttachment(attachments, tt.notePath, tt.target)
+			if got != tt.expected || ok != (tt.expected != "") {
+				t.Errorf("ResolveAttachment(%q) = %q, %v, want %q", tt.target, got, ok, tt.expected)
+			}
+		})
+	}
+}
+
+func TestResolveAttachmentEmbeds(t *testing.T) {
+	notes := []model.Note{
+		{Path: "trip.md", Content: "![[beach.png|300]] and ![[missing.png]], ![[Other note]] and ![[guide.pdf#page=2]]"},
+		{Path: "plain.md", Content: "No embeds"},
+	}
+	ResolveAttachmentEmbeds(notes, []string{"photos/beach.png", "guide.pdf"})
+
+	if len(notes[0].Attachments) != 2 || notes[0].Attachments["beach.png"] != "photos/beach.png" || notes[0].Attachments["guide.pdf"] != "guide.pdf" {
+		t.Errorf("unexpected attachments %v", notes[0].Attachments)
+	}
+	if notes[1].Attachments != nil {
+		t.Errorf("a note without embeds should have no attachments, got %v", notes[1].Attachments)
+	}
+}
+
+func TestResolveAttachmentEmbeds_MarkdownLinks(t *testing.T) {
+	notes := []model.Note{{
+		Path: "journal/2024/trip.md",
+		Content: "![](../../attachments/beach.png) ![Map](/attachments/map%20v2.png \"The map\") " +
+			"[guide](guide.pdf#page=2) [site](https://example.com/guide.pdf) [note](other.md) ![](missing.png)",
+	}}
+	ResolveAttachmentEmbeds(notes, []string{"attachments/beach.png", "attachments/map v2.png", "journal/2024/guide.pdf", "guide.pdf"})
+
+	expected := map[string]string{
+		"../../attachments/beach.png": "attachments/beach.png",
+		"/attachments/map%20v2.png":   "attachments/map v2.png",
+		"guide.pdf#page=2":            "journal/2024/guide.pdf",
+	}
+	if len(notes[0].Attachments) != len(expected) {
+		t.Fatalf("attachments = %v, want %v", notes[0].Attachments, expected)
+	}
+	for target, vaultPath := range expected {
+		if notes[0].Attachments[target] != vaultPath {
+			t.Errorf("attachments[%q] = %q, want %q", target, notes[0].Attachments[target], vaultPath)
+		}
+	}
+}
+
+func TestProcessMarkdownLinks_Attachments(t *testing.T) {
+	attachments := map[string]string{
+		"../attachments/beach.png": "attachments/beach.png",
+		"guide.pdf#page=2":         "docs/guide.pdf",
+	}
+
+	content := "![](../attachments/beach.png) [guide](guide.pdf#page=2 \"Guide\") [Note](other.md) `![](../attachments/beach.png)`"
+	expected := "![](/-/attachments/attachments/beach.png) [guide](/-/attachments/docs/guide.pdf#page=2 \"Guide\") [Note](other) `![](../attachments/beach.png)`"
+	if got := ProcessMarkdownLinks(content, attachments); got != expected {
+		t.Errorf("ProcessMarkdownLinks() = %q, want %q", got, expected)
+	}
+}
+
+func TestParseAttachmentExtensions(t *testing.T) {
+	extensions := ParseAttachmentExtensions(" PNG, .csv,,svg,html,md")
+	for name, expected := range map[string]bool{
+		"cat.png": true, "Cat.PNG": true, "table.csv": true,
+		"drawing.svg": false, "page.html": false, "note.md": false, "guide.pdf": false,
+	} {
+		if got := extensions.Has(name); got != expected {
+			t.Errorf("Has(%q) = %v, want %v", name, got, expected)
+		}
+	}
+
+	if defaults := ParseAttachmentExtensions(""); !defaults.Has("guide.pdf") || !defaults.Has("song.mp3") || defaults.Has("drawing.svg") {
+		t.Errorf("unexpected default extensions %v", defaults)
+	}
+}
+
+func TestAttachmentContentType(t *testing.T) {
+	for name, expected := range map[string]string{
+		"song.MP3":   "audio/mpeg",
+		"guide.pdf":  "application/pdf",
+		"photo.jpeg": "image/jpeg",
+		"data.bin42": "application/octet-stream",
+	} {
+		if got := AttachmentContentType(name); got != expected {
+			t.Errorf("AttachmentContentType(%q) = %q, want %q", name, got, expected)
+		}
+	}
+}
+
+func TestParseAttachmentEmbeds(t *testing.T) {
+	attachments := map[string]string{
+		"beach.png":     "photos/beach.png",
+		"my trip.jpg":   "photos/my trip.jpg",
+		"guide.pdf":     "docs/guide.pdf",
+		"photos/up.gif": "photos/up.gif",
+		"song.mp3":      "audio/song.mp3",
+		"table.csv":     "data/table.csv",
+	}
+
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{name: "image", content: "![[beach.png]]", expected: "![beach](/-/attachments/photos/beach.png)"},
+		{name: "width", content: "![[beach.png|300]]", expected: "![beach](/-/attachments/photos/beach.png#300)"},
+		{name: "width and height", content: "![[beach.png|300x200]]", expected: "![beach](/-/attachments/photos/beach.png#300x200)"},
+		{name: "alternative text", content: "![[beach.png|The beach]]", expected: "![The beach](/-/attachments/photos/beach.png)"},
+		{name: "escaped path", content: "![[my trip.jpg]]", expected: "![my trip](/-/attachments/photos/my%20trip.jpg)"},
+		{name: "path target", content: "![[photos/up.gif]]", expected: "![up](/-/attachments/photos/up.gif)"},
+		{name: "pdf link", content: "See ![[guide.pdf#page=2]]", expected: "See [guide.pdf](/-/attachments/docs/guide.pdf)"},
+		{name: "audio", content: "![[song.mp3]]", expected: "![song](/-/attachments/audio/song.mp3)"},
+		{name: "other file link", content: "![[table.csv]]", expected: "[table.csv](/-/attachments/data/table.csv)"},
+		{name: "unknown attachment", content: "![[missing.png]]", expected: "missing.png"},
+		{name: "note embed untouched", content: "![[Some note]]", expected: "![[Some note]]"},
+		{name: "code untouched", content: "`![[beach.png]]` ![[beach.png]]", expected: "`![[beach.png]]` ![beach](/-/attachments/photos/beach.png)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseAttachmentEmbeds(tt.content, attachments); got != tt.expected {
+				t.Errorf("ParseAttachmentEmbeds() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
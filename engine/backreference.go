@@ -16,32 +16,21 @@ func BuildBackreferences(notes []model.Note) []model.Note {
 		slog.Info("Backreferences built", "in", time.Since(start).String())
 	}()
 
-	// Create a map for quick note lookup by title
-	notesByTitle := make(map[string]*model.Note)
-
 	// Initialize all notes with empty ReferencedBy slices
 	for i := range notes {
 		notes[i].ReferencedBy = []model.NoteReference{}
-		notesByTitle[notes[i].Title] = &notes[i]
 	}
 
+	// Wikilinks matching no title resolve with the aliases
+	aliases, conflicts := BuildAliasIndex(notes)
+	logAliasConflicts(conflicts)
+	findNote := wikiLinkResolver(notes, aliases)
+
 	// Analyze each note for wikilinks
 	for _, sourceNote := range notes {
-		// Find all wikilinks in the source note's content
-		contentWikiLinks := extractWikiLinks(sourceNote.Content)
-
-		// Find all wikilinks in the source note's metadata
-		metadataWikiLinks := extractWikiLinksFromMetadata(sourceNote.Metadata)
-
-		// Combine all wikilinks from both content and metadata
-		allWikiLinks := append(contentWikiLinks, metadataWikiLinks...)
-
-		// Remove duplicates from the combined list
-		uniqueWikiLinks := removeDuplicateStrings(allWikiLinks)
-
 		// For each wikilink, add this note as a reference to the target note
-		for _, targetTitle := range uniqueWikiLinks {
-			if targetNote, exists := notesByTitle[targetTitle]; exists {
+		for _, targetTitle := range wikiLinkTargets(sourceNote) {
+			if targetNote, exists := findNote(targetTitle); exists {
 				// Add the source note as a reference to the target note
 				reference := model.NoteReference{
 					Slug:  sourceNote.Slug,
@@ -59,6 +48,62 @@ func BuildBackreferences(notes []model.Note) []model.Note {
 	return notes
 }
 
+// LinkCount is what the wikilinks of a note point to, see NoteLinkCounts
+type LinkCount struct {
+	Outgoing int // Notes linked, once each
+	Broken   int // Targets matching no note
+}
+
+// NoteLinkCounts counts the wikilinks of each note, by slug, with the extraction and resolution
+// of BuildBackreferences: a note is linked by the targets it has in its ReferencedBy, links to
+// itself included. [[#heading]] links and attachment embeds are not broken links.
+func NoteLinkCounts(notes []model.Note) map[string]LinkCount {
+	aliases, _ := BuildAliasIndex(notes) // Conflicts are logged by BuildBackreferences
+	findNote := wikiLinkResolver(notes, aliases)
+
+	counts := make(map[string]LinkCount, len(notes))
+	for _, note := range notes {
+		var count LinkCount
+		linked := make(map[string]bool)
+		for _, target := range wikiLinkTargets(note) {
+			if targetNote, exists := findNote(target); exists {
+				if !linked[targetNote.Slug] {
+					linked[targetNote.Slug] = true
+					count.Outgoing++
+				}
+				continue
+			}
+			if _, resolved := note.Attachments[attachmentTarget(target)]; resolved || IsAttachment(attachmentTarget(target)) || attachmentTarget(target) == "" {
+				continue
+			}
+			count.Broken++
+		}
+		counts[note.Slug] = count
+	}
+	return counts
+}
+
+// wikiLinkResolver returns the function finding the note of a wikilink target among notes, like
+// ParseWikiLinks, see LinkIndex: [[Note#Heading]] or [[Note#^blockid]] by the title before the "#".
+// The notes found point into notes.
+func wikiLinkResolver(notes []model.Note, aliases map[string]string) func(target string) (*model.Note, bool) {
+	pointers := make([]*model.Note, len(notes))
+	for i := range notes {
+		pointers[i] = &notes[i]
+	}
+	index := newLinkIndex(pointers, aliases)
+
+	return func(target string) (*model.Note, bool) {
+		note := index.FindLink(target)
+		return note, note != nil
+	}
+}
+
+// wikiLinkTargets returns the unique targets of the wikilinks of the content and metadata of the note
+func wikiLinkTargets(note model.Note) []string {
+	return removeDuplicateStrings(append(extractWikiLinks(note.Content), extractWikiLinksFromMetadata(note.Metadata)...))
+}
+
 // extractWikiLinks extracts all unique target titles from wikilinks in the content
 func extractWikiLinks(content string) []string {
 	var links []string
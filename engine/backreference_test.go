@@ -488,6 +488,102 @@ func TestBuildBackreferences(t *testing.T) {
 	}
 }
 
+func TestBuildBackreferences_EmbedBoundaries(t *testing.T) {
+	// Host embeds Embedded, which links to Third. The embed counts as a
+	// reference to Embedded, but Embedded's own links are not the host's.
+	notes := BuildBackreferences([]model.Note{
+		{Title: "Host", Slug: "host", Content: "![[Embedded]]"},
+		{Title: "Embedded", Slug: "embedded", Content: "See [[Third]]"},
+		{Title: "Third", Slug: "third", Content: "Leaf"},
+	})
+
+	bySlug := make(map[string]model.Note)
+	for _, note := range notes {
+		bySlug[note.Slug] = note
+	}
+
+	expectedEmbedded := []model.NoteReference{{Slug: "host", Title: "Host"}}
+	if !reflect.DeepEqual(bySlug["embedded"].ReferencedBy, expectedEmbedded) {
+		t.Errorf("Expected embedded to be referenced by host, got %+v", bySlug["embedded"].ReferencedBy)
+	}
+
+	expectedThird := []model.NoteReference{{Slug: "embedded", Title: "Embedded"}}
+	if !reflect.DeepEqual(bySlug["third"].ReferencedBy, expectedThird) {
+		t.Errorf("Expected third to be referenced by embedded only, got %+v", bySlug["third"].ReferencedBy)
+	}
+}
+
+func TestBuildBackreferences_SectionLinks(t *testing.T) {
+	notes := BuildBackreferences([]model.Note{
+		{Title: "Guide", Slug: "guide", Content: "See [[Setup#Install|installing]] and [[Setup#^quote]]"},
+		{Title: "FAQ", Slug: "faq", Content: "See [[Setup#Missing heading]]"},
+		{Title: "Setup", Slug: "setup", Content: "## Install\n\n[[#Install]] is the only step."},
+	})
+
+	expected := []model.NoteReference{{Slug: "guide", Title: "Guide"}, {Slug: "faq", Title: "FAQ"}}
+	if !reflect.DeepEqual(notes[2].ReferencedBy, expected) {
+		t.Errorf("section links should reference the note once per source, got %+v", notes[2].ReferencedBy)
+	}
+}
+
+func TestBuildBackreferences_NormalizedLinks(t *testing.T) {
+	notes := BuildBackreferences([]model.Note{
+		{Title: "Journal", Slug: "journal", Path: "Journal.md", Content: "See [[my note]], [[My   Note]] and [[ideas/MY NOTE.md]]"},
+		{Title: "My Note", Slug: "ideas/my-note", Path: "Ideas/My Note.md"},
+		{Title: "my note", Slug: "archive/old/my-note", Path: "Archive/Old/my note.md"},
+	})
+
+	// Exact case first, then the shallower path
+	expected := []model.NoteReference{{Slug: "journal", Title: "Journal"}}
+	if !reflect.DeepEqual(notes[1].ReferencedBy, expected) {
+		t.Errorf("links in any case and spacing should reference the shallower note, got %+v", notes[1].ReferencedBy)
+	}
+	if !reflect.DeepEqual(notes[2].ReferencedBy, expected) {
+		t.Errorf("the exact case link should reference its note, got %+v", notes[2].ReferencedBy)
+	}
+}
+
+func TestBuildBackreferences_SectionEmbeds(t *testing.T) {
+	notes := BuildBackreferences([]model.Note{
+		{Title: "Menu", Slug: "menu", Content: "![[Recipe#Steps]]"},
+		{Title: "Recipe", Slug: "recipe", Content: "## Steps\n\nBake."},
+	})
+
+	expected := []model.NoteReference{{Slug: "menu", Title: "Menu"}}
+	if !reflect.DeepEqual(notes[1].ReferencedBy, expected) {
+		t.Errorf("embedding a section should reference the note, got %+v", notes[1].ReferencedBy)
+	}
+}
+
+func TestNoteLinkCounts(t *testing.T) {
+	notes := []model.Note{
+		{Title: "Journal", Slug: "journal", Content: "See [[My Note]], [[my note|again]], [[Recipe#Steps]], [[Missing]] and [[#Today]]\n\n![[photo.png]] ![[Gone]]"},
+		{Title: "My Note", Slug: "my-note", Content: "Back to [[Journal]] and [[My Note]]", Metadata: map[string]any{"related": "[[Nowhere]]"}},
+		{Title: "Recipe", Slug: "recipe", Aliases: []string{"Cake"}, Content: "Nothing"},
+		{Title: "Orphan", Slug: "orphan", Content: "Alone, see [[Cake]]"},
+	}
+
+	expected := map[string]LinkCount{
+		"journal": {Outgoing: 2, Broken: 2},
+		"my-note": {Outgoing: 2, Broken: 1},
+		"recipe":  {},
+		"orphan":  {Outgoing: 1},
+	}
+	counts := NoteLinkCounts(notes)
+	if !reflect.DeepEqual(counts, expected) {
+		t.Errorf("NoteLinkCounts() = %+v, want %+v", counts, expected)
+	}
+
+	// The outgoing links are the backlinks of the linked notes
+	total := 0
+	for _, note := range BuildBackreferences(notes) {
+		total += len(note.ReferencedBy)
+	}
+	if total != 5 {
+		t.Errorf("expected as many backlinks as outgoing links, got %d", total)
+	}
+}
+
 func TestExtractWikiLinks(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -0,0 +1,94 @@
+package engine
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Crumb is a folder of the breadcrumb of a note
+type Crumb struct {
+	Name string // Folder name, as in the notes tree
+	Slug string // Slug of the folder, the prefix of the slugs of its notes, like "projects/clientx"
+	Path string // Folder path in the vault, like "Projects/ClientX", empty when the note is not in the tree
+}
+
+// BreadcrumbsForSlug returns the folders of the note at slug, from the top one. Notes at
+// the root of the vault have none. Names come from the tree, or from the decoded slug
+// segments when the note is not in it.
+func BreadcrumbsForSlug(slug string, tree *TreeNode) []Crumb {
+	slug = strings.Trim(slug, "/")
+	if tree != nil {
+		if node := FindNoteInTree(tree, slug); node != nil && node.Note.Permalink != "" {
+			return permalinkBreadcrumbs(tree, slug)
+		}
+	}
+	segments := strings.Split(slug, "/")
+	if len(segments) < 2 {
+		return nil
+	}
+	folders := segments[:len(segments)-1]
+
+	var ancestors []*TreeNode
+	if tree != nil {
+		ancestors, _ = folderAncestors(tree, slug)
+	}
+
+	crumbs := make([]Crumb, len(folders))
+	for i, segment := range folders {
+		crumbs[i].Slug = strings.Join(segments[:i+1], "/")
+		if len(ancestors) == len(folders) {
+			crumbs[i].Name = ancestors[i].Name
+			crumbs[i].Path = ancestors[i].Path
+		} else if name, err := url.PathUnescape(segment); err == nil {
+			crumbs[i].Name = name
+		} else {
+			crumbs[i].Name = segment
+		}
+	}
+	return crumbs
+}
+
+// permalinkBreadcrumbs returns the folders of the note at slug with a permalink: the folders of
+// its path in the vault, as its slug has nothing to do with them
+func permalinkBreadcrumbs(tree *TreeNode, slug string) []Crumb {
+	ancestors, _ := folderAncestors(tree, slug)
+	crumbs := make([]Crumb, len(ancestors))
+	for i, folder := range ancestors {
+		crumbs[i] = Crumb{Name: folder.Name, Slug: FolderSlug(folder), Path: folder.Path}
+	}
+	return crumbs
+}
+
+// folderAncestors returns the folders leading from node to the note at slug
+func folderAncestors(node *TreeNode, slug string) ([]*TreeNode, bool) {
+	for _, child := range node.Children {
+		if !child.IsFolder {
+			if child.Note != nil && child.Note.Slug == slug {
+				return nil, true
+			}
+			continue
+		}
+		if ancestors, found := folderAncestors(child, slug); found {
+			return append([]*TreeNode{child}, ancestors...), true
+		}
+	}
+	return nil, false
+}
+
+// FolderBreadcrumbs returns the parent folders of a folder of the tree, from the top one.
+// Like the note breadcrumbs, the folder itself is not in them.
+func FolderBreadcrumbs(folder, tree *TreeNode) []Crumb {
+	if folder == nil || folder.Path == "" {
+		return nil
+	}
+	depth := strings.Count(folder.Path, "/") + 1
+	for node := range folder.AllNotes {
+		if node.Note.Permalink != "" {
+			continue
+		}
+		if crumbs := BreadcrumbsForSlug(node.Note.Slug, tree); len(crumbs) >= depth {
+			return crumbs[:depth-1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package engine
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/EwenQuim/pluie/model"
+)
+
+func TestBreadcrumbsForSlug(t *testing.T) {
+	tree := BuildTree([]model.Note{
+		{Title: "2024-05-01", Slug: "projects/client-x/meetings/2024-05-01", Path: "/Projects/Client X/Meetings/2024-05-01.md"},
+		{Title: "Q&A!", Slug: "study/q&a%21/q&a%21", Path: "/Study/Q&A!/Q&A!.md"},
+		{Title: "Projects", Slug: "projects/projects", Path: "/Projects/Projects.md"},
+		{Title: "Index", Slug: "index", Path: "/Index.md"},
+		{Title: "Old", Slug: "blog/my-old-url", Path: "/Projects/Old.md", Permalink: "blog/my-old-url"},
+		{Title: "About", Slug: "about-me", Path: "/Projects/Client X/About.md", Permalink: "about-me"},
+	})
+
+	tests := []struct {
+		name     string
+		slug     string
+		expected []Crumb
+	}{
+		{
+			name: "nested note",
+			slug: "projects/client-x/meetings/2024-05-01",
+			expected: []Crumb{
+				{Name: "Projects", Slug: "projects", Path: "Projects"},
+				{Name: "Client X", Slug: "projects/client-x", Path: "Projects/Client X"},
+				{Name: "Meetings", Slug: "projects/client-x/meetings", Path: "Projects/Client X/Meetings"},
+			},
+		},
+		{
+			name: "encoded segments and a note named like its folder",
+			slug: "study/q&a%21/q&a%21",
+			expected: []Crumb{
+				{Name: "Study", Slug: "study", Path: "Study"},
+				{Name: "Q&A!", Slug: "study/q&a%21", Path: "Study/Q&A!"},
+			},
+		},
+		{
+			name:     "note named like its folder",
+			slug:     "projects/projects",
+			expected: []Crumb{{Name: "Projects", Slug: "projects", Path: "Projects"}},
+		},
+		{
+			name:     "note outside the tree, segments decoded",
+			slug:     "archive/caf%C3%A9/menu",
+			expected: []Crumb{{Name: "archive", Slug: "archive"}, {Name: "café", Slug: "archive/caf%C3%A9"}},
+		},
+		{
+			name:     "trailing slash",
+			slug:     "projects/projects/",
+			expected: []Crumb{{Name: "Projects", Slug: "projects", Path: "Projects"}},
+		},
+		{
+			name:     "permalink, folders of the path",
+			slug:     "blog/my-old-url",
+			expected: []Crumb{{Name: "Projects", Slug: "projects", Path: "Projects"}},
+		},
+		{
+			name: "permalink at the root, folders of the path",
+			slug: "about-me",
+			expected: []Crumb{
+				{Name: "Projects", Slug: "projects", Path: "Projects"},
+				{Name: "Client X", Slug: "projects/client-x", Path: "Projects/Client X"},
+			},
+		},
+		{name: "root note", slug: "index", expected: nil},
+		{name: "empty slug", slug: "", expected: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := BreadcrumbsForSlug(tt.slug, tree); !slices.Equal(got, tt.expected) {
+				t.Errorf("BreadcrumbsForSlug(%q) = %+v, want %+v", tt.slug, got, tt.expected)
+			}
+		})
+	}
+
+	if got := BreadcrumbsForSlug("a/b", nil); !slices.Equal(got, []Crumb{{Name: "a", Slug: "a"}}) {
+		t.Errorf("BreadcrumbsForSlug() without tree = %+v", got)
+	}
+}
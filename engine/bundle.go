@@ -0,0 +1,100 @@
+package engine
+
+import (
+	"slices"
+
+	"github.com/EwenQuim/pluie/model"
+)
+
+// LinkedNotes returns the note at slug and the notes its wikilinks lead to, following up to
+// depth links away, resolved like BuildBackreferences. The start note comes first, then the
+// notes by distance. Their ReferencedBy only keeps the returned notes. Nil if slug is not a note.
+func LinkedNotes(notes []model.Note, slug string, depth int) []model.Note {
+	start := slices.IndexFunc(notes, func(note model.Note) bool { return note.Slug == slug })
+	if start < 0 {
+		return nil
+	}
+
+	aliases, _ := BuildAliasIndex(notes)
+	findNote := wikiLinkResolver(notes, aliases)
+
+	order := []*model.Note{&notes[start]}
+	seen := map[string]bool{slug: true}
+	frontier := order
+	for range max(depth, 0) {
+		var next []*model.Note
+		for _, source := range frontier {
+			for _, target := range wikiLinkTargets(*source) {
+				note, exists := findNote(target)
+				if !exists || seen[note.Slug] {
+					continue
+				}
+				seen[note.Slug] = true
+				next = append(next, note)
+			}
+		}
+		order = append(order, next...)
+		frontier = next
+	}
+
+	linked := make([]model.Note, len(order))
+	for i, note := range order {
+		linked[i] = *note
+		linked[i].ReferencedBy = slices.DeleteFunc(slices.Clone(note.ReferencedBy), func(reference model.NoteReference) bool {
+			return !seen[reference.Slug]
+		})
+	}
+	return linked
+}
+
+// SubsetTree returns a copy of the tree with only the given notes, which replace the ones of
+// the same slug, and the folders holding them
+func SubsetTree(root *TreeNode, notes []model.Note) *TreeNode {
+	bySlug := make(map[string]*model.Note, len(notes))
+	for i := range notes {
+		bySlug[notes[i].Slug] = &notes[i]
+	}
+
+	subset := subsetTree(root, bySlug)
+	if subset == nil && root != nil {
+		subset = &TreeNode{Name: root.Name, Path: root.Path, IsFolder: true, IsOpen: root.IsOpen, Children: []*TreeNode{}}
+	}
+	return subset
+}
+
+// subsetTree copies the node with the notes of bySlug, nil when it has none of them
+func subsetTree(node *TreeNode, bySlug map[string]*model.Note) *TreeNode {
+	if node == nil {
+		return nil
+	}
+	if !node.IsFolder {
+		if node.Note == nil {
+			return nil
+		}
+		note, ok := bySlug[node.Note.Slug]
+		if !ok {
+			return nil
+		}
+		return &TreeNode{Name: node.Name, Path: node.Path, Note: note, Children: []*TreeNode{}}
+	}
+
+	copy := &TreeNode{
+		Name:        node.Name,
+		Path:        node.Path,
+		IsFolder:    true,
+		IsOpen:      node.IsOpen,
+		Icon:        node.Icon,
+		Order:       node.Order,
+		Description: node.Description,
+		Children:    []*TreeNode{},
+	}
+	for _, child := range node.Children {
+		if childCopy := subsetTree(child, bySlug); childCopy != nil {
+			copy.Children = append(copy.Children, childCopy)
+		}
+	}
+	if len(copy.Children) == 0 {
+		return nil
+	}
+	return copy
+}
@@ -0,0 +1,76 @@
+package engine
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/EwenQuim/pluie/model"
+)
+
+func TestLinkedNotes(t *testing.T) {
+	notes := []model.Note{
+		{Slug: "start", Title: "Start", Path: "start.md", Content: "[[Second]], [[Missing]] and [[Start]]"},
+		{Slug: "topics/second", Title: "Second", Path: "topics/second.md", Content: "[[Third#Details]]", ReferencedBy: []model.NoteReference{{Slug: "start"}, {Slug: "other"}}},
+		{Slug: "third", Title: "Third", Path: "third.md", Content: "[[Pal]]"},
+		{Slug: "fourth", Title: "Fourth", Path: "fourth.md", Aliases: []string{"Pal"}},
+		{Slug: "other", Title: "Other", Path: "other.md", Content: "[[Second]]"},
+	}
+
+	slugs := func(notes []model.Note) []string {
+		var slugs []string
+		for _, note := range notes {
+			slugs = append(slugs, note.Slug)
+		}
+		return slugs
+	}
+
+	tests := []struct {
+		depth    int
+		expected []string
+	}{
+		{depth: 0, expected: []string{"start"}},
+		{depth: 1, expected: []string{"start", "topics/second"}},
+		{depth: 3, expected: []string{"start", "topics/second", "third", "fourth"}},
+	}
+	for _, tt := range tests {
+		if got := slugs(LinkedNotes(notes, "start", tt.depth)); !slices.Equal(got, tt.expected) {
+			t.Errorf("LinkedNotes(depth %d) = %v, want %v", tt.depth, got, tt.expected)
+		}
+	}
+
+	linked := LinkedNotes(notes, "start", 1)
+	if len(linked[1].ReferencedBy) != 1 || linked[1].ReferencedBy[0].Slug != "start" {
+		t.Errorf("ReferencedBy = %v, want only the exported start note", linked[1].ReferencedBy)
+	}
+	if len(notes[1].ReferencedBy) != 2 {
+		t.Error("LinkedNotes should not change the notes given")
+	}
+	if LinkedNotes(notes, "missing", 1) != nil {
+		t.Error("expected nil for an unknown slug")
+	}
+}
+
+func TestSubsetTree(t *testing.T) {
+	notes := []model.Note{
+		{Slug: "start", Path: "start.md", Title: "Start"},
+		{Slug: "topics/second", Path: "topics/second.md", Title: "Second"},
+		{Slug: "archive/old", Path: "archive/old.md", Title: "Old"},
+	}
+	tree := BuildTree(notes)
+
+	subset := SubsetTree(tree, notes[:2])
+	var got []string
+	for node := range subset.AllNotes {
+		got = append(got, node.Note.Slug)
+	}
+	slices.Sort(got)
+	if !slices.Equal(got, []string{"start", "topics/second"}) {
+		t.Errorf("SubsetTree notes = %v", got)
+	}
+	if FindFolderInTree(subset, "archive") != nil {
+		t.Error("folders without exported notes should be left out")
+	}
+	if len(GetAllNotesFromTree(tree)) != 3 {
+		t.Error("SubsetTree should not change the tree given")
+	}
+}
@@ -0,0 +1,119 @@
+package engine
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/EwenQuim/pluie/model"
+)
+
+// Chunk sizes, in bytes: about 500 tokens with 4 characters per token, and about 50 tokens
+// shared by two chunks of a long section, so a sentence cut by one is whole in the other
+const (
+	ChunkMaxChars     = 2000
+	ChunkOverlapChars = 200
+)
+
+// Chunk is a part of a note embedded on its own, so semantic search finds the section matching
+// a query rather than the note as a whole
+type Chunk struct {
+	Heading string // Heading of the section of the chunk, empty before the first heading
+	Start   int    // Byte offset of the chunk in the content of the note
+	End     int    // Byte offset of the end of the chunk, excluded
+	Text    string // content[Start:End]
+}
+
+// ChunkMatch is a note found by semantic search, with its chunk closest to the query
+type ChunkMatch struct {
+	Note  model.Note
+	Chunk Chunk
+}
+
+// ChunkContent splits markdown content into chunks: one per section, see SplitSections, and
+// sections longer than ChunkMaxChars in windows overlapping by ChunkOverlapChars, cut between
+// words. Sections without text have no chunk, their heading is in the chunks of the note.
+func ChunkContent(content string) []Chunk {
+	lineStarts := []int{0}
+	for i := range len(content) {
+		if content[i] == '\n' {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+	lineStart := func(line int) int {
+		if line >= len(lineStarts) {
+			return len(content)
+		}
+		return lineStarts[line]
+	}
+
+	sections := SplitSections(content)
+	var chunks []Chunk
+	for i, section := range sections {
+		start, end := 0, len(content)
+		if section.Level > 0 {
+			start = lineStart(section.LineNum + 1)
+		}
+		if i+1 < len(sections) {
+			end = lineStart(sections[i+1].LineNum)
+		}
+		chunks = appendChunks(chunks, content, section.Heading, start, end)
+	}
+	return chunks
+}
+
+// appendChunks appends the chunks of content[start:end], the text of a section
+func appendChunks(chunks []Chunk, content, heading string, start, end int) []Chunk {
+	start, end = trimSpaceBounds(content, start, end)
+	for start < end {
+		chunkEnd := end
+		if end-start > ChunkMaxChars {
+			chunkEnd = wordBoundaryBefore(content, start+ChunkMaxChars, start+ChunkMaxChars/2)
+		}
+		chunkStart, chunkEnd := trimSpaceBounds(content, start, chunkEnd)
+		chunks = append(chunks, Chunk{Heading: heading, Start: chunkStart, End: chunkEnd, Text: content[chunkStart:chunkEnd]})
+		if chunkEnd >= end {
+			break
+		}
+
+		// The next chunk starts with the last words of this one, and always after its start
+		next := wordBoundaryAfter(content, chunkEnd-ChunkOverlapChars, chunkEnd)
+		start = max(next, start+1)
+		for start < end && !utf8.RuneStart(content[start]) {
+			start++
+		}
+	}
+	return chunks
+}
+
+// wordBoundaryBefore returns the offset of the last space of content between floor and offset,
+// or the rune boundary before offset when there is none
+func wordBoundaryBefore(content string, offset, floor int) int {
+	if i := strings.LastIndexFunc(content[floor:offset], unicode.IsSpace); i >= 0 {
+		return floor + i
+	}
+	for offset > floor && !utf8.RuneStart(content[offset]) {
+		offset--
+	}
+	return offset
+}
+
+// wordBoundaryAfter returns the offset after the first space of content between offset and
+// ceiling, or offset when there is none
+func wordBoundaryAfter(content string, offset, ceiling int) int {
+	for offset > 0 && !utf8.RuneStart(content[offset]) {
+		offset--
+	}
+	if i := strings.IndexFunc(content[offset:ceiling], unicode.IsSpace); i >= 0 {
+		return offset + i + 1
+	}
+	return offset
+}
+
+// trimSpaceBounds moves start and end of content[start:end] inwards, past its spaces
+func trimSpaceBounds(content string, start, end int) (int, int) {
+	text := content[start:end]
+	trimmedLeft := strings.TrimLeftFunc(text, unicode.IsSpace)
+	start += len(text) - len(trimmedLeft)
+	return start, start + len(strings.TrimRightFunc(trimmedLeft, unicode.IsSpace))
+}
@@ -0,0 +1,66 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestChunkContent_Sections(t *testing.T) {
+	content := "Intro text\n\n# Setup\n\nInstall it.\n\n## Empty\n\n## Usage\n```\n# not a heading\n```\nRun it.\n"
+
+	chunks := ChunkContent(content)
+	want := []struct{ heading, text string }{
+		{"", "Intro text"},
+		{"Setup", "Install it."},
+		{"Usage", "```\n# not a heading\n```\nRun it."},
+	}
+	if len(chunks) != len(want) {
+		t.Fatalf("ChunkContent() = %+v, want %d chunks", chunks, len(want))
+	}
+	for i, chunk := range chunks {
+		if chunk.Heading != want[i].heading || chunk.Text != want[i].text {
+			t.Errorf("chunk %d = %q %q, want %q %q", i, chunk.Heading, chunk.Text, want[i].heading, want[i].text)
+		}
+		if content[chunk.Start:chunk.End] != chunk.Text {
+			t.Errorf("chunk %d offsets %d:%d = %q, want its text", i, chunk.Start, chunk.End, content[chunk.Start:chunk.End])
+		}
+	}
+
+	if chunks := ChunkContent("  \n# Only a heading\n"); len(chunks) != 0 {
+		t.Errorf("ChunkContent() without text = %+v, want no chunk", chunks)
+	}
+}
+
+func TestChunkContent_LongSection(t *testing.T) {
+	var words []string
+	for i := range 1000 {
+		words = append(words, []string{"alpha", "béta", "gamma", "delta"}[i%4])
+	}
+	content := "# Long\n" + strings.Join(words, " ")
+
+	chunks := ChunkContent(content)
+	if len(chunks) < 3 {
+		t.Fatalf("ChunkContent() = %d chunks, want the long section windowed", len(chunks))
+	}
+	for i, chunk := range chunks {
+		if chunk.Heading != "Long" || content[chunk.Start:chunk.End] != chunk.Text {
+			t.Errorf("chunk %d = %q at %d:%d, want the heading and its offsets", i, chunk.Heading, chunk.Start, chunk.End)
+		}
+		if len(chunk.Text) > ChunkMaxChars || !utf8.ValidString(chunk.Text) {
+			t.Errorf("chunk %d is %d bytes or cuts a rune", i, len(chunk.Text))
+		}
+		if (chunk.Start > 0 && content[chunk.Start-1] != ' ' && content[chunk.Start-1] != '\n') || (chunk.End < len(content) && content[chunk.End] != ' ') {
+			t.Errorf("chunk %d should be cut between words: %q", i, chunk.Text)
+		}
+		if i > 0 {
+			overlap := chunks[i-1].End - chunk.Start
+			if overlap <= 0 || overlap > ChunkOverlapChars {
+				t.Errorf("chunks %d and %d overlap by %d bytes, want up to %d", i-1, i, overlap, ChunkOverlapChars)
+			}
+		}
+	}
+	if last := chunks[len(chunks)-1]; last.End != len(content) {
+		t.Errorf("last chunk ends at %d, want the end of the content %d", last.End, len(content))
+	}
+}
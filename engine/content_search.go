@@ -0,0 +1,200 @@
+package engine
+
+import (
+	"cmp"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/EwenQuim/pluie/model"
+)
+
+// Snippet sizes of content matches, in bytes
+const (
+	contentSnippetBefore = 60  // Text kept before the match
+	contentSnippetLength = 160 // Whole snippet
+)
+
+// Score of content matches: exact words count more than words merely starting with a query word,
+// and finding the whole query as written beats any word count
+const (
+	contentScoreWord   = 2
+	contentScorePrefix = 1
+	contentScorePhrase = 50
+)
+
+// ContentMatch is a note whose body matches a content search
+type ContentMatch struct {
+	Note    model.Note
+	Snippet string // Plain text around the first match, on one line
+	Start   int    // Byte offset of the highlighted match in Snippet
+	End     int    // Byte offset of the end of the highlighted match in Snippet
+	Score   int    // Higher is better
+}
+
+// contentPosting is a note containing a word of the index
+type contentPosting struct {
+	note  uint32 // Index in ContentIndex.notes
+	count uint32 // Occurrences of the word in the note
+}
+
+// ContentIndex is an inverted index of the words of the note bodies, for content search
+type ContentIndex struct {
+	notes    []model.Note
+	texts    []string           // Lowercase plain text of the notes, indexed like notes
+	words    []string           // Sorted vocabulary, lowercase
+	postings [][]contentPosting // Notes of each word, indexed like words
+}
+
+// BuildContentIndex indexes the words of the plain text of the notes
+func BuildContentIndex(notes []model.Note) *ContentIndex {
+	counts := make(map[string]map[uint32]uint32)
+	texts := make([]string, len(notes))
+	for i, note := range notes {
+		texts[i] = strings.ToLower(PlainText(note.Content))
+		for _, word := range contentWords(texts[i]) {
+			if counts[word] == nil {
+				counts[word] = make(map[uint32]uint32)
+			}
+			counts[word][uint32(i)]++
+		}
+	}
+
+	index := &ContentIndex{notes: notes, texts: texts, words: make([]string, 0, len(counts))}
+	for word := range counts {
+		index.words = append(index.words, word)
+	}
+	slices.Sort(index.words)
+
+	index.postings = make([][]contentPosting, len(index.words))
+	for i, word := range index.words {
+		postings := make([]contentPosting, 0, len(counts[word]))
+		for note, count := range counts[word] {
+			postings = append(postings, contentPosting{note: note, count: count})
+		}
+		slices.SortFunc(postings, func(a, b contentPosting) int { return cmp.Compare(a.note, b.note) })
+		index.postings[i] = postings
+	}
+	return index
+}
+
+// Search returns the notes containing every word of the query, or a word starting with it,
+// best first. maxResults 0 means no limit.
+func (index *ContentIndex) Search(query string, maxResults int) []ContentMatch {
+	terms := slices.Compact(slices.Sorted(slices.Values(contentWords(query))))
+	if index == nil || len(terms) == 0 {
+		return nil
+	}
+
+	var scores map[uint32]int
+	for i, term := range terms {
+		termScores := make(map[uint32]int)
+		// Words starting with the term are contiguous in the sorted vocabulary
+		for w := sort.SearchStrings(index.words, term); w < len(index.words) && strings.HasPrefix(index.words[w], term); w++ {
+			weight := contentScorePrefix
+			if index.words[w] == term {
+				weight = contentScoreWord
+			}
+			for _, posting := range index.postings[w] {
+				termScores[posting.note] += weight * int(posting.count)
+			}
+		}
+
+		// Notes must match every term
+		if i == 0 {
+			scores = termScores
+			continue
+		}
+		for note, score := range scores {
+			if termScore, ok := termScores[note]; ok {
+				scores[note] = score + termScore
+			} else {
+				delete(scores, note)
+			}
+		}
+	}
+
+	phrase := strings.Join(strings.Fields(strings.ToLower(query)), " ")
+	matches := make([]ContentMatch, 0, len(scores))
+	for noteIndex, score := range scores {
+		if len(terms) > 1 && strings.Contains(index.texts[noteIndex], phrase) {
+			score += contentScorePhrase
+		}
+		matches = append(matches, ContentMatch{Note: index.notes[noteIndex], Score: score})
+	}
+
+	slices.SortFunc(matches, func(a, b ContentMatch) int {
+		if a.Score != b.Score {
+			return b.Score - a.Score
+		}
+		return strings.Compare(a.Note.Slug, b.Note.Slug)
+	})
+	if maxResults > 0 && len(matches) > maxResults {
+		matches = matches[:maxResults]
+	}
+
+	// Snippets of the results only, on the phrase or else on the first word found
+	phraseRegex := regexp.MustCompile("(?i)" + regexp.QuoteMeta(phrase))
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = regexp.QuoteMeta(term)
+	}
+	termsRegex := regexp.MustCompile("(?i)" + strings.Join(quoted, "|"))
+	for i := range matches {
+		match := &matches[i]
+		var found bool
+		if match.Snippet, match.Start, match.End, found = contentSnippet(match.Note.Content, phraseRegex); !found {
+			match.Snippet, match.Start, match.End, _ = contentSnippet(match.Note.Content, termsRegex)
+		}
+	}
+	return matches
+}
+
+// contentSnippet returns the snippet of the first line of content whose plain text matches re,
+// with the offsets of the match in it
+func contentSnippet(content string, re *regexp.Regexp) (snippet string, start, end int, found bool) {
+	for line := range strings.SplitSeq(content, "\n") {
+		text := PlainText(line)
+		loc := re.FindStringIndex(text)
+		if loc == nil {
+			continue
+		}
+		snippet, start, end = snippetAround(text, loc[0], loc[1])
+		return snippet, start, end, true
+	}
+	return "", 0, 0, false
+}
+
+// snippetAround returns the snippet of the line of text around text[matchStart:matchEnd], with
+// the offsets of the match in it
+func snippetAround(text string, matchStart, matchEnd int) (snippet string, start, end int) {
+	from := max(0, matchStart-contentSnippetBefore)
+	to := min(len(text), max(matchEnd, from+contentSnippetLength))
+	// Cut on character boundaries
+	for from > 0 && !utf8.RuneStart(text[from]) {
+		from--
+	}
+	for to < len(text) && !utf8.RuneStart(text[to]) {
+		to++
+	}
+
+	snippet, start = text[from:to], matchStart-from
+	if from > 0 {
+		snippet = "…" + snippet
+		start += len("…")
+	}
+	if to < len(text) {
+		snippet += "…"
+	}
+	return snippet, start, start + matchEnd - matchStart
+}
+
+// contentWords splits text into lowercase words of letters and digits
+func contentWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
@@ -0,0 +1,101 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/EwenQuim/pluie/model"
+)
+
+func TestContentIndexSearch(t *testing.T) {
+	index := BuildContentIndex([]model.Note{
+		{Slug: "bread", Content: "Sourdough bread needs a starter.\n\nThe starter feeds on flour and water."},
+		{Slug: "pizza", Content: "Pizza dough is like bread dough, with more water."},
+		{Slug: "links", Content: "See [the docs](https://example.com/sourdough) for more."},
+		{Slug: "starters", Content: "Starters and **sourdough starter** recipes."},
+	})
+
+	slugs := func(matches []ContentMatch) string {
+		var result []string
+		for _, match := range matches {
+			result = append(result, match.Note.Slug)
+		}
+		return strings.Join(result, " ")
+	}
+
+	tests := []struct {
+		name     string
+		query    string
+		expected string
+	}{
+		{name: "every word must match", query: "bread water", expected: "bread pizza"},
+		{name: "the phrase wins", query: "sourdough starter", expected: "starters bread"},
+		{name: "word prefixes", query: "dou", expected: "pizza"},
+		{name: "case insensitive", query: "PIZZA", expected: "pizza"},
+		{name: "link targets are not text", query: "example", expected: ""},
+		{name: "no words", query: " ?! ", expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := slugs(index.Search(tt.query, 0)); got != tt.expected {
+				t.Errorf("Search(%q) = %q, want %q", tt.query, got, tt.expected)
+			}
+		})
+	}
+
+	if matches := index.Search("water", 1); len(matches) != 1 {
+		t.Errorf("expected 1 match with maxResults 1, got %d", len(matches))
+	}
+	var nilIndex *ContentIndex
+	if matches := nilIndex.Search("water", 0); matches != nil {
+		t.Errorf("a nil index should match nothing, got %v", matches)
+	}
+}
+
+func TestContentIndexSearch_Snippet(t *testing.T) {
+	long := strings.Repeat("é", 50) + " the needle is here " + strings.Repeat("word ", 50)
+	index := BuildContentIndex([]model.Note{
+		{Slug: "short", Content: "# Title\n\nSome **bold** text."},
+		{Slug: "long", Content: long},
+	})
+
+	match := index.Search("bold text", 0)[0]
+	if match.Snippet != "Some bold text." || match.Snippet[match.Start:match.End] != "bold text" {
+		t.Errorf("unexpected snippet %q [%d:%d]", match.Snippet, match.Start, match.End)
+	}
+
+	match = index.Search("needle", 0)[0]
+	if match.Snippet[match.Start:match.End] != "needle" {
+		t.Errorf("the match should be highlighted, got %q", match.Snippet[match.Start:match.End])
+	}
+	if !strings.HasPrefix(match.Snippet, "…é") || !strings.HasSuffix(match.Snippet, "…") {
+		t.Errorf("long lines should be cut on characters, got %q", match.Snippet)
+	}
+}
+
+func BenchmarkContentIndexSearch(b *testing.B) {
+	words := []string{"meeting", "acme", "corp", "project", "notes", "garden", "recipe", "weekly", "review", "ideas"}
+	notes := make([]model.Note, 0, 2500)
+	for i := range 2500 {
+		var content strings.Builder
+		for j := range 200 {
+			content.WriteString(words[(i*7+j*3)%10])
+			content.WriteString(fmt.Sprintf(" w%d ", (i+j)%1000))
+			if j%20 == 19 {
+				content.WriteString("\n")
+			}
+		}
+		notes = append(notes, model.Note{Slug: fmt.Sprintf("note-%d", i), Content: content.String()})
+	}
+	index := BuildContentIndex(notes)
+
+	for _, query := range []string{"garden", "acme corp", "w12"} {
+		b.Run(query, func(b *testing.B) {
+			for b.Loop() {
+				index.Search(query, 5)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package engine
+
+import (
+	"strings"
+
+	"github.com/EwenQuim/pluie/model"
+)
+
+// coverKeys are the frontmatter keys of the cover image of a note, by priority
+var coverKeys = []string{"cover", "image"}
+
+// CoverTarget returns the cover image of a note from its "cover" or "image" frontmatter:
+// a URL, a site path like "/static/cover.png", or an attachment written like an embed,
+// "[[photo.png]]", or with its path, "images/photo.png". Empty when the note has none.
+func CoverTarget(metadata map[string]any) string {
+	for _, key := range coverKeys {
+		value, ok := metadata[key].(string)
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		value = strings.TrimPrefix(value, "!")
+		if inner, ok := strings.CutPrefix(value, "[["); ok {
+			value, _, _ = strings.Cut(strings.TrimSuffix(inner, "]]"), "|")
+			value = attachmentTarget(value)
+		}
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// CoverURL returns the URL of the cover image of a note, see CoverTarget: URLs and site
+// paths as they are, attachments at the URL they are served at. Empty when the note has
+// no cover or its attachment does not exist.
+func CoverURL(note model.Note) string {
+	target := CoverTarget(note.Metadata)
+	switch {
+	case target == "":
+		return ""
+	case strings.HasPrefix(target, "https://") || strings.HasPrefix(target, "http://"):
+		return target
+	}
+	if vaultPath, ok := note.Attachments[target]; ok {
+		return AttachmentURL(vaultPath)
+	}
+	if strings.HasPrefix(target, "/") {
+		return target
+	}
+	return ""
+}
@@ -0,0 +1,34 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/EwenQuim/pluie/model"
+)
+
+func TestCoverURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata map[string]any
+		expected string
+	}{
+		{name: "no cover", metadata: nil, expected: ""},
+		{name: "absolute URL", metadata: map[string]any{"cover": "https://example.com/cover.png"}, expected: "https://example.com/cover.png"},
+		{name: "site path", metadata: map[string]any{"image": "/static/cover.png"}, expected: "/static/cover.png"},
+		{name: "embed of an attachment", metadata: map[string]any{"cover": "![[beach.png|300]]"}, expected: "/-/attachments/photos/beach.png"},
+		{name: "relative path of an attachment", metadata: map[string]any{"cover": "photos/beach.png"}, expected: "/-/attachments/photos/beach.png"},
+		{name: "cover before image", metadata: map[string]any{"cover": "[[beach.png]]", "image": "/static/other.png"}, expected: "/-/attachments/photos/beach.png"},
+		{name: "missing attachment", metadata: map[string]any{"cover": "[[missing.png]]"}, expected: ""},
+		{name: "not a string", metadata: map[string]any{"cover": 3, "image": "/static/cover.png"}, expected: "/static/cover.png"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notes := []model.Note{{Path: "trip.md", Metadata: tt.metadata}}
+			ResolveAttachmentEmbeds(notes, []string{"photos/beach.png"})
+			if got := CoverURL(notes[0]); got != tt.expected {
+				t.Errorf("CoverURL() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
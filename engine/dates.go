@@ -0,0 +1,77 @@
+package engine
+
+import (
+	"strings"
+	"time"
+)
+
+// Date display formats, shared by every page showing a date
+const (
+	DateFormat     = "Jan 2, 2006"
+	DateTimeFormat = "Jan 2, 2006 15:04 MST"
+)
+
+// dateLayouts are the accepted frontmatter date formats, tried in order
+var dateLayouts = []struct {
+	layout   string
+	dateOnly bool
+}{
+	{layout: time.RFC3339Nano},
+	{layout: "2006-01-02T15:04:05"},
+	{layout: "2006-01-02 15:04:05"},
+	{layout: "2006-01-02T15:04"},
+	{layout: "2006-01-02 15:04"},
+	{layout: "2006-01-02", dateOnly: true},
+}
+
+// ParseDate parses a frontmatter date value (string or time.Time) into the given location.
+// Values without an explicit offset are assumed to be in that location.
+// dateOnly reports whether the value had no time part.
+func ParseDate(value any, loc *time.Location) (t time.Time, dateOnly bool, ok bool) {
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	switch v := value.(type) {
+	case time.Time:
+		return v.In(loc), false, true
+	case string:
+		str := strings.TrimSpace(v)
+		for _, candidate := range dateLayouts {
+			if parsed, err := time.ParseInLocation(candidate.layout, str, loc); err == nil {
+				return parsed.In(loc), candidate.dateOnly, true
+			}
+		}
+	}
+
+	return time.Time{}, false, false
+}
+
+// FormatDate formats the calendar day of t in the given location, like "Jan 2, 2006"
+func FormatDate(t time.Time, loc *time.Location) string {
+	return inLocation(t, loc).Format(DateFormat)
+}
+
+// FormatDateTime formats t in the given location with its zone abbreviation
+func FormatDateTime(t time.Time, loc *time.Location) string {
+	return inLocation(t, loc).Format(DateTimeFormat)
+}
+
+// FormatRFC3339 formats t in the given location as RFC3339, always with an explicit offset.
+// Used for JSON outputs, Atom feeds and <time datetime> attributes.
+func FormatRFC3339(t time.Time, loc *time.Location) string {
+	return inLocation(t, loc).Format("2006-01-02T15:04:05-07:00")
+}
+
+// FormatRFC822 formats t in the given location for RSS feeds (RFC 1123 with numeric zone)
+func FormatRFC822(t time.Time, loc *time.Location) string {
+	return inLocation(t, loc).Format(time.RFC1123Z)
+}
+
+// inLocation converts t to loc, defaulting to UTC
+func inLocation(t time.Time, loc *time.Location) time.Time {
+	if loc == nil {
+		loc = time.UTC
+	}
+	return t.In(loc)
+}
@@ -0,0 +1,157 @@
+package engine
+
+import (
+	"testing"
+	"time"
+)
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatalf("LoadLocation(%q) error: %v", name, err)
+	}
+	return loc
+}
+
+func TestFormatDates(t *testing.T) {
+	// 2024-03-10 23:30 UTC is already March 11 in Paris (UTC+1) and Kolkata (UTC+5:30)
+	instant := time.Date(2024, 3, 10, 23, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		zone             string
+		expectedDate     string
+		expectedDateTime string
+		expectedRFC3339  string
+		expectedRFC822   string
+	}{
+		{
+			zone:             "UTC",
+			expectedDate:     "Mar 10, 2024",
+			expectedDateTime: "Mar 10, 2024 23:30 UTC",
+			expectedRFC3339:  "2024-03-10T23:30:00+00:00",
+			expectedRFC822:   "Sun, 10 Mar 2024 23:30:00 +0000",
+		},
+		{
+			zone:             "Europe/Paris",
+			expectedDate:     "Mar 11, 2024",
+			expectedDateTime: "Mar 11, 2024 00:30 CET",
+			expectedRFC3339:  "2024-03-11T00:30:00+01:00",
+			expectedRFC822:   "Mon, 11 Mar 2024 00:30:00 +0100",
+		},
+		{
+			zone:             "Asia/Kolkata",
+			expectedDate:     "Mar 11, 2024",
+			expectedDateTime: "Mar 11, 2024 05:00 IST",
+			expectedRFC3339:  "2024-03-11T05:00:00+05:30",
+			expectedRFC822:   "Mon, 11 Mar 2024 05:00:00 +0530",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.zone, func(t *testing.T) {
+			loc := mustLoadLocation(t, tt.zone)
+
+			if got := FormatDate(instant, loc); got != tt.expectedDate {
+				t.Errorf("FormatDate() = %q, want %q", got, tt.expectedDate)
+			}
+			if got := FormatDateTime(instant, loc); got != tt.expectedDateTime {
+				t.Errorf("FormatDateTime() = %q, want %q", got, tt.expectedDateTime)
+			}
+			if got := FormatRFC3339(instant, loc); got != tt.expectedRFC3339 {
+				t.Errorf("FormatRFC3339() = %q, want %q", got, tt.expectedRFC3339)
+			}
+			if got := FormatRFC822(instant, loc); got != tt.expectedRFC822 {
+				t.Errorf("FormatRFC822() = %q, want %q", got, tt.expectedRFC822)
+			}
+		})
+	}
+
+	t.Run("nil location is UTC", func(t *testing.T) {
+		if got := FormatRFC3339(instant, nil); got != "2024-03-10T23:30:00+00:00" {
+			t.Errorf("FormatRFC3339() = %q", got)
+		}
+	})
+}
+
+func TestParseDate(t *testing.T) {
+	paris := mustLoadLocation(t, "Europe/Paris")
+	kolkata := mustLoadLocation(t, "Asia/Kolkata")
+
+	tests := []struct {
+		name         string
+		value        any
+		loc          *time.Location
+		expected     time.Time
+		expectedOnly bool
+		expectedOK   bool
+	}{
+		{
+			name:         "Date only assumes the site timezone",
+			value:        "2024-03-11",
+			loc:          paris,
+			expected:     time.Date(2024, 3, 10, 23, 0, 0, 0, time.UTC),
+			expectedOnly: true,
+			expectedOK:   true,
+		},
+		{
+			name:       "Datetime without offset assumes the site timezone",
+			value:      "2024-03-11 08:15",
+			loc:        kolkata,
+			expected:   time.Date(2024, 3, 11, 2, 45, 0, 0, time.UTC),
+			expectedOK: true,
+		},
+		{
+			name:       "Datetime with T separator and seconds",
+			value:      "2024-07-01T12:00:00",
+			loc:        paris,
+			expected:   time.Date(2024, 7, 1, 10, 0, 0, 0, time.UTC),
+			expectedOK: true,
+		},
+		{
+			name:       "Explicit offset wins over the site timezone",
+			value:      "2024-03-11T08:00:00Z",
+			loc:        paris,
+			expected:   time.Date(2024, 3, 11, 8, 0, 0, 0, time.UTC),
+			expectedOK: true,
+		},
+		{
+			name:       "time.Time value",
+			value:      time.Date(2024, 3, 11, 8, 0, 0, 0, time.UTC),
+			loc:        kolkata,
+			expected:   time.Date(2024, 3, 11, 8, 0, 0, 0, time.UTC),
+			expectedOK: true,
+		},
+		{
+			name:  "Invalid date",
+			value: "2024-13-45",
+			loc:   paris,
+		},
+		{
+			name:  "Not a date",
+			value: 42,
+			loc:   paris,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, dateOnly, ok := ParseDate(tt.value, tt.loc)
+			if ok != tt.expectedOK {
+				t.Fatalf("ParseDate(%v) ok = %v, want %v", tt.value, ok, tt.expectedOK)
+			}
+			if !ok {
+				return
+			}
+			if !got.Equal(tt.expected) {
+				t.Errorf("ParseDate(%v) = %v, want %v", tt.value, got, tt.expected)
+			}
+			if got.Location() != tt.loc {
+				t.Errorf("ParseDate(%v) location = %v, want %v", tt.value, got.Location(), tt.loc)
+			}
+			if dateOnly != tt.expectedOnly {
+				t.Errorf("ParseDate(%v) dateOnly = %v, want %v", tt.value, dateOnly, tt.expectedOnly)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/EwenQuim/pluie/model"
+)
+
+// Diagnostic kinds
+const (
+	DiagnosticDuplicate = "duplicate"
+	DiagnosticStatus    = "status"
+	DiagnosticOrder     = "order"
+)
+
+// Diagnostic is a problem found in the vault, shown on /-/diagnostics and by -mode check
+type Diagnostic struct {
+	Kind    string
+	Message string
+	Paths   []string // Files concerned, relative to the vault
+	Slugs   []string // Slugs of the notes concerned, in the same order as Paths
+}
+
+// BuildDiagnostics runs every vault check on the given notes
+func BuildDiagnostics(notes []model.Note) []Diagnostic {
+	var diagnostics []Diagnostic
+	diagnostics = append(diagnostics, DuplicateDiagnostics(FindDuplicates(notes, DefaultDuplicateOptions()))...)
+	diagnostics = append(diagnostics, StatusDiagnostics(notes)...)
+	diagnostics = append(diagnostics, OrderDiagnostics(notes)...)
+	return diagnostics
+}
+
+// DuplicateDiagnostics reports one diagnostic per near-duplicate pair
+func DuplicateDiagnostics(groups []DuplicateGroup) []Diagnostic {
+	var diagnostics []Diagnostic
+	for _, group := range groups {
+		for _, pair := range group.Pairs {
+			diagnostics = append(diagnostics, Diagnostic{
+				Kind:    DiagnosticDuplicate,
+				Message: fmt.Sprintf("Near-duplicate notes (%d%% similar)", int(math.Round(pair.Similarity*100))),
+				Paths:   []string{pair.A.Path, pair.B.Path},
+				Slugs:   []string{pair.A.Slug, pair.B.Slug},
+			})
+		}
+	}
+	return diagnostics
+}
@@ -0,0 +1,171 @@
+package engine
+
+import "strings"
+
+// DiffOp is what a line of a diff does
+type DiffOp int
+
+const (
+	DiffEqual  DiffOp = iota // In both texts
+	DiffDelete               // Only in the old text
+	DiffInsert               // Only in the new text
+)
+
+// DiffLine is a line of the diff of two texts
+type DiffLine struct {
+	Op      DiffOp
+	Text    string
+	OldLine int // Line number in the old text, from 1, 0 for inserted lines
+	NewLine int // Line number in the new text, from 1, 0 for deleted lines
+}
+
+// maxDiffEdits bounds the work of DiffLines: texts further apart are shown as all their
+// different lines deleted then inserted
+const maxDiffEdits = 2000
+
+// DiffLines returns the line diff of two texts turning old into new, with the fewest deleted
+// and inserted lines (Myers' algorithm), every line of both texts in order
+func DiffLines(old, new string) []DiffLine {
+	a, b := splitLines(old), splitLines(new)
+
+	// Edits are usually local, the lines around them are left out of the search
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ops := make([]DiffOp, 0, len(a)+len(b))
+	for range prefix {
+		ops = append(ops, DiffEqual)
+	}
+	ops = append(ops, myersDiff(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for range suffix {
+		ops = append(ops, DiffEqual)
+	}
+
+	lines := make([]DiffLine, 0, len(ops))
+	i, j := 0, 0
+	for _, op := range ops {
+		switch op {
+		case DiffEqual:
+			lines = append(lines, DiffLine{Op: op, Text: a[i], OldLine: i + 1, NewLine: j + 1})
+			i++
+			j++
+		case DiffDelete:
+			lines = append(lines, DiffLine{Op: op, Text: a[i], OldLine: i + 1})
+			i++
+		case DiffInsert:
+			lines = append(lines, DiffLine{Op: op, Text: b[j], NewLine: j + 1})
+			j++
+		}
+	}
+	return lines
+}
+
+// DiffChanged reports whether a diff has deleted or inserted lines
+func DiffChanged(lines []DiffLine) bool {
+	for _, line := range lines {
+		if line.Op != DiffEqual {
+			return true
+		}
+	}
+	return false
+}
+
+// splitLines splits text into lines, without their line breaks. Empty text has no lines.
+func splitLines(text string) []string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// myersDiff returns the operations turning a into b, deletions before insertions, see
+// "An O(ND) Difference Algorithm and Its Variations" by Eugene W. Myers. Past maxDiffEdits, all
+// of a is deleted then all of b inserted.
+func myersDiff(a, b []string) []DiffOp {
+	n, m := len(a), len(b)
+	offset := n + m + 1
+	v := make([]int, 2*offset+1) // Furthest x on each diagonal k = x - y, at v[offset+k]
+	var trace [][]int            // v before each round d, for the diagonals -d-1 to d+1
+
+	for d := 0; d <= n+m; d++ {
+		if d > maxDiffEdits {
+			return replaceAll(n, m)
+		}
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1] // Down from diagonal k+1: insertion
+			} else {
+				x = v[offset+k-1] + 1 // Right from diagonal k-1: deletion
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrackDiff(trace, n, m)
+			}
+		}
+	}
+	return replaceAll(n, m)
+}
+
+// backtrackDiff follows the trace of myersDiff back from the end of both texts
+func backtrackDiff(trace [][]int, n, m int) []DiffOp {
+	var reversed []DiffOp
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		at := func(k int) int { return v[k+d+1] }
+
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			reversed = append(reversed, DiffEqual)
+			x--
+			y--
+		}
+		if d > 0 {
+			if x == prevX {
+				reversed = append(reversed, DiffInsert)
+			} else {
+				reversed = append(reversed, DiffDelete)
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	ops := make([]DiffOp, len(reversed))
+	for i, op := range reversed {
+		ops[len(reversed)-1-i] = op
+	}
+	return ops
+}
+
+// replaceAll returns the operations deleting n lines then inserting m lines
+func replaceAll(n, m int) []DiffOp {
+	ops := make([]DiffOp, 0, n+m)
+	for range n {
+		ops = append(ops, DiffDelete)
+	}
+	for range m {
+		ops = append(ops, DiffInsert)
+	}
+	return ops
+}
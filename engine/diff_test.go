@@ -0,0 +1,93 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+)
+
+// applyDiff rebuilds both texts from a diff
+func applyDiff(lines []DiffLine) (old, new []string) {
+	for _, line := range lines {
+		if line.Op != DiffInsert {
+			old = append(old, line.Text)
+		}
+		if line.Op != DiffDelete {
+			new = append(new, line.Text)
+		}
+	}
+	return old, new
+}
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name    string
+		old     string
+		new     string
+		want    string // One character per line: = kept, - deleted, + inserted
+		changed bool
+	}{
+		{name: "same", old: "a\nb\n", new: "a\nb", want: "==", changed: false},
+		{name: "empty", old: "", new: "", want: "", changed: false},
+		{name: "created", old: "", new: "a\nb\n", want: "++", changed: true},
+		{name: "emptied", old: "a\nb\n", new: "", want: "--", changed: true},
+		{name: "edited line", old: "a\nb\nc\n", new: "a\nB\nc\n", want: "=-+=", changed: true},
+		{name: "moved line", old: "a\nb\nc\nd\n", new: "b\nc\nd\na\n", want: "-===+", changed: true},
+		{name: "crlf", old: "a\r\nb\r\n", new: "a\nb\n", want: "==", changed: false},
+		{name: "apart", old: "a\nb\nc\nx\ny", new: "b\nz\nc\ny\nw", want: "-=+=-=+", changed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := DiffLines(tt.old, tt.new)
+
+			var ops strings.Builder
+			for _, line := range lines {
+				ops.WriteString(map[DiffOp]string{DiffEqual: "=", DiffDelete: "-", DiffInsert: "+"}[line.Op])
+			}
+			if ops.String() != tt.want {
+				t.Errorf("DiffLines() = %q, want %q", ops.String(), tt.want)
+			}
+			if DiffChanged(lines) != tt.changed {
+				t.Errorf("DiffChanged() = %v, want %v", DiffChanged(lines), tt.changed)
+			}
+
+			old, new := applyDiff(lines)
+			if strings.Join(old, "\n") != strings.Join(splitLines(tt.old), "\n") || strings.Join(new, "\n") != strings.Join(splitLines(tt.new), "\n") {
+				t.Errorf("DiffLines() = %+v, does not rebuild both texts", lines)
+			}
+		})
+	}
+}
+
+func TestDiffLines_LineNumbers(t *testing.T) {
+	lines := DiffLines("a\nb\nc\n", "a\nc\nd\n")
+	want := []DiffLine{
+		{Op: DiffEqual, Text: "a", OldLine: 1, NewLine: 1},
+		{Op: DiffDelete, Text: "b", OldLine: 2},
+		{Op: DiffEqual, Text: "c", OldLine: 3, NewLine: 2},
+		{Op: DiffInsert, Text: "d", NewLine: 3},
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("DiffLines() = %+v, want %+v", lines, want)
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("line %d = %+v, want %+v", i, lines[i], want[i])
+		}
+	}
+}
+
+func TestDiffLines_TooManyEdits(t *testing.T) {
+	var old, new strings.Builder
+	for i := range maxDiffEdits + 10 {
+		old.WriteString("old " + strings.Repeat("x", i%7) + "\n")
+		new.WriteString("new\n")
+	}
+
+	lines := DiffLines("same\n"+old.String(), "same\n"+new.String())
+	if lines[0].Op != DiffEqual || lines[1].Op != DiffDelete || lines[len(lines)-1].Op != DiffInsert {
+		t.Errorf("DiffLines() should keep the common lines and replace the others")
+	}
+	if oldLines, newLines := applyDiff(lines); len(oldLines) != maxDiffEdits+11 || len(newLines) != maxDiffEdits+11 {
+		t.Errorf("DiffLines() has %d and %d lines, want every line of both texts", len(oldLines), len(newLines))
+	}
+}
@@ -0,0 +1,221 @@
+package engine
+
+import (
+	"hash/fnv"
+	"log/slog"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/EwenQuim/pluie/model"
+)
+
+// MinHash / LSH parameters. With 32 bands of 4 rows, pairs above ~42% similarity
+// are very likely to share a band and be compared, while dissimilar pairs never are.
+const (
+	minHashBands    = 32
+	minHashRows     = 4
+	minHashSize     = minHashBands * minHashRows
+	shingleSize     = 3 // Words per shingle
+	minHashSeedBase = 0x9E3779B97F4A7C15
+)
+
+// DuplicateOptions tunes the duplicates report
+type DuplicateOptions struct {
+	Threshold float64 // Minimum estimated similarity, between 0 and 1
+	MinWords  int     // Notes with fewer words are too small to compare
+}
+
+// DefaultDuplicateOptions reports notes sharing at least 85% of their content
+func DefaultDuplicateOptions() DuplicateOptions {
+	return DuplicateOptions{
+		Threshold: 0.85,
+		MinWords:  30,
+	}
+}
+
+// DuplicatePair is two notes with near-identical content
+type DuplicatePair struct {
+	A, B       model.Note
+	Similarity float64 // Estimated Jaccard similarity of their shingles
+}
+
+// DuplicateGroup is a set of notes connected by near-duplicate pairs
+type DuplicateGroup struct {
+	Notes []model.Note // Sorted by path
+	Pairs []DuplicatePair
+}
+
+// FindDuplicates groups notes with near-identical content.
+// It uses MinHash signatures with LSH banding, so only candidate pairs are compared.
+func FindDuplicates(notes []model.Note, opts DuplicateOptions) []DuplicateGroup {
+	start := time.Now()
+	defer func() {
+		slog.Info("Duplicates computed", "in", time.Since(start).String())
+	}()
+
+	// Deterministic order, whatever the input
+	notes = slices.Clone(notes)
+	slices.SortFunc(notes, func(a, b model.Note) int { return strings.Compare(a.Path, b.Path) })
+
+	// Signatures are independent, compute them concurrently
+	signatures := make([][]uint64, len(notes))
+	var wg sync.WaitGroup
+	for i, note := range notes {
+		wg.Go(func() {
+			words := normalizedWords(note.Content)
+			if len(words) < max(opts.MinWords, shingleSize) {
+				return
+			}
+			signatures[i] = minHashSignature(shingles(words))
+		})
+	}
+	wg.Wait()
+
+	// LSH: notes sharing any band bucket are candidates
+	candidates := make(map[[2]int]bool)
+	for band := range minHashBands {
+		buckets := make(map[uint64][]int)
+		for i, signature := range signatures {
+			if signature == nil {
+				continue
+			}
+			key := bandHash(signature[band*minHashRows : (band+1)*minHashRows])
+			buckets[key] = append(buckets[key], i)
+		}
+		for _, bucket := range buckets {
+			for x := 0; x < len(bucket); x++ {
+				for y := x + 1; y < len(bucket); y++ {
+					candidates[[2]int{bucket[x], bucket[y]}] = true
+				}
+			}
+		}
+	}
+
+	// Verify candidates and connect them with union-find
+	parent := make([]int, len(notes))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	var pairs [][2]int
+	similarities := make(map[[2]int]float64)
+	for pair := range candidates {
+		similarity := signatureSimilarity(signatures[pair[0]], signatures[pair[1]])
+		if similarity < opts.Threshold {
+			continue
+		}
+		pairs = append(pairs, pair)
+		similarities[pair] = similarity
+		parent[find(pair[0])] = find(pair[1])
+	}
+	slices.SortFunc(pairs, func(a, b [2]int) int {
+		if a[0] != b[0] {
+			return a[0] - b[0]
+		}
+		return a[1] - b[1]
+	})
+
+	// Build groups, ordered by their first note path
+	groupIndex := make(map[int]int)
+	var groups []DuplicateGroup
+	for _, pair := range pairs {
+		root := find(pair[0])
+		index, ok := groupIndex[root]
+		if !ok {
+			index = len(groups)
+			groupIndex[root] = index
+			groups = append(groups, DuplicateGroup{})
+		}
+		groups[index].Pairs = append(groups[index].Pairs, DuplicatePair{
+			A:          notes[pair[0]],
+			B:          notes[pair[1]],
+			Similarity: similarities[pair],
+		})
+	}
+	for i := range notes {
+		if index, ok := groupIndex[find(i)]; ok && signatures[i] != nil {
+			groups[index].Notes = append(groups[index].Notes, notes[i])
+		}
+	}
+	slices.SortFunc(groups, func(a, b DuplicateGroup) int { return strings.Compare(a.Notes[0].Path, b.Notes[0].Path) })
+
+	return groups
+}
+
+// normalizedWords lowercases content and splits it into words, dropping punctuation and markup
+func normalizedWords(content string) []string {
+	return strings.FieldsFunc(strings.ToLower(content), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// shingles hashes every sequence of shingleSize consecutive words
+func shingles(words []string) []uint64 {
+	hashes := make([]uint64, 0, len(words)-shingleSize+1)
+	for i := 0; i+shingleSize <= len(words); i++ {
+		h := fnv.New64a()
+		for _, word := range words[i : i+shingleSize] {
+			_, _ = h.Write([]byte(word))
+			_, _ = h.Write([]byte{0})
+		}
+		hashes = append(hashes, h.Sum64())
+	}
+	return hashes
+}
+
+// minHashSignature keeps, for each hash function, the minimum hash over all shingles
+func minHashSignature(shingleHashes []uint64) []uint64 {
+	signature := make([]uint64, minHashSize)
+	for i := range signature {
+		signature[i] = math.MaxUint64
+	}
+	for _, shingle := range shingleHashes {
+		for i := range signature {
+			if h := mix64(shingle ^ (minHashSeedBase * uint64(i+1))); h < signature[i] {
+				signature[i] = h
+			}
+		}
+	}
+	return signature
+}
+
+// mix64 is the splitmix64 finalizer, turning one hash into a family of independent ones
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xBF58476D1CE4E5B9
+	x ^= x >> 27
+	x *= 0x94D049BB133111EB
+	x ^= x >> 31
+	return x
+}
+
+// bandHash hashes the rows of one LSH band
+func bandHash(rows []uint64) uint64 {
+	var h uint64 = 14695981039346656037
+	for _, row := range rows {
+		h = mix64(h ^ row)
+	}
+	return h
+}
+
+// signatureSimilarity estimates the Jaccard similarity from two MinHash signatures
+func signatureSimilarity(a, b []uint64) float64 {
+	equal := 0
+	for i := range a {
+		if a[i] == b[i] {
+			equal++
+		}
+	}
+	return float64(equal) / float64(len(a))
+}
@@ -0,0 +1,181 @@
+package engine
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/EwenQuim/pluie/model"
+)
+
+const meetingNotes = `# Weekly sync
+
+Attendees: Alice, Bob, Charlie and the whole platform team.
+
+We discussed the migration of the billing service to the new cluster. Bob will
+write the runbook before Friday and Alice reviews the alerting rules. The
+database upgrade is postponed until the load tests are green on staging.
+
+Action items: update the dashboards, clean the old feature flags, schedule the
+retrospective, and send the summary to the product managers by Monday morning.`
+
+const recipeNotes = `# Sourdough bread
+
+Feed the starter the night before with equal weights of flour and water. In the
+morning, mix the flour, water and starter, rest for an hour, then add the salt.
+Fold the dough every thirty minutes for the first two hours of fermentation.
+Shape the loaf, proof it overnight in the fridge and bake it in a very hot dutch
+oven, lid on for twenty minutes, then lid off until the crust is deep brown.`
+
+const gardenNotes = `# Vegetable garden
+
+Tomatoes need full sun, deep watering twice a week and a stake or a cage to
+climb. Plant basil nearby, it keeps some pests away and tastes great with them.
+Zucchini grow fast: harvest them small, every other day, before they become
+huge. Mulch the beds with straw in June to keep the soil humid during summer.`
+
+func fixtureNote(path, content string) model.Note {
+	slug := strings.TrimSuffix(strings.ToLower(path), ".md")
+	return model.Note{Title: path, Slug: slug, Path: path, Content: content}
+}
+
+func TestFindDuplicates(t *testing.T) {
+	notes := []model.Note{
+		fixtureNote("Meetings/Meeting notes.md", meetingNotes),
+		// Sync accident: exact copy
+		fixtureNote("Meetings/Meeting notes 1.md", meetingNotes),
+		// Conflicted copy with a small edit
+		fixtureNote("Meetings/Meeting notes (conflicted copy).md", strings.Replace(meetingNotes, "before Friday", "before Thursday evening", 1)),
+		fixtureNote("Cooking/Sourdough.md", recipeNotes),
+		// Same recipe with different formatting and case only
+		fixtureNote("Cooking/sourdough copy.md", strings.ToUpper(strings.ReplaceAll(recipeNotes, "\n", "  "))),
+		fixtureNote("Garden.md", gardenNotes),
+		// Same topic, different text: not a duplicate
+		fixtureNote("Garden 2024.md", "# Vegetable garden 2024\n\nThis year the tomatoes got blight in August, so next year I will plant resistant varieties, water at the base and remove the lower leaves. The basil did well, the zucchini less so because of the powdery mildew after the rainy weeks."),
+		// Tiny identical notes are below the size floor
+		fixtureNote("Inbox/todo.md", "Call the bank."),
+		fixtureNote("Inbox/todo 1.md", "Call the bank."),
+	}
+
+	groups := FindDuplicates(notes, DefaultDuplicateOptions())
+
+	if len(groups) != 2 {
+		for _, group := range groups {
+			t.Logf("group: %v", groupPaths(group))
+		}
+		t.Fatalf("expected 2 duplicate groups, got %d", len(groups))
+	}
+
+	expectedGroups := [][]string{
+		{"Cooking/Sourdough.md", "Cooking/sourdough copy.md"},
+		{"Meetings/Meeting notes (conflicted copy).md", "Meetings/Meeting notes 1.md", "Meetings/Meeting notes.md"},
+	}
+	for i, expected := range expectedGroups {
+		if got := groupPaths(groups[i]); strings.Join(got, "|") != strings.Join(expected, "|") {
+			t.Errorf("group %d = %v, want %v", i, got, expected)
+		}
+	}
+
+	// Exact copies are 100% similar, the conflicted copy slightly less
+	for _, pair := range groups[1].Pairs {
+		if pair.Similarity < 0.85 || pair.Similarity > 1 {
+			t.Errorf("unexpected similarity %v between %s and %s", pair.Similarity, pair.A.Path, pair.B.Path)
+		}
+		isCopy := pair.A.Path == "Meetings/Meeting notes 1.md" && pair.B.Path == "Meetings/Meeting notes.md"
+		if isCopy && pair.Similarity != 1 {
+			t.Errorf("exact copies should be 100%% similar, got %v", pair.Similarity)
+		}
+	}
+}
+
+func TestFindDuplicates_NoFalsePositives(t *testing.T) {
+	notes := []model.Note{
+		fixtureNote("meeting.md", meetingNotes),
+		fixtureNote("recipe.md", recipeNotes),
+		fixtureNote("garden.md", gardenNotes),
+		// Shares its first half with the meeting notes only
+		fixtureNote("meeting half.md", meetingNotes[:len(meetingNotes)/2]+"\n\n"+gardenNotes),
+	}
+
+	if groups := FindDuplicates(notes, DefaultDuplicateOptions()); len(groups) != 0 {
+		for _, group := range groups {
+			t.Errorf("unexpected duplicate group %v", groupPaths(group))
+		}
+	}
+}
+
+func TestFindDuplicates_Scales(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping large vault test in short mode")
+	}
+
+	// Random notes over a shared vocabulary, plus one duplicated note
+	rng := rand.New(rand.NewSource(42))
+	vocabulary := normalizedWords(meetingNotes + recipeNotes + gardenNotes)
+	notes := make([]model.Note, 0, 5001)
+	for i := range 5000 {
+		words := make([]string, 120)
+		for j := range words {
+			words[j] = vocabulary[rng.Intn(len(vocabulary))]
+		}
+		notes = append(notes, fixtureNote(fmt.Sprintf("notes/%05d.md", i), strings.Join(words, " ")))
+	}
+	notes = append(notes, fixtureNote("notes/00042 copy.md", notes[42].Content))
+
+	groups := FindDuplicates(notes, DefaultDuplicateOptions())
+
+	if len(groups) != 1 {
+		t.Fatalf("expected exactly 1 group, got %d", len(groups))
+	}
+	if got := groupPaths(groups[0]); strings.Join(got, "|") != "notes/00042 copy.md|notes/00042.md" {
+		t.Errorf("unexpected group %v", got)
+	}
+}
+
+func TestDuplicateDiagnostics(t *testing.T) {
+	a := fixtureNote("a.md", meetingNotes)
+	b := fixtureNote("b.md", meetingNotes)
+	groups := []DuplicateGroup{{
+		Notes: []model.Note{a, b},
+		Pairs: []DuplicatePair{{A: a, B: b, Similarity: 0.934}},
+	}}
+
+	diagnostics := DuplicateDiagnostics(groups)
+
+	if len(diagnostics) != 1 {
+		t.Fatalf("expected 1 diagnostic, got %d", len(diagnostics))
+	}
+	d := diagnostics[0]
+	if d.Kind != DiagnosticDuplicate || d.Message != "Near-duplicate notes (93% similar)" {
+		t.Errorf("unexpected diagnostic %+v", d)
+	}
+	if strings.Join(d.Paths, ",") != "a.md,b.md" || strings.Join(d.Slugs, ",") != "a,b" {
+		t.Errorf("unexpected paths %v and slugs %v", d.Paths, d.Slugs)
+	}
+}
+
+func TestNotesService_DiagnosticsFollowUpdates(t *testing.T) {
+	notes := []model.Note{fixtureNote("a.md", meetingNotes), fixtureNote("b.md", meetingNotes)}
+	ns := createTestNotesService(notes)
+
+	if got := len(ns.Diagnostics()); got != 1 {
+		t.Fatalf("expected 1 diagnostic, got %d", got)
+	}
+
+	notes = notes[:1]
+	notesMap := map[string]model.Note{notes[0].Slug: notes[0]}
+	ns.UpdateData(&notesMap, BuildTree(notes), nil)
+
+	if got := len(ns.Diagnostics()); got != 0 {
+		t.Errorf("expected no diagnostic after the duplicate was removed, got %d", got)
+	}
+}
+
+func groupPaths(group DuplicateGroup) []string {
+	var paths []string
+	for _, note := range group.Notes {
+		paths = append(paths, note.Path)
+	}
+	return paths
+}
@@ -0,0 +1,79 @@
+package engine
+
+import (
+	"path"
+	"strings"
+)
+
+// ExcludeMatcher matches the vault paths excluded from publication by EXCLUDE_PATHS globs.
+// The zero value excludes nothing.
+type ExcludeMatcher struct {
+	globs      [][]string // Globs split in path segments
+	ignoreCase bool
+}
+
+// NewExcludeMatcher returns a matcher of globs like "Archive/**", "Templates/*.md" or
+// "*.excalidraw.md". "**" matches any number of folders, globs without a slash match the
+// file or folder name at any depth. Invalid globs never match.
+func NewExcludeMatcher(globs []string, ignoreCase bool) ExcludeMatcher {
+	matcher := ExcludeMatcher{ignoreCase: ignoreCase}
+	for _, glob := range globs {
+		glob = strings.Trim(strings.TrimSpace(glob), "/")
+		if glob == "" {
+			continue
+		}
+		if ignoreCase {
+			glob = strings.ToLower(glob)
+		}
+		if !strings.Contains(glob, "/") {
+			glob = "**/" + glob
+		}
+		matcher.globs = append(matcher.globs, strings.Split(glob, "/"))
+	}
+	return matcher
+}
+
+// Match reports whether the vault path, like "Archive/2023/note.md", or one of its folders
+// is excluded
+func (m ExcludeMatcher) Match(vaultPath string) bool {
+	if len(m.globs) == 0 {
+		return false
+	}
+	vaultPath = strings.Trim(vaultPath, "/")
+	if vaultPath == "" {
+		return false
+	}
+	if m.ignoreCase {
+		vaultPath = strings.ToLower(vaultPath)
+	}
+
+	segments := strings.Split(vaultPath, "/")
+	for end := 1; end <= len(segments); end++ {
+		for _, glob := range m.globs {
+			if matchGlobSegments(glob, segments[:end]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchGlobSegments matches path segments against glob segments, "**" matching any number of segments
+func matchGlobSegments(glob, segments []string) bool {
+	if len(glob) == 0 {
+		return len(segments) == 0
+	}
+	if glob[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchGlobSegments(glob[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segments) == 0 {
+		return false
+	}
+	matched, err := path.Match(glob[0], segments[0])
+	return err == nil && matched && matchGlobSegments(glob[1:], segments[1:])
+}
@@ -0,0 +1,44 @@
+package engine
+
+import "testing"
+
+func TestExcludeMatcher(t *testing.T) {
+	matcher := NewExcludeMatcher([]string{"Archive/**", "Templates", "*.excalidraw.md", "journal/**/scratch-*.md"}, false)
+
+	for vaultPath, excluded := range map[string]bool{
+		"Archive":                           true,
+		"Archive/note.md":                   true,
+		"Archive/2023/deep/note.md":         true,
+		"/Archive/note.md":                  true,
+		"Templates":                         true,
+		"Templates/daily.md":                true,
+		"projects/Templates/weekly.md":      true,
+		"drawing.excalidraw.md":             true,
+		"projects/plan.excalidraw.md":       true,
+		"journal/scratch-1.md":              true,
+		"journal/2024/05/scratch-monday.md": true,
+		"journal/2024/entry.md":             false,
+		"archive/note.md":                   false,
+		"Archived/note.md":                  false,
+		"projects/Archive/note.md":          false,
+		"notes/excalidraw.md":               false,
+		"":                                  false,
+	} {
+		if got := matcher.Match(vaultPath); got != excluded {
+			t.Errorf("Match(%q) = %v, want %v", vaultPath, got, excluded)
+		}
+	}
+}
+
+func TestExcludeMatcher_IgnoreCase(t *testing.T) {
+	matcher := NewExcludeMatcher([]string{"Archive/**", "*.Excalidraw.md"}, true)
+	for _, vaultPath := range []string{"archive/note.md", "ARCHIVE/2023/Note.md", "plan.excalidraw.MD"} {
+		if !matcher.Match(vaultPath) {
+			t.Errorf("Match(%q) = false, want true when ignoring case", vaultPath)
+		}
+	}
+
+	if (ExcludeMatcher{}).Match("Archive/note.md") {
+		t.Error("the zero ExcludeMatcher should exclude nothing")
+	}
+}
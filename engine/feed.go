@@ -0,0 +1,171 @@
+package engine
+
+import (
+	"encoding/xml"
+	"html"
+	"io"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/EwenQuim/pluie/model"
+)
+
+// FeedItem is a note of the RSS feed
+type FeedItem struct {
+	Title       string
+	Slug        string
+	Description string    // Plain text
+	Date        time.Time // Zero when unknown
+}
+
+// rssFeed is the XML document of an RSS 2.0 feed
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string      `xml:"title"`
+	Link          string      `xml:"link"`
+	Description   string      `xml:"description"`
+	SelfLink      rssAtomLink `xml:"atom:link"`
+	LastBuildDate string      `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem   `xml:"item"`
+}
+
+type rssAtomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	GUID        rssGUID `xml:"guid"`
+	Description string  `xml:"description,omitempty"`
+	PubDate     string  `xml:"pubDate,omitempty"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// RecentNotes returns the limit most recently modified public notes as feed items, most recent
+// first. Dates come from the "modified" frontmatter key, "date" as a fallback, then the file
+// modification time. Notes with "noindex: true" are left out, see IsNoIndex.
+func RecentNotes(notes []model.Note, publicByDefault bool, limit int, loc *time.Location) []FeedItem {
+	items := make([]FeedItem, 0, len(notes))
+	for _, note := range notes {
+		if !IsVisible(note, publicByDefault) || IsNoIndex(note) {
+			continue
+		}
+
+		date := NoteLastModified(note, loc)
+		if date.IsZero() {
+			date = note.ModTime
+		}
+		items = append(items, FeedItem{
+			Title:       note.Title,
+			Slug:        note.Slug,
+			Description: ExtractDescription(note.Content),
+			Date:        date,
+		})
+	}
+
+	slices.SortFunc(items, func(a, b FeedItem) int {
+		if cmp := b.Date.Compare(a.Date); cmp != 0 {
+			return cmp
+		}
+		return strings.Compare(a.Slug, b.Slug)
+	})
+	if limit > 0 && len(items) > limit {
+		items = items[:limit]
+	}
+	return items
+}
+
+// WriteRSSFeed writes the items as an RSS 2.0 feed, with absolute links on baseURL.
+// selfPath is the path the feed itself is served at. GUIDs are the note slugs, so they
+// don't change with the site URL.
+func WriteRSSFeed(w io.Writer, title, description, baseURL, selfPath string, items []FeedItem, loc *time.Location) error {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+
+	channel := rssChannel{
+		Title:       title,
+		Link:        baseURL + "/",
+		Description: description,
+		SelfLink:    rssAtomLink{Href: baseURL + selfPath, Rel: "self", Type: "application/rss+xml"},
+		Items:       make([]rssItem, 0, len(items)),
+	}
+	if channel.Description == "" {
+		channel.Description = title
+	}
+
+	for _, item := range items {
+		notePath, err := url.PathUnescape(item.Slug)
+		if err != nil {
+			notePath = item.Slug
+		}
+		rss := rssItem{
+			Title: item.Title,
+			Link:  baseURL + (&url.URL{Path: "/" + notePath}).EscapedPath(),
+			GUID:  rssGUID{Value: item.Slug},
+			// Descriptions are read as HTML: the plain text is escaped for HTML, then for XML
+			Description: html.EscapeString(item.Description),
+		}
+		if !item.Date.IsZero() {
+			rss.PubDate = FormatRFC822(item.Date, loc)
+			if channel.LastBuildDate == "" {
+				// Items are sorted, the first one is the most recent
+				channel.LastBuildDate = rss.PubDate
+			}
+		}
+		channel.Items = append(channel.Items, rss)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(rssFeed{Version: "2.0", Atom: "http://www.w3.org/2005/Atom", Channel: channel}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// ExtractDescription extracts the first substantial line of content, skipping headings,
+// for note cards and feed descriptions
+func ExtractDescription(content string) string {
+	// Remove markdown headers and get first paragraph
+	lines := strings.Split(content, "\n")
+	var description strings.Builder
+
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		// Skip empty lines and headers
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// Stop at first substantial line and use it as description
+		if len(line) > 10 {
+			description.WriteString(line)
+			break
+		}
+	}
+
+	desc := description.String()
+	// Truncate if too long, without cutting a character in half
+	if len(desc) > 150 {
+		desc = strings.ToValidUTF8(desc[:150], "") + "..."
+	}
+
+	return desc
+}
@@ -0,0 +1,103 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/EwenQuim/pluie/model"
+)
+
+func TestRecentNotes(t *testing.T) {
+	notes := []model.Note{
+		{Slug: "old", Title: "Old", IsPublic: true, Metadata: map[string]any{"date": "2023-01-01"}},
+		{Slug: "edited", Title: "Edited", IsPublic: true, Metadata: map[string]any{"modified": "2024-03-10", "date": "2022-01-01"}},
+		{Slug: "undated", Title: "Undated", IsPublic: true, ModTime: time.Date(2024, 2, 1, 12, 0, 0, 0, time.UTC)},
+		{Slug: "private", Title: "Private", IsPublic: false, Metadata: map[string]any{"date": "2025-01-01"}},
+		{Slug: "a-tie", Title: "Tie", IsPublic: true, Metadata: map[string]any{"date": "2023-01-01"}},
+		{Slug: "hidden", Title: "Hidden", IsPublic: true, Metadata: map[string]any{"date": "2025-01-01", "noindex": true}},
+	}
+
+	items := RecentNotes(notes, false, 0, time.UTC)
+	var slugs []string
+	for _, item := range items {
+		slugs = append(slugs, item.Slug)
+	}
+	// "modified" first, then the file modification time, then ties by slug. noindex notes are left out.
+	if got := strings.Join(slugs, " "); got != "edited undated a-tie old" {
+		t.Errorf("order = %s, want edited undated a-tie old", got)
+	}
+
+	if items := RecentNotes(notes, false, 2, time.UTC); len(items) != 2 || items[1].Slug != "undated" {
+		t.Errorf("expected the 2 most recent notes, got %v", items)
+	}
+	if items := RecentNotes(notes, true, 1, time.UTC); items[0].Slug != "private" {
+		t.Errorf("with PublicByDefault, every given note is listed, got %v", items)
+	}
+}
+
+func TestWriteRSSFeed(t *testing.T) {
+	items := []FeedItem{
+		{Title: "Tom & Jerry", Slug: "cartoons/tom-%26-jerry", Description: "Uses <b>bold</b> tags", Date: time.Date(2024, 3, 10, 8, 0, 0, 0, time.UTC)},
+		{Title: "Undated", Slug: "undated"},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteRSSFeed(&buf, "Pluie", "", "https://notes.example.com/", "/-/feed.xml", items, time.UTC); err != nil {
+		t.Fatalf("WriteRSSFeed() error: %v", err)
+	}
+	output := buf.String()
+
+	for _, expected := range []string{
+		`<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom">`,
+		`<atom:link href="https://notes.example.com/-/feed.xml" rel="self" type="application/rss+xml"></atom:link>`,
+		`<guid isPermaLink="false">cartoons/tom-%26-jerry</guid>`,
+		// Escaped for HTML, then for XML
+		`<description>Uses &amp;lt;b&amp;gt;bold&amp;lt;/b&amp;gt; tags</description>`,
+		`<lastBuildDate>Sun, 10 Mar 2024 08:00:00 +0000</lastBuildDate>`,
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("expected %q in feed:\n%s", expected, output)
+		}
+	}
+
+	var feed rssFeed
+	if err := xml.Unmarshal(buf.Bytes(), &feed); err != nil {
+		t.Fatalf("the feed should be valid XML: %v", err)
+	}
+	if feed.Channel.Description != "Pluie" {
+		t.Errorf("the description should default to the title, got %q", feed.Channel.Description)
+	}
+	if len(feed.Channel.Items) != 2 {
+		t.Fatalf("expected 2 items, got %d", len(feed.Channel.Items))
+	}
+	first := feed.Channel.Items[0]
+	if first.Title != "Tom & Jerry" || first.Link != "https://notes.example.com/cartoons/tom-&-jerry" || first.PubDate != "Sun, 10 Mar 2024 08:00:00 +0000" {
+		t.Errorf("unexpected first item %+v", first)
+	}
+	if feed.Channel.Items[1].PubDate != "" {
+		t.Errorf("an undated item should have no pubDate, got %q", feed.Channel.Items[1].PubDate)
+	}
+}
+
+func TestExtractDescription(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{name: "skips headings and short lines", content: "# Title\n\nShort\n\nThe first real paragraph.\nSecond one.", expected: "The first real paragraph."},
+		{name: "empty", content: "# Only a title", expected: ""},
+		{name: "truncates on a character boundary", content: strings.Repeat("a", 149) + "éé", expected: strings.Repeat("a", 149) + "..."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExtractDescription(tt.content); got != tt.expected {
+				t.Errorf("ExtractDescription() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package engine
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/EwenQuim/pluie/model"
+)
+
+// Flashcard is a question and its answer, extracted from a note
+type Flashcard struct {
+	Front     string // Question, as plain text
+	Back      string // Answer, as markdown
+	NoteTitle string
+	NoteSlug  string
+}
+
+var (
+	flashcardQuestionRegex = regexp.MustCompile(`^(?i:q)\s*:\s*(.*)$`)
+	flashcardAnswerRegex   = regexp.MustCompile(`^(?i:a)\s*:\s*(.*)$`)
+
+	plainImageRegex = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	plainLinkRegex  = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+	// Strongest markers first, "_" only around words so snake_case is kept
+	plainEmphasisRegexes = []*regexp.Regexp{
+		regexp.MustCompile("`([^`]+)`"),
+		regexp.MustCompile(`\*\*(\S(?:.*?\S)?)\*\*`),
+		regexp.MustCompile(`~~(\S(?:.*?\S)?)~~`),
+		regexp.MustCompile(`\*(\S(?:.*?\S)?)\*`),
+		regexp.MustCompile(`\b__(\S(?:.*?\S)?)__\b`),
+		regexp.MustCompile(`\b_(\S(?:.*?\S)?)_\b`),
+	}
+)
+
+// FlashcardsFromNotes extracts the flashcards of the notes tagged with tag, or one of its subtags
+// like "flashcards/biology". Notes are taken in the given order.
+func FlashcardsFromNotes(notes []model.Note, tag string) []Flashcard {
+	tag = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+
+	var cards []Flashcard
+	for _, note := range notes {
+		if hasTag(note, tag) {
+			cards = append(cards, ExtractFlashcards(note)...)
+		}
+	}
+	return cards
+}
+
+// hasTag reports whether the note has the tag or one of its subtags
+func hasTag(note model.Note, tag string) bool {
+	for _, noteTag := range extractAllTags(note) {
+		noteTag = strings.ToLower(strings.TrimSpace(noteTag))
+		if noteTag == tag || strings.HasPrefix(noteTag, tag+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// ExtractFlashcards extracts the question/answer pairs of a note, following two conventions:
+//
+//   - A heading ending with "?" is a question, answered by the text below it. The answer ends at
+//     the next heading of the same or a higher level, or at a question heading: deeper headings
+//     are part of the answer.
+//   - A "Q:" line is a question, answered by the "A:" line after it. The answer goes on
+//     until a blank line, a heading or the next "Q:" line.
+//
+// Questions without an answer are skipped, and fenced code blocks are never parsed.
+func ExtractFlashcards(note model.Note) []Flashcard {
+	var cards []Flashcard
+	add := func(front string, back []string) {
+		front = PlainText(front)
+		text := strings.TrimSpace(strings.Join(back, "\n"))
+		if front != "" && text != "" {
+			cards = append(cards, Flashcard{Front: front, Back: text, NoteTitle: note.Title, NoteSlug: note.Slug})
+		}
+	}
+
+	// Open question heading
+	var heading string
+	headingLevel := 0
+	var headingBody []string
+	closeHeading := func() {
+		if headingLevel > 0 {
+			add(heading, headingBody)
+		}
+		heading, headingLevel, headingBody = "", 0, nil
+	}
+
+	// Open "Q:" line and its "A:" answer
+	var question string
+	var answer []string
+	inQuestion, inAnswer := false, false
+	closeQuestion := func() {
+		if inAnswer {
+			add(question, answer)
+		}
+		question, answer, inQuestion, inAnswer = "", nil, false, false
+	}
+
+	inFence := false
+	for line := range strings.SplitSeq(note.Content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			inFence = !inFence
+		}
+
+		if !inFence {
+			if text, level := extractHeading(trimmed); level > 0 && text != "" {
+				closeQuestion()
+				isQuestion := strings.HasSuffix(PlainText(text), "?")
+				if headingLevel > 0 && (level <= headingLevel || isQuestion) {
+					closeHeading()
+				}
+				if isQuestion {
+					heading, headingLevel = text, level
+					continue
+				}
+			} else if match := flashcardQuestionRegex.FindStringSubmatch(trimmed); match != nil {
+				closeQuestion()
+				closeHeading()
+				question, inQuestion = match[1], true
+				continue
+			} else if match := flashcardAnswerRegex.FindStringSubmatch(trimmed); match != nil && inQuestion && !inAnswer {
+				answer, inAnswer = []string{match[1]}, true
+				continue
+			} else if trimmed == "" && inQuestion {
+				closeQuestion()
+				continue
+			}
+		}
+
+		switch {
+		case inAnswer:
+			answer = append(answer, line)
+		case inQuestion:
+			// The question goes on until its "A:" line
+			question += " " + trimmed
+		case headingLevel > 0:
+			headingBody = append(headingBody, line)
+		}
+	}
+	closeQuestion()
+	closeHeading()
+
+	return cards
+}
+
+// WikiLinksToText replaces [[Note]] and [[Note|text]] wikilinks with their displayed text
+func WikiLinksToText(text string) string {
+	return wikiLinkRegex.ReplaceAllStringFunc(text, func(match string) string {
+		link := strings.TrimSuffix(strings.TrimPrefix(match, "[["), "]]")
+		if _, display, ok := strings.Cut(link, "|"); ok {
+			return display
+		}
+		return link
+	})
+}
+
+// PlainText strips the inline markdown of a short text: emphasis, code, links, images and wikilinks
+func PlainText(text string) string {
+	text = WikiLinksToText(text)
+	text = plainImageRegex.ReplaceAllString(text, "$1")
+	text = plainLinkRegex.ReplaceAllString(text, "$1")
+	for _, emphasisRegex := range plainEmphasisRegexes {
+		text = emphasisRegex.ReplaceAllString(text, "$1")
+	}
+	return strings.Join(strings.Fields(text), " ")
+}
@@ -0,0 +1,169 @@
+package engine
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/EwenQuim/pluie/model"
+)
+
+func TestExtractFlashcards(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected [][2]string // Front and back of each card
+	}{
+		{
+			name:     "question heading",
+			content:  "# Biology\n\n## What is a cell?\n\nThe basic unit of life.\n\n## Notes\n\nNot a card.",
+			expected: [][2]string{{"What is a cell?", "The basic unit of life."}},
+		},
+		{
+			name:    "multi-line answer ends at the next heading of the same level",
+			content: "## Why is the sky blue?\n\nRayleigh scattering.\n\n- Short wavelengths\n- Scatter more\n\n## Next\n\nText.",
+			expected: [][2]string{
+				{"Why is the sky blue?", "Rayleigh scattering.\n\n- Short wavelengths\n- Scatter more"},
+			},
+		},
+		{
+			name:    "nested headings are part of the answer",
+			content: "## How does TCP connect?\n\nWith a handshake.\n\n### Steps\n\nSYN, SYN-ACK, ACK.\n\n# Other\n\nText.",
+			expected: [][2]string{
+				{"How does TCP connect?", "With a handshake.\n\n### Steps\n\nSYN, SYN-ACK, ACK."},
+			},
+		},
+		{
+			name:    "nested question heading starts its own card",
+			content: "## What is DNS?\n\nName resolution.\n\n### What is a TTL?\n\nHow long a record is cached.",
+			expected: [][2]string{
+				{"What is DNS?", "Name resolution."},
+				{"What is a TTL?", "How long a record is cached."},
+			},
+		},
+		{
+			name:     "markdown is stripped from the front only",
+			content:  "## What does **`go vet`** do?\n\nReports *suspicious* constructs, see [docs](https://go.dev).",
+			expected: [][2]string{{"What does go vet do?", "Reports *suspicious* constructs, see [docs](https://go.dev)."}},
+		},
+		{
+			name:    "Q/A line pairs",
+			content: "Q: Capital of France?\nA: Paris\n\nq: Largest planet?\na: Jupiter\nA gas giant.\n\nSome text.",
+			expected: [][2]string{
+				{"Capital of France?", "Paris"},
+				{"Largest planet?", "Jupiter\nA gas giant."},
+			},
+		},
+		{
+			name:    "Q/A pairs next to each other",
+			content: "Q: One?\nA: 1\nQ: Two?\nA: 2",
+			expected: [][2]string{
+				{"One?", "1"},
+				{"Two?", "2"},
+			},
+		},
+		{
+			name:     "multi-line question",
+			content:  "Q: What is the output of\n`len(\"héllo\")`?\nA: 6",
+			expected: [][2]string{{`What is the output of len("héllo")?`, "6"}},
+		},
+		{
+			name:     "Q/A pair ends a question heading answer",
+			content:  "## What is Go?\n\nA language.\n\nQ: Who made it?\nA: Google",
+			expected: [][2]string{{"What is Go?", "A language."}, {"Who made it?", "Google"}},
+		},
+		{
+			name:     "question heading without answer",
+			content:  "## What is missing?\n\n## Is this answered?\n\nYes.",
+			expected: [][2]string{{"Is this answered?", "Yes."}},
+		},
+		{
+			name:     "question without answer line",
+			content:  "Q: Lonely question?\n\nA: Too late, after a blank line",
+			expected: nil,
+		},
+		{
+			name:     "code blocks are not parsed",
+			content:  "```\n## Is this a heading?\nQ: Or this?\nA: No\n```",
+			expected: nil,
+		},
+		{
+			name:     "code block inside an answer",
+			content:  "## How to print?\n\n```go\n# not a heading\nfmt.Println()\n```",
+			expected: [][2]string{{"How to print?", "```go\n# not a heading\nfmt.Println()\n```"}},
+		},
+		{
+			name:     "no extractable cards",
+			content:  "# Plain note\n\nJust text, a question? Nope.\n\n## Section\n\nMore text.",
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cards := ExtractFlashcards(model.Note{Title: "Study", Slug: "study", Content: tt.content})
+
+			var got [][2]string
+			for _, card := range cards {
+				got = append(got, [2]string{card.Front, card.Back})
+				if card.NoteTitle != "Study" || card.NoteSlug != "study" {
+					t.Errorf("card %q should point to its note, got %q (%s)", card.Front, card.NoteTitle, card.NoteSlug)
+				}
+			}
+			if fmt.Sprintf("%q", got) != fmt.Sprintf("%q", tt.expected) {
+				t.Errorf("ExtractFlashcards() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFlashcardsFromNotes(t *testing.T) {
+	notes := []model.Note{
+		{Title: "Tagged", Content: "#flashcards\n\nQ: One?\nA: 1"},
+		{Title: "Subtag", Metadata: map[string]any{"tags": []any{"FlashCards/biology"}}, Content: "Q: Two?\nA: 2"},
+		{Title: "Untagged", Content: "Q: Three?\nA: 3"},
+		{Title: "Other tag", Content: "#flashcardsx\n\nQ: Four?\nA: 4"},
+	}
+
+	tests := []struct {
+		tag      string
+		expected []string
+	}{
+		{tag: "flashcards", expected: []string{"One?", "Two?"}},
+		{tag: "#Flashcards", expected: []string{"One?", "Two?"}},
+		{tag: "flashcards/biology", expected: []string{"Two?"}},
+		{tag: "none", expected: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tag, func(t *testing.T) {
+			var fronts []string
+			for _, card := range FlashcardsFromNotes(notes, tt.tag) {
+				fronts = append(fronts, card.Front)
+			}
+			if fmt.Sprint(fronts) != fmt.Sprint(tt.expected) {
+				t.Errorf("FlashcardsFromNotes(%q) = %v, want %v", tt.tag, fronts, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPlainText(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{input: "**Bold** and *italic* and _under_", expected: "Bold and italic and under"},
+		{input: "Use `snake_case_names` in ~~old~~ code", expected: "Use snake_case_names in old code"},
+		{input: "See [[Note|the note]], [[Other]] and [a link](https://example.com)", expected: "See the note, Other and a link"},
+		{input: "An ![image](img.png) here", expected: "An image here"},
+		{input: "  spaced\n  out  ", expected: "spaced out"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if result := PlainText(tt.input); result != tt.expected {
+				t.Errorf("PlainText(%q) = %q, want %q", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
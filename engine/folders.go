@@ -0,0 +1,125 @@
+package engine
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/EwenQuim/pluie/model"
+)
+
+// FolderSettings are the sidebar settings of a folder, from the "order", "icon" and
+// "collapsed" keys of its .pluie file, and the "description" of its index page
+type FolderSettings struct {
+	Order       *int   // Position among the sibling folders, nil sorts it alphabetically after the ordered ones
+	Icon        string // Emoji, or URL of an image like "/static/blog.png", shown before the folder name
+	Collapsed   *bool  // Whether the folder starts closed, nil keeps the default: only first level folders open
+	Description string // Shown under the folder name on its index page
+}
+
+// ParseFolderSettings reads the sidebar settings of a folder from its .pluie metadata.
+// Invalid values are ignored.
+func ParseFolderSettings(metadata map[string]any) FolderSettings {
+	var settings FolderSettings
+	if order, ok := metadataInt(metadata["order"]); ok {
+		settings.Order = &order
+	}
+	if icon, ok := metadata["icon"].(string); ok {
+		settings.Icon = strings.TrimSpace(icon)
+	}
+	if collapsed, ok := metadata["collapsed"].(bool); ok {
+		settings.Collapsed = &collapsed
+	}
+	if description, ok := metadata["description"].(string); ok {
+		settings.Description = strings.TrimSpace(description)
+	}
+	return settings
+}
+
+// IsImageIcon reports whether a folder icon is the URL of an image rather than an emoji
+func IsImageIcon(icon string) bool {
+	return strings.HasPrefix(icon, "/") || strings.HasPrefix(icon, "https://") || strings.HasPrefix(icon, "http://")
+}
+
+// FolderSlug returns the slug of a folder of the tree, the prefix of the slugs of its
+// notes like "projects/clientx". It is empty for the root and for folders without notes other
+// than notes with a permalink.
+func FolderSlug(folder *TreeNode) string {
+	if folder == nil || !folder.IsFolder || folder.Path == "" {
+		return ""
+	}
+	depth := strings.Count(folder.Path, "/") + 1
+	for node := range folder.AllNotes {
+		if node.Note.Permalink != "" {
+			continue
+		}
+		segments := strings.Split(node.Note.Slug, "/")
+		if len(segments) > depth {
+			return strings.Join(segments[:depth], "/")
+		}
+	}
+	return ""
+}
+
+// FindFolderInTree searches for a folder by slug in the tree, see FolderSlug
+func FindFolderInTree(root *TreeNode, slug string) *TreeNode {
+	slug = strings.Trim(slug, "/")
+	if root == nil || slug == "" {
+		return nil
+	}
+	for _, child := range root.Children {
+		if !child.IsFolder {
+			continue
+		}
+		folderSlug := FolderSlug(child)
+		if folderSlug == slug {
+			return child
+		}
+		if strings.HasPrefix(slug, folderSlug+"/") {
+			return FindFolderInTree(child, slug)
+		}
+	}
+	return nil
+}
+
+// FindFolderByPath returns the folder of the tree at a path of the vault, like "Projects/Pluie",
+// nil if there is none
+func FindFolderByPath(root *TreeNode, folderPath string) *TreeNode {
+	folderPath = strings.Trim(folderPath, "/")
+	if root == nil || folderPath == "" {
+		return nil
+	}
+	for _, child := range root.Children {
+		if !child.IsFolder {
+			continue
+		}
+		if child.Path == folderPath {
+			return child
+		}
+		if strings.HasPrefix(folderPath, child.Path+"/") {
+			return FindFolderByPath(child, folderPath)
+		}
+	}
+	return nil
+}
+
+// FolderContents returns the direct subfolders and notes of a folder, in the sidebar order.
+// Private notes are left out, and so are the subfolders with no note left.
+func FolderContents(folder *TreeNode, publicByDefault bool) (folders []*TreeNode, notes []model.Note) {
+	if folder == nil {
+		return nil, nil
+	}
+	for _, child := range folder.Children {
+		if child.IsFolder {
+			if slices.ContainsFunc(GetAllNotesFromTree(child), func(note model.Note) bool {
+				return IsVisible(note, publicByDefault)
+			}) {
+				folders = append(folders, child)
+			}
+			continue
+		}
+		if child.Note != nil && IsVisible(*child.Note, publicByDefault) {
+			notes = append(notes, *child.Note)
+		}
+	}
+	return folders, notes
+}
@@ -0,0 +1,152 @@
+package engine
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/EwenQuim/pluie/model"
+)
+
+func TestParseFolderSettings(t *testing.T) {
+	settings := ParseFolderSettings(map[string]any{"order": 2, "icon": " 📚 ", "collapsed": true, "publish": true, "description": " Client work "})
+	if settings.Order == nil || *settings.Order != 2 || settings.Icon != "📚" || settings.Collapsed == nil || !*settings.Collapsed || settings.Description != "Client work" {
+		t.Errorf("unexpected settings %+v", settings)
+	}
+
+	invalid := ParseFolderSettings(map[string]any{"order": "first", "icon": 3, "collapsed": "yes", "description": 4})
+	if invalid.Order != nil || invalid.Icon != "" || invalid.Collapsed != nil || invalid.Description != "" {
+		t.Errorf("invalid values should be ignored, got %+v", invalid)
+	}
+}
+
+func TestBuildTreeWithFolders(t *testing.T) {
+	collapsed, expanded := true, false
+	first, second := 1, 2
+	notes := []model.Note{
+		{Title: "A", Slug: "archive/a", Path: "Archive/a.md"},
+		{Title: "B", Slug: "blog/b", Path: "Blog/b.md"},
+		{Title: "C", Slug: "projects/c", Path: "Projects/c.md"},
+		{Title: "D", Slug: "projects/deep/d", Path: "Projects/deep/d.md"},
+		{Title: "E", Slug: "zettel/e", Path: "Zettel/e.md"},
+		{Title: "Root", Slug: "root", Path: "root.md"},
+	}
+	tree := BuildTreeWithFolders(notes, map[string]FolderSettings{
+		"Zettel":        {Order: &first, Icon: "🗂️"},
+		"Projects":      {Order: &second, Collapsed: &collapsed},
+		"Projects/deep": {Collapsed: &expanded},
+	})
+
+	var names []string
+	for _, child := range tree.Children {
+		names = append(names, child.Name)
+	}
+	// Ordered folders first, the others alphabetically, then the notes
+	if expected := []string{"Zettel", "Projects", "Archive", "Blog", "Root"}; fmt.Sprint(names) != fmt.Sprint(expected) {
+		t.Errorf("root children = %v, want %v", names, expected)
+	}
+
+	zettel, projects, archive := tree.Children[0], tree.Children[1], tree.Children[2]
+	if zettel.Icon != "🗂️" || !zettel.IsOpen {
+		t.Errorf("Zettel should have its icon and keep the default open state, got %+v", zettel)
+	}
+	if projects.IsOpen {
+		t.Error("Projects is collapsed in its .pluie file and should start closed")
+	}
+	if deep := projects.Children[0]; !deep.IsOpen {
+		t.Error("Projects/deep sets collapsed: false and should start open")
+	}
+	if !archive.IsOpen || archive.Icon != "" {
+		t.Errorf("folders without settings should keep the defaults, got %+v", archive)
+	}
+
+	if filtered := FilterTreeBySearch(tree, "zettel"); filtered.Children[0].Icon != "🗂️" {
+		t.Error("search results should keep the folder icons")
+	}
+}
+
+func TestFindFolderInTree(t *testing.T) {
+	tree := BuildTreeWithFolders([]model.Note{
+		{Title: "Kickoff", Slug: "projects/client-x/kickoff", Path: "Projects/Client X/Kickoff.md"},
+		{Title: "Ideas", Slug: "projects/ideas", Path: "Projects/Ideas.md"},
+		{Title: "Q&A", Slug: "study/q&a%21/q&a", Path: "Study/Q&A!/Q&A.md"},
+		{Title: "Root", Slug: "root", Path: "Root.md"},
+	}, map[string]FolderSettings{"Projects/Client X": {Description: "Client work"}})
+
+	tests := []struct {
+		slug     string
+		expected string // Path of the folder found, empty for none
+	}{
+		{slug: "projects", expected: "Projects"},
+		{slug: "projects/client-x", expected: "Projects/Client X"},
+		{slug: "/projects/client-x/", expected: "Projects/Client X"},
+		{slug: "study/q&a%21", expected: "Study/Q&A!"},
+		{slug: "projects/ideas"},
+		{slug: "projects/client"},
+		{slug: "root"},
+		{slug: ""},
+	}
+	for _, tt := range tests {
+		folder := FindFolderInTree(tree, tt.slug)
+		switch {
+		case tt.expected == "" && folder != nil:
+			t.Errorf("FindFolderInTree(%q) = %q, want no folder", tt.slug, folder.Path)
+		case tt.expected != "" && (folder == nil || folder.Path != tt.expected):
+			t.Errorf("FindFolderInTree(%q) = %+v, want %q", tt.slug, folder, tt.expected)
+		}
+	}
+
+	if folder := FindFolderInTree(tree, "projects/client-x"); folder.Description != "Client work" || FolderSlug(folder) != "projects/client-x" {
+		t.Errorf("unexpected folder %+v", folder)
+	}
+	if FolderSlug(tree) != "" {
+		t.Error("the root has no slug")
+	}
+}
+
+func TestFindFolderByPath(t *testing.T) {
+	tree := BuildTreeWithFolders([]model.Note{
+		{Title: "Kickoff", Slug: "projects/client-x/kickoff", Path: "Projects/Client X/Kickoff.md"},
+		{Title: "Ideas", Slug: "projects/ideas", Path: "Projects/Ideas.md"},
+	}, nil)
+
+	for folderPath, expected := range map[string]string{
+		"Projects":           "Projects",
+		"Projects/Client X":  "Projects/Client X",
+		"/Projects/Client X": "Projects/Client X",
+		"projects/client-x":  "",
+		"Projects/Ideas":     "",
+		"Projects/Client":    "",
+		"":                   "",
+	} {
+		folder := FindFolderByPath(tree, folderPath)
+		switch {
+		case expected == "" && folder != nil:
+			t.Errorf("FindFolderByPath(%q) = %q, want no folder", folderPath, folder.Path)
+		case expected != "" && (folder == nil || folder.Path != expected):
+			t.Errorf("FindFolderByPath(%q) = %+v, want %q", folderPath, folder, expected)
+		}
+	}
+}
+
+func TestFolderContents(t *testing.T) {
+	tree := BuildTree([]model.Note{
+		{Title: "Public", Slug: "projects/public", Path: "Projects/Public.md", IsPublic: true},
+		{Title: "Private", Slug: "projects/private", Path: "Projects/Private.md"},
+		{Title: "Open", Slug: "projects/open/open", Path: "Projects/Open/Open.md", IsPublic: true},
+		{Title: "Secret", Slug: "projects/secret/secret", Path: "Projects/Secret/Secret.md"},
+	})
+	projects := FindFolderInTree(tree, "projects")
+
+	folders, notes := FolderContents(projects, false)
+	if len(folders) != 1 || folders[0].Name != "Open" {
+		t.Errorf("expected only the subfolder with public notes, got %+v", folders)
+	}
+	if len(notes) != 1 || notes[0].Slug != "projects/public" {
+		t.Errorf("expected only the public notes, got %+v", notes)
+	}
+
+	folders, notes = FolderContents(projects, true)
+	if len(folders) != 2 || len(notes) != 2 {
+		t.Errorf("expected every subfolder and note when public by default, got %d folders and %d notes", len(folders), len(notes))
+	}
+}
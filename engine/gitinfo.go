@@ -0,0 +1,213 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/EwenQuim/pluie/model"
+)
+
+// GitCommit is the last commit of a file
+type GitCommit struct {
+	Hash string
+	Date time.Time // Committer date
+}
+
+// gitLogTimeout bounds the git log of LastCommits, on huge histories
+const gitLogTimeout = time.Minute
+
+// LastCommits returns the last commit of every file of the git repository holding dir, by path
+// relative to dir, with slashes. A single git log is run for all the files, so calling it once
+// per load of the notes is enough. Nil when git is not installed, dir is not in a repository,
+// or git fails.
+func LastCommits(dir string) map[string]GitCommit {
+	if !inGitRepository(dir) {
+		return nil
+	}
+	gitPath, err := exec.LookPath("git")
+	if err != nil {
+		slog.Debug("git not installed, the notes have no last commit", "error", err)
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), gitLogTimeout)
+	defer cancel()
+
+	// \x01 marks the commit lines, -z separates the file names with NUL so they are never quoted
+	cmd := exec.CommandContext(ctx, gitPath, "-C", dir, "log", "-z", "--name-only", "--relative", "--format=%x01%H %cI", "--", ".")
+	output, err := cmd.Output()
+	if err != nil {
+		slog.Warn("git log failed, the notes have no last commit", "dir", dir, "error", err)
+		return nil
+	}
+	return parseGitLog(output)
+}
+
+// parseGitLog reads the output of LastCommits' git log, newest commit first: the first commit
+// listing a file is its last one
+func parseGitLog(output []byte) map[string]GitCommit {
+	commits := make(map[string]GitCommit)
+	var current GitCommit
+	for field := range bytes.SplitSeq(output, []byte{0}) {
+		entry := strings.TrimPrefix(string(field), "\n")
+		if header, isCommit := strings.CutPrefix(entry, "\x01"); isCommit {
+			hash, date, _ := strings.Cut(header, " ")
+			current = GitCommit{Hash: hash}
+			current.Date, _ = time.Parse(time.RFC3339, date)
+			continue
+		}
+		if entry == "" || current.Hash == "" {
+			continue
+		}
+		if _, seen := commits[entry]; !seen {
+			commits[entry] = current
+		}
+	}
+	return commits
+}
+
+// inGitRepository reports whether dir or one of its parents has a .git folder, or a .git file
+// for worktrees and submodules
+func inGitRepository(dir string) bool {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return false
+		}
+		dir = parent
+	}
+}
+
+// ApplyLastCommits sets the LastCommit fields of the notes found in commits, by vault path
+func ApplyLastCommits(notes []model.Note, commits map[string]GitCommit) {
+	if len(commits) == 0 {
+		return
+	}
+	for i := range notes {
+		if commit, ok := commits[strings.TrimPrefix(notes[i].Path, "/")]; ok {
+			notes[i].LastCommitHash = commit.Hash
+			notes[i].LastCommitDate = commit.Date
+		}
+	}
+}
+
+// GitRevision is a commit changing a file, see FileHistory
+type GitRevision struct {
+	Hash    string
+	Date    time.Time // Committer date
+	Author  string
+	Message string // First line of the commit message
+	Path    string // Path of the file in this commit, relative to the dir, it changes with renames
+}
+
+// ErrGitUnavailable is returned when git is not installed or the folder is not in a repository
+var ErrGitUnavailable = errors.New("git is not available")
+
+const (
+	// gitFileTimeout bounds the git commands of FileHistory and FileAtRevision, run on requests
+	gitFileTimeout = 10 * time.Second
+	// maxFileHistory is the number of commits listed by FileHistory
+	maxFileHistory = 200
+)
+
+// gitHashPattern matches abbreviated and full commit hashes, never an option of git
+var gitHashPattern = regexp.MustCompile(`^[0-9a-f]{4,64}$`)
+
+// GitAvailable reports whether git is installed and dir is in a git repository
+func GitAvailable(dir string) bool {
+	_, err := gitCommand(dir)
+	return err == nil
+}
+
+// gitCommand returns the path of git, or ErrGitUnavailable
+func gitCommand(dir string) (string, error) {
+	if !inGitRepository(dir) {
+		return "", ErrGitUnavailable
+	}
+	gitPath, err := exec.LookPath("git")
+	if err != nil {
+		return "", ErrGitUnavailable
+	}
+	return gitPath, nil
+}
+
+// FileHistory returns the commits changing the file at path, relative to dir, newest first and
+// following renames, at most maxFileHistory of them
+func FileHistory(dir, path string) ([]GitRevision, error) {
+	gitPath, err := gitCommand(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), gitFileTimeout)
+	defer cancel()
+
+	// Like LastCommits, with the unit separator between the fields of the commit lines
+	cmd := exec.CommandContext(ctx, gitPath, "-C", dir, "log", "--follow", "-z", "--name-only", "--relative",
+		fmt.Sprintf("--max-count=%d", maxFileHistory), "--format=%x01%H%x1f%cI%x1f%an%x1f%s", "--", strings.TrimPrefix(path, "/"))
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git log of %s: %w", path, err)
+	}
+	return parseFileHistory(output), nil
+}
+
+// parseFileHistory reads the output of FileHistory's git log
+func parseFileHistory(output []byte) []GitRevision {
+	var revisions []GitRevision
+	for field := range bytes.SplitSeq(output, []byte{0}) {
+		entry := strings.TrimPrefix(string(field), "\n")
+		if header, isCommit := strings.CutPrefix(entry, "\x01"); isCommit {
+			parts := strings.SplitN(header, "\x1f", 4)
+			if len(parts) != 4 {
+				continue
+			}
+			revision := GitRevision{Hash: parts[0], Author: parts[2], Message: parts[3]}
+			revision.Date, _ = time.Parse(time.RFC3339, parts[1])
+			revisions = append(revisions, revision)
+			continue
+		}
+		if n := len(revisions); entry != "" && n > 0 && revisions[n-1].Path == "" {
+			revisions[n-1].Path = entry
+		}
+	}
+	return revisions
+}
+
+// FileAtRevision returns the content of the file at path, relative to dir, in the commit hash
+func FileAtRevision(dir, path, hash string) (string, error) {
+	if !gitHashPattern.MatchString(hash) {
+		return "", fmt.Errorf("invalid commit hash %q", hash)
+	}
+	gitPath, err := gitCommand(dir)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), gitFileTimeout)
+	defer cancel()
+
+	// ./ makes the path relative to dir instead of the root of the repository
+	cmd := exec.CommandContext(ctx, gitPath, "-C", dir, "show", hash+":./"+strings.TrimPrefix(path, "/"))
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git show of %s at %s: %w", path, hash, err)
+	}
+	return string(output), nil
+}
@@ -0,0 +1,144 @@
+package engine
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/EwenQuim/pluie/model"
+)
+
+func TestParseGitLog(t *testing.T) {
+	output := "\x01bbb 2024-06-02T10:00:00+02:00\x00\nnotes/Q&A\"é.md\x00todo.md\x00" +
+		"\x01aaa 2024-01-01T09:00:00Z\x00\ntodo.md\x00old.md\x00"
+
+	commits := parseGitLog([]byte(output))
+	if len(commits) != 3 {
+		t.Fatalf("commits = %v, want 3 files", commits)
+	}
+	if commit := commits["todo.md"]; commit.Hash != "bbb" || !commit.Date.Equal(time.Date(2024, 6, 2, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("todo.md = %+v, want its newest commit", commit)
+	}
+	if commits[`notes/Q&A"é.md`].Hash != "bbb" {
+		t.Errorf("file names should be read unquoted, got %v", commits)
+	}
+	if commits["old.md"].Hash != "aaa" {
+		t.Errorf("old.md = %+v, want aaa", commits["old.md"])
+	}
+}
+
+func TestLastCommits(t *testing.T) {
+	if commits := LastCommits(t.TempDir()); commits != nil {
+		t.Errorf("LastCommits() = %v outside a git repository, want nil", commits)
+	}
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	repo := t.TempDir()
+	vault := filepath.Join(repo, "vault")
+	if err := os.MkdirAll(filepath.Join(vault, "Notes"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(vault, "Notes", "Garden.md"), []byte("Tomatoes\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", repo, "-c", "user.name=Test", "-c", "user.email=test@example.com"}, args...)...)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, output)
+		}
+	}
+	git("init", "-q")
+	git("add", "-A")
+	git("commit", "-q", "-m", "Add garden")
+
+	// The vault is a folder of the repository, paths are relative to it
+	commits := LastCommits(vault)
+	commit, ok := commits["Notes/Garden.md"]
+	if !ok || len(commit.Hash) != 40 || commit.Date.IsZero() {
+		t.Fatalf("LastCommits() = %v, want the commit of Notes/Garden.md", commits)
+	}
+
+	notes := []model.Note{{Path: "Notes/Garden.md"}, {Path: "Uncommitted.md"}}
+	ApplyLastCommits(notes, commits)
+	if notes[0].LastCommitHash != commit.Hash || !notes[0].LastCommitDate.Equal(commit.Date) {
+		t.Errorf("note = %+v, want its last commit", notes[0])
+	}
+	if notes[1].LastCommitHash != "" {
+		t.Errorf("uncommitted note = %+v, want no commit", notes[1])
+	}
+}
+
+func TestParseFileHistory(t *testing.T) {
+	output := "\x01bbb\x1f2024-06-02T10:00:00+02:00\x1fAda\x1fRename: the garden\x00\x00\nGarden.md\x00" +
+		"\x01aaa\x1f2024-01-01T09:00:00Z\x1fBob\x1fAdd\x00\x00\nOld garden.md\x00"
+
+	revisions := parseFileHistory([]byte(output))
+	if len(revisions) != 2 {
+		t.Fatalf("revisions = %+v, want 2", revisions)
+	}
+	if rev := revisions[0]; rev.Hash != "bbb" || rev.Author != "Ada" || rev.Message != "Rename: the garden" || rev.Path != "Garden.md" ||
+		!rev.Date.Equal(time.Date(2024, 6, 2, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("newest revision = %+v", rev)
+	}
+	if revisions[1].Path != "Old garden.md" {
+		t.Errorf("oldest revision = %+v, want the path before the rename", revisions[1])
+	}
+}
+
+func TestFileHistory(t *testing.T) {
+	if _, err := FileHistory(t.TempDir(), "Garden.md"); !errors.Is(err, ErrGitUnavailable) {
+		t.Errorf("FileHistory() error = %v outside a git repository, want ErrGitUnavailable", err)
+	}
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	repo := t.TempDir()
+	vault := filepath.Join(repo, "vault")
+	if err := os.MkdirAll(vault, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(vault, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", repo, "-c", "user.name=Test", "-c", "user.email=test@example.com"}, args...)...)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, output)
+		}
+	}
+	git("init", "-q")
+	write("Old garden.md", "Tomatoes\n")
+	git("add", "-A")
+	git("commit", "-q", "-m", "Add garden")
+	git("mv", "vault/Old garden.md", "vault/Garden.md")
+	git("commit", "-q", "-m", "Rename garden")
+	write("Garden.md", "Tomatoes\nBeans\n")
+	git("commit", "-q", "-a", "-m", "Plant beans")
+
+	revisions, err := FileHistory(vault, "Garden.md")
+	if err != nil {
+		t.Fatalf("FileHistory() error: %v", err)
+	}
+	if len(revisions) != 3 || revisions[0].Message != "Plant beans" || revisions[0].Author != "Test" || revisions[2].Path != "Old garden.md" {
+		t.Fatalf("FileHistory() = %+v, want the 3 commits, through the rename", revisions)
+	}
+
+	content, err := FileAtRevision(vault, revisions[2].Path, revisions[2].Hash)
+	if err != nil || content != "Tomatoes\n" {
+		t.Errorf("FileAtRevision() = %q, %v, want the first version", content, err)
+	}
+	if _, err := FileAtRevision(vault, "Garden.md", "--output=/tmp/x"); err == nil {
+		t.Error("FileAtRevision() should refuse what is not a commit hash")
+	}
+}
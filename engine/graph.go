@@ -0,0 +1,63 @@
+package engine
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+
+	"github.com/EwenQuim/pluie/model"
+)
+
+// GraphNode is a note of the link graph
+type GraphNode struct {
+	Slug  string
+	Title string
+	Tags  []string // Lowercase and sorted, never nil
+}
+
+// GraphEdge is a wikilink from the note From to the note To, by slug
+type GraphEdge struct {
+	From string
+	To   string
+}
+
+// Graph is the link structure of the notes
+type Graph struct {
+	Nodes []GraphNode // Sorted by slug
+	Edges []GraphEdge // Sorted by From, then To
+}
+
+// BuildGraph builds the link graph of the public notes from their ReferencedBy data, see
+// BuildBackreferences. Notes without links are nodes without edges. Links from or to notes
+// left out of the graph are dropped, and so are links of a note to itself.
+func BuildGraph(notes []model.Note, publicByDefault bool) Graph {
+	graph := Graph{Nodes: []GraphNode{}, Edges: []GraphEdge{}}
+
+	included := make(map[string]bool, len(notes))
+	for _, note := range notes {
+		if !IsVisible(note, publicByDefault) {
+			continue
+		}
+		included[note.Slug] = true
+
+		graph.Nodes = append(graph.Nodes, GraphNode{Slug: note.Slug, Title: note.Title, Tags: NoteTags(note)})
+	}
+
+	for _, note := range notes {
+		if !included[note.Slug] {
+			continue
+		}
+		for _, ref := range note.ReferencedBy {
+			if included[ref.Slug] && ref.Slug != note.Slug {
+				graph.Edges = append(graph.Edges, GraphEdge{From: ref.Slug, To: note.Slug})
+			}
+		}
+	}
+
+	slices.SortFunc(graph.Nodes, func(a, b GraphNode) int { return strings.Compare(a.Slug, b.Slug) })
+	slices.SortFunc(graph.Edges, func(a, b GraphEdge) int {
+		return cmp.Or(strings.Compare(a.From, b.From), strings.Compare(a.To, b.To))
+	})
+	graph.Edges = slices.Compact(graph.Edges)
+	return graph
+}
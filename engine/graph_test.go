@@ -0,0 +1,77 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/EwenQuim/pluie/model"
+)
+
+func TestBuildGraph(t *testing.T) {
+	notes := BuildBackreferences([]model.Note{
+		{Title: "Alpha", Slug: "alpha", IsPublic: true, Content: "See [[Beta]] and [[Beta|the beta note]], and [[Alpha]]. #Go", Metadata: map[string]any{"tags": []any{"go", "notes"}}},
+		{Title: "Beta", Slug: "beta", IsPublic: true, Content: "Back to [[Alpha]], on to [[Secret]]"},
+		{Title: "Lonely", Slug: "lonely", IsPublic: true, Content: "No links here"},
+		{Title: "Secret", Slug: "secret", IsPublic: false, Content: "Links to [[Alpha]]", Metadata: map[string]any{"tags": []any{"hidden"}}},
+	})
+
+	graph := BuildGraph(notes, false)
+
+	expectedNodes := []GraphNode{
+		{Slug: "alpha", Title: "Alpha", Tags: []string{"go", "notes"}},
+		{Slug: "beta", Title: "Beta", Tags: []string{}},
+		{Slug: "lonely", Title: "Lonely", Tags: []string{}},
+	}
+	if !reflect.DeepEqual(graph.Nodes, expectedNodes) {
+		t.Errorf("nodes = %+v, want %+v", graph.Nodes, expectedNodes)
+	}
+
+	// Both directions of a bidirectional link, once each: no self link, nothing from or to the private note
+	expectedEdges := []GraphEdge{
+		{From: "alpha", To: "beta"},
+		{From: "beta", To: "alpha"},
+	}
+	if !reflect.DeepEqual(graph.Edges, expectedEdges) {
+		t.Errorf("edges = %+v, want %+v", graph.Edges, expectedEdges)
+	}
+}
+
+func TestBuildGraph_Orphans(t *testing.T) {
+	notes := BuildBackreferences([]model.Note{
+		{Title: "One", Slug: "one", IsPublic: true},
+		{Title: "Two", Slug: "two", IsPublic: true, Content: "[[Missing]] is not a note"},
+	})
+
+	graph := BuildGraph(notes, false)
+
+	if len(graph.Nodes) != 2 {
+		t.Errorf("expected the 2 orphan notes as nodes, got %+v", graph.Nodes)
+	}
+	if graph.Edges == nil || len(graph.Edges) != 0 {
+		t.Errorf("expected empty, non-nil edges, got %#v", graph.Edges)
+	}
+}
+
+func TestBuildGraph_PublicByDefault(t *testing.T) {
+	// With PUBLIC_BY_DEFAULT, the given notes are the public ones and IsPublic is not set
+	notes := BuildBackreferences([]model.Note{
+		{Title: "One", Slug: "one", Content: "[[Two]]"},
+		{Title: "Two", Slug: "two"},
+	})
+
+	graph := BuildGraph(notes, true)
+
+	if len(graph.Nodes) != 2 || !reflect.DeepEqual(graph.Edges, []GraphEdge{{From: "one", To: "two"}}) {
+		t.Errorf("unexpected graph %+v", graph)
+	}
+	if graph := BuildGraph(notes, false); len(graph.Nodes) != 0 || len(graph.Edges) != 0 {
+		t.Errorf("notes not marked public should be left out, got %+v", graph)
+	}
+}
+
+func TestBuildGraph_Empty(t *testing.T) {
+	graph := BuildGraph(nil, false)
+	if graph.Nodes == nil || graph.Edges == nil {
+		t.Errorf("expected non-nil nodes and edges, got %#v", graph)
+	}
+}
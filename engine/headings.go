@@ -0,0 +1,75 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+)
+
+// HeadingIDs gives the headings of a page unique anchors, in page order: the second heading
+// with the anchor "intro" gets "intro-2". The note page, its table of contents and section
+// links all number duplicates this way.
+type HeadingIDs map[string]int
+
+// Next returns the anchor of the next heading of the page, from its markdown or plain text
+func (ids HeadingIDs) Next(text string) string {
+	base := SlugifyHeading(PlainText(text))
+	ids[base]++
+	if count := ids[base]; count > 1 {
+		return fmt.Sprintf("%s-%d", base, count)
+	}
+	return base
+}
+
+// HeadingAnchor returns the anchor of the heading of content a [[Note#heading]] link points to:
+// the first heading with that text, or else the first one with the same anchor slug, like
+// "getting-started" for "Getting Started!". Block references like "^blockid" are not headings.
+func HeadingAnchor(content, heading string) (string, bool) {
+	heading = strings.TrimSpace(heading)
+	if heading == "" || strings.HasPrefix(heading, "^") {
+		return "", false
+	}
+
+	ids := HeadingIDs{}
+	wanted := SlugifyHeading(PlainText(heading))
+	var slugMatch string
+	for _, section := range SplitSections(content) {
+		if section.Level == 0 {
+			continue
+		}
+		id := ids.Next(section.Heading)
+		if section.Heading == heading || PlainText(section.Heading) == PlainText(heading) {
+			return id, true
+		}
+		if slugMatch == "" && wanted != "" && SlugifyHeading(PlainText(section.Heading)) == wanted {
+			slugMatch = id
+		}
+	}
+	return slugMatch, slugMatch != ""
+}
+
+// SectionHeadings returns the headings of content with their anchors on its own page, or the
+// ones of its section under heading, subsections included, matched like SectionByHeading
+func SectionHeadings(content, heading string) []TOCItem {
+	wanted := SlugifyHeading(heading)
+	ids := HeadingIDs{}
+	var items []TOCItem
+	sectionLevel := 0
+	for _, section := range SplitSections(content) {
+		if section.Level == 0 {
+			continue
+		}
+		id := ids.Next(section.Heading)
+		if heading != "" {
+			if sectionLevel == 0 {
+				if wanted == "" || SlugifyHeading(section.Heading) != wanted {
+					continue
+				}
+				sectionLevel = section.Level
+			} else if section.Level <= sectionLevel {
+				break
+			}
+		}
+		items = append(items, TOCItem{ID: id, Text: PlainText(section.Heading), Level: section.Level})
+	}
+	return items
+}
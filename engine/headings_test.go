@@ -0,0 +1,83 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHeadingIDs(t *testing.T) {
+	ids := HeadingIDs{}
+	var got []string
+	for _, text := range []string{"Setup", "Intro", "Setup!", "**Setup**", "See [[Other|the other]]"} {
+		got = append(got, ids.Next(text))
+	}
+
+	expected := []string{"setup", "intro", "setup-2", "setup-3", "see-the-other"}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("Next() #%d = %q, want %q", i, got[i], expected[i])
+		}
+	}
+}
+
+func TestHeadingAnchor(t *testing.T) {
+	content := "## Install\n\n```sh\n# Install\n```\n\n## Usage\n\n### Install!\n\n## Getting Started"
+
+	tests := []struct {
+		name     string
+		heading  string
+		expected string
+	}{
+		{name: "exact text", heading: "Usage", expected: "usage"},
+		{name: "duplicate anchor", heading: "Install!", expected: "install-2"},
+		{name: "first of duplicates", heading: "Install", expected: "install"},
+		{name: "same anchor slug", heading: "getting started", expected: "getting-started"},
+		{name: "missing heading", heading: "Nowhere", expected: ""},
+		{name: "block reference", heading: "^a1b2c3", expected: ""},
+		{name: "empty", heading: " ", expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := HeadingAnchor(content, tt.heading)
+			if got != tt.expected || ok != (tt.expected != "") {
+				t.Errorf("HeadingAnchor(%q) = %q, %v, want %q", tt.heading, got, ok, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSectionHeadings(t *testing.T) {
+	content := "# Guide\n\n## Install\n\n### Linux\n\n## Usage\n\n### Install"
+
+	tests := []struct {
+		name     string
+		heading  string
+		expected []TOCItem
+	}{
+		{name: "whole note", heading: "", expected: []TOCItem{
+			{ID: "guide", Text: "Guide", Level: 1},
+			{ID: "install", Text: "Install", Level: 2},
+			{ID: "linux", Text: "Linux", Level: 3},
+			{ID: "usage", Text: "Usage", Level: 2},
+			{ID: "install-2", Text: "Install", Level: 3},
+		}},
+		{name: "section and subsections", heading: "Usage", expected: []TOCItem{
+			{ID: "usage", Text: "Usage", Level: 2},
+			{ID: "install-2", Text: "Install", Level: 3},
+		}},
+		{name: "first matching section", heading: "install", expected: []TOCItem{
+			{ID: "install", Text: "Install", Level: 2},
+			{ID: "linux", Text: "Linux", Level: 3},
+		}},
+		{name: "missing section", heading: "Nowhere", expected: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SectionHeadings(content, tt.heading); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("SectionHeadings(%q) = %+v, want %+v", tt.heading, got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package engine
+
+import (
+	"log/slog"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/EwenQuim/pluie/model"
+)
+
+// LinkIndex finds the note of a wikilink target without going through every note. Targets are
+// matched against the titles, then the aliases, the paths in the vault like "Blog/Post" and
+// the file names, exactly first, then like Obsidian in any case, with any spaces and with or
+// without ".md", then without accents like "Economie" for "Économie".
+type LinkIndex struct {
+	layers []linkLayer // In lookup order, see NewLinkIndex
+}
+
+// linkNormalizations are the ways names are compared, in lookup order: as they are, normalized
+// like Obsidian does, then without accents either
+var linkNormalizations = []func(string) string{
+	func(name string) string { return name },
+	normalizeLinkName,
+	func(name string) string { return removeLatinAccents(normalizeLinkName(name)) },
+}
+
+// normalizeLinkName lowercases a wikilink target or a name of a note, collapses its spaces and
+// removes its ".md" extension, so "My  Note.md" matches "my note"
+func normalizeLinkName(name string) string {
+	return strings.Join(strings.Fields(strings.TrimSuffix(strings.ToLower(name), ".md")), " ")
+}
+
+// ambiguousLinksWarned are the ambiguous wikilinks already logged, see LinkIndex.Find
+var ambiguousLinksWarned sync.Map
+
+// NewLinkIndex indexes the notes for their wikilinks, the notes found point into notes. Aliases
+// are given to notes like BuildAliasIndex does. When several notes have a title or a file name,
+// the one whose file is named like it wins, then the one with the shallowest path, then the one
+// with the shortest slug.
+func NewLinkIndex(notes []*model.Note) *LinkIndex {
+	values := make([]model.Note, len(notes))
+	for i, note := range notes {
+		values[i] = *note
+	}
+	aliases, _ := BuildAliasIndex(values)
+	return newLinkIndex(notes, aliases)
+}
+
+// newLinkIndex is NewLinkIndex with the aliases of the notes already built
+func newLinkIndex(notes []*model.Note, aliases map[string]string) *LinkIndex {
+	bySlug := make(map[string]*model.Note, len(notes))
+	for _, note := range notes {
+		bySlug[note.Slug] = note
+	}
+
+	index := &LinkIndex{}
+	for _, normalize := range linkNormalizations {
+		titles, aliased, paths, names := linkLayer{normalize: normalize}, linkLayer{normalize: normalize}, linkLayer{normalize: normalize}, linkLayer{normalize: normalize}
+		for _, note := range notes {
+			titles.add(note.Title, note)
+			paths.add(notePathName(note.Path), note)
+			names.add(path.Base(notePathName(note.Path)), note)
+		}
+		for alias, slug := range aliases {
+			if note := bySlug[slug]; note != nil {
+				aliased.add(alias, note)
+			}
+		}
+		index.layers = append(index.layers, titles, aliased, paths, names)
+	}
+	return index
+}
+
+// FindLink returns the note of the wikilink target, like Find, or the note of a section link
+// like [[Title#Heading]]. Titles containing a # are matched as a whole first.
+func (index *LinkIndex) FindLink(target string) *model.Note {
+	if note := index.Find(target); note != nil {
+		return note
+	}
+	if title, _, isSection := strings.Cut(target, "#"); isSection {
+		return index.Find(strings.TrimSpace(title))
+	}
+	return nil
+}
+
+// linkLayer maps the names of one kind, like the titles, to their note
+type linkLayer struct {
+	normalize func(string) string // One of linkNormalizations
+	targets   map[string]*model.Note
+	ambiguous map[string][]string // Slugs of the notes of the names several notes have
+}
+
+// add gives name to note, unless a note better matching it has it, see NewLinkIndex
+func (l *linkLayer) add(name string, note *model.Note) {
+	if name == "" || name == "." {
+		return
+	}
+	name = l.normalize(name)
+	if l.targets == nil {
+		l.targets = make(map[string]*model.Note)
+	}
+	current := l.targets[name]
+	if current != nil && current.Slug != note.Slug {
+		if l.ambiguous == nil {
+			l.ambiguous = make(map[string][]string)
+		}
+		if len(l.ambiguous[name]) == 0 {
+			l.ambiguous[name] = []string{current.Slug}
+		}
+		l.ambiguous[name] = append(l.ambiguous[name], note.Slug)
+	}
+	if current == nil || l.prefer(name, note, current) {
+		l.targets[name] = note
+	}
+}
+
+// prefer reports whether note matches name better than current
+func (l *linkLayer) prefer(name string, note, current *model.Note) bool {
+	named, currentNamed := l.fileName(note) == name, l.fileName(current) == name
+	if named != currentNamed {
+		return named
+	}
+	if depth, currentDepth := strings.Count(note.Path, "/"), strings.Count(current.Path, "/"); depth != currentDepth {
+		return depth < currentDepth
+	}
+	if len(note.Slug) != len(current.Slug) {
+		return len(note.Slug) < len(current.Slug)
+	}
+	return note.Slug < current.Slug
+}
+
+// fileName is the name of the file of note without its extension, normalized like the names
+func (l *linkLayer) fileName(note *model.Note) string {
+	return l.normalize(path.Base(notePathName(note.Path)))
+}
+
+// Find returns the note of the wikilink target, nil if there is none. A target several notes
+// match is logged once, with the note chosen.
+func (index *LinkIndex) Find(target string) *model.Note {
+	if index == nil {
+		return nil
+	}
+	layersPerNormalization := len(index.layers) / len(linkNormalizations)
+	for i, normalize := range linkNormalizations {
+		key := normalize(target)
+		for _, layer := range index.layers[i*layersPerNormalization : (i+1)*layersPerNormalization] {
+			if note, ok := layer.targets[key]; ok {
+				if slugs := layer.ambiguous[key]; len(slugs) > 0 {
+					warnAmbiguousLink(target, slugs, note.Slug)
+				}
+				return note
+			}
+		}
+	}
+	return nil
+}
+
+// warnAmbiguousLink logs the wikilink target several notes match, once per target and notes
+func warnAmbiguousLink(target string, slugs []string, chosen string) {
+	if _, warned := ambiguousLinksWarned.LoadOrStore(target+"\x00"+strings.Join(slugs, "\x00"), true); !warned {
+		slog.Warn("Wikilink matches several notes", "link", target, "notes", slugs, "chosen", chosen)
+	}
+}
+
+// notePathName is the path of a note in the vault without its extension, like "Blog/Post"
+func notePathName(vaultPath string) string {
+	return strings.TrimSuffix(strings.Trim(vaultPath, "/"), ".md")
+}
+
+// linkIndex returns the index of the notes of the tree, built with it for the roots, see
+// BuildTreeWithFolders
+func (t *TreeNode) linkIndex() *LinkIndex {
+	if t != nil && t.links != nil {
+		return t.links
+	}
+	var notes []*model.Note
+	t.AllNotes(func(node *TreeNode) bool {
+		if node.Note != nil {
+			notes = append(notes, node.Note)
+		}
+		return true
+	})
+	return NewLinkIndex(notes)
+}
+
+// LinkIndex returns the index of the wikilinks of the public notes, built once per notes update
+func (ns *NotesService) LinkIndex() *LinkIndex {
+	ns.mu.RLock()
+	defer ns.mu.RUnlock()
+
+	return ns.links
+}
+
+// FindNoteByLink returns the public note the wikilink target leads to, like [[Title]] or
+// [[Title#Heading]], nil if there is none
+func (ns *NotesService) FindNoteByLink(target string) *model.Note {
+	return ns.LinkIndex().FindLink(target)
+}
@@ -0,0 +1,174 @@
+package engine
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/EwenQuim/pluie/model"
+)
+
+func TestLinkIndex_Find(t *testing.T) {
+	notes := []model.Note{
+		{Title: "Go", Slug: "languages/go", Path: "languages/Go.md", Aliases: []string{"Golang"}},
+		{Title: "Go", Slug: "games/board/weiqi", Path: "games/board/Weiqi.md"},
+		{Title: "Setup", Slug: "projects/pluie/setup", Path: "projects/pluie/Setup.md"},
+		{Title: "Setup", Slug: "home/setup", Path: "home/Setup.md"},
+		{Title: "Reading list", Slug: "reading", Path: "Reading.md"},
+		{Title: "go", Slug: "go-lowercase", Path: "go-lowercase.md"},
+		{Title: "Économie française", Slug: "economie", Path: "Économie.md"},
+		{Title: "Resume", Slug: "resume", Path: "Resume.md"},
+		{Title: "Résumé", Slug: "resume-fr", Path: "Résumé.md"},
+	}
+	tree := BuildTreeWithFolders(notes, nil)
+	index := tree.linkIndex()
+
+	tests := []struct {
+		target string
+		slug   string // Empty when no note is found
+	}{
+		{"Go", "languages/go"},                           // Named like the title before the shorter slug
+		{"go", "go-lowercase"},                           // Exact title before any case
+		{"GO", "languages/go"},                           // Any case, named like the title
+		{"Setup", "home/setup"},                          // Both named like the title: the shortest slug
+		{"projects/pluie/Setup", "projects/pluie/setup"}, // Path in the vault
+		{"Weiqi", "games/board/weiqi"},                   // File name
+		{"golang", "languages/go"},                       // Alias in any case
+		{"reading LIST", "reading"},                      // Title in any case
+		{"Reading", "reading"},                           // File name when the title differs
+		{"economie francaise", "economie"},               // Title without accents
+		{"E\u0301conomie franc\u0327aise", "economie"},   // Title with decomposed accents
+		{"economie", "economie"},                         // File name without accents
+		{"Résumé", "resume-fr"},                          // Exact before without accents
+		{"resume", "resume"},
+		{"Missing", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			note := index.Find(tt.target)
+			switch {
+			case note == nil && tt.slug != "":
+				t.Errorf("Find(%q) = nil, want %q", tt.target, tt.slug)
+			case note != nil && note.Slug != tt.slug:
+				t.Errorf("Find(%q) = %q, want %q", tt.target, note.Slug, tt.slug)
+			}
+		})
+	}
+}
+
+func TestLinkIndex_Deterministic(t *testing.T) {
+	notes := []model.Note{
+		{Title: "Inbox", Slug: "b/inbox", Path: "b/Notes.md"},
+		{Title: "Inbox", Slug: "a/inbox", Path: "a/Notes.md"},
+		{Title: "Inbox", Slug: "c/inbox", Path: "c/Notes.md"},
+	}
+
+	for i := range 20 {
+		// Whatever the order of the notes, the same one wins
+		shuffled := append([]model.Note(nil), notes[i%3:]...)
+		shuffled = append(shuffled, notes[:i%3]...)
+		pointers := make([]*model.Note, len(shuffled))
+		for j := range shuffled {
+			pointers[j] = &shuffled[j]
+		}
+
+		if note := NewLinkIndex(pointers).Find("Inbox"); note == nil || note.Slug != "a/inbox" {
+			t.Fatalf("Find(%q) = %v, want a/inbox", "Inbox", note)
+		}
+	}
+}
+
+func TestLinkIndex_Normalized(t *testing.T) {
+	notes := []*model.Note{
+		{Title: "Plans", Slug: "work/deep/plans", Path: "Work/Deep/Plans.md"},
+		{Title: "Plans", Slug: "home/plans-for-the-house", Path: "Home/Plans.md"},
+		{Title: "Note  with  double  spaces", Slug: "spaces", Path: "Spaces.md"},
+	}
+	index := NewLinkIndex(notes)
+
+	for target, slug := range map[string]string{
+		"plans":                      "home/plans-for-the-house", // The shallower path before the shorter slug
+		"Note with double spaces":    "spaces",
+		"note   WITH double spaces ": "spaces",
+		"Spaces.md":                  "spaces",
+		"work/deep/PLANS.md":         "work/deep/plans",
+	} {
+		if note := index.Find(target); note == nil || note.Slug != slug {
+			t.Errorf("Find(%q) = %v, want %q", target, note, slug)
+		}
+	}
+
+	// Ambiguous links are logged once
+	key := "plans\x00work/deep/plans\x00home/plans-for-the-house"
+	ambiguousLinksWarned.Delete(key)
+	index.Find("plans")
+	if _, warned := ambiguousLinksWarned.Load(key); !warned {
+		t.Error("the ambiguous link should be logged")
+	}
+}
+
+func TestLinkIndex_FindLink(t *testing.T) {
+	notes := []*model.Note{
+		{Title: "Guide", Slug: "guide", Path: "Guide.md"},
+		{Title: "C#", Slug: "c-sharp", Path: "C#.md"},
+	}
+	index := NewLinkIndex(notes)
+
+	for target, slug := range map[string]string{
+		"Guide#Install":   "guide",
+		"Guide # Install": "guide",
+		"Guide#^block":    "guide",
+		"C#":              "c-sharp", // Titles containing a # are matched as a whole first
+	} {
+		if note := index.FindLink(target); note == nil || note.Slug != slug {
+			t.Errorf("FindLink(%q) = %v, want %q", target, note, slug)
+		}
+	}
+	if note := index.FindLink("Missing#Install"); note != nil {
+		t.Errorf("FindLink(%q) = %q, want nil", "Missing#Install", note.Slug)
+	}
+
+	var nilIndex *LinkIndex
+	if note := nilIndex.FindLink("Guide"); note != nil {
+		t.Errorf("nil index found %q", note.Slug)
+	}
+}
+
+func TestNotesService_LinkIndex(t *testing.T) {
+	notes := []model.Note{{Title: "Old", Slug: "old", Path: "Old.md"}}
+	ns := NewNotesService(&map[string]model.Note{"old": notes[0]}, BuildTreeWithFolders(notes, nil), nil)
+
+	if note := ns.FindNoteByLink("old"); note == nil || note.Slug != "old" {
+		t.Fatalf("FindNoteByLink(%q) = %v, want old", "old", note)
+	}
+	if got := ns.ParseWikiLinks("[[Old]] [[New]]"); got != "[Old](/old) New" {
+		t.Errorf("ParseWikiLinks() = %q", got)
+	}
+
+	// The index is rebuilt with the data
+	notes = []model.Note{{Title: "New", Slug: "new", Path: "New.md"}}
+	ns.UpdateData(&map[string]model.Note{"new": notes[0]}, BuildTreeWithFolders(notes, nil), nil)
+
+	if note := ns.FindNoteByLink("Old"); note != nil {
+		t.Errorf("FindNoteByLink(%q) = %q after the update, want nil", "Old", note.Slug)
+	}
+	if got := ns.ParseWikiLinks("[[Old]] [[New]]"); got != "Old [New](/new)" {
+		t.Errorf("ParseWikiLinks() = %q after the update", got)
+	}
+	if got := ns.ParseWikiLinksInMetadata(map[string]any{"up": "[[new]]"})["up"]; got != "[new](/new)" {
+		t.Errorf("ParseWikiLinksInMetadata() = %q", got)
+	}
+}
+
+func BenchmarkNewLinkIndex(b *testing.B) {
+	notes := make([]*model.Note, 5000)
+	for i := range notes {
+		title := fmt.Sprintf("Note %d", i)
+		notes[i] = &model.Note{Title: title, Slug: fmt.Sprintf("folder-%d/note-%d", i%50, i), Path: fmt.Sprintf("folder-%d/%s.md", i%50, title)}
+	}
+
+	for b.Loop() {
+		NewLinkIndex(notes)
+	}
+}
@@ -0,0 +1,68 @@
+package engine
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/EwenQuim/pluie/model"
+)
+
+// OutgoingLink is a wikilink of a note, resolved against the tree
+type OutgoingLink struct {
+	Title string      // Linked title, without the "#heading" of section links
+	Note  *model.Note // Linked note, nil when the link is broken
+}
+
+// Broken reports whether the link points to no note of the tree
+func (link OutgoingLink) Broken() bool {
+	return link.Note == nil
+}
+
+// OutgoingLinks returns the notes the wikilinks of the content and metadata of note point to,
+// once each, then the broken links, both sorted by title. Section links count as links to
+// their note, [[#heading]] links and attachment embeds are left out, and so are links of the
+// note to itself. Private targets are hidden: unless publicByDefault, only notes marked public
+// are listed.
+func OutgoingLinks(note model.Note, tree *TreeNode, publicByDefault bool) []OutgoingLink {
+	targets := append(extractWikiLinks(note.Content), extractWikiLinksFromMetadata(note.Metadata)...)
+
+	index := tree.linkIndex()
+	seen := make(map[string]bool)
+	var linked, broken []OutgoingLink
+	for _, target := range targets {
+		if _, resolved := note.Attachments[attachmentTarget(target)]; resolved || IsAttachment(attachmentTarget(target)) {
+			continue
+		}
+
+		// Titles containing a # are matched as a whole first, like ParseWikiLinks does
+		title := target
+		found := index.Find(title)
+		if before, _, isSection := strings.Cut(target, "#"); found == nil && isSection {
+			title = strings.TrimSpace(before)
+			if title == "" {
+				continue
+			}
+			found = index.Find(title)
+		}
+
+		switch {
+		case found == nil:
+			if !seen["?"+title] {
+				seen["?"+title] = true
+				broken = append(broken, OutgoingLink{Title: title})
+			}
+		case found.Slug == note.Slug || !IsVisible(*found, publicByDefault):
+			continue
+		case !seen[found.Slug]:
+			seen[found.Slug] = true
+			linked = append(linked, OutgoingLink{Title: found.Title, Note: found})
+		}
+	}
+
+	byTitle := func(a, b OutgoingLink) int {
+		return strings.Compare(strings.ToLower(a.Title), strings.ToLower(b.Title))
+	}
+	slices.SortFunc(linked, byTitle)
+	slices.SortFunc(broken, byTitle)
+	return append(linked, broken...)
+}
@@ -0,0 +1,67 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/EwenQuim/pluie/model"
+)
+
+func TestOutgoingLinks(t *testing.T) {
+	note := model.Note{
+		Title:    "Home",
+		Slug:     "home",
+		IsPublic: true,
+		Content: "See [[Recipes#Bread]], [[Recipes|the recipes]] and [[Garden]]. Also [[Missing note]], " +
+			"[[Diary]], [[#Local heading]], [[Home]] and ![[photo.png]].",
+		Metadata: map[string]any{"related": []any{"[[tools/Spade]]", "[[Gone]]"}},
+	}
+	notes := []model.Note{
+		note,
+		{Title: "Garden", Slug: "garden", IsPublic: true},
+		{Title: "Recipes", Slug: "recipes", IsPublic: true, Content: "## Bread"},
+		{Title: "tools/Spade", Slug: "tools/spade", IsPublic: true},
+		{Title: "Diary", Slug: "diary", IsPublic: false},
+	}
+	tree := BuildTree(notes)
+
+	links := OutgoingLinks(note, tree, false)
+
+	var got []string
+	for _, link := range links {
+		if link.Broken() {
+			got = append(got, "broken:"+link.Title)
+		} else {
+			got = append(got, link.Note.Slug)
+		}
+	}
+	expected := []string{"garden", "recipes", "tools/spade", "broken:Gone", "broken:Missing note"}
+	if len(got) != len(expected) {
+		t.Fatalf("links = %v, want %v", got, expected)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("links = %v, want %v", got, expected)
+			break
+		}
+	}
+}
+
+func TestOutgoingLinks_PublicByDefault(t *testing.T) {
+	// With PUBLIC_BY_DEFAULT, the notes of the tree are the public ones and IsPublic is not set
+	tree := BuildTree([]model.Note{{Title: "Garden", Slug: "garden"}})
+	note := model.Note{Title: "Home", Slug: "home", Content: "[[Garden]]"}
+
+	if links := OutgoingLinks(note, tree, true); len(links) != 1 || links[0].Note == nil || links[0].Note.Slug != "garden" {
+		t.Errorf("expected the garden note, got %+v", links)
+	}
+	if links := OutgoingLinks(note, tree, false); len(links) != 0 {
+		t.Errorf("notes not marked public should be hidden, got %+v", links)
+	}
+}
+
+func TestOutgoingLinks_NoLinks(t *testing.T) {
+	tree := BuildTree([]model.Note{{Title: "Garden", Slug: "garden", IsPublic: true}})
+	if links := OutgoingLinks(model.Note{Title: "Home", Slug: "home", Content: "No links"}, tree, false); len(links) != 0 {
+		t.Errorf("expected no links, got %+v", links)
+	}
+}
@@ -0,0 +1,138 @@
+package engine
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/EwenQuim/pluie/model"
+)
+
+var (
+	// mentionSkipRegex matches the parts of a line that never count as a mention: wikilinks and
+	// embeds, markdown links and inline code
+	mentionSkipRegex = regexp.MustCompile("!?\\[\\[[^\\]]*\\]\\]|!?\\[[^\\]]*\\]\\([^)]*\\)|`[^`]*`")
+)
+
+// UnlinkedMention is a note mentioning the title of another note without linking to it
+type UnlinkedMention struct {
+	Note    model.Note
+	Snippet string // Plain text around the first mention, on one line
+	Start   int    // Byte offset of the mention in Snippet
+	End     int    // Byte offset of the end of the mention in Snippet
+	Count   int    // Mentions in the note
+}
+
+// FindUnlinkedMentions returns the notes whose content mentions the title of target, as a whole
+// word and in any case, without linking to it: the notes of target.ReferencedBy are skipped, like
+// target itself, and wikilinks, markdown links and code never count. Most mentions first, limit 0
+// means no limit. It reads the content of every note, so it is computed on request only.
+func FindUnlinkedMentions(notes []model.Note, target model.Note, limit int) []UnlinkedMention {
+	title := strings.TrimSpace(target.Title)
+	if title == "" {
+		return nil
+	}
+	lowerTitle := strings.ToLower(title)
+	titleRegex := regexp.MustCompile("(?i)" + regexp.QuoteMeta(title))
+
+	linked := make(map[string]bool, len(target.ReferencedBy)+1)
+	linked[target.Slug] = true
+	for _, reference := range target.ReferencedBy {
+		linked[reference.Slug] = true
+	}
+
+	var mentions []UnlinkedMention
+	for _, note := range notes {
+		if linked[note.Slug] {
+			continue
+		}
+		if mention, found := findMentions(note, lowerTitle, titleRegex); found {
+			mentions = append(mentions, mention)
+		}
+	}
+
+	slices.SortFunc(mentions, func(a, b UnlinkedMention) int {
+		if a.Count != b.Count {
+			return b.Count - a.Count
+		}
+		return strings.Compare(a.Note.Slug, b.Note.Slug)
+	})
+	if limit > 0 && len(mentions) > limit {
+		mentions = mentions[:limit]
+	}
+	return mentions
+}
+
+// findMentions counts the whole-word mentions of the title in the content of the note, outside
+// of fenced code blocks and of the parts matched by mentionSkipRegex
+func findMentions(note model.Note, lowerTitle string, titleRegex *regexp.Regexp) (UnlinkedMention, bool) {
+	// Case-insensitive regexes are slow, most notes and lines do not contain the title at all
+	if !strings.Contains(strings.ToLower(note.Content), lowerTitle) {
+		return UnlinkedMention{}, false
+	}
+
+	mention := UnlinkedMention{Note: note}
+	inFence := false
+	for line := range strings.SplitSeq(note.Content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+			continue
+		}
+		lowerLine := strings.ToLower(line)
+		if inFence || !strings.Contains(lowerLine, lowerTitle) {
+			continue
+		}
+		if strings.ContainsAny(line, "[`") {
+			line, lowerLine = mentionSkipRegex.ReplaceAllString(line, " "), mentionSkipRegex.ReplaceAllString(lowerLine, " ")
+		}
+		count := countWholeWords(lowerLine, lowerTitle)
+		if count == 0 {
+			continue
+		}
+
+		// The snippet is plain text, worth the slower regexes once per note only
+		if mention.Count == 0 {
+			text := PlainText(line)
+			for _, loc := range titleRegex.FindAllStringIndex(text, -1) {
+				if isWordBoundary(text, loc[0], loc[1]) {
+					mention.Snippet, mention.Start, mention.End = snippetAround(text, loc[0], loc[1])
+					break
+				}
+			}
+		}
+		mention.Count += count
+	}
+	return mention, mention.Count > 0
+}
+
+// countWholeWords counts the occurrences of word in text that are whole words
+func countWholeWords(text, word string) int {
+	count := 0
+	for offset := 0; ; {
+		i := strings.Index(text[offset:], word)
+		if i < 0 {
+			return count
+		}
+		start := offset + i
+		if isWordBoundary(text, start, start+len(word)) {
+			count++
+		}
+		offset = start + len(word)
+	}
+}
+
+// isWordBoundary reports whether text[start:end] is a whole word: no letter or digit right
+// before or after it
+func isWordBoundary(text string, start, end int) bool {
+	isWordRune := func(r rune) bool { return unicode.IsLetter(r) || unicode.IsNumber(r) }
+	if before, _ := utf8.DecodeLastRuneInString(text[:start]); start > 0 && isWordRune(before) {
+		return false
+	}
+	if after, _ := utf8.DecodeRuneInString(text[end:]); end < len(text) && isWordRune(after) {
+		return false
+	}
+	return true
+}
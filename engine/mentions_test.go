@@ -0,0 +1,89 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/EwenQuim/pluie/model"
+)
+
+func TestFindUnlinkedMentions(t *testing.T) {
+	target := model.Note{
+		Slug:         "garden",
+		Title:        "Garden",
+		Content:      "My garden is the Garden note itself.",
+		ReferencedBy: []model.NoteReference{{Slug: "linked", Title: "Linked"}},
+	}
+	notes := []model.Note{
+		target,
+		{Slug: "plain", Content: "Watered the garden today.\nThe GARDEN needs more sun, garden!"},
+		{Slug: "once", Content: "# Spring\n\nPlanting in the **Garden** soon."},
+		{Slug: "linked", Content: "See [[Garden]], the garden is nice."},
+		{Slug: "wikilink", Content: "Only [[Garden]], ![[Garden]] and [[garden|the garden]]."},
+		{Slug: "markdown-link", Content: "Only [my garden](garden.md)."},
+		{Slug: "code", Content: "```\ngarden := Garden{}\n```\nThe `garden` variable."},
+		{Slug: "words", Content: "Gardening and gardens, a rooftop-garden-less flat."},
+		{Slug: "other", Content: "Nothing to see here."},
+	}
+
+	mentions := FindUnlinkedMentions(notes, target, 0)
+	var got []string
+	for _, mention := range mentions {
+		got = append(got, fmt.Sprintf("%s:%d", mention.Note.Slug, mention.Count))
+	}
+	// rooftop-garden-less has "garden" between hyphens, a whole word
+	if expected := "plain:3,once:1,words:1"; strings.Join(got, ",") != expected {
+		t.Fatalf("FindUnlinkedMentions() = %v, want %s", got, expected)
+	}
+
+	if mention := mentions[1]; mention.Snippet != "Planting in the Garden soon." || mention.Snippet[mention.Start:mention.End] != "Garden" {
+		t.Errorf("snippet = %q [%d:%d], want the mention in plain text", mention.Snippet, mention.Start, mention.End)
+	}
+
+	if limited := FindUnlinkedMentions(notes, target, 1); len(limited) != 1 || limited[0].Note.Slug != "plain" {
+		t.Errorf("FindUnlinkedMentions(limit 1) = %v, want the note with most mentions", limited)
+	}
+	if none := FindUnlinkedMentions(notes, model.Note{Slug: "untitled"}, 0); len(none) != 0 {
+		t.Errorf("a note without title has no mentions, got %v", none)
+	}
+}
+
+func TestFindUnlinkedMentions_SpecialCharacters(t *testing.T) {
+	target := model.Note{Slug: "c-plus-plus", Title: "C++ (2020)"}
+	notes := []model.Note{
+		{Slug: "cpp", Content: "Learning C++ (2020) this year."},
+		{Slug: "regex", Content: "Learning C+ 2020 this year."},
+		{Slug: "accents", Content: "Un Éte with C++ (2020)é"},
+	}
+
+	mentions := FindUnlinkedMentions(notes, target, 0)
+	if len(mentions) != 1 || mentions[0].Note.Slug != "cpp" {
+		t.Errorf("FindUnlinkedMentions() = %v, want the literal title as a whole word only", mentions)
+	}
+}
+
+func BenchmarkFindUnlinkedMentions(b *testing.B) {
+	words := []string{"meeting", "acme", "corp", "project", "notes", "garden", "recipe", "weekly", "review", "ideas"}
+	notes := make([]model.Note, 0, 2500)
+	for i := range 2500 {
+		var content strings.Builder
+		for j := range 200 {
+			content.WriteString(words[(i*7+j*3)%10])
+			content.WriteString(fmt.Sprintf(" w%d ", (i+j)%1000))
+			if j%20 == 19 {
+				content.WriteString("\n")
+			}
+		}
+		notes = append(notes, model.Note{Slug: fmt.Sprintf("note-%d", i), Title: fmt.Sprintf("Note %d", i), Content: content.String()})
+	}
+
+	for _, title := range []string{"Garden", "Weekly review", "Nowhere"} {
+		b.Run(title, func(b *testing.B) {
+			target := model.Note{Slug: "target", Title: title}
+			for b.Loop() {
+				FindUnlinkedMentions(notes, target, 20)
+			}
+		})
+	}
+}
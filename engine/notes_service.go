@@ -2,42 +2,243 @@ package engine
 
 import (
 	"log/slog"
+	"math/rand/v2"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/EwenQuim/pluie/model"
 )
 
 // NotesService manages the notes data with thread-safe access
 type NotesService struct {
-	mu       sync.RWMutex           // Protects notesMap, tree, and tagIndex
-	notesMap *map[string]model.Note // Slug -> Note
-	tree     *TreeNode              // Tree structure of notes
-	tagIndex TagIndex               // Tag -> Notes mapping
+	mu           sync.RWMutex           // Protects notesMap, sharedNotes, systemNotes, privateSlugs, tree, tagIndex, loadedAt and renderCache
+	notesMap     *map[string]model.Note // Slug -> Note
+	sharedNotes  map[string]model.Note  // Slug -> Note with a ShareKey, only found by GetSharedNote
+	systemNotes  map[string]model.Note  // Name -> Note of SystemNotesFolder, see SystemNote
+	privateSlugs map[string]bool        // Slugs of the private notes, see SetPrivateSlugs
+	tree         *TreeNode              // Tree structure of notes
+	links        *LinkIndex             // Wikilink targets of the notes of tree, see LinkIndex
+	tagIndex     TagIndex               // Tag -> Notes mapping
+	loadedAt     time.Time              // When the data was last set, see LoadedAt
+	renderCache  *RenderCache           // Rendered notes, replaced with the data, see RenderNote
+
+	diagnosticsMu   sync.Mutex   // Protects the diagnostics cache
+	diagnostics     []Diagnostic // Computed on first access for diagnosticsTree
+	diagnosticsTree *TreeNode    // Tree the cached diagnostics were computed from
+
+	switcherMu   sync.Mutex     // Protects the switcher index cache
+	switcher     *SwitcherIndex // Built on first access for switcherTree
+	switcherTree *TreeNode      // Tree the cached switcher index was built from
+	switcherAll  bool           // Whether the cached switcher index has the private notes too
+
+	contentIndexMu   sync.Mutex    // Protects the content index cache
+	contentIndex     *ContentIndex // Built on first access for contentIndexTree
+	contentIndexTree *TreeNode     // Tree the cached content index was built from
+	contentIndexAll  bool          // Whether the cached content index has the private notes too
+
+	legacySlugsMu   sync.Mutex        // Protects the legacy slugs cache
+	legacySlugs     map[string]string // Built on first access for legacySlugsTree
+	legacySlugsTree *TreeNode         // Tree the cached legacy slugs were built from
+
+	aliasSlugsMu   sync.Mutex        // Protects the alias slugs cache
+	aliasSlugs     map[string]string // Built on first access for aliasSlugsTree
+	aliasSlugsTree *TreeNode         // Tree the cached alias slugs were built from
+
+	attachmentsMu   sync.Mutex // Protects the attachments cache
+	attachments     []string   // Sorted, built on first access for attachmentsTree
+	attachmentsTree *TreeNode  // Tree the cached attachments were built from
+
+	randomMu sync.Mutex // Protects random
+	random   *rand.Rand // Generator of RandomNote set by SeedRandom, the global one when nil
 }
 
-// NewNotesService creates a new NotesService with the given data
+// NewNotesService creates a new NotesService with the given data. The notes of notesMap with
+// a ShareKey are kept apart: only GetSharedNote returns them. So are the system notes, see
+// SystemNote.
 func NewNotesService(notesMap *map[string]model.Note, tree *TreeNode, tagIndex TagIndex) *NotesService {
+	notesMap, sharedNotes := splitSharedNotesMap(notesMap)
+	notesMap, systemNotes := splitSystemNotesMap(notesMap)
 	return &NotesService{
-		notesMap: notesMap,
-		tree:     tree,
-		tagIndex: tagIndex,
+		notesMap:    notesMap,
+		sharedNotes: sharedNotes,
+		systemNotes: systemNotes,
+		tree:        tree,
+		links:       tree.linkIndex(),
+		tagIndex:    tagIndex,
+		loadedAt:    time.Now(),
+		renderCache: NewRenderCache(DefaultRenderCacheSize),
 	}
 }
 
 // UpdateData safely updates the service's notesMap, tree, and tagIndex with new data
 func (ns *NotesService) UpdateData(notesMap *map[string]model.Note, tree *TreeNode, tagIndex TagIndex) {
+	notesMap, sharedNotes := splitSharedNotesMap(notesMap)
+	notesMap, systemNotes := splitSystemNotesMap(notesMap)
+	links := tree.linkIndex()
+
 	ns.mu.Lock()
 	defer ns.mu.Unlock()
 
 	ns.notesMap = notesMap
+	ns.sharedNotes = sharedNotes
+	ns.systemNotes = systemNotes
 	ns.tree = tree
+	ns.links = links
 	ns.tagIndex = tagIndex
+	ns.loadedAt = time.Now()
+	if ns.renderCache != nil {
+		ns.renderCache = NewRenderCache(ns.renderCache.size)
+	}
 
 	slog.Info("Notes data updated", "notes_count", len(*notesMap))
 }
 
+// LoadedAt returns when the notes were last loaded or reloaded
+func (ns *NotesService) LoadedAt() time.Time {
+	ns.mu.RLock()
+	defer ns.mu.RUnlock()
+
+	return ns.loadedAt
+}
+
+// Diagnostics returns the problems found in the public notes, computed once per notes update
+func (ns *NotesService) Diagnostics() []Diagnostic {
+	// Every update replaces the tree, so it identifies the notes the cache is for
+	tree := ns.GetTree()
+	if tree == nil {
+		return nil
+	}
+
+	ns.diagnosticsMu.Lock()
+	defer ns.diagnosticsMu.Unlock()
+
+	if ns.diagnosticsTree != tree {
+		ns.diagnostics = BuildDiagnostics(GetAllNotesFromTree(tree))
+		ns.diagnosticsTree = tree
+	}
+	return ns.diagnostics
+}
+
+// Switcher returns the quick switcher index of the public notes, rebuilt once per notes update
+func (ns *NotesService) Switcher(publicByDefault bool) *SwitcherIndex {
+	tree := ns.GetTree()
+
+	ns.switcherMu.Lock()
+	defer ns.switcherMu.Unlock()
+
+	if ns.switcher == nil || ns.switcherTree != tree || ns.switcherAll != publicByDefault {
+		start := time.Now()
+		ns.switcher = BuildSwitcherIndex(ns.GetVisibleNotes(publicByDefault))
+		ns.switcherTree = tree
+		ns.switcherAll = publicByDefault
+		slog.Info("Switcher index built", "in", time.Since(start).String(), "entries", ns.switcher.Len())
+	}
+	return ns.switcher
+}
+
+// SearchNotesByContent searches the words of the public note bodies, see ContentIndex.Search,
+// keeping the notes passing the operators of the query, see ParseSearchQuery.
+// The index is built once per notes update.
+func (ns *NotesService) SearchNotesByContent(searchQuery string, maxResults int, publicByDefault bool) []ContentMatch {
+	query := ParseSearchQuery(searchQuery)
+	tree := ns.GetTree()
+
+	ns.contentIndexMu.Lock()
+	if ns.contentIndex == nil || ns.contentIndexTree != tree || ns.contentIndexAll != publicByDefault {
+		start := time.Now()
+		ns.contentIndex = BuildContentIndex(ns.GetVisibleNotes(publicByDefault))
+		ns.contentIndexTree = tree
+		ns.contentIndexAll = publicByDefault
+		slog.Info("Content index built", "in", time.Since(start).String(), "words", len(ns.contentIndex.words))
+	}
+	index := ns.contentIndex
+	ns.contentIndexMu.Unlock()
+
+	if !query.HasFilters() {
+		return index.Search(query.Text(), maxResults)
+	}
+	filter := ns.SearchFilter(query)
+	matches := slices.DeleteFunc(index.Search(query.Text(), 0), func(match ContentMatch) bool {
+		return !filter(match.Note)
+	})
+	if maxResults > 0 && len(matches) > maxResults {
+		matches = matches[:maxResults]
+	}
+	return matches
+}
+
+// LegacySlug returns the current slug of the note that had slug with the v1 scheme, when the
+// v2 scheme or SLUG_TRANSLITERATE changed it, see BuildLegacySlugs. Built once per notes update.
+func (ns *NotesService) LegacySlug(slug string) (string, bool) {
+	tree := ns.GetTree()
+	if tree == nil {
+		return "", false
+	}
+
+	ns.legacySlugsMu.Lock()
+	defer ns.legacySlugsMu.Unlock()
+
+	if ns.legacySlugs == nil || ns.legacySlugsTree != tree {
+		ns.legacySlugs = BuildLegacySlugs(GetAllNotesFromTree(tree))
+		ns.legacySlugsTree = tree
+	}
+	current, ok := ns.legacySlugs[slug]
+	return current, ok
+}
+
+// AliasSlug returns the slug of the note with an alias whose slug is slug, see BuildAliasSlugs.
+// Built once per notes update.
+func (ns *NotesService) AliasSlug(slug string) (string, bool) {
+	tree := ns.GetTree()
+	if tree == nil {
+		return "", false
+	}
+
+	ns.aliasSlugsMu.Lock()
+	defer ns.aliasSlugsMu.Unlock()
+
+	if ns.aliasSlugs == nil || ns.aliasSlugsTree != tree {
+		ns.aliasSlugs = BuildAliasSlugs(GetAllNotesFromTree(tree))
+		ns.aliasSlugsTree = tree
+	}
+	current, ok := ns.aliasSlugs[slug]
+	return current, ok
+}
+
+// Attachments returns the sorted vault paths of the attachments embedded or linked by the public notes,
+// the only ones that can be served. Built once per notes update.
+func (ns *NotesService) Attachments() []string {
+	tree := ns.GetTree()
+	if tree == nil {
+		return nil
+	}
+
+	ns.attachmentsMu.Lock()
+	defer ns.attachmentsMu.Unlock()
+
+	if ns.attachmentsTree != tree {
+		var attachments []string
+		for _, note := range GetAllNotesFromTree(tree) {
+			for _, vaultPath := range note.Attachments {
+				attachments = append(attachments, vaultPath)
+			}
+		}
+		slices.Sort(attachments)
+		ns.attachments = slices.Compact(attachments)
+		ns.attachmentsTree = tree
+	}
+	return ns.attachments
+}
+
+// HasAttachment reports whether a public note embeds or links to the attachment at vaultPath
+func (ns *NotesService) HasAttachment(vaultPath string) bool {
+	_, found := slices.BinarySearch(ns.Attachments(), vaultPath)
+	return found
+}
+
 // GetNotesMap returns a thread-safe copy of the notesMap
 func (ns *NotesService) GetNotesMap() map[string]model.Note {
 	ns.mu.RLock()
@@ -65,7 +266,8 @@ func (ns *NotesService) GetTagIndex() TagIndex {
 	return ns.tagIndex
 }
 
-// GetNote safely retrieves a note by slug
+// GetNote safely retrieves a note by slug. The slug can also be decoded from a URL path:
+// v1 slugs keep punctuation URL-encoded, like "q&a%21" for "Q&A!", see findBySlug.
 func (ns *NotesService) GetNote(slug string) (model.Note, bool) {
 	ns.mu.RLock()
 	defer ns.mu.RUnlock()
@@ -74,20 +276,117 @@ func (ns *NotesService) GetNote(slug string) (model.Note, bool) {
 		return model.Note{}, false
 	}
 
-	note, ok := (*ns.notesMap)[slug]
-	return note, ok
+	return findBySlug(*ns.notesMap, slug)
+}
+
+// GetSharedNote returns the note at slug of a folder with "access: private", when one of the
+// keys is its share key. These notes are never listed, searched or returned by GetNote.
+func (ns *NotesService) GetSharedNote(slug string, keys []string) (model.Note, bool) {
+	ns.mu.RLock()
+	defer ns.mu.RUnlock()
+
+	note, ok := findBySlug(ns.sharedNotes, slug)
+	if !ok || !unlocks(note, keys) {
+		return model.Note{}, false
+	}
+	return note, true
+}
+
+// HasSharedAttachment reports whether a note unlocked by one of the keys embeds or links to
+// the attachment at vaultPath
+func (ns *NotesService) HasSharedAttachment(vaultPath string, keys []string) bool {
+	ns.mu.RLock()
+	defer ns.mu.RUnlock()
+
+	for _, note := range ns.sharedNotes {
+		for _, attachment := range note.Attachments {
+			if attachment == vaultPath && unlocks(note, keys) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RandomNote returns a uniformly random public note, one of the notes with tag when tag is not
+// empty. Folder index pages are not notes, so they are never picked. False when there is none.
+func (ns *NotesService) RandomNote(tag string, publicByDefault bool) (model.Note, bool) {
+	candidates := ns.GetVisibleNotes(publicByDefault)
+	if tag != "" {
+		tagged := make(map[string]bool)
+		for _, note := range ns.GetTagIndex().GetNotesWithTag(tag) {
+			tagged[note.Slug] = true
+		}
+		candidates = slices.DeleteFunc(candidates, func(note model.Note) bool {
+			return !tagged[note.Slug]
+		})
+	}
+	if len(candidates) == 0 {
+		return model.Note{}, false
+	}
+
+	ns.randomMu.Lock()
+	defer ns.randomMu.Unlock()
+	if ns.random == nil {
+		return candidates[rand.IntN(len(candidates))], true
+	}
+	return candidates[ns.random.IntN(len(candidates))], true
+}
+
+// UnlinkedMentions returns the public notes mentioning the title of note without linking to it,
+// see FindUnlinkedMentions
+func (ns *NotesService) UnlinkedMentions(note model.Note, limit int, publicByDefault bool) []UnlinkedMention {
+	return FindUnlinkedMentions(ns.GetVisibleNotes(publicByDefault), note, limit)
+}
+
+// SetRenderCacheSize sets the number of rendered notes kept, 0 disables the cache
+func (ns *NotesService) SetRenderCacheSize(size int) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	ns.renderCache = NewRenderCache(size)
+}
+
+// RenderNote returns the rendered content of the note, from the cache when it was rendered since
+// the last update, else with render. The content is all it depends on with the notes: the
+// sidebar and the search results are rendered on each request.
+func (ns *NotesService) RenderNote(note *model.Note, render func() RenderedNote) RenderedNote {
+	ns.mu.RLock()
+	cache, tree := ns.renderCache, ns.tree
+	ns.mu.RUnlock()
+
+	return cache.Render(tree, note.Slug, note.Content, render)
+}
+
+// SeedRandom makes RandomNote pick with a generator seeded with seed, for reproducible picks
+func (ns *NotesService) SeedRandom(seed uint64) {
+	ns.randomMu.Lock()
+	defer ns.randomMu.Unlock()
+
+	ns.random = rand.New(rand.NewPCG(seed, seed))
 }
 
 // ParseWikiLinksInMetadata processes wikilinks in metadata values
 // This is a convenience method that wraps engine.ParseWikiLinksInMetadata
 func (ns *NotesService) ParseWikiLinksInMetadata(metadata map[string]any) map[string]any {
-	return ParseWikiLinksInMetadata(metadata, ns.GetTree())
+	return parseWikiLinksInMetadata(metadata, ns.LinkIndex())
 }
 
 // ParseWikiLinks processes wiki-style links in content
 // This is a convenience method that wraps engine.ParseWikiLinks
 func (ns *NotesService) ParseWikiLinks(content string) string {
-	return ParseWikiLinks(content, ns.GetTree())
+	return parseWikiLinks(content, ns.LinkIndex())
+}
+
+// AdjacentNotes returns the previous and next notes of slug in the sidebar order
+// This is a convenience method that wraps engine.AdjacentNotes
+func (ns *NotesService) AdjacentNotes(slug string, publicByDefault bool) (prev, next *model.Note) {
+	return AdjacentNotes(ns.GetTree(), slug, publicByDefault)
+}
+
+// GetFolder returns the folder of the tree at slug, like "projects/clientx", or nil
+func (ns *NotesService) GetFolder(slug string) *TreeNode {
+	return FindFolderInTree(ns.GetTree(), slug)
 }
 
 // FilterTreeBySearch filters the tree to only show nodes matching the search query
@@ -106,6 +405,51 @@ func (ns *NotesService) GetAllNotes() []model.Note {
 	return GetAllNotesFromTree(tree)
 }
 
+// SearchFilter returns whether a note passes the operators of query, with the tags of the tag index
+func (ns *NotesService) SearchFilter(query SearchQuery) func(model.Note) bool {
+	var tagged []map[string]bool // Slugs of the notes with each tag
+	for _, tag := range query.Tags {
+		slugs := make(map[string]bool)
+		for _, note := range ns.GetTagIndex().GetNotesWithTag(tag) {
+			slugs[note.Slug] = true
+		}
+		tagged = append(tagged, slugs)
+	}
+
+	return func(note model.Note) bool {
+		for _, slugs := range tagged {
+			if !slugs[note.Slug] {
+				return false
+			}
+		}
+		return query.matches(note)
+	}
+}
+
+// searchableNotes returns the visible notes passing the operators of query
+func (ns *NotesService) searchableNotes(query SearchQuery, publicByDefault bool) []model.Note {
+	notes := ns.GetVisibleNotes(publicByDefault)
+	if !query.HasFilters() {
+		return notes
+	}
+	filter := ns.SearchFilter(query)
+	return slices.DeleteFunc(notes, func(note model.Note) bool { return !filter(note) })
+}
+
+// GetVisibleNotes returns the notes anonymous visitors may see, see IsVisible
+func (ns *NotesService) GetVisibleNotes(publicByDefault bool) []model.Note {
+	return slices.DeleteFunc(ns.GetAllNotes(), func(note model.Note) bool {
+		return !IsVisible(note, publicByDefault)
+	})
+}
+
+// IsVisible reports whether anonymous visitors may see the note: every note when publicByDefault,
+// else the notes marked public. The loaded notes are public ones already, checking it again where
+// notes are searched or listed ensures a private note can never show up.
+func IsVisible(note model.Note, publicByDefault bool) bool {
+	return publicByDefault || note.IsPublic
+}
+
 // GetHomeSlug determines the home note slug based on priority:
 // 1. The provided homeNoteSlug config value (if it exists in notes)
 // 2. First note in alphabetical order
@@ -131,11 +475,15 @@ func (ns *NotesService) GetHomeSlug(homeNoteSlug string) string {
 	return ""
 }
 
-// SearchNotesByFilename searches notes by filename (title and slug) with a maximum result limit
+// SearchNotesByFilename searches the visible notes by filename (title and slug) with a maximum result limit
 // This function shows matches in the file name first (score 2), folder names second (score 1)
 // Returns early if maxResults is reached to optimize performance (0 means no limit)
-func (ns *NotesService) SearchNotesByFilename(searchQuery string, maxResults int) []model.Note {
-	notes := ns.GetAllNotes()
+// The operators of the query restrict the notes searched, see ParseSearchQuery: a query with
+// only operators lists all the notes passing them.
+func (ns *NotesService) SearchNotesByFilename(searchQuery string, maxResults int, publicByDefault bool) []model.Note {
+	query := ParseSearchQuery(searchQuery)
+	searchQuery = query.Text()
+	notes := ns.searchableNotes(query, publicByDefault)
 
 	if searchQuery == "" {
 		// Sort by slug for consistent ordering
@@ -196,14 +544,16 @@ func (ns *NotesService) SearchNotesByFilename(searchQuery string, maxResults int
 	return result
 }
 
-// SearchNotesByHeadings searches for headings (H1-H3) matching the query
+// SearchNotesByHeadings searches for headings (H1-H3) of the visible notes matching the query
 // Returns early if maxResults is reached after sorting (0 means no limit)
-func (ns *NotesService) SearchNotesByHeadings(searchQuery string, maxResults int) []HeadingMatch {
-	if searchQuery == "" {
+// The operators of the query restrict the notes searched, see ParseSearchQuery.
+func (ns *NotesService) SearchNotesByHeadings(searchQuery string, maxResults int, publicByDefault bool) []HeadingMatch {
+	query := ParseSearchQuery(searchQuery)
+	if searchQuery = query.Text(); searchQuery == "" {
 		return nil
 	}
 
-	notes := ns.GetAllNotes()
+	notes := ns.searchableNotes(query, publicByDefault)
 	searchLower := strings.ToLower(searchQuery)
 
 	var matches []HeadingMatch
@@ -255,3 +605,31 @@ func (ns *NotesService) SearchNotesByHeadings(searchQuery string, maxResults int
 
 	return matches
 }
+
+// SearchNotesByPattern searches the content of the visible notes for an exact phrase (case-insensitive) or a regex.
+// Notes are returned by slug, each with at most maxMatches matching lines (0 means no limit).
+// Returns an error for invalid or too long patterns.
+func (ns *NotesService) SearchNotesByPattern(pattern string, isRegex bool, maxMatches int, publicByDefault bool) ([]PatternResult, error) {
+	re, err := compilePattern(pattern, isRegex)
+	if err != nil {
+		return nil, err
+	}
+
+	notes := ns.GetVisibleNotes(publicByDefault)
+	sort.Slice(notes, func(i, j int) bool {
+		return notes[i].Slug < notes[j].Slug
+	})
+
+	var results []PatternResult
+	for _, note := range notes {
+		result := matchPattern(note, re, maxMatches, time.Now().Add(patternNoteDeadline))
+		if result.TimedOut {
+			slog.Warn("Pattern search stopped by the deadline", "slug", note.Slug, "pattern", pattern)
+		}
+		if len(result.Matches) > 0 || result.TimedOut {
+			results = append(results, result)
+		}
+	}
+
+	return results, nil
+}
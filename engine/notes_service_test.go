@@ -0,0 +1,62 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/EwenQuim/pluie/model"
+)
+
+func TestNotesService_RandomNote(t *testing.T) {
+	notes := []model.Note{
+		{Slug: "garden", Title: "Garden", IsPublic: true, Metadata: map[string]any{"tags": []any{"plants"}}},
+		{Slug: "recipes/bread", Title: "Bread", IsPublic: true},
+		{Slug: "recipes/soup", Title: "Soup", IsPublic: true, Metadata: map[string]any{"tags": []any{"plants"}}},
+		{Slug: "diary", Title: "Diary", IsPublic: false, Metadata: map[string]any{"tags": []any{"plants"}}},
+	}
+	notesMap := map[string]model.Note{}
+	for _, note := range notes {
+		notesMap[note.Slug] = note
+	}
+	ns := NewNotesService(&notesMap, BuildTree(notes), BuildTagIndex(notes))
+
+	picks := func(ns *NotesService, tag string) map[string]int {
+		counts := map[string]int{}
+		for range 300 {
+			note, ok := ns.RandomNote(tag, false)
+			if !ok {
+				t.Fatalf("RandomNote(%q) found no note", tag)
+			}
+			counts[note.Slug]++
+		}
+		return counts
+	}
+
+	all := picks(ns, "")
+	if all["diary"] > 0 {
+		t.Error("RandomNote should never pick a private note")
+	}
+	if len(all) != 3 {
+		t.Errorf("picks = %v, want every public note picked at some point", all)
+	}
+
+	tagged := picks(ns, "Plants")
+	if len(tagged) != 2 || tagged["garden"] == 0 || tagged["recipes/soup"] == 0 {
+		t.Errorf("picks with tag = %v, want garden and soup only", tagged)
+	}
+
+	if _, ok := ns.RandomNote("unknown", false); ok {
+		t.Error("RandomNote should find nothing for an unknown tag")
+	}
+
+	// The same seed picks the same notes
+	other := NewNotesService(&notesMap, BuildTree(notes), BuildTagIndex(notes))
+	ns.SeedRandom(42)
+	other.SeedRandom(42)
+	for range 20 {
+		first, _ := ns.RandomNote("", false)
+		second, _ := other.RandomNote("", false)
+		if first.Slug != second.Slug {
+			t.Fatalf("seeded picks differ: %s and %s", first.Slug, second.Slug)
+		}
+	}
+}
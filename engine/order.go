@@ -0,0 +1,183 @@
+package engine
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/EwenQuim/pluie/model"
+)
+
+// NoteOrder returns the "order" frontmatter integer of a note, used to curate reading paths
+func NoteOrder(note model.Note) (int, bool) {
+	return metadataInt(note.Metadata["order"])
+}
+
+// metadataInt returns the integer of a frontmatter value, as decoded from YAML
+func metadataInt(value any) (int, bool) {
+	switch value := value.(type) {
+	case int:
+		return value, true
+	case int64:
+		return int(value), true
+	case uint64:
+		return int(value), true
+	case float64:
+		if value == math.Trunc(value) {
+			return int(value), true
+		}
+	}
+	return 0, false
+}
+
+// CompareTreeNodes is the ordering of the children of a folder, shared by the sidebar tree and
+// GetSiblings so they never disagree: folders first, then notes. Folders with an "order" in
+// their .pluie file and notes with an "order" in their frontmatter come first, sorted
+// numerically, then the others alphabetically. Ties are broken by title, then path.
+func CompareTreeNodes(a, b *TreeNode) int {
+	if a.IsFolder != b.IsFolder {
+		if a.IsFolder {
+			return -1
+		}
+		return 1
+	}
+
+	orderA, orderedA := treeNodeOrder(a)
+	orderB, orderedB := treeNodeOrder(b)
+	if orderedA != orderedB {
+		if orderedA {
+			return -1
+		}
+		return 1
+	}
+	if c := cmp.Compare(orderA, orderB); c != 0 {
+		return c
+	}
+
+	if c := strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)); c != 0 {
+		return c
+	}
+	return strings.Compare(a.Path, b.Path)
+}
+
+// treeNodeOrder returns the order of a folder or note node, if any
+func treeNodeOrder(node *TreeNode) (int, bool) {
+	if node.IsFolder && node.Order != nil {
+		return *node.Order, true
+	}
+	if node.Note == nil {
+		return 0, false
+	}
+	return NoteOrder(*node.Note)
+}
+
+// GetSiblings returns the notes before and after slug in its folder, in the tree order.
+// Subfolders are skipped. Both are nil if the note is not in the tree.
+func GetSiblings(root *TreeNode, slug string) (prev, next *model.Note) {
+	parent := findParentInTree(root, slug)
+	if parent == nil {
+		return nil, nil
+	}
+
+	var notes []*TreeNode
+	for _, child := range parent.Children {
+		if !child.IsFolder && child.Note != nil {
+			notes = append(notes, child)
+		}
+	}
+
+	i := slices.IndexFunc(notes, func(node *TreeNode) bool { return node.Note.Slug == slug })
+	if i > 0 {
+		prev = notes[i-1].Note
+	}
+	if i >= 0 && i < len(notes)-1 {
+		next = notes[i+1].Note
+	}
+	return prev, next
+}
+
+// AdjacentNotes returns the notes before and after slug in the sidebar order of the whole
+// tree: depth first, every folder expanded. Private notes are skipped, see publicByDefault.
+// Both are nil if the note is not in the tree.
+func AdjacentNotes(root *TreeNode, slug string, publicByDefault bool) (prev, next *model.Note) {
+	found := false
+	for node := range root.AllNotes {
+		if node.Note.Slug == slug {
+			found = true
+			continue
+		}
+		if !IsVisible(*node.Note, publicByDefault) {
+			continue
+		}
+		if found {
+			return prev, node.Note
+		}
+		prev = node.Note
+	}
+	if !found {
+		return nil, nil
+	}
+	return prev, nil
+}
+
+// findParentInTree returns the folder node directly containing the note at slug
+func findParentInTree(node *TreeNode, slug string) *TreeNode {
+	if node == nil {
+		return nil
+	}
+	for _, child := range node.Children {
+		if !child.IsFolder && child.Note != nil && child.Note.Slug == slug {
+			return node
+		}
+		if child.IsFolder {
+			if parent := findParentInTree(child, slug); parent != nil {
+				return parent
+			}
+		}
+	}
+	return nil
+}
+
+// OrderDiagnostics reports the notes of a folder sharing the same "order" value.
+// Their relative order falls back to the title, which is probably not what was meant.
+func OrderDiagnostics(notes []model.Note) []Diagnostic {
+	type folderOrder struct {
+		folder string
+		order  int
+	}
+	groups := make(map[folderOrder][]model.Note)
+	for _, note := range notes {
+		if order, ok := NoteOrder(note); ok {
+			key := folderOrder{folder: path.Dir(strings.TrimPrefix(note.Path, "/")), order: order}
+			groups[key] = append(groups[key], note)
+		}
+	}
+
+	var diagnostics []Diagnostic
+	for key, group := range groups {
+		if len(group) < 2 {
+			continue
+		}
+		slices.SortFunc(group, func(a, b model.Note) int { return strings.Compare(a.Path, b.Path) })
+
+		folder := key.folder
+		if folder == "." {
+			folder = "the root folder"
+		}
+		diagnostic := Diagnostic{
+			Kind:    DiagnosticOrder,
+			Message: fmt.Sprintf("Notes share order %d in %s, sorted by title", key.order, folder),
+		}
+		for _, note := range group {
+			diagnostic.Paths = append(diagnostic.Paths, note.Path)
+			diagnostic.Slugs = append(diagnostic.Slugs, note.Slug)
+		}
+		diagnostics = append(diagnostics, diagnostic)
+	}
+
+	slices.SortFunc(diagnostics, func(a, b Diagnostic) int { return strings.Compare(a.Paths[0], b.Paths[0]) })
+	return diagnostics
+}
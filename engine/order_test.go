@@ -0,0 +1,129 @@
+package engine
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/EwenQuim/pluie/model"
+)
+
+func orderTestNotes() []model.Note {
+	return []model.Note{
+		{Title: "Zebra", Slug: "guide/zebra", Path: "guide/zebra.md"},
+		{Title: "Setup", Slug: "guide/setup", Path: "guide/setup.md", Metadata: map[string]any{"order": 2}},
+		{Title: "Welcome", Slug: "guide/welcome", Path: "guide/welcome.md", Metadata: map[string]any{"order": 1}},
+		{Title: "Apple", Slug: "guide/apple", Path: "guide/apple.md"},
+		{Title: "Advanced", Slug: "guide/advanced", Path: "guide/advanced.md", Metadata: map[string]any{"order": 10}},
+		{Title: "Basics", Slug: "guide/basics", Path: "guide/basics.md", Metadata: map[string]any{"order": 2}},
+		{Title: "Nested", Slug: "guide/extra/nested", Path: "guide/extra/nested.md"},
+		{Title: "Float", Slug: "guide/float", Path: "guide/float.md", Metadata: map[string]any{"order": 1.5}}, // Not an integer
+	}
+}
+
+func TestBuildTree_Order(t *testing.T) {
+	tree := BuildTree(orderTestNotes())
+	guide := tree.Children[0]
+
+	var names []string
+	for _, child := range guide.Children {
+		names = append(names, child.Name)
+	}
+	expected := []string{"extra", "Welcome", "Basics", "Setup", "Advanced", "Apple", "Float", "Zebra"}
+	if fmt.Sprint(names) != fmt.Sprint(expected) {
+		t.Errorf("folder children = %v, want %v", names, expected)
+	}
+}
+
+func TestGetSiblings_MatchesTreeOrder(t *testing.T) {
+	notes := orderTestNotes()
+	tree := BuildTree(notes)
+
+	// Walking next links from the first note visits the folder notes in the sidebar order
+	var walked []string
+	for note := tree.Children[0].Children[1].Note; note != nil; {
+		walked = append(walked, note.Title)
+		prev, next := GetSiblings(tree, note.Slug)
+		if next != nil {
+			if back, _ := GetSiblings(tree, next.Slug); back == nil || back.Slug != note.Slug {
+				t.Errorf("previous of %q should be %q, got %v", next.Title, note.Title, back)
+			}
+		}
+		if len(walked) == 1 && prev != nil {
+			t.Errorf("the first note should have no previous note, got %q", prev.Title)
+		}
+		note = next
+	}
+
+	var treeOrder []string
+	for _, child := range tree.Children[0].Children {
+		if !child.IsFolder {
+			treeOrder = append(treeOrder, child.Name)
+		}
+	}
+	if fmt.Sprint(walked) != fmt.Sprint(treeOrder) {
+		t.Errorf("GetSiblings order = %v, tree order = %v", walked, treeOrder)
+	}
+
+	if prev, next := GetSiblings(tree, "guide/extra/nested"); prev != nil || next != nil {
+		t.Errorf("a note alone in its folder has no siblings, got %v and %v", prev, next)
+	}
+	if prev, next := GetSiblings(tree, "missing"); prev != nil || next != nil {
+		t.Errorf("a missing note has no siblings, got %v and %v", prev, next)
+	}
+}
+
+func TestAdjacentNotes(t *testing.T) {
+	notes := []model.Note{
+		{Title: "Intro", Slug: "intro", Path: "intro.md", IsPublic: true},
+		{Title: "Setup", Slug: "guide/setup", Path: "guide/setup.md", IsPublic: true},
+		{Title: "Draft", Slug: "guide/draft", Path: "guide/draft.md"},
+		{Title: "Nested", Slug: "guide/extra/nested", Path: "guide/extra/nested.md", IsPublic: true},
+		{Title: "Zebra", Slug: "zebra", Path: "zebra.md", IsPublic: true},
+	}
+	tree := BuildTree(notes)
+
+	title := func(note *model.Note) string {
+		if note == nil {
+			return ""
+		}
+		return note.Title
+	}
+
+	// Sidebar order: guide/extra/nested, guide/draft, guide/setup, intro, zebra
+	tests := []struct {
+		slug            string
+		publicByDefault bool
+		prev, next      string
+	}{
+		{slug: "guide/extra/nested", next: "Setup"},
+		{slug: "guide/setup", prev: "Nested", next: "Intro"},
+		{slug: "guide/setup", publicByDefault: true, prev: "Draft", next: "Intro"},
+		{slug: "guide/draft", prev: "Nested", next: "Setup"},
+		{slug: "zebra", prev: "Intro"},
+		{slug: "missing"},
+	}
+	for _, tt := range tests {
+		prev, next := AdjacentNotes(tree, tt.slug, tt.publicByDefault)
+		if title(prev) != tt.prev || title(next) != tt.next {
+			t.Errorf("AdjacentNotes(%q, %v) = %q, %q, want %q, %q", tt.slug, tt.publicByDefault, title(prev), title(next), tt.prev, tt.next)
+		}
+	}
+}
+
+func TestOrderDiagnostics(t *testing.T) {
+	notes := append(orderTestNotes(),
+		model.Note{Title: "Other", Slug: "other", Path: "other.md", Metadata: map[string]any{"order": 2}}, // Another folder
+	)
+
+	diagnostics := OrderDiagnostics(notes)
+	if len(diagnostics) != 1 {
+		t.Fatalf("expected one duplicate order, got %+v", diagnostics)
+	}
+	diagnostic := diagnostics[0]
+	if diagnostic.Kind != DiagnosticOrder || fmt.Sprint(diagnostic.Paths) != "[guide/basics.md guide/setup.md]" {
+		t.Errorf("unexpected diagnostic %+v", diagnostic)
+	}
+	if diagnostic.Message != "Notes share order 2 in guide, sorted by title" {
+		t.Errorf("unexpected message %q", diagnostic.Message)
+	}
+}
@@ -0,0 +1,121 @@
+package engine
+
+import (
+	"strings"
+
+	"github.com/EwenQuim/pluie/model"
+)
+
+// SystemNotesFolder is the folder of the vault holding the notes customizing the pages of
+// pluie, like _pluie/404.md. They are never served as notes.
+const SystemNotesFolder = "_pluie"
+
+// Names of the system notes, the file name in SystemNotesFolder without .md
+const (
+	SystemNoteNotFound = "404"     // Page of the notes that don't exist
+	SystemNotePrivate  = "private" // Page of the private notes, SystemNoteNotFound when missing
+	SystemNotePrompt   = "prompt"  // Prompt template of the AI answers, see ai.ParsePromptTemplate
+)
+
+// SystemNoteName returns the name of the system note at vaultPath, like "404" for
+// "_pluie/404.md", and whether it is one
+func SystemNoteName(vaultPath string) (string, bool) {
+	name, ok := strings.CutPrefix(strings.Trim(vaultPath, "/"), SystemNotesFolder+"/")
+	if !ok || strings.Contains(name, "/") {
+		return "", false
+	}
+	return strings.ToLower(strings.TrimSuffix(name, ".md")), true
+}
+
+// SplitSystemNotes separates the notes of SystemNotesFolder from the others. System notes need
+// no "public" frontmatter, they are only rendered in place of the pages they customize.
+func SplitSystemNotes(notes []model.Note) (system, others []model.Note) {
+	for _, note := range notes {
+		if _, ok := SystemNoteName(note.Path); ok {
+			system = append(system, note)
+		} else {
+			others = append(others, note)
+		}
+	}
+	return system, others
+}
+
+// PrivateNoteStubs returns the notes of notes missing from public, reduced to their slug and
+// path: enough to tell a private note from a missing one, see SetPrivateSlugs, never its content
+func PrivateNoteStubs(notes, public []model.Note) []model.Note {
+	publicPaths := make(map[string]bool, len(public))
+	for _, note := range public {
+		publicPaths[note.Path] = true
+	}
+
+	var stubs []model.Note
+	for _, note := range notes {
+		if !publicPaths[note.Path] {
+			stubs = append(stubs, model.Note{Slug: note.Slug, Path: note.Path})
+		}
+	}
+	return stubs
+}
+
+// splitSystemNotesMap moves the system notes out of notesMap, so only SystemNote finds them.
+// notesMap is returned as is when it has none.
+func splitSystemNotesMap(notesMap *map[string]model.Note) (*map[string]model.Note, map[string]model.Note) {
+	if notesMap == nil {
+		return nil, nil
+	}
+	var system map[string]model.Note
+	for _, note := range *notesMap {
+		if name, ok := SystemNoteName(note.Path); ok {
+			if system == nil {
+				system = make(map[string]model.Note)
+			}
+			system[name] = note
+		}
+	}
+	if system == nil {
+		return notesMap, nil
+	}
+
+	others := make(map[string]model.Note, len(*notesMap)-len(system))
+	for slug, note := range *notesMap {
+		if _, isSystem := SystemNoteName(note.Path); !isSystem {
+			others[slug] = note
+		}
+	}
+	return &others, system
+}
+
+// SystemNote returns the system note with the given name, like SystemNoteNotFound
+func (ns *NotesService) SystemNote(name string) (model.Note, bool) {
+	ns.mu.RLock()
+	defer ns.mu.RUnlock()
+
+	note, ok := ns.systemNotes[name]
+	return note, ok
+}
+
+// SetPrivateSlugs sets the slugs of the notes that exist but are private, see IsPrivateNote
+func (ns *NotesService) SetPrivateSlugs(slugs []string) {
+	privateSlugs := make(map[string]bool, len(slugs))
+	for _, slug := range slugs {
+		privateSlugs[slug] = true
+	}
+
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	ns.privateSlugs = privateSlugs
+}
+
+// IsPrivateNote reports whether slug is a note that exists but is not served: a private note,
+// or a shared one opened without its key
+func (ns *NotesService) IsPrivateNote(slug string) bool {
+	ns.mu.RLock()
+	defer ns.mu.RUnlock()
+
+	if _, ok := findBySlug(ns.privateSlugs, slug); ok {
+		return true
+	}
+	_, ok := findBySlug(ns.sharedNotes, slug)
+	return ok
+}
@@ -0,0 +1,64 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/EwenQuim/pluie/model"
+)
+
+func TestSystemNoteName(t *testing.T) {
+	for path, want := range map[string]string{
+		"_pluie/404.md":       "404",
+		"/_pluie/Private.md":  "private",
+		"_pluie/sub/404.md":   "",
+		"notes/_pluie/404.md": "",
+		"404.md":              "",
+	} {
+		name, ok := SystemNoteName(path)
+		if name != want || ok != (want != "") {
+			t.Errorf("SystemNoteName(%q) = %q, %v, want %q", path, name, ok, want)
+		}
+	}
+}
+
+func TestSystemNotes(t *testing.T) {
+	notes := []model.Note{
+		{Slug: "garden", Path: "garden.md", Content: "Tomatoes"},
+		{Slug: "_pluie/404", Path: "_pluie/404.md", Content: "Lost"},
+	}
+	system, others := SplitSystemNotes(notes)
+	if len(system) != 1 || system[0].Slug != "_pluie/404" || len(others) != 1 || others[0].Slug != "garden" {
+		t.Fatalf("SplitSystemNotes() = %v, %v", system, others)
+	}
+
+	notesMap := map[string]model.Note{}
+	for _, note := range notes {
+		notesMap[note.Slug] = note
+	}
+	ns := NewNotesService(&notesMap, BuildTree(others), BuildTagIndex(others))
+	if page, ok := ns.SystemNote(SystemNoteNotFound); !ok || page.Content != "Lost" {
+		t.Errorf("SystemNote(404) = %v, %v", page, ok)
+	}
+	if _, ok := ns.SystemNote(SystemNotePrivate); ok {
+		t.Error("the vault has no private page")
+	}
+	if _, ok := ns.GetNote("_pluie/404"); ok {
+		t.Error("system notes should not be served as notes")
+	}
+}
+
+func TestPrivateNoteStubs(t *testing.T) {
+	public := []model.Note{{Slug: "garden", Path: "garden.md"}}
+	notes := append(public, model.Note{Slug: "secret", Path: "secret.md", Title: "Secret", Content: "Hidden"})
+
+	stubs := PrivateNoteStubs(notes, public)
+	if len(stubs) != 1 || stubs[0].Slug != "secret" || stubs[0].Content != "" || stubs[0].Title != "" {
+		t.Fatalf("PrivateNoteStubs() = %v, want the secret note without its content", stubs)
+	}
+
+	ns := NewNotesService(&map[string]model.Note{}, BuildTree(public), BuildTagIndex(public))
+	ns.SetPrivateSlugs([]string{stubs[0].Slug})
+	if !ns.IsPrivateNote("secret") || ns.IsPrivateNote("garden") || ns.IsPrivateNote("nowhere") {
+		t.Error("IsPrivateNote should report the private slugs only")
+	}
+}
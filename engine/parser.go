@@ -4,8 +4,6 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
-
-	"github.com/EwenQuim/pluie/model"
 )
 
 // Package-level regex variables to avoid duplication
@@ -32,9 +30,18 @@ func ParseWikiLinksInMetadata(metadata map[string]any, tree *TreeNode) map[strin
 		return metadata
 	}
 
+	return parseWikiLinksInMetadata(metadata, tree.linkIndex())
+}
+
+// parseWikiLinksInMetadata is ParseWikiLinksInMetadata resolving the links with index
+func parseWikiLinksInMetadata(metadata map[string]any, index *LinkIndex) map[string]any {
+	if metadata == nil {
+		return metadata
+	}
+
 	result := make(map[string]any)
 	for key, value := range metadata {
-		result[key] = parseWikiLinksInValue(value, tree)
+		result[key] = parseWikiLinksInValue(value, index)
 	}
 	return result
 }
@@ -80,23 +87,23 @@ func parseTagLinksInValue(value any) any {
 }
 
 // parseWikiLinksInValue recursively processes wikilinks in a metadata value
-func parseWikiLinksInValue(value any, tree *TreeNode) any {
+func parseWikiLinksInValue(value any, index *LinkIndex) any {
 	switch v := value.(type) {
 	case string:
 		// Parse wikilinks in string values
-		return ParseWikiLinks(v, tree)
+		return parseWikiLinks(v, index)
 	case []interface{}:
 		// Parse wikilinks in list items
 		result := make([]interface{}, len(v))
 		for i, item := range v {
-			result[i] = parseWikiLinksInValue(item, tree)
+			result[i] = parseWikiLinksInValue(item, index)
 		}
 		return result
 	case map[string]interface{}:
 		// Recursively parse wikilinks in nested objects
 		result := make(map[string]interface{})
 		for k, val := range v {
-			result[k] = parseWikiLinksInValue(val, tree)
+			result[k] = parseWikiLinksInValue(val, index)
 		}
 		return result
 	default:
@@ -105,11 +112,13 @@ func parseWikiLinksInValue(value any, tree *TreeNode) any {
 	}
 }
 
-// ProcessMarkdownLinks removes .md extensions from markdown links before rendering
-func ProcessMarkdownLinks(content string) string {
+// ProcessMarkdownLinks removes .md extensions from markdown links before rendering, and
+// points links to attachments, like ![](../images/cat.png), to the URL they are served at
+// whatever the folder of the note. attachments are the resolved attachments of the note.
+func ProcessMarkdownLinks(content string, attachments map[string]string) string {
 	// Regular expression to match [text](link.md) patterns and remove .md extension
 	// This handles cases with query parameters and anchors after .md
-	return markdownLinkRegex.ReplaceAllStringFunc(content, func(match string) string {
+	content = markdownLinkRegex.ReplaceAllStringFunc(content, func(match string) string {
 		// Extract parts using the regex
 		parts := markdownLinkRegex.FindStringSubmatch(match)
 		if len(parts) != 4 {
@@ -123,10 +132,18 @@ func ProcessMarkdownLinks(content string) string {
 		// Return the reconstructed link without .md
 		return fmt.Sprintf("[%s](%s%s", linkText, pathPart, suffix)
 	})
+
+	return rewriteAttachmentLinks(content, attachments)
 }
 
-// ParseWikiLinks transforms [[linktitle]] and [[linktitle|displayname]] into [title](link) format
+// ParseWikiLinks transforms [[linktitle]] and [[linktitle|displayname]] into [title](link) format.
+// [[linktitle#heading]] and [[#heading]] link to a section, with the heading anchor as fragment.
 func ParseWikiLinks(content string, tree *TreeNode) string {
+	return parseWikiLinks(content, tree.linkIndex())
+}
+
+// parseWikiLinks is ParseWikiLinks resolving the links with index
+func parseWikiLinks(content string, index *LinkIndex) string {
 	// Regular expression to match [[linktitle]] and [[linktitle|displayname]] patterns
 	// Allow empty content between brackets
 	return wikiLinkRegex.ReplaceAllStringFunc(content, func(match string) string {
@@ -151,27 +168,52 @@ func ParseWikiLinks(content string, tree *TreeNode) string {
 
 		// Check if this is a custom display name format: [[Page Title|Display Name]]
 		var pageTitle, displayName string
-		if strings.Contains(innerContent, "|") {
+		hasDisplayName := strings.Contains(innerContent, "|")
+		if hasDisplayName {
 			parts := strings.SplitN(innerContent, "|", 2)
 			pageTitle = strings.TrimSpace(parts[0])
 			displayName = strings.TrimSpace(parts[1])
 		} else {
-			pageTitle = innerContent
-			displayName = innerContent
+			pageTitle = strings.TrimSpace(innerContent)
+			displayName = pageTitle
 		}
 
-		// Find the corresponding note by title in the tree using iterator
-		var foundNote *model.Note
-		tree.AllNotes(func(noteNode *TreeNode) bool {
-			if noteNode.Note != nil && noteNode.Note.Title == pageTitle {
-				foundNote = noteNode.Note
-				return false // Stop iteration
+		foundNote := index.Find(pageTitle)
+
+		// [[Note#Heading]] links to a section of a note, [[#Heading]] to a section of the current note.
+		// Titles containing a # are matched as a whole first.
+		var heading string
+		if idx := strings.IndexByte(pageTitle, '#'); foundNote == nil && idx != -1 {
+			heading = strings.TrimSpace(pageTitle[idx+1:])
+			pageTitle = strings.TrimSpace(pageTitle[:idx])
+			if !hasDisplayName {
+				displayName = sectionLinkText(pageTitle, heading)
+				if strings.HasPrefix(heading, "^") && pageTitle != "" {
+					// Block references degrade to links to the note
+					displayName = pageTitle
+				}
+			}
+
+			if pageTitle == "" {
+				if heading == "" {
+					return displayName
+				}
+				// The content is the current note, unless it is a metadata value
+				anchor, ok := HeadingAnchor(content, heading)
+				if !ok {
+					anchor = SlugifyHeading(heading)
+				}
+				return fmt.Sprintf("[%s](#%s)", displayName, anchor)
 			}
-			return true // Continue iteration
-		})
+			foundNote = index.Find(pageTitle)
+		}
 
 		if foundNote != nil {
-			// Return markdown link format [displayName](link)
+			// Return markdown link format [displayName](link).
+			// Missing headings and block references link to the top of the note.
+			if anchor, ok := HeadingAnchor(foundNote.Content, heading); ok {
+				return fmt.Sprintf("[%s](/%s#%s)", displayName, foundNote.Slug, anchor)
+			}
 			return fmt.Sprintf("[%s](/%s)", displayName, foundNote.Slug)
 		}
 
@@ -181,6 +223,17 @@ func ParseWikiLinks(content string, tree *TreeNode) string {
 	})
 }
 
+// sectionLinkText is the default text of a link to a section, like Obsidian: "Note > Heading"
+func sectionLinkText(title, heading string) string {
+	switch {
+	case heading == "":
+		return title
+	case title == "":
+		return heading
+	}
+	return title + " > " + heading
+}
+
 // ParseHashtagLinks converts hashtags in content to clickable links
 // It avoids false positives by:
 // 1. Ignoring hashtags inside code blocks (both inline ` and multi-line ```)
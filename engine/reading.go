@@ -0,0 +1,57 @@
+package engine
+
+import (
+	"strings"
+	"unicode"
+)
+
+// DefaultReadingWPM is the reading speed used when none is configured, in words per minute
+const DefaultReadingWPM = 200
+
+// ReadingStats counts the words of a note content and the minutes needed to read them at
+// wordsPerMinute, rounded and at least one. Code blocks, a leftover frontmatter block, image and
+// attachment embeds and the markup of links are not counted.
+func ReadingStats(content string, wordsPerMinute int) (words int, minutes int) {
+	if wordsPerMinute < 1 {
+		wordsPerMinute = DefaultReadingWPM
+	}
+
+	lines := strings.Split(content, "\n")
+	// Frontmatter left in the content, like when it could not be parsed
+	if len(lines) > 1 && strings.TrimSpace(lines[0]) == "---" {
+		for i := 1; i < len(lines); i++ {
+			if strings.TrimSpace(lines[i]) == "---" {
+				lines = lines[i+1:]
+				break
+			}
+		}
+	}
+
+	inFence := false
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			continue
+		}
+
+		line = attachmentEmbedRegex.ReplaceAllString(line, "")
+		line = plainImageRegex.ReplaceAllString(line, "")
+		line = plainLinkRegex.ReplaceAllString(line, "$1")
+		line = WikiLinksToText(line)
+		for field := range strings.FieldsSeq(line) {
+			// Skip markup like "-", "#", ">" or "|"
+			if strings.IndexFunc(field, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) >= 0 {
+				words++
+			}
+		}
+	}
+
+	if words == 0 {
+		return 0, 0
+	}
+	return words, max(1, (words+wordsPerMinute/2)/wordsPerMinute)
+}
@@ -0,0 +1,37 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadingStats(t *testing.T) {
+	tests := []struct {
+		name            string
+		content         string
+		wordsPerMinute  int
+		expectedWords   int
+		expectedMinutes int
+	}{
+		{name: "empty", content: "", wordsPerMinute: 200},
+		{name: "short note rounds up to a minute", content: "# Title\n\nHello there, world!", wordsPerMinute: 200, expectedWords: 4, expectedMinutes: 1},
+		{name: "markup is not counted", content: "- one\n> two\n| three | four |\n---", wordsPerMinute: 200, expectedWords: 4, expectedMinutes: 1},
+		{name: "code blocks are skipped", content: "Before\n```go\nfunc main() {}\n```\nAfter\n~~~\nmore code\n~~~", wordsPerMinute: 200, expectedWords: 2, expectedMinutes: 1},
+		{name: "wikilinks count their text", content: "See [[Some Note]] and [[Other|that one]]", wordsPerMinute: 200, expectedWords: 6, expectedMinutes: 1},
+		{name: "embeds and images are skipped", content: "A ![[photo.png|300]] B ![alt text](cat.png) [link text](https://example.com)", wordsPerMinute: 200, expectedWords: 4, expectedMinutes: 1},
+		{name: "embedded notes are not counted", content: "Intro ![[Long Note]]\n\n![[Other#Section]]\n\nOutro", wordsPerMinute: 200, expectedWords: 2, expectedMinutes: 1},
+		{name: "leftover frontmatter is skipped", content: "---\ntitle: Hello\ntags: [a, b]\n---\nBody", wordsPerMinute: 200, expectedWords: 1, expectedMinutes: 1},
+		{name: "minutes use the rate", content: strings.Repeat("word ", 450), wordsPerMinute: 200, expectedWords: 450, expectedMinutes: 2},
+		{name: "minutes are rounded", content: strings.Repeat("word ", 500), wordsPerMinute: 200, expectedWords: 500, expectedMinutes: 3},
+		{name: "invalid rate uses the default", content: strings.Repeat("word ", 400), wordsPerMinute: 0, expectedWords: 400, expectedMinutes: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			words, minutes := ReadingStats(tt.content, tt.wordsPerMinute)
+			if words != tt.expectedWords || minutes != tt.expectedMinutes {
+				t.Errorf("ReadingStats() = %d words, %d minutes, want %d, %d", words, minutes, tt.expectedWords, tt.expectedMinutes)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package engine
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"sync"
+)
+
+// DefaultRenderCacheSize is the number of rendered notes kept by a NotesService, see SetRenderCacheSize
+const DefaultRenderCacheSize = 500
+
+// TOCItem is a heading of the table of contents of a note
+type TOCItem struct {
+	ID     string
+	Text   string
+	Level  int
+	Source string // Slug of the embedded note the heading is on, "" for the headings of the note
+}
+
+// RenderedNote is the content of a note rendered to HTML, with its table of contents. Source is
+// the HTML before the embedded notes are expanded, HTML the one of the page, with them.
+type RenderedNote struct {
+	Source string
+	HTML   string
+	TOC    []TOCItem // Headings of Source: the embedded ones are on the pages of their notes
+}
+
+// renderKey identifies a rendered note: the same slug with another content, like a deleted
+// note and the new one at its URL, is another entry
+type renderKey struct {
+	slug    string
+	content [sha256.Size]byte
+}
+
+type renderEntry struct {
+	key      renderKey
+	rendered RenderedNote
+}
+
+// RenderCache keeps the last rendered notes, the least recently used one goes first when full.
+// Rendering depends on the other notes, for links and embeds: the cache is emptied when the
+// tree it was filled from is replaced.
+type RenderCache struct {
+	mu      sync.Mutex
+	size    int                         // Entries kept, 0 disables the cache
+	tree    *TreeNode                   // Tree the entries were rendered from
+	entries map[renderKey]*list.Element // Value of each element is a *renderEntry
+	order   *list.List                  // Most recently used first
+}
+
+// NewRenderCache creates a RenderCache keeping size rendered notes
+func NewRenderCache(size int) *RenderCache {
+	return &RenderCache{size: max(size, 0)}
+}
+
+// Render returns the rendered note for slug and content, calling render on a miss. Entries of a
+// tree other than tree are dropped first.
+func (c *RenderCache) Render(tree *TreeNode, slug, content string, render func() RenderedNote) RenderedNote {
+	if c == nil || c.size == 0 {
+		return render()
+	}
+	key := renderKey{slug: slug, content: sha256.Sum256([]byte(content))}
+
+	c.mu.Lock()
+	if c.tree != tree || c.entries == nil {
+		c.tree = tree
+		c.entries = make(map[renderKey]*list.Element)
+		c.order = list.New()
+	}
+	if element, ok := c.entries[key]; ok {
+		c.order.MoveToFront(element)
+		rendered := element.Value.(*renderEntry).rendered
+		c.mu.Unlock()
+		return rendered
+	}
+	c.mu.Unlock()
+
+	// Rendering is slow and reads the notes service: not under the lock
+	rendered := render()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.tree != tree {
+		// The notes were reloaded while rendering, the result is for the previous ones
+		return rendered
+	}
+	if _, ok := c.entries[key]; !ok {
+		c.entries[key] = c.order.PushFront(&renderEntry{key: key, rendered: rendered})
+		for c.order.Len() > c.size {
+			oldest := c.order.Back()
+			c.order.Remove(oldest)
+			delete(c.entries, oldest.Value.(*renderEntry).key)
+		}
+	}
+	return rendered
+}
+
+// Len returns the number of rendered notes kept
+func (c *RenderCache) Len() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.entries)
+}
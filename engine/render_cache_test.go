@@ -0,0 +1,86 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/EwenQuim/pluie/model"
+)
+
+func TestRenderCache(t *testing.T) {
+	tree := &TreeNode{}
+	cache := NewRenderCache(2)
+	renders := 0
+	render := func(html string) func() RenderedNote {
+		return func() RenderedNote {
+			renders++
+			return RenderedNote{HTML: html}
+		}
+	}
+
+	if got := cache.Render(tree, "a", "A", render("<p>A</p>")); got.HTML != "<p>A</p>" || renders != 1 {
+		t.Fatalf("first render = %q after %d renders", got.HTML, renders)
+	}
+	if got := cache.Render(tree, "a", "A", render("other")); got.HTML != "<p>A</p>" || renders != 1 {
+		t.Errorf("same note: expected the cached render, got %q after %d renders", got.HTML, renders)
+	}
+	if got := cache.Render(tree, "a", "A changed", render("<p>A changed</p>")); got.HTML != "<p>A changed</p>" || renders != 2 {
+		t.Errorf("changed content: expected a new render, got %q", got.HTML)
+	}
+
+	// "a" with content "A" is the least recently used one
+	cache.Render(tree, "b", "B", render("<p>B</p>"))
+	if cache.Len() != 2 {
+		t.Errorf("Len() = %d, want the size 2", cache.Len())
+	}
+	cache.Render(tree, "a", "A", render("<p>A</p>"))
+	if renders != 4 {
+		t.Errorf("the least recently used note should have been dropped, %d renders", renders)
+	}
+	cache.Render(tree, "a", "A changed", render("<p>A changed</p>"))
+	if renders != 5 {
+		t.Errorf("the least recently used note should have been dropped, %d renders", renders)
+	}
+
+	// Other notes, other links: nothing rendered from the previous tree is kept
+	cache.Render(&TreeNode{}, "a", "A changed", render("<p>A changed</p>"))
+	if renders != 6 || cache.Len() != 1 {
+		t.Errorf("a new tree should empty the cache, %d renders and %d entries", renders, cache.Len())
+	}
+
+	disabled := NewRenderCache(0)
+	disabled.Render(tree, "a", "A", render("<p>A</p>"))
+	disabled.Render(tree, "a", "A", render("<p>A</p>"))
+	if renders != 8 || disabled.Len() != 0 {
+		t.Errorf("size 0 should disable the cache, %d renders", renders)
+	}
+}
+
+func TestNotesServiceRenderNote(t *testing.T) {
+	note := model.Note{Slug: "garden", Content: "Tomatoes"}
+	notesMap := map[string]model.Note{note.Slug: note}
+	ns := NewNotesService(&notesMap, BuildTree([]model.Note{note}), BuildTagIndex([]model.Note{note}))
+
+	renders := 0
+	render := func() RenderedNote {
+		renders++
+		return RenderedNote{HTML: "<p>Tomatoes</p>", TOC: []TOCItem{{ID: "tomatoes", Text: "Tomatoes", Level: 1}}}
+	}
+	ns.RenderNote(&note, render)
+	if got := ns.RenderNote(&note, render); renders != 1 || len(got.TOC) != 1 {
+		t.Errorf("expected the cached render with its table of contents, %d renders", renders)
+	}
+
+	// Even with the same tree, an update renders the notes again
+	ns.UpdateData(&notesMap, ns.GetTree(), ns.GetTagIndex())
+	ns.RenderNote(&note, render)
+	if renders != 2 {
+		t.Errorf("UpdateData should empty the render cache, %d renders", renders)
+	}
+
+	ns.SetRenderCacheSize(0)
+	ns.RenderNote(&note, render)
+	ns.RenderNote(&note, render)
+	if renders != 4 {
+		t.Errorf("SetRenderCacheSize(0) should disable the cache, %d renders", renders)
+	}
+}
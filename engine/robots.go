@@ -0,0 +1,46 @@
+package engine
+
+import (
+	"strings"
+
+	"github.com/EwenQuim/pluie/model"
+)
+
+// robotsDisallowed are the paths robots.txt always disallows: search results are endless
+// and duplicate the notes
+var robotsDisallowed = []string{"/-/search"}
+
+// BuildRobots returns the content of robots.txt: everything is allowed but the search pages
+// and the comma-separated paths of disallow, like "/drafts/,/private". The sitemap is
+// referenced when siteURL is set.
+func BuildRobots(siteURL, disallow string) string {
+	var sb strings.Builder
+	sb.WriteString("User-agent: *\n")
+
+	disallowed := append([]string{}, robotsDisallowed...)
+	for path := range strings.SplitSeq(disallow, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+		disallowed = append(disallowed, path)
+	}
+	for _, path := range disallowed {
+		sb.WriteString("Disallow: " + path + "\n")
+	}
+
+	if siteURL != "" {
+		sb.WriteString("\nSitemap: " + siteURL + "/sitemap.xml\n")
+	}
+	return sb.String()
+}
+
+// IsNoIndex reports whether a note sets "noindex: true" in its frontmatter, or is a draft: its
+// page asks search engines not to index it, and it is left out of the sitemap and the feed
+func IsNoIndex(note model.Note) bool {
+	noIndex, ok := note.Metadata["noindex"].(bool)
+	return (ok && noIndex) || note.IsDraft
+}
@@ -0,0 +1,47 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/EwenQuim/pluie/model"
+)
+
+func TestBuildRobots(t *testing.T) {
+	tests := []struct {
+		name     string
+		siteURL  string
+		disallow string
+		expected string
+	}{
+		{
+			name:     "defaults",
+			expected: "User-agent: *\nDisallow: /-/search\n",
+		},
+		{
+			name:     "configured paths and sitemap",
+			siteURL:  "https://notes.example.com",
+			disallow: " /drafts/, private ,,",
+			expected: "User-agent: *\nDisallow: /-/search\nDisallow: /drafts/\nDisallow: /private\n\nSitemap: https://notes.example.com/sitemap.xml\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := BuildRobots(tt.siteURL, tt.disallow); got != tt.expected {
+				t.Errorf("BuildRobots() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestIsNoIndex(t *testing.T) {
+	for value, expected := range map[any]bool{true: true, false: false, "true": false, nil: false} {
+		note := model.Note{Metadata: map[string]any{"noindex": value}}
+		if got := IsNoIndex(note); got != expected {
+			t.Errorf("IsNoIndex() with noindex %v = %v, want %v", value, got, expected)
+		}
+	}
+	if !IsNoIndex(model.Note{IsDraft: true}) {
+		t.Error("IsNoIndex() of a draft = false, want true")
+	}
+}
@@ -1,7 +1,12 @@
 package engine
 
 import (
+	"errors"
+	"fmt"
+	"regexp"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/EwenQuim/pluie/model"
 )
@@ -92,3 +97,170 @@ func extractContext(lines []string, lineNum int, maxChars int) string {
 
 	return context
 }
+
+// Section is a part of a note's content, from one heading to the next
+type Section struct {
+	Heading string // Empty for the text before the first heading
+	Level   int    // 0 for the text before the first heading
+	Content string // Text below the heading, without the heading line
+	LineNum int    // Line number of the heading in the note
+}
+
+// SplitSections splits markdown content into sections at every heading.
+// Lines starting with # inside fenced code blocks are not headings.
+func SplitSections(content string) []Section {
+	var sections []Section
+	current := Section{}
+	var body []string
+	inFence := false
+
+	flush := func() {
+		current.Content = strings.TrimSpace(strings.Join(body, "\n"))
+		if current.Heading != "" || current.Content != "" {
+			sections = append(sections, current)
+		}
+		body = nil
+	}
+
+	for i, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			inFence = !inFence
+		}
+
+		if !inFence && strings.HasPrefix(trimmed, "#") {
+			if heading, level := extractHeading(trimmed); level > 0 && level <= 6 && heading != "" {
+				flush()
+				current = Section{Heading: heading, Level: level, LineNum: i}
+				continue
+			}
+		}
+
+		body = append(body, line)
+	}
+	flush()
+
+	return sections
+}
+
+// SectionByHeading returns the markdown of the first section matching the heading, with its subsections.
+// Headings are compared by anchor slug, so "Getting started" and "getting-started" both match.
+func SectionByHeading(content, heading string) (string, bool) {
+	wanted := SlugifyHeading(heading)
+	if wanted == "" {
+		return "", false
+	}
+
+	sections := SplitSections(content)
+	for i, section := range sections {
+		if section.Level == 0 || SlugifyHeading(section.Heading) != wanted {
+			continue
+		}
+
+		var sb strings.Builder
+		for _, sub := range sections[i:] {
+			if sub.LineNum != section.LineNum && sub.Level <= section.Level {
+				break
+			}
+			sb.WriteString(strings.Repeat("#", sub.Level) + " " + sub.Heading + "\n\n")
+			if sub.Content != "" {
+				sb.WriteString(sub.Content + "\n\n")
+			}
+		}
+		return strings.TrimSpace(sb.String()), true
+	}
+	return "", false
+}
+
+// Pattern search guards against expensive queries on public instances
+const (
+	maxPatternLength    = 256 // Longer patterns are rejected
+	patternContextLines = 2   // Lines shown before and after a match
+)
+
+// patternNoteDeadline is the matching time allowed per note
+var patternNoteDeadline = 100 * time.Millisecond
+
+// ErrPatternTooLong is returned for patterns longer than maxPatternLength
+var ErrPatternTooLong = fmt.Errorf("pattern is longer than %d characters", maxPatternLength)
+
+// PatternMatch is a line of a note matching a pattern search
+type PatternMatch struct {
+	LineNum int      // Line number in the note, starting at 0 like HeadingMatch
+	Line    string   // The matched line
+	Start   int      // Byte offset of the first match in Line
+	End     int      // Byte offset of the end of the first match in Line
+	Before  []string // Up to patternContextLines lines before the match
+	After   []string // Up to patternContextLines lines after the match
+}
+
+// PatternResult groups the matches of one note
+type PatternResult struct {
+	Note      model.Note
+	Matches   []PatternMatch
+	Truncated bool // The note has more matches than the limit
+	TimedOut  bool // Matching was stopped by the per-note deadline
+}
+
+// ParsePatternQuery detects the advanced search syntax of a query:
+// "re:" prefix for a regex, double quotes around the whole query for an exact phrase.
+// Returns ok false for regular queries, like the ones with several phrases, see ParseSearchQuery.
+func ParsePatternQuery(query string) (pattern string, isRegex bool, ok bool) {
+	query = strings.TrimSpace(query)
+	if rest, found := strings.CutPrefix(query, "re:"); found {
+		return strings.TrimSpace(rest), true, true
+	}
+	if len(query) > 2 && strings.HasPrefix(query, `"`) && strings.HasSuffix(query, `"`) && !strings.Contains(query[1:len(query)-1], `"`) {
+		return query[1 : len(query)-1], false, true
+	}
+	return "", false, false
+}
+
+// compilePattern compiles a regex as is, or an exact phrase matched case-insensitively.
+// Go regexps run in linear time, so the guards are on pattern size and matching time.
+func compilePattern(pattern string, isRegex bool) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, errors.New("empty pattern")
+	}
+	if utf8.RuneCountInString(pattern) > maxPatternLength {
+		return nil, ErrPatternTooLong
+	}
+	if !isRegex {
+		return regexp.MustCompile("(?i)" + regexp.QuoteMeta(pattern)), nil
+	}
+	return regexp.Compile(pattern)
+}
+
+// matchPattern finds the lines of a note matching re, with their context.
+// It stops at maxMatches (0 means no limit) or when the deadline is reached.
+func matchPattern(note model.Note, re *regexp.Regexp, maxMatches int, deadline time.Time) PatternResult {
+	result := PatternResult{Note: note}
+	lines := strings.Split(note.Content, "\n")
+
+	for i, line := range lines {
+		if time.Now().After(deadline) {
+			result.TimedOut = true
+			break
+		}
+
+		loc := re.FindStringIndex(line)
+		if loc == nil {
+			continue
+		}
+		if maxMatches > 0 && len(result.Matches) == maxMatches {
+			result.Truncated = true
+			break
+		}
+
+		result.Matches = append(result.Matches, PatternMatch{
+			LineNum: i,
+			Line:    line,
+			Start:   loc[0],
+			End:     loc[1],
+			Before:  lines[max(i-patternContextLines, 0):i],
+			After:   lines[i+1 : min(i+1+patternContextLines, len(lines))],
+		})
+	}
+
+	return result
+}
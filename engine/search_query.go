@@ -0,0 +1,103 @@
+package engine
+
+import (
+	"strings"
+
+	"github.com/EwenQuim/pluie/model"
+)
+
+// SearchQuery is a search query with its operators: tag:golang restricts to the notes with the
+// tag, path:projects/ to the notes whose slug starts with projects/, -word leaves out the notes
+// containing word and "exact phrase" requires the phrase. Other words are the free text.
+type SearchQuery struct {
+	Terms    []string // Free text words, in the order of the query
+	Phrases  []string // Quoted phrases the notes must contain, ignoring case
+	Tags     []string // Tags the notes must all have, lowercase and without #
+	Paths    []string // Slug prefixes, the notes must start with one of them
+	Excluded []string // Words the notes must not contain, ignoring case
+}
+
+// ParseSearchQuery parses the operators of a search query. Unknown operators, like "foo:bar",
+// and operators without value are free text, and an unclosed quote quotes the end of the query.
+func ParseSearchQuery(q string) SearchQuery {
+	var query SearchQuery
+	for q = strings.TrimSpace(q); q != ""; q = strings.TrimSpace(q) {
+		// Quoted phrase
+		if rest, ok := strings.CutPrefix(q, `"`); ok {
+			phrase, after, _ := strings.Cut(rest, `"`)
+			if phrase = strings.Join(strings.Fields(phrase), " "); phrase != "" {
+				query.Phrases = append(query.Phrases, phrase)
+			}
+			q = after
+			continue
+		}
+
+		end := strings.IndexAny(q, " \t\n\r")
+		if end < 0 {
+			end = len(q)
+		}
+		word := q[:end]
+		q = q[end:]
+
+		if tag, ok := strings.CutPrefix(word, "tag:"); ok && strings.Trim(tag, "#/") != "" {
+			query.Tags = append(query.Tags, strings.ToLower(strings.Trim(tag, "#/")))
+		} else if path, ok := strings.CutPrefix(word, "path:"); ok && strings.TrimLeft(path, "/") != "" {
+			query.Paths = append(query.Paths, strings.ToLower(strings.TrimLeft(path, "/")))
+		} else if excluded, ok := strings.CutPrefix(word, "-"); ok && excluded != "" {
+			query.Excluded = append(query.Excluded, excluded)
+		} else {
+			query.Terms = append(query.Terms, word)
+		}
+	}
+	return query
+}
+
+// Text returns the free text of the query, what titles, headings and contents are searched for
+func (query SearchQuery) Text() string {
+	return strings.Join(query.Terms, " ")
+}
+
+// SemanticText returns the text of the query meant for semantic search and AI answers: its free
+// text and phrases, without the operators
+func (query SearchQuery) SemanticText() string {
+	return strings.Join(append(append([]string{}, query.Terms...), query.Phrases...), " ")
+}
+
+// HasFilters reports whether the query has operators restricting the notes searched
+func (query SearchQuery) HasFilters() bool {
+	return len(query.Phrases) > 0 || len(query.Tags) > 0 || len(query.Paths) > 0 || len(query.Excluded) > 0
+}
+
+// matches reports whether note passes the path, phrase and exclusion filters of the query, the
+// tags are checked with a TagIndex, see NotesService.SearchFilter
+func (query SearchQuery) matches(note model.Note) bool {
+	if len(query.Paths) > 0 {
+		slug := strings.ToLower(note.Slug)
+		found := false
+		for _, path := range query.Paths {
+			if strings.HasPrefix(slug, path) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(query.Phrases) == 0 && len(query.Excluded) == 0 {
+		return true
+	}
+	text := strings.ToLower(note.Title + "\n" + note.Content)
+	for _, phrase := range query.Phrases {
+		if !strings.Contains(text, strings.ToLower(phrase)) {
+			return false
+		}
+	}
+	for _, excluded := range query.Excluded {
+		if strings.Contains(text, strings.ToLower(excluded)) {
+			return false
+		}
+	}
+	return true
+}
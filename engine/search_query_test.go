@@ -0,0 +1,90 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSearchQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		expected SearchQuery
+	}{
+		{
+			name:     "free text",
+			query:    "  golang   kubernetes ",
+			expected: SearchQuery{Terms: []string{"golang", "kubernetes"}},
+		},
+		{
+			name:     "tag",
+			query:    "tag:golang kubernetes",
+			expected: SearchQuery{Terms: []string{"kubernetes"}, Tags: []string{"golang"}},
+		},
+		{
+			name:     "tags are normalized",
+			query:    "tag:#GoLang tag:web/",
+			expected: SearchQuery{Tags: []string{"golang", "web"}},
+		},
+		{
+			name:     "path",
+			query:    "path:/Projects/ deploy",
+			expected: SearchQuery{Terms: []string{"deploy"}, Paths: []string{"projects/"}},
+		},
+		{
+			name:     "exclusion",
+			query:    "deploy -staging",
+			expected: SearchQuery{Terms: []string{"deploy"}, Excluded: []string{"staging"}},
+		},
+		{
+			name:     "phrases",
+			query:    `"rolling  update" deploy "blue green"`,
+			expected: SearchQuery{Terms: []string{"deploy"}, Phrases: []string{"rolling update", "blue green"}},
+		},
+		{
+			name:     "unclosed quote",
+			query:    `deploy "rolling update`,
+			expected: SearchQuery{Terms: []string{"deploy"}, Phrases: []string{"rolling update"}},
+		},
+		{
+			name:     "empty phrase",
+			query:    `"" deploy`,
+			expected: SearchQuery{Terms: []string{"deploy"}},
+		},
+		{
+			name:     "unknown operators and operators without value are text",
+			query:    "foo:bar tag: path:/ - -",
+			expected: SearchQuery{Terms: []string{"foo:bar", "tag:", "path:/", "-", "-"}},
+		},
+		{
+			name:     "operators inside words are text",
+			query:    "e-mail kubernetes-tag:golang",
+			expected: SearchQuery{Terms: []string{"e-mail", "kubernetes-tag:golang"}},
+		},
+		{
+			name:     "empty",
+			query:    "   ",
+			expected: SearchQuery{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseSearchQuery(tt.query); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("ParseSearchQuery(%q) = %+v, want %+v", tt.query, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSearchQuery_Text(t *testing.T) {
+	query := ParseSearchQuery(`tag:golang deploy "rolling update" -staging kubernetes`)
+	if query.Text() != "deploy kubernetes" {
+		t.Errorf("Text() = %q, want the free text", query.Text())
+	}
+	if query.SemanticText() != "deploy kubernetes rolling update" {
+		t.Errorf("SemanticText() = %q, want the free text and the phrases", query.SemanticText())
+	}
+	if !query.HasFilters() || ParseSearchQuery("deploy kubernetes").HasFilters() {
+		t.Error("HasFilters() should only be true with operators")
+	}
+}
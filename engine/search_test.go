@@ -1,10 +1,12 @@
 package engine
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/EwenQuim/pluie/model"
 )
@@ -181,10 +183,10 @@ func TestSearchNotesByFilename(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			ns := createTestNotesService(tt.notes)
-			result := ns.SearchNotesByFilename(tt.searchQuery, 0) // 0 = no limit
+			result := ns.SearchNotesByFilename(tt.searchQuery, 0, true) // 0 = no limit
 
 			if !reflect.DeepEqual(result, tt.expected) {
-				t.Errorf("SearchNotesByFilename() = %v, expected %v", result, tt.expected)
+				t.Errorf("SearchNotesByFilename(%q, 0, true) = %v, expected %v", tt.searchQuery, result, tt.expected)
 			}
 		})
 	}
@@ -241,10 +243,10 @@ func TestSearchNotesByFilename_EdgeCases(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			ns := createTestNotesService(tt.notes)
-			result := ns.SearchNotesByFilename(tt.searchQuery, 0) // 0 = no limit
+			result := ns.SearchNotesByFilename(tt.searchQuery, 0, true) // 0 = no limit
 
 			if !reflect.DeepEqual(result, tt.expected) {
-				t.Errorf("SearchNotesByFilename() = %v, expected %v", result, tt.expected)
+				t.Errorf("SearchNotesByFilename(%q, 0, true) = %v, expected %v", tt.searchQuery, result, tt.expected)
 			}
 		})
 	}
@@ -331,10 +333,10 @@ func TestSearchNotesByFilename_WithLimits(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			ns := createTestNotesService(tt.notes)
-			result := ns.SearchNotesByFilename(tt.searchQuery, tt.maxResults)
+			result := ns.SearchNotesByFilename(tt.searchQuery, tt.maxResults, true)
 
 			if len(result) != tt.expectedCount {
-				t.Errorf("SearchNotesByFilename() returned %d results, expected %d", len(result), tt.expectedCount)
+				t.Errorf("SearchNotesByFilename(%q, %d, true) returned %d results, expected %d", tt.searchQuery, tt.maxResults, len(result), tt.expectedCount)
 			}
 
 			if tt.expectedCount > 0 && len(result) > 0 {
@@ -345,7 +347,7 @@ func TestSearchNotesByFilename_WithLimits(t *testing.T) {
 
 			// Verify we never exceed the limit
 			if tt.maxResults > 0 && len(result) > tt.maxResults {
-				t.Errorf("SearchNotesByFilename() returned %d results, exceeds maxResults of %d", len(result), tt.maxResults)
+				t.Errorf("SearchNotesByFilename(%q, %d, true) returned %d results, exceeds maxResults", tt.searchQuery, tt.maxResults, len(result))
 			}
 		})
 	}
@@ -365,7 +367,7 @@ func BenchmarkSearchNotesByFilename(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		ns.SearchNotesByFilename("note-500", 0)
+		ns.SearchNotesByFilename("note-500", 0, true)
 	}
 }
 
@@ -492,10 +494,10 @@ Set up your development environment.`,
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			ns := createTestNotesService(tt.notes)
-			result := ns.SearchNotesByHeadings(tt.searchQuery, tt.limit)
+			result := ns.SearchNotesByHeadings(tt.searchQuery, tt.limit, true)
 
 			if len(result) != tt.expectedCount {
-				t.Errorf("SearchNotesByHeadings() returned %d results, expected %d", len(result), tt.expectedCount)
+				t.Errorf("SearchNotesByHeadings(%q, %d, true) returned %d results, expected %d", tt.searchQuery, tt.limit, len(result), tt.expectedCount)
 				for i, match := range result {
 					t.Logf("  [%d] %s (level %d, score %d)", i, match.Heading, match.Level, match.Score)
 				}
@@ -605,10 +607,10 @@ Building apps with React.`,
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			ns := createTestNotesService(tt.notes)
-			result := ns.SearchNotesByHeadings(tt.searchQuery, tt.limit)
+			result := ns.SearchNotesByHeadings(tt.searchQuery, tt.limit, true)
 
 			if len(result) != tt.expectedCount {
-				t.Errorf("SearchNotesByHeadings() returned %d results, expected %d", len(result), tt.expectedCount)
+				t.Errorf("SearchNotesByHeadings(%q, %d, true) returned %d results, expected %d", tt.searchQuery, tt.limit, len(result), tt.expectedCount)
 				for i, match := range result {
 					t.Logf("  [%d] %s (level %d, score %d)", i, match.Heading, match.Level, match.Score)
 				}
@@ -622,7 +624,7 @@ Building apps with React.`,
 
 			// Verify we never exceed the limit
 			if tt.limit > 0 && len(result) > tt.limit {
-				t.Errorf("SearchNotesByHeadings() returned %d results, exceeds limit of %d", len(result), tt.limit)
+				t.Errorf("SearchNotesByHeadings(%q, %d, true) returned %d results, exceeds limit", tt.searchQuery, tt.limit, len(result))
 			}
 		})
 	}
@@ -882,6 +884,322 @@ Even more content.`, i, i, i, i),
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		ns.SearchNotesByHeadings("Heading", 5)
+		ns.SearchNotesByHeadings("Heading", 5, true)
+	}
+}
+
+func TestSearchNotesByHeadings_EmbedsStayInSourceNote(t *testing.T) {
+	// The host only references the embedded note: its headings must be
+	// attributed to the embedded note, never to the host.
+	notes := []model.Note{
+		{
+			Title:   "Host",
+			Slug:    "host",
+			Content: "# Host Intro\nSome text.\n\n![[Embedded]]\n",
+		},
+		{
+			Title:   "Embedded",
+			Slug:    "embedded",
+			Content: "## Deployment Checklist\nSteps to deploy.",
+		},
+	}
+
+	ns := createTestNotesService(notes)
+	matches := ns.SearchNotesByHeadings("deployment", 0, true)
+
+	if len(matches) != 1 {
+		t.Fatalf("Expected 1 heading match, got %d", len(matches))
+	}
+	if matches[0].Note.Slug != "embedded" {
+		t.Errorf("Expected heading to point at 'embedded', got %q", matches[0].Note.Slug)
+	}
+}
+
+func TestSplitSections(t *testing.T) {
+	content := "Intro paragraph.\n\n# Title\nFirst section.\n\n## Setup\nInstall it.\n```sh\n# not a heading\n```\n#hashtag line\n### Empty\n## Usage\nRun it."
+
+	sections := SplitSections(content)
+
+	expected := []Section{
+		{Heading: "", Level: 0, Content: "Intro paragraph.", LineNum: 0},
+		{Heading: "Title", Level: 1, Content: "First section.", LineNum: 2},
+		{Heading: "Setup", Level: 2, Content: "Install it.\n```sh\n# not a heading\n```\n#hashtag line", LineNum: 5},
+		{Heading: "Empty", Level: 3, Content: "", LineNum: 11},
+		{Heading: "Usage", Level: 2, Content: "Run it.", LineNum: 12},
+	}
+
+	if !reflect.DeepEqual(sections, expected) {
+		t.Errorf("SplitSections() =\n%#v\nwant\n%#v", sections, expected)
+	}
+
+	if got := SplitSections(""); len(got) != 0 {
+		t.Errorf("SplitSections(\"\") = %#v, want no sections", got)
+	}
+}
+
+func TestSectionByHeading(t *testing.T) {
+	content := "Intro.\n\n# Title\nFirst section.\n\n## Setup\nInstall it.\n```sh\n# not a heading\n```\n### Linux\nUse apt.\n## Usage\nRun it."
+
+	tests := []struct {
+		name     string
+		heading  string
+		expected string
+		found    bool
+	}{
+		{
+			name:     "section with its subsections",
+			heading:  "Setup",
+			expected: "## Setup\n\nInstall it.\n```sh\n# not a heading\n```\n\n### Linux\n\nUse apt.",
+			found:    true,
+		},
+		{
+			name:     "matches the anchor slug",
+			heading:  "usage",
+			expected: "## Usage\n\nRun it.",
+			found:    true,
+		},
+		{
+			name:     "deepest section",
+			heading:  "Linux",
+			expected: "### Linux\n\nUse apt.",
+			found:    true,
+		},
+		{
+			name:    "heading inside a code block",
+			heading: "not a heading",
+		},
+		{
+			name:    "unknown heading",
+			heading: "Missing",
+		},
+		{
+			name:    "empty heading",
+			heading: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := SectionByHeading(content, tt.heading)
+			if found != tt.found {
+				t.Fatalf("SectionByHeading(%q) found = %v, want %v", tt.heading, found, tt.found)
+			}
+			if got != tt.expected {
+				t.Errorf("SectionByHeading(%q) = %q, want %q", tt.heading, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParsePatternQuery(t *testing.T) {
+	tests := []struct {
+		query     string
+		pattern   string
+		isRegex   bool
+		isPattern bool
+	}{
+		{query: "re:TODO\\(\\w+\\)", pattern: "TODO\\(\\w+\\)", isRegex: true, isPattern: true},
+		{query: "re: ^# ", pattern: "^#", isRegex: true, isPattern: true},
+		{query: `"TODO(ewen)"`, pattern: "TODO(ewen)", isPattern: true},
+		{query: `""`},
+		{query: `"unbalanced`},
+		{query: "plain query"},
+		{query: `"rolling update" "blue green"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			pattern, isRegex, isPattern := ParsePatternQuery(tt.query)
+			if pattern != tt.pattern || isRegex != tt.isRegex || isPattern != tt.isPattern {
+				t.Errorf("ParsePatternQuery(%q) = (%q, %v, %v), want (%q, %v, %v)",
+					tt.query, pattern, isRegex, isPattern, tt.pattern, tt.isRegex, tt.isPattern)
+			}
+		})
+	}
+}
+
+func TestSearchNotesByPattern(t *testing.T) {
+	notes := []model.Note{
+		{Slug: "alpha", Title: "Alpha", Content: "TODO(ewen) first line\nsecond\nthird\nfourth\nTODO(ewen) last line"},
+		{Slug: "beta", Title: "Beta", Content: "Nothing here\ntodo(EWEN) lowercase\nabc"},
+		{Slug: "gamma", Title: "Gamma", Content: "TODO: something else\nTODO(alice)"},
+	}
+	ns := createTestNotesService(notes)
+
+	slugs := func(results []PatternResult) string {
+		var s []string
+		for _, result := range results {
+			s = append(s, result.Note.Slug)
+		}
+		return strings.Join(s, ",")
+	}
+
+	t.Run("phrase is literal and case-insensitive", func(t *testing.T) {
+		results, err := ns.SearchNotesByPattern("TODO(ewen)", false, 0, true)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := slugs(results); got != "alpha,beta" {
+			t.Errorf("notes = %q, want alpha,beta", got)
+		}
+		if got := len(results[0].Matches); got != 2 {
+			t.Errorf("alpha matches = %d, want 2", got)
+		}
+
+		if results, _ := ns.SearchNotesByPattern("a.c", false, 0, true); len(results) != 0 {
+			t.Error("phrase search should not interpret regex metacharacters")
+		}
+	})
+
+	t.Run("regex", func(t *testing.T) {
+		results, err := ns.SearchNotesByPattern(`TODO\(\w+\)`, true, 0, true)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := slugs(results); got != "alpha,gamma" {
+			t.Errorf("notes = %q, want alpha,gamma (regex is case-sensitive)", got)
+		}
+
+		match := results[1].Matches[0]
+		if match.LineNum != 1 || match.Line[match.Start:match.End] != "TODO(alice)" {
+			t.Errorf("unexpected match %+v", match)
+		}
+	})
+
+	t.Run("invalid patterns", func(t *testing.T) {
+		if _, err := ns.SearchNotesByPattern("(unclosed", true, 0, true); err == nil {
+			t.Error("expected an error for an invalid regex")
+		}
+		if _, err := ns.SearchNotesByPattern(strings.Repeat("a", maxPatternLength+1), false, 0, true); !errors.Is(err, ErrPatternTooLong) {
+			t.Errorf("expected ErrPatternTooLong, got %v", err)
+		}
+		if _, err := ns.SearchNotesByPattern("", false, 0, true); err == nil {
+			t.Error("expected an error for an empty pattern")
+		}
+	})
+
+	t.Run("per-note match cap", func(t *testing.T) {
+		results, err := ns.SearchNotesByPattern("TODO(ewen)", false, 1, true)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(results[0].Matches) != 1 || !results[0].Truncated {
+			t.Errorf("alpha should have 1 match and be truncated, got %d matches, truncated %v", len(results[0].Matches), results[0].Truncated)
+		}
+		if results[1].Truncated {
+			t.Error("beta has a single match and should not be truncated")
+		}
+	})
+
+	t.Run("context around the first and last lines", func(t *testing.T) {
+		results, _ := ns.SearchNotesByPattern("TODO(ewen)", false, 0, true)
+		first, last := results[0].Matches[0], results[0].Matches[1]
+
+		if first.LineNum != 0 || len(first.Before) != 0 || strings.Join(first.After, "|") != "second|third" {
+			t.Errorf("unexpected context for the first line: %+v", first)
+		}
+		if last.LineNum != 4 || strings.Join(last.Before, "|") != "third|fourth" || len(last.After) != 0 {
+			t.Errorf("unexpected context for the last line: %+v", last)
+		}
+	})
+
+	t.Run("deadline guard", func(t *testing.T) {
+		previous := patternNoteDeadline
+		patternNoteDeadline = -time.Second
+		defer func() { patternNoteDeadline = previous }()
+
+		results, err := ns.SearchNotesByPattern("TODO", false, 0, true)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(results) != len(notes) {
+			t.Fatalf("expected every note to be reported, got %d", len(results))
+		}
+		for _, result := range results {
+			if !result.TimedOut || len(result.Matches) != 0 {
+				t.Errorf("%s: expected the deadline to stop matching, got %+v", result.Note.Slug, result)
+			}
+		}
+	})
+}
+
+func TestSearch_VisibleNotesOnly(t *testing.T) {
+	ns := createTestNotesService([]model.Note{
+		{Slug: "garden", Title: "Garden", IsPublic: true, Content: "# Garden beds\nTomatoes"},
+		{Slug: "hidden-garden", Title: "Hidden garden", Content: "# Garden secrets\nTomatoes"},
+	})
+
+	for _, publicByDefault := range []bool{false, true} {
+		want := 1
+		if publicByDefault {
+			want = 2
+		}
+		patterns, _ := ns.SearchNotesByPattern("tomatoes", false, 0, publicByDefault)
+		switcherSlugs := make(map[string]bool)
+		for _, result := range ns.Switcher(publicByDefault).Search("garden", 0) {
+			switcherSlugs[result.Slug] = true
+		}
+		counts := map[string]int{
+			"GetVisibleNotes":       len(ns.GetVisibleNotes(publicByDefault)),
+			"SearchNotesByFilename": len(ns.SearchNotesByFilename("garden", 0, publicByDefault)),
+			"SearchNotesByHeadings": len(ns.SearchNotesByHeadings("garden", 0, publicByDefault)),
+			"SearchNotesByContent":  len(ns.SearchNotesByContent("tomatoes", 0, publicByDefault)),
+			"SearchNotesByPattern":  len(patterns),
+			"Switcher":              len(switcherSlugs),
+		}
+		for search, count := range counts {
+			if count != want {
+				t.Errorf("%s with publicByDefault=%v found %d notes, want %d", search, publicByDefault, count, want)
+			}
+		}
+	}
+}
+
+func TestSearch_QueryOperators(t *testing.T) {
+	notes := []model.Note{
+		{Slug: "projects/cluster", Title: "Cluster", IsPublic: true, Metadata: map[string]any{"tags": []any{"golang"}}, Content: "# Kubernetes setup\nDeploy with a rolling update on staging"},
+		{Slug: "projects/operator", Title: "Kubernetes operator", IsPublic: true, Metadata: map[string]any{"tags": []any{"golang"}}, Content: "# Kubernetes reconcile\nWritten in Go"},
+		{Slug: "notes/kubernetes", Title: "Kubernetes", IsPublic: true, Content: "# Kubernetes basics\nPods and a rolling update"},
+	}
+	notesMap := make(map[string]model.Note)
+	for _, note := range notes {
+		notesMap[note.Slug] = note
+	}
+	ns := NewNotesService(&notesMap, BuildTree(notes), BuildTagIndex(notes))
+
+	slugs := func(notes []model.Note) []string {
+		result := []string{}
+		for _, note := range notes {
+			result = append(result, note.Slug)
+		}
+		return result
+	}
+	tests := []struct {
+		query    string
+		titles   []string
+		headings int
+		content  int
+	}{
+		{query: "kubernetes", titles: []string{"notes/kubernetes", "projects/operator"}, headings: 3, content: 3},
+		{query: "tag:golang kubernetes", titles: []string{"projects/operator"}, headings: 2, content: 2},
+		{query: "tag:golang", titles: []string{"projects/cluster", "projects/operator"}},
+		{query: "path:notes/ kubernetes", titles: []string{"notes/kubernetes"}, headings: 1, content: 1},
+		{query: "kubernetes -staging", titles: []string{"notes/kubernetes", "projects/operator"}, headings: 2, content: 2},
+		{query: `kubernetes "rolling update"`, titles: []string{"notes/kubernetes"}, headings: 2, content: 2},
+		{query: "tag:unknown kubernetes", titles: []string{}},
+		{query: "foo:bar", titles: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			if got := slugs(ns.SearchNotesByFilename(tt.query, 0, true)); !reflect.DeepEqual(got, tt.titles) {
+				t.Errorf("SearchNotesByFilename() = %v, want %v", got, tt.titles)
+			}
+			if got := len(ns.SearchNotesByHeadings(tt.query, 0, true)); got != tt.headings {
+				t.Errorf("SearchNotesByHeadings() found %d headings, want %d", got, tt.headings)
+			}
+			if got := len(ns.SearchNotesByContent(tt.query, 0, true)); got != tt.content {
+				t.Errorf("SearchNotesByContent() found %d notes, want %d", got, tt.content)
+			}
+		})
 	}
 }
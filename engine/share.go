@@ -0,0 +1,85 @@
+package engine
+
+import (
+	"crypto/subtle"
+	"log/slog"
+	"path"
+	"strings"
+
+	"github.com/EwenQuim/pluie/model"
+)
+
+// ShareKeyFor returns the key of SHARE_KEYS unlocking the note at vaultPath: the one of its
+// closest folder with a key, empty if none
+func ShareKeyFor(vaultPath string, keys map[string]string) string {
+	for folder := path.Dir(strings.Trim(vaultPath, "/")); folder != "." && folder != "/"; folder = path.Dir(folder) {
+		if key, ok := keys[folder]; ok {
+			return key
+		}
+	}
+	return ""
+}
+
+// SplitSharedNotes separates the notes with "access: private" from the others, and sets their
+// ShareKey from SHARE_KEYS. The ones without key, or with "publish: false" frontmatter, are
+// readable by nobody and left out of both.
+func SplitSharedNotes(notes []model.Note, keys map[string]string) (shared, others []model.Note) {
+	warned := make(map[string]bool)
+	for _, note := range notes {
+		if note.Access != model.AccessPrivate {
+			others = append(others, note)
+			continue
+		}
+		if publish, ok := note.Metadata["publish"].(bool); ok && !publish {
+			continue
+		}
+		note.ShareKey = ShareKeyFor(note.Path, keys)
+		if note.ShareKey == "" {
+			if folder := path.Dir(strings.Trim(note.Path, "/")); !warned[folder] {
+				slog.Warn("Folder with access: private has no SHARE_KEYS key, its notes are not served", "folder", folder)
+				warned[folder] = true
+			}
+			continue
+		}
+		shared = append(shared, note)
+	}
+	return shared, others
+}
+
+// splitSharedNotesMap moves the notes with a ShareKey out of notesMap, so only GetSharedNote
+// finds them. notesMap is returned as is when it has none.
+func splitSharedNotesMap(notesMap *map[string]model.Note) (*map[string]model.Note, map[string]model.Note) {
+	if notesMap == nil {
+		return nil, nil
+	}
+	var shared map[string]model.Note
+	for slug, note := range *notesMap {
+		if note.ShareKey != "" {
+			if shared == nil {
+				shared = make(map[string]model.Note)
+			}
+			shared[slug] = note
+		}
+	}
+	if shared == nil {
+		return notesMap, nil
+	}
+
+	public := make(map[string]model.Note, len(*notesMap)-len(shared))
+	for slug, note := range *notesMap {
+		if note.ShareKey == "" {
+			public[slug] = note
+		}
+	}
+	return &public, shared
+}
+
+// unlocks reports whether one of the keys is the ShareKey of the note, in constant time
+func unlocks(note model.Note, keys []string) bool {
+	for _, key := range keys {
+		if key != "" && subtle.ConstantTimeCompare([]byte(key), []byte(note.ShareKey)) == 1 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/EwenQuim/pluie/model"
+)
+
+func TestShareKeyFor(t *testing.T) {
+	keys := map[string]string{"Clients": "outer", "Clients/Acme": "inner"}
+
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{path: "Clients/Acme/report.md", expected: "inner"},
+		{path: "/Clients/Acme/2024/report.md", expected: "inner"},
+		{path: "Clients/Globex/plan.md", expected: "outer"},
+		{path: "Clients.md", expected: ""},
+		{path: "Other/note.md", expected: ""},
+	}
+
+	for _, tt := range tests {
+		if got := ShareKeyFor(tt.path, keys); got != tt.expected {
+			t.Errorf("ShareKeyFor(%q) = %q, want %q", tt.path, got, tt.expected)
+		}
+	}
+}
+
+func TestSplitSharedNotes(t *testing.T) {
+	notes := []model.Note{
+		{Slug: "welcome", Path: "welcome.md"},
+		{Slug: "clients/acme/report", Path: "Clients/Acme/report.md", Access: model.AccessPrivate},
+		{Slug: "clients/acme/draft", Path: "Clients/Acme/draft.md", Access: model.AccessPrivate, Metadata: map[string]any{"publish": false}},
+		{Slug: "clients/globex/plan", Path: "Clients/Globex/plan.md", Access: model.AccessPrivate},
+	}
+
+	shared, others := SplitSharedNotes(notes, map[string]string{"Clients/Acme": "abc123"})
+	if len(others) != 1 || others[0].Slug != "welcome" {
+		t.Errorf("others = %v, want only welcome", others)
+	}
+	if len(shared) != 1 || shared[0].Slug != "clients/acme/report" || shared[0].ShareKey != "abc123" {
+		t.Errorf("shared = %v, want the Acme report with its key", shared)
+	}
+}
+
+func TestNotesService_GetSharedNote(t *testing.T) {
+	notesMap := map[string]model.Note{
+		"welcome":             {Slug: "welcome", Title: "Welcome", IsPublic: true},
+		"clients/acme/report": {Slug: "clients/acme/report", Title: "Report", Access: model.AccessPrivate, ShareKey: "abc123", Attachments: map[string]string{"chart.png": "Clients/Acme/chart.png"}},
+	}
+	ns := NewNotesService(&notesMap, nil, nil)
+
+	if _, ok := ns.GetNote("clients/acme/report"); ok {
+		t.Error("GetNote should not return the shared note")
+	}
+	if _, ok := ns.GetNotesMap()["clients/acme/report"]; ok {
+		t.Error("GetNotesMap should not have the shared note")
+	}
+	if _, ok := ns.GetNote("welcome"); !ok {
+		t.Error("GetNote should return the other notes")
+	}
+
+	if _, ok := ns.GetSharedNote("clients/acme/report", []string{"wrong"}); ok {
+		t.Error("GetSharedNote returned the note with a wrong key")
+	}
+	if _, ok := ns.GetSharedNote("clients/acme/report", nil); ok {
+		t.Error("GetSharedNote returned the note without key")
+	}
+	if note, ok := ns.GetSharedNote("clients/acme/report", []string{"other", "abc123"}); !ok || note.Title != "Report" {
+		t.Errorf("GetSharedNote() = %v, %v, want the report", note, ok)
+	}
+	if _, ok := ns.GetSharedNote("welcome", []string{"abc123"}); ok {
+		t.Error("GetSharedNote should only return shared notes")
+	}
+
+	if !ns.HasSharedAttachment("Clients/Acme/chart.png", []string{"abc123"}) {
+		t.Error("HasSharedAttachment should accept the attachment with the key")
+	}
+	if ns.HasSharedAttachment("Clients/Acme/chart.png", []string{"wrong"}) {
+		t.Error("HasSharedAttachment accepted a wrong key")
+	}
+}
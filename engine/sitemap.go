@@ -0,0 +1,114 @@
+package engine
+
+import (
+	"encoding/xml"
+	"io"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/EwenQuim/pluie/model"
+)
+
+// SitemapEntry is a page listed in the sitemap
+type SitemapEntry struct {
+	Path    string    // Decoded URL path, like "/-/tag/go"
+	LastMod time.Time // Zero when unknown
+}
+
+// sitemapURLSet is the XML document of the sitemaps.org protocol
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// BuildSitemap lists the home page, the public notes and the tag pages of the public notes.
+// Notes with "noindex: true" are left out, see IsNoIndex.
+// tagPath returns the path of a tag page.
+// Notes get the date of their "modified" frontmatter key, or "date" as a fallback, and tag
+// pages the most recent date of their notes.
+func BuildSitemap(notes []model.Note, publicByDefault bool, loc *time.Location, tagPath func(tag string) string) []SitemapEntry {
+	entries := []SitemapEntry{{Path: "/"}}
+
+	var noteEntries []SitemapEntry
+	tagDates := make(map[string]time.Time)
+	for _, note := range notes {
+		if !IsVisible(note, publicByDefault) || IsNoIndex(note) {
+			continue
+		}
+
+		lastMod := NoteLastModified(note, loc)
+		notePath, err := url.PathUnescape(note.Slug)
+		if err != nil {
+			notePath = note.Slug
+		}
+		noteEntries = append(noteEntries, SitemapEntry{Path: "/" + notePath, LastMod: lastMod})
+
+		for _, tag := range extractAllTags(note) {
+			tag = strings.ToLower(strings.TrimSpace(tag))
+			if tag == "" {
+				continue
+			}
+			if previous, seen := tagDates[tag]; !seen || lastMod.After(previous) {
+				tagDates[tag] = lastMod
+			}
+		}
+	}
+	slices.SortFunc(noteEntries, func(a, b SitemapEntry) int { return strings.Compare(a.Path, b.Path) })
+	entries = append(entries, noteEntries...)
+
+	tags := make([]string, 0, len(tagDates))
+	for tag := range tagDates {
+		tags = append(tags, tag)
+	}
+	slices.Sort(tags)
+	for _, tag := range tags {
+		entries = append(entries, SitemapEntry{Path: tagPath(tag), LastMod: tagDates[tag]})
+	}
+
+	return entries
+}
+
+// NoteLastModified returns the date of the "modified" frontmatter key, or "date" as a fallback.
+// It is zero when the note has neither.
+func NoteLastModified(note model.Note, loc *time.Location) time.Time {
+	for _, key := range []string{"modified", "date"} {
+		if date, _, ok := ParseDate(note.Metadata[key], loc); ok {
+			return date
+		}
+	}
+	return time.Time{}
+}
+
+// WriteSitemap writes the entries as a sitemap.xml document, with absolute URLs on baseURL
+// like "https://notes.example.com"
+func WriteSitemap(w io.Writer, baseURL string, entries []SitemapEntry, loc *time.Location) error {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+
+	urlSet := sitemapURLSet{Xmlns: "http://www.sitemaps.org/schemas/sitemap/0.9", URLs: make([]sitemapURL, 0, len(entries))}
+	for _, entry := range entries {
+		sitemapEntry := sitemapURL{Loc: baseURL + (&url.URL{Path: entry.Path}).EscapedPath()}
+		if !entry.LastMod.IsZero() {
+			sitemapEntry.LastMod = FormatRFC3339(entry.LastMod, loc)
+		}
+		urlSet.URLs = append(urlSet.URLs, sitemapEntry)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(urlSet); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,103 @@
+package engine
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/EwenQuim/pluie/model"
+)
+
+func TestBuildSitemap(t *testing.T) {
+	notes := []model.Note{
+		{Slug: "garden", IsPublic: true, Metadata: map[string]any{"modified": "2024-03-10", "date": "2024-01-01", "tags": []any{"plants"}}},
+		{Slug: "recipes/bread", IsPublic: true, Metadata: map[string]any{"date": "2024-02-01"}, Content: "#cooking #plants"},
+		{Slug: "study/q&a%21", IsPublic: true},
+		{Slug: "private", IsPublic: false, Metadata: map[string]any{"tags": []any{"secret"}}},
+		{Slug: "hidden", IsPublic: true, Metadata: map[string]any{"noindex": true, "tags": []any{"unlisted"}}},
+	}
+	tagPath := func(tag string) string { return "/-/tag/" + tag }
+
+	entries := BuildSitemap(notes, false, time.UTC, tagPath)
+
+	var paths []string
+	for _, entry := range entries {
+		paths = append(paths, entry.Path)
+	}
+	expected := "/ /garden /recipes/bread /study/q&a! /-/tag/cooking /-/tag/plants"
+	if strings.Join(paths, " ") != expected {
+		t.Errorf("paths = %v, want %s", paths, expected)
+	}
+
+	dates := map[string]string{}
+	for _, entry := range entries {
+		if !entry.LastMod.IsZero() {
+			dates[entry.Path] = entry.LastMod.Format("2006-01-02")
+		}
+	}
+	for path, date := range map[string]string{
+		"/garden":        "2024-03-10", // "modified" wins over "date"
+		"/recipes/bread": "2024-02-01",
+		"/-/tag/plants":  "2024-03-10", // Most recent of its notes
+		"/-/tag/cooking": "2024-02-01",
+	} {
+		if dates[path] != date {
+			t.Errorf("lastmod of %s = %q, want %q", path, dates[path], date)
+		}
+	}
+	if _, ok := dates["/study/q&a!"]; ok {
+		t.Error("a note without dates should have no lastmod")
+	}
+
+	// With PUBLIC_BY_DEFAULT, every given note is listed, with its tags
+	if entries := BuildSitemap(notes, true, time.UTC, tagPath); len(entries) != 8 {
+		t.Errorf("expected 8 entries with PublicByDefault, got %d", len(entries))
+	}
+}
+
+func TestWriteSitemap(t *testing.T) {
+	entries := []SitemapEntry{
+		{Path: "/"},
+		{Path: "/study/q&a!", LastMod: time.Date(2024, 3, 10, 12, 30, 0, 0, time.UTC)},
+		{Path: "/日本語"},
+	}
+
+	var sb strings.Builder
+	if err := WriteSitemap(&sb, "https://notes.example.com/", entries, time.UTC); err != nil {
+		t.Fatalf("WriteSitemap() error: %v", err)
+	}
+	output := sb.String()
+
+	if !strings.HasPrefix(output, `<?xml version="1.0" encoding="UTF-8"?>`) {
+		t.Errorf("missing XML declaration:\n%s", output)
+	}
+
+	var urlSet struct {
+		XMLName xml.Name `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+		URLs    []struct {
+			Loc     string `xml:"loc"`
+			LastMod string `xml:"lastmod"`
+		} `xml:"url"`
+	}
+	if err := xml.Unmarshal([]byte(output), &urlSet); err != nil {
+		t.Fatalf("the sitemap should be a valid urlset: %v\n%s", err, output)
+	}
+	if len(urlSet.URLs) != 3 {
+		t.Fatalf("expected 3 URLs, got %d", len(urlSet.URLs))
+	}
+
+	expected := []struct{ loc, lastMod string }{
+		{loc: "https://notes.example.com/"},
+		{loc: "https://notes.example.com/study/q&a%21", lastMod: "2024-03-10T12:30:00+00:00"},
+		{loc: "https://notes.example.com/%E6%97%A5%E6%9C%AC%E8%AA%9E"},
+	}
+	for i, want := range expected {
+		if urlSet.URLs[i].Loc != want.loc || urlSet.URLs[i].LastMod != want.lastMod {
+			t.Errorf("url %d = %+v, want %+v", i, urlSet.URLs[i], want)
+		}
+	}
+	if !strings.Contains(output, "q&amp;a%21") {
+		t.Error("ampersands should be escaped in the XML")
+	}
+}
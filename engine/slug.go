@@ -3,7 +3,14 @@ package engine
 import (
 	"net/url"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/EwenQuim/pluie/model"
+	"golang.org/x/text/unicode/norm"
 )
 
 // SlugifyOptions defines options for slugification behavior
@@ -18,6 +25,9 @@ type SlugifyOptions struct {
 	TrimSlashes bool
 	// PreserveCase keeps the original case instead of converting to lowercase
 	PreserveCase bool
+	// Transliterate removes the accents of Latin letters, like "é" to "e". Letters of other
+	// scripts are kept as they are, and not URL-encoded: browsers encode them in requests
+	Transliterate bool
 }
 
 // DefaultNoteSlugOptions returns the default options for note slugification
@@ -30,6 +40,13 @@ func DefaultNoteSlugOptions() SlugifyOptions {
 	}
 }
 
+// NoteSlugOptions returns DefaultNoteSlugOptions, transliterated with SLUG_TRANSLITERATE
+func NoteSlugOptions(transliterate bool) SlugifyOptions {
+	options := DefaultNoteSlugOptions()
+	options.Transliterate = transliterate
+	return options
+}
+
 // DefaultHeadingSlugOptions returns the default options for heading slugification
 func DefaultHeadingSlugOptions() SlugifyOptions {
 	return SlugifyOptions{
@@ -58,6 +75,10 @@ func Slugify(text string, options SlugifyOptions) string {
 		slug = strings.ToLower(slug)
 	}
 
+	if options.Transliterate {
+		slug = removeLatinAccents(slug)
+	}
+
 	if options.PreserveSlashes {
 		// For paths: replace spaces with dashes but preserve forward slashes
 		slug = strings.ReplaceAll(slug, " ", "-")
@@ -71,9 +92,10 @@ func Slugify(text string, options SlugifyOptions) string {
 		}
 
 		// URL encode while preserving forward slashes
-		if options.URLEncode {
-			slug = url.PathEscape(slug)
-			slug = strings.ReplaceAll(slug, "%2F", "/")
+		if options.URLEncode && options.Transliterate {
+			slug = EscapeSlugPunctuation(slug)
+		} else if options.URLEncode {
+			slug = EscapeSlug(slug)
 		}
 	} else {
 		// For simple slugs: replace all non-alphanumeric characters with dashes
@@ -133,3 +155,214 @@ func cleanMultipleDashes(s string) string {
 
 	return result.String()
 }
+
+// EscapeSlug URL-encodes a slug, keeping its slashes. The router decodes request paths,
+// so this finds the v1 slug of a note with punctuation from the path of its URL.
+func EscapeSlug(slug string) string {
+	return strings.ReplaceAll(url.PathEscape(slug), "%2F", "/")
+}
+
+// isASCII reports whether text has only ASCII characters
+func isASCII(text string) bool {
+	for i := 0; i < len(text); i++ {
+		if text[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// EscapeSlugPunctuation URL-encodes a slug like EscapeSlug, but keeps the letters and digits of
+// every script, like "日本語" or "é", as they are
+func EscapeSlugPunctuation(slug string) string {
+	var sb strings.Builder
+	sb.Grow(len(slug))
+	for _, r := range slug {
+		if r >= utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r)) {
+			sb.WriteRune(r)
+		} else {
+			sb.WriteString(EscapeSlug(string(r)))
+		}
+	}
+	return sb.String()
+}
+
+// findBySlug returns the value of slug in m. slug can be decoded from a URL path: the v1 slugs
+// are URL-encoded, and the transliterated ones except their letters, see EscapeSlugPunctuation.
+func findBySlug[V any](m map[string]V, slug string) (V, bool) {
+	if value, ok := m[slug]; ok {
+		return value, true
+	}
+	if value, ok := m[EscapeSlug(slug)]; ok {
+		return value, true
+	}
+	value, ok := m[EscapeSlugPunctuation(slug)]
+	return value, ok
+}
+
+// removeLatinAccents removes the accents of the Latin letters of text, and transliterates the
+// ones without accent like "ß" to "ss". The other characters, like "日本語" or "ガ", are kept.
+func removeLatinAccents(text string) string {
+	if isASCII(text) {
+		return text
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(text))
+
+	latin := false // Whether the last letter written was a Latin one
+	for _, r := range norm.NFC.String(text) {
+		switch {
+		case r < utf8.RuneSelf:
+			latin = unicode.IsLetter(r)
+			sb.WriteRune(r)
+		case unicode.Is(unicode.Mn, r):
+			// Accents without a precomposed letter, only dropped after Latin letters
+			if !latin {
+				sb.WriteRune(r)
+			}
+		case unicode.Is(unicode.Latin, r):
+			latin = true
+			if transliteration, ok := slugTransliterations[unicode.ToLower(r)]; ok {
+				if unicode.IsUpper(r) {
+					transliteration = strings.ToUpper(transliteration[:1]) + transliteration[1:]
+				}
+				sb.WriteString(transliteration)
+				continue
+			}
+			// Decomposition splits "é" into "e" and its accent, and "ﬁ" into "fi"
+			for _, decomposed := range norm.NFKD.String(string(r)) {
+				if !unicode.Is(unicode.Mn, decomposed) {
+					sb.WriteRune(decomposed)
+				}
+			}
+		default:
+			latin = false
+			sb.WriteRune(r)
+		}
+	}
+
+	return sb.String()
+}
+
+// slugTransliterations are the letters that don't decompose into a base letter and an accent
+var slugTransliterations = map[rune]string{
+	'ß': "ss", 'æ': "ae", 'œ': "oe", 'ø': "o", 'đ': "d", 'ð': "d", 'þ': "th", 'ł': "l", 'ı': "i",
+}
+
+// SlugifyNoteV2 creates the slug of a note from its path with the v2 scheme: every folder and the
+// file name become lowercase words separated by dashes. Accents are removed, and punctuation,
+// symbols and emoji are stripped, so slugs never need URL encoding: "Notes/Q&A: Café!.md"
+// becomes "notes/q-a-cafe". Letters of other scripts are kept as they are.
+func SlugifyNoteV2(notePath string) string {
+	notePath = strings.TrimSuffix(strings.Trim(notePath, "/"), ".md")
+
+	var segments []string
+	for segment := range strings.SplitSeq(notePath, "/") {
+		if segment == "" {
+			continue
+		}
+		slug := slugifySegmentV2(segment)
+		if slug == "" {
+			// Only punctuation or emoji, like "🎉.md"
+			slug = "untitled"
+		}
+		segments = append(segments, slug)
+	}
+	return strings.Join(segments, "/")
+}
+
+// slugifySegmentV2 slugifies a folder or file name of the v2 scheme
+func slugifySegmentV2(segment string) string {
+	var sb strings.Builder
+	sb.Grow(len(segment))
+
+	// Decomposition splits "é" into "e" and its accent, and "ﬁ" into "fi"
+	for _, r := range norm.NFKD.String(strings.ToLower(segment)) {
+		switch {
+		case unicode.Is(unicode.Mn, r), r == '\'', r == '’':
+			// Accents are dropped, and apostrophes so that "don't" is one word
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if transliteration, ok := slugTransliterations[r]; ok {
+				sb.WriteString(transliteration)
+			} else {
+				sb.WriteRune(r)
+			}
+		default:
+			sb.WriteByte('-')
+		}
+	}
+
+	return strings.Trim(cleanMultipleDashes(sb.String()), "-")
+}
+
+// ApplySlugSchemeV2 replaces the slugs of the notes with their v2 slug. Notes whose v2 slugs
+// collide get a numbered suffix, in path order so that URLs don't change between reloads.
+func ApplySlugSchemeV2(notes []model.Note) {
+	applySlugs(notes, SlugifyNoteV2)
+}
+
+// ApplySlugTransliteration replaces the v1 slugs of the notes with their transliterated slug,
+// see SlugifyOptions.Transliterate: "Économie.md" is at "economie" instead of "%C3%A9conomie".
+// Notes whose slugs collide get a numbered suffix, like with ApplySlugSchemeV2.
+func ApplySlugTransliteration(notes []model.Note) {
+	options := NoteSlugOptions(true)
+	applySlugs(notes, func(notePath string) string {
+		return Slugify(notePath, options)
+	})
+}
+
+// applySlugs replaces the slugs of the notes with the slug of their path. Notes whose slugs
+// collide get a numbered suffix, in path order so that URLs don't change between reloads.
+// Notes with a permalink keep it.
+func applySlugs(notes []model.Note, slugify func(notePath string) string) {
+	order := make([]int, 0, len(notes))
+	taken := make(map[string]bool, len(notes))
+	for i, note := range notes {
+		if note.Permalink != "" {
+			taken[note.Slug] = true
+		} else {
+			order = append(order, i)
+		}
+	}
+	slices.SortFunc(order, func(a, b int) int {
+		return strings.Compare(notes[a].Path, notes[b].Path)
+	})
+
+	for _, i := range order {
+		base := slugify(notes[i].Path)
+		slug := base
+		for n := 2; taken[slug]; n++ {
+			slug = base + "-" + strconv.Itoa(n)
+		}
+		taken[slug] = true
+		notes[i].Slug = slug
+	}
+}
+
+// BuildLegacySlugs maps the v1 slugs of the notes, transliterated or not, to their current slug,
+// for notes whose slug changed with the v2 scheme or SLUG_TRANSLITERATE: turning either on or
+// off keeps the old URLs working. The notes with a permalink keep their v2 slug too. The
+// decoded form of the slugs is mapped too: it is what the router sees when a browser requests
+// a v1 URL.
+func BuildLegacySlugs(notes []model.Note) map[string]string {
+	transliterated := NoteSlugOptions(true)
+
+	legacySlugs := make(map[string]string)
+	for _, note := range notes {
+		candidates := []string{SlugifyNote(note.Path), Slugify(note.Path, transliterated)}
+		if note.Permalink != "" {
+			candidates = append(candidates, SlugifyNoteV2(note.Path))
+		}
+		for _, legacySlug := range candidates {
+			if legacySlug == note.Slug || legacySlug == "" {
+				continue
+			}
+			legacySlugs[legacySlug] = note.Slug
+			if decoded, err := url.PathUnescape(legacySlug); err == nil {
+				legacySlugs[decoded] = note.Slug
+			}
+		}
+	}
+	return legacySlugs
+}
@@ -1,7 +1,12 @@
 package engine
 
 import (
+	"net/url"
+	"strings"
 	"testing"
+	"unicode"
+
+	"github.com/EwenQuim/pluie/model"
 )
 
 func TestSlugify(t *testing.T) {
@@ -485,3 +490,218 @@ func TestDefaultOptions(t *testing.T) {
 		}
 	})
 }
+
+func TestSlugifyNoteV2(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{input: "Hello World.md", expected: "hello-world"},
+		{input: "Q&A!.md", expected: "q-a"},
+		{input: "100% done.md", expected: "100-done"},
+		{input: "C# tips.md", expected: "c-tips"},
+		{input: "Why? Because.md", expected: "why-because"},
+		{input: "Don't panic.md", expected: "dont-panic"},
+		{input: "L’été à Paris.md", expected: "lete-a-paris"},
+		{input: "Straße, Æsir & Øl.md", expected: "strasse-aesir-ol"},
+		{input: "«Quotes» — and… dashes.md", expected: "quotes-and-dashes"},
+		{input: "Party 🎉 time.md", expected: "party-time"},
+		{input: "🎉.md", expected: "untitled"},
+		{input: "日本語 ノート.md", expected: "日本語-ノート"},
+		{input: "My Folder!/Sub (old)/Note *1*.md", expected: "my-folder/sub-old/note-1"},
+		{input: "/already-clean/", expected: "already-clean"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if result := SlugifyNoteV2(tt.input); result != tt.expected {
+				t.Errorf("SlugifyNoteV2(%q) = %q, want %q", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestApplySlugSchemeV2_Collisions(t *testing.T) {
+	notes := []model.Note{
+		{Path: "Q and A.md"},
+		{Path: "Q&A.md"},
+		{Path: "q-a.md"},
+		{Path: "Garden.md"},
+	}
+	ApplySlugSchemeV2(notes)
+
+	// Path order decides which note keeps the plain slug: "Q&A.md" < "Q and A.md" < "q-a.md"
+	expected := []string{"q-and-a", "q-a", "q-a-2", "garden"}
+	for i, note := range notes {
+		if note.Slug != expected[i] {
+			t.Errorf("slug of %q = %q, want %q", note.Path, note.Slug, expected[i])
+		}
+	}
+}
+
+func TestApplySlugSchemeV2_Permalink(t *testing.T) {
+	notes := []model.Note{
+		{Path: "Old Post.md", Slug: "blog/post", Permalink: "blog/post"},
+		{Path: "Blog/Post.md"},
+	}
+	ApplySlugSchemeV2(notes)
+
+	// The permalink stays, and the note whose path gives the same slug is numbered
+	if notes[0].Slug != "blog/post" || notes[1].Slug != "blog/post-2" {
+		t.Errorf("slugs = %q, %q, want blog/post, blog/post-2", notes[0].Slug, notes[1].Slug)
+	}
+}
+
+// TestSlugRoundTrip follows a title from its slug to a link, the path a browser requests,
+// the decoded path the router hands to the handler, and the note lookup
+func TestSlugRoundTrip(t *testing.T) {
+	titles := []string{"Q&A!", "100% done", "C# tips", "Why? Because", "L’été", "«Quotes» — dashes…", "Party 🎉", "日本語", "Folder (old)/Note *1*", "Économie?/日本語ノート #1"}
+
+	for _, scheme := range []string{"v1", "v2", "transliterate"} {
+		for _, title := range titles {
+			t.Run(scheme+"/"+title, func(t *testing.T) {
+				notes := []model.Note{{Title: title, Path: title + ".md", Slug: title + ".md"}}
+				notes[0].BuildSlug()
+				switch scheme {
+				case "v2":
+					ApplySlugSchemeV2(notes)
+				case "transliterate":
+					ApplySlugTransliteration(notes)
+				}
+				notesMap := map[string]model.Note{notes[0].Slug: notes[0]}
+				ns := NewNotesService(&notesMap, BuildTree(notes), nil)
+
+				// The link, as written in href attributes, is parsed like a browser does
+				link, err := url.Parse("http://pluie.test/" + notes[0].Slug)
+				if err != nil {
+					t.Fatalf("the link of slug %q is not a valid URL: %v", notes[0].Slug, err)
+				}
+				// Go's router matches the escaped path, and hands the decoded path to the handler
+				routed, err := url.PathUnescape(link.EscapedPath())
+				if err != nil {
+					t.Fatalf("the path of slug %q cannot be decoded: %v", notes[0].Slug, err)
+				}
+
+				note, ok := ns.GetNote(strings.TrimPrefix(routed, "/"))
+				if !ok || note.Title != title {
+					t.Errorf("slug %q requested as %q was not found", notes[0].Slug, link.EscapedPath())
+				}
+
+				isPunctuation := func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '/' }
+				if scheme == "v2" && strings.ContainsFunc(notes[0].Slug, isPunctuation) {
+					t.Errorf("v2 slug %q should only have letters, digits, dashes and slashes", notes[0].Slug)
+				}
+			})
+		}
+	}
+}
+
+func TestApplySlugTransliteration(t *testing.T) {
+	notes := []model.Note{
+		{Path: "Économie française.md"},
+		{Path: "Notes/日本語ノート.md"},
+		{Path: "Straße/Q&A!.md"},
+		{Path: "Cafe.md"},
+		{Path: "Café.md"},
+		{Path: "ガイド.md"},
+	}
+	ApplySlugTransliteration(notes)
+
+	// Latin accents are removed, other scripts kept as they are, punctuation still URL-encoded
+	expected := []string{"economie-francaise", "notes/日本語ノート", "strasse/q&a%21", "cafe", "cafe-2", "ガイド"}
+	for i, note := range notes {
+		if note.Slug != expected[i] {
+			t.Errorf("slug of %q = %q, want %q", note.Path, note.Slug, expected[i])
+		}
+	}
+}
+
+func TestRemoveLatinAccents(t *testing.T) {
+	tests := map[string]string{
+		"Économie":            "Economie",
+		"e\u0301te\u0301":     "ete", // Decomposed accents
+		"Æsir, Œuvre, straße": "Aesir, Oeuvre, strasse",
+		"ﬁn":                  "fin",
+		"日本語ノート":              "日本語ノート",
+		"ガイド":                 "ガイド", // Japanese voiced marks are not accents
+		"plain":               "plain",
+	}
+	for input, expected := range tests {
+		if got := removeLatinAccents(input); got != expected {
+			t.Errorf("removeLatinAccents(%q) = %q, want %q", input, got, expected)
+		}
+	}
+}
+
+func TestLegacySlug_Transliterate(t *testing.T) {
+	notes := []model.Note{{Title: "Économie", Path: "Économie.md"}}
+	ApplySlugTransliteration(notes)
+	notesMap := map[string]model.Note{notes[0].Slug: notes[0]}
+	ns := NewNotesService(&notesMap, BuildTree(notes), nil)
+
+	// The URLs the note had before SLUG_TRANSLITERATE are redirected
+	for _, slug := range []string{"%C3%A9conomie", "économie"} {
+		if current, ok := ns.LegacySlug(slug); !ok || current != "economie" {
+			t.Errorf("LegacySlug(%q) = %q, %v, want economie", slug, current, ok)
+		}
+	}
+
+	// And the transliterated ones once it is turned off again
+	notes[0].Slug = notes[0].Path
+	notes[0].BuildSlug()
+	notesMap = map[string]model.Note{notes[0].Slug: notes[0]}
+	ns.UpdateData(&notesMap, BuildTree(notes), nil)
+	if current, ok := ns.LegacySlug("economie"); !ok || current != "%C3%A9conomie" {
+		t.Errorf("LegacySlug(%q) = %q, %v, want %%C3%%A9conomie", "economie", current, ok)
+	}
+}
+
+func TestLegacySlug(t *testing.T) {
+	notes := []model.Note{
+		{Title: "Q&A!", Path: "Study/Q&A!.md"},
+		{Title: "Garden", Path: "Garden.md"},
+	}
+	ApplySlugSchemeV2(notes)
+	notesMap := map[string]model.Note{}
+	for _, note := range notes {
+		notesMap[note.Slug] = note
+	}
+	ns := NewNotesService(&notesMap, BuildTree(notes), nil)
+
+	tests := []struct {
+		slug     string
+		expected string
+		found    bool
+	}{
+		{slug: "study/q&a%21", expected: "study/q-a", found: true}, // As written in v1 links
+		{slug: "study/q&a!", expected: "study/q-a", found: true},   // As decoded by the router
+		{slug: "garden", found: false},                             // Same slug with both schemes
+		{slug: "study/q-a", found: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.slug, func(t *testing.T) {
+			current, ok := ns.LegacySlug(tt.slug)
+			if ok != tt.found || current != tt.expected {
+				t.Errorf("LegacySlug(%q) = %q, %v, want %q, %v", tt.slug, current, ok, tt.expected, tt.found)
+			}
+		})
+	}
+}
+
+func TestLegacySlug_Permalink(t *testing.T) {
+	note := model.Note{Title: "Q&A", Slug: "Notes/Q&A.md", Path: "Notes/Q&A.md", Metadata: map[string]any{"permalink": "/blog/my-old-url"}}
+	note.BuildSlug()
+	notesMap := map[string]model.Note{note.Slug: note}
+	ns := NewNotesService(&notesMap, BuildTree([]model.Note{note}), nil)
+
+	if got, ok := ns.GetNote("blog/my-old-url"); !ok || got.Path != note.Path {
+		t.Fatalf("GetNote(permalink) = %v, %v, want the note", got, ok)
+	}
+	// The URLs of its path, with either slug scheme, redirect to the permalink
+	for _, slug := range []string{"notes/q&a", "notes/q-a"} {
+		if current, ok := ns.LegacySlug(slug); !ok || current != "blog/my-old-url" {
+			t.Errorf("LegacySlug(%q) = %q, %v, want blog/my-old-url", slug, current, ok)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package engine
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/EwenQuim/pluie/model"
+)
+
+// StatusColors are the badge colors a status can use
+var StatusColors = []string{"gray", "red", "orange", "amber", "yellow", "lime", "green", "emerald", "teal", "sky", "blue", "indigo", "purple", "pink"}
+
+// Status is a recognized value of the "status" frontmatter key
+type Status struct {
+	Name    string // Lowercase, like "draft"
+	Color   string // One of StatusColors
+	Private bool   // Notes with this status are private unless they set "publish: true" themselves
+}
+
+// StatusSet is the ordered list of recognized statuses
+type StatusSet []Status
+
+// ParseStatuses parses a status list like "draft:gray,review:yellow,published:green".
+// private lists the statuses implying privacy, like "draft".
+// Invalid colors fall back to gray, duplicated statuses are ignored.
+func ParseStatuses(spec, private string) StatusSet {
+	privateNames := make(map[string]bool)
+	for name := range strings.SplitSeq(private, ",") {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			privateNames[name] = true
+		}
+	}
+
+	var statuses StatusSet
+	for entry := range strings.SplitSeq(spec, ",") {
+		name, color, _ := strings.Cut(entry, ":")
+		name = strings.ToLower(strings.TrimSpace(name))
+		color = strings.ToLower(strings.TrimSpace(color))
+		if name == "" || statuses.Has(name) {
+			continue
+		}
+		if !slices.Contains(StatusColors, color) {
+			if color != "" {
+				slog.Warn("Invalid status color, defaulting to 'gray'", "status", name, "provided", color)
+			}
+			color = "gray"
+		}
+		statuses = append(statuses, Status{Name: name, Color: color, Private: privateNames[name]})
+	}
+	return statuses
+}
+
+// Has reports whether name is a recognized status
+func (s StatusSet) Has(name string) bool {
+	_, ok := s.Get(name)
+	return ok
+}
+
+// Get returns the recognized status with the given name
+func (s StatusSet) Get(name string) (Status, bool) {
+	for _, status := range s {
+		if status.Name == name {
+			return status, true
+		}
+	}
+	return Status{}, false
+}
+
+// ResolveStatuses sets the Status of every note whose "status" frontmatter is recognized.
+// Unknown values leave it empty and are reported by StatusDiagnostics.
+func ResolveStatuses(notes []model.Note, statuses StatusSet) {
+	for i := range notes {
+		name, ok := notes[i].Metadata["status"].(string)
+		name = strings.ToLower(strings.TrimSpace(name))
+		if ok && statuses.Has(name) {
+			notes[i].Status = name
+		}
+	}
+}
+
+// HideStatusPrivateNotes removes notes whose status implies privacy.
+// A note setting "publish: true" in its own frontmatter stays: explicit publishing wins over the status.
+func HideStatusPrivateNotes(notes []model.Note, statuses StatusSet) []model.Note {
+	visible := make([]model.Note, 0, len(notes))
+	for _, note := range notes {
+		status, _ := statuses.Get(note.Status)
+		if status.Private {
+			if publish, ok := note.Metadata["publish"].(bool); !ok || !publish {
+				continue
+			}
+		}
+		visible = append(visible, note)
+	}
+	return visible
+}
+
+// StatusDiagnostics reports notes with a "status" frontmatter value that is not recognized
+func StatusDiagnostics(notes []model.Note) []Diagnostic {
+	var diagnostics []Diagnostic
+	for _, note := range notes {
+		value, exists := note.Metadata["status"]
+		if !exists || value == nil || note.Status != "" {
+			continue
+		}
+		diagnostics = append(diagnostics, Diagnostic{
+			Kind:    DiagnosticStatus,
+			Message: fmt.Sprintf("Unknown status %q", fmt.Sprint(value)),
+			Paths:   []string{note.Path},
+			Slugs:   []string{note.Slug},
+		})
+	}
+	slices.SortFunc(diagnostics, func(a, b Diagnostic) int { return strings.Compare(a.Paths[0], b.Paths[0]) })
+	return diagnostics
+}
+
+// StatusColumn is a status and its notes, for the status overview
+type StatusColumn struct {
+	Status      Status
+	Notes       []model.Note
+	OldestFirst bool
+}
+
+// StatusColumns groups notes by status, in the order of the status set.
+// Each column is sorted by modified date, most recent first unless listed in oldestFirst.
+// Notes without a modified date come last.
+func StatusColumns(notes []model.Note, statuses StatusSet, oldestFirst map[string]bool, loc *time.Location) []StatusColumn {
+	byStatus := make(map[string][]model.Note)
+	for _, note := range notes {
+		if note.Status != "" {
+			byStatus[note.Status] = append(byStatus[note.Status], note)
+		}
+	}
+
+	columns := make([]StatusColumn, 0, len(statuses))
+	for _, status := range statuses {
+		column := StatusColumn{Status: status, Notes: byStatus[status.Name], OldestFirst: oldestFirst[status.Name]}
+		slices.SortStableFunc(column.Notes, func(a, b model.Note) int {
+			dateA, okA := NoteModified(a, loc)
+			dateB, okB := NoteModified(b, loc)
+			switch {
+			case okA != okB:
+				if okA {
+					return -1
+				}
+				return 1
+			case !dateA.Equal(dateB):
+				if column.OldestFirst {
+					return dateA.Compare(dateB)
+				}
+				return dateB.Compare(dateA)
+			}
+			return strings.Compare(a.Path, b.Path)
+		})
+		columns = append(columns, column)
+	}
+	return columns
+}
+
+// NoteModified returns the date of the "modified" frontmatter key, or "updated" as a fallback
+func NoteModified(note model.Note, loc *time.Location) (time.Time, bool) {
+	for _, key := range []string{"modified", "updated"} {
+		if date, _, ok := ParseDate(note.Metadata[key], loc); ok {
+			return date, true
+		}
+	}
+	return time.Time{}, false
+}
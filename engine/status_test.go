@@ -0,0 +1,131 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/EwenQuim/pluie/model"
+)
+
+func TestParseStatuses(t *testing.T) {
+	statuses := ParseStatuses("Seed:lime, sprout:green,evergreen:nope,draft,seed:red", "draft, REVIEW")
+
+	expected := StatusSet{
+		{Name: "seed", Color: "lime"},
+		{Name: "sprout", Color: "green"},
+		{Name: "evergreen", Color: "gray"}, // Invalid color
+		{Name: "draft", Color: "gray", Private: true},
+	}
+	if len(statuses) != len(expected) {
+		t.Fatalf("ParseStatuses() = %+v, want %+v", statuses, expected)
+	}
+	for i := range expected {
+		if statuses[i] != expected[i] {
+			t.Errorf("status %d = %+v, want %+v", i, statuses[i], expected[i])
+		}
+	}
+
+	if statuses.Has("review") {
+		t.Error("private statuses should not be recognized unless listed")
+	}
+	if len(ParseStatuses("", "draft")) != 0 {
+		t.Error("an empty spec should recognize no status")
+	}
+}
+
+func TestStatusVisibility(t *testing.T) {
+	statuses := ParseStatuses("draft:gray,published:green", "draft")
+
+	notes := []model.Note{
+		{Slug: "draft", Path: "draft.md", Metadata: map[string]any{"status": "Draft"}},
+		{Slug: "explicit", Path: "explicit.md", Metadata: map[string]any{"status": "draft", "publish": true}},
+		{Slug: "folder", Path: "folder/note.md", Metadata: map[string]any{"status": "draft"}, IsPublic: true}, // Public through its folder
+		{Slug: "published", Path: "published.md", Metadata: map[string]any{"status": "published"}},
+		{Slug: "unknown", Path: "unknown.md", Metadata: map[string]any{"status": "wip"}},
+		{Slug: "none", Path: "none.md", Metadata: map[string]any{}},
+	}
+	ResolveStatuses(notes, statuses)
+
+	resolved := map[string]string{"draft": "draft", "explicit": "draft", "folder": "draft", "published": "published", "unknown": "", "none": ""}
+	for _, note := range notes {
+		if note.Status != resolved[note.Slug] {
+			t.Errorf("%s: status = %q, want %q", note.Slug, note.Status, resolved[note.Slug])
+		}
+	}
+
+	var visible []string
+	for _, note := range HideStatusPrivateNotes(notes, statuses) {
+		visible = append(visible, note.Slug)
+	}
+	// Explicit "publish: true" wins over the status, folder publishing does not
+	expected := []string{"explicit", "published", "unknown", "none"}
+	if len(visible) != len(expected) {
+		t.Fatalf("visible notes = %v, want %v", visible, expected)
+	}
+	for i := range expected {
+		if visible[i] != expected[i] {
+			t.Errorf("visible notes = %v, want %v", visible, expected)
+			break
+		}
+	}
+
+	// Without private statuses nothing is hidden
+	if len(HideStatusPrivateNotes(notes, ParseStatuses("draft:gray", ""))) != len(notes) {
+		t.Error("no note should be hidden without private statuses")
+	}
+}
+
+func TestStatusDiagnostics(t *testing.T) {
+	notes := []model.Note{
+		{Slug: "b", Path: "b.md", Metadata: map[string]any{"status": 3}},
+		{Slug: "a", Path: "a.md", Metadata: map[string]any{"status": "wip"}},
+		{Slug: "ok", Path: "ok.md", Metadata: map[string]any{"status": "draft"}, Status: "draft"},
+		{Slug: "empty", Path: "empty.md", Metadata: map[string]any{"status": nil}},
+	}
+
+	diagnostics := StatusDiagnostics(notes)
+	if len(diagnostics) != 2 {
+		t.Fatalf("expected 2 diagnostics, got %+v", diagnostics)
+	}
+	if diagnostics[0].Kind != DiagnosticStatus || diagnostics[0].Message != `Unknown status "wip"` || diagnostics[0].Slugs[0] != "a" {
+		t.Errorf("unexpected first diagnostic %+v", diagnostics[0])
+	}
+	if diagnostics[1].Message != `Unknown status "3"` {
+		t.Errorf("unexpected second diagnostic %+v", diagnostics[1])
+	}
+}
+
+func TestStatusColumns(t *testing.T) {
+	statuses := ParseStatuses("draft:gray,review:yellow,published:green", "")
+	notes := []model.Note{
+		{Path: "old.md", Status: "draft", Metadata: map[string]any{"modified": "2024-01-01"}},
+		{Path: "undated.md", Status: "draft", Metadata: map[string]any{}},
+		{Path: "new.md", Status: "draft", Metadata: map[string]any{"updated": time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)}},
+		{Path: "published.md", Status: "published", Metadata: map[string]any{}},
+		{Path: "none.md", Metadata: map[string]any{}},
+	}
+
+	paths := func(column StatusColumn) []string {
+		var result []string
+		for _, note := range column.Notes {
+			result = append(result, note.Path)
+		}
+		return result
+	}
+
+	columns := StatusColumns(notes, statuses, nil, time.UTC)
+	if len(columns) != 3 || columns[0].Status.Name != "draft" || columns[1].Status.Name != "review" || columns[2].Status.Name != "published" {
+		t.Fatalf("columns should follow the status order, got %+v", columns)
+	}
+	if got := paths(columns[0]); len(got) != 3 || got[0] != "new.md" || got[1] != "old.md" || got[2] != "undated.md" {
+		t.Errorf("draft column = %v, want newest first and undated last", got)
+	}
+	if len(columns[1].Notes) != 0 || len(columns[2].Notes) != 1 {
+		t.Errorf("unexpected column sizes %d, %d", len(columns[1].Notes), len(columns[2].Notes))
+	}
+
+	columns = StatusColumns(notes, statuses, map[string]bool{"draft": true}, time.UTC)
+	if got := paths(columns[0]); !columns[0].OldestFirst || got[0] != "old.md" || got[1] != "new.md" || got[2] != "undated.md" {
+		t.Errorf("draft column = %v, want oldest first and undated last", got)
+	}
+}
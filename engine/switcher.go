@@ -0,0 +1,343 @@
+package engine
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/EwenQuim/pluie/model"
+)
+
+// Kinds of switcher entries
+const (
+	SwitcherNote    = "note"    // Note title
+	SwitcherAlias   = "alias"   // "aliases" frontmatter value
+	SwitcherHeading = "heading" // H1 or H2 heading of a note
+)
+
+// Switcher match tiers, from the best to the worst
+const (
+	SwitcherTierPrefix   = "prefix"   // The entry starts with the query
+	SwitcherTierInitials = "initials" // Every query word starts at a word of the entry: "mtg ac" for "Meeting — Acme Corp"
+	SwitcherTierFuzzy    = "fuzzy"    // The query letters appear in order in the entry
+)
+
+// switcherKinds are the kinds of entries, indexed by switcherEntry.kind
+var switcherKinds = [...]string{switcherKindNote: SwitcherNote, switcherKindAlias: SwitcherAlias, switcherKindHeading: SwitcherHeading}
+
+// Compact kinds stored in the entries
+const (
+	switcherKindNote uint8 = iota
+	switcherKindAlias
+	switcherKindHeading
+)
+
+// Compact tiers stored in the candidates
+const (
+	switcherTierPrefix uint8 = iota
+	switcherTierInitials
+	switcherTierFuzzy
+)
+
+// switcherTiers are the tiers of matches, indexed by switcherCandidate.tier
+var switcherTiers = [...]string{switcherTierPrefix: SwitcherTierPrefix, switcherTierInitials: SwitcherTierInitials, switcherTierFuzzy: SwitcherTierFuzzy}
+
+// Base score of each tier, far enough apart that the tier always decides the ranking
+var switcherTierScores = [...]int{switcherTierPrefix: 3000, switcherTierInitials: 2000, switcherTierFuzzy: 1000}
+
+// Bonus of each kind, to rank a note before its headings on the same match
+var switcherKindBonus = [...]int{switcherKindNote: 20, switcherKindAlias: 10, switcherKindHeading: 0}
+
+// SwitcherResult is a match of the quick switcher
+type SwitcherResult struct {
+	Text      string `json:"text"`       // Matched title, alias or heading
+	Kind      string `json:"kind"`       // One of the Switcher* kinds
+	Tier      string `json:"tier"`       // One of the SwitcherTier* tiers
+	Score     int    `json:"score"`      // Higher is better
+	Slug      string `json:"slug"`       // Slug of the note
+	NoteTitle string `json:"note_title"` // Title of the note, differs from Text for aliases and headings
+	URL       string `json:"url"`        // Note URL, with the heading anchor for headings
+}
+
+// switcherEntry is a searchable text of the index. Texts are spans of SwitcherIndex.text.
+type switcherEntry struct {
+	lowerStart, lowerEnd     uint32 // Lowercase text, for matching
+	displayStart, displayEnd uint32 // Text as written, for results
+	note                     uint32 // Index in SwitcherIndex.notes
+	kind                     uint8  // Index in switcherKinds
+	letters                  uint64 // ASCII letters and digits of the text, see letterMask
+}
+
+// switcherNote is the note an entry points back to
+type switcherNote struct {
+	slug  string
+	title string
+}
+
+// SwitcherIndex matches note titles, aliases and H1/H2 headings for the quick switcher.
+// All texts share one string to keep the index small, entries only hold offsets.
+type SwitcherIndex struct {
+	text    string
+	entries []switcherEntry
+	notes   []switcherNote
+}
+
+// BuildSwitcherIndex indexes the titles, aliases and H1/H2 headings of notes
+func BuildSwitcherIndex(notes []model.Note) *SwitcherIndex {
+	index := &SwitcherIndex{notes: make([]switcherNote, 0, len(notes))}
+	var text strings.Builder
+
+	add := func(note uint32, kind uint8, display string) {
+		display = strings.TrimSpace(display)
+		if display == "" {
+			return
+		}
+		entry := switcherEntry{note: note, kind: kind}
+		entry.displayStart = uint32(text.Len())
+		text.WriteString(display)
+		entry.displayEnd = uint32(text.Len())
+
+		// Most texts are already lowercase: share the span instead of storing them twice
+		lower := strings.ToLower(display)
+		if lower == display {
+			entry.lowerStart, entry.lowerEnd = entry.displayStart, entry.displayEnd
+		} else {
+			entry.lowerStart = uint32(text.Len())
+			text.WriteString(lower)
+			entry.lowerEnd = uint32(text.Len())
+		}
+		entry.letters = letterMask(lower)
+		index.entries = append(index.entries, entry)
+	}
+
+	for _, note := range notes {
+		i := uint32(len(index.notes))
+		index.notes = append(index.notes, switcherNote{slug: note.Slug, title: note.Title})
+
+		add(i, switcherKindNote, note.Title)
+		for _, alias := range noteAliases(note) {
+			add(i, switcherKindAlias, alias)
+		}
+		for _, section := range SplitSections(note.Content) {
+			if section.Level == 1 || section.Level == 2 {
+				add(i, switcherKindHeading, section.Heading)
+			}
+		}
+	}
+
+	index.text = text.String()
+	return index
+}
+
+// noteAliases returns the "aliases" (or "alias") frontmatter values of a note
+func noteAliases(note model.Note) []string {
+	return model.ParseAliases(note.Metadata)
+}
+
+// Len returns the number of indexed entries
+func (si *SwitcherIndex) Len() int {
+	return len(si.entries)
+}
+
+// switcherCandidate is a matching entry, before it becomes a SwitcherResult
+type switcherCandidate struct {
+	entry int32
+	score int32
+	tier  uint8
+}
+
+// Search returns the best matches of query, at most limit (0 for no limit).
+// A note matched by its title and aliases appears once, with its best match.
+func (si *SwitcherIndex) Search(query string, limit int) []SwitcherResult {
+	query = strings.ToLower(strings.Join(strings.Fields(query), " "))
+	if query == "" {
+		return nil
+	}
+	tokens := strings.Fields(query)
+	compact := strings.ReplaceAll(query, " ", "")
+	letters := letterMask(compact)
+
+	// Score every entry, keeping the best title or alias match of each note
+	var candidates []switcherCandidate
+	bestForNote := make([]int32, len(si.notes)) // Index+1 in candidates of the note match, 0 if none
+	for i, entry := range si.entries {
+		// Every tier needs all the letters of the query
+		if entry.letters&letters != letters {
+			continue
+		}
+		lower := si.text[entry.lowerStart:entry.lowerEnd]
+
+		var tier uint8
+		switch {
+		case strings.HasPrefix(lower, query):
+			tier = switcherTierPrefix
+		case matchInitials(lower, tokens):
+			tier = switcherTierInitials
+		case matchSubsequence(lower, compact):
+			tier = switcherTierFuzzy
+		default:
+			continue
+		}
+
+		// Shorter texts are closer to the query
+		score := switcherTierScores[tier] + switcherKindBonus[entry.kind] - min(len(lower), 200)
+		candidate := switcherCandidate{entry: int32(i), score: int32(score), tier: tier}
+
+		if entry.kind != switcherKindHeading {
+			if best := bestForNote[entry.note]; best != 0 {
+				if si.less(candidate, candidates[best-1]) {
+					candidates[best-1] = candidate
+				}
+				continue
+			}
+			bestForNote[entry.note] = int32(len(candidates) + 1)
+		}
+		candidates = append(candidates, candidate)
+	}
+
+	// Only the returned results are sorted and built
+	if limit > 0 && len(candidates) > limit {
+		candidates = si.top(candidates, limit)
+	} else {
+		slices.SortFunc(candidates, si.compare)
+	}
+
+	results := make([]SwitcherResult, 0, len(candidates))
+	for _, candidate := range candidates {
+		results = append(results, si.result(candidate))
+	}
+	return results
+}
+
+// top returns the limit best candidates, sorted, without sorting all of them
+func (si *SwitcherIndex) top(candidates []switcherCandidate, limit int) []switcherCandidate {
+	best := make([]switcherCandidate, 0, limit+1)
+	for _, candidate := range candidates {
+		if len(best) == limit && !si.less(candidate, best[limit-1]) {
+			continue
+		}
+		i, _ := slices.BinarySearchFunc(best, candidate, si.compare)
+		best = slices.Insert(best, i, candidate)
+		if len(best) > limit {
+			best = best[:limit]
+		}
+	}
+	return best
+}
+
+// compare orders candidates by score, then alphabetically
+func (si *SwitcherIndex) compare(a, b switcherCandidate) int {
+	if c := cmp.Compare(b.score, a.score); c != 0 {
+		return c
+	}
+	ea, eb := si.entries[a.entry], si.entries[b.entry]
+	if c := strings.Compare(si.text[ea.displayStart:ea.displayEnd], si.text[eb.displayStart:eb.displayEnd]); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.entry, b.entry)
+}
+
+// less reports whether a ranks before b
+func (si *SwitcherIndex) less(a, b switcherCandidate) bool {
+	return si.compare(a, b) < 0
+}
+
+// result builds the SwitcherResult of a candidate
+func (si *SwitcherIndex) result(candidate switcherCandidate) SwitcherResult {
+	entry := si.entries[candidate.entry]
+	note := si.notes[entry.note]
+	result := SwitcherResult{
+		Text:      si.text[entry.displayStart:entry.displayEnd],
+		Kind:      switcherKinds[entry.kind],
+		Tier:      switcherTiers[candidate.tier],
+		Score:     int(candidate.score),
+		Slug:      note.slug,
+		NoteTitle: note.title,
+		URL:       "/" + note.slug,
+	}
+	if entry.kind == switcherKindHeading {
+		result.URL += "#" + SlugifyHeading(result.Text)
+	}
+	return result
+}
+
+// letterMask returns a bit set of the ASCII lowercase letters and digits of text
+func letterMask(text string) uint64 {
+	var mask uint64
+	for i := range len(text) {
+		switch c := text[i]; {
+		case c >= 'a' && c <= 'z':
+			mask |= 1 << (c - 'a')
+		case c >= '0' && c <= '9':
+			mask |= 1 << (26 + c - '0')
+		}
+	}
+	return mask
+}
+
+// matchInitials reports whether every token, in order, starts at the beginning of a word of text
+// with its other letters in order after it, before the next token starts
+func matchInitials(text string, tokens []string) bool {
+	return matchInitialsFrom(text, 0, tokens)
+}
+
+func matchInitialsFrom(text string, from int, tokens []string) bool {
+	if len(tokens) == 0 {
+		return true
+	}
+	token := tokens[0]
+	first, size := utf8.DecodeRuneInString(token)
+
+	for start := from; start < len(text); {
+		r, width := utf8.DecodeRuneInString(text[start:])
+		if r == first && isWordStart(text, start) {
+			if end, ok := subsequenceEnd(text, start+width, token[size:]); ok && matchInitialsFrom(text, end, tokens[1:]) {
+				return true
+			}
+		}
+		start += width
+	}
+	return false
+}
+
+// isWordStart reports whether the rune at i starts a word: a letter or digit after anything else
+func isWordStart(text string, i int) bool {
+	r, _ := utf8.DecodeRuneInString(text[i:])
+	if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+		return false
+	}
+	if i == 0 {
+		return true
+	}
+	previous, _ := utf8.DecodeLastRuneInString(text[:i])
+	return !unicode.IsLetter(previous) && !unicode.IsDigit(previous)
+}
+
+// matchSubsequence reports whether the letters of query appear in order in text
+func matchSubsequence(text, query string) bool {
+	_, ok := subsequenceEnd(text, 0, query)
+	return ok
+}
+
+// subsequenceEnd matches the runes of query in order in text from the byte offset from,
+// and returns the offset after the last matched rune
+func subsequenceEnd(text string, from int, query string) (int, bool) {
+	pos := from
+	for _, want := range query {
+		found := false
+		for pos < len(text) {
+			r, width := utf8.DecodeRuneInString(text[pos:])
+			pos += width
+			if r == want {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return pos, false
+		}
+	}
+	return pos, true
+}
@@ -0,0 +1,180 @@
+package engine
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/EwenQuim/pluie/model"
+)
+
+func switcherTestNotes() []model.Note {
+	return []model.Note{
+		{Title: "Meeting — Acme Corp", Slug: "meeting-acme-corp", Content: "## Action items\n\nCall back."},
+		{Title: "Machine learning", Slug: "machine-learning", Metadata: map[string]any{"aliases": []any{"ML", "Deep stuff"}}},
+		{Title: "Recipes", Slug: "recipes", Content: "# Pancakes\n\n### Toppings\n\n```\n# not a heading\n```"},
+		{Title: "Garden", Slug: "garden", Metadata: map[string]any{"alias": "Backyard"}},
+	}
+}
+
+func TestSwitcherIndex_Tiers(t *testing.T) {
+	index := BuildSwitcherIndex(switcherTestNotes())
+
+	tests := []struct {
+		name     string
+		query    string
+		text     string // Expected first result
+		kind     string
+		tier     string
+		url      string
+		expected int // Number of results, -1 to skip
+	}{
+		{name: "prefix of a title", query: "meet", text: "Meeting — Acme Corp", kind: SwitcherNote, tier: SwitcherTierPrefix, url: "/meeting-acme-corp", expected: -1},
+		{name: "case insensitive prefix", query: "GARD", text: "Garden", kind: SwitcherNote, tier: SwitcherTierPrefix, url: "/garden", expected: 1},
+		{name: "word initials", query: "mtg ac", text: "Meeting — Acme Corp", kind: SwitcherNote, tier: SwitcherTierInitials, url: "/meeting-acme-corp", expected: -1},
+		{name: "initials across words", query: "ml", text: "ML", kind: SwitcherAlias, tier: SwitcherTierPrefix, url: "/machine-learning", expected: -1},
+		{name: "word prefix after the start", query: "acme", text: "Meeting — Acme Corp", kind: SwitcherNote, tier: SwitcherTierInitials, url: "/meeting-acme-corp", expected: 1},
+		{name: "alias", query: "backy", text: "Backyard", kind: SwitcherAlias, tier: SwitcherTierPrefix, url: "/garden", expected: 1},
+		{name: "heading", query: "pan", text: "Pancakes", kind: SwitcherHeading, tier: SwitcherTierPrefix, url: "/recipes#pancakes", expected: 1},
+		{name: "H2 heading", query: "action", text: "Action items", kind: SwitcherHeading, tier: SwitcherTierPrefix, url: "/meeting-acme-corp#action-items", expected: 1},
+		{name: "abbreviated word", query: "rcps", text: "Recipes", kind: SwitcherNote, tier: SwitcherTierInitials, url: "/recipes", expected: 1},
+		{name: "fuzzy subsequence", query: "ecps", text: "Recipes", kind: SwitcherNote, tier: SwitcherTierFuzzy, url: "/recipes", expected: 1},
+		{name: "H3 headings are not indexed", query: "toppings", expected: 0},
+		{name: "code blocks are not headings", query: "not a heading", expected: 0},
+		{name: "no match", query: "zzz", expected: 0},
+		{name: "empty query", query: "  ", expected: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := index.Search(tt.query, 0)
+			if tt.expected >= 0 && len(results) != tt.expected {
+				t.Fatalf("Search(%q) returned %d results, want %d: %+v", tt.query, len(results), tt.expected, results)
+			}
+			if tt.text == "" {
+				return
+			}
+			if len(results) == 0 {
+				t.Fatalf("Search(%q) returned no results", tt.query)
+			}
+			first := results[0]
+			if first.Text != tt.text || first.Kind != tt.kind || first.Tier != tt.tier || first.URL != tt.url {
+				t.Errorf("Search(%q)[0] = %+v, want %q (%s, %s) at %s", tt.query, first, tt.text, tt.kind, tt.tier, tt.url)
+			}
+		})
+	}
+}
+
+func TestSwitcherIndex_Ranking(t *testing.T) {
+	index := BuildSwitcherIndex([]model.Note{
+		{Title: "Cooking basics", Slug: "cooking-basics"},     // Prefix
+		{Title: "Chef outfit knowledge", Slug: "chef-outfit"}, // Initials: "c" starts a word
+		{Title: "Chocolate", Slug: "chocolate"},               // Shorter initials
+		{Title: "Bacon", Slug: "bacon"},                       // Fuzzy: "c" inside a word
+		{Title: "Co", Slug: "co"},                             // Shorter prefix
+		{Title: "Notes", Slug: "notes", Content: "# Co-op"},   // Heading prefix, after note titles
+	})
+
+	tests := []struct {
+		name     string
+		query    string
+		expected []string
+	}{
+		{
+			name:     "prefix before initials before fuzzy, shorter texts first",
+			query:    "co",
+			expected: []string{"Co", "Cooking basics", "Co-op", "Chocolate", "Chef outfit knowledge", "Bacon"},
+		},
+		{
+			name:     "every word must start a word",
+			query:    "ch ou",
+			expected: []string{"Chef outfit knowledge"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := index.Search(tt.query, 0)
+			var texts []string
+			for _, result := range results {
+				texts = append(texts, result.Text)
+			}
+			if fmt.Sprint(texts) != fmt.Sprint(tt.expected) {
+				t.Errorf("Search(%q) = %v, want %v", tt.query, texts, tt.expected)
+			}
+		})
+	}
+
+	limited := index.Search("co", 3)
+	if len(limited) != 3 || limited[0].Text != "Co" || limited[2].Text != "Co-op" {
+		t.Errorf("Search() with a limit should return the best results, got %+v", limited)
+	}
+}
+
+func TestSwitcherIndex_OneResultPerNote(t *testing.T) {
+	index := BuildSwitcherIndex([]model.Note{
+		{Title: "Journal", Slug: "journal", Metadata: map[string]any{"aliases": []any{"Jour", "Diary"}}},
+	})
+
+	results := index.Search("jour", 0)
+	if len(results) != 1 {
+		t.Fatalf("expected the title and alias matches to be merged, got %+v", results)
+	}
+	if results[0].Text != "Journal" || results[0].Kind != SwitcherNote {
+		t.Errorf("expected the best match (the title), got %+v", results[0])
+	}
+
+	results = index.Search("dia", 0)
+	if len(results) != 1 || results[0].Text != "Diary" || results[0].NoteTitle != "Journal" || results[0].URL != "/journal" {
+		t.Errorf("expected the alias to point to its note, got %+v", results)
+	}
+}
+
+func TestNotesService_SwitcherRebuild(t *testing.T) {
+	notes := []model.Note{{Title: "First", Slug: "first"}}
+	notesMap := map[string]model.Note{"first": notes[0]}
+	ns := NewNotesService(&notesMap, BuildTree(notes), nil)
+
+	if results := ns.Switcher(true).Search("first", 0); len(results) != 1 {
+		t.Fatalf("expected the first note, got %+v", results)
+	}
+	if ns.Switcher(true) != ns.Switcher(true) {
+		t.Error("the index should be cached between updates")
+	}
+
+	notes = []model.Note{{Title: "Second", Slug: "second"}}
+	notesMap = map[string]model.Note{"second": notes[0]}
+	ns.UpdateData(&notesMap, BuildTree(notes), nil)
+
+	if results := ns.Switcher(true).Search("first", 0); len(results) != 0 {
+		t.Errorf("the index should be rebuilt after UpdateData, got %+v", results)
+	}
+	if results := ns.Switcher(true).Search("sec", 0); len(results) != 1 {
+		t.Errorf("expected the second note, got %+v", results)
+	}
+}
+
+func BenchmarkSwitcherIndexSearch(b *testing.B) {
+	words := []string{"meeting", "acme", "corp", "project", "notes", "garden", "recipe", "weekly", "review", "ideas"}
+	notes := make([]model.Note, 0, 2500)
+	for i := range 2500 {
+		notes = append(notes, model.Note{
+			Title: fmt.Sprintf("%s %s %d", words[i%10], words[(i/10)%10], i),
+			Slug:  fmt.Sprintf("note-%d", i),
+			// Title, alias and two headings: 10k entries
+			Metadata: map[string]any{"aliases": []any{fmt.Sprintf("%s alias %d", words[(i/3)%10], i)}},
+			Content:  fmt.Sprintf("# %s overview\n\ntext\n\n## %s details %d\n\ntext", words[(i/7)%10], words[(i/5)%10], i),
+		})
+	}
+	index := BuildSwitcherIndex(notes)
+	if index.Len() != 10000 {
+		b.Fatalf("expected 10k entries, got %d", index.Len())
+	}
+
+	for _, query := range []string{"meet", "mtg ac", "rvw"} {
+		b.Run(query, func(b *testing.B) {
+			for b.Loop() {
+				index.Search(query, 20)
+			}
+		})
+	}
+}
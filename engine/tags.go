@@ -2,6 +2,7 @@ package engine
 
 import (
 	"log/slog"
+	"slices"
 	"strings"
 	"time"
 
@@ -24,13 +25,9 @@ func BuildTagIndex(notes []model.Note) TagIndex {
 	tagIndex := make(TagIndex)
 
 	for _, note := range notes {
-		tags := extractAllTags(note)
-		for _, tag := range tags {
-			// Normalize tag (lowercase, trim spaces)
-			normalizedTag := strings.ToLower(strings.TrimSpace(tag))
-			if normalizedTag != "" {
-				tagIndex[normalizedTag] = append(tagIndex[normalizedTag], note)
-			}
+		// Normalized and without duplicates, a tag both in the metadata and the text counts once
+		for _, tag := range NoteTags(note) {
+			tagIndex[tag] = append(tagIndex[tag], note)
 		}
 	}
 
@@ -52,6 +49,18 @@ func extractAllTags(note model.Note) []string {
 	return allTags
 }
 
+// NoteTags returns the tags of the note, lowercase, sorted and without duplicates. Never nil.
+func NoteTags(note model.Note) []string {
+	tags := []string{}
+	for _, tag := range extractAllTags(note) {
+		if tag = strings.ToLower(strings.TrimSpace(tag)); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	slices.Sort(tags)
+	return slices.Compact(tags)
+}
+
 // extractMetadataTags extracts tags from the metadata "tags" field
 func extractMetadataTags(metadata map[string]any) []string {
 	var tags []string
@@ -105,6 +114,63 @@ func (tagIndex TagIndex) GetNotesWithTag(tag string) []model.Note {
 	return []model.Note{}
 }
 
+// TagGroup is a tag with its notes
+type TagGroup struct {
+	Tag   string
+	Notes []model.Note
+}
+
+// GetNotesWithTagOrChildren returns the notes with the tag or a tag nested under it, like
+// "golang/web" and "golang/web/htmx" for "golang", grouped by tag: the tag itself first, then the
+// nested tags sorted. A note with several of these tags is only in the group of its most nested
+// one. Groups without notes are left out.
+func (tagIndex TagIndex) GetNotesWithTagOrChildren(tag string) []TagGroup {
+	normalizedTag := strings.Trim(strings.ToLower(strings.TrimSpace(tag)), "#/")
+	if normalizedTag == "" {
+		return []TagGroup{}
+	}
+
+	var tags []string
+	for indexed := range tagIndex {
+		if indexed == normalizedTag || strings.HasPrefix(indexed, normalizedTag+"/") {
+			tags = append(tags, indexed)
+		}
+	}
+	// Most nested tags first, so that they get the notes that also have a parent tag
+	slices.SortFunc(tags, func(a, b string) int {
+		if depth := strings.Count(b, "/") - strings.Count(a, "/"); depth != 0 {
+			return depth
+		}
+		return strings.Compare(a, b)
+	})
+
+	seen := make(map[string]bool)
+	groups := make([]TagGroup, 0, len(tags))
+	for _, indexed := range tags {
+		var notes []model.Note
+		for _, note := range tagIndex[indexed] {
+			if !seen[note.Slug] {
+				seen[note.Slug] = true
+				notes = append(notes, note)
+			}
+		}
+		if len(notes) > 0 {
+			groups = append(groups, TagGroup{Tag: indexed, Notes: notes})
+		}
+	}
+
+	slices.SortFunc(groups, func(a, b TagGroup) int {
+		switch {
+		case a.Tag == normalizedTag:
+			return -1
+		case b.Tag == normalizedTag:
+			return 1
+		}
+		return strings.Compare(a.Tag, b.Tag)
+	})
+	return groups
+}
+
 // GetAllTags returns all unique tags in the index
 func (tagIndex TagIndex) GetAllTags() []string {
 	var tags []string
@@ -114,6 +180,61 @@ func (tagIndex TagIndex) GetAllTags() []string {
 	return tags
 }
 
+// TagCount is a tag with its number of notes
+type TagCount struct {
+	Tag   string
+	Count int
+}
+
+// Counts returns every tag of the index with its number of notes, sorted by tag
+func (tagIndex TagIndex) Counts() []TagCount {
+	counts := make([]TagCount, 0, len(tagIndex))
+	for tag, notes := range tagIndex {
+		counts = append(counts, TagCount{Tag: tag, Count: len(notes)})
+	}
+	slices.SortFunc(counts, func(a, b TagCount) int { return strings.Compare(a.Tag, b.Tag) })
+	return counts
+}
+
+// AllTags returns every tag of the index with its number of notes, most used first, then by tag
+func (tagIndex TagIndex) AllTags() []TagCount {
+	counts := tagIndex.Counts()
+	slices.SortStableFunc(counts, func(a, b TagCount) int { return b.Count - a.Count })
+	return counts
+}
+
+// ChildTags returns the tags one level under parent, sorted by tag: "golang/web" under "golang",
+// the top-level tags for an empty parent. parent is matched case-insensitively. A level that only
+// exists through deeper tags, like "golang/web" for a lone "golang/web/htmx", has a zero Count.
+func (tagIndex TagIndex) ChildTags(parent string) []TagCount {
+	prefix := strings.Trim(strings.ToLower(strings.TrimSpace(parent)), "#/")
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	children := make(map[string]int)
+	for tag, notes := range tagIndex {
+		rest, ok := strings.CutPrefix(tag, prefix)
+		if !ok || rest == "" {
+			continue
+		}
+		if name, _, nested := strings.Cut(rest, "/"); nested {
+			if _, seen := children[prefix+name]; !seen {
+				children[prefix+name] = 0
+			}
+		} else {
+			children[tag] = len(notes)
+		}
+	}
+
+	counts := make([]TagCount, 0, len(children))
+	for tag, count := range children {
+		counts = append(counts, TagCount{Tag: tag, Count: count})
+	}
+	slices.SortFunc(counts, func(a, b TagCount) int { return strings.Compare(a.Tag, b.Tag) })
+	return counts
+}
+
 // GetTagsContaining returns all tags that contain the specified substring
 func (tagIndex TagIndex) GetTagsContaining(substring string) []string {
 	var matchingTags []string
@@ -1,6 +1,8 @@
 package engine
 
 import (
+	"maps"
+	"slices"
 	"testing"
 
 	"github.com/EwenQuim/pluie/model"
@@ -187,6 +189,19 @@ func TestGetTagsContaining(t *testing.T) {
 	}
 }
 
+func TestTagIndexCounts(t *testing.T) {
+	tagIndex := BuildTagIndex([]model.Note{
+		{Title: "Note 1", Content: "Content with #golang and #golang/web tags."},
+		{Title: "Note 2", Content: "Content with #golang too."},
+	})
+
+	counts := tagIndex.Counts()
+	expected := []TagCount{{Tag: "golang", Count: 2}, {Tag: "golang/web", Count: 1}}
+	if len(counts) != len(expected) || counts[0] != expected[0] || counts[1] != expected[1] {
+		t.Errorf("Counts() = %v, want %v", counts, expected)
+	}
+}
+
 func TestParseHashtagLinks(t *testing.T) {
 	testCases := []struct {
 		name     string
@@ -364,3 +379,106 @@ func TestParseTagLinksInMetadata(t *testing.T) {
 		})
 	}
 }
+
+func TestBuildTagIndex_EmbedsDoNotLeakTags(t *testing.T) {
+	notes := []model.Note{
+		{Title: "Host", Slug: "host", Content: "Intro #host-tag\n\n![[Embedded]]"},
+		{Title: "Embedded", Slug: "embedded", Content: "Embedded body #embedded-tag"},
+	}
+
+	tagIndex := BuildTagIndex(notes)
+
+	embeddedTagNotes := tagIndex.GetNotesWithTag("embedded-tag")
+	if len(embeddedTagNotes) != 1 || embeddedTagNotes[0].Slug != "embedded" {
+		t.Errorf("Expected 'embedded-tag' only on the embedded note, got %v", embeddedTagNotes)
+	}
+}
+
+func TestTagIndexAllTags(t *testing.T) {
+	tagIndex := BuildTagIndex([]model.Note{
+		{Title: "Note 1", Content: "#Golang and #rust", Metadata: map[string]any{"tags": []any{"golang"}}},
+		{Title: "Note 2", Content: "#golang/web"},
+		{Title: "Note 3", Content: "#GOLANG #Rust"},
+	})
+
+	// Tags are case-insensitive, and a tag in both the metadata and the text counts once
+	got := tagIndex.AllTags()
+	expected := []TagCount{{Tag: "golang", Count: 2}, {Tag: "rust", Count: 2}, {Tag: "golang/web", Count: 1}}
+	if !slices.Equal(got, expected) {
+		t.Errorf("AllTags() = %v, want %v", got, expected)
+	}
+}
+
+func TestTagIndexChildTags(t *testing.T) {
+	tagIndex := BuildTagIndex([]model.Note{
+		{Title: "Note 1", Content: "#golang #golang/web #golang/web/htmx"},
+		{Title: "Note 2", Content: "#Golang/CLI #golang/tools/lint #rust"},
+	})
+
+	tests := []struct {
+		parent   string
+		expected []TagCount
+	}{
+		{"", []TagCount{{Tag: "golang", Count: 1}, {Tag: "rust", Count: 1}}},
+		{"golang", []TagCount{{Tag: "golang/cli", Count: 1}, {Tag: "golang/tools", Count: 0}, {Tag: "golang/web", Count: 1}}},
+		{"#GoLang/", []TagCount{{Tag: "golang/cli", Count: 1}, {Tag: "golang/tools", Count: 0}, {Tag: "golang/web", Count: 1}}},
+		{"golang/web", []TagCount{{Tag: "golang/web/htmx", Count: 1}}},
+		{"golang/tools", []TagCount{{Tag: "golang/tools/lint", Count: 1}}},
+		{"go", []TagCount{}},
+		{"rust", []TagCount{}},
+	}
+	for _, tt := range tests {
+		if got := tagIndex.ChildTags(tt.parent); !slices.Equal(got, tt.expected) {
+			t.Errorf("ChildTags(%q) = %v, want %v", tt.parent, got, tt.expected)
+		}
+	}
+}
+
+func TestGetNotesWithTagOrChildren(t *testing.T) {
+	tagIndex := BuildTagIndex([]model.Note{
+		{Slug: "intro", Content: "#golang"},
+		{Slug: "server", Content: "#golang and #golang/web"},
+		{Slug: "htmx", Content: "#golang/web/htmx and #golang/web"},
+		{Slug: "table-tests", Content: "#Golang/Testing"},
+		{Slug: "gopher", Content: "#gopher is not nested under go"},
+	})
+
+	slugs := func(groups []TagGroup) map[string][]string {
+		got := map[string][]string{}
+		for _, group := range groups {
+			for _, note := range group.Notes {
+				got[group.Tag] = append(got[group.Tag], note.Slug)
+			}
+		}
+		return got
+	}
+
+	groups := tagIndex.GetNotesWithTagOrChildren("golang")
+	var tags []string
+	for _, group := range groups {
+		tags = append(tags, group.Tag)
+	}
+	if expected := []string{"golang", "golang/testing", "golang/web", "golang/web/htmx"}; !slices.Equal(tags, expected) {
+		t.Fatalf("groups = %v, want %v", tags, expected)
+	}
+	// Notes with a parent and a nested tag are only in the group of the most nested one
+	expected := map[string][]string{
+		"golang":          {"intro"},
+		"golang/testing":  {"table-tests"},
+		"golang/web":      {"server"},
+		"golang/web/htmx": {"htmx"},
+	}
+	if got := slugs(groups); !maps.EqualFunc(got, expected, slices.Equal) {
+		t.Errorf("GetNotesWithTagOrChildren(golang) = %v, want %v", got, expected)
+	}
+
+	if got := slugs(tagIndex.GetNotesWithTagOrChildren("#Golang/Web")); !maps.EqualFunc(got, map[string][]string{"golang/web": {"server"}, "golang/web/htmx": {"htmx"}}, slices.Equal) {
+		t.Errorf("GetNotesWithTagOrChildren(#Golang/Web) = %v", got)
+	}
+	if got := slugs(tagIndex.GetNotesWithTagOrChildren("go")); len(got) != 0 {
+		t.Errorf("GetNotesWithTagOrChildren(go) = %v, want no prefix match", got)
+	}
+	if groups := tagIndex.GetNotesWithTagOrChildren(""); len(groups) != 0 {
+		t.Errorf("GetNotesWithTagOrChildren(\"\") = %v, want none", groups)
+	}
+}
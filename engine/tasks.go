@@ -0,0 +1,99 @@
+package engine
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/EwenQuim/pluie/model"
+)
+
+// Task is a "- [ ]" or "- [x]" checkbox of a note
+type Task struct {
+	Text  string
+	Done  bool
+	Line  int // 1-based line in the note content, after the frontmatter
+	Depth int // 0 for a top-level list item, 1 when nested under another item...
+}
+
+// listItemRegex matches list items, bullet or numbered, with their indentation
+var listItemRegex = regexp.MustCompile(`^([ \t]*)(?:[-*+]|\d+[.)])\s`)
+
+// taskRegex matches the checkbox and the text of a list item
+var taskRegex = regexp.MustCompile(`^[ \t]*(?:[-*+]|\d+[.)])\s+\[([ xX])\]\s+(.+)$`)
+
+// ExtractTasks returns the tasks of the note in content order. Tasks in code blocks are skipped.
+func ExtractTasks(note model.Note) []Task {
+	var tasks []Task
+	var indents []int // Indentation of the enclosing list items
+	inFence := false
+	for i, line := range strings.Split(note.Content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			inFence = !inFence
+			continue
+		}
+		if inFence || trimmed == "" {
+			continue
+		}
+
+		item := listItemRegex.FindStringSubmatch(line)
+		if item == nil {
+			// A paragraph ends the list, indented lines continue its item
+			if !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t") {
+				indents = indents[:0]
+			}
+			continue
+		}
+		indent := indentWidth(item[1])
+		for len(indents) > 0 && indents[len(indents)-1] >= indent {
+			indents = indents[:len(indents)-1]
+		}
+		depth := len(indents)
+		indents = append(indents, indent)
+
+		if match := taskRegex.FindStringSubmatch(line); match != nil {
+			tasks = append(tasks, Task{
+				Text:  strings.TrimSpace(match[2]),
+				Done:  match[1] != " ",
+				Line:  i + 1,
+				Depth: depth,
+			})
+		}
+	}
+	return tasks
+}
+
+// indentWidth is the width of leading whitespace, tabs counting as 4 spaces
+func indentWidth(indent string) int {
+	return len(strings.ReplaceAll(indent, "\t", "    "))
+}
+
+// NoteTasks is a note and its open tasks, for the tasks page
+type NoteTasks struct {
+	Note  model.Note
+	Tasks []Task
+}
+
+// OpenTasks groups the open tasks of the public notes by note, sorted by note title.
+// Notes without open tasks are left out.
+func OpenTasks(notes []model.Note, publicByDefault bool) []NoteTasks {
+	var groups []NoteTasks
+	for _, note := range notes {
+		if !IsVisible(note, publicByDefault) {
+			continue
+		}
+		open := slices.DeleteFunc(ExtractTasks(note), func(task Task) bool { return task.Done })
+		if len(open) > 0 {
+			groups = append(groups, NoteTasks{Note: note, Tasks: open})
+		}
+	}
+
+	slices.SortFunc(groups, func(a, b NoteTasks) int {
+		if c := strings.Compare(strings.ToLower(a.Note.Title), strings.ToLower(b.Note.Title)); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Note.Slug, b.Note.Slug)
+	})
+	return groups
+}
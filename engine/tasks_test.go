@@ -0,0 +1,65 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/EwenQuim/pluie/model"
+)
+
+func TestExtractTasks(t *testing.T) {
+	content := "# Groceries\n" +
+		"- [ ] Buy [[Milk]]\n" +
+		"- [x] Buy bread\n" +
+		"  - [ ] Nested under a done task\n" +
+		"\t- [X] Tab nested, deeper\n" +
+		"- plain item\n" +
+		"    1. [ ] Numbered and nested\n" +
+		"\n" +
+		"```markdown\n" +
+		"- [ ] Inside a code block\n" +
+		"```\n" +
+		"Paragraph\n" +
+		"* [ ] Star bullet\n" +
+		"- [ ]\n" +
+		"- [?] Not a task\n"
+
+	expected := []Task{
+		{Text: "Buy [[Milk]]", Done: false, Line: 2, Depth: 0},
+		{Text: "Buy bread", Done: true, Line: 3, Depth: 0},
+		{Text: "Nested under a done task", Done: false, Line: 4, Depth: 1},
+		{Text: "Tab nested, deeper", Done: true, Line: 5, Depth: 2},
+		{Text: "Numbered and nested", Done: false, Line: 7, Depth: 1},
+		{Text: "Star bullet", Done: false, Line: 13, Depth: 0},
+	}
+
+	tasks := ExtractTasks(model.Note{Content: content})
+	if len(tasks) != len(expected) {
+		t.Fatalf("ExtractTasks() = %+v, want %d tasks", tasks, len(expected))
+	}
+	for i := range expected {
+		if tasks[i] != expected[i] {
+			t.Errorf("task %d = %+v, want %+v", i, tasks[i], expected[i])
+		}
+	}
+}
+
+func TestOpenTasks(t *testing.T) {
+	notes := []model.Note{
+		{Title: "Zoo", Slug: "zoo", IsPublic: true, Content: "- [ ] Feed the lions"},
+		{Title: "apples", Slug: "apples", IsPublic: true, Content: "- [x] Pick\n- [ ] Press"},
+		{Title: "Done", Slug: "done", IsPublic: true, Content: "- [x] Everything"},
+		{Title: "Secret", Slug: "secret", IsPublic: false, Content: "- [ ] Hidden"},
+	}
+
+	groups := OpenTasks(notes, false)
+	if len(groups) != 2 || groups[0].Note.Slug != "apples" || groups[1].Note.Slug != "zoo" {
+		t.Fatalf("OpenTasks() = %+v, want apples then zoo", groups)
+	}
+	if len(groups[0].Tasks) != 1 || groups[0].Tasks[0].Text != "Press" {
+		t.Errorf("only the open tasks should be listed, got %+v", groups[0].Tasks)
+	}
+
+	if groups := OpenTasks(notes, true); len(groups) != 3 {
+		t.Errorf("with public by default, the private note should be listed, got %+v", groups)
+	}
+}
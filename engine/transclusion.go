@@ -0,0 +1,42 @@
+package engine
+
+import (
+	"strings"
+
+	"github.com/EwenQuim/pluie/model"
+)
+
+// ProcessTransclusions replaces each ![[Note]] and ![[Note#Heading]] embed of a note of the tree
+// in content with replace(note, heading), heading being empty for the whole note. Titles
+// containing a # are matched as a whole first, like ParseWikiLinks. Embeds of attachments, see
+// ParseAttachmentEmbeds, of notes that don't exist and inside code are left untouched.
+func ProcessTransclusions(content string, tree *TreeNode, replace func(note *model.Note, heading string) string) string {
+	codeBlocks := findCodeBlocks(content)
+	index := tree.linkIndex()
+
+	var result strings.Builder
+	last := 0
+	for _, indexes := range attachmentEmbedRegex.FindAllStringSubmatchIndex(content, -1) {
+		start, end := indexes[0], indexes[1]
+		if insideCodeBlock(codeBlocks, start) {
+			continue
+		}
+
+		target := strings.TrimSpace(content[indexes[2]:indexes[3]])
+		var heading string
+		note := index.Find(target)
+		if title, section, isSection := strings.Cut(target, "#"); note == nil && isSection && strings.TrimSpace(title) != "" {
+			heading = strings.TrimSpace(section)
+			note = index.Find(strings.TrimSpace(title))
+		}
+		if note == nil {
+			continue
+		}
+
+		result.WriteString(content[last:start])
+		result.WriteString(replace(note, heading))
+		last = end
+	}
+	result.WriteString(content[last:])
+	return result.String()
+}
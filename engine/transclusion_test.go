@@ -0,0 +1,37 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/EwenQuim/pluie/model"
+)
+
+func TestProcessTransclusions(t *testing.T) {
+	tree := BuildTree([]model.Note{
+		{Title: "Recipe", Slug: "recipe", Path: "recipe.md"},
+		{Title: "C# tips", Slug: "c-tips", Path: "c-tips.md"},
+	})
+	replace := func(note *model.Note, heading string) string {
+		return "<" + note.Slug + "|" + heading + ">"
+	}
+
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{"whole note", "Before\n\n![[Recipe]]\n\nAfter", "Before\n\n<recipe|>\n\nAfter"},
+		{"section", "![[Recipe#Steps]] and ![[Recipe # Oven |alt]]", "<recipe|Steps> and <recipe|Oven>"},
+		{"title with a hash", "![[C# tips]]", "<c-tips|>"},
+		{"missing note", "![[Unknown]] and ![[#Steps]]", "![[Unknown]] and ![[#Steps]]"},
+		{"link, not an embed", "[[Recipe]]", "[[Recipe]]"},
+		{"in code", "`![[Recipe]]`\n\n```\n![[Recipe]]\n```", "`![[Recipe]]`\n\n```\n![[Recipe]]\n```"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ProcessTransclusions(tt.content, tree, replace); got != tt.expected {
+				t.Errorf("ProcessTransclusions(%q) = %q, want %q", tt.content, got, tt.expected)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package engine
 
 import (
 	"log/slog"
+	"slices"
 	"sort"
 	"strings"
 	"time"
@@ -11,33 +12,52 @@ import (
 
 // TreeNode represents a node in the file tree structure
 type TreeNode struct {
-	Name     string      `json:"name"`     // Display name (folder name or note title)
-	Path     string      `json:"path"`     // Full path from root
-	IsFolder bool        `json:"isFolder"` // True if this is a folder, false if it's a note
-	Note     *model.Note `json:"note"`     // Reference to the note if this is a note node
-	Children []*TreeNode `json:"children"` // Child nodes (subfolders and notes)
-	IsOpen   bool        `json:"isOpen"`   // Whether the folder is expanded in the UI
+	Name        string      `json:"name"`        // Display name (folder name or note title)
+	Path        string      `json:"path"`        // Full path from root
+	IsFolder    bool        `json:"isFolder"`    // True if this is a folder, false if it's a note
+	Note        *model.Note `json:"note"`        // Reference to the note if this is a note node
+	Children    []*TreeNode `json:"children"`    // Child nodes (subfolders and notes)
+	IsOpen      bool        `json:"isOpen"`      // Whether the folder is expanded in the UI
+	Icon        string      `json:"icon"`        // Emoji or image URL shown before a folder name, see FolderSettings
+	Order       *int        `json:"order"`       // Position of a folder among its sibling folders, see FolderSettings
+	Description string      `json:"description"` // Shown on the index page of a folder, see FolderSettings
+
+	links *LinkIndex // Wikilink targets of the notes of a root, see NewLinkIndex
 }
 
 // AllNotes yields all notes in the tree using Go 1.23 iterator pattern
 func (t *TreeNode) AllNotes(yield func(*TreeNode) bool) {
+	t.allNotes(yield)
+}
+
+// allNotes yields the notes of the tree, and reports whether the iteration should go on
+func (t *TreeNode) allNotes(yield func(*TreeNode) bool) bool {
 	if t == nil {
-		return
+		return true
 	}
 
 	if !t.IsFolder && t.Note != nil {
 		if !yield(t) {
-			return
+			return false
 		}
 	}
 
 	for _, child := range t.Children {
-		child.AllNotes(yield)
+		if !child.allNotes(yield) {
+			return false
+		}
 	}
+	return true
 }
 
 // BuildTree creates a tree structure from a list of notes
 func BuildTree(notes []model.Note) *TreeNode {
+	return BuildTreeWithFolders(notes, nil)
+}
+
+// BuildTreeWithFolders creates a tree structure from a list of notes, with the order, icon
+// and initial open state of the folders in folders, keyed by folder path like "Blog/2024"
+func BuildTreeWithFolders(notes []model.Note, folders map[string]FolderSettings) *TreeNode {
 	start := time.Now()
 	defer func() {
 		elapsed := time.Since(start)
@@ -99,12 +119,19 @@ func BuildTree(notes []model.Note) *TreeNode {
 			folderNode, exists := folderMap[currentPath]
 			if !exists {
 				// Create new folder node
+				settings := folders[currentPath]
 				folderNode = &TreeNode{
-					Name:     part,
-					Path:     currentPath,
-					IsFolder: true,
-					Children: make([]*TreeNode, 0),
-					IsOpen:   i == 0, // Only open first level by default
+					Name:        part,
+					Path:        currentPath,
+					IsFolder:    true,
+					Children:    make([]*TreeNode, 0),
+					IsOpen:      i == 0, // Only open first level by default
+					Icon:        settings.Icon,
+					Order:       settings.Order,
+					Description: settings.Description,
+				}
+				if settings.Collapsed != nil {
+					folderNode.IsOpen = !*settings.Collapsed
 				}
 				folderMap[currentPath] = folderNode
 				currentParent.Children = append(currentParent.Children, folderNode)
@@ -123,9 +150,12 @@ func BuildTree(notes []model.Note) *TreeNode {
 		currentParent.Children = append(currentParent.Children, noteNode)
 	}
 
-	// Sort children at each level (folders first, then notes, both alphabetically)
+	// Sort children at each level (folders first, then notes, see CompareTreeNodes)
 	sortTreeChildren(root)
 
+	// Wikilinks are resolved for every page, the index is only built once
+	root.links = root.linkIndex()
+
 	return root
 }
 
@@ -135,21 +165,7 @@ func sortTreeChildren(node *TreeNode) {
 		return
 	}
 
-	// Sort children: folders first, then notes, both alphabetically
-	sort.Slice(node.Children, func(i, j int) bool {
-		a, b := node.Children[i], node.Children[j]
-
-		// Folders come before notes
-		if a.IsFolder && !b.IsFolder {
-			return true
-		}
-		if !a.IsFolder && b.IsFolder {
-			return false
-		}
-
-		// Both are same type, sort alphabetically
-		return strings.ToLower(a.Name) < strings.ToLower(b.Name)
-	})
+	slices.SortFunc(node.Children, CompareTreeNodes)
 
 	// Recursively sort children
 	for _, child := range node.Children {
@@ -225,11 +241,14 @@ func filterChildren(source *TreeNode, target *TreeNode, query string) {
 
 			// Create temp folder to check for matching descendants
 			tempFolder := &TreeNode{
-				Name:     child.Name,
-				Path:     child.Path,
-				IsFolder: true,
-				Children: make([]*TreeNode, 0),
-				IsOpen:   true, // Open folders in search results
+				Name:        child.Name,
+				Path:        child.Path,
+				IsFolder:    true,
+				Children:    make([]*TreeNode, 0),
+				IsOpen:      true, // Open folders in search results
+				Icon:        child.Icon,
+				Order:       child.Order,
+				Description: child.Description,
 			}
 
 			// Recursively filter children
@@ -269,12 +288,15 @@ func copyEntireSubtree(source *TreeNode) *TreeNode {
 	}
 
 	copy := &TreeNode{
-		Name:     source.Name,
-		Path:     source.Path,
-		IsFolder: source.IsFolder,
-		Note:     source.Note,
-		IsOpen:   true, // Open all folders in search results
-		Children: make([]*TreeNode, len(source.Children)),
+		Name:        source.Name,
+		Path:        source.Path,
+		IsFolder:    source.IsFolder,
+		Note:        source.Note,
+		IsOpen:      true, // Open all folders in search results
+		Children:    make([]*TreeNode, len(source.Children)),
+		Icon:        source.Icon,
+		Order:       source.Order,
+		Description: source.Description,
 	}
 
 	for i, child := range source.Children {
@@ -7,10 +7,12 @@ import (
 	"path"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/EwenQuim/pluie/config"
 	"github.com/EwenQuim/pluie/engine"
 	"github.com/EwenQuim/pluie/model"
 	"github.com/adrg/frontmatter"
@@ -22,6 +24,15 @@ var (
 
 type Explorer struct {
 	BasePath string
+	Exclude  engine.ExcludeMatcher // Vault paths never explored, see EXCLUDE_PATHS
+}
+
+// newExplorer returns the explorer of the vault at basePath, with the exclusions of cfg
+func newExplorer(basePath string, cfg *config.Config) Explorer {
+	return Explorer{
+		BasePath: basePath,
+		Exclude:  engine.NewExcludeMatcher(cfg.ExcludeGlobs, cfg.ExcludePathsIgnoreCase),
+	}
 }
 
 func (e Explorer) getFolderNotes(currentPath string) ([]model.Note, error) {
@@ -42,8 +53,99 @@ func (e Explorer) getFolderNotes(currentPath string) ([]model.Note, error) {
 	return notes, nil
 }
 
-// shouldSkipPath determines if a path should be skipped during exploration
+// getAttachments lists the vault paths of the attachments notes can embed or link to, like
+// "images/cat.png", in the folders explored for notes. Files with other extensions are left out.
+func (e Explorer) getAttachments(extensions engine.AttachmentExtensions) ([]string, error) {
+	var attachments []string
+	err := filepath.WalkDir(e.BasePath, func(filePath string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(e.BasePath, filePath)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+		if entry.IsDir() {
+			if relPath != "." && e.shouldSkipPath(relPath) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if extensions.Has(entry.Name()) && !strings.HasPrefix(entry.Name(), ".") && !e.Exclude.Match(relPath) {
+			attachments = append(attachments, relPath)
+		}
+		return nil
+	})
+	return attachments, err
+}
+
+// getFolderSettings reads the sidebar settings of the .pluie files of the explored folders,
+// keyed by folder path like "Blog/2024"
+func (e Explorer) getFolderSettings() (map[string]engine.FolderSettings, error) {
+	folders := make(map[string]engine.FolderSettings)
+	err := filepath.WalkDir(e.BasePath, func(filePath string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(e.BasePath, filePath)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+		if entry.IsDir() {
+			if relPath != "." && e.shouldSkipPath(relPath) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		folder := path.Dir(relPath)
+		if folder == "." || !strings.HasSuffix(entry.Name(), ".pluie") {
+			return nil
+		}
+		if metadata := e.parsePluieFile(folder, entry.Name()); metadata != nil {
+			folders[folder] = engine.ParseFolderSettings(metadata)
+		}
+		return nil
+	})
+	return folders, err
+}
+
+// filterPrivateAttachments removes the attachments of the folders made private by their
+// .pluie file, with "publish: false", like the notes of the folder
+func (e Explorer) filterPrivateAttachments(attachments []string) []string {
+	privateFolders := make(map[string]bool)
+	return slices.DeleteFunc(attachments, func(vaultPath string) bool {
+		folder := path.Dir(vaultPath)
+		if folder == "." {
+			folder = ""
+		}
+		private, checked := privateFolders[folder]
+		if !checked {
+			private = e.isPrivateFolder(folder)
+			privateFolders[folder] = private
+		}
+		return private
+	})
+}
+
+// isPrivateFolder reports whether the .pluie file of the folder sets "publish: false"
+func (e Explorer) isPrivateFolder(folder string) bool {
+	dir, err := os.ReadDir(filepath.Join(e.BasePath, folder))
+	if err != nil {
+		return false
+	}
+	publish, ok := e.collectFolderMetadata(dir, folder)[folder]["publish"].(bool)
+	return ok && !publish
+}
+
+// shouldSkipPath determines if a path should be skipped during exploration: hidden folders,
+// node_modules, and the excluded paths
 func (e Explorer) shouldSkipPath(currentPath string) bool {
+	if e.Exclude.Match(currentPath) {
+		return true
+	}
 	for segment := range strings.SplitSeq(currentPath, "/") {
 		if segment == "" {
 			continue
@@ -106,7 +208,7 @@ func (e Explorer) processDirectoryEntries(dir []os.DirEntry, currentPath string,
 					notes = append(notes, subfolderNotes...)
 					mu.Unlock()
 				}
-			} else if strings.HasSuffix(entry.Name(), ".md") {
+			} else if strings.HasSuffix(entry.Name(), ".md") && !e.Exclude.Match(path.Join(currentPath, entry.Name())) {
 				if note := e.processMarkdownFile(currentPath, entry.Name(), folderMetadata); note != nil {
 					mu.Lock()
 					notes = append(notes, *note)
@@ -123,10 +225,15 @@ func (e Explorer) processDirectoryEntries(dir []os.DirEntry, currentPath string,
 
 // processMarkdownFile processes a single markdown file
 func (e Explorer) processMarkdownFile(currentPath, fileName string, folderMetadata map[string]map[string]any) *model.Note {
-	contentBytes, err := os.ReadFile(filepath.Join(e.BasePath, currentPath, fileName))
+	filePath := filepath.Join(e.BasePath, currentPath, fileName)
+	contentBytes, err := os.ReadFile(filePath)
 	if err != nil {
 		return nil
 	}
+	var modTime time.Time
+	if info, err := os.Stat(filePath); err == nil {
+		modTime = info.ModTime()
+	}
 
 	// Parse frontmatter
 	metadata, finalContent, err := ParseMetadataAndContent(contentBytes)
@@ -146,9 +253,14 @@ func (e Explorer) processMarkdownFile(currentPath, fileName string, folderMetada
 		Slug:     path.Join(currentPath, fileName),
 		Path:     path.Join(currentPath, fileName),
 		Metadata: metadata,
+		ModTime:  modTime,
 	}
 	note.BuildSlug()
 	note.DetermineIsPublic(folderMetadata)
+	note.DetermineIsDraft(folderMetadata)
+	note.DetermineLayout(folderMetadata)
+	note.DetermineAccess(folderMetadata)
+	note.DetermineAliases()
 
 	return &note
 }
@@ -250,3 +362,62 @@ func filterPublicNotes(notes []model.Note, publicByDefault bool) []model.Note {
 	slog.Info("filtered notes", "publicNotes", len(publicNotes), "totalNotes", len(notes))
 	return publicNotes
 }
+
+// filterVisibleNotes filters notes like filterPublicNotes, and filters out the drafts unless
+// showDrafts, see config.Config.DraftsShown. Shown drafts are made public whatever their
+// frontmatter says, like in preview mode, the pages leaving drafts out check IsDraft.
+func filterVisibleNotes(notes []model.Note, publicByDefault, showDrafts bool) []model.Note {
+	var drafts, others []model.Note
+	for _, note := range notes {
+		if note.IsDraft {
+			drafts = append(drafts, note)
+		} else {
+			others = append(others, note)
+		}
+	}
+
+	visibleNotes := filterPublicNotes(others, publicByDefault)
+	if len(drafts) > 0 {
+		slog.Info("filtered drafts", "drafts", len(drafts), "shown", showDrafts)
+	}
+	if showDrafts {
+		visibleNotes = append(visibleNotes, forcePublicNotes(drafts)...)
+	}
+	return visibleNotes
+}
+
+// resolveDuplicatePermalinks keeps each permalink for one note: the last one in path order when
+// several notes have it, so the winner doesn't change between reloads. The others, and the
+// notes whose permalink is the slug of the path of another note, get their path slug back.
+func resolveDuplicatePermalinks(notes []model.Note) {
+	pathSlugs := make(map[string]bool, len(notes))
+	claims := make(map[string][]int) // Permalink slug -> indexes of the notes having it
+	for i, note := range notes {
+		if note.Permalink == "" {
+			pathSlugs[note.Slug] = true
+		} else {
+			claims[note.Slug] = append(claims[note.Slug], i)
+		}
+	}
+
+	for slug, indexes := range claims {
+		slices.SortFunc(indexes, func(a, b int) int {
+			return strings.Compare(notes[a].Path, notes[b].Path)
+		})
+		paths := make([]string, len(indexes))
+		for j, i := range indexes {
+			paths[j] = notes[i].Path
+		}
+
+		losers := indexes[:len(indexes)-1]
+		if pathSlugs[slug] {
+			slog.Error("Permalink is the URL of another note, ignored", "permalink", slug, "notes", paths)
+			losers = indexes
+		} else if len(indexes) > 1 {
+			slog.Error("Duplicate permalink, the last note in path order keeps it", "permalink", slug, "notes", paths, "kept", paths[len(paths)-1])
+		}
+		for _, i := range losers {
+			notes[i].BuildPathSlug()
+		}
+	}
+}
@@ -2,8 +2,12 @@ package main
 
 import (
 	"os"
+	"slices"
+	"strings"
 	"testing"
 
+	"github.com/EwenQuim/pluie/config"
+	"github.com/EwenQuim/pluie/engine"
 	"github.com/EwenQuim/pluie/model"
 )
 
@@ -372,3 +376,140 @@ func TestNoteDetermineIsPublic(t *testing.T) {
 		})
 	}
 }
+
+func TestExplorerGetAttachments(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "note.md", "# Note")
+	writeTestFile(t, dir, "images/Cat.PNG", "png")
+	writeTestFile(t, dir, "docs/guide.pdf", "pdf")
+	writeTestFile(t, dir, "docs/drawing.svg", "svg")
+	writeTestFile(t, dir, ".obsidian/icon.png", "png")
+	writeTestFile(t, dir, "node_modules/pkg/logo.png", "png")
+
+	writeTestFile(t, dir, "audio/song.mp3", "mp3")
+	writeTestFile(t, dir, "data/table.csv", "csv")
+
+	attachments, err := Explorer{BasePath: dir}.getAttachments(engine.ParseAttachmentExtensions(""))
+	if err != nil {
+		t.Fatalf("getAttachments() error: %v", err)
+	}
+	if !slices.Equal(attachments, []string{"audio/song.mp3", "docs/guide.pdf", "images/Cat.PNG"}) {
+		t.Errorf("attachments = %v, want the images, PDFs and audio files outside of skipped folders", attachments)
+	}
+
+	attachments, err = Explorer{BasePath: dir}.getAttachments(engine.ParseAttachmentExtensions("csv, .svg"))
+	if err != nil {
+		t.Fatalf("getAttachments() error: %v", err)
+	}
+	if !slices.Equal(attachments, []string{"data/table.csv"}) {
+		t.Errorf("attachments = %v, want only the configured extensions, SVG refused", attachments)
+	}
+}
+
+func TestExplorerFilterPrivateAttachments(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "private/.pluie", "---\npublish: false\n---\n")
+	writeTestFile(t, dir, "public/.pluie", "---\npublish: true\n---\n")
+
+	attachments := Explorer{BasePath: dir}.filterPrivateAttachments([]string{"cat.png", "private/secret.png", "private/deep/photo.png", "public/dog.png"})
+	if !slices.Equal(attachments, []string{"cat.png", "private/deep/photo.png", "public/dog.png"}) {
+		t.Errorf("attachments = %v, want the ones of the private folder removed", attachments)
+	}
+}
+
+func TestLoadNotes_ExcludePaths(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "archive/.pluie", "---\npublish: true\n---\n")
+	writeTestFile(t, dir, "archive/2023/Old.md", "# Old")
+	writeTestFile(t, dir, "archive/Older.md", "# Older")
+	writeTestFile(t, dir, "archive/photo.png", "png")
+	writeTestFile(t, dir, "blog/.pluie", "---\npublish: true\n---\n")
+	writeTestFile(t, dir, "blog/Post.md", "# Post\n\n![[photo.png]]")
+	writeTestFile(t, dir, "blog/Plan.excalidraw.md", "# Plan")
+	writeTestFile(t, dir, "blog/templates/Daily.md", "---\npublish: true\n---\n# Daily")
+
+	cfg := &config.Config{Path: dir, ExcludeGlobs: []string{"Archive/**", "*.excalidraw.md", "Templates"}, ExcludePathsIgnoreCase: true}
+	notesMap, tree, _, err := loadNotes(dir, cfg)
+	if err != nil {
+		t.Fatalf("loadNotes() error: %v", err)
+	}
+
+	// Exclusions win over the publish setting of the folder
+	var slugs []string
+	for slug := range *notesMap {
+		slugs = append(slugs, slug)
+	}
+	if !slices.Equal(slugs, []string{"blog/post"}) {
+		t.Errorf("notes = %q, want only the public note outside of the excluded paths", slugs)
+	}
+	for _, note := range engine.GetAllNotesFromTree(tree) {
+		if note.Slug != "blog/post" {
+			t.Errorf("excluded note %q should not be in the tree", note.Slug)
+		}
+	}
+	for _, child := range tree.Children {
+		if strings.EqualFold(child.Name, "archive") {
+			t.Error("the excluded folder should not be in the tree")
+		}
+	}
+	if post := (*notesMap)["blog/post"]; len(post.Attachments) != 0 {
+		t.Errorf("attachments of excluded folders should not be resolved, got %v", post.Attachments)
+	}
+}
+
+func TestExplorerGetFolderSettings(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, ".pluie", "---\norder: 1\n---\n")
+	writeTestFile(t, dir, "Blog/.pluie", "---\npublish: true\norder: 2\nicon: 📝\ncollapsed: true\n---\n")
+	writeTestFile(t, dir, "Blog/2024/.pluie", "---\nicon: /static/year.png\n---\n")
+	writeTestFile(t, dir, ".obsidian/.pluie", "---\norder: 3\n---\n")
+
+	folders, err := Explorer{BasePath: dir}.getFolderSettings()
+	if err != nil {
+		t.Fatalf("getFolderSettings() error: %v", err)
+	}
+	if len(folders) != 2 {
+		t.Fatalf("folders = %v, want Blog and Blog/2024 only", folders)
+	}
+	if blog := folders["Blog"]; blog.Order == nil || *blog.Order != 2 || blog.Icon != "📝" || blog.Collapsed == nil || !*blog.Collapsed {
+		t.Errorf("unexpected Blog settings %+v", blog)
+	}
+	if year := folders["Blog/2024"]; year.Icon != "/static/year.png" || year.Order != nil {
+		t.Errorf("unexpected Blog/2024 settings %+v", year)
+	}
+}
+
+func TestResolveDuplicatePermalinks(t *testing.T) {
+	newNote := func(notePath, permalink string) model.Note {
+		note := model.Note{Slug: notePath, Path: notePath, Metadata: map[string]any{}}
+		if permalink != "" {
+			note.Metadata["permalink"] = permalink
+		}
+		note.BuildSlug()
+		return note
+	}
+
+	t.Run("Last note in path order wins", func(t *testing.T) {
+		for _, order := range [][]int{{0, 1, 2}, {2, 1, 0}} {
+			all := []model.Note{newNote("b/Second.md", "/blog/post"), newNote("a/First.md", "blog/post/"), newNote("c/Other.md", "/blog/other")}
+			notes := []model.Note{all[order[0]], all[order[1]], all[order[2]]}
+			resolveDuplicatePermalinks(notes)
+
+			slugs := map[string]string{}
+			for _, note := range notes {
+				slugs[note.Path] = note.Slug
+			}
+			if slugs["b/Second.md"] != "blog/post" || slugs["a/First.md"] != "a/first" || slugs["c/Other.md"] != "blog/other" {
+				t.Errorf("resolveDuplicatePermalinks() gave slugs %v, want b/Second.md to keep blog/post", slugs)
+			}
+		}
+	})
+
+	t.Run("Permalink taking the URL of a note", func(t *testing.T) {
+		notes := []model.Note{newNote("Blog/Post.md", ""), newNote("Old.md", "/blog/post")}
+		resolveDuplicatePermalinks(notes)
+		if notes[0].Slug != "blog/post" || notes[1].Slug != "old" || notes[1].Permalink != "" {
+			t.Errorf("resolveDuplicatePermalinks() gave slugs %q and %q, want the permalink ignored", notes[0].Slug, notes[1].Slug)
+		}
+	})
+}
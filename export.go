@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/EwenQuim/pluie/config"
+	"github.com/EwenQuim/pluie/engine"
+	"github.com/EwenQuim/pluie/model"
+)
+
+// runExport writes the note cfg.ExportNote and the notes its wikilinks lead to, up to
+// cfg.ExportDepth links away, as a static site in cfg.Output: their pages, a sidebar with only
+// them and their attachments. Links to the other notes become plain text, like broken
+// wikilinks. Returns how many notes were exported.
+func runExport(cfg *config.Config) (int, error) {
+	if cfg.ExportNote == "" {
+		return 0, errors.New("missing -note: the slug of the note to export")
+	}
+
+	// With -include-private every note is loaded as public, like in preview mode
+	exportCfg := *cfg
+	exportCfg.ForcePublic = cfg.ExportIncludePrivate
+
+	notesMap, tree, tagIndex, err := loadNotes(cfg.Path, &exportCfg)
+	if err != nil {
+		return 0, fmt.Errorf("loading notes: %w", err)
+	}
+	notesService := engine.NewNotesService(notesMap, tree, tagIndex)
+
+	start, ok := notesService.GetNote(strings.Trim(cfg.ExportNote, "/"))
+	if !ok {
+		return 0, fmt.Errorf("note %q not found, or private without -include-private", cfg.ExportNote)
+	}
+
+	notes := engine.LinkedNotes(notesService.GetAllNotes(), start.Slug, cfg.ExportDepth)
+	exportCfg.HomeNoteSlug = start.Slug
+	if err := generateStaticSite(bundleNotesService(notes, tree), &exportCfg); err != nil {
+		return 0, err
+	}
+
+	slog.Info("Note exported", "note", start.Slug, "depth", cfg.ExportDepth, "notes", len(notes), "folder", cfg.Output)
+	return len(notes), nil
+}
+
+// bundleNotesService returns the notes service of an export: only the notes, in the folders
+// of tree holding them
+func bundleNotesService(notes []model.Note, tree *engine.TreeNode) *engine.NotesService {
+	notesMap := make(map[string]model.Note, len(notes))
+	for _, note := range notes {
+		notesMap[note.Slug] = note
+	}
+	return engine.NewNotesService(&notesMap, engine.SubsetTree(tree, notes), engine.BuildTagIndex(notes))
+}
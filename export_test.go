@@ -0,0 +1,81 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/EwenQuim/pluie/config"
+)
+
+func TestRunExport(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "Start.md", "---\npublish: true\n---\n# Start\n\nSee [[Second]] and [[Diary]].\n")
+	writeTestFile(t, dir, "Topics/Second.md", "---\npublish: true\n---\n# Second\n\nThen [[Third]].\n\n![[chart.png]]\n")
+	writeTestFile(t, dir, "Topics/chart.png", "png")
+	writeTestFile(t, dir, "Third.md", "---\npublish: true\n---\n# Third\n\nThe end.\n")
+	writeTestFile(t, dir, "Diary.md", "---\npublish: false\n---\n# Diary\n\nSecret.\n")
+	writeTestFile(t, dir, "Unrelated.md", "---\npublish: true\n---\n# Unrelated\n")
+
+	export := func(t *testing.T, depth int, includePrivate bool) string {
+		t.Helper()
+		output := filepath.Join(t.TempDir(), "bundle")
+		cfg := &config.Config{Path: dir, Output: output, SiteTitle: "Test", ExportNote: "start", ExportDepth: depth, ExportIncludePrivate: includePrivate}
+		if _, err := runExport(cfg); err != nil {
+			t.Fatalf("runExport() error: %v", err)
+		}
+		return output
+	}
+	exists := func(path string) bool {
+		_, err := os.Stat(path)
+		return err == nil
+	}
+
+	t.Run("follows the links up to the depth", func(t *testing.T) {
+		output := export(t, 1, false)
+		for _, page := range []string{"index.html", "start/index.html", "topics/second/index.html"} {
+			if !exists(filepath.Join(output, page)) {
+				t.Errorf("expected %s in the bundle", page)
+			}
+		}
+		for _, page := range []string{"third/index.html", "unrelated/index.html", "diary/index.html"} {
+			if exists(filepath.Join(output, page)) {
+				t.Errorf("%s should not be in the bundle", page)
+			}
+		}
+		if !exists(filepath.Join(output, "-/attachments/Topics/chart.png")) {
+			t.Error("expected the attachment of the exported note")
+		}
+
+		second, err := os.ReadFile(filepath.Join(output, "topics/second/index.html"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(second), `href="/third"`) || !strings.Contains(string(second), "Third") {
+			t.Error("links out of the bundle should become plain text")
+		}
+		if strings.Contains(string(second), "Unrelated") {
+			t.Error("the sidebar should only list the exported notes")
+		}
+	})
+
+	t.Run("deeper", func(t *testing.T) {
+		if output := export(t, 2, false); !exists(filepath.Join(output, "third/index.html")) {
+			t.Error("expected the note 2 links away")
+		}
+	})
+
+	t.Run("private notes with include-private only", func(t *testing.T) {
+		if output := export(t, 1, true); !exists(filepath.Join(output, "diary/index.html")) {
+			t.Error("expected the private note with include-private")
+		}
+	})
+
+	t.Run("unknown note", func(t *testing.T) {
+		cfg := &config.Config{Path: dir, Output: filepath.Join(t.TempDir(), "bundle"), ExportNote: "diary"}
+		if _, err := runExport(cfg); err == nil {
+			t.Error("expected an error for a private note without include-private")
+		}
+	})
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/EwenQuim/pluie/config"
+	"github.com/EwenQuim/pluie/engine"
+	"github.com/go-fuego/fuego/extra/markdown"
+)
+
+// ankiSeparatorNames are the names of the separators in the "#separator:" Anki file header
+var ankiSeparatorNames = map[rune]string{',': "Comma", ';': "Semicolon", '\t': "Tab", '|': "Pipe"}
+
+// runExportFlashcards writes the flashcards of the public notes to the cfg.Output CSV file
+func runExportFlashcards(notesService *engine.NotesService, cfg *config.Config) (int, error) {
+	cards := engine.FlashcardsFromNotes(notesService.GetAllNotes(), cfg.FlashcardsTag)
+
+	var buf bytes.Buffer
+	if err := writeFlashcardsCSV(&buf, cards, config.FlashcardsSeparators[cfg.FlashcardsSeparator]); err != nil {
+		return 0, err
+	}
+	if err := os.WriteFile(cfg.Output, buf.Bytes(), 0644); err != nil {
+		return 0, fmt.Errorf("writing flashcards file: %w", err)
+	}
+
+	slog.Info("Flashcards exported", "cards", len(cards), "tag", cfg.FlashcardsTag, "file", cfg.Output)
+	return len(cards), nil
+}
+
+// writeFlashcardsCSV writes cards as an Anki-importable CSV: front as plain text, back as HTML,
+// and the source note title as a tag. Header lines tell Anki how to read the file.
+func writeFlashcardsCSV(w io.Writer, cards []engine.Flashcard, separator rune) error {
+	if _, err := fmt.Fprintf(w, "#separator:%s\n#html:true\n#tags column:3\n", ankiSeparatorNames[separator]); err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(w)
+	writer.Comma = separator
+	for _, card := range cards {
+		if err := writer.Write([]string{card.Front, flashcardBackHTML(card.Back), ankiTag(card.NoteTitle)}); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// flashcardBackHTML renders the markdown answer of a card. Wikilinks become their text:
+// links to the vault mean nothing in Anki.
+func flashcardBackHTML(back string) string {
+	return strings.TrimSpace(string(markdown.Markdown(engine.WikiLinksToText(back))))
+}
+
+// ankiTag turns a note title into an Anki tag, which cannot contain spaces
+func ankiTag(title string) string {
+	return strings.Join(strings.Fields(title), "_")
+}
+
+// getFlashcardsExport serves the flashcards CSV, authenticated with "Authorization: Bearer <FLASHCARDS_TOKEN>"
+func (s *Server) getFlashcardsExport(w http.ResponseWriter, r *http.Request) {
+	if !validBearerToken(r.Header.Get("Authorization"), s.cfg.FlashcardsToken) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	cards := engine.FlashcardsFromNotes(s.NotesService.GetAllNotes(), s.cfg.FlashcardsTag)
+	var buf bytes.Buffer
+	if err := writeFlashcardsCSV(&buf, cards, config.FlashcardsSeparators[s.cfg.FlashcardsSeparator]); err != nil {
+		slog.Error("Failed to write flashcards", "error", err)
+		http.Error(w, "failed to export flashcards", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="flashcards.csv"`)
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		slog.Debug("Flashcards response write failed", "error", err)
+	}
+}
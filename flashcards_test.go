@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/EwenQuim/pluie/config"
+	"github.com/EwenQuim/pluie/engine"
+	"github.com/EwenQuim/pluie/template"
+	"github.com/go-fuego/fuego"
+)
+
+func TestWriteFlashcardsCSV(t *testing.T) {
+	cards := []engine.Flashcard{
+		{Front: `What is "Go"?`, Back: "A language, *fast*.\n\n- See [[Go tour|the tour]]", NoteTitle: "Cell biology"},
+		{Front: "One; two?", Back: "Three", NoteTitle: "Numbers"},
+	}
+
+	tests := []struct {
+		name      string
+		separator rune
+		header    string
+	}{
+		{name: "comma", separator: ',', header: "#separator:Comma\n#html:true\n#tags column:3\n"},
+		{name: "semicolon", separator: ';', header: "#separator:Semicolon\n#html:true\n#tags column:3\n"},
+		{name: "tab", separator: '\t', header: "#separator:Tab\n#html:true\n#tags column:3\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeFlashcardsCSV(&buf, cards, tt.separator); err != nil {
+				t.Fatalf("writeFlashcardsCSV() error: %v", err)
+			}
+			output := buf.String()
+			if !strings.HasPrefix(output, tt.header) {
+				t.Fatalf("expected the Anki headers, got %q", output)
+			}
+
+			// The rows read back to the same fields, whatever the quoting needed
+			reader := csv.NewReader(strings.NewReader(strings.TrimPrefix(output, tt.header)))
+			reader.Comma = tt.separator
+			records, err := reader.ReadAll()
+			if err != nil {
+				t.Fatalf("the CSV should be valid: %v", err)
+			}
+			if len(records) != 2 {
+				t.Fatalf("expected 2 rows, got %d", len(records))
+			}
+
+			first := records[0]
+			if first[0] != `What is "Go"?` || first[2] != "Cell_biology" {
+				t.Errorf("unexpected front or tag %q", first)
+			}
+			if !strings.Contains(first[1], "<em>fast</em>") || !strings.Contains(first[1], "<li>See the tour</li>") || strings.Contains(first[1], "[[") {
+				t.Errorf("the back should be HTML without wikilinks, got %q", first[1])
+			}
+			if records[1][0] != "One; two?" {
+				t.Errorf("separators in fields should be quoted, got %q", records[1])
+			}
+		})
+	}
+}
+
+func TestRunExportFlashcards(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "study.md", "---\npublish: true\ntags: [flashcards]\n---\n# Study\n\n## What is a cell?\n\nThe unit of life.\n\nQ: Capital of France?\nA: Paris")
+	writeTestFile(t, dir, "private.md", "---\npublish: false\ntags: [flashcards]\n---\nQ: Secret?\nA: Yes")
+	writeTestFile(t, dir, "other.md", "---\npublish: true\n---\nQ: Untagged?\nA: Yes")
+
+	output := filepath.Join(t.TempDir(), "deck.csv")
+	cfg := &config.Config{Path: dir, Output: output, FlashcardsTag: "flashcards", FlashcardsSeparator: "semicolon"}
+	notesMap, tree, tagIndex, err := loadNotes(dir, cfg)
+	if err != nil {
+		t.Fatalf("loadNotes() error: %v", err)
+	}
+
+	count, err := runExportFlashcards(engine.NewNotesService(notesMap, tree, tagIndex), cfg)
+	if err != nil {
+		t.Fatalf("runExportFlashcards() error: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("expected the 2 cards of the public tagged note, got %d", count)
+	}
+
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("the deck should be written: %v", err)
+	}
+	deck := string(data)
+	if !strings.HasPrefix(deck, "#separator:Semicolon") || !strings.Contains(deck, "What is a cell?;<p>The unit of life.</p>;Study") {
+		t.Errorf("unexpected deck:\n%s", deck)
+	}
+	if strings.Contains(deck, "Secret") || strings.Contains(deck, "Untagged") {
+		t.Error("private and untagged notes should not be exported")
+	}
+}
+
+func TestServer_FlashcardsExport(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "study.md", "---\npublish: true\n---\n#flashcards\n\nQ: Capital of France?\nA: Paris")
+
+	newServer := func(token string) *fuego.Server {
+		cfg := &config.Config{Path: dir, SiteTitle: "Pluie", FlashcardsTag: "flashcards", FlashcardsSeparator: "comma", FlashcardsToken: token}
+		notesMap, tree, tagIndex, err := loadNotes(dir, cfg)
+		if err != nil {
+			t.Fatalf("loadNotes() error: %v", err)
+		}
+		server := &Server{NotesService: engine.NewNotesService(notesMap, tree, tagIndex), rs: template.NewResource(cfg), cfg: cfg}
+		fuegoServer := fuego.NewServer()
+		server.registerRoutes(fuegoServer)
+		return fuegoServer
+	}
+	get := func(server *fuego.Server, authorization string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/-/export/flashcards.csv", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		w := httptest.NewRecorder()
+		server.Mux.ServeHTTP(w, req)
+		return w
+	}
+
+	server := newServer("secret")
+	if w := get(server, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without token, got %d", w.Code)
+	}
+	if w := get(server, "Bearer wrong"); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 with a wrong token, got %d", w.Code)
+	}
+
+	w := get(server, "Bearer secret")
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/csv") {
+		t.Fatalf("expected the CSV, got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(w.Body.String(), "Capital of France?,<p>Paris</p>,study") {
+		t.Errorf("unexpected CSV:\n%s", w.Body.String())
+	}
+
+	// Without a token, the URL is a note URL like any other
+	if w := get(newServer(""), "Bearer secret"); strings.HasPrefix(w.Header().Get("Content-Type"), "text/csv") {
+		t.Error("the export should not be available without FLASHCARDS_TOKEN")
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/EwenQuim/pluie/model"
+)
+
+// securityHeadersMiddleware forbids framing of every page. The embed route relaxes it, see allowEmbedding.
+func securityHeadersMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Frame-Options", "DENY")
+		w.Header().Set("Content-Security-Policy", "frame-ancestors 'none'")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		next.ServeHTTP(w, r)
+	})
+}
+
+// embedContentSecurityPolicy only allows the stylesheet and images: embeds load no script
+const embedContentSecurityPolicy = "default-src 'none'; style-src 'self'; img-src 'self' https: data:; font-src 'self'; base-uri 'none'; form-action 'none'"
+
+// allowEmbedding overrides the security headers so the response can be framed by the given origins
+func allowEmbedding(h http.Header, frameAncestors string) {
+	h.Del("X-Frame-Options")
+	h.Set("Content-Security-Policy", embedContentSecurityPolicy+"; frame-ancestors "+frameAncestors)
+}
+
+// noteETag identifies the page of a note: its content, and the last reload, which changes the
+// sidebar, the backreferences and the embedded notes of every page. The query is part of it,
+// for ?search= and ?print=1, and so is the view count shown with SHOW_VIEW_COUNT.
+func noteETag(note model.Note, loadedAt time.Time, rawQuery string) string {
+	content := sha256.Sum256([]byte(note.Content))
+	sum := sha256.Sum256([]byte(note.Slug + "\x00" + hex.EncodeToString(content[:]) + "\x00" + strconv.FormatInt(loadedAt.UnixNano(), 10) + "\x00" + rawQuery +
+		"\x00" + strconv.FormatInt(note.ViewCount, 10)))
+	return `"` + hex.EncodeToString(sum[:8]) + `"`
+}
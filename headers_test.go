@@ -0,0 +1,159 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/EwenQuim/pluie/config"
+	"github.com/EwenQuim/pluie/engine"
+	"github.com/EwenQuim/pluie/template"
+	"github.com/go-fuego/fuego"
+)
+
+func TestNoteEmbedRoute(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "course/lesson.md", "---\npublish: true\n---\n# Lesson\n\nIntro with a [[course/other|link]].\n\n## Setup\n\nInstall it.\n\n### Linux\n\nUse apt.\n\n## Usage\n\nRun it.\n")
+	writeTestFile(t, dir, "course/other.md", "---\npublish: true\n---\n# Other\n")
+	writeTestFile(t, dir, "private.md", "# Private\n\nSecret.\n")
+
+	cfg := &config.Config{SiteTitle: "My Garden", EmbedLinkTarget: "_top", EmbedFrameAncestors: "https://school.example"}
+	notesMap, tree, tagIndex, err := loadNotes(dir, cfg)
+	if err != nil {
+		t.Fatalf("loadNotes error: %v", err)
+	}
+	server := &Server{
+		NotesService: engine.NewNotesService(notesMap, tree, tagIndex),
+		rs:           template.NewResource(cfg),
+		cfg:          cfg,
+	}
+	fuegoServer := fuego.NewServer()
+	server.registerRoutes(fuegoServer)
+	handler := securityHeadersMiddleware(fuegoServer.Mux)
+
+	get := func(url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+		return w
+	}
+
+	t.Run("other pages cannot be framed", func(t *testing.T) {
+		w := get("/course/lesson")
+		if got := w.Header().Get("X-Frame-Options"); got != "DENY" {
+			t.Errorf("X-Frame-Options = %q, want DENY", got)
+		}
+		if got := w.Header().Get("Content-Security-Policy"); got != "frame-ancestors 'none'" {
+			t.Errorf("Content-Security-Policy = %q", got)
+		}
+	})
+
+	t.Run("embed can be framed by the configured origins", func(t *testing.T) {
+		w := get("/course/lesson/embed")
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200", w.Code)
+		}
+		if got := w.Header().Get("X-Frame-Options"); got != "" {
+			t.Errorf("X-Frame-Options = %q, want none", got)
+		}
+		csp := w.Header().Get("Content-Security-Policy")
+		for _, directive := range []string{"default-src 'none'", "frame-ancestors https://school.example"} {
+			if !strings.Contains(csp, directive) {
+				t.Errorf("Content-Security-Policy = %q, missing %q", csp, directive)
+			}
+		}
+
+		body := w.Body.String()
+		if strings.Contains(body, "<script") || strings.Contains(body, "sidebar") {
+			t.Error("embed should not load scripts nor the sidebar")
+		}
+		for _, expected := range []string{"Install it.", "Run it.", `target="_top"`, "via My Garden"} {
+			if !strings.Contains(body, expected) {
+				t.Errorf("expected %q in the embed", expected)
+			}
+		}
+	})
+
+	t.Run("heading filter", func(t *testing.T) {
+		body := get("/course/lesson/embed?heading=setup&target=_blank").Body.String()
+		for _, expected := range []string{"Install it.", "Use apt.", `target="_blank"`, `href="/course/lesson#setup"`} {
+			if !strings.Contains(body, expected) {
+				t.Errorf("expected %q in the section embed", expected)
+			}
+		}
+		for _, unexpected := range []string{"Intro with", "Run it."} {
+			if strings.Contains(body, unexpected) {
+				t.Errorf("unexpected %q outside of the section", unexpected)
+			}
+		}
+	})
+
+	t.Run("unknown heading", func(t *testing.T) {
+		if w := get("/course/lesson/embed?heading=missing"); w.Code != http.StatusNotFound {
+			t.Errorf("status = %d, want 404", w.Code)
+		}
+	})
+
+	t.Run("private notes are not embeddable", func(t *testing.T) {
+		w := get("/private/embed")
+		if w.Code != http.StatusNotFound {
+			t.Errorf("status = %d, want 404", w.Code)
+		}
+		if strings.Contains(w.Body.String(), "Secret.") {
+			t.Error("private content leaked in the embed")
+		}
+	})
+}
+
+func TestNotePageETag(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "garden.md", "---\npublish: true\n---\nTomatoes")
+
+	cfg := &config.Config{Path: dir}
+	notesMap, tree, tagIndex, err := loadNotes(dir, cfg)
+	if err != nil {
+		t.Fatalf("loadNotes error: %v", err)
+	}
+	server := &Server{NotesService: engine.NewNotesService(notesMap, tree, tagIndex), rs: template.NewResource(cfg), cfg: cfg}
+	fuegoServer := fuego.NewServer()
+	server.registerRoutes(fuegoServer)
+
+	get := func(url, ifNoneMatch string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, url, nil)
+		if ifNoneMatch != "" {
+			r.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		fuegoServer.Mux.ServeHTTP(w, r)
+		return w
+	}
+
+	first := get("/garden", "")
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" || first.Header().Get("Cache-Control") != "no-cache" {
+		t.Fatalf("expected a revalidated page with an ETag, got %d %v", first.Code, first.Header())
+	}
+	if w := get("/garden", etag); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("same ETag: expected an empty 304, got %d", w.Code)
+	}
+	if w := get("/garden", "W/"+etag); w.Code != http.StatusNotModified {
+		t.Errorf("weak comparison: expected a 304, got %d", w.Code)
+	}
+	if w := get("/garden?print=1", etag); w.Code != http.StatusOK {
+		t.Errorf("the print view is another page, got %d", w.Code)
+	}
+
+	// A reload changes the sidebar of every page
+	writeTestFile(t, dir, "other.md", "---\npublish: true\n---\nPotatoes")
+	if _, err := server.reloadNotes(dir, cfg, true); err != nil {
+		t.Fatalf("reloadNotes error: %v", err)
+	}
+	if w := get("/garden", etag); w.Code != http.StatusOK || w.Header().Get("ETag") == etag || !strings.Contains(w.Body.String(), `href="/other"`) {
+		t.Errorf("after a reload: expected the new page, got %d", w.Code)
+	}
+
+	// Pages that are not notes have no ETag
+	if w := get("/nowhere", ""); w.Header().Get("ETag") != "" {
+		t.Errorf("the not found page should have no ETag, got %q", w.Header().Get("ETag"))
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/EwenQuim/pluie/api"
+
+	"github.com/go-fuego/fuego"
+)
+
+// weaviatePingTTL is how long GET /-/readyz reuses the last Weaviate ping, so frequent probes
+// don't hit Weaviate every time
+const weaviatePingTTL = 30 * time.Second
+
+// cachedCheck runs a check at most once per ttl and returns the last result in between.
+// The zero value is ready to use.
+type cachedCheck struct {
+	mu        sync.Mutex
+	checkedAt time.Time
+	ok        bool
+	now       func() time.Time // Replaced in tests, time.Now when nil
+}
+
+// result returns the result of check, run again when the last one is older than ttl.
+// Concurrent callers wait for the running check rather than starting another one.
+func (c *cachedCheck) result(ttl time.Duration, check func() bool) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now
+	if c.now != nil {
+		now = c.now
+	}
+	if c.checkedAt.IsZero() || now().Sub(c.checkedAt) >= ttl {
+		c.ok = check()
+		c.checkedAt = now()
+	}
+	return c.ok
+}
+
+// getHealthz is the liveness probe, always ok once the server answers
+func (s *Server) getHealthz(c fuego.ContextNoBody) (api.Envelope[api.Health], error) {
+	return api.Wrap(api.Health{Status: "ok"}), nil
+}
+
+// getReadyz is the readiness probe: 503 until the notes are loaded, with the state of the
+// optional dependencies for debugging. It never calls the chat providers.
+func (s *Server) getReadyz(c fuego.ContextNoBody) (api.Envelope[api.Readiness], error) {
+	readiness := s.readiness()
+	if !readiness.Ready {
+		c.SetStatus(http.StatusServiceUnavailable)
+	}
+	return api.Wrap(readiness), nil
+}
+
+// readiness collects the state reported by GET /-/readyz
+func (s *Server) readiness() api.Readiness {
+	readiness := api.Readiness{
+		VaultPath:  s.cfg.Path,
+		Weaviate:   s.weaviateStatus(),
+		Chat:       s.chatStatus(),
+		Embeddings: s.embeddingsReadiness(),
+	}
+	if s.NotesService == nil || s.NotesService.LoadedAt().IsZero() {
+		return readiness
+	}
+	readiness.Ready = true
+	readiness.Notes = len(s.NotesService.GetNotesMap())
+	readiness.LastReload = s.NotesService.LoadedAt()
+	return readiness
+}
+
+// weaviateStatus pings Weaviate when it is the vector store, see weaviatePingTTL
+func (s *Server) weaviateStatus() api.Dependency {
+	if s.embeddingsManager == nil {
+		return api.Dependency{Status: "disabled", Detail: "no vector store"}
+	}
+	if _, ok := s.embeddingsManager.store.(*WeaviateStore); !ok {
+		return api.Dependency{Status: "disabled", Detail: "not the vector store"}
+	}
+	address := s.cfg.WeaviateScheme + "://" + s.cfg.WeaviateHost
+	if !s.weaviatePing.result(weaviatePingTTL, func() bool { return weaviateReady(s.cfg) }) {
+		return api.Dependency{Status: "unavailable", Detail: "no answer from " + address}
+	}
+	return api.Dependency{Status: "ok", Detail: address}
+}
+
+// chatStatus reports the chat providers in cooldown after failing, without calling any
+func (s *Server) chatStatus() api.Dependency {
+	if s.chatChain == nil {
+		return api.Dependency{Status: "disabled", Detail: "no chat provider"}
+	}
+	names, down := s.chatChain.Providers()
+	var failing []string
+	for i, name := range names {
+		if down[i] {
+			failing = append(failing, name)
+		}
+	}
+	switch {
+	case len(failing) == 0:
+		return api.Dependency{Status: "ok", Detail: strings.Join(names, ", ")}
+	case len(failing) == len(names):
+		return api.Dependency{Status: "unavailable", Detail: "every provider failed recently"}
+	}
+	return api.Dependency{Status: "degraded", Detail: fmt.Sprintf("failed recently: %s", strings.Join(failing, ", "))}
+}
+
+// embeddingsReadiness reports how far the embedding of the notes went
+func (s *Server) embeddingsReadiness() api.EmbeddingsReadiness {
+	em := s.embeddingsManager
+	if em == nil || em.store == nil || em.progress == nil {
+		return api.EmbeddingsReadiness{Status: "disabled"}
+	}
+	status := em.progress.GetStatus()
+	readiness := api.EmbeddingsReadiness{}
+	if status.TotalNotes > 0 {
+		readiness.Percent = float64(status.EmbeddedNotes+status.SkippedNotes) * 100 / float64(status.TotalNotes)
+	}
+	switch {
+	case !em.initialized.Load():
+		readiness.Status = "pending"
+	case status.IsPaused:
+		readiness.Status = "paused"
+	case status.IsEmbedding:
+		readiness.Status = "embedding"
+	default:
+		readiness.Status = "done"
+	}
+	return readiness
+}
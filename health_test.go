@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/EwenQuim/pluie/api"
+	"github.com/EwenQuim/pluie/config"
+	"github.com/EwenQuim/pluie/engine"
+	"github.com/EwenQuim/pluie/template"
+
+	"github.com/go-fuego/fuego"
+)
+
+func TestHealthProbes(t *testing.T) {
+	vaultDir := t.TempDir()
+	writeTestFile(t, vaultDir, "welcome.md", "# Welcome")
+	cfg := &config.Config{Path: vaultDir, PublicByDefault: true}
+	notesMap, tree, tagIndex, err := loadNotes(vaultDir, cfg)
+	if err != nil {
+		t.Fatalf("loadNotes() error: %v", err)
+	}
+
+	serve := func(s *Server, path string) (*httptest.ResponseRecorder, api.Readiness) {
+		t.Helper()
+		fuegoServer := fuego.NewServer()
+		s.registerRoutes(fuegoServer)
+		w := httptest.NewRecorder()
+		fuegoServer.Mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		var envelope api.Envelope[api.Readiness]
+		if path == "/-/readyz" {
+			if err := json.Unmarshal(w.Body.Bytes(), &envelope); err != nil {
+				t.Fatalf("invalid JSON %q: %v", w.Body.String(), err)
+			}
+		}
+		return w, envelope.Data
+	}
+
+	t.Run("healthz answers before the notes are loaded", func(t *testing.T) {
+		w, _ := serve(&Server{rs: template.NewResource(cfg), cfg: cfg}, "/-/healthz")
+		if w.Code != http.StatusOK {
+			t.Errorf("status = %d, want 200", w.Code)
+		}
+	})
+
+	t.Run("readyz is 503 until the notes are loaded", func(t *testing.T) {
+		w, readiness := serve(&Server{rs: template.NewResource(cfg), cfg: cfg}, "/-/readyz")
+		if w.Code != http.StatusServiceUnavailable || readiness.Ready {
+			t.Errorf("status = %d, ready = %v, want 503 and not ready", w.Code, readiness.Ready)
+		}
+	})
+
+	t.Run("readyz reports the notes and the dependencies", func(t *testing.T) {
+		s := &Server{NotesService: engine.NewNotesService(notesMap, tree, tagIndex), rs: template.NewResource(cfg), cfg: cfg}
+		w, readiness := serve(s, "/-/readyz")
+		if w.Code != http.StatusOK || !readiness.Ready {
+			t.Fatalf("status = %d, ready = %v, want 200 and ready", w.Code, readiness.Ready)
+		}
+		if readiness.VaultPath != vaultDir || readiness.Notes != 1 || !readiness.LastReload.Equal(s.NotesService.LoadedAt()) {
+			t.Errorf("readiness = %+v", readiness)
+		}
+		if readiness.Weaviate.Status != "disabled" || readiness.Chat.Status != "disabled" || readiness.Embeddings.Status != "disabled" {
+			t.Errorf("dependencies = %+v, %+v, %+v, want them disabled", readiness.Weaviate, readiness.Chat, readiness.Embeddings)
+		}
+	})
+}
+
+func TestReadiness_Dependencies(t *testing.T) {
+	pings := 0
+	weaviate := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pings++
+		if r.URL.Path != "/v1/.well-known/ready" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer weaviate.Close()
+	address, _ := url.Parse(weaviate.URL)
+
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	local := &fakeChatProvider{name: "ollama/llama3", err: errors.New("connection refused")}
+	hosted := &fakeChatProvider{name: "mistral/small", chunks: []string{"Hi"}}
+	chain := NewChatChain([]ChatProvider{local, hosted}, time.Minute)
+	chain.now = func() time.Time { return now }
+
+	progress := NewEmbeddingProgress()
+	progress.UpdateProgress(3, 3, 10, "garden", true)
+
+	s := &Server{
+		cfg:               &config.Config{WeaviateScheme: "http", WeaviateHost: address.Host},
+		chatChain:         chain,
+		embeddingsManager: &EmbeddingsManager{store: &WeaviateStore{}, progress: progress},
+	}
+	s.weaviatePing.now = func() time.Time { return now }
+
+	if got := s.readiness(); got.Weaviate.Status != "ok" || got.Chat.Status != "ok" || got.Chat.Detail != "ollama/llama3, mistral/small" {
+		t.Errorf("readiness = %+v, want Weaviate and chat ok", got)
+	}
+	if got := s.embeddingsReadiness(); got.Status != "pending" || got.Percent != 60 {
+		t.Errorf("embeddings = %+v, want pending at 60%%", got)
+	}
+	s.embeddingsManager.initialized.Store(true)
+	if got := s.embeddingsReadiness(); got.Status != "embedding" {
+		t.Errorf("embeddings = %+v, want embedding", got)
+	}
+
+	// A failed provider is reported without calling any
+	if _, _, err := generate(t.Context(), chain); err != nil {
+		t.Fatal(err)
+	}
+	calls := local.calls + hosted.calls
+	if got := s.chatStatus(); got.Status != "degraded" || got.Detail != "failed recently: ollama/llama3" {
+		t.Errorf("chat = %+v, want degraded", got)
+	}
+	if local.calls+hosted.calls != calls {
+		t.Error("the readiness must not call the chat providers")
+	}
+
+	// The ping is cached for weaviatePingTTL
+	weaviate.Close()
+	if got := s.weaviateStatus(); got.Status != "ok" || pings != 1 {
+		t.Errorf("weaviate = %+v after %d pings, want the cached ping", got, pings)
+	}
+	now = now.Add(weaviatePingTTL)
+	if got := s.weaviateStatus(); got.Status != "unavailable" {
+		t.Errorf("weaviate = %+v, want unavailable once the cache expired", got)
+	}
+}
@@ -0,0 +1,563 @@
+// Package htmltomd converts HTML documents, like Notion exports, into markdown.
+// It supports headings, paragraphs, emphasis, links, images, lists and to-do lists,
+// blockquotes, code blocks and tables. Scripts, styles and unknown markup are dropped.
+package htmltomd
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// LinkResolver rewrites the destination of links and images.
+// When wikilink is not empty, the link is written as [[wikilink|text]] instead of a markdown link.
+type LinkResolver func(href string, image bool) (dest string, wikilink string)
+
+// Options tunes the conversion
+type Options struct {
+	// ContentClass selects the first element with this class as the content, like "page-body" for Notion.
+	// The whole body is converted when empty or not found.
+	ContentClass string
+	// ResolveLink rewrites links and images. Destinations are kept as is when nil.
+	ResolveLink LinkResolver
+}
+
+// Document is a converted HTML document
+type Document struct {
+	Title    string // <title>, or the first h1 when missing
+	Markdown string
+}
+
+// Convert reads an HTML document and converts its content to markdown
+func Convert(r io.Reader, opts Options) (Document, error) {
+	root, err := html.Parse(r)
+	if err != nil {
+		return Document{}, fmt.Errorf("parsing HTML: %w", err)
+	}
+
+	content := findByAtom(root, atom.Body)
+	if opts.ContentClass != "" {
+		if node := findByClass(root, opts.ContentClass); node != nil {
+			content = node
+		}
+	}
+	if content == nil {
+		content = root
+	}
+
+	c := converter{opts: opts}
+	return Document{
+		Title:    documentTitle(root),
+		Markdown: strings.Join(c.blocks(content), "\n\n") + "\n",
+	}, nil
+}
+
+// Title reads the title of an HTML document without converting it
+func Title(r io.Reader) (string, error) {
+	root, err := html.Parse(r)
+	if err != nil {
+		return "", fmt.Errorf("parsing HTML: %w", err)
+	}
+	return documentTitle(root), nil
+}
+
+func documentTitle(root *html.Node) string {
+	for _, tag := range []atom.Atom{atom.Title, atom.H1} {
+		if node := findByAtom(root, tag); node != nil {
+			if title := collapseSpaces(strings.TrimSpace(textContent(node))); title != "" {
+				return title
+			}
+		}
+	}
+	return ""
+}
+
+type converter struct {
+	opts Options
+}
+
+// blockAtoms are the elements rendered as their own markdown blocks
+var blockAtoms = []atom.Atom{
+	atom.Address, atom.Article, atom.Aside, atom.Blockquote, atom.Details, atom.Dd, atom.Div, atom.Dl, atom.Dt,
+	atom.Fieldset, atom.Figcaption, atom.Figure, atom.Footer, atom.Form, atom.H1, atom.H2, atom.H3, atom.H4,
+	atom.H5, atom.H6, atom.Header, atom.Hr, atom.Li, atom.Main, atom.Nav, atom.Ol, atom.P, atom.Pre,
+	atom.Section, atom.Summary, atom.Table, atom.Ul,
+}
+
+// skippedAtoms are never rendered
+var skippedAtoms = []atom.Atom{atom.Head, atom.Script, atom.Style, atom.Noscript, atom.Template, atom.Iframe}
+
+func isBlock(n *html.Node) bool {
+	return n.Type == html.ElementNode && slices.Contains(blockAtoms, n.DataAtom) && !isCheckbox(n)
+}
+
+// blocks converts the children of a container into markdown blocks.
+// Consecutive inline children are gathered into a paragraph.
+func (c converter) blocks(n *html.Node) []string {
+	var blocks []string
+	var paragraph strings.Builder
+	flush := func() {
+		if text := cleanParagraph(paragraph.String()); text != "" {
+			blocks = append(blocks, text)
+		}
+		paragraph.Reset()
+	}
+
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if isBlock(child) {
+			flush()
+			if block := c.block(child); block != "" {
+				blocks = append(blocks, block)
+			}
+			continue
+		}
+		paragraph.WriteString(c.inline(child))
+	}
+	flush()
+
+	return blocks
+}
+
+// block converts a block element
+func (c converter) block(n *html.Node) string {
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		text := collapseSpaces(cleanParagraph(c.inlineChildren(n)))
+		if text == "" {
+			return ""
+		}
+		level := int(n.Data[1] - '0')
+		return strings.Repeat("#", level) + " " + text
+	case atom.P, atom.Dt, atom.Summary, atom.Figcaption:
+		text := cleanParagraph(c.inlineChildren(n))
+		if text != "" && n.DataAtom == atom.Dt {
+			return "**" + text + "**"
+		}
+		if text != "" && (n.DataAtom == atom.Summary || n.DataAtom == atom.Figcaption) {
+			return "*" + text + "*"
+		}
+		return text
+	case atom.Hr:
+		return "---"
+	case atom.Pre:
+		return c.codeBlock(n)
+	case atom.Ul, atom.Ol:
+		return c.list(n)
+	case atom.Blockquote:
+		return prefixLines(strings.Join(c.blocks(n), "\n\n"), "> ", ">")
+	case atom.Table:
+		return c.table(n)
+	default:
+		// Containers: div, section, figure, li outside of a list...
+		return strings.Join(c.blocks(n), "\n\n")
+	}
+}
+
+// inline converts a node inside a paragraph
+func (c converter) inline(n *html.Node) string {
+	switch n.Type {
+	case html.TextNode:
+		return escapeText(collapseSpaces(n.Data))
+	case html.ElementNode:
+	default:
+		return ""
+	}
+	if slices.Contains(skippedAtoms, n.DataAtom) {
+		return ""
+	}
+	if isCheckbox(n) {
+		if isChecked(n) {
+			return "[x] "
+		}
+		return "[ ] "
+	}
+
+	switch n.DataAtom {
+	case atom.Br:
+		return "  \n"
+	case atom.Strong, atom.B:
+		return wrap(c.inlineChildren(n), "**")
+	case atom.Em, atom.I:
+		return wrap(c.inlineChildren(n), "*")
+	case atom.Del, atom.S, atom.Strike:
+		return wrap(c.inlineChildren(n), "~~")
+	case atom.Code, atom.Kbd, atom.Samp:
+		return inlineCode(textContent(n))
+	case atom.A:
+		return c.link(n)
+	case atom.Img:
+		return c.image(n)
+	}
+
+	if isBlock(n) {
+		// Block inside a paragraph, like a div in a span: keep its text on the line
+		return " " + strings.Join(c.blocks(n), " ") + " "
+	}
+	return c.inlineChildren(n)
+}
+
+func (c converter) inlineChildren(n *html.Node) string {
+	var sb strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		sb.WriteString(c.inline(child))
+	}
+	return sb.String()
+}
+
+func (c converter) resolve(href string, image bool) (string, string) {
+	if c.opts.ResolveLink == nil {
+		return href, ""
+	}
+	return c.opts.ResolveLink(href, image)
+}
+
+// link converts <a>, to a wikilink when the resolver says it targets an imported page
+func (c converter) link(n *html.Node) string {
+	href, hasHref := attr(n, "href")
+	text := strings.TrimSpace(c.inlineChildren(n))
+	if !hasHref || href == "" {
+		return text
+	}
+
+	// A linked image, usually the image itself in Notion figures
+	if img := onlyChild(n, atom.Img); img != nil {
+		if src, _ := attr(img, "src"); src == href {
+			return c.image(img)
+		}
+	}
+
+	dest, wikilink := c.resolve(href, false)
+	plain := collapseSpaces(strings.TrimSpace(textContent(n)))
+	if wikilink != "" && !strings.ContainsAny(plain+wikilink, "|[]") {
+		if plain == "" || plain == wikilink {
+			return "[[" + wikilink + "]]"
+		}
+		return "[[" + wikilink + "|" + plain + "]]"
+	}
+
+	if text == "" {
+		text = escapeText(dest)
+	}
+	return "[" + text + "](" + formatDestination(dest) + ")"
+}
+
+func (c converter) image(n *html.Node) string {
+	src, _ := attr(n, "src")
+	if src == "" {
+		return ""
+	}
+	alt, _ := attr(n, "alt")
+	dest, _ := c.resolve(src, true)
+	return "![" + escapeText(collapseSpaces(alt)) + "](" + formatDestination(dest) + ")"
+}
+
+// codeBlock converts <pre>, with the language from a "language-*" class
+func (c converter) codeBlock(n *html.Node) string {
+	code := strings.TrimRight(textContent(n), "\n")
+
+	language := ""
+	for _, node := range []*html.Node{n, onlyChild(n, atom.Code)} {
+		if node == nil {
+			continue
+		}
+		for _, class := range classes(node) {
+			if lang, ok := strings.CutPrefix(class, "language-"); ok {
+				language = strings.ToLower(lang)
+			}
+		}
+	}
+
+	fence := strings.Repeat("`", max(3, longestRun(code, '`')+1))
+	return fence + language + "\n" + code + "\n" + fence
+}
+
+// list converts <ul> and <ol>, indenting the content of each item under its marker
+func (c converter) list(n *html.Node) string {
+	number := 1
+	if start, ok := attr(n, "start"); ok {
+		if parsed, err := strconv.Atoi(start); err == nil {
+			number = parsed
+		}
+	}
+
+	var items []string
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type != html.ElementNode || child.DataAtom != atom.Li {
+			continue
+		}
+
+		marker := "- "
+		if n.DataAtom == atom.Ol {
+			marker = strconv.Itoa(number) + ". "
+			number++
+		}
+
+		blocks := c.blocks(child)
+		separator := "\n"
+		paragraphs := 0
+		for _, block := range blocks {
+			if !isListBlock(block) {
+				paragraphs++
+			}
+		}
+		if paragraphs > 1 {
+			separator = "\n\n"
+		}
+
+		content := strings.Join(blocks, separator)
+		indent := strings.Repeat(" ", len(marker))
+		items = append(items, marker+strings.ReplaceAll(content, "\n", "\n"+indent))
+	}
+
+	// Blank lines of loose items must not keep the indentation spaces
+	return trimTrailingSpaces(strings.Join(items, "\n"))
+}
+
+// table converts <table> into a GFM table, the first row being the header
+func (c converter) table(n *html.Node) string {
+	var rows [][]string
+	columns := 0
+
+	var walk func(*html.Node)
+	walk = func(node *html.Node) {
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type != html.ElementNode {
+				continue
+			}
+			switch child.DataAtom {
+			case atom.Thead, atom.Tbody, atom.Tfoot:
+				walk(child)
+			case atom.Tr:
+				var row []string
+				for cell := child.FirstChild; cell != nil; cell = cell.NextSibling {
+					if cell.Type == html.ElementNode && (cell.DataAtom == atom.Td || cell.DataAtom == atom.Th) {
+						text := strings.Join(c.blocks(cell), " ")
+						text = strings.ReplaceAll(strings.ReplaceAll(text, "\n", " "), "|", `\|`)
+						row = append(row, text)
+					}
+				}
+				columns = max(columns, len(row))
+				rows = append(rows, row)
+			}
+		}
+	}
+	walk(n)
+
+	if len(rows) == 0 || columns == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	writeRow := func(row []string) {
+		sb.WriteString("|")
+		for i := range columns {
+			cell := ""
+			if i < len(row) {
+				cell = row[i]
+			}
+			sb.WriteString(" " + cell + " |")
+		}
+		sb.WriteString("\n")
+	}
+
+	writeRow(rows[0])
+	writeRow(slices.Repeat([]string{"---"}, columns))
+	for _, row := range rows[1:] {
+		writeRow(row)
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// isCheckbox detects <input type="checkbox"> and Notion's to-do <div class="checkbox">
+func isCheckbox(n *html.Node) bool {
+	if n.Type != html.ElementNode {
+		return false
+	}
+	if n.DataAtom == atom.Input {
+		inputType, _ := attr(n, "type")
+		return inputType == "checkbox"
+	}
+	return slices.Contains(classes(n), "checkbox")
+}
+
+func isChecked(n *html.Node) bool {
+	_, checked := attr(n, "checked")
+	return checked || slices.Contains(classes(n), "checkbox-on")
+}
+
+var (
+	spacesRegex       = regexp.MustCompile(`[ \t\n\r\f]+`)
+	doubleSpacesRegex = regexp.MustCompile(` {2,}([^ \n])`) // Hard line breaks are two spaces before \n
+	listBlockRegex    = regexp.MustCompile(`^(- |\d+\. )`)
+	blockStartRegex   = regexp.MustCompile(`(?m)^[ \t]*(#{1,6} |> |- |\+ |\d+\. )`)
+	textEscapeReplace = strings.NewReplacer(`\`, `\\`, "*", `\*`, "_", `\_`, "`", "\\`", "[", `\[`, "]", `\]`, "<", `\<`)
+)
+
+func collapseSpaces(s string) string {
+	return spacesRegex.ReplaceAllString(s, " ")
+}
+
+// escapeText escapes the markdown characters of a text node
+func escapeText(s string) string {
+	return textEscapeReplace.Replace(s)
+}
+
+// cleanParagraph trims a paragraph and escapes line starts that markdown would read as blocks,
+// like "1. " or "# " typed as text
+func cleanParagraph(s string) string {
+	s = strings.TrimSpace(s)
+	s = strings.ReplaceAll(s, "  \n ", "  \n")
+	s = strings.TrimSuffix(s, "  \n")
+	s = doubleSpacesRegex.ReplaceAllString(s, " $1")
+	return blockStartRegex.ReplaceAllStringFunc(s, func(match string) string {
+		trimmed := strings.TrimLeft(match, " \t")
+		leading := match[:len(match)-len(trimmed)]
+		if number, ok := strings.CutSuffix(trimmed, ". "); ok {
+			return leading + number + `\. `
+		}
+		return leading + `\` + trimmed
+	})
+}
+
+func isListBlock(block string) bool {
+	return listBlockRegex.MatchString(block)
+}
+
+// wrap surrounds text with an emphasis marker, keeping its surrounding spaces outside
+func wrap(text, marker string) string {
+	trimmed := strings.TrimSpace(text)
+	if trimmed == "" {
+		return text
+	}
+	leading := text[:strings.Index(text, trimmed)]
+	trailing := text[len(leading)+len(trimmed):]
+	return leading + marker + trimmed + marker + trailing
+}
+
+// inlineCode wraps code in enough backticks to contain its own backticks
+func inlineCode(code string) string {
+	code = collapseSpaces(code)
+	if strings.TrimSpace(code) == "" {
+		return code
+	}
+	fence := strings.Repeat("`", longestRun(code, '`')+1)
+	if strings.HasPrefix(code, "`") || strings.HasSuffix(code, "`") {
+		return fence + " " + code + " " + fence
+	}
+	return fence + code + fence
+}
+
+// formatDestination encloses destinations with spaces or parentheses in angle brackets
+func formatDestination(dest string) string {
+	if strings.ContainsAny(dest, " ()") {
+		return "<" + dest + ">"
+	}
+	return dest
+}
+
+func prefixLines(s, prefix, emptyPrefix string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if line == "" {
+			lines[i] = emptyPrefix
+		} else {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func trimTrailingSpaces(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			lines[i] = ""
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func longestRun(s string, r byte) int {
+	longest, current := 0, 0
+	for i := range len(s) {
+		if s[i] == r {
+			current++
+			longest = max(longest, current)
+		} else {
+			current = 0
+		}
+	}
+	return longest
+}
+
+func textContent(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var sb strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && child.DataAtom == atom.Br {
+			sb.WriteString("\n")
+			continue
+		}
+		sb.WriteString(textContent(child))
+	}
+	return sb.String()
+}
+
+func attr(n *html.Node, key string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+func classes(n *html.Node) []string {
+	class, _ := attr(n, "class")
+	return strings.Fields(class)
+}
+
+// onlyChild returns the single element child of n if it is of the given type, ignoring blank text
+func onlyChild(n *html.Node, tag atom.Atom) *html.Node {
+	var found *html.Node
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		switch {
+		case child.Type == html.TextNode && strings.TrimSpace(child.Data) == "":
+			continue
+		case child.Type == html.ElementNode && child.DataAtom == tag && found == nil:
+			found = child
+		default:
+			return nil
+		}
+	}
+	return found
+}
+
+func findByAtom(n *html.Node, tag atom.Atom) *html.Node {
+	return find(n, func(node *html.Node) bool { return node.DataAtom == tag })
+}
+
+func findByClass(n *html.Node, class string) *html.Node {
+	return find(n, func(node *html.Node) bool { return slices.Contains(classes(node), class) })
+}
+
+// find returns the first element, depth first, matching the predicate
+func find(n *html.Node, match func(*html.Node) bool) *html.Node {
+	if n.Type == html.ElementNode && match(n) {
+		return n
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if found := find(child, match); found != nil {
+			return found
+		}
+	}
+	return nil
+}
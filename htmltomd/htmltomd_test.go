@@ -0,0 +1,197 @@
+package htmltomd
+
+import (
+	"strings"
+	"testing"
+)
+
+func convert(t *testing.T, input string, opts Options) string {
+	t.Helper()
+	doc, err := Convert(strings.NewReader(input), opts)
+	if err != nil {
+		t.Fatalf("Convert() returned error: %v", err)
+	}
+	return strings.TrimSuffix(doc.Markdown, "\n")
+}
+
+type conversionTest struct {
+	name     string
+	html     string
+	expected string
+}
+
+func runConversionTests(t *testing.T, tests []conversionTest) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := convert(t, tt.html, Options{}); got != tt.expected {
+				t.Errorf("Convert(%q)\ngot:\n%s\nwant:\n%s", tt.html, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestConvert_Headings(t *testing.T) {
+	runConversionTests(t, []conversionTest{
+		{name: "all levels", html: "<h1>One</h1><h2>Two</h2><h3>Three</h3><h6>Six</h6>", expected: "# One\n\n## Two\n\n### Three\n\n###### Six"},
+		{name: "inline markup", html: "<h2>Hello <em>world</em></h2>", expected: "## Hello *world*"},
+		{name: "line breaks are flattened", html: "<h2>Hello<br>world</h2>", expected: "## Hello world"},
+		{name: "empty heading is dropped", html: "<h2> </h2><p>Text</p>", expected: "Text"},
+	})
+}
+
+func TestConvert_Paragraphs(t *testing.T) {
+	runConversionTests(t, []conversionTest{
+		{name: "whitespace is collapsed", html: "<p>  Hello\n   world  </p><p>Second</p>", expected: "Hello world\n\nSecond"},
+		{name: "emphasis", html: "<p><strong>bold</strong>, <b>b</b>, <em>em</em>, <i>i</i> and <del>gone</del></p>", expected: "**bold**, **b**, *em*, *i* and ~~gone~~"},
+		{name: "spaces stay outside of markers", html: "<p>a<strong> bold </strong>b</p>", expected: "a **bold** b"},
+		{name: "line break", html: "<p>first<br>second</p>", expected: "first  \nsecond"},
+		{name: "markdown characters are escaped", html: "<p>2 * 3 = 6, snake_case, [link]</p>", expected: `2 \* 3 = 6, snake\_case, \[link\]`},
+		{name: "block syntax at line start is escaped", html: "<p>1. not a list</p><p># not a heading</p>", expected: "1\\. not a list\n\n\\# not a heading"},
+		{name: "hashtags are kept", html: "<p>#project</p>", expected: "#project"},
+		{name: "inline code", html: "<p>run <code>go test ./...</code> and <code>a`b</code></p>", expected: "run `go test ./...` and ``a`b``"},
+		{name: "loose text in a div", html: "<div>Hello <span>there</span><p>Para</p>after</div>", expected: "Hello there\n\nPara\n\nafter"},
+		{name: "scripts and styles are dropped", html: "<p>Text</p><script>alert(1)</script><style>p{}</style>", expected: "Text"},
+		{name: "horizontal rule", html: "<p>a</p><hr><p>b</p>", expected: "a\n\n---\n\nb"},
+	})
+}
+
+func TestConvert_Lists(t *testing.T) {
+	runConversionTests(t, []conversionTest{
+		{name: "unordered", html: "<ul><li>One</li><li>Two</li></ul>", expected: "- One\n- Two"},
+		{name: "ordered with start", html: `<ol start="3"><li>Three</li><li>Four</li></ol>`, expected: "3. Three\n4. Four"},
+		{
+			name:     "nested lists are indented under their marker",
+			html:     "<ul><li>Parent<ul><li>Child<ol><li>Deep</li></ol></li></ul></li><li>Sibling</li></ul>",
+			expected: "- Parent\n  - Child\n    1. Deep\n- Sibling",
+		},
+		{
+			name:     "loose items with paragraphs",
+			html:     "<ol><li><p>First</p><p>More</p></li><li><p>Second</p></li></ol>",
+			expected: "1. First\n\n   More\n2. Second",
+		},
+		{
+			name:     "checkboxes",
+			html:     `<ul><li><input type="checkbox" checked> Done</li><li><input type="checkbox"> Todo</li></ul>`,
+			expected: "- [x] Done\n- [ ] Todo",
+		},
+		{
+			name:     "notion to-do list",
+			html:     `<ul class="to-do-list"><li><div class="checkbox checkbox-on"></div> <span class="to-do-children-checked">Milk</span></li><li><div class="checkbox checkbox-off"></div> <span>Eggs</span></li></ul>`,
+			expected: "- [x] Milk\n- [ ] Eggs",
+		},
+	})
+}
+
+func TestConvert_Links(t *testing.T) {
+	runConversionTests(t, []conversionTest{
+		{name: "link", html: `<p>See <a href="https://example.com">the site</a>.</p>`, expected: "See [the site](https://example.com)."},
+		{name: "link without text", html: `<p><a href="https://example.com"></a></p>`, expected: "[https://example.com](https://example.com)"},
+		{name: "destination with spaces", html: `<p><a href="My Page.html">page</a></p>`, expected: "[page](<My Page.html>)"},
+		{name: "anchor without href", html: `<p><a name="top">Top</a></p>`, expected: "Top"},
+		{name: "bold link text", html: `<p><a href="/x"><strong>x</strong></a></p>`, expected: "[**x**](/x)"},
+	})
+}
+
+func TestConvert_LinkResolver(t *testing.T) {
+	resolver := func(href string, image bool) (string, string) {
+		switch {
+		case image:
+			return "assets/" + href, ""
+		case href == "Other%20abc.html":
+			return "Other.md", "Other Page"
+		default:
+			return href, ""
+		}
+	}
+
+	tests := []conversionTest{
+		{name: "wikilink with its own text", html: `<p><a href="Other%20abc.html">Other Page</a></p>`, expected: "[[Other Page]]"},
+		{name: "wikilink with a display name", html: `<p><a href="Other%20abc.html">see this</a></p>`, expected: "[[Other Page|see this]]"},
+		{name: "wikilink text with brackets stays a link", html: `<p><a href="Other%20abc.html">[draft]</a></p>`, expected: `[\[draft\]](Other.md)`},
+		{name: "external link untouched", html: `<p><a href="https://example.com">x</a></p>`, expected: "[x](https://example.com)"},
+		{name: "image", html: `<p><img src="cat.png" alt="A cat"></p>`, expected: "![A cat](assets/cat.png)"},
+		{name: "linked image is the image", html: `<figure><a href="cat.png"><img src="cat.png"></a><figcaption>Caption</figcaption></figure>`, expected: "![](assets/cat.png)\n\n*Caption*"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := convert(t, tt.html, Options{ResolveLink: resolver}); got != tt.expected {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestConvert_CodeBlocks(t *testing.T) {
+	runConversionTests(t, []conversionTest{
+		{name: "plain", html: "<pre>line 1\n  indented *not emphasis*\n</pre>", expected: "```\nline 1\n  indented *not emphasis*\n```"},
+		{name: "language class", html: `<pre class="code"><code class="language-Go">fmt.Println("hi")</code></pre>`, expected: "```go\nfmt.Println(\"hi\")\n```"},
+		{name: "escaped html", html: "<pre><code>a &lt; b &amp;&amp; c</code></pre>", expected: "```\na < b && c\n```"},
+		{name: "backticks in the code", html: "<pre>```nested```</pre>", expected: "````\n```nested```\n````"},
+		{name: "code block in a list", html: "<ul><li>Run<pre>make\nmake test</pre></li></ul>", expected: "- Run\n\n  ```\n  make\n  make test\n  ```"},
+	})
+}
+
+func TestConvert_Blockquotes(t *testing.T) {
+	runConversionTests(t, []conversionTest{
+		{name: "simple", html: "<blockquote>Quote</blockquote>", expected: "> Quote"},
+		{name: "paragraphs", html: "<blockquote><p>One</p><p>Two</p></blockquote>", expected: "> One\n>\n> Two"},
+		{name: "nested", html: "<blockquote><p>Outer</p><blockquote>Inner</blockquote></blockquote>", expected: "> Outer\n>\n> > Inner"},
+	})
+}
+
+func TestConvert_Tables(t *testing.T) {
+	runConversionTests(t, []conversionTest{
+		{
+			name:     "header and body",
+			html:     "<table><thead><tr><th>Name</th><th>Age</th></tr></thead><tbody><tr><td>Ada</td><td>36</td></tr></tbody></table>",
+			expected: "| Name | Age |\n| --- | --- |\n| Ada | 36 |",
+		},
+		{
+			name:     "without thead, ragged rows and pipes",
+			html:     "<table><tr><td>a</td><td>b</td></tr><tr><td>x|y</td></tr></table>",
+			expected: "| a | b |\n| --- | --- |\n| x\\|y |  |",
+		},
+		{
+			name:     "cell content is kept on one line",
+			html:     "<table><tr><th>H</th></tr><tr><td><p>one</p><p><strong>two</strong></p></td></tr></table>",
+			expected: "| H |\n| --- |\n| one **two** |",
+		},
+		{name: "empty table", html: "<table></table><p>after</p>", expected: "after"},
+	})
+}
+
+func TestConvert_NotionPage(t *testing.T) {
+	page := `<html><head><title>Project plan</title><style>body{}</style></head><body>
+<article id="abc" class="page sans">
+<header><h1 class="page-title">Project plan</h1><table class="properties"><tr><th>Created</th><td>May 1</td></tr></table></header>
+<div class="page-body"><p>Intro</p><h2 id="x">Goals</h2><ul class="bulleted-list"><li>Ship</li></ul></div>
+</article></body></html>`
+
+	doc, err := Convert(strings.NewReader(page), Options{ContentClass: "page-body"})
+	if err != nil {
+		t.Fatalf("Convert() returned error: %v", err)
+	}
+	if doc.Title != "Project plan" {
+		t.Errorf("Title = %q, want %q", doc.Title, "Project plan")
+	}
+	if expected := "Intro\n\n## Goals\n\n- Ship\n"; doc.Markdown != expected {
+		t.Errorf("Markdown = %q, want %q", doc.Markdown, expected)
+	}
+}
+
+func TestTitle(t *testing.T) {
+	tests := []struct {
+		html     string
+		expected string
+	}{
+		{html: "<title> My   Page </title><h1>Other</h1>", expected: "My Page"},
+		{html: "<h1>From <em>heading</em></h1>", expected: "From heading"},
+		{html: "<p>nothing</p>", expected: ""},
+	}
+	for _, tt := range tests {
+		if got, err := Title(strings.NewReader(tt.html)); err != nil || got != tt.expected {
+			t.Errorf("Title(%q) = %q, %v, want %q", tt.html, got, err, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/EwenQuim/pluie/config"
+	"github.com/EwenQuim/pluie/htmltomd"
+)
+
+// ImportReport summarizes what an import did
+type ImportReport struct {
+	Converted []string // Paths of the written notes, relative to the output folder
+	Assets    int      // Copied images and attachments
+	Failed    []ImportFailure
+}
+
+// ImportFailure is a page or asset that could not be imported
+type ImportFailure struct {
+	Path   string // Path in the export
+	Reason string
+}
+
+// importedPage is an HTML page of the export and the note it becomes
+type importedPage struct {
+	path  string // In the export
+	title string
+	note  string // Output path, relative to the output folder
+}
+
+// notionIDRegex matches the page ID Notion appends to exported file and folder names
+var notionIDRegex = regexp.MustCompile(` [0-9a-f]{32}$`)
+
+// runImport converts the export in cfg.ImportInput into markdown notes in cfg.Output
+func runImport(cfg *config.Config) (ImportReport, error) {
+	if cfg.ImportInput == "" {
+		return ImportReport{}, errors.New("missing -input: the export folder or .zip file to import")
+	}
+
+	var fsys fs.FS
+	if strings.EqualFold(filepath.Ext(cfg.ImportInput), ".zip") {
+		archive, err := zip.OpenReader(cfg.ImportInput)
+		if err != nil {
+			return ImportReport{}, fmt.Errorf("opening export archive: %w", err)
+		}
+		defer archive.Close()
+		fsys = archive
+	} else {
+		if info, err := os.Stat(cfg.ImportInput); err != nil || !info.IsDir() {
+			return ImportReport{}, fmt.Errorf("export folder %q not found", cfg.ImportInput)
+		}
+		fsys = os.DirFS(cfg.ImportInput)
+	}
+
+	slog.Info("Importing export", "input", cfg.ImportInput, "format", cfg.ImportFrom, "output", cfg.Output)
+	report, err := importExport(fsys, cfg.Output, cfg.ImportFrom, cfg.Force)
+	if err != nil {
+		return report, err
+	}
+
+	for _, failure := range report.Failed {
+		slog.Warn("Failed to import", "path", failure.Path, "reason", failure.Reason)
+	}
+	slog.Info("Import completed", "notes", len(report.Converted), "assets", report.Assets, "failed", len(report.Failed))
+	return report, nil
+}
+
+// importExport converts every HTML page of fsys into a note in the output folder.
+// Links between pages become wikilinks, linked images and files are copied next to the notes.
+// Existing files are never overwritten unless force is set.
+func importExport(fsys fs.FS, output, format string, force bool) (ImportReport, error) {
+	var report ImportReport
+	notion := format == "notion-html"
+
+	// First pass: titles and note paths, so links can be resolved whatever the page order
+	var pages []*importedPage
+	pagesByPath := make(map[string]*importedPage)
+	usedNotes := make(map[string]bool)
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		ext := strings.ToLower(path.Ext(p))
+		if d.IsDir() || (ext != ".html" && ext != ".htm") {
+			return nil
+		}
+
+		content, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			report.Failed = append(report.Failed, ImportFailure{Path: p, Reason: err.Error()})
+			return nil
+		}
+		title, err := htmltomd.Title(bytes.NewReader(content))
+		if err != nil {
+			report.Failed = append(report.Failed, ImportFailure{Path: p, Reason: err.Error()})
+			return nil
+		}
+
+		note := uniqueNotePath(importedPath(strings.TrimSuffix(p, path.Ext(p)), notion)+".md", usedNotes)
+		if title == "" {
+			title = strings.TrimSuffix(path.Base(note), ".md")
+		}
+
+		page := &importedPage{path: p, title: title, note: note}
+		pages = append(pages, page)
+		pagesByPath[p] = page
+		return nil
+	})
+	if err != nil {
+		return report, fmt.Errorf("reading export: %w", err)
+	}
+
+	// Second pass: convert and write the notes
+	copiedAssets := make(map[string]string) // Export path -> output path
+	for _, page := range pages {
+		resolve := func(href string, image bool) (string, string) {
+			target, ok := resolveExportLink(page.path, href)
+			if !ok {
+				return href, ""
+			}
+
+			if linked, ok := pagesByPath[target]; ok {
+				return relativeURL(page.note, linked.note), linked.title
+			}
+
+			asset, copied := copiedAssets[target]
+			if !copied {
+				info, err := fs.Stat(fsys, target)
+				if err != nil || info.IsDir() {
+					return href, ""
+				}
+				asset = importedPath(path.Dir(target), notion) + "/" + path.Base(target)
+				asset = strings.TrimPrefix(asset, "./")
+				if err := copyExportFile(fsys, target, filepath.Join(output, filepath.FromSlash(asset)), force); err != nil {
+					report.Failed = append(report.Failed, ImportFailure{Path: target, Reason: err.Error()})
+					return href, ""
+				}
+				copiedAssets[target] = asset
+				report.Assets++
+			}
+			return relativeURL(page.note, asset), ""
+		}
+
+		content, err := fs.ReadFile(fsys, page.path)
+		if err != nil {
+			report.Failed = append(report.Failed, ImportFailure{Path: page.path, Reason: err.Error()})
+			continue
+		}
+
+		opts := htmltomd.Options{ResolveLink: resolve}
+		if notion {
+			opts.ContentClass = "page-body"
+		}
+		doc, err := htmltomd.Convert(bytes.NewReader(content), opts)
+		if err != nil {
+			report.Failed = append(report.Failed, ImportFailure{Path: page.path, Reason: err.Error()})
+			continue
+		}
+
+		markdown := fmt.Sprintf("---\ntitle: %s\nimported_from: %s\n---\n\n%s", strconv.Quote(page.title), format, doc.Markdown)
+		if err := writeNewFile(filepath.Join(output, filepath.FromSlash(page.note)), []byte(markdown), force); err != nil {
+			report.Failed = append(report.Failed, ImportFailure{Path: page.path, Reason: err.Error()})
+			continue
+		}
+		report.Converted = append(report.Converted, page.note)
+	}
+
+	return report, nil
+}
+
+// importedPath removes the Notion page IDs from every segment of an export path
+func importedPath(p string, notion bool) string {
+	if !notion {
+		return p
+	}
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		segments[i] = notionIDRegex.ReplaceAllString(segment, "")
+	}
+	return strings.Join(segments, "/")
+}
+
+// uniqueNotePath appends a number to the note name when another page already uses it
+func uniqueNotePath(note string, used map[string]bool) string {
+	candidate := note
+	for i := 2; used[strings.ToLower(candidate)]; i++ {
+		candidate = fmt.Sprintf("%s %d.md", strings.TrimSuffix(note, ".md"), i)
+	}
+	used[strings.ToLower(candidate)] = true
+	return candidate
+}
+
+// resolveExportLink returns the export path a relative link of a page points to
+func resolveExportLink(pagePath, href string) (string, bool) {
+	u, err := url.Parse(href)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" || strings.HasPrefix(u.Path, "/") {
+		return "", false
+	}
+	target := path.Clean(path.Join(path.Dir(pagePath), u.Path))
+	if target == ".." || strings.HasPrefix(target, "../") {
+		return "", false
+	}
+	return target, true
+}
+
+// relativeURL returns the link from a note to another output file, with escaped path segments
+func relativeURL(fromNote, to string) string {
+	rel, err := filepath.Rel(filepath.FromSlash(path.Dir(fromNote)), filepath.FromSlash(to))
+	if err != nil {
+		rel = to
+	}
+	segments := strings.Split(filepath.ToSlash(rel), "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// copyExportFile copies a file of the export to the output folder
+func copyExportFile(fsys fs.FS, source, destination string, force bool) error {
+	content, err := fs.ReadFile(fsys, source)
+	if err != nil {
+		return err
+	}
+	return writeNewFile(destination, content, force)
+}
+
+// writeNewFile writes a file, failing when it already exists unless force is set
+func writeNewFile(name string, content []byte, force bool) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if !force {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	}
+	file, err := os.OpenFile(name, flags, 0644)
+	if errors.Is(err, fs.ErrExist) {
+		return fmt.Errorf("%s already exists, use -force to overwrite it", name)
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(content); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
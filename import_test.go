@@ -0,0 +1,180 @@
+package main
+
+import (
+	"archive/zip"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/EwenQuim/pluie/config"
+)
+
+const notionFixture = "testdata/notion-export"
+
+func readImported(t *testing.T, output, name string) string {
+	t.Helper()
+	content, err := os.ReadFile(filepath.Join(output, name))
+	if err != nil {
+		t.Fatalf("reading %s: %v", name, err)
+	}
+	return string(content)
+}
+
+func TestImportExport_Notion(t *testing.T) {
+	output := t.TempDir()
+
+	report, err := importExport(os.DirFS(notionFixture), output, "notion-html", false)
+	if err != nil {
+		t.Fatalf("importExport error: %v", err)
+	}
+	if len(report.Failed) != 0 {
+		t.Fatalf("unexpected failures: %+v", report.Failed)
+	}
+	converted := slices.Sorted(slices.Values(report.Converted))
+	if got := strings.Join(converted, ","); got != "Home.md,Projects.md,Projects/Roadmap.md" {
+		t.Errorf("converted = %q", got)
+	}
+	if report.Assets != 1 {
+		t.Errorf("assets = %d, want 1", report.Assets)
+	}
+
+	home := readImported(t, output, "Home.md")
+	for _, expected := range []string{
+		"---\ntitle: \"Home\"\nimported_from: notion-html\n---\n\n",
+		"See [[Projects]] and the [[Roadmap|2024 roadmap]].",
+		"![](Home/diagram.png)",
+		"*Architecture*",
+		"[external link](https://www.notion.so)",
+		// Pages missing from the export keep their link
+		"[deleted page](Deleted%20page%2033333333333333333333333333333333.html)",
+	} {
+		if !strings.Contains(home, expected) {
+			t.Errorf("Home.md should contain %q, got:\n%s", expected, home)
+		}
+	}
+	if strings.Contains(home, "May 1, 2021") {
+		t.Error("the Notion properties header should not be imported")
+	}
+
+	projects := readImported(t, output, "Projects.md")
+	for _, expected := range []string{"- [x] Ship v1\n- [ ] Write docs", "| Name | Status |", "| [[Roadmap]] | Draft |"} {
+		if !strings.Contains(projects, expected) {
+			t.Errorf("Projects.md should contain %q, got:\n%s", expected, projects)
+		}
+	}
+
+	roadmap := readImported(t, output, "Projects/Roadmap.md")
+	for _, expected := range []string{"## Q1", "```shell\nmake release\n```", "Back to [[Home|the home page]]."} {
+		if !strings.Contains(roadmap, expected) {
+			t.Errorf("Roadmap.md should contain %q, got:\n%s", expected, roadmap)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(output, "Home", "diagram.png")); err != nil {
+		t.Errorf("image should be copied: %v", err)
+	}
+}
+
+func TestImportExport_NeverOverwrites(t *testing.T) {
+	output := t.TempDir()
+	existing := filepath.Join(output, "Home.md")
+	if err := os.WriteFile(existing, []byte("my own note"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := importExport(os.DirFS(notionFixture), output, "notion-html", false)
+	if err != nil {
+		t.Fatalf("importExport error: %v", err)
+	}
+	if len(report.Failed) != 1 || !strings.Contains(report.Failed[0].Reason, "-force") {
+		t.Fatalf("expected Home.md to fail without -force, got %+v", report.Failed)
+	}
+	if content := readImported(t, output, "Home.md"); content != "my own note" {
+		t.Error("existing note was overwritten")
+	}
+
+	// A second import fails for every file, -force overwrites them
+	report, _ = importExport(os.DirFS(notionFixture), output, "notion-html", false)
+	if len(report.Converted) != 0 {
+		t.Errorf("expected nothing converted on the second run, got %v", report.Converted)
+	}
+	report, _ = importExport(os.DirFS(notionFixture), output, "notion-html", true)
+	if len(report.Failed) != 0 || len(report.Converted) != 3 {
+		t.Errorf("expected -force to overwrite everything, got %+v", report)
+	}
+	if content := readImported(t, output, "Home.md"); !strings.Contains(content, "imported_from: notion-html") {
+		t.Error("Home.md should be overwritten with -force")
+	}
+}
+
+func TestRunImport_Zip(t *testing.T) {
+	// Notion exports come as .zip files
+	archivePath := filepath.Join(t.TempDir(), "export.zip")
+	archive, err := os.Create(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer := zip.NewWriter(archive)
+	err = fs.WalkDir(os.DirFS(notionFixture), ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		content, err := os.ReadFile(filepath.Join(notionFixture, p))
+		if err != nil {
+			return err
+		}
+		w, err := writer.Create(p)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(content)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	output := t.TempDir()
+	report, err := runImport(&config.Config{ImportInput: archivePath, ImportFrom: "notion-html", Output: output})
+	if err != nil {
+		t.Fatalf("runImport error: %v", err)
+	}
+	if len(report.Converted) != 3 || len(report.Failed) != 0 {
+		t.Errorf("unexpected report %+v", report)
+	}
+
+	if _, err := runImport(&config.Config{ImportInput: filepath.Join(t.TempDir(), "missing"), Output: output}); err == nil {
+		t.Error("expected an error for a missing export")
+	}
+}
+
+func TestImportedPath(t *testing.T) {
+	tests := []struct {
+		path     string
+		notion   bool
+		expected string
+	}{
+		{path: "Projects 11111111111111111111111111111111/Roadmap 22222222222222222222222222222222", notion: true, expected: "Projects/Roadmap"},
+		{path: "Notes 2024/Page abc", notion: true, expected: "Notes 2024/Page abc"},
+		{path: "Projects 11111111111111111111111111111111/page", notion: false, expected: "Projects 11111111111111111111111111111111/page"},
+	}
+	for _, tt := range tests {
+		if got := importedPath(tt.path, tt.notion); got != tt.expected {
+			t.Errorf("importedPath(%q) = %q, want %q", tt.path, got, tt.expected)
+		}
+	}
+
+	used := map[string]bool{}
+	if a, b := uniqueNotePath("Untitled.md", used), uniqueNotePath("untitled.md", used); a != "Untitled.md" || b != "untitled 2.md" {
+		t.Errorf("uniqueNotePath() = %q, %q", a, b)
+	}
+}
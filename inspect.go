@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/EwenQuim/pluie/config"
+	"github.com/EwenQuim/pluie/engine"
+	"github.com/EwenQuim/pluie/model"
+)
+
+// inspectedNote is the record of a note written by the inspect mode
+type inspectedNote struct {
+	Slug          string   `json:"slug"`
+	Title         string   `json:"title"`
+	Folder        string   `json:"folder"` // Folder of the note in the vault, "" at the root
+	Tags          []string `json:"tags"`
+	Words         int      `json:"words"`          // See engine.ReadingStats
+	OutgoingLinks int      `json:"outgoing_links"` // Notes linked, see engine.NoteLinkCounts
+	BrokenLinks   int      `json:"broken_links"`   // Wikilinks matching no note
+	Backlinks     int      `json:"backlinks"`      // Notes linking to this one, like the page shows them
+	Public        bool     `json:"public"`         // Whether the site serves the note
+	Date          string   `json:"date"`           // "date" frontmatter, RFC 3339, "" when none
+	Modified      string   `json:"modified"`       // Modification date, like the JSON API gives it
+}
+
+// inspectCSVHeader are the columns of the CSV format, the keys of the JSON one
+var inspectCSVHeader = []string{"slug", "title", "folder", "tags", "words", "outgoing_links", "broken_links", "backlinks", "public", "date", "modified"}
+
+// runInspect writes a record of every note of the site to the cfg.Output file, "-" for the
+// standard output, in cfg.InspectFormat. With -include-private the private notes are loaded
+// too, like in preview mode, so their links count. Returns the number of notes written.
+func runInspect(cfg *config.Config) (int, error) {
+	notesMap, tree, tagIndex, err := loadNotes(cfg.Path, cfg)
+	if err != nil {
+		return 0, fmt.Errorf("loading notes: %w", err)
+	}
+	notes := engine.NewNotesService(notesMap, tree, tagIndex).GetAllNotes()
+	served := make(map[string]bool, len(notes))
+	for _, note := range notes {
+		served[note.Slug] = true
+	}
+
+	if cfg.ExportIncludePrivate {
+		inspectCfg := *cfg
+		inspectCfg.ForcePublic = true
+		notesMap, tree, tagIndex, err := loadNotes(cfg.Path, &inspectCfg)
+		if err != nil {
+			return 0, fmt.Errorf("loading private notes: %w", err)
+		}
+		notes = engine.NewNotesService(notesMap, tree, tagIndex).GetAllNotes()
+	}
+
+	records := inspectNotes(notes, served, cfg.Location())
+	if cfg.OrphansOnly {
+		records = slices.DeleteFunc(records, func(record inspectedNote) bool {
+			return record.Backlinks > 0 || record.OutgoingLinks > 0
+		})
+	}
+
+	var buf bytes.Buffer
+	if cfg.InspectFormat == "csv" {
+		err = writeInspectCSV(&buf, records)
+	} else {
+		err = writeInspectJSON(&buf, records)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if cfg.Output == "-" {
+		_, err = os.Stdout.Write(buf.Bytes())
+	} else {
+		err = os.WriteFile(cfg.Output, buf.Bytes(), 0644)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("writing notes file: %w", err)
+	}
+
+	slog.Info("Notes inspected", "notes", len(records), "format", cfg.InspectFormat, "orphans_only", cfg.OrphansOnly, "file", cfg.Output)
+	return len(records), nil
+}
+
+// inspectNotes returns the records of the notes, sorted by slug. The notes of served are public.
+func inspectNotes(notes []model.Note, served map[string]bool, loc *time.Location) []inspectedNote {
+	counts := engine.NoteLinkCounts(notes)
+
+	records := make([]inspectedNote, 0, len(notes))
+	for _, note := range notes {
+		folder := path.Dir(strings.Trim(note.Path, "/"))
+		if folder == "." {
+			folder = ""
+		}
+		words, _ := engine.ReadingStats(note.Content, 0)
+		record := inspectedNote{
+			Slug:          note.Slug,
+			Title:         note.Title,
+			Folder:        folder,
+			Tags:          engine.NoteTags(note),
+			Words:         words,
+			OutgoingLinks: counts[note.Slug].Outgoing,
+			BrokenLinks:   counts[note.Slug].Broken,
+			Backlinks:     len(note.ReferencedBy),
+			Public:        served[note.Slug],
+			Modified:      engine.FormatRFC3339(apiModified(note, loc), loc),
+		}
+		if date, _, ok := engine.ParseDate(note.Metadata["date"], loc); ok {
+			record.Date = engine.FormatRFC3339(date, loc)
+		}
+		records = append(records, record)
+	}
+
+	slices.SortFunc(records, func(a, b inspectedNote) int {
+		return strings.Compare(a.Slug, b.Slug)
+	})
+	return records
+}
+
+// writeInspectJSON writes the records as an indented JSON array
+func writeInspectJSON(w io.Writer, records []inspectedNote) error {
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling notes: %w", err)
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// writeInspectCSV writes the records as CSV with a header line, the tags separated by ";"
+func writeInspectCSV(w io.Writer, records []inspectedNote) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(inspectCSVHeader); err != nil {
+		return err
+	}
+	for _, record := range records {
+		if err := writer.Write([]string{
+			record.Slug,
+			record.Title,
+			record.Folder,
+			strings.Join(record.Tags, ";"),
+			strconv.Itoa(record.Words),
+			strconv.Itoa(record.OutgoingLinks),
+			strconv.Itoa(record.BrokenLinks),
+			strconv.Itoa(record.Backlinks),
+			strconv.FormatBool(record.Public),
+			record.Date,
+			record.Modified,
+		}); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
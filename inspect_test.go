@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/EwenQuim/pluie/config"
+)
+
+func TestRunInspect(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "Index.md", "---\npublish: true\ntags: [home]\ndate: 2024-03-01\n---\n# Index\n\nSee the [[Recipe]], [[Recipe|again]] and [[Missing]].")
+	writeTestFile(t, dir, "Kitchen/Recipe.md", "---\npublish: true\n---\nBack [[Index|home]].")
+	writeTestFile(t, dir, "Lonely.md", "---\npublish: true\ntitle: \"Salt, \\\"pepper\\\"\\nand more\"\n---\nNo links here.")
+	writeTestFile(t, dir, "Secret.md", "---\npublish: false\n---\nSee [[Lonely]].")
+
+	inspect := func(format string, includePrivate, orphansOnly bool) []byte {
+		t.Helper()
+		output := filepath.Join(t.TempDir(), "notes."+format)
+		cfg := &config.Config{Path: dir, Output: output, InspectFormat: format, ExportIncludePrivate: includePrivate, OrphansOnly: orphansOnly}
+		if _, err := runInspect(cfg); err != nil {
+			t.Fatalf("runInspect() error: %v", err)
+		}
+		data, err := os.ReadFile(output)
+		if err != nil {
+			t.Fatalf("the notes file should be written: %v", err)
+		}
+		return data
+	}
+	decode := func(data []byte) []inspectedNote {
+		t.Helper()
+		var records []inspectedNote
+		if err := json.Unmarshal(data, &records); err != nil {
+			t.Fatalf("invalid JSON: %v\n%s", err, data)
+		}
+		return records
+	}
+
+	t.Run("json", func(t *testing.T) {
+		records := decode(inspect("json", false, false))
+		if len(records) != 3 {
+			t.Fatalf("expected the 3 public notes, got %+v", records)
+		}
+		index := records[0]
+		if index.Slug != "index" || !reflect.DeepEqual(index.Tags, []string{"home"}) || index.OutgoingLinks != 1 || index.BrokenLinks != 1 || index.Backlinks != 1 || !index.Public {
+			t.Errorf("unexpected record of the index: %+v", index)
+		}
+		if index.Words != 6 || index.Date != "2024-03-01T00:00:00+00:00" || index.Modified != index.Date {
+			t.Errorf("unexpected words and dates of the index: %+v", index)
+		}
+		if recipe := records[1]; recipe.Slug != "kitchen/recipe" || recipe.Folder != "Kitchen" || recipe.OutgoingLinks != 1 || recipe.Backlinks != 1 || recipe.Date != "" || recipe.Modified == "" {
+			t.Errorf("unexpected record of the recipe: %+v", recipe)
+		}
+		if lonely := records[2]; lonely.Folder != "" || lonely.Backlinks != 0 || lonely.Tags == nil {
+			t.Errorf("the private note link should not count: %+v", lonely)
+		}
+	})
+
+	t.Run("csv", func(t *testing.T) {
+		rows, err := csv.NewReader(bytes.NewReader(inspect("csv", false, false))).ReadAll()
+		if err != nil {
+			t.Fatalf("invalid CSV: %v", err)
+		}
+		if len(rows) != 4 || !reflect.DeepEqual(rows[0], inspectCSVHeader) {
+			t.Fatalf("expected the header and 3 rows, got %q", rows)
+		}
+		if rows[3][1] != "Salt, \"pepper\"\nand more" {
+			t.Errorf("expected the title with a comma, quotes and a newline back, got %q", rows[3][1])
+		}
+		if rows[1][3] != "home" || rows[1][5] != "1" || rows[1][6] != "1" || rows[1][8] != "true" {
+			t.Errorf("unexpected row of the index: %q", rows[1])
+		}
+	})
+
+	t.Run("include private", func(t *testing.T) {
+		records := decode(inspect("json", true, false))
+		if len(records) != 4 {
+			t.Fatalf("expected the 4 notes, got %+v", records)
+		}
+		if secret := records[3]; secret.Slug != "secret" || secret.Public || secret.OutgoingLinks != 1 {
+			t.Errorf("unexpected record of the private note: %+v", secret)
+		}
+		if lonely := records[2]; !lonely.Public || lonely.Backlinks != 1 {
+			t.Errorf("the private note link should count: %+v", lonely)
+		}
+	})
+
+	t.Run("orphans only", func(t *testing.T) {
+		records := decode(inspect("json", false, true))
+		if len(records) != 1 || records[0].Slug != "lonely" {
+			t.Errorf("expected the lonely note only, got %+v", records)
+		}
+		if records := decode(inspect("json", true, true)); len(records) != 0 {
+			t.Errorf("expected no orphan with the private notes, got %+v", records)
+		}
+	})
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/EwenQuim/pluie/metrics"
+)
+
+// Application metrics, exposed on /-/metrics
+var (
+	httpRequestsTotal = metrics.Default.NewCounter("pluie_http_requests_total",
+		"HTTP requests by route pattern and status code.", "route", "code")
+	httpRequestDuration = metrics.Default.NewHistogram("pluie_http_request_duration_seconds",
+		"HTTP request duration by route pattern.", nil, "route")
+	sseConnections = metrics.Default.NewGauge("pluie_sse_connections",
+		"Open Server-Sent Events connections by stream.", "stream")
+
+	notesLoaded = metrics.Default.NewGauge("pluie_notes_loaded",
+		"Public notes currently loaded.")
+	notesReloadDuration = metrics.Default.NewHistogram("pluie_notes_reload_duration_seconds",
+		"Time to load and index the vault.", nil)
+	notesLastReloadDuration = metrics.Default.NewGauge("pluie_notes_last_reload_duration_seconds",
+		"Time the last load of the vault took.")
+	notesLastReloadTimestamp = metrics.Default.NewGauge("pluie_notes_last_reload_timestamp_seconds",
+		"Unix time of the last load of the vault.")
+
+	embeddingNotesTotal = metrics.Default.NewGauge("pluie_embedding_notes_total",
+		"Notes to embed in the current embedding run.")
+	embeddingNotesEmbedded = metrics.Default.NewGauge("pluie_embedding_notes_embedded",
+		"Notes embedded by the current embedding run.")
+	embeddingNotesSkipped = metrics.Default.NewGauge("pluie_embedding_notes_skipped",
+		"Notes skipped by the current embedding run, already embedded with their content.")
+	embeddingInProgress = metrics.Default.NewGauge("pluie_embedding_in_progress",
+		"1 while notes are being embedded.")
+
+	vectorSearchDuration = metrics.Default.NewHistogram("pluie_vector_search_duration_seconds",
+		"Vector store similarity search latency.", nil)
+
+	llmGenerationDuration = metrics.Default.NewHistogram("pluie_llm_generation_duration_seconds",
+		"Chat model generation duration.", []float64{0.5, 1, 2.5, 5, 10, 20, 30, 60, 120}, "status")
+	llmTokensTotal = metrics.Default.NewCounter("pluie_llm_tokens_total",
+		"Streamed chat model tokens.")
+	llmFailoversTotal = metrics.Default.NewCounter("pluie_llm_failovers_total",
+		"Chat requests moved to the next provider, by failing provider.", "provider")
+)
+
+// routeGroup maps a request path to a coarse route group, like static for the assets
+func routeGroup(path string) string {
+	switch {
+	case strings.HasPrefix(path, "/static/"):
+		return "static"
+	case path == "/-/search-stream" || path == "/-/embedding-progress":
+		return "sse"
+	case path == "/-/search":
+		return "search"
+	case strings.HasPrefix(path, "/-/tag/") || path == "/-/tags":
+		return "tag"
+	case strings.HasPrefix(path, "/-/") || path == "/sitemap.xml":
+		return "internal"
+	default:
+		return "note"
+	}
+}
+
+// unmatchedRoute labels the requests no route pattern matched, like rejected credentials and
+// unknown methods
+const unmatchedRoute = "unmatched"
+
+// routeLabel is the route label of a served request: the pattern of the route that matched it,
+// like "GET /-/tag/{tag...}", so the label values are bounded by the routes rather than the notes
+func routeLabel(r *http.Request) string {
+	if r.Pattern == "" {
+		return unmatchedRoute
+	}
+	return r.Pattern
+}
+
+// metricsMiddleware records the count and duration of every request, by route pattern.
+// The mux sets the pattern on the request, read once the request was served.
+func metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(recorder, r)
+
+		route := routeLabel(r)
+		httpRequestsTotal.Inc(route, strconv.Itoa(recorder.status))
+		httpRequestDuration.Observe(time.Since(start).Seconds(), route)
+	})
+}
+
+// accessLogMiddleware logs every request with its status, duration and referrer, the static
+// assets at debug level. Query strings are left out, they can carry share keys and tokens.
+func accessLogMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(recorder, r)
+
+		level := slog.LevelInfo
+		if routeGroup(r.URL.Path) == "static" {
+			level = slog.LevelDebug
+		}
+		slog.Log(r.Context(), level, "HTTP request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", recorder.status,
+			"duration", time.Since(start).String(),
+			"referrer", r.Referer(),
+		)
+	})
+}
+
+// statusRecorder captures the response status code.
+// It keeps streaming working: SSE handlers need http.Flusher and http.ResponseController.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Flush() {
+	_ = r.FlushError()
+}
+
+// FlushError flushes like Flush, reporting the errors to http.ResponseController
+func (r *statusRecorder) FlushError() error {
+	return http.NewResponseController(r.ResponseWriter).Flush()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
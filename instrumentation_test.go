@@ -0,0 +1,174 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/EwenQuim/pluie/config"
+	"github.com/EwenQuim/pluie/engine"
+	"github.com/EwenQuim/pluie/template"
+	"github.com/go-fuego/fuego"
+)
+
+func TestRouteGroup(t *testing.T) {
+	tests := map[string]string{
+		"/":                       "note",
+		"/folder/my-note":         "note",
+		"/-/tag/golang":           "tag",
+		"/-/tags":                 "tag",
+		"/-/search":               "search",
+		"/-/search-stream":        "sse",
+		"/-/embedding-progress":   "sse",
+		"/static/app.js":          "static",
+		"/-/health":               "internal",
+		"/-/metrics":              "internal",
+		"/sitemap.xml":            "internal",
+		"/static-notes/not-asset": "note",
+	}
+
+	for path, expected := range tests {
+		if got := routeGroup(path); got != expected {
+			t.Errorf("routeGroup(%q) = %q, want %q", path, got, expected)
+		}
+	}
+}
+
+func TestMetricsMiddleware(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /-/tag/{tag...}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("tag") == "missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		// SSE handlers must still be able to flush through the middleware
+		if _, ok := w.(http.Flusher); !ok {
+			t.Error("response writer lost http.Flusher")
+		}
+		_, _ = w.Write([]byte("ok"))
+	})
+	handler := metricsMiddleware(mux)
+
+	const route = "GET /-/tag/{tag...}"
+	okBefore := httpRequestsTotal.Value(route, "200")
+	notFoundBefore := httpRequestsTotal.Value(route, "404")
+	durationsBefore := httpRequestDuration.Count(route)
+	unmatchedBefore := httpRequestsTotal.Value(unmatchedRoute, "405")
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/-/tag/golang", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/-/tag/missing", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/-/tag/golang", nil))
+
+	if got := httpRequestsTotal.Value(route, "200") - okBefore; got != 1 {
+		t.Errorf("tag 200 requests increased by %v, want 1", got)
+	}
+	if got := httpRequestsTotal.Value(route, "404") - notFoundBefore; got != 1 {
+		t.Errorf("tag 404 requests increased by %v, want 1", got)
+	}
+	if got := httpRequestDuration.Count(route) - durationsBefore; got != 2 {
+		t.Errorf("tag durations increased by %d, want 2", got)
+	}
+	if got := httpRequestsTotal.Value(unmatchedRoute, "405") - unmatchedBefore; got != 1 {
+		t.Errorf("unmatched 405 requests increased by %v, want 1", got)
+	}
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "note.md", "# Note\n")
+
+	cfg := &config.Config{PublicByDefault: true, MetricsEnabled: true, MetricsToken: "secret"}
+	notesMap, tree, tagIndex, err := loadNotes(dir, cfg)
+	if err != nil {
+		t.Fatalf("loadNotes error: %v", err)
+	}
+	server := &Server{
+		NotesService: engine.NewNotesService(notesMap, tree, tagIndex),
+		rs:           template.NewResource(cfg),
+		cfg:          cfg,
+	}
+	fuegoServer := fuego.NewServer()
+	server.registerRoutes(fuegoServer)
+
+	t.Run("token is required", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		fuegoServer.Mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/-/metrics", nil))
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("status = %d, want 401", w.Code)
+		}
+	})
+
+	t.Run("exposes application metrics", func(t *testing.T) {
+		metricsMiddleware(fuegoServer.Mux).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/note", nil))
+
+		req := httptest.NewRequest(http.MethodGet, "/-/metrics", nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		fuegoServer.Mux.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200", w.Code)
+		}
+		for _, expected := range []string{
+			"# TYPE pluie_http_requests_total counter",
+			`pluie_http_requests_total{route="GET /{slug...}",code="200"}`,
+			"# TYPE pluie_http_request_duration_seconds histogram",
+			"# TYPE pluie_notes_reload_duration_seconds histogram",
+			"# TYPE pluie_notes_last_reload_duration_seconds gauge",
+			"# TYPE pluie_notes_last_reload_timestamp_seconds gauge",
+			"# TYPE pluie_embedding_notes_total gauge",
+			"# TYPE pluie_embedding_notes_embedded gauge",
+			"# TYPE pluie_sse_connections gauge",
+			"# TYPE pluie_llm_generation_duration_seconds histogram",
+			"pluie_notes_loaded 1\n",
+			"# TYPE pluie_embedding_in_progress gauge",
+			"# TYPE pluie_llm_tokens_total counter",
+		} {
+			if !strings.Contains(w.Body.String(), expected) {
+				t.Errorf("expected %q in metrics output", expected)
+			}
+		}
+	})
+
+	t.Run("disabled endpoint", func(t *testing.T) {
+		cfg.MetricsEnabled = false
+		disabled := fuego.NewServer()
+		server.registerRoutes(disabled)
+
+		w := httptest.NewRecorder()
+		disabled.Mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/-/metrics", nil))
+		if strings.Contains(w.Body.String(), "# TYPE") {
+			t.Error("metrics exposed while disabled")
+		}
+	})
+}
+
+func TestAccessLogMiddleware(t *testing.T) {
+	var logs strings.Builder
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelInfo})))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	handler := accessLogMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	r := httptest.NewRequest(http.MethodGet, "/garden?key=secret", nil)
+	r.Header.Set("Referer", "https://example.com/")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/static/app.js", nil))
+
+	line := logs.String()
+	for _, want := range []string{`"method":"GET"`, `"path":"/garden"`, `"status":418`, `"duration":`, `"referrer":"https://example.com/"`} {
+		if !strings.Contains(line, want) {
+			t.Errorf("access log = %s, want %s", line, want)
+		}
+	}
+	if strings.Contains(line, "secret") {
+		t.Errorf("access log = %s, the query should be left out", line)
+	}
+	if strings.Contains(line, "app.js") {
+		t.Errorf("access log = %s, static assets should be logged at debug level", line)
+	}
+}
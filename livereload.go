@@ -0,0 +1,82 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// LiveReload tells the open browser tabs that the notes were reloaded, so they refresh. Every
+// subscriber channel holds one pending reload at most: a burst of reloads is a single one for a
+// tab that didn't read it yet. A nil LiveReload notifies no one.
+type LiveReload struct {
+	mu          sync.Mutex
+	subscribers map[chan struct{}]bool
+}
+
+// NewLiveReload creates a live reload hub without subscribers
+func NewLiveReload() *LiveReload {
+	return &LiveReload{subscribers: make(map[chan struct{}]bool)}
+}
+
+// Subscribe returns a channel receiving the reloads from now on, until Unsubscribe
+func (l *LiveReload) Subscribe() chan struct{} {
+	ch := make(chan struct{}, 1)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.subscribers[ch] = true
+	return ch
+}
+
+// Unsubscribe stops sending the reloads to ch
+func (l *LiveReload) Unsubscribe(ch chan struct{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.subscribers, ch)
+}
+
+// Notify sends a reload to every subscriber, without waiting for the ones not done with the
+// previous one
+func (l *LiveReload) Notify() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for ch := range l.subscribers {
+		select {
+		case ch <- struct{}{}:
+		default:
+			// Already has a reload to read
+		}
+	}
+}
+
+// getLiveReload streams a reload event after every reload of the notes to the live reload script
+// of the layout. The stream lasts as long as the tab, without the write deadline of startSSE.
+func (s *Server) getLiveReload(w http.ResponseWriter, r *http.Request) {
+	sse, stop, ok := s.startSSE(w, r, "live-reload")
+	if !ok {
+		return
+	}
+	defer stop()
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		slog.Debug("Failed to clear the write deadline of the live reload stream", "error", err)
+	}
+
+	reloads := s.liveReload.Subscribe()
+	defer s.liveReload.Unsubscribe(reloads)
+
+	for {
+		select {
+		case <-sse.Context().Done():
+			return
+		case <-reloads:
+			if err := sse.Event("reload", s.NotesService.LoadedAt().Format(time.RFC3339Nano)); err != nil {
+				slog.Debug("SSE live reload write failed", "error", err)
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/EwenQuim/pluie/config"
+	"github.com/EwenQuim/pluie/engine"
+	"github.com/EwenQuim/pluie/template"
+	"github.com/go-fuego/fuego"
+)
+
+func TestLiveReload(t *testing.T) {
+	received := func(ch chan struct{}) bool {
+		select {
+		case <-ch:
+			return true
+		default:
+			return false
+		}
+	}
+
+	hub := NewLiveReload()
+	first, second := hub.Subscribe(), hub.Subscribe()
+
+	hub.Notify()
+	if !received(first) || !received(second) {
+		t.Error("every subscriber should get the reload")
+	}
+
+	// A burst of reloads is one for a subscriber that didn't read them yet
+	hub.Notify()
+	hub.Notify()
+	hub.Notify()
+	if !received(first) || received(first) {
+		t.Error("a burst of reloads should be received once")
+	}
+
+	received(second)
+	hub.Unsubscribe(second)
+	for range 3 { // More reloads than the buffer of the channel, none waiting for a reader
+		hub.Notify()
+	}
+	if received(second) {
+		t.Error("an unsubscribed channel should not get the reloads")
+	}
+
+	// Servers without WATCH have no hub
+	var disabled *LiveReload
+	disabled.Notify()
+}
+
+func TestLiveReloadStream(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "garden.md", "# Garden\n")
+	cfg := &config.Config{Path: dir, PublicByDefault: true, Watch: true, Mode: "server"}
+	notesMap, tree, tagIndex, err := loadNotes(dir, cfg)
+	if err != nil {
+		t.Fatalf("loadNotes() error: %v", err)
+	}
+	server := &Server{
+		NotesService: engine.NewNotesService(notesMap, tree, tagIndex),
+		rs:           template.NewResource(cfg),
+		cfg:          cfg,
+		liveReload:   NewLiveReload(),
+	}
+	fuegoServer := fuego.NewServer()
+	server.registerRoutes(fuegoServer)
+	ts := httptest.NewServer(fuegoServer.Mux)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/-/live-reload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("Content-Type = %q, want an SSE stream", resp.Header.Get("Content-Type"))
+	}
+
+	// The stream subscribes once its headers are sent: reload until the event comes
+	events := make(chan string)
+	go func() {
+		reader := bufio.NewReader(resp.Body)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				close(events)
+				return
+			}
+			events <- line
+		}
+	}()
+	writeTestFile(t, dir, "garden.md", "# Garden\nTomatoes\n")
+	ticker := time.NewTicker(20 * time.Millisecond)
+	defer ticker.Stop()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := server.reloadNotes(dir, cfg, true); err != nil {
+				t.Fatalf("reloadNotes() error: %v", err)
+			}
+		case line, ok := <-events:
+			if !ok {
+				t.Fatal("the stream ended before the reload event")
+			}
+			if strings.HasPrefix(line, "event: reload") {
+				return
+			}
+		case <-timeout:
+			t.Fatal("no reload event after reloading the notes")
+		}
+	}
+}
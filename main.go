@@ -7,11 +7,14 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
+	_ "time/tzdata" // SITE_TIMEZONE must work on images without a zoneinfo database
 
 	"github.com/EwenQuim/pluie/config"
 	"github.com/EwenQuim/pluie/engine"
 	"github.com/EwenQuim/pluie/template"
 	"github.com/charmbracelet/log"
+	"github.com/fsnotify/fsnotify"
 )
 
 // version is set at build time via -ldflags
@@ -26,6 +29,11 @@ func main() {
 		return
 	}
 
+	if cfg.PrintConfig {
+		fmt.Print(cfg.String())
+		return
+	}
+
 	// Setup charmbracelet/log as slog handler
 	logger := log.New(os.Stderr)
 	logger.SetReportTimestamp(true)
@@ -43,67 +51,210 @@ func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
+	// Import mode converts an export into notes, the vault is not loaded
+	if cfg.Mode == "import" {
+		if _, err := runImport(cfg); err != nil {
+			slog.Error("Error importing", "error", err)
+		}
+		return
+	}
+
+	// Export mode writes a note and the notes it links to as a static site, it loads the notes itself
+	if cfg.Mode == "export" {
+		if _, err := runExport(cfg); err != nil {
+			slog.Error("Error exporting note", "error", err)
+		}
+		return
+	}
+
+	// Inspect mode writes a record of every note, it loads the notes itself
+	if cfg.Mode == "inspect" {
+		if _, err := runInspect(cfg); err != nil {
+			slog.Error("Error inspecting notes", "error", err)
+		}
+		return
+	}
+
 	// Load initial notes
-	notesMap, tree, tagIndex, err := loadNotes(cfg.Path, cfg)
+	notesMap, tree, tagIndex, privateSlugs, err := loadNotesWithPrivateSlugs(cfg.Path, cfg)
 	if err != nil {
 		slog.Error("Error loading notes", "error", err)
 		return
 	}
 
 	notesService := engine.NewNotesService(notesMap, tree, tagIndex)
+	notesService.SetPrivateSlugs(privateSlugs)
+	notesService.SetRenderCacheSize(cfg.RenderCacheSize)
+
+	// Check mode only reports problems found in the notes
+	if cfg.Mode == "check" {
+		runCheck(notesService)
+		return
+	}
+
+	// Flashcards export mode writes the Q&A of the tagged notes to a CSV file
+	if cfg.Mode == "export-flashcards" {
+		if _, err := runExportFlashcards(notesService, cfg); err != nil {
+			slog.Error("Error exporting flashcards", "error", err)
+		}
+		return
+	}
+
+	// Preview mode: README.md or index.md as home, on the first free port
+	if cfg.Preview {
+		cfg.HomeNoteSlug = previewHomeSlug(notesService.GetAllNotes())
+		port, err := findListenPort(cfg.Port)
+		if err != nil {
+			slog.Error("Error finding a free port", "error", err)
+			return
+		}
+		if port != cfg.Port {
+			slog.Info("Port already in use, using a free one", "requested", cfg.Port, "port", port)
+		}
+		cfg.Port = port
+	}
 
 	// Initialize embedding progress tracker
 	embeddingProgress := NewEmbeddingProgress()
 
-	// Initialize Weaviate store for search (embeddings will be lazy-loaded on first search)
-	wvStore, err := initializeWeaviateStore(cfg)
-	if err != nil {
-		slog.Warn("Failed to initialize Weaviate store, search and embeddings will not be available", "error", err)
-		wvStore = nil
+	// Initialize the vector store for search (embeddings will be lazy-loaded on first search)
+	var vectorStore VectorStore
+	if !cfg.Preview {
+		vectorStore, err = initializeVectorStore(cfg)
+		if err != nil {
+			slog.Warn("Failed to initialize the vector store, semantic search and embeddings will not be available", "error", err)
+			vectorStore = nil
+		}
 	}
 
 	// Create embeddings manager
-	embeddingsManager := NewEmbeddingsManager(ctx, wvStore, embeddingProgress, notesService, cfg.EmbeddingsTrackingFile, cfg.EmbeddingModel)
+	embeddingsQueue := NewEmbeddingQueue(cfg.EmbeddingsRateLimit, cfg.EmbeddingsConcurrency)
+	embeddingsManager := NewEmbeddingsManager(ctx, vectorStore, embeddingProgress, notesService, cfg.EmbeddingsTrackingFile, cfg.EmbeddingModel, embeddingsQueue)
+	embeddingsManager.publicByDefault = cfg.PublicByDefault
+	if cfg.ForceReembed && vectorStore != nil {
+		if err := embeddingsManager.Reembed(); err != nil {
+			slog.Warn("Failed to clear the embeddings cache", "error", err)
+		}
+	}
 
-	// Initialize chat client for AI responses
-	chatClient, err := initializeChatClient(cfg)
-	if err != nil {
-		slog.Warn("Failed to initialize chat client, AI responses will not be available", "error", err)
-		chatClient = nil
+	// Initialize chat providers for AI responses
+	var chatChain *ChatChain
+	if !cfg.Preview {
+		chatChain, err = initializeChatChain(cfg)
+		if err != nil {
+			slog.Warn("Failed to initialize chat client, AI responses will not be available", "error", err)
+			chatChain = nil
+		}
 	}
 
 	// Run in static mode if requested
 	if cfg.Mode == "static" {
+		// When uploading, generate into a temporary folder that is synced then discarded
+		if cfg.Upload != "" {
+			tmpDir, err := os.MkdirTemp("", "pluie-static-")
+			if err != nil {
+				slog.Error("Error creating temporary output folder", "error", err)
+				return
+			}
+			defer func() {
+				if err := os.RemoveAll(tmpDir); err != nil {
+					slog.Error("failed to remove temporary output folder", "path", tmpDir, "error", err)
+				}
+			}()
+			cfg.Output = tmpDir
+		}
+
 		err := generateStaticSite(notesService, cfg)
 		if err != nil {
 			slog.Error("Error generating static site", "error", err)
 			return
 		}
 		slog.Info("Static site generated successfully", "folder", cfg.Output)
+
+		if cfg.Upload != "" {
+			if err := uploadStaticSite(ctx, cfg); err != nil {
+				slog.Error("Error uploading static site", "error", err)
+				return
+			}
+		}
 		return
 	}
 
+	// Deleted notes stay readable at their URL for a few days
+	var trash *Trash
+	if cfg.TrashDays > 0 {
+		trash = NewTrash(cfg.Path, time.Duration(cfg.TrashDays)*24*time.Hour, cfg.TrashFile)
+	}
+
+	// Comments of the readers, kept out of the vault
+	var comments CommentStore
+	if cfg.CommentsEnabled {
+		if comments, err = NewFileCommentStore(cfg.CommentsDir); err != nil {
+			slog.Error("Failed to open comments, comments disabled", "dir", cfg.CommentsDir, "error", err)
+			comments = nil
+		}
+	}
+
 	// Otherwise run in server mode
 	server := &Server{
 		NotesService:      notesService,
 		rs:                template.NewResource(cfg),
 		cfg:               cfg,
-		chatClient:        chatClient,
+		chatChain:         chatChain,
 		embeddingsManager: embeddingsManager,
+		trash:             trash,
+		comments:          comments,
+		commentLimiter:    newRateLimiter(commentRateLimit, commentRateWindow),
+		views:             NewViewCounter(cfg.ViewsFile),
+	}
+	if cfg.Watch {
+		server.liveReload = NewLiveReload()
+	}
+	go server.views.Run(ctx, viewsFlushInterval)
+
+	// Report an invalid prompt template now rather than at the first question
+	if chatChain != nil {
+		server.promptTemplate()
 	}
 
 	// Start file watcher if enabled
+	var watcher *fsnotify.Watcher
 	if cfg.Watch {
-		_, err = watchFiles(ctx, server, cfg.Path, cfg)
+		watcher, err = watchFiles(ctx, server, cfg.Path, cfg)
 		if err != nil {
 			slog.Error("Error starting file watcher", "error", err)
 			// Continue anyway - the server can still work without file watching
 		}
 	}
 
-	err = server.Start(ctx)
+	if cfg.Preview {
+		url := "http://localhost:" + cfg.Port
+		fmt.Println("Previewing " + cfg.Path + " at " + url)
+		if !cfg.NoOpen {
+			go openBrowserWhenReady(ctx, url, cfg.Port)
+		}
+	}
+
+	httpServer, err := server.Start(ctx)
 	if err != nil {
 		slog.Error("Server failed to start", "error", err)
 		os.Exit(1)
 	}
+	// No reload while the requests drain
+	if watcher != nil {
+		httpServer.RegisterOnShutdown(func() {
+			if err := watcher.Close(); err != nil {
+				slog.Error("failed to close watcher", "error", err)
+			}
+		})
+	}
+
+	err = server.Wait()
+	// Once no request runs: the embedding pass, then the view counts
+	embeddingsManager.Close()
+	server.views.Flush()
+	if err != nil {
+		slog.Error("Server stopped", "error", err)
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,248 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// sample is one parsed exposition line
+type sample struct {
+	name   string
+	labels map[string]string
+	value  float64
+}
+
+// parseExposition is a tiny parser for the Prometheus text format, strict enough to catch format errors
+func parseExposition(t *testing.T, text string) (samples []sample, types map[string]string) {
+	t.Helper()
+	types = make(map[string]string)
+
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			t.Fatalf("unexpected empty line")
+		}
+
+		if strings.HasPrefix(line, "# ") {
+			fields := strings.SplitN(line, " ", 4)
+			if len(fields) < 4 || (fields[1] != "HELP" && fields[1] != "TYPE") {
+				t.Fatalf("invalid comment line %q", line)
+			}
+			if fields[1] == "TYPE" {
+				types[fields[2]] = fields[3]
+			}
+			continue
+		}
+
+		s := sample{labels: make(map[string]string)}
+		rest := line
+		if i := strings.IndexAny(rest, "{ "); i > 0 {
+			s.name, rest = rest[:i], rest[i:]
+		} else {
+			t.Fatalf("invalid sample line %q", line)
+		}
+
+		if strings.HasPrefix(rest, "{") {
+			rest = rest[1:]
+			for !strings.HasPrefix(rest, "}") {
+				eq := strings.Index(rest, `="`)
+				if eq <= 0 {
+					t.Fatalf("invalid labels in %q", line)
+				}
+				name := rest[:eq]
+				rest = rest[eq+2:]
+
+				var value strings.Builder
+				for {
+					if rest == "" {
+						t.Fatalf("unterminated label value in %q", line)
+					}
+					if rest[0] == '\\' {
+						switch rest[1] {
+						case 'n':
+							value.WriteByte('\n')
+						default:
+							value.WriteByte(rest[1])
+						}
+						rest = rest[2:]
+						continue
+					}
+					if rest[0] == '"' {
+						rest = rest[1:]
+						break
+					}
+					value.WriteByte(rest[0])
+					rest = rest[1:]
+				}
+				s.labels[name] = value.String()
+				rest = strings.TrimPrefix(rest, ",")
+			}
+			rest = rest[1:]
+		}
+
+		if !strings.HasPrefix(rest, " ") {
+			t.Fatalf("missing value separator in %q", line)
+		}
+		value, err := strconv.ParseFloat(strings.TrimPrefix(rest[1:], "+"), 64)
+		if err != nil {
+			t.Fatalf("invalid value in %q: %v", line, err)
+		}
+		s.value = value
+		samples = append(samples, s)
+	}
+
+	return samples, types
+}
+
+func findSample(samples []sample, name string, labels map[string]string) (float64, bool) {
+	for _, s := range samples {
+		if s.name != name || len(s.labels) != len(labels) {
+			continue
+		}
+		match := true
+		for k, v := range labels {
+			if s.labels[k] != v {
+				match = false
+			}
+		}
+		if match {
+			return s.value, true
+		}
+	}
+	return 0, false
+}
+
+func render(t *testing.T, r *Registry) string {
+	t.Helper()
+	var sb strings.Builder
+	if err := r.Write(&sb); err != nil {
+		t.Fatalf("Write error: %v", err)
+	}
+	return sb.String()
+}
+
+func TestRegistry_Exposition(t *testing.T) {
+	r := NewRegistry()
+	requests := r.NewCounter("test_requests_total", "Requests.\nSecond line.", "route")
+	loaded := r.NewGauge("test_loaded", "Loaded things.")
+	duration := r.NewHistogram("test_duration_seconds", "Durations.", []float64{0.1, 1}, "route")
+
+	requests.Inc("note")
+	requests.Add(2, "note")
+	requests.Inc(`we"ird\route` + "\n")
+	loaded.Set(42)
+	loaded.Add(-2)
+	duration.Observe(0.05, "note")
+	duration.Observe(0.1, "note")
+	duration.Observe(0.5, "note")
+	duration.Observe(3, "note")
+
+	samples, types := parseExposition(t, render(t, r))
+
+	expectedTypes := map[string]string{
+		"test_requests_total":   "counter",
+		"test_loaded":           "gauge",
+		"test_duration_seconds": "histogram",
+	}
+	for name, kind := range expectedTypes {
+		if types[name] != kind {
+			t.Errorf("TYPE of %s = %q, want %q", name, types[name], kind)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		labels   map[string]string
+		expected float64
+	}{
+		{"test_requests_total", map[string]string{"route": "note"}, 3},
+		{"test_requests_total", map[string]string{"route": `we"ird\route` + "\n"}, 1},
+		{"test_loaded", map[string]string{}, 40},
+		{"test_duration_seconds_bucket", map[string]string{"route": "note", "le": "0.1"}, 2},
+		{"test_duration_seconds_bucket", map[string]string{"route": "note", "le": "1"}, 3},
+		{"test_duration_seconds_bucket", map[string]string{"route": "note", "le": "+Inf"}, 4},
+		{"test_duration_seconds_sum", map[string]string{"route": "note"}, 3.65},
+		{"test_duration_seconds_count", map[string]string{"route": "note"}, 4},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s%v", tt.name, tt.labels), func(t *testing.T) {
+			got, ok := findSample(samples, tt.name, tt.labels)
+			if !ok {
+				t.Fatalf("sample not found")
+			}
+			if got != tt.expected {
+				t.Errorf("value = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRegistry_UnlabelledSeriesExposedBeforeUpdate(t *testing.T) {
+	r := NewRegistry()
+	r.NewGauge("test_idle", "Never set.")
+
+	samples, _ := parseExposition(t, render(t, r))
+	if value, ok := findSample(samples, "test_idle", map[string]string{}); !ok || value != 0 {
+		t.Errorf("expected test_idle 0, got %v (found %v)", value, ok)
+	}
+}
+
+func TestCounter_PanicsOnWrongLabelCount(t *testing.T) {
+	r := NewRegistry()
+	counter := r.NewCounter("test_total", "Test.", "route")
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for missing label values")
+		}
+	}()
+	counter.Inc()
+}
+
+func TestHandler_Token(t *testing.T) {
+	r := NewRegistry()
+	r.NewGauge("test_up", "Up.").Set(1)
+
+	tests := []struct {
+		name          string
+		token         string
+		authorization string
+		expectedCode  int
+	}{
+		{name: "no token configured", expectedCode: http.StatusOK},
+		{name: "missing token", token: "secret", expectedCode: http.StatusUnauthorized},
+		{name: "wrong token", token: "secret", authorization: "Bearer nope", expectedCode: http.StatusUnauthorized},
+		{name: "valid token", token: "secret", authorization: "Bearer secret", expectedCode: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/-/metrics", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+
+			r.Handler(tt.token).ServeHTTP(w, req)
+
+			if w.Code != tt.expectedCode {
+				t.Fatalf("status = %d, want %d", w.Code, tt.expectedCode)
+			}
+			if tt.expectedCode == http.StatusOK {
+				if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+					t.Errorf("unexpected content type %q", w.Header().Get("Content-Type"))
+				}
+				samples, _ := parseExposition(t, w.Body.String())
+				if value, ok := findSample(samples, "test_up", map[string]string{}); !ok || value != 1 {
+					t.Errorf("expected test_up 1 in %q", w.Body.String())
+				}
+			}
+		})
+	}
+}
@@ -2,27 +2,99 @@ package model
 
 import (
 	"net/url"
+	"path"
 	"strings"
+	"time"
 )
 
+// Page layouts a note can request with the "layout" frontmatter key
+const (
+	LayoutDefault = "default" // Prose column with the tree and TOC sidebars
+	LayoutWide    = "wide"    // Full-width content, TOC sidebar hidden
+	LayoutMinimal = "minimal" // Content only, no sidebars at all
+)
+
+// AccessPrivate is the "access" frontmatter or .pluie value of the notes only readable with
+// the share key of their folder, see SHARE_KEYS
+const AccessPrivate = "private"
+
 type NoteReference struct {
 	Slug  string `json:"slug"`
 	Title string `json:"title"`
 }
 
 type Note struct {
-	Title        string          `json:"title"` // May contains spaces and slashes, like "articles/Hello World"
-	Slug         string          `json:"slug"`  // Slugified title, like "my-articles/hello-world"
-	Path         string          `json:"path"`  // Full path relative to the base directory, like "My articles/Hello World.md"
-	Content      string          `json:"content"`
-	ReferencedBy []NoteReference `json:"referenced_by"` // Notes that have wikilinks to this note
-	IsPublic     bool            `json:"isPublic"`      // Whether this note is public or private
-	Metadata     map[string]any  `json:"metadata"`      // YAML frontmatter metadata
+	Title        string            `json:"title"`         // May contains spaces and slashes, like "articles/Hello World"
+	Slug         string            `json:"slug"`          // Slugified title, like "my-articles/hello-world"
+	Path         string            `json:"path"`          // Full path relative to the base directory, like "My articles/Hello World.md"
+	Content      string            `json:"content"`       // Note's own markdown, before any ![[embed]] expansion
+	ReferencedBy []NoteReference   `json:"referenced_by"` // Notes that have wikilinks to this note
+	IsPublic     bool              `json:"isPublic"`      // Whether this note is public or private
+	IsDraft      bool              `json:"isDraft"`       // Whether this note is a draft, only shown with SHOW_DRAFTS, see DetermineIsDraft
+	Metadata     map[string]any    `json:"metadata"`      // YAML frontmatter metadata
+	Layout       string            `json:"layout"`        // Resolved page layout, one of the Layout* constants
+	Status       string            `json:"status"`        // Recognized "status" frontmatter value, like "draft", empty if none
+	ModTime      time.Time         `json:"mod_time"`      // Modification time of the file
+	Attachments  map[string]string `json:"attachments"`   // Resolved ![[attachment]] embeds: embed target -> path in the vault
+	Aliases      []string          `json:"aliases"`       // Other titles wikilinks resolve to the note with, from the "aliases" frontmatter
+	Access       string            `json:"access"`        // AccessPrivate for the notes only readable with ShareKey, empty otherwise
+	ShareKey     string            `json:"-"`             // Key unlocking a note with AccessPrivate, from SHARE_KEYS
+	Permalink    string            `json:"permalink"`     // Slug from the "permalink" frontmatter, like "blog/my-old-url", empty if none
+
+	LastCommitHash string    `json:"last_commit_hash"` // Hash of the last commit of the file, empty outside a git repository
+	LastCommitDate time.Time `json:"last_commit_date"` // Committer date of LastCommitHash
+	ViewCount      int64     `json:"-"`                // Views of the note, set when rendering it with SHOW_VIEW_COUNT
 }
 
-// BuildSlug creates a URL-friendly slug from the note's title or existing slug
+// TrashedNote is a note whose file was deleted, still readable at its old URL until ExpiresAt
+type TrashedNote struct {
+	Note      Note      `json:"note"` // Last known version of the note
+	DeletedAt time.Time `json:"deleted_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Comment is a comment left by a reader at the bottom of a note
+type Comment struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// BuildSlug creates a URL-friendly slug from the "permalink" metadata of the note, like
+// "/blog/my-old-url" for URLs kept from another site, or else from its title or existing slug
 // This uses the unified slugification approach for notes (matches engine.SlugifyNoteWithCaseLogic)
 func (n *Note) BuildSlug() {
+	n.Permalink = ParsePermalink(n.Metadata)
+	if n.Permalink != "" {
+		n.Slug = strings.ReplaceAll(url.PathEscape(n.Permalink), "%2F", "/")
+		return
+	}
+	n.buildSlugFromText()
+}
+
+// BuildPathSlug sets the slug of the note from its path, ignoring its permalink, like for the
+// notes whose permalink another note has
+func (n *Note) BuildPathSlug() {
+	n.Permalink = ""
+	n.Slug = n.Path
+	n.buildSlugFromText()
+}
+
+// ParsePermalink returns the "permalink" value of note metadata as a slug: without its leading
+// and trailing slashes, and without "." or ".." segments. Empty when there is none, or when it
+// would take the URLs of the pages of the site, starting with "-/" or "static/".
+func ParsePermalink(metadata map[string]any) string {
+	value, _ := metadata["permalink"].(string)
+	permalink := strings.Trim(path.Clean("/"+strings.TrimSpace(value)), "/")
+	if permalink == "-" || strings.HasPrefix(permalink, "-/") || permalink == "static" || strings.HasPrefix(permalink, "static/") {
+		return ""
+	}
+	return permalink
+}
+
+// buildSlugFromText is BuildSlug from the title or existing slug of the note
+func (n *Note) buildSlugFromText() {
 	text := n.Slug
 	usingTitle := false
 	if text == "" {
@@ -89,19 +161,11 @@ func (n *Note) DetermineIsPublic(folderMetadata map[string]map[string]any) {
 	}
 
 	// Second, check parent folder metadata
-	// Extract the folder path from the slug
-	pathParts := strings.Split(strings.Trim(n.Slug, "/"), "/")
-	if len(pathParts) > 1 {
-		// Build folder path (all parts except the last one which is the file)
-		folderPath := strings.Join(pathParts[:len(pathParts)-1], "/")
-
-		// Check if folder has metadata
-		if metadata, exists := folderMetadata[folderPath]; exists {
-			if publishValue, exists := metadata["publish"]; exists {
-				if publishBool, ok := publishValue.(bool); ok {
-					n.IsPublic = publishBool
-					return
-				}
+	if metadata := n.parentFolderMetadata(folderMetadata); metadata != nil {
+		if publishValue, exists := metadata["publish"]; exists {
+			if publishBool, ok := publishValue.(bool); ok {
+				n.IsPublic = publishBool
+				return
 			}
 		}
 	}
@@ -109,3 +173,109 @@ func (n *Note) DetermineIsPublic(folderMetadata map[string]map[string]any) {
 	// Third, fall back to private by default
 	n.IsPublic = false
 }
+
+// DetermineAliases sets the Aliases field from the "aliases" (or "alias") metadata,
+// a list or a single string, like Obsidian
+func (n *Note) DetermineAliases() {
+	n.Aliases = ParseAliases(n.Metadata)
+}
+
+// ParseAliases returns the "aliases" (or "alias") values of note metadata, empty ones left out
+func ParseAliases(metadata map[string]any) []string {
+	var aliases []string
+	add := func(alias string) {
+		if alias = strings.TrimSpace(alias); alias != "" {
+			aliases = append(aliases, alias)
+		}
+	}
+	for _, key := range []string{"aliases", "alias"} {
+		switch value := metadata[key].(type) {
+		case string:
+			add(value)
+		case []any:
+			for _, item := range value {
+				if alias, ok := item.(string); ok {
+					add(alias)
+				}
+			}
+		}
+	}
+	return aliases
+}
+
+// DetermineIsDraft sets the IsDraft field with the same hierarchy as DetermineIsPublic: the
+// note's own "draft" metadata, then its parent folder's, then not a draft.
+func (n *Note) DetermineIsDraft(folderMetadata map[string]map[string]any) {
+	if draft, ok := n.Metadata["draft"].(bool); ok {
+		n.IsDraft = draft
+		return
+	}
+	draft, _ := n.parentFolderMetadata(folderMetadata)["draft"].(bool)
+	n.IsDraft = draft
+}
+
+// DetermineLayout sets the Layout field with the same hierarchy as DetermineIsPublic:
+// the note's own "layout" metadata, then its parent folder's, then LayoutDefault.
+// Unknown layout values are ignored.
+func (n *Note) DetermineLayout(folderMetadata map[string]map[string]any) {
+	if layout, ok := layoutFromMetadata(n.Metadata); ok {
+		n.Layout = layout
+		return
+	}
+
+	if layout, ok := layoutFromMetadata(n.parentFolderMetadata(folderMetadata)); ok {
+		n.Layout = layout
+		return
+	}
+
+	n.Layout = LayoutDefault
+}
+
+// layoutFromMetadata returns the valid layout declared in metadata, if any
+func layoutFromMetadata(metadata map[string]any) (string, bool) {
+	layout, ok := metadata["layout"].(string)
+	if !ok {
+		return "", false
+	}
+
+	switch layout = strings.ToLower(strings.TrimSpace(layout)); layout {
+	case LayoutDefault, LayoutWide, LayoutMinimal:
+		return layout, true
+	}
+	return "", false
+}
+
+// DetermineAccess sets the Access field from the "access" metadata of the note, else of the
+// .pluie file of its folder. Only AccessPrivate is recognized.
+func (n *Note) DetermineAccess(folderMetadata map[string]map[string]any) {
+	n.Access = ""
+	access, ok := n.Metadata["access"].(string)
+	if !ok {
+		// Keyed by the folder path in the vault, like "Clients/Acme", see Explorer.collectFolderMetadata
+		folder := strings.Trim(n.Path, "/")
+		if i := strings.LastIndex(folder, "/"); i >= 0 {
+			access, _ = folderMetadata[folder[:i]]["access"].(string)
+		}
+	}
+	if strings.ToLower(strings.TrimSpace(access)) == AccessPrivate {
+		n.Access = AccessPrivate
+	}
+}
+
+// parentFolderMetadata returns the .pluie metadata of the folder containing the note, if any
+func (n *Note) parentFolderMetadata(folderMetadata map[string]map[string]any) map[string]any {
+	// Keyed by the folder path in the vault, like "Clients/Acme": the slug is only used by notes
+	// built without their path, it is lowercased and may be a permalink
+	notePath := n.Path
+	if notePath == "" {
+		notePath = n.Slug
+	}
+	pathParts := strings.Split(strings.Trim(notePath, "/"), "/")
+	if len(pathParts) <= 1 {
+		return nil
+	}
+
+	// Build folder path (all parts except the last one which is the file)
+	folderPath := strings.Join(pathParts[:len(pathParts)-1], "/")
+	return folderMetadata[folderPath]
+}
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"slices"
 	"testing"
 )
 
@@ -357,3 +358,226 @@ func TestNote_DetermineIsPublic(t *testing.T) {
 		})
 	}
 }
+
+func TestNote_DetermineLayout(t *testing.T) {
+	tests := []struct {
+		name           string
+		note           Note
+		folderMetadata map[string]map[string]any
+		expected       string
+	}{
+		{
+			name:           "No metadata falls back to default",
+			note:           Note{Slug: "folder/note", Metadata: map[string]any{}},
+			folderMetadata: map[string]map[string]any{},
+			expected:       LayoutDefault,
+		},
+		{
+			name:           "Note layout wide",
+			note:           Note{Slug: "note", Metadata: map[string]any{"layout": "wide"}},
+			folderMetadata: map[string]map[string]any{},
+			expected:       LayoutWide,
+		},
+		{
+			name:           "Note layout is case and space insensitive",
+			note:           Note{Slug: "note", Metadata: map[string]any{"layout": "  Minimal "}},
+			folderMetadata: map[string]map[string]any{},
+			expected:       LayoutMinimal,
+		},
+		{
+			name: "Folder layout applies to its notes",
+			note: Note{Slug: "folder/note", Metadata: map[string]any{}},
+			folderMetadata: map[string]map[string]any{
+				"folder": {"layout": "wide"},
+			},
+			expected: LayoutWide,
+		},
+		{
+			name: "Note layout overrides folder layout",
+			note: Note{Slug: "folder/note", Metadata: map[string]any{"layout": "minimal"}},
+			folderMetadata: map[string]map[string]any{
+				"folder": {"layout": "wide"},
+			},
+			expected: LayoutMinimal,
+		},
+		{
+			name: "Invalid note layout falls back to folder layout",
+			note: Note{Slug: "folder/note", Metadata: map[string]any{"layout": "fullscreen"}},
+			folderMetadata: map[string]map[string]any{
+				"folder": {"layout": "wide"},
+			},
+			expected: LayoutWide,
+		},
+		{
+			name: "Non-string layout ignored",
+			note: Note{Slug: "folder/note", Metadata: map[string]any{"layout": 42}},
+			folderMetadata: map[string]map[string]any{
+				"folder": {"layout": true},
+			},
+			expected: LayoutDefault,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.note.DetermineLayout(tt.folderMetadata)
+			if tt.note.Layout != tt.expected {
+				t.Errorf("DetermineLayout() set Layout = %q, want %q", tt.note.Layout, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDetermineAccess(t *testing.T) {
+	tests := []struct {
+		name           string
+		note           Note
+		folderMetadata map[string]map[string]any
+		expected       string
+	}{
+		{
+			name:     "No access",
+			note:     Note{Path: "Clients/note.md", Metadata: map[string]any{}},
+			expected: "",
+		},
+		{
+			name:           "Folder access with the vault path of the folder",
+			note:           Note{Slug: "clients/acme/note", Path: "Clients/Acme/note.md", Metadata: map[string]any{}},
+			folderMetadata: map[string]map[string]any{"Clients/Acme": {"access": "private"}},
+			expected:       AccessPrivate,
+		},
+		{
+			name:     "Note access",
+			note:     Note{Path: "note.md", Metadata: map[string]any{"access": " Private "}},
+			expected: AccessPrivate,
+		},
+		{
+			name:           "Note access overrides folder access",
+			note:           Note{Path: "Clients/note.md", Metadata: map[string]any{"access": "public"}},
+			folderMetadata: map[string]map[string]any{"Clients": {"access": "private"}},
+			expected:       "",
+		},
+		{
+			name:           "Unknown access ignored",
+			note:           Note{Path: "Clients/note.md", Metadata: map[string]any{}},
+			folderMetadata: map[string]map[string]any{"Clients": {"access": "secret"}},
+			expected:       "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.note.DetermineAccess(tt.folderMetadata)
+			if tt.note.Access != tt.expected {
+				t.Errorf("DetermineAccess() set Access = %q, want %q", tt.note.Access, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseAliases(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata map[string]any
+		expected []string
+	}{
+		{name: "No aliases", metadata: map[string]any{"title": "Note"}, expected: nil},
+		{name: "List", metadata: map[string]any{"aliases": []any{"AI", " Machine minds ", "", 42}}, expected: []string{"AI", "Machine minds"}},
+		{name: "Single string", metadata: map[string]any{"aliases": "AI"}, expected: []string{"AI"}},
+		{name: "Alias key", metadata: map[string]any{"alias": "AI"}, expected: []string{"AI"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseAliases(tt.metadata); !slices.Equal(got, tt.expected) {
+				t.Errorf("ParseAliases() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParsePermalink(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    any
+		expected string
+	}{
+		{name: "No permalink", value: nil, expected: ""},
+		{name: "Leading slash", value: "/blog/my-old-url", expected: "blog/my-old-url"},
+		{name: "Without slashes", value: "blog/my-old-url", expected: "blog/my-old-url"},
+		{name: "Trailing slash and spaces", value: " /blog/my-old-url/ ", expected: "blog/my-old-url"},
+		{name: "Dot segments", value: "/blog/./drafts/../my-old-url", expected: "blog/my-old-url"},
+		{name: "Out of the site", value: "../../etc/passwd", expected: "etc/passwd"},
+		{name: "Only a slash", value: "/", expected: ""},
+		{name: "Pages of the site", value: "/-/search", expected: ""},
+		{name: "Static files", value: "/static/app.js", expected: ""},
+		{name: "Not a string", value: 42, expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParsePermalink(map[string]any{"permalink": tt.value}); got != tt.expected {
+				t.Errorf("ParsePermalink(%v) = %q, want %q", tt.value, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNote_BuildSlug_Permalink(t *testing.T) {
+	note := Note{Title: "Old Post", Slug: "Notes/Old Post.md", Path: "Notes/Old Post.md", Metadata: map[string]any{"permalink": "/blog/My Old URL"}}
+	note.BuildSlug()
+	if note.Slug != "blog/My%20Old%20URL" || note.Permalink != "blog/My Old URL" {
+		t.Errorf("BuildSlug() set Slug = %q, Permalink = %q, want blog/My%%20Old%%20URL from the permalink", note.Slug, note.Permalink)
+	}
+
+	note.BuildPathSlug()
+	if note.Slug != "notes/old-post" || note.Permalink != "" {
+		t.Errorf("BuildPathSlug() set Slug = %q, Permalink = %q, want the slug of the path", note.Slug, note.Permalink)
+	}
+}
+
+func TestNote_DetermineIsDraft(t *testing.T) {
+	tests := []struct {
+		name           string
+		note           Note
+		folderMetadata map[string]map[string]any
+		expected       bool
+	}{
+		{
+			name:     "No metadata is not a draft",
+			note:     Note{Slug: "note", Path: "Note.md", Metadata: map[string]any{}},
+			expected: false,
+		},
+		{
+			name:     "Note draft",
+			note:     Note{Slug: "note", Path: "Note.md", Metadata: map[string]any{"draft": true}},
+			expected: true,
+		},
+		{
+			name:           "Folder draft applies to its notes, by path",
+			note:           Note{Slug: "blog/my-old-url", Path: "Ideas/Boat.md", Metadata: map[string]any{}},
+			folderMetadata: map[string]map[string]any{"Ideas": {"draft": true}},
+			expected:       true,
+		},
+		{
+			name:           "Note draft overrides folder draft",
+			note:           Note{Slug: "ideas/done", Path: "Ideas/Done.md", Metadata: map[string]any{"draft": false}},
+			folderMetadata: map[string]map[string]any{"Ideas": {"draft": true}},
+			expected:       false,
+		},
+		{
+			name:     "Non-bool draft ignored",
+			note:     Note{Slug: "note", Path: "Note.md", Metadata: map[string]any{"draft": "yes"}},
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.note.DetermineIsDraft(tt.folderMetadata)
+			if tt.note.IsDraft != tt.expected {
+				t.Errorf("DetermineIsDraft() set IsDraft = %v, want %v", tt.note.IsDraft, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/EwenQuim/pluie/api"
+	"github.com/EwenQuim/pluie/engine"
+	"github.com/EwenQuim/pluie/model"
+
+	"github.com/go-fuego/fuego"
+)
+
+// apiCacheHeaders sets the ETag and Last-Modified headers of the notes API from the last load
+// of the vault, and answers 304 Not Modified when the client already has that version
+func (s *Server) apiCacheHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		loadedAt := s.NotesService.LoadedAt()
+		etag := `"` + strconv.FormatInt(loadedAt.UnixNano(), 36) + `"`
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Last-Modified", loadedAt.UTC().Format(http.TimeFormat))
+
+		if notModified(r, etag, loadedAt) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// apiCORSHeaders lets the browser pages of the CORS_ORIGINS call the API, like editor plugins,
+// and answers their preflight requests. Other origins get no CORS header, so the browser
+// keeps the response from them.
+func (s *Server) apiCORSHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if !s.cfg.CORSAllowed(origin) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Add("Vary", "Origin")
+		w.Header().Set("Access-Control-Expose-Headers", "ETag, Last-Modified")
+
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Authorization, If-None-Match, If-Modified-Since")
+			w.Header().Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// notModified reports whether the conditional headers of the request match the version of the
+// notes. If-None-Match takes precedence over If-Modified-Since, like in RFC 9110.
+func notModified(r *http.Request, etag string, loadedAt time.Time) bool {
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		for candidate := range strings.SplitSeq(ifNoneMatch, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == etag || candidate == "*" {
+				return true
+			}
+		}
+		return false
+	}
+
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	return err == nil && !loadedAt.Truncate(time.Second).After(since)
+}
+
+// getAPINotes lists the public notes, by slug, without the drafts shown by the server
+func (s *Server) getAPINotes(ctx fuego.ContextNoBody) (api.Envelope[api.NoteSummaries], error) {
+	loc := s.cfg.Location()
+
+	notes := s.NotesService.GetAllNotes()
+	summaries := make(api.NoteSummaries, 0, len(notes))
+	for _, note := range notes {
+		if !apiListed(note, s.cfg.PublicByDefault) {
+			continue
+		}
+		summaries = append(summaries, api.NoteSummary{
+			Slug:     note.Slug,
+			Title:    note.Title,
+			Tags:     engine.NoteTags(note),
+			Modified: apiModified(note, loc),
+		})
+	}
+	slices.SortFunc(summaries, func(a, b api.NoteSummary) int { return strings.Compare(a.Slug, b.Slug) })
+	return api.Wrap(summaries), nil
+}
+
+// apiListed reports whether the API gives the note: the notes visitors may see, see
+// engine.IsVisible, without the drafts shown by the server
+func apiListed(note model.Note, publicByDefault bool) bool {
+	return engine.IsVisible(note, publicByDefault) && !note.IsDraft
+}
+
+// getAPINote returns a note with its rendered content and backlinks. Like the note pages, it
+// finds the public notes, and the notes of a folder with "access: private" given their key.
+// Drafts shown by the server are neither backlinks nor embedded.
+func (s *Server) getAPINote(ctx fuego.ContextNoBody) (api.Envelope[api.Note], error) {
+	slug := ctx.PathParam("slug")
+
+	note, ok := s.NotesService.GetNote(slug)
+	if !ok {
+		if note, ok = s.NotesService.GetSharedNote(slug, shareKeys(ctx.Request())); ok {
+			ctx.Response().Header().Set("Cache-Control", "private, no-store")
+		}
+	} else if !apiListed(note, s.cfg.PublicByDefault) {
+		ok = false
+	}
+	if !ok {
+		return api.Envelope[api.Note]{}, fuego.NotFoundError{Detail: "note not found or private"}
+	}
+
+	metadata := note.Metadata
+	if metadata == nil {
+		metadata = map[string]any{}
+	}
+	backlinks := make([]api.NoteLink, 0, len(note.ReferencedBy))
+	for _, reference := range note.ReferencedBy {
+		if referrer, ok := s.NotesService.GetNote(reference.Slug); ok && apiListed(referrer, s.cfg.PublicByDefault) {
+			backlinks = append(backlinks, api.NoteLink(reference))
+		}
+	}
+
+	return api.Wrap(api.Note{
+		Slug:      note.Slug,
+		Title:     note.Title,
+		Tags:      engine.NoteTags(note),
+		Modified:  apiModified(note, s.cfg.Location()),
+		Metadata:  metadata,
+		HTML:      s.rs.NoteAPIHTML(s.NotesService, &note),
+		Backlinks: backlinks,
+	}), nil
+}
+
+// getAPITree returns the folders and public notes of the sidebar
+func (s *Server) getAPITree(ctx fuego.ContextNoBody) (api.Envelope[api.Tree], error) {
+	tree := api.Tree{}
+	if root := s.NotesService.GetTree(); root != nil {
+		tree = appendAPIFolder(tree, root, s.cfg.PublicByDefault)
+	}
+	return api.Wrap(tree), nil
+}
+
+// appendAPIFolder appends the content of a folder of the tree, leaving out private notes,
+// drafts and the subfolders with no note left, like FolderContents
+func appendAPIFolder(tree api.Tree, folder *engine.TreeNode, publicByDefault bool) api.Tree {
+	for _, child := range folder.Children {
+		if child.IsFolder {
+			withFolder := append(tree, api.TreeEntry{Name: child.Name, Path: child.Path, Parent: folder.Path, IsFolder: true})
+			if withContent := appendAPIFolder(withFolder, child, publicByDefault); len(withContent) > len(withFolder) {
+				tree = withContent
+			}
+			continue
+		}
+		if child.Note != nil && apiListed(*child.Note, publicByDefault) {
+			tree = append(tree, api.TreeEntry{Name: child.Name, Path: child.Path, Parent: folder.Path, Slug: child.Note.Slug})
+		}
+	}
+	return tree
+}
+
+// getAPITags lists the tags with their number of notes, by name. Only the notes of apiListed
+// count, a tag of drafts only is left out.
+func (s *Server) getAPITags(ctx fuego.ContextNoBody) (api.Envelope[api.Tags], error) {
+	tagIndex := s.NotesService.GetTagIndex()
+	counts := tagIndex.Counts()
+	tags := make(api.Tags, 0, len(counts))
+	for _, count := range counts {
+		listed := 0
+		for _, note := range tagIndex[count.Tag] {
+			if apiListed(note, s.cfg.PublicByDefault) {
+				listed++
+			}
+		}
+		if listed > 0 {
+			tags = append(tags, api.Tag{Name: count.Tag, Count: listed})
+		}
+	}
+	return api.Wrap(tags), nil
+}
+
+const (
+	linkIndexDefaultLimit = 100 // Notes of GET /-/api/link-index without ?limit=
+	linkIndexMaxLimit     = 500 // Notes of GET /-/api/link-index at most
+)
+
+// getAPILinkIndex lists the public notes a [[wikilink]] can point to, for the autocompletion
+// of editors. ?q= filters them like the sidebar search does, see SearchNotesByFilename.
+func (s *Server) getAPILinkIndex(ctx fuego.ContextNoBody) (api.Envelope[api.LinkIndex], error) {
+	limit := ctx.QueryParamInt("limit")
+	if limit <= 0 {
+		limit = linkIndexDefaultLimit
+	}
+	limit = min(limit, linkIndexMaxLimit)
+
+	index := make(api.LinkIndex, 0, limit)
+	for _, note := range s.NotesService.SearchNotesByFilename(ctx.QueryParam("q"), 0, s.cfg.PublicByDefault) {
+		if !apiListed(note, s.cfg.PublicByDefault) {
+			continue
+		}
+		folder := path.Dir(strings.Trim(note.Path, "/"))
+		if folder == "." {
+			folder = ""
+		}
+		aliases := note.Aliases
+		if aliases == nil {
+			aliases = []string{}
+		}
+		index = append(index, api.LinkTarget{Title: note.Title, Slug: note.Slug, Aliases: aliases, Folder: folder})
+		if len(index) == limit {
+			break
+		}
+	}
+	return api.Wrap(index), nil
+}
+
+// apiModified returns the modification date of the note, from its frontmatter like the feed,
+// the file modification time as a fallback
+func apiModified(note model.Note, loc *time.Location) time.Time {
+	if modified := engine.NoteLastModified(note, loc); !modified.IsZero() {
+		return modified
+	}
+	return note.ModTime
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/EwenQuim/pluie/api"
+	"github.com/EwenQuim/pluie/config"
+	"github.com/EwenQuim/pluie/engine"
+	"github.com/EwenQuim/pluie/template"
+
+	"github.com/go-fuego/fuego"
+)
+
+// newAPITestServer loads a vault with a public note linking to another, a private note and a
+// shared folder, and returns its routes
+func newAPITestServer(t *testing.T) *fuego.Server {
+	t.Helper()
+	dir := t.TempDir()
+	writeTestFile(t, dir, "Garden.md", "---\npublish: true\nmodified: 2024-03-10\ntags: [Plants]\n---\n# Garden\n\nTomatoes and [[Bread]].\n")
+	writeTestFile(t, dir, "Recipes/Bread.md", "---\npublish: true\n---\nFlour #baking, back to [[Garden]].\n")
+	writeTestFile(t, dir, "Diary.md", "---\npublish: false\ntags: [secret]\n---\nSecret\n")
+	writeTestFile(t, dir, "Clients/.pluie", "---\naccess: private\n---\n")
+	writeTestFile(t, dir, "Clients/Report.md", "---\npublish: true\n---\nFor Acme only\n")
+	return newAPITestServerOf(t, dir, &config.Config{Path: dir, SiteTitle: "Pluie", ShareKeyFolders: map[string]string{"Clients": "abc123"}, CORSOrigins: "https://editor.example.com"})
+}
+
+// newAPITestServerOf loads the vault of dir with cfg and returns its routes
+func newAPITestServerOf(t *testing.T, dir string, cfg *config.Config) *fuego.Server {
+	t.Helper()
+	notesMap, tree, tagIndex, err := loadNotes(dir, cfg)
+	if err != nil {
+		t.Fatalf("loadNotes() error: %v", err)
+	}
+	server := &Server{NotesService: engine.NewNotesService(notesMap, tree, tagIndex), rs: template.NewResource(cfg), cfg: cfg}
+	fuegoServer := fuego.NewServer()
+	server.registerRoutes(fuegoServer)
+	return fuegoServer
+}
+
+// getAPI requests path and decodes the data of the response envelope in data
+func getAPI(t *testing.T, fuegoServer *fuego.Server, path string, data any) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	fuegoServer.Mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	if w.Code == http.StatusOK {
+		envelope := api.Envelope[any]{Data: data}
+		if err := json.Unmarshal(w.Body.Bytes(), &envelope); err != nil {
+			t.Fatalf("%s: decoding %s: %v", path, w.Body.String(), err)
+		}
+	}
+	return w
+}
+
+func TestAPINotes(t *testing.T) {
+	fuegoServer := newAPITestServer(t)
+
+	var notes api.NoteSummaries
+	if w := getAPI(t, fuegoServer, "/-/api/notes", &notes); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	if len(notes) != 2 || notes[0].Slug != "garden" || notes[1].Slug != "recipes/bread" {
+		t.Fatalf("notes = %+v, want garden and recipes/bread only", notes)
+	}
+	if notes[0].Title != "Garden" || len(notes[0].Tags) != 1 || notes[0].Tags[0] != "plants" {
+		t.Errorf("garden = %+v, want its title and lowercase tag", notes[0])
+	}
+	if got := notes[0].Modified.Format("2006-01-02"); got != "2024-03-10" {
+		t.Errorf("garden modified = %s, want the frontmatter date", got)
+	}
+	if notes[1].Modified.IsZero() {
+		t.Error("bread modified should fall back to the file modification time")
+	}
+}
+
+func TestAPINote(t *testing.T) {
+	fuegoServer := newAPITestServer(t)
+
+	var note api.Note
+	if w := getAPI(t, fuegoServer, "/-/api/notes/recipes/bread", &note); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	if note.Metadata["publish"] != true {
+		t.Errorf("metadata = %v, want the frontmatter", note.Metadata)
+	}
+	if !strings.Contains(note.HTML, `href="/garden"`) || !strings.Contains(note.HTML, "Flour") {
+		t.Errorf("html = %q, want the rendered content with its wikilinks", note.HTML)
+	}
+	if len(note.Backlinks) != 1 || note.Backlinks[0].Slug != "garden" {
+		t.Errorf("backlinks = %+v, want garden", note.Backlinks)
+	}
+
+	for _, path := range []string{"/-/api/notes/diary", "/-/api/notes/missing", "/-/api/notes/clients/report", "/-/api/notes/clients/report?key=wrong"} {
+		w := getAPI(t, fuegoServer, path, nil)
+		if w.Code != http.StatusNotFound || strings.Contains(w.Body.String(), "Secret") || strings.Contains(w.Body.String(), "Acme") {
+			t.Errorf("%s: status = %d, want 404", path, w.Code)
+		}
+	}
+
+	var shared api.Note
+	w := getAPI(t, fuegoServer, "/-/api/notes/clients/report?key=abc123", &shared)
+	if w.Code != http.StatusOK || !strings.Contains(shared.HTML, "For Acme only") {
+		t.Errorf("shared note: status = %d, html = %q, want the note with its key", w.Code, shared.HTML)
+	}
+	if got := w.Header().Get("Cache-Control"); got != "private, no-store" {
+		t.Errorf("shared note Cache-Control = %q, want private, no-store", got)
+	}
+}
+
+func TestAPITreeAndTags(t *testing.T) {
+	fuegoServer := newAPITestServer(t)
+
+	var tree api.Tree
+	if w := getAPI(t, fuegoServer, "/-/api/tree", &tree); w.Code != http.StatusOK {
+		t.Fatalf("tree status = %d, want 200", w.Code)
+	}
+	var entries []string
+	for _, entry := range tree {
+		entries = append(entries, entry.Parent+">"+entry.Path)
+	}
+	if got := strings.Join(entries, ","); got != ">Recipes,Recipes>recipes/bread,>garden" {
+		t.Errorf("tree = %s, want the public notes and their folders only", got)
+	}
+
+	var tags api.Tags
+	if w := getAPI(t, fuegoServer, "/-/api/tags", &tags); w.Code != http.StatusOK {
+		t.Fatalf("tags status = %d, want 200", w.Code)
+	}
+	for _, tag := range tags {
+		if tag.Name == "secret" {
+			t.Errorf("tags = %+v, should not list the tags of private notes", tags)
+		}
+	}
+	if len(tags) != 2 {
+		t.Errorf("tags = %+v, want baking and plants", tags)
+	}
+}
+
+func TestAPIDrafts(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "Garden.md", "---\npublish: true\ntags: [plants]\n---\nTomatoes\n\n![[Post]]\n")
+	writeTestFile(t, dir, "Post.md", "---\npublish: false\ndraft: true\ntags: [plants, wip]\n---\nWork in progress, see [[Garden]].\n")
+	fuegoServer := newAPITestServerOf(t, dir, &config.Config{Path: dir, SiteTitle: "Pluie", Mode: "server", ShowDrafts: true})
+
+	var tags api.Tags
+	if w := getAPI(t, fuegoServer, "/-/api/tags", &tags); w.Code != http.StatusOK {
+		t.Fatalf("tags status = %d, want 200", w.Code)
+	}
+	if len(tags) != 1 || tags[0].Name != "plants" || tags[0].Count != 1 {
+		t.Errorf("tags = %+v, want plants of the garden only", tags)
+	}
+
+	var note api.Note
+	if w := getAPI(t, fuegoServer, "/-/api/notes/garden", &note); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	if len(note.Backlinks) != 0 {
+		t.Errorf("backlinks = %+v, the draft should not be one", note.Backlinks)
+	}
+	if !strings.Contains(note.HTML, "Tomatoes") || strings.Contains(note.HTML, "Work in progress") {
+		t.Errorf("html = %q, want the note without the embedded draft", note.HTML)
+	}
+}
+
+func TestAPICacheHeaders(t *testing.T) {
+	fuegoServer := newAPITestServer(t)
+
+	w := getAPI(t, fuegoServer, "/-/api/tags", nil)
+	etag, lastModified := w.Header().Get("ETag"), w.Header().Get("Last-Modified")
+	if etag == "" || lastModified == "" {
+		t.Fatalf("ETag = %q, Last-Modified = %q, want both", etag, lastModified)
+	}
+
+	for header, value := range map[string]string{"If-None-Match": etag, "If-Modified-Since": lastModified} {
+		r := httptest.NewRequest(http.MethodGet, "/-/api/notes", nil)
+		r.Header.Set(header, value)
+		w := httptest.NewRecorder()
+		fuegoServer.Mux.ServeHTTP(w, r)
+		if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+			t.Errorf("%s: status = %d, want 304 without body", header, w.Code)
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/-/api/notes", nil)
+	r.Header.Set("If-None-Match", `"stale"`)
+	w = httptest.NewRecorder()
+	fuegoServer.Mux.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("stale ETag: status = %d, want 200", w.Code)
+	}
+
+	if fuegoServer.OpenAPI.Description().Paths.Find("/-/api/tree") == nil {
+		t.Error("the API routes should be in the OpenAPI spec")
+	}
+}
+
+func TestAPILinkIndex(t *testing.T) {
+	fuegoServer := newAPITestServer(t)
+
+	var index api.LinkIndex
+	if w := getAPI(t, fuegoServer, "/-/api/link-index", &index); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	var slugs []string
+	for _, target := range index {
+		slugs = append(slugs, target.Slug)
+	}
+	if got := strings.Join(slugs, ","); got != "garden,recipes/bread" {
+		t.Errorf("link index = %s, want the public notes only", got)
+	}
+	if index[1].Title != "Bread" || index[1].Folder != "Recipes" || index[1].Aliases == nil || index[0].Folder != "" {
+		t.Errorf("link index = %+v, want the titles, folders and aliases", index)
+	}
+
+	// The filter matches the titles, then the slugs
+	for query, expected := range map[string]string{"gar": "garden", "BRE": "recipes/bread", "recipes": "recipes/bread", "diary": "", "report": ""} {
+		var matches api.LinkIndex
+		if w := getAPI(t, fuegoServer, "/-/api/link-index?q="+query, &matches); w.Code != http.StatusOK {
+			t.Fatalf("q=%s: status = %d, want 200", query, w.Code)
+		}
+		var got []string
+		for _, target := range matches {
+			got = append(got, target.Slug)
+		}
+		if strings.Join(got, ",") != expected {
+			t.Errorf("q=%s: link index = %v, want %q", query, got, expected)
+		}
+	}
+
+	var limited api.LinkIndex
+	if getAPI(t, fuegoServer, "/-/api/link-index?limit=1", &limited); len(limited) != 1 || limited[0].Slug != "garden" {
+		t.Errorf("limit=1: link index = %+v, want garden only", limited)
+	}
+
+	if fuegoServer.OpenAPI.Description().Paths.Find("/-/api/link-index") == nil {
+		t.Error("the link index should be in the OpenAPI spec")
+	}
+}
+
+func TestAPILinkIndex_LimitCap(t *testing.T) {
+	dir := t.TempDir()
+	for i := range linkIndexMaxLimit + 10 {
+		writeTestFile(t, dir, fmt.Sprintf("Note %03d.md", i), "Content")
+	}
+	fuegoServer := newAPITestServerOf(t, dir, &config.Config{Path: dir, PublicByDefault: true})
+
+	for path, expected := range map[string]int{
+		"/-/api/link-index":             linkIndexDefaultLimit,
+		"/-/api/link-index?limit=0":     linkIndexDefaultLimit,
+		"/-/api/link-index?limit=250":   250,
+		"/-/api/link-index?limit=10000": linkIndexMaxLimit,
+	} {
+		var index api.LinkIndex
+		if w := getAPI(t, fuegoServer, path, &index); w.Code != http.StatusOK || len(index) != expected {
+			t.Errorf("%s: status = %d with %d notes, want %d", path, w.Code, len(index), expected)
+		}
+	}
+}
+
+func TestAPICORS(t *testing.T) {
+	fuegoServer := newAPITestServer(t)
+
+	request := func(method, origin string, headers ...string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/-/api/link-index?q=gar", nil)
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+		for i := 0; i+1 < len(headers); i += 2 {
+			r.Header.Set(headers[i], headers[i+1])
+		}
+		w := httptest.NewRecorder()
+		fuegoServer.Mux.ServeHTTP(w, r)
+		return w
+	}
+
+	w := request(http.MethodGet, "https://editor.example.com")
+	if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "https://editor.example.com" || !strings.Contains(w.Header().Get("Access-Control-Expose-Headers"), "ETag") {
+		t.Errorf("allowed origin: status = %d, headers = %v, want the CORS headers", w.Code, w.Header())
+	}
+
+	// Revalidations are readable by the allowed origins too
+	if w := request(http.MethodGet, "https://editor.example.com", "If-None-Match", w.Header().Get("ETag")); w.Code != http.StatusNotModified || w.Header().Get("Access-Control-Allow-Origin") == "" {
+		t.Errorf("revalidation: status = %d, headers = %v, want 304 with the CORS headers", w.Code, w.Header())
+	}
+
+	if w := request(http.MethodGet, "https://evil.example.com"); w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("other origin: headers = %v, want no CORS header", w.Header())
+	}
+
+	preflight := request(http.MethodOptions, "https://editor.example.com", "Access-Control-Request-Method", "GET", "Access-Control-Request-Headers", "authorization")
+	if preflight.Code != http.StatusNoContent || !strings.Contains(preflight.Header().Get("Access-Control-Allow-Headers"), "Authorization") {
+		t.Errorf("preflight: status = %d, headers = %v, want 204 allowing the Authorization header", preflight.Code, preflight.Header())
+	}
+	if w := request(http.MethodOptions, "https://evil.example.com"); w.Code == http.StatusNoContent {
+		t.Error("the preflight of another origin should not succeed")
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os/exec"
+	"path"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/EwenQuim/pluie/model"
+)
+
+// previewHomeFiles are the file names picked as home note in preview mode, by priority
+var previewHomeFiles = []string{"readme.md", "index.md"}
+
+// forcePublicNotes marks every note as public, whatever its frontmatter says
+func forcePublicNotes(notes []model.Note) []model.Note {
+	for i := range notes {
+		notes[i].IsPublic = true
+	}
+	return notes
+}
+
+// previewHomeSlug returns the slug of the README.md or index.md closest to the root.
+// Returns an empty string when there is none, so the first note is used instead.
+func previewHomeSlug(notes []model.Note) string {
+	var best *model.Note
+	bestDepth, bestPriority := 0, 0
+
+	for i, note := range notes {
+		priority := slices.Index(previewHomeFiles, strings.ToLower(path.Base(note.Path)))
+		if priority < 0 {
+			continue
+		}
+		depth := strings.Count(note.Path, "/")
+
+		if best == nil ||
+			depth < bestDepth ||
+			(depth == bestDepth && priority < bestPriority) ||
+			(depth == bestDepth && priority == bestPriority && note.Path < best.Path) {
+			best = &notes[i]
+			bestDepth, bestPriority = depth, priority
+		}
+	}
+
+	if best == nil {
+		return ""
+	}
+	return best.Slug
+}
+
+// findListenPort returns the preferred port if it is free, or any free port otherwise
+func findListenPort(preferred string) (string, error) {
+	for _, candidate := range []string{preferred, "0"} {
+		listener, err := net.Listen("tcp", ":"+candidate)
+		if err != nil {
+			continue
+		}
+		port := listener.Addr().(*net.TCPAddr).Port
+		if err := listener.Close(); err != nil {
+			return "", err
+		}
+		return fmt.Sprint(port), nil
+	}
+	return "", errors.New("no free port available")
+}
+
+// openBrowserWhenReady waits for the server to accept connections, then opens the URL in the browser
+func openBrowserWhenReady(ctx context.Context, url, port string) {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if ctx.Err() != nil {
+			return
+		}
+		conn, err := net.DialTimeout("tcp", "127.0.0.1:"+port, 200*time.Millisecond)
+		if err == nil {
+			_ = conn.Close()
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	if err := openBrowser(url); err != nil {
+		slog.Warn("Could not open the browser, open the URL manually", "url", url, "error", err)
+	}
+}
+
+// openBrowser opens the URL with the default browser of the OS
+func openBrowser(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	// Don't leave a zombie process behind
+	go func() { _ = cmd.Wait() }()
+	return nil
+}
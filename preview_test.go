@@ -0,0 +1,136 @@
+package main
+
+import (
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/EwenQuim/pluie/config"
+	"github.com/EwenQuim/pluie/model"
+)
+
+func TestLoadNotes_ForcePublic(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "private.md", "---\npublic: false\n---\n# Private\n")
+	writeTestFile(t, dir, "plain.md", "# Plain markdown, no frontmatter\n")
+	writeTestFile(t, dir, "secret/.pluie", "---\npublic: false\n---\n")
+	writeTestFile(t, dir, "secret/inner.md", "Inner note")
+
+	t.Run("private notes are filtered out by default", func(t *testing.T) {
+		notesMap, _, _, err := loadNotes(dir, &config.Config{})
+		if err != nil {
+			t.Fatalf("loadNotes error: %v", err)
+		}
+		if len(*notesMap) != 0 {
+			t.Errorf("expected no public notes, got %d", len(*notesMap))
+		}
+	})
+
+	t.Run("force public keeps every note", func(t *testing.T) {
+		notesMap, _, _, err := loadNotes(dir, &config.Config{PublicByDefault: true, ForcePublic: true})
+		if err != nil {
+			t.Fatalf("loadNotes error: %v", err)
+		}
+		if len(*notesMap) != 3 {
+			t.Fatalf("expected 3 notes, got %d", len(*notesMap))
+		}
+		for slug, note := range *notesMap {
+			if !note.IsPublic {
+				t.Errorf("note %q is not public", slug)
+			}
+		}
+	})
+}
+
+func TestForcePublicNotes(t *testing.T) {
+	notes := forcePublicNotes([]model.Note{
+		{Slug: "a", IsPublic: false},
+		{Slug: "b", IsPublic: true},
+	})
+
+	for _, note := range notes {
+		if !note.IsPublic {
+			t.Errorf("note %q is not public", note.Slug)
+		}
+	}
+}
+
+func TestPreviewHomeSlug(t *testing.T) {
+	tests := []struct {
+		name     string
+		notes    []model.Note
+		expected string
+	}{
+		{
+			name: "README at the root",
+			notes: []model.Note{
+				{Slug: "guide", Path: "guide.md"},
+				{Slug: "readme", Path: "README.md"},
+			},
+			expected: "readme",
+		},
+		{
+			name: "README preferred over index at the same depth",
+			notes: []model.Note{
+				{Slug: "index", Path: "index.md"},
+				{Slug: "readme", Path: "readme.md"},
+			},
+			expected: "readme",
+		},
+		{
+			name: "Shallowest file wins",
+			notes: []model.Note{
+				{Slug: "docs/readme", Path: "docs/README.md"},
+				{Slug: "index", Path: "index.md"},
+			},
+			expected: "index",
+		},
+		{
+			name: "Alphabetical order between folders of the same depth",
+			notes: []model.Note{
+				{Slug: "b/readme", Path: "b/README.md"},
+				{Slug: "a/readme", Path: "a/README.md"},
+			},
+			expected: "a/readme",
+		},
+		{
+			name: "No README nor index falls back to the default home",
+			notes: []model.Note{
+				{Slug: "notes", Path: "notes.md"},
+				{Slug: "readme-draft", Path: "readme-draft.md"},
+			},
+			expected: "",
+		},
+		{
+			name:     "No notes",
+			notes:    nil,
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := previewHomeSlug(tt.notes); got != tt.expected {
+				t.Errorf("previewHomeSlug() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFindListenPort(t *testing.T) {
+	// Occupy a port so it must be skipped
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	busy := listener.Addr().(*net.TCPAddr).Port
+
+	port, err := findListenPort(strconv.Itoa(busy))
+	if err != nil {
+		t.Fatalf("findListenPort error: %v", err)
+	}
+	if port == strconv.Itoa(busy) || port == "0" || port == "" {
+		t.Errorf("findListenPort() = %q, expected another free port than %d", port, busy)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/EwenQuim/pluie/api"
+	"github.com/EwenQuim/pluie/config"
+
+	"github.com/go-fuego/fuego"
+)
+
+// errReloadInProgress is returned by reloadNotes when another reload is running
+var errReloadInProgress = errors.New("a reload is already in progress")
+
+// reloadNotes loads the notes of the vault at basePath again and swaps them in. The watcher and
+// POST /-/reload both go through it, so only one reload runs at a time: with wait, it waits for
+// the running one, otherwise it returns errReloadInProgress.
+func (s *Server) reloadNotes(basePath string, cfg *config.Config, wait bool) (api.Reload, error) {
+	if wait {
+		s.reloadMu.Lock()
+	} else if !s.reloadMu.TryLock() {
+		return api.Reload{}, errReloadInProgress
+	}
+	defer s.reloadMu.Unlock()
+
+	start := time.Now()
+	notesMap, tree, tagIndex, privateSlugs, err := loadNotesWithPrivateSlugs(basePath, cfg)
+	if err != nil {
+		return api.Reload{}, err
+	}
+	s.UpdateData(notesMap, tree, tagIndex)
+	s.NotesService.SetPrivateSlugs(privateSlugs)
+
+	reload := api.Reload{
+		Notes:      len(s.NotesService.GetNotesMap()),
+		DurationMS: time.Since(start).Milliseconds(),
+		ReloadedAt: s.NotesService.LoadedAt(),
+	}
+	s.lastReload.Store(&reload)
+	return reload, nil
+}
+
+// postReload reloads the notes, for vaults updated by a git pull or a sync the watcher may miss
+func (s *Server) postReload(c fuego.ContextNoBody) (api.Envelope[api.Reload], error) {
+	if !s.validReloadToken(c.Header("Authorization")) {
+		return api.Envelope[api.Reload]{}, fuego.UnauthorizedError{Detail: "missing or wrong reload token"}
+	}
+
+	reload, err := s.reloadNotes(s.cfg.Path, s.cfg, false)
+	if errors.Is(err, errReloadInProgress) {
+		return api.Envelope[api.Reload]{}, fuego.ConflictError{Title: "Conflict", Detail: err.Error()}
+	}
+	if err != nil {
+		slog.Error("Error reloading notes", "error", err, "trigger", "webhook")
+		return api.Envelope[api.Reload]{}, fuego.InternalServerError{Detail: "reloading the notes failed, see the server logs"}
+	}
+
+	slog.Info("Notes reloaded", "trigger", "webhook", "notes", reload.Notes, "in", time.Duration(reload.DurationMS)*time.Millisecond)
+	return api.Wrap(reload), nil
+}
+
+// getReload returns the last successful reload, the startup load before the first one
+func (s *Server) getReload(c fuego.ContextNoBody) (api.Envelope[api.Reload], error) {
+	if !s.validReloadToken(c.Header("Authorization")) {
+		return api.Envelope[api.Reload]{}, fuego.UnauthorizedError{Detail: "missing or wrong reload token"}
+	}
+
+	if last := s.lastReload.Load(); last != nil {
+		return api.Wrap(*last), nil
+	}
+	return api.Wrap(api.Reload{
+		Notes:      len(s.NotesService.GetNotesMap()),
+		ReloadedAt: s.NotesService.LoadedAt(),
+	}), nil
+}
+
+// validReloadToken reports whether the Authorization header carries RELOAD_TOKEN
+func (s *Server) validReloadToken(authorization string) bool {
+	token, ok := strings.CutPrefix(authorization, "Bearer ")
+	return ok && constantTimeEqual(token, s.cfg.ReloadToken)
+}
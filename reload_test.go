@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/EwenQuim/pluie/api"
+	"github.com/EwenQuim/pluie/config"
+	"github.com/EwenQuim/pluie/engine"
+	"github.com/EwenQuim/pluie/model"
+	"github.com/EwenQuim/pluie/template"
+
+	"github.com/go-fuego/fuego"
+)
+
+func TestReloadEndpoint(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "First.md", "First note\n")
+
+	cfg := &config.Config{Path: dir, SiteTitle: "Pluie", PublicByDefault: true, ReloadToken: "secret"}
+	notesMap, tree, tagIndex, err := loadNotes(dir, cfg)
+	if err != nil {
+		t.Fatalf("loadNotes() error: %v", err)
+	}
+	server := &Server{NotesService: engine.NewNotesService(notesMap, tree, tagIndex), rs: template.NewResource(cfg), cfg: cfg}
+	fuegoServer := fuego.NewServer()
+	server.registerRoutes(fuegoServer)
+
+	serve := func(method, token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/-/reload", nil)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		fuegoServer.Mux.ServeHTTP(w, r)
+		return w
+	}
+	decode := func(w *httptest.ResponseRecorder) api.Reload {
+		t.Helper()
+		var envelope api.Envelope[api.Reload]
+		if err := json.Unmarshal(w.Body.Bytes(), &envelope); err != nil {
+			t.Fatalf("decoding %s: %v", w.Body.String(), err)
+		}
+		return envelope.Data
+	}
+
+	for _, method := range []string{http.MethodPost, http.MethodGet} {
+		for _, token := range []string{"", "wrong"} {
+			if w := serve(method, token); w.Code != http.StatusUnauthorized {
+				t.Errorf("%s with token %q: status = %d, want 401", method, token, w.Code)
+			}
+		}
+	}
+
+	startup := decode(serve(http.MethodGet, "secret"))
+	if startup.Notes != 1 || !startup.ReloadedAt.Equal(server.NotesService.LoadedAt()) {
+		t.Errorf("GET before any reload = %+v, want the startup load", startup)
+	}
+
+	writeTestFile(t, dir, "Second.md", "Second note\n")
+	w := serve(http.MethodPost, "secret")
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST status = %d, want 200: %s", w.Code, w.Body.String())
+	}
+	reload := decode(w)
+	if reload.Notes != 2 {
+		t.Errorf("POST notes = %d, want 2", reload.Notes)
+	}
+	if _, ok := server.NotesService.GetNote("second"); !ok {
+		t.Error("the new note should be served after the reload")
+	}
+	if last := decode(serve(http.MethodGet, "secret")); last != reload {
+		t.Errorf("GET = %+v, want the last reload %+v", last, reload)
+	}
+
+	// A reload already running, like one of the watcher, makes POST answer 409
+	server.reloadMu.Lock()
+	w = serve(http.MethodPost, "secret")
+	server.reloadMu.Unlock()
+	if w.Code != http.StatusConflict {
+		t.Errorf("POST during a reload: status = %d, want 409", w.Code)
+	}
+}
+
+func TestReloadEndpoint_DisabledWithoutToken(t *testing.T) {
+	cfg := &config.Config{Path: t.TempDir(), SiteTitle: "Pluie"}
+	server := &Server{NotesService: engine.NewNotesService(&map[string]model.Note{}, nil, nil), rs: template.NewResource(cfg), cfg: cfg}
+	fuegoServer := fuego.NewServer()
+	server.registerRoutes(fuegoServer)
+
+	w := httptest.NewRecorder()
+	fuegoServer.Mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/-/reload", nil))
+	if w.Code == http.StatusOK || w.Code == http.StatusUnauthorized {
+		t.Errorf("POST /-/reload without RELOAD_TOKEN: status = %d, want no route", w.Code)
+	}
+}
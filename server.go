@@ -1,39 +1,76 @@
 package main
 
 import (
+	"bytes"
+	"cmp"
 	"context"
-	"fmt"
+	"encoding/json"
+	"errors"
+	"io"
 	"log/slog"
+	"mime"
+	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
+	"github.com/EwenQuim/pluie/ai"
+	"github.com/EwenQuim/pluie/api"
 	"github.com/EwenQuim/pluie/config"
 	"github.com/EwenQuim/pluie/engine"
+	"github.com/EwenQuim/pluie/metrics"
 	"github.com/EwenQuim/pluie/model"
 	"github.com/EwenQuim/pluie/static"
 	"github.com/EwenQuim/pluie/template"
 	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/schema"
 
 	"github.com/go-fuego/fuego"
 	"github.com/go-fuego/fuego/option"
+	"github.com/go-fuego/fuego/param"
 )
 
-type HealthResponse struct {
-	Status string `json:"status"`
-}
-
 type Server struct {
 	NotesService      *engine.NotesService
 	rs                template.Resource
 	cfg               *config.Config
-	chatClient        llms.Model         // Chat client for AI responses
+	chatChain         *ChatChain         // Chat providers for AI responses, in failover order
 	embeddingsManager *EmbeddingsManager // Manages all embeddings functionality
+	trash             *Trash             // Recently deleted notes, nil when disabled
+	comments          CommentStore       // Comments of the notes, nil without COMMENTS_ENABLED
+	commentLimiter    *rateLimiter       // Comments accepted per IP address
+	views             *ViewCounter       // Views of the notes, nil counts nothing
+	liveReload        *LiveReload        // Reloads of the notes pushed to the browsers, nil without WATCH
+	weaviatePing      cachedCheck        // Last Weaviate ping of GET /-/readyz
+
+	shuttingDown chan struct{} // Closed when the shutdown begins, nil before Start
+	stopped      chan error    // Why the server started by Start stopped, see Wait
+
+	reloadMu   sync.Mutex                 // Held during a reload of the notes, see reloadNotes
+	lastReload atomic.Pointer[api.Reload] // Last successful reload, nil before the first one
+
+	promptMu     sync.Mutex         // Guards prompt and promptSource
+	prompt       *ai.PromptTemplate // Last parsed prompt template, see promptTemplate
+	promptSource string             // Template and system prompt prompt was parsed from
 }
 
-// UpdateData safely updates the server's NotesMap, Tree, and TagIndex with new data
+// UpdateData safely updates the server's NotesMap, Tree, and TagIndex with new data.
+// Notes whose file was deleted since the previous data go to the trash, and the open pages
+// are told to refresh.
 func (s *Server) UpdateData(notesMap *map[string]model.Note, tree *engine.TreeNode, tagIndex engine.TagIndex) {
+	previous := s.NotesService.GetNotesMap()
 	s.NotesService.UpdateData(notesMap, tree, tagIndex)
+	s.trash.Capture(previous, *notesMap)
+	s.liveReload.Notify()
 }
 
 func (s *Server) registerRoutes(server *fuego.Server) {
@@ -41,32 +78,194 @@ func (s *Server) registerRoutes(server *fuego.Server) {
 	server.Mux.Handle("GET /static/", http.StripPrefix("/static", static.Handler()))
 
 	// Health check endpoint for Docker/K8s probes
-	fuego.Get(server, "/-/health", func(c fuego.ContextNoBody) (HealthResponse, error) {
-		return HealthResponse{Status: "ok"}, nil
+	fuego.Get(server, "/-/health", func(c fuego.ContextNoBody) (api.Envelope[api.Health], error) {
+		return api.Wrap(api.Health{Status: "ok"}), nil
 	}, option.Summary("health"), option.Tags("Health"))
 
+	// Liveness and readiness probes of load balancers, the readiness with the state of the dependencies
+	fuego.Get(server, "/-/healthz", s.getHealthz, option.Summary("liveness"), option.Tags("Health"))
+	fuego.Get(server, "/-/readyz", s.getReadyz, option.Summary("readiness"), option.Tags("Health"))
+
+	// Login page of the site credential
+	if s.cfg.AuthEnabled() {
+		server.Mux.HandleFunc("GET /-/login", s.getLogin)
+		server.Mux.HandleFunc("POST /-/login", s.postLogin)
+	}
+
+	// Prometheus metrics
+	if s.cfg.MetricsEnabled {
+		server.Mux.Handle("GET /-/metrics", metrics.Default.Handler(s.cfg.MetricsToken))
+	}
+
 	// Unified search route - must be registered before the catch-all route
 	fuego.Get(server, "/-/search", s.getUnifiedSearch,
 		option.Query("q", "Search query for unified search (title, heading, semantic, AI)"),
+		option.Query("mode", "Search mode: phrase or regex, like the \"quotes\" and re: query syntax"),
+	)
+
+	// Matches of the sidebar search as you type, as HTML - must be registered before the catch-all route
+	fuego.Get(server, "/-/quick-search", s.getQuickSearch,
+		option.Query("q", "Search query over note titles and headings"),
+	)
+
+	// Quick switcher matches, as JSON - must be registered before the catch-all route
+	fuego.Get(server, "/-/switcher", s.getSwitcher,
+		option.Query("q", "Prefix, word initials (\"mtg ac\") or fuzzy query over note titles, aliases and headings"),
+		option.Summary("switcher"), option.Tags("Search"),
 	)
 
 	// Unified search SSE stream route
 	fuego.GetStd(server, "/-/search-stream", s.getUnifiedSearchStream)
 
+	// Follow-up questions of the AI summary, answered as an SSE stream
+	fuego.PostStd(server, "/-/search-chat", s.postSearchChat)
+
 	// Embedding progress SSE route
 	fuego.GetStd(server, "/-/embedding-progress", s.getEmbeddingProgress)
 
-	// Tag route - must be registered before the catch-all route
-	fuego.Get(server, "/-/tag/{tag...}", s.getTag)
+	// Live reload of the open pages when the watcher reloads the notes
+	if s.liveReload != nil {
+		fuego.GetStd(server, "/-/live-reload", s.getLiveReload)
+	}
+
+	// Embedding pass controls, only available with a token
+	if s.cfg.EmbeddingsToken != "" {
+		fuego.Post(server, "/-/embeddings/pause", s.embeddingsControl((*EmbeddingsManager).Pause),
+			option.Header("Authorization", "Bearer <EMBEDDINGS_TOKEN>"),
+			option.Summary("pause embeddings"), option.Tags("Embeddings"),
+		)
+		fuego.Post(server, "/-/embeddings/resume", s.embeddingsControl((*EmbeddingsManager).Resume),
+			option.Header("Authorization", "Bearer <EMBEDDINGS_TOKEN>"),
+			option.Summary("resume embeddings"), option.Tags("Embeddings"),
+		)
+		fuego.Post(server, "/-/embeddings/reembed", s.postReembed,
+			option.Header("Authorization", "Bearer <EMBEDDINGS_TOKEN>"),
+			option.Summary("embed every note again"), option.Tags("Embeddings"),
+		)
+	}
+
+	// Reload of the notes from the vault, only available with a token
+	if s.cfg.ReloadToken != "" {
+		fuego.Post(server, "/-/reload", s.postReload,
+			option.Header("Authorization", "Bearer <RELOAD_TOKEN>"),
+			option.Summary("reload notes"), option.Tags("Reload"),
+		)
+		fuego.Get(server, "/-/reload", s.getReload,
+			option.Header("Authorization", "Bearer <RELOAD_TOKEN>"),
+			option.Summary("last reload"), option.Tags("Reload"),
+		)
+	}
+
+	// Flashcards CSV of the tagged notes, only available with a token
+	if s.cfg.FlashcardsToken != "" {
+		server.Mux.HandleFunc("GET /-/export/flashcards.csv", s.getFlashcardsExport)
+	}
+
+	// Sitemap of the public notes and tags, for search engines
+	fuego.GetStd(server, "/sitemap.xml", s.getSitemap, option.Summary("sitemap"), option.Tags("SEO"))
+	fuego.GetStd(server, "/robots.txt", s.getRobots, option.Summary("robots"), option.Tags("SEO"))
+
+	// Attachments embedded by the public notes - must be registered before the catch-all route
+	fuego.GetStd(server, engine.AttachmentsPrefix+"{path...}", s.getAttachment, option.Summary("attachment"))
+
+	// Read-only JSON API of the public notes, cached until the next reload - must be registered before the catch-all route
+	apiOptions := option.Group(option.Tags("API"), option.Middleware(s.apiCORSHeaders, s.apiCacheHeaders))
+	fuego.Get(server, "/-/api/notes", s.getAPINotes, option.Summary("list notes"), apiOptions)
+	fuego.Get(server, "/-/api/notes/{slug...}", s.getAPINote, option.Summary("get note"), apiOptions)
+	fuego.Get(server, "/-/api/tree", s.getAPITree, option.Summary("notes tree"), apiOptions)
+	fuego.Get(server, "/-/api/tags", s.getAPITags, option.Summary("list tags"), apiOptions)
+	fuego.Get(server, "/-/api/link-index", s.getAPILinkIndex,
+		option.Query("q", "Filter over the note titles, then their slugs, like the sidebar search"),
+		option.QueryInt("limit", "Notes listed at most, capped at 500", param.Default(linkIndexDefaultLimit)),
+		option.Summary("link index"), apiOptions,
+	)
+	// CORS preflight requests of the browser tools calling the API
+	fuego.OptionsStd(server, "/-/api/{path...}", http.NotFound, option.Hide(), option.Middleware(s.apiCORSHeaders))
 
+	// Markdown file of the notes - must be registered before the catch-all route
+	fuego.GetStd(server, "/-/raw/{slug...}", s.getRawNote, option.Summary("raw note"), option.Tags("Notes"))
+
+	// RSS feed of the recently modified notes - must be registered before the catch-all route
+	fuego.GetStd(server, "/-/feed.xml", s.getFeed, option.Summary("feed"), option.Tags("SEO"))
+
+	// Graph of the notes and their wikilinks, as JSON and as a page - must be registered before the catch-all route
+	fuego.Get(server, "/-/graph.json", s.getGraphJSON, option.Summary("graph"), option.Tags("Graph"))
+	fuego.Get(server, "/-/graph", s.getGraph)
+
+	// Vault diagnostics (duplicates...) - must be registered before the catch-all route
+	fuego.Get(server, "/-/diagnostics", s.getDiagnostics)
+
+	// Notes by lifecycle status - must be registered before the catch-all route
+	fuego.Get(server, "/-/status", s.getStatusOverview,
+		option.Query("oldest", "Comma-separated statuses whose column is sorted oldest modified first"),
+	)
+
+	// Redirect to a random note - must be registered before the catch-all route
+	fuego.Get(server, "/-/random", s.getRandomNote,
+		option.Query("tag", "Pick among the notes with this tag"),
+	)
+
+	// Unlinked mentions of a note, loaded by the note page - must be registered before the catch-all route
+	fuego.Get(server, "/-/mentions/{slug...}", s.getUnlinkedMentions)
+
+	// Contents of a folder of the sidebar, loaded when opened with SIDEBAR_LAZY - must be registered before the catch-all route
+	fuego.Get(server, "/-/tree", s.getTreeFolder,
+		option.Query("path", "Path of the folder in the vault, like Projects/Pluie"),
+	)
+
+	// Comments of the notes, listed and deleted by the admin with the site credential - must be registered before the catch-all route
+	if s.comments != nil {
+		server.Mux.HandleFunc("GET /-/comments/{slug...}", s.getComments)
+		server.Mux.HandleFunc("POST /-/comments/{slug...}", s.postComment)
+		server.Mux.HandleFunc("DELETE /-/comments/{slug...}", s.deleteComment)
+		server.Mux.HandleFunc("GET /-/comments", s.getCommentsAdmin)
+	}
+
+	// History of a note and diffs with its past versions, when the vault is a git repository - must be registered before the catch-all route
+	fuego.Get(server, "/-/history/{slug...}", s.getNoteHistory)
+	fuego.Get(server, "/-/diff/{slug...}", s.getNoteDiff,
+		option.Query("rev", "Hash of the commit to compare with the current note"),
+		option.Query("view", "split for a side by side diff, unified otherwise"),
+	)
+
+	// View counts of the notes - must be registered before the catch-all route
+	fuego.Get(server, "/-/stats", s.getViewStats)
+
+	// Open tasks of the notes - must be registered before the catch-all route
+	fuego.Get(server, "/-/tasks", s.getTasks)
+
+	// Tag cloud, also at /-/tag/, and tag pages - must be registered before the catch-all route
+	fuego.Get(server, "/-/tags", s.getTags)
+	fuego.Get(server, "/-/tag/{tag...}", s.getTag,
+		option.QueryBool("exact", "Only the notes with the tag, not the ones of its nested tags"),
+	)
+
+	// Also serves /{slug}/embed, slugs can contain slashes
 	fuego.Get(server, "/{slug...}", s.getNote,
 		option.Query("search", "Search query to filter notes by title"),
+		option.Query("heading", "Embed only: show only the section under this heading"),
+		option.Query("target", "Embed only: where links open, _top or _blank"),
+		option.Query("print", "1 for the print view of the note"),
 	)
 }
 
-func (s *Server) Start(ctx context.Context) error {
+// Start listens on the port of the configuration and serves in the background until SIGINT,
+// SIGTERM or the end of ctx, then shuts down gracefully, see shutdown. Wait returns once it did.
+// The server is returned so the caller can stop its own work along with it.
+func (s *Server) Start(ctx context.Context) (*fuego.Server, error) {
+	listener, err := net.Listen("tcp", ":"+s.cfg.Port)
+	if err != nil {
+		return nil, err
+	}
+
+	middlewares := []func(http.Handler) http.Handler{metricsMiddleware, accessLogMiddleware, compressionMiddleware, securityHeadersMiddleware}
+	if s.cfg.AuthEnabled() {
+		middlewares = append(middlewares, s.authMiddleware)
+	}
+
 	server := fuego.NewServer(
-		fuego.WithAddr(":"+s.cfg.Port),
+		fuego.WithListener(listener),
+		fuego.WithGlobalMiddlewares(middlewares...),
 		fuego.WithEngineOptions(
 			fuego.WithOpenAPIConfig(fuego.OpenAPIConfig{
 				DisableLocalSave: true,
@@ -76,27 +275,47 @@ func (s *Server) Start(ctx context.Context) error {
 
 	s.registerRoutes(server)
 
-	// Start server in a goroutine so we can listen for shutdown signal
-	errCh := make(chan error, 1)
+	// The open SSE streams are told to reconnect as soon as the shutdown begins
+	s.shuttingDown = make(chan struct{})
+	server.RegisterOnShutdown(func() { close(s.shuttingDown) })
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	served := make(chan error, 1)
 	go func() {
-		errCh <- server.Run()
+		served <- server.Run()
 	}()
 
-	// Wait for shutdown signal or server error
-	select {
-	case <-ctx.Done():
-		slog.Info("Shutdown signal received, draining connections...")
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
-		if err := server.Shutdown(shutdownCtx); err != nil {
-			slog.Error("Server shutdown error", "error", err)
-			return err
+	s.stopped = make(chan error, 1)
+	go func() {
+		defer stop()
+		select {
+		case <-ctx.Done():
+			s.stopped <- s.shutdown(server)
+		case err := <-served:
+			s.stopped <- err
 		}
-		slog.Info("Server shut down gracefully")
-		return nil
-	case err := <-errCh:
+	}()
+	return server, nil
+}
+
+// Wait blocks until the server started by Start stopped, returning why unless it shut down
+// gracefully
+func (s *Server) Wait() error {
+	return <-s.stopped
+}
+
+// shutdown stops accepting connections, sends a shutdown event to the open SSE streams so their
+// clients reconnect later, and waits for the running requests for SHUTDOWN_TIMEOUT_SECONDS
+func (s *Server) shutdown(server *fuego.Server) error {
+	slog.Info("Shutdown signal received, draining connections...", "timeout", s.cfg.ShutdownTimeout())
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.cfg.ShutdownTimeout())
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Server shutdown error", "error", err)
 		return err
 	}
+	slog.Info("Server shut down gracefully")
+	return nil
 }
 
 func (s *Server) getNote(ctx fuego.ContextNoBody) (fuego.Renderer, error) {
@@ -109,6 +328,43 @@ func (s *Server) getNote(ctx fuego.ContextNoBody) (fuego.Renderer, error) {
 
 	note, ok := s.NotesService.GetNote(slug)
 	if !ok {
+		if target, moved := s.legacySlugURL(slug); moved {
+			target.RawQuery = ctx.Request().URL.RawQuery
+			slog.Info("Redirecting v1 slug", "slug", slug, "to", target.Path)
+			_, err := ctx.Redirect(http.StatusMovedPermanently, target.String())
+			return nil, err
+		}
+		if current, isAlias := s.NotesService.AliasSlug(slug); isAlias {
+			target := url.URL{Path: "/" + current, RawQuery: ctx.Request().URL.RawQuery}
+			slog.Info("Redirecting alias", "slug", slug, "to", target.Path)
+			_, err := ctx.Redirect(http.StatusMovedPermanently, target.String())
+			return nil, err
+		}
+	}
+	if noteSlug, isEmbed := strings.CutSuffix(slug, "/embed"); !ok && isEmbed {
+		return s.getNoteEmbed(ctx, noteSlug)
+	}
+	if !ok {
+		if shared, unlocked := s.sharedNote(ctx.Response(), ctx.Request(), slug); unlocked {
+			if ctx.QueryParam("print") == "1" {
+				return s.rs.NotePrintView(s.NotesService, &shared)
+			}
+			return s.rs.NoteWithList(s.NotesService, &shared, searchQuery)
+		}
+		if folder := s.NotesService.GetFolder(slug); folder != nil {
+			if subfolders, notes := engine.FolderContents(folder, s.cfg.PublicByDefault); len(subfolders) > 0 || len(notes) > 0 {
+				return s.rs.FolderIndex(s.NotesService, folder, subfolders, notes, searchQuery)
+			}
+		}
+		if trashed, inTrash := s.trash.Get(slug); inTrash {
+			slog.Info("Serving deleted note", "slug", slug)
+			return s.rs.DeletedNote(s.NotesService, trashed, searchQuery)
+		}
+		ctx.SetStatus(http.StatusNotFound)
+		if s.NotesService.IsPrivateNote(slug) {
+			slog.Info("Private note access denied", "slug", slug)
+			return s.rs.PrivateNote(s.NotesService, searchQuery)
+		}
 		slog.Info("Note not found", "slug", slug)
 		return s.rs.NoteWithList(s.NotesService, nil, searchQuery)
 	}
@@ -116,31 +372,351 @@ func (s *Server) getNote(ctx fuego.ContextNoBody) (fuego.Renderer, error) {
 	// Additional security check: ensure note is public
 	if !s.cfg.PublicByDefault && !note.IsPublic {
 		slog.Info("Private note access denied", "slug", slug)
-		return s.rs.NoteWithList(s.NotesService, nil, searchQuery)
+		ctx.SetStatus(http.StatusNotFound)
+		return s.rs.PrivateNote(s.NotesService, searchQuery)
 	}
 
+	s.views.Record(ctx.Request(), note.Slug)
+	if s.cfg.ShowViewCount {
+		note.ViewCount = s.views.Count(note.Slug)
+	}
+
+	// Revalidated on each visit, a 304 when the page did not change since the last one
+	loadedAt := s.NotesService.LoadedAt()
+	etag := noteETag(note, loadedAt, ctx.Request().URL.RawQuery)
+	ctx.Response().Header().Set("Cache-Control", "no-cache")
+	ctx.Response().Header().Set("ETag", etag)
+	if notModified(ctx.Request(), etag, loadedAt) {
+		ctx.SetStatus(http.StatusNotModified)
+		return nil, nil
+	}
+
+	if ctx.QueryParam("print") == "1" {
+		return s.rs.NotePrintView(s.NotesService, &note)
+	}
 	return s.rs.NoteWithList(s.NotesService, &note, searchQuery)
 }
 
-func (s *Server) getTag(ctx fuego.ContextNoBody) (fuego.Renderer, error) {
-	tag := ctx.PathParam("tag")
+// legacySlugURL returns the URL of the note, or of its embed, that slug pointed to with the v1 slug scheme
+func (s *Server) legacySlugURL(slug string) (*url.URL, bool) {
+	if current, ok := s.NotesService.LegacySlug(slug); ok {
+		return &url.URL{Path: "/" + current}, true
+	}
+	if noteSlug, isEmbed := strings.CutSuffix(slug, "/embed"); isEmbed {
+		if current, ok := s.NotesService.LegacySlug(noteSlug); ok {
+			return &url.URL{Path: "/" + current + "/embed"}, true
+		}
+	}
+	return nil, false
+}
 
-	if tag == "" {
-		slog.Info("Empty tag parameter")
-		return s.rs.TagList(s.NotesService, "", nil)
+// siteURL returns SITE_URL, or the URL of the host of the request when it is not set
+func (s *Server) siteURL(r *http.Request) string {
+	if s.cfg.SiteURL != "" {
+		return s.cfg.SiteURL
+	}
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// getSitemap serves sitemap.xml, with absolute URLs on siteURL
+func (s *Server) getSitemap(w http.ResponseWriter, r *http.Request) {
+	loc := s.cfg.Location()
+	entries := engine.BuildSitemap(s.NotesService.GetAllNotes(), s.cfg.PublicByDefault, loc, func(tag string) string {
+		return "/-/tag/" + tag
+	})
+
+	var buf bytes.Buffer
+	if err := engine.WriteSitemap(&buf, s.siteURL(r), entries, loc); err != nil {
+		slog.Error("Failed to write sitemap", "error", err)
+		http.Error(w, "failed to build sitemap", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		slog.Debug("Sitemap response write failed", "error", err)
+	}
+}
+
+// getRobots serves robots.txt, see engine.BuildRobots
+func (s *Server) getRobots(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if _, err := w.Write([]byte(engine.BuildRobots(s.cfg.SiteURL, s.cfg.RobotsDisallow))); err != nil {
+		slog.Debug("Robots response write failed", "error", err)
+	}
+}
+
+// getAttachment serves an attachment of the vault. Only the ones embedded or linked by public notes,
+// or by the shared notes the visitor has the key of, are served, other files of the vault are never reachable.
+func (s *Server) getAttachment(w http.ResponseWriter, r *http.Request) {
+	vaultPath := r.PathValue("path")
+	cacheControl := "public, max-age=3600"
+	if !s.NotesService.HasAttachment(vaultPath) {
+		if !s.NotesService.HasSharedAttachment(vaultPath, shareKeys(r)) {
+			http.NotFound(w, r)
+			return
+		}
+		// Only for the visitors with the share key, like the shared notes embedding it
+		cacheControl = "private, max-age=3600"
 	}
+	// Set before ServeFile, which would sniff the type of unknown extensions
+	w.Header().Set("Content-Type", engine.AttachmentContentType(vaultPath))
+	w.Header().Set("Cache-Control", cacheControl)
+	http.ServeFile(w, r, filepath.Join(s.cfg.Path, filepath.FromSlash(vaultPath)))
+}
+
+// getRawNote serves the markdown file of a note as it is in the vault, frontmatter included.
+// Private notes are not found, like their page.
+func (s *Server) getRawNote(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+	note, ok := s.NotesService.GetNote(slug)
+	if !ok {
+		if note, ok = s.NotesService.GetSharedNote(slug, shareKeys(r)); ok {
+			w.Header().Set("Cache-Control", "private, no-store")
+		}
+	} else if !engine.IsVisible(note, s.cfg.PublicByDefault) {
+		ok = false
+	}
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	content, err := os.ReadFile(filepath.Join(s.cfg.Path, filepath.FromSlash(note.Path)))
+	if err != nil {
+		slog.Error("Failed to read note file", "path", note.Path, "error", err)
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": path.Base(note.Path)}))
+	if _, err := w.Write(content); err != nil {
+		slog.Debug("Raw note response write failed", "error", err)
+	}
+}
+
+// getFeed serves the RSS feed of the most recently modified notes, with absolute links on siteURL
+func (s *Server) getFeed(w http.ResponseWriter, r *http.Request) {
+	loc := s.cfg.Location()
+	items := engine.RecentNotes(s.NotesService.GetAllNotes(), s.cfg.PublicByDefault, s.cfg.FeedSize, loc)
+
+	var buf bytes.Buffer
+	if err := engine.WriteRSSFeed(&buf, s.cfg.SiteTitle, s.cfg.SiteDescription, s.siteURL(r), "/-/feed.xml", items, loc); err != nil {
+		slog.Error("Failed to write feed", "error", err)
+		http.Error(w, "failed to build feed", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		slog.Debug("Feed response write failed", "error", err)
+	}
+}
+
+// getNoteEmbed renders a chromeless view of a note, the only page that can be framed by other websites
+func (s *Server) getNoteEmbed(ctx fuego.ContextNoBody, slug string) (fuego.Renderer, error) {
+	allowEmbedding(ctx.Response().Header(), s.cfg.EmbedFrameAncestors)
+
+	linkTarget := s.cfg.EmbedLinkTarget
+	if target := ctx.QueryParam("target"); target == "_top" || target == "_blank" {
+		linkTarget = target
+	}
+
+	note, ok := s.NotesService.GetNote(slug)
+	if !ok || !engine.IsVisible(note, s.cfg.PublicByDefault) {
+		slog.Info("Embedded note not found or private", "slug", slug)
+		ctx.SetStatus(http.StatusNotFound)
+		return s.rs.NoteEmbed(s.NotesService, nil, "", linkTarget)
+	}
+
+	heading := ctx.QueryParam("heading")
+	if heading != "" {
+		section, found := engine.SectionByHeading(note.Content, heading)
+		if !found {
+			slog.Info("Embedded section not found", "slug", slug, "heading", heading)
+			ctx.SetStatus(http.StatusNotFound)
+			return s.rs.NoteEmbed(s.NotesService, nil, "", linkTarget)
+		}
+		note.Content = section
+	}
+
+	return s.rs.NoteEmbed(s.NotesService, &note, heading, linkTarget)
+}
+
+// getGraphJSON returns the public notes and their wikilinks
+func (s *Server) getGraphJSON(ctx fuego.ContextNoBody) (api.Envelope[api.Graph], error) {
+	graph := engine.BuildGraph(s.NotesService.GetAllNotes(), s.cfg.PublicByDefault)
+
+	response := api.Graph{
+		Nodes: make([]api.GraphNode, 0, len(graph.Nodes)),
+		Edges: make([]api.GraphEdge, 0, len(graph.Edges)),
+	}
+	for _, node := range graph.Nodes {
+		response.Nodes = append(response.Nodes, api.GraphNode(node))
+	}
+	for _, edge := range graph.Edges {
+		response.Edges = append(response.Edges, api.GraphEdge(edge))
+	}
+	return api.Wrap(response), nil
+}
+
+func (s *Server) getGraph(ctx fuego.ContextNoBody) (fuego.Renderer, error) {
+	return s.rs.Graph(s.NotesService)
+}
+
+func (s *Server) getDiagnostics(ctx fuego.ContextNoBody) (fuego.Renderer, error) {
+	return s.rs.Diagnostics(s.NotesService, s.NotesService.Diagnostics(), s.trash.List())
+}
+
+func (s *Server) getStatusOverview(ctx fuego.ContextNoBody) (fuego.Renderer, error) {
+	oldestFirst := make(map[string]bool)
+	for name := range strings.SplitSeq(ctx.QueryParam("oldest"), ",") {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			oldestFirst[name] = true
+		}
+	}
+	return s.rs.StatusOverview(s.NotesService, oldestFirst)
+}
+
+// getRandomNote redirects to a random public note, never cached so each visit picks another
+func (s *Server) getRandomNote(ctx fuego.ContextNoBody) (fuego.Renderer, error) {
+	note, ok := s.NotesService.RandomNote(ctx.QueryParam("tag"), s.cfg.PublicByDefault)
+	if !ok {
+		slog.Info("No note to pick at random", "tag", ctx.QueryParam("tag"))
+		ctx.SetStatus(http.StatusNotFound)
+		return s.rs.NoteWithList(s.NotesService, nil, "")
+	}
+
+	ctx.Response().Header().Set("Cache-Control", "no-store")
+	_, err := ctx.Redirect(http.StatusFound, "/"+note.Slug)
+	return nil, err
+}
+
+// getUnlinkedMentions renders the "Unlinked mentions" section of a public note
+func (s *Server) getUnlinkedMentions(ctx fuego.ContextNoBody) (fuego.Renderer, error) {
+	note, ok := s.NotesService.GetNote(ctx.PathParam("slug"))
+	if !ok || !engine.IsVisible(note, s.cfg.PublicByDefault) {
+		return nil, fuego.NotFoundError{Detail: "note not found or private"}
+	}
+	return s.rs.UnlinkedMentions(s.NotesService, note), nil
+}
+
+// getTreeFolder renders the contents of a folder of the sidebar
+func (s *Server) getTreeFolder(ctx fuego.ContextNoBody) (fuego.Renderer, error) {
+	folder := engine.FindFolderByPath(s.NotesService.GetTree(), ctx.QueryParam("path"))
+	if folder == nil {
+		return nil, fuego.NotFoundError{Detail: "folder not found"}
+	}
+	return s.rs.TreeFolder(folder), nil
+}
+
+// historyOf returns the note of slug with its commits, never for private or shared notes. A
+// vault without git has no history.
+func (s *Server) historyOf(slug string) (model.Note, []engine.GitRevision, error) {
+	note, ok := s.NotesService.GetNote(slug)
+	if !ok || !engine.IsVisible(note, s.cfg.PublicByDefault) {
+		return model.Note{}, nil, fuego.NotFoundError{Detail: "note not found or private"}
+	}
+	revisions, err := engine.FileHistory(s.cfg.Path, note.Path)
+	if errors.Is(err, engine.ErrGitUnavailable) {
+		return model.Note{}, nil, fuego.NotFoundError{Detail: "note history not available, the vault is not a git repository"}
+	}
+	if err != nil {
+		slog.Error("Failed to read note history", "path", note.Path, "error", err)
+		return model.Note{}, nil, err
+	}
+	return note, revisions, nil
+}
+
+func (s *Server) getNoteHistory(ctx fuego.ContextNoBody) (fuego.Renderer, error) {
+	note, revisions, err := s.historyOf(ctx.PathParam("slug"))
+	if err != nil {
+		return nil, err
+	}
+	return s.rs.NoteHistory(s.NotesService, &note, revisions)
+}
+
+// getNoteDiff renders the changes of a note since one of its revisions. Only the commits of the
+// note's own history are read, never other files of the repository.
+func (s *Server) getNoteDiff(ctx fuego.ContextNoBody) (fuego.Renderer, error) {
+	note, revisions, err := s.historyOf(ctx.PathParam("slug"))
+	if err != nil {
+		return nil, err
+	}
+	rev := ctx.QueryParam("rev")
+	i := slices.IndexFunc(revisions, func(revision engine.GitRevision) bool { return revision.Hash == rev })
+	if rev == "" || i < 0 {
+		return nil, fuego.NotFoundError{Detail: "revision not found in the history of the note"}
+	}
+	revision := revisions[i]
+
+	old, err := engine.FileAtRevision(s.cfg.Path, revision.Path, revision.Hash)
+	if err != nil {
+		slog.Error("Failed to read note revision", "path", revision.Path, "hash", revision.Hash, "error", err)
+		return nil, err
+	}
+	current, err := os.ReadFile(filepath.Join(s.cfg.Path, filepath.FromSlash(note.Path)))
+	if err != nil {
+		slog.Error("Failed to read note file", "path", note.Path, "error", err)
+		return nil, fuego.NotFoundError{Detail: "note file not found"}
+	}
+
+	return s.rs.NoteDiff(s.NotesService, &note, revision, engine.DiffLines(old, string(current)), ctx.QueryParam("view") == "split")
+}
+
+// getViewStats lists the public notes by views, most viewed first
+func (s *Server) getViewStats(ctx fuego.ContextNoBody) (fuego.Renderer, error) {
+	var notes []model.Note
+	for slug, views := range s.views.Counts() {
+		if note, ok := s.NotesService.GetNote(slug); ok && engine.IsVisible(note, s.cfg.PublicByDefault) {
+			note.ViewCount = views
+			notes = append(notes, note)
+		}
+	}
+	slices.SortFunc(notes, func(a, b model.Note) int {
+		if c := cmp.Compare(b.ViewCount, a.ViewCount); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Slug, b.Slug)
+	})
+	return s.rs.ViewStats(s.NotesService, notes)
+}
+
+func (s *Server) getTasks(ctx fuego.ContextNoBody) (fuego.Renderer, error) {
+	return s.rs.Tasks(s.NotesService, engine.OpenTasks(s.NotesService.GetAllNotes(), s.cfg.PublicByDefault))
+}
+
+func (s *Server) getTags(ctx fuego.ContextNoBody) (fuego.Renderer, error) {
+	return s.rs.TagCloud(s.NotesService, s.NotesService.GetTagIndex())
+}
+
+func (s *Server) getTag(ctx fuego.ContextNoBody) (fuego.Renderer, error) {
+	tag := ctx.PathParam("tag")
 
 	tagIndex := s.NotesService.GetTagIndex()
 
-	// Get all notes that contain this tag
-	notesWithTag := tagIndex.GetNotesWithTag(tag)
+	// /-/tag/ lists every tag, like /-/tags
+	if tag == "" {
+		return s.rs.TagCloud(s.NotesService, tagIndex)
+	}
 
-	// Also get all tags that contain this tag as a substring
-	relatedTags := tagIndex.GetTagsContaining(tag)
+	// The notes of the tag and of its nested tags, like #golang/web for #golang, unless ?exact=1
+	exact := ctx.QueryParamBool("exact")
+	var groups []engine.TagGroup
+	if exact {
+		groups = []engine.TagGroup{{Tag: tag, Notes: tagIndex.GetNotesWithTag(tag)}}
+	} else {
+		groups = tagIndex.GetNotesWithTagOrChildren(tag)
+	}
 
-	slog.Info("Tag search", "tag", tag, "notes_found", len(notesWithTag), "related_tags", len(relatedTags))
+	slog.Info("Tag search", "tag", tag, "exact", exact, "groups", len(groups))
 
-	return s.rs.TagList(s.NotesService, tag, notesWithTag)
+	return s.rs.TagList(s.NotesService, tag, groups, exact)
 }
 
 // getUnifiedSearch handles the unified search page with immediate and lazy-loaded results
@@ -154,11 +730,31 @@ func (s *Server) getUnifiedSearch(ctx fuego.ContextNoBody) (fuego.Renderer, erro
 
 	if query == "" {
 		slog.Info("Empty unified search query")
-		return s.rs.UnifiedSearchResults(s.NotesService, "", nil, nil, nil)
+		return s.rs.UnifiedSearchResults(s.NotesService, "", "", nil, nil, nil, nil)
+	}
+
+	// Chat model of the AI summary, the default one when not allowed
+	chatModel := ctx.QueryParam("model")
+	if !s.cfg.ChatModelAllowed(chatModel) {
+		slog.Warn("Unknown chat model requested, using the default one", "model", chatModel)
+		chatModel = ""
+	}
+
+	// Advanced search: exact phrase or regex over note contents
+	mode := ctx.QueryParam("mode")
+	pattern, isRegex, isPattern := engine.ParsePatternQuery(query)
+	switch mode {
+	case template.SearchModePhrase:
+		pattern, isRegex, isPattern = query, false, true
+	case template.SearchModeRegex:
+		pattern, isRegex, isPattern = query, true, true
+	}
+	if isPattern {
+		return s.getPatternSearch(query, mode, pattern, isRegex)
 	}
 
 	// Perform title search (limit to top 5)
-	titleMatches := s.NotesService.SearchNotesByFilename(query, 5)
+	titleMatches := s.NotesService.SearchNotesByFilename(query, 5, s.cfg.PublicByDefault)
 
 	// Track seen note slugs for deduplication
 	seenSlugs := make(map[string]bool)
@@ -169,7 +765,7 @@ func (s *Server) getUnifiedSearch(ctx fuego.ContextNoBody) (fuego.Renderer, erro
 	}
 
 	// Perform heading search (limit to top 5, filter already-seen notes)
-	allHeadingMatches := s.NotesService.SearchNotesByHeadings(query, 0) // Get all first
+	allHeadingMatches := s.NotesService.SearchNotesByHeadings(query, 0, s.cfg.PublicByDefault) // Get all first
 	var headingMatches []engine.HeadingMatch
 	for _, match := range allHeadingMatches {
 		if !seenSlugs[match.Note.Slug] {
@@ -183,13 +779,100 @@ func (s *Server) getUnifiedSearch(ctx fuego.ContextNoBody) (fuego.Renderer, erro
 		}
 	}
 
+	// Perform content search (limit to top 5, filter already-seen notes)
+	var contentMatches []engine.ContentMatch
+	// Enough matches to find 5 unseen ones
+	for _, match := range s.NotesService.SearchNotesByContent(query, len(seenSlugsList)+5, s.cfg.PublicByDefault) {
+		if !seenSlugs[match.Note.Slug] {
+			contentMatches = append(contentMatches, match)
+			seenSlugs[match.Note.Slug] = true
+			seenSlugsList = append(seenSlugsList, match.Note.Slug)
+
+			if len(contentMatches) >= 5 {
+				break
+			}
+		}
+	}
+
 	slog.Info("Unified search",
 		"query", query,
 		"title_matches", len(titleMatches),
 		"heading_matches", len(headingMatches),
+		"content_matches", len(contentMatches),
 		"seen_slugs", len(seenSlugsList))
 
-	return s.rs.UnifiedSearchResults(s.NotesService, query, titleMatches, headingMatches, seenSlugsList)
+	return s.rs.UnifiedSearchResults(s.NotesService, query, chatModel, titleMatches, headingMatches, contentMatches, seenSlugsList)
+}
+
+// quickSearchMaxResults limits the matches of the sidebar search, titles and headings together
+const quickSearchMaxResults = 8
+
+// quickSearchMaxQueryLength limits the query of the sidebar search, in bytes: it is run on
+// each keystroke
+const quickSearchMaxQueryLength = 100
+
+// getQuickSearch renders the best title matches of the query, then headings of other notes,
+// one per note
+func (s *Server) getQuickSearch(ctx fuego.ContextNoBody) (fuego.Renderer, error) {
+	query := strings.TrimSpace(ctx.QueryParam("q"))
+	if len(query) > quickSearchMaxQueryLength {
+		query = strings.ToValidUTF8(query[:quickSearchMaxQueryLength], "")
+	}
+	if query == "" {
+		return s.rs.QuickSearchResults("", nil, nil), nil
+	}
+
+	titleMatches := s.NotesService.SearchNotesByFilename(query, quickSearchMaxResults, s.cfg.PublicByDefault)
+	seenSlugs := make(map[string]bool, len(titleMatches))
+	for _, note := range titleMatches {
+		seenSlugs[note.Slug] = true
+	}
+
+	var headingMatches []engine.HeadingMatch
+	if remaining := quickSearchMaxResults - len(titleMatches); remaining > 0 {
+		// Enough headings to find the remaining ones among the notes not listed yet
+		for _, match := range s.NotesService.SearchNotesByHeadings(query, len(seenSlugs)+remaining*quickSearchMaxResults, s.cfg.PublicByDefault) {
+			if seenSlugs[match.Note.Slug] {
+				continue
+			}
+			seenSlugs[match.Note.Slug] = true
+			headingMatches = append(headingMatches, match)
+			if len(headingMatches) == remaining {
+				break
+			}
+		}
+	}
+
+	return s.rs.QuickSearchResults(query, titleMatches, headingMatches), nil
+}
+
+// switcherMaxResults limits the quick switcher matches
+const switcherMaxResults = 20
+
+// getSwitcher returns the best quick switcher matches of the query
+func (s *Server) getSwitcher(ctx fuego.ContextNoBody) (api.Envelope[api.SwitcherResults], error) {
+	results := s.NotesService.Switcher(s.cfg.PublicByDefault).Search(ctx.QueryParam("q"), switcherMaxResults)
+
+	response := make(api.SwitcherResults, 0, len(results))
+	for _, result := range results {
+		response = append(response, api.SwitcherResult(result))
+	}
+	return api.Wrap(response), nil
+}
+
+// maxPatternMatchesPerNote limits the lines shown per note in phrase and regex searches
+const maxPatternMatchesPerNote = 5
+
+// getPatternSearch renders the exact phrase or regex matches of a query
+func (s *Server) getPatternSearch(query, mode, pattern string, isRegex bool) (fuego.Renderer, error) {
+	if isRegex && !s.cfg.RegexSearch {
+		return s.rs.PatternSearchResults(s.NotesService, query, mode, nil, errors.New("regex search is disabled on this site"))
+	}
+
+	results, err := s.NotesService.SearchNotesByPattern(pattern, isRegex, maxPatternMatchesPerNote, s.cfg.PublicByDefault)
+	slog.Info("Pattern search", "pattern", pattern, "regex", isRegex, "notes_found", len(results), "error", err)
+
+	return s.rs.PatternSearchResults(s.NotesService, query, mode, results, err)
 }
 
 // getUnifiedSearchStream handles SSE streaming for semantic search and AI response
@@ -201,11 +884,16 @@ func (s *Server) getUnifiedSearchStream(w http.ResponseWriter, r *http.Request)
 
 	query := r.URL.Query().Get("q")
 	seenParam := r.URL.Query().Get("seen")
+	chatModel := r.URL.Query().Get("model")
 
 	if query == "" {
 		http.Error(w, "Missing query parameter 'q'", http.StatusBadRequest)
 		return
 	}
+	if !s.cfg.ChatModelAllowed(chatModel) {
+		http.Error(w, "Unknown model, see CHAT_MODELS", http.StatusBadRequest)
+		return
+	}
 
 	// Parse seen slugs
 	seenSlugs := make(map[string]bool)
@@ -217,6 +905,228 @@ func (s *Server) getUnifiedSearchStream(w http.ResponseWriter, r *http.Request)
 		}
 	}
 
+	sse, stop, ok := s.startSSE(w, r, "search")
+	if !ok {
+		return
+	}
+	defer stop()
+
+	// --- SEMANTIC SEARCH PHASE ---
+
+	// The free text drives the semantic search and the AI answer, the operators filter the notes
+	parsed := engine.ParseSearchQuery(query)
+	question := parsed.SemanticText()
+
+	// The phase always ends with a semantic-results event, possibly empty, or a semantic-skipped event
+	// with the reason, so the client can remove its placeholders
+	var semanticResults []engine.ChunkMatch
+	var matchedChunks map[string][]engine.Chunk
+	skipReason := "Semantic search needs words to search"
+	if question != "" {
+		semanticResults, matchedChunks, skipReason = s.semanticSearch(sse.Context(), question, seenSlugs)
+		if parsed.HasFilters() {
+			filter := s.NotesService.SearchFilter(parsed)
+			semanticResults = slices.DeleteFunc(semanticResults, func(match engine.ChunkMatch) bool { return !filter(match.Note) })
+		}
+	}
+	if skipReason != "" {
+		if err := sse.Event("semantic-skipped", skipReason); err != nil {
+			slog.Debug("SSE semantic skipped write failed", "error", err, "query", query)
+			return
+		}
+	} else {
+		html := template.RenderSemanticResultsHTML(s.rs, semanticResults)
+		if err := sse.Event("semantic-results", html); err != nil {
+			slog.Debug("SSE semantic results write failed", "error", err, "query", query)
+			return
+		}
+		slog.Info("Sent semantic results", "query", query, "count", len(semanticResults))
+	}
+
+	// --- AI RESPONSE PHASE ---
+
+	// Generate AI response if chat client is available
+	if s.chatChain == nil {
+		slog.Warn("Chat client not available for unified search")
+	} else if question == "" {
+		slog.Info("No AI answer to a query of operators only", "query", query)
+	} else if contextNotes := s.answerContextNotes(query, semanticResults); len(contextNotes) > 0 {
+		if !s.streamAnswer(sse.Context(), sse, question, chatModel, nil, contextNotes, matchedChunks) {
+			return
+		}
+	}
+
+	// Send completion event
+	if err := sse.Event("done", "Complete"); err != nil {
+		slog.Debug("SSE done write failed", "error", err, "query", query)
+	}
+}
+
+// searchChatRequest is a follow-up question of the AI summary of the search page, with the
+// conversation so far: the page keeps it, so the server stays stateless
+type searchChatRequest struct {
+	Question string    `json:"question"`
+	Model    string    `json:"model"`   // Chat model picked with CHAT_MODELS, empty for the default one
+	History  []ai.Turn `json:"history"` // Previous questions and answers, oldest first
+	Seen     []string  `json:"seen"`    // Slugs of the notes the page already shows
+}
+
+// maxSearchChatBytes bounds the body of a follow-up question, the history of ai.MaxHistoryTurns
+// answers included
+const maxSearchChatBytes = 64 << 10
+
+// postSearchChat answers a follow-up question of the AI summary as an SSE stream: the notes
+// retrieved for the question the page doesn't show yet in a results event, then the answer like
+// getUnifiedSearchStream
+func (s *Server) postSearchChat(w http.ResponseWriter, r *http.Request) {
+	if s.chatChain == nil {
+		http.Error(w, "AI chat is not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req searchChatRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSearchChatBytes)).Decode(&req); err != nil {
+		http.Error(w, "Invalid chat request", http.StatusBadRequest)
+		return
+	}
+	question := strings.TrimSpace(req.Question)
+	if question == "" {
+		http.Error(w, "Missing question", http.StatusBadRequest)
+		return
+	}
+	if !s.cfg.ChatModelAllowed(req.Model) {
+		http.Error(w, "Unknown model, see CHAT_MODELS", http.StatusBadRequest)
+		return
+	}
+
+	if s.embeddingsManager != nil {
+		s.embeddingsManager.InitializeLazily()
+	}
+
+	sse, stop, ok := s.startSSE(w, r, "chat")
+	if !ok {
+		return
+	}
+	defer stop()
+
+	// Notes already shown still give context to the follow-up, they are just not shown twice
+	semanticResults, matchedChunks, skipReason := s.semanticSearch(sse.Context(), question, nil)
+	if skipReason != "" {
+		slog.Info("Follow-up question without semantic search", "reason", skipReason)
+	}
+	contextNotes := s.answerContextNotes(question, semanticResults)
+
+	seen := make(map[string]bool, len(req.Seen))
+	for _, slug := range req.Seen {
+		seen[slug] = true
+	}
+	sources := make([]model.Note, 0, len(contextNotes))
+	for _, note := range contextNotes {
+		if !seen[note.Slug] {
+			sources = append(sources, note)
+		}
+	}
+	if err := sse.Event("results", template.RenderSourcesHTML(s.rs, sources, matchedChunks)); err != nil {
+		slog.Debug("SSE results write failed", "error", err, "query", question)
+		return
+	}
+
+	if !s.streamAnswer(sse.Context(), sse, question, req.Model, ai.RecentTurns(req.History), contextNotes, matchedChunks) {
+		return
+	}
+
+	if err := sse.Event("done", "Complete"); err != nil {
+		slog.Debug("SSE done write failed", "error", err, "query", question)
+	}
+}
+
+// sseWriter writes the events of a Server-Sent Events response. Writes are serialized, since
+// keep-alive comments are sent from another goroutine, and flushed: a failed write or flush means
+// the client is gone.
+type sseWriter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	rc     *http.ResponseController
+	ctx    context.Context
+	closed bool // Set by the shutdown event, the last one
+}
+
+// Context is the context of the request, also canceled by the shutdown event
+func (s *sseWriter) Context() context.Context {
+	return s.ctx
+}
+
+// errSSEShutdown is returned by the writes of an sseWriter after its shutdown event
+var errSSEShutdown = errors.New("server shutting down")
+
+// Event sends an event with writeSSE and flushes it
+func (s *sseWriter) Event(event, data string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return errSSEShutdown
+	}
+	if err := writeSSE(s.w, event, data); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+// shutdown sends the shutdown event, after which every write fails so the handler returns
+func (s *sseWriter) shutdown() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	if err := writeSSE(s.w, "shutdown", errSSEShutdown.Error()); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+// write sends text and flushes it
+func (s *sseWriter) write(text string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return errSSEShutdown
+	}
+	if _, err := io.WriteString(s.w, text); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+// sseLineBreaks are the line breaks of the SSE format, which all end a field
+var sseLineBreaks = strings.NewReplacer("\r\n", "\n", "\r", "\n")
+
+// writeSSE writes a Server-Sent Event, a message event when event is empty. Every line of data
+// is sent as a data field, so multi-line HTML and tokens arrive whole: browsers join them back
+// with "\n".
+func writeSSE(w io.Writer, event, data string) error {
+	var sb strings.Builder
+	if event != "" {
+		sb.WriteString("event: " + event + "\n")
+	}
+	for line := range strings.SplitSeq(sseLineBreaks.Replace(data), "\n") {
+		sb.WriteString("data: " + line + "\n")
+	}
+	sb.WriteString("\n")
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// startSSE sets the headers of a Server-Sent Events response and sends keep-alive comments until
+// the returned stop is called, which waits for the last one so the handler can return. When the
+// server shuts down, it sends the shutdown event and the writes of the handler fail from then
+// on. It answers with an error when w can't stream.
+func (s *Server) startSSE(w http.ResponseWriter, r *http.Request, stream string) (*sseWriter, func(), bool) {
 	// Set headers for Server-Sent Events
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
@@ -224,34 +1134,45 @@ func (s *Server) getUnifiedSearchStream(w http.ResponseWriter, r *http.Request)
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("X-Accel-Buffering", "no") // Disable nginx buffering
 
-	flusher, ok := w.(http.Flusher)
-	if !ok {
+	if _, ok := w.(http.Flusher); !ok {
 		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
-		return
+		return nil, nil, false
 	}
+	sseConnections.Add(1, stream)
 
 	// Set write deadline to 5 minutes for long-running SSE connections
 	rc := http.NewResponseController(w)
 	if err := rc.SetWriteDeadline(time.Now().Add(5 * time.Minute)); err != nil {
 		slog.Warn("Failed to set write deadline", "error", err)
 	}
+	ctx, cancel := context.WithCancel(r.Context())
+	sse := &sseWriter{w: w, rc: rc, ctx: ctx}
+	// The headers go right away, so the client knows the stream is open before the first event
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		slog.Debug("SSE headers flush failed", "error", err)
+	}
 
 	// Start keep-alive ticker to prevent timeout
 	keepAliveTicker := time.NewTicker(15 * time.Second)
-	defer keepAliveTicker.Stop()
-
-	keepAliveDone := make(chan bool)
-	defer close(keepAliveDone)
+	keepAliveDone := make(chan struct{})
+	keepAliveExited := make(chan struct{})
 
 	go func() {
+		defer close(keepAliveExited)
 		for {
 			select {
 			case <-keepAliveTicker.C:
-				if _, err := fmt.Fprintf(w, ": keep-alive\n\n"); err != nil {
+				if err := sse.write(": keep-alive\n\n"); err != nil {
 					slog.Debug("SSE keep-alive write failed (client likely disconnected)", "error", err)
 					return
 				}
-				flusher.Flush()
+			case <-s.shuttingDown:
+				if err := sse.shutdown(); err != nil {
+					slog.Debug("SSE shutdown write failed", "error", err)
+				}
+				cancel()
+				return
 			case <-keepAliveDone:
 				return
 			case <-r.Context().Done():
@@ -260,177 +1181,282 @@ func (s *Server) getUnifiedSearchStream(w http.ResponseWriter, r *http.Request)
 		}
 	}()
 
-	// --- SEMANTIC SEARCH PHASE ---
+	stop := func() {
+		close(keepAliveDone)
+		<-keepAliveExited
+		keepAliveTicker.Stop()
+		cancel()
+		sseConnections.Add(-1, stream)
+	}
+	return sse, stop, true
+}
 
-	// If vector store is available, perform semantic search
-	var semanticResults []model.Note
-	vectorStore := s.embeddingsManager.GetStore()
-	if vectorStore == nil {
+// semanticSearch returns the notes closest to query, without the seen ones, and their matched
+// chunks by slug, best first, for the AI context. The reason is set when the search couldn't run.
+func (s *Server) semanticSearch(ctx context.Context, query string, seenSlugs map[string]bool) ([]engine.ChunkMatch, map[string][]engine.Chunk, string) {
+	// Weaviate or the embedded store, whichever VECTOR_STORE chose
+	var searcher SimilaritySearcher = s.embeddingsManager.GetStore()
+	if searcher == nil {
 		slog.Warn("Vector store not available for unified search")
-	} else {
-		docs, err := vectorStore.SimilaritySearch(r.Context(), query, 10) // Get 10, will filter to 5
-		if err != nil {
-			slog.Error("Similarity search failed", "error", err, "query", query)
-		} else {
-			slog.Info("Weaviate returned documents for unified search", "query", query, "doc_count", len(docs))
-
-			// Convert documents to notes using metadata
-			notesMap := s.NotesService.GetNotesMap()
-			for _, doc := range docs {
-				if slug, ok := doc.Metadata["slug"].(string); ok {
-					if note, exists := notesMap[slug]; exists {
-						// Only add if not already seen
-						if !seenSlugs[note.Slug] {
-							semanticResults = append(semanticResults, note)
-							seenSlugs[note.Slug] = true
-
-							// Stop at 5 results
-							if len(semanticResults) >= 5 {
-								break
-							}
-						}
-					}
-				}
-			}
-		}
+		return nil, nil, "Semantic search is not available"
 	}
 
-	// Send semantic results if we have any
-	if len(semanticResults) > 0 {
-		html := template.RenderSemanticResultsHTML(s.rs, semanticResults)
-		if _, err := fmt.Fprintf(w, "event: semantic-results\ndata: %s\n\n", html); err != nil {
-			slog.Debug("SSE semantic results write failed", "error", err, "query", query)
-			return
-		}
-		flusher.Flush()
-		slog.Info("Sent semantic results", "query", query, "count", len(semanticResults))
+	searchStart := time.Now()
+	// Chunks, several per note: get 20, will collapse to 5 notes
+	docs, err := searcher.SimilaritySearch(ctx, query, 20)
+	vectorSearchDuration.Observe(time.Since(searchStart).Seconds())
+	if err != nil {
+		slog.Error("Similarity search failed", "error", err, "query", query)
+		return nil, nil, "Semantic search failed"
 	}
+	slog.Info("Vector store returned documents for unified search", "query", query, "doc_count", len(docs))
+	results, matchedChunks := semanticMatches(s.NotesService.GetNotesMap(), docs, seenSlugs, 5, s.cfg.PublicByDefault)
+	return results, matchedChunks, ""
+}
 
-	// --- AI RESPONSE PHASE ---
+// answerContextNotes returns the notes given as context to the AI answer of query: title, heading,
+// then semantic matches, up to 10
+func (s *Server) answerContextNotes(query string, semanticResults []engine.ChunkMatch) []model.Note {
+	// Collect all unique notes for context (title + heading + semantic)
+	// Re-perform title and heading searches to get all relevant notes
 
-	// Generate AI response if chat client is available
-	if s.chatClient == nil {
-		slog.Warn("Chat client not available for unified search")
-	} else {
-		// Collect all unique notes for context (title + heading + semantic)
-		// Re-perform title and heading searches to get all relevant notes
+	// Get title matches (no limit - get all)
+	titleMatches := s.NotesService.SearchNotesByFilename(query, 10, s.cfg.PublicByDefault)
 
-		// Get title matches (no limit - get all)
-		titleMatches := s.NotesService.SearchNotesByFilename(query, 10)
+	// Get heading matches (no limit - get all)
+	headingMatches := s.NotesService.SearchNotesByHeadings(query, 10, s.cfg.PublicByDefault)
 
-		// Get heading matches (no limit - get all)
-		headingMatches := s.NotesService.SearchNotesByHeadings(query, 10)
+	// Combine all results: title, heading, then semantic
+	contextNotes := make([]model.Note, 0, 10)
+	contextSlugs := make(map[string]bool)
 
-		// Combine all results: title, heading, then semantic
-		contextNotes := make([]model.Note, 0, 10)
-		contextSlugs := make(map[string]bool)
+	// Add title matches first
+	for _, note := range titleMatches {
+		if !contextSlugs[note.Slug] {
+			contextNotes = append(contextNotes, note)
+			contextSlugs[note.Slug] = true
+		}
+	}
 
-		// Add title matches first
-		for _, note := range titleMatches {
-			if !contextSlugs[note.Slug] {
-				contextNotes = append(contextNotes, note)
-				contextSlugs[note.Slug] = true
-			}
+	// Add heading matches
+	for _, match := range headingMatches {
+		if !contextSlugs[match.Note.Slug] {
+			contextNotes = append(contextNotes, match.Note)
+			contextSlugs[match.Note.Slug] = true
 		}
+	}
 
-		// Add heading matches
-		for _, match := range headingMatches {
-			if !contextSlugs[match.Note.Slug] {
-				contextNotes = append(contextNotes, match.Note)
-				contextSlugs[match.Note.Slug] = true
-			}
+	// Add semantic matches
+	for _, match := range semanticResults {
+		if !contextSlugs[match.Note.Slug] {
+			contextNotes = append(contextNotes, match.Note)
+			contextSlugs[match.Note.Slug] = true
 		}
+	}
 
-		// Add semantic matches
-		for _, note := range semanticResults {
-			if !contextSlugs[note.Slug] {
-				contextNotes = append(contextNotes, note)
-				contextSlugs[note.Slug] = true
-			}
+	// Limit to 15 notes to maximize 2K token context
+	if len(contextNotes) > 10 {
+		contextNotes = contextNotes[:10]
+	}
+
+	slog.Info("Combined context notes for AI response",
+		"query", query,
+		"title_matches", len(titleMatches),
+		"heading_matches", len(headingMatches),
+		"semantic_matches", len(semanticResults),
+		"total_context_notes", len(contextNotes))
+	return contextNotes
+}
+
+// streamAnswer streams the AI answer of query, after the history of the conversation: a sources
+// event with the numbered context blocks as JSON, then provider and token events, the tokens as
+// HTML with their citations linked to the sources, or an error event. It reports whether the
+// stream can go on: the generation stops when the client leaves, as ctx is done. A chatModel
+// allowed by CHAT_MODELS replaces the model of the providers.
+func (s *Server) streamAnswer(ctx context.Context, sse *sseWriter, query, chatModel string, history []ai.Turn, contextNotes []model.Note, matchedChunks map[string][]engine.Chunk) bool {
+	// Build context from the chunks semantic search matched, or the most relevant sections of
+	// each note, within the token budget
+	contextBlocks := ai.SelectMatchedContext(query, contextNotes, matchedChunks, s.contextBudget())
+	prompt, err := s.promptTemplate().Build(query, contextBlocks)
+	if err != nil {
+		// Templates are checked when parsed, with a sample question
+		slog.Error("Building the AI prompt failed, using the built-in one", "error", err)
+		prompt, _ = ai.DefaultPrompt().Build(query, contextBlocks)
+	}
+	prompt.History = history
+
+	// Sent before the answer, so the page can list the sources it cites
+	sources := ai.Sources(contextBlocks)
+	sourcesJSON, err := json.Marshal(sources)
+	if err != nil {
+		slog.Error("Encoding the AI sources failed", "error", err)
+		return false
+	}
+	if err := sse.Event("sources", string(sourcesJSON)); err != nil {
+		slog.Debug("SSE sources write failed", "error", err, "query", query)
+		return false
+	}
+	citations := template.NewCitationLinker(sources)
+
+	slog.Info("Generating unified search AI response", "query", query, "context_size", len(prompt.User), "context_blocks", len(contextBlocks), "history_turns", len(history), "user_prompt", prompt.User)
+
+	// Create streaming callback
+	tokenCount := 0
+	streamCallback := func(ctx context.Context, chunk []byte) error {
+		// Stops the generation, rather than sending tokens to no one
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		tokenCount++
+		llmTokensTotal.Inc()
+		if tokenCount == 1 {
+			slog.Info("First AI token received", "query", query, "data", string(chunk))
+		}
+		html := citations.Write(string(chunk))
+		if html == "" {
+			return nil // The start of a citation, written with the next chunk
+		}
+		if err := sse.Event("token", html); err != nil {
+			slog.Debug("SSE token write failed", "error", err, "query", query)
+			return err
 		}
+		return nil
+	}
 
-		// Limit to 15 notes to maximize 2K token context
-		if len(contextNotes) > 10 {
-			contextNotes = contextNotes[:10]
+	// Announce the provider answering before its first token
+	providerCallback := func(name string) error {
+		if err := sse.Event("provider", name); err != nil {
+			slog.Debug("SSE provider write failed", "error", err, "query", query)
+			return err
 		}
+		return nil
+	}
 
-		slog.Info("Combined context notes for AI response",
-			"query", query,
-			"title_matches", len(titleMatches),
-			"heading_matches", len(headingMatches),
-			"semantic_matches", len(semanticResults),
-			"total_context_notes", len(contextNotes))
+	options := []llms.CallOption{
+		llms.WithMaxTokens(512), // Shorter for unified search
+		llms.WithTemperature(0.7),
+	}
+	if chatModel != "" {
+		options = append(options, llms.WithModel(chatModel))
+	}
 
-		if len(contextNotes) > 0 {
-			// Build context from notes (limit to ~600 chars per note to maximize 2K token usage)
-			var contextBuilder strings.Builder
-			contextBuilder.WriteString("Relevant notes:\n\n")
-			for i, note := range contextNotes {
-				contextBuilder.WriteString(fmt.Sprintf("%d. %s:\n", i+1, note.Title))
-				content := note.Content
-				if len(content) > 600 {
-					content = content[:600] + "..."
-				}
-				contextBuilder.WriteString(content)
-				contextBuilder.WriteString("\n\n")
-			}
+	// Generate response with streaming, from the first provider of the chain that answers
+	generationStart := time.Now()
+	provider, err := s.chatChain.Generate(ctx, prompt, providerCallback, streamCallback, options...)
+	if ctx.Err() != nil {
+		llmGenerationDuration.Observe(time.Since(generationStart).Seconds(), "canceled")
+		slog.Info("Stream closed, AI generation stopped", "query", query, "tokens", tokenCount, "provider", provider)
+		return false
+	}
+	if err != nil {
+		llmGenerationDuration.Observe(time.Since(generationStart).Seconds(), "error")
+		slog.Error("AI generation error", "error", err, "query", query, "provider", provider)
+		if writeErr := sse.Event("error", "AI generation failed"); writeErr != nil {
+			slog.Debug("SSE error write failed", "error", writeErr, "query", query)
+		}
+		return false
+	}
 
-			userPrompt := contextBuilder.String()
+	llmGenerationDuration.Observe(time.Since(generationStart).Seconds(), "ok")
+	slog.Info("AI streaming completed", "query", query, "tokens", tokenCount, "provider", provider)
+	if html := citations.Flush(); html != "" {
+		if err := sse.Event("token", html); err != nil {
+			slog.Debug("SSE token write failed", "error", err, "query", query)
+			return false
+		}
+	}
+	return true
+}
 
-			// Build prompt
-			prompt := fmt.Sprintf(`Answer this question based on the notes below.
+// semanticMatches turns the chunks found by semantic search, best first, into the notes to show:
+// up to limit notes not seen yet, each with its best chunk. All the chunks found are returned by
+// slug for the AI context, seen notes included. Chunks of a previous version of their note, or of
+// a private note, are ignored, the vector store may still have them.
+func semanticMatches(notesMap map[string]model.Note, docs []schema.Document, seenSlugs map[string]bool, limit int, publicByDefault bool) ([]engine.ChunkMatch, map[string][]engine.Chunk) {
+	var matches []engine.ChunkMatch
+	chunks := make(map[string][]engine.Chunk)
+	shown := make(map[string]bool)
+	for _, doc := range docs {
+		slug, _ := doc.Metadata["slug"].(string)
+		note, exists := notesMap[slug]
+		if !exists || !engine.IsVisible(note, publicByDefault) {
+			continue
+		}
+		if hash, _ := doc.Metadata["hash"].(string); hash != computeContentHash(note) {
+			continue
+		}
 
-Question: %s
+		chunk := engine.Chunk{}
+		chunk.Heading, _ = doc.Metadata["heading"].(string)
+		start, end := metadataInt(doc.Metadata["start"]), metadataInt(doc.Metadata["end"])
+		if 0 <= start && start <= end && end <= len(note.Content) {
+			chunk.Start, chunk.End, chunk.Text = start, end, note.Content[start:end]
+		}
+		if chunk.Text != "" {
+			chunks[slug] = append(chunks[slug], chunk)
+		}
 
-%s
+		// Only the best chunk of a note not already seen is shown
+		if !seenSlugs[slug] && !shown[slug] && len(matches) < limit {
+			matches = append(matches, engine.ChunkMatch{Note: note, Chunk: chunk})
+			shown[slug] = true
+		}
+	}
+	return matches, chunks
+}
+
+// metadataInt returns a number of the metadata of a document, stored as an int by EmbeddedStore
+// and decoded from JSON by Weaviate, -1 if there is none
+func metadataInt(value any) int {
+	switch v := value.(type) {
+	case int:
+		return v
+	case float64:
+		return int(v)
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return int(n)
+		}
+	}
+	return -1
+}
 
-Answer concisely:`, query, userPrompt)
+// embeddingsControl serves a pause or resume request, authenticated with "Authorization: Bearer <EMBEDDINGS_TOKEN>".
+// It responds with the embedding status.
+func (s *Server) embeddingsControl(action func(*EmbeddingsManager) bool) func(fuego.ContextNoBody) (api.Envelope[api.EmbeddingStatus], error) {
+	return func(c fuego.ContextNoBody) (api.Envelope[api.EmbeddingStatus], error) {
+		if !validBearerToken(c.Header("Authorization"), s.cfg.EmbeddingsToken) {
+			return api.Envelope[api.EmbeddingStatus]{}, fuego.UnauthorizedError{Detail: "missing or wrong embeddings token"}
+		}
+		if s.embeddingsManager.GetStore() == nil {
+			return api.Envelope[api.EmbeddingStatus]{}, fuego.HTTPError{Status: http.StatusServiceUnavailable, Title: "Service Unavailable", Detail: "embeddings are not available"}
+		}
 
-			slog.Info("Generating unified search AI response", "query", query, "context_size", len(userPrompt), "user_prompt", userPrompt)
+		action(s.embeddingsManager)
 
-			// Create streaming callback
-			tokenCount := 0
-			streamCallback := func(ctx context.Context, chunk []byte) error {
-				tokenCount++
-				if tokenCount == 1 {
-					slog.Info("First AI token received", "query", query, "data", string(chunk))
-				}
-				if _, err := fmt.Fprintf(w, "event: token\ndata: %s\n\n", string(chunk)); err != nil {
-					slog.Debug("SSE token write failed", "error", err, "query", query)
-					return err
-				}
-				flusher.Flush()
-				return nil
-			}
+		return api.Wrap(api.EmbeddingStatus(s.embeddingsManager.GetProgress().GetStatus())), nil
+	}
+}
 
-			// Generate response with streaming
-			_, err := llms.GenerateFromSinglePrompt(
-				r.Context(),
-				s.chatClient,
-				prompt,
-				llms.WithMaxTokens(512), // Shorter for unified search
-				llms.WithTemperature(0.7),
-				llms.WithStreamingFunc(streamCallback),
-			)
-			if err != nil {
-				slog.Error("AI generation error", "error", err, "query", query)
-				if _, writeErr := fmt.Fprintf(w, "event: error\ndata: AI generation failed\n\n"); writeErr != nil {
-					slog.Debug("SSE error write failed", "error", writeErr, "query", query)
-				}
-				flusher.Flush()
-				return
-			}
+// postReembed wipes the embeddings cache so every note is embedded again, authenticated like
+// embeddingsControl. It responds with 409 Conflict during an embedding pass.
+func (s *Server) postReembed(c fuego.ContextNoBody) (api.Envelope[api.EmbeddingStatus], error) {
+	if !validBearerToken(c.Header("Authorization"), s.cfg.EmbeddingsToken) {
+		return api.Envelope[api.EmbeddingStatus]{}, fuego.UnauthorizedError{Detail: "missing or wrong embeddings token"}
+	}
+	if s.embeddingsManager.GetStore() == nil {
+		return api.Envelope[api.EmbeddingStatus]{}, fuego.HTTPError{Status: http.StatusServiceUnavailable, Title: "Service Unavailable", Detail: "embeddings are not available"}
+	}
 
-			slog.Info("AI streaming completed", "query", query, "tokens", tokenCount)
+	if err := s.embeddingsManager.Reembed(); err != nil {
+		if errors.Is(err, errEmbeddingInProgress) {
+			return api.Envelope[api.EmbeddingStatus]{}, fuego.ConflictError{Title: "Conflict", Detail: err.Error()}
 		}
+		slog.Error("Clearing the embeddings failed", "error", err)
+		return api.Envelope[api.EmbeddingStatus]{}, fuego.InternalServerError{Detail: "clearing the embeddings failed, see the server logs"}
 	}
 
-	// Send completion event
-	if _, err := fmt.Fprintf(w, "event: done\ndata: Complete\n\n"); err != nil {
-		slog.Debug("SSE done write failed", "error", err, "query", query)
-		return
-	}
-	flusher.Flush()
+	return api.Wrap(api.EmbeddingStatus(s.embeddingsManager.GetProgress().GetStatus())), nil
 }
 
 func (s *Server) getEmbeddingProgress(w http.ResponseWriter, r *http.Request) {
@@ -447,6 +1473,8 @@ func (s *Server) getEmbeddingProgress(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
 		return
 	}
+	sseConnections.Add(1, "embedding-progress")
+	defer sseConnections.Add(-1, "embedding-progress")
 
 	// Subscribe to embedding progress updates
 	embeddingProgress := s.embeddingsManager.GetProgress()
@@ -467,23 +1495,22 @@ func (s *Server) getEmbeddingProgress(w http.ResponseWriter, r *http.Request) {
 		// Create progress data
 		data := template.EmbeddingProgressData{
 			Embedded:    status.EmbeddedNotes,
+			Skipped:     status.SkippedNotes,
 			Total:       status.TotalNotes,
 			IsEmbedding: status.IsEmbedding,
+			IsPaused:    status.IsPaused,
+			Rate:        status.Rate,
 		}
 
 		// Render the progress content using the SAME gomponent as in navbar
-		progressNode := template.RenderEmbeddingProgressContent(data)
-
-		// Write SSE message
-		if _, err := fmt.Fprint(w, "data: "); err != nil {
-			slog.Debug("SSE embedding progress write failed", "error", err)
-			return
-		}
-		if err := progressNode.Render(w); err != nil {
+		var html strings.Builder
+		if err := template.RenderEmbeddingProgressContent(data).Render(&html); err != nil {
 			slog.Debug("SSE embedding progress render failed", "error", err)
 			return
 		}
-		if _, err := fmt.Fprint(w, "\n\n"); err != nil {
+
+		// Write SSE message
+		if err := writeSSE(w, "", html.String()); err != nil {
 			slog.Debug("SSE embedding progress write failed", "error", err)
 			return
 		}
@@ -497,6 +1524,12 @@ func (s *Server) getEmbeddingProgress(w http.ResponseWriter, r *http.Request) {
 		select {
 		case <-r.Context().Done():
 			return
+		case <-s.shuttingDown:
+			// htmx reconnects once the server is back
+			if err := writeSSE(w, "shutdown", errSSEShutdown.Error()); err == nil {
+				flusher.Flush()
+			}
+			return
 		case <-ticker.C:
 			// Send periodic update
 			sendUpdate(embeddingProgress.GetStatus())
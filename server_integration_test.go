@@ -214,8 +214,10 @@ This is a completely new note.
 		}
 		defer resp.Body.Close()
 
-		// Should get 200 but with empty/not found content
-		// (depending on how your server handles missing notes)
+		// Should get the not found page, with a 404
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("Expected status 404 for a deleted note, got %d", resp.StatusCode)
+		}
 		body, err := io.ReadAll(resp.Body)
 		if err != nil {
 			t.Fatalf("Failed to read response body: %v", err)
@@ -301,14 +303,14 @@ func createTestHandler(server *Server) http.Handler {
 
 		note, ok := server.NotesService.GetNote(slug)
 		if !ok {
-			w.WriteHeader(http.StatusOK)
+			w.WriteHeader(http.StatusNotFound)
 			fmt.Fprintf(w, "Note not found: %s", slug)
 			return
 		}
 
 		// Additional security check: ensure note is public
 		if !server.cfg.PublicByDefault && !note.IsPublic {
-			w.WriteHeader(http.StatusOK)
+			w.WriteHeader(http.StatusNotFound)
 			fmt.Fprintf(w, "Note not found: %s", slug)
 			return
 		}
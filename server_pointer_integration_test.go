@@ -183,8 +183,8 @@ func TestServerPointerIntegrationWithPrivateNote(t *testing.T) {
 	w2 := httptest.NewRecorder()
 	fuegServer.Mux.ServeHTTP(w2, req2)
 
-	if w2.Code != http.StatusOK {
-		t.Fatalf("Expected status 200 (but with access denied content), got %d", w2.Code)
+	if w2.Code != http.StatusNotFound {
+		t.Fatalf("Expected status 404, like a note that does not exist, got %d", w2.Code)
 	}
 
 	body2 := w2.Body.String()
@@ -1,14 +1,34 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"sync/atomic"
+	"syscall"
 	"testing"
+	"time"
 
+	"github.com/EwenQuim/pluie/ai"
+	"github.com/EwenQuim/pluie/api"
 	"github.com/EwenQuim/pluie/config"
 	"github.com/EwenQuim/pluie/engine"
 	"github.com/EwenQuim/pluie/model"
 	"github.com/EwenQuim/pluie/template"
+	"github.com/go-fuego/fuego"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/schema"
+	"github.com/tmc/langchaingo/vectorstores"
 )
 
 func TestServerPrivateNoteFiltering(t *testing.T) {
@@ -62,13 +82,13 @@ func TestServerPrivateNoteFiltering(t *testing.T) {
 		{
 			name:             "AccessPrivateNote",
 			slug:             "private-note",
-			expectedStatus:   http.StatusOK, // Returns 200 but with empty note list
+			expectedStatus:   http.StatusNotFound, // Same as a note that does not exist
 			shouldNotContain: "This is private content",
 		},
 		{
 			name:           "AccessNonExistentNote",
 			slug:           "non-existent",
-			expectedStatus: http.StatusOK, // Returns 200 but with empty note list
+			expectedStatus: http.StatusNotFound,
 		},
 	}
 
@@ -93,7 +113,7 @@ func TestServerPrivateNoteFiltering(t *testing.T) {
 				note, ok := server.NotesService.GetNote(slug)
 				if !ok {
 					// Note not found - this simulates the server behavior
-					w.WriteHeader(http.StatusOK)
+					w.WriteHeader(http.StatusNotFound)
 					w.Write([]byte("Note not found"))
 					return
 				}
@@ -101,7 +121,7 @@ func TestServerPrivateNoteFiltering(t *testing.T) {
 				// Additional security check: ensure note is public
 				if !note.IsPublic {
 					// Private note access denied
-					w.WriteHeader(http.StatusOK)
+					w.WriteHeader(http.StatusNotFound)
 					w.Write([]byte("Note not found"))
 					return
 				}
@@ -267,3 +287,1881 @@ func containsHelper(s, substr string) bool {
 	}
 	return false
 }
+
+func TestEmbeddingsControlRoutes(t *testing.T) {
+	newHandler := func(cfg *config.Config, store VectorStore) (http.Handler, *EmbeddingsManager) {
+		manager := NewEmbeddingsManager(t.Context(), store, NewEmbeddingProgress(), nil, t.TempDir()+"/tracking.json", "", nil)
+		server := &Server{rs: template.NewResource(cfg), cfg: cfg, embeddingsManager: manager}
+		fuegoServer := fuego.NewServer()
+		server.registerRoutes(fuegoServer)
+		return fuegoServer.Mux, manager
+	}
+	post := func(handler http.Handler, url, authorization string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, url, nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	handler, manager := newHandler(&config.Config{EmbeddingsToken: "secret"}, &fakeEmbedder{})
+
+	if w := post(handler, "/-/embeddings/pause", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("without token: status = %d, want 401", w.Code)
+	}
+	if w := post(handler, "/-/embeddings/pause", "Bearer nope"); w.Code != http.StatusUnauthorized {
+		t.Errorf("wrong token: status = %d, want 401", w.Code)
+	}
+	if manager.queue.IsPaused() {
+		t.Fatal("unauthorized requests should not pause the embeddings")
+	}
+
+	w := post(handler, "/-/embeddings/pause", "Bearer secret")
+	var response api.Envelope[api.EmbeddingStatus]
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	status := response.Data
+	if w.Code != http.StatusOK || !status.IsPaused || !manager.queue.IsPaused() {
+		t.Errorf("pause: status = %d, response %+v", w.Code, status)
+	}
+
+	w = post(handler, "/-/embeddings/resume", "Bearer secret")
+	if w.Code != http.StatusOK || manager.queue.IsPaused() || manager.progress.GetStatus().IsPaused {
+		t.Errorf("resume: status = %d, the queue should be resumed", w.Code)
+	}
+
+	if w := post(handler, "/-/embeddings/reembed", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("reembed without token: status = %d, want 401", w.Code)
+	}
+	if w := post(handler, "/-/embeddings/reembed", "Bearer secret"); w.Code != http.StatusOK {
+		t.Errorf("reembed: status = %d, want 200", w.Code)
+	}
+	manager.passMu.Lock()
+	if w := post(handler, "/-/embeddings/reembed", "Bearer secret"); w.Code != http.StatusConflict {
+		t.Errorf("reembed during a pass: status = %d, want 409", w.Code)
+	}
+	manager.passMu.Unlock()
+
+	// No vector store, nothing to control
+	handler, _ = newHandler(&config.Config{EmbeddingsToken: "secret"}, nil)
+	if w := post(handler, "/-/embeddings/pause", "Bearer secret"); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("without store: status = %d, want 503", w.Code)
+	}
+
+	// No token configured, no control routes
+	handler, _ = newHandler(&config.Config{}, &fakeEmbedder{})
+	if w := post(handler, "/-/embeddings/pause", ""); w.Code == http.StatusOK {
+		t.Error("control routes should not be available without EMBEDDINGS_TOKEN")
+	}
+}
+
+func TestGetSwitcher(t *testing.T) {
+	notes := []model.Note{
+		{Title: "Meeting — Acme Corp", Slug: "meeting-acme-corp", IsPublic: true, Content: "## Action items"},
+		{Title: "Garden", Slug: "garden", IsPublic: true},
+	}
+	notesMap := map[string]model.Note{}
+	for _, note := range notes {
+		notesMap[note.Slug] = note
+	}
+	cfg := &config.Config{}
+	server := &Server{
+		NotesService: engine.NewNotesService(&notesMap, engine.BuildTree(notes), engine.BuildTagIndex(notes)),
+		rs:           template.NewResource(cfg),
+		cfg:          cfg,
+	}
+	fuegoServer := fuego.NewServer()
+	server.registerRoutes(fuegoServer)
+
+	search := func(query string) api.SwitcherResults {
+		t.Helper()
+		w := httptest.NewRecorder()
+		fuegoServer.Mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/-/switcher?q="+query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200", w.Code)
+		}
+		var response api.Envelope[api.SwitcherResults]
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("decoding response: %v", err)
+		}
+		if response.Version != api.Version || response.Data == nil {
+			t.Fatalf("the response should be a version %d envelope of a JSON array, got %+v", api.Version, response)
+		}
+		return response.Data
+	}
+
+	results := search("mtg+ac")
+	if len(results) == 0 || results[0].Slug != "meeting-acme-corp" || results[0].Tier != engine.SwitcherTierInitials {
+		t.Errorf("unexpected results %+v", results)
+	}
+	if results := search("acti"); len(results) != 1 || results[0].URL != "/meeting-acme-corp#action-items" {
+		t.Errorf("expected the heading, got %+v", results)
+	}
+	if results := search(""); len(results) != 0 {
+		t.Errorf("an empty query should return no results, got %+v", results)
+	}
+}
+
+// failingStore is a VectorStore whose searches fail
+type failingStore struct{ fakeEmbedder }
+
+func (f *failingStore) SimilaritySearch(context.Context, string, int, ...vectorstores.Option) ([]schema.Document, error) {
+	return nil, errors.New("weaviate is down")
+}
+
+func TestUnifiedSearchStream_SemanticPhaseEnds(t *testing.T) {
+	notes := []model.Note{{Title: "Garden", Slug: "garden", IsPublic: true, Content: "Tomatoes"}}
+	notesMap := map[string]model.Note{"garden": notes[0]}
+	notesService := engine.NewNotesService(&notesMap, engine.BuildTree(notes), engine.BuildTagIndex(notes))
+
+	stream := func(store VectorStore, chain *ChatChain) string {
+		t.Helper()
+		cfg := &config.Config{}
+		server := &Server{NotesService: notesService, rs: template.NewResource(cfg), cfg: cfg, chatChain: chain}
+		if store != nil {
+			server.embeddingsManager = NewEmbeddingsManager(t.Context(), store, NewEmbeddingProgress(), notesService, "", "", nil)
+		}
+		fuegoServer := fuego.NewServer()
+		server.registerRoutes(fuegoServer)
+
+		w := httptest.NewRecorder()
+		fuegoServer.Mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/-/search-stream?q=garden", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200", w.Code)
+		}
+		return w.Body.String()
+	}
+
+	tests := []struct {
+		name     string
+		store    VectorStore
+		chain    *ChatChain
+		expected []string // Events, in order
+	}{
+		{
+			name:     "no vector store",
+			expected: []string{"event: semantic-skipped\ndata: Semantic search is not available\n\n", "event: done\n"},
+		},
+		{
+			name:     "no semantic results",
+			store:    &fakeEmbedder{},
+			expected: []string{"event: semantic-results\ndata: \n\n", "event: done\n"},
+		},
+		{
+			name:     "semantic search error",
+			store:    &failingStore{},
+			expected: []string{"event: semantic-skipped\ndata: Semantic search failed\n\n", "event: done\n"},
+		},
+		{
+			name:     "AI generation error",
+			chain:    NewChatChain([]ChatProvider{&fakeChatProvider{name: "ollama/llama3", err: errors.New("connection refused")}}, time.Minute),
+			expected: []string{"event: semantic-skipped\n", "event: error\ndata: AI generation failed\n\n"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := stream(tt.store, tt.chain)
+			rest := body
+			for _, event := range tt.expected {
+				_, after, found := strings.Cut(rest, event)
+				if !found {
+					t.Fatalf("expected %q in order, got:\n%s", event, body)
+				}
+				rest = after
+			}
+		})
+	}
+}
+
+func TestSemanticMatches(t *testing.T) {
+	garden := model.Note{Title: "Garden", Slug: "garden", Content: "# Tomatoes\nWater them.\n\n# Soil\nAdd compost."}
+	kitchen := model.Note{Title: "Kitchen", Slug: "kitchen", Content: "Tomato salad."}
+	seen := model.Note{Title: "Seen", Slug: "seen", Content: "Tomatoes again."}
+	notesMap := map[string]model.Note{"garden": garden, "kitchen": kitchen, "seen": seen}
+
+	chunkDoc := func(note model.Note, i int, hash string) schema.Document {
+		doc := noteChunkDocuments(note)[i]
+		doc.Metadata["hash"] = hash
+		// Numbers come back as float64 from Weaviate
+		doc.Metadata["start"] = float64(doc.Metadata["start"].(int))
+		return doc
+	}
+	docs := []schema.Document{
+		chunkDoc(seen, 0, computeContentHash(seen)),
+		chunkDoc(garden, 1, "previous-version"),
+		chunkDoc(garden, 0, computeContentHash(garden)),
+		chunkDoc(kitchen, 0, computeContentHash(kitchen)),
+		chunkDoc(garden, 1, computeContentHash(garden)),
+	}
+
+	matches, chunks := semanticMatches(notesMap, docs, map[string]bool{"seen": true}, 5, true)
+	if len(matches) != 2 || matches[0].Note.Slug != "garden" || matches[1].Note.Slug != "kitchen" {
+		t.Fatalf("matches = %+v, want garden then kitchen, once each", matches)
+	}
+	if matches[0].Chunk.Heading != "Tomatoes" || matches[0].Chunk.Text != "Water them." {
+		t.Errorf("garden chunk = %+v, want its best one", matches[0].Chunk)
+	}
+	if got := chunks["garden"]; len(got) != 2 || got[1].Text != "Add compost." {
+		t.Errorf("garden chunks = %+v, want both current chunks, best first", got)
+	}
+	if len(chunks["seen"]) != 1 {
+		t.Errorf("seen notes should still give their chunks to the AI context, got %+v", chunks["seen"])
+	}
+
+	html := template.RenderSemanticResultsHTML(template.NewResource(&config.Config{}), matches)
+	if !strings.Contains(html, `href="/garden#tomatoes"`) || !strings.Contains(html, "semantic-heading") || !strings.Contains(html, "Water them.") {
+		t.Errorf("the garden card should link to its matched section with its heading:\n%s", html)
+	}
+}
+
+func TestJSONEndpoints_Envelope(t *testing.T) {
+	cfg := &config.Config{EmbeddingsToken: "secret"}
+	server := &Server{rs: template.NewResource(cfg), cfg: cfg}
+	fuegoServer := fuego.NewServer()
+	server.registerRoutes(fuegoServer)
+
+	w := httptest.NewRecorder()
+	fuegoServer.Mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/-/health", nil))
+	var health api.Envelope[api.Health]
+	if err := json.NewDecoder(w.Body).Decode(&health); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if health.Version != api.Version || health.Data.Status != "ok" {
+		t.Errorf("unexpected health response %+v", health)
+	}
+
+	// The OpenAPI components come from the api types, through the typed handlers
+	description := fuegoServer.OpenAPI.Description()
+	for path, schema := range map[string]string{
+		"/-/health":           "Envelope_api.Health",
+		"/-/switcher":         "Envelope_api.SwitcherResults",
+		"/-/embeddings/pause": "Envelope_api.EmbeddingStatus",
+		"/-/graph.json":       "Envelope_api.Graph",
+	} {
+		if _, ok := description.Components.Schemas[schema]; !ok {
+			t.Errorf("missing %s schema in the OpenAPI components", schema)
+		}
+		item := description.Paths.Find(path)
+		if item == nil {
+			t.Errorf("missing %s in the OpenAPI paths", path)
+			continue
+		}
+		operation := item.Get
+		if operation == nil {
+			operation = item.Post
+		}
+		if ref := operation.Responses.Status(http.StatusOK).Value.Content["application/json"].Schema.Ref; ref != "#/components/schemas/"+schema {
+			t.Errorf("%s responds with %q, want the %s schema", path, ref, schema)
+		}
+	}
+}
+
+func TestGetNote_SlugSchemes(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "Study/Q&A!.md", "---\npublish: true\n---\nQuestions and answers")
+	writeTestFile(t, dir, "Party 🎉.md", "---\npublish: true\n---\nCelebration")
+
+	newServer := func(scheme string) *fuego.Server {
+		cfg := &config.Config{Path: dir, SiteTitle: "Pluie", SlugScheme: scheme}
+		notesMap, tree, tagIndex, err := loadNotes(dir, cfg)
+		if err != nil {
+			t.Fatalf("loadNotes() error: %v", err)
+		}
+		server := &Server{NotesService: engine.NewNotesService(notesMap, tree, tagIndex), rs: template.NewResource(cfg), cfg: cfg}
+		fuegoServer := fuego.NewServer()
+		server.registerRoutes(fuegoServer)
+		return fuegoServer
+	}
+	get := func(server *fuego.Server, target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		server.Mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		return w
+	}
+
+	// v1 slugs keep punctuation URL-encoded, the router decodes it
+	v1 := newServer(config.SlugSchemeV1)
+	for target, content := range map[string]string{
+		"/study/q&a%21":               "Questions and answers",
+		"/party-%F0%9F%8E%89":         "Celebration",
+		"/study/q&a%21/embed":         "Questions and answers",
+		"/party-%25F0%259F%258E%2589": "Celebration", // Encoded twice
+	} {
+		if w := get(v1, target); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), content) {
+			t.Errorf("v1 %s: status %d, expected the note", target, w.Code)
+		}
+	}
+
+	// v2 slugs need no encoding, v1 URLs are redirected to them
+	v2 := newServer(config.SlugSchemeV2)
+	if w := get(v2, "/study/q-a"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Questions and answers") {
+		t.Errorf("v2 slug: status %d, expected the note", w.Code)
+	}
+	for target, location := range map[string]string{
+		"/study/q&a%21":               "/study/q-a",
+		"/study/q&a%21?search=x":      "/study/q-a?search=x",
+		"/study/q&a%21/embed":         "/study/q-a/embed",
+		"/party-%F0%9F%8E%89":         "/party",
+		"/party-%25F0%259F%258E%2589": "/party", // The v1 link encoded again by the browser
+	} {
+		w := get(v2, target)
+		if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != location {
+			t.Errorf("v2 %s: status %d to %q, want a 301 to %q", target, w.Code, w.Header().Get("Location"), location)
+		}
+	}
+}
+
+func TestGetNote_StatusCodes(t *testing.T) {
+	notes := []model.Note{
+		{Title: "Garden", Slug: "garden", IsPublic: true, Content: "Tomatoes"},
+		{Title: "Secret", Slug: "secret", Content: "Secret content"},
+	}
+	notesMap := map[string]model.Note{}
+	for _, note := range notes {
+		notesMap[note.Slug] = note
+	}
+	cfg := &config.Config{}
+	server := &Server{
+		NotesService: engine.NewNotesService(&notesMap, engine.BuildTree(notes[:1]), engine.BuildTagIndex(notes[:1])),
+		rs:           template.NewResource(cfg),
+		cfg:          cfg,
+	}
+	fuegoServer := fuego.NewServer()
+	server.registerRoutes(fuegoServer)
+
+	for path, want := range map[string]int{
+		"/garden":  http.StatusOK,
+		"/nowhere": http.StatusNotFound,
+		"/secret":  http.StatusNotFound, // Not 403, that would tell the note exists
+	} {
+		// Boosted navigation gets the same status as a full page load
+		for _, boosted := range []bool{false, true} {
+			r := httptest.NewRequest(http.MethodGet, path, nil)
+			if boosted {
+				r.Header.Set("HX-Request", "true")
+				r.Header.Set("HX-Boosted", "true")
+			}
+			w := httptest.NewRecorder()
+			fuegoServer.Mux.ServeHTTP(w, r)
+			if w.Code != want {
+				t.Errorf("%s (boosted: %v): status %d, want %d", path, boosted, w.Code, want)
+			}
+			if body := w.Body.String(); want == http.StatusNotFound && (!strings.Contains(body, "<html") || !strings.Contains(body, "404 : Not found") || strings.Contains(body, "Secret content")) {
+				t.Errorf("%s (boosted: %v): expected the not found page, got:\n%s", path, boosted, body)
+			}
+		}
+	}
+}
+
+// parseSSE parses SSE events like browsers do: data fields joined with "\n", "message" by
+// default, comments ignored
+func parseSSE(t *testing.T, stream string) [][2]string {
+	t.Helper()
+	var events [][2]string
+	for block := range strings.SplitSeq(strings.TrimSuffix(stream, "\n\n"), "\n\n") {
+		event, data := "message", []string{}
+		for line := range strings.SplitSeq(block, "\n") {
+			switch {
+			case strings.HasPrefix(line, "event: "):
+				event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				data = append(data, strings.TrimPrefix(line, "data: "))
+			case line != "" && !strings.HasPrefix(line, ":"):
+				t.Errorf("line %q is neither a field nor a comment", line)
+			}
+		}
+		events = append(events, [2]string{event, strings.Join(data, "\n")})
+	}
+	return events
+}
+
+func TestWriteSSE(t *testing.T) {
+	tests := []struct {
+		name, event, data string
+		expected          string // Data received by the browser
+	}{
+		{name: "single line", event: "token", data: "Hello", expected: "Hello"},
+		{name: "multi-line HTML", event: "semantic-results", data: "<div>\n  <h3>Garden</h3>\n</div>\n", expected: "<div>\n  <h3>Garden</h3>\n</div>\n"},
+		{name: "token with a newline", event: "token", data: "first\n\nsecond", expected: "first\n\nsecond"},
+		{name: "token with CRLF", event: "token", data: "first\r\nsecond\rthird", expected: "first\nsecond\nthird"},
+		{name: "empty", event: "semantic-results", data: "", expected: ""},
+		{name: "message", data: "<p>\n3 notes</p>", expected: "<p>\n3 notes</p>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sb strings.Builder
+			if err := writeSSE(&sb, tt.event, tt.data); err != nil {
+				t.Fatalf("writeSSE() error: %v", err)
+			}
+			if strings.Contains(sb.String(), "\r") {
+				t.Errorf("writeSSE() = %q, want no carriage return", sb.String())
+			}
+
+			event := tt.event
+			if event == "" {
+				event = "message"
+			}
+			events := parseSSE(t, sb.String())
+			if len(events) != 1 || events[0] != [2]string{event, tt.expected} {
+				t.Errorf("writeSSE() = %q, parsed as %q, want event %q with %q", sb.String(), events, event, tt.expected)
+			}
+		})
+	}
+}
+
+// endlessChatProvider streams tokens until its callback fails, like a model writing a long answer
+type endlessChatProvider struct {
+	tokens  atomic.Int32 // Accepted by the callback
+	stopped chan error   // Receives the error of the callback that stopped the stream
+}
+
+func (p *endlessChatProvider) Name() string           { return "ollama/llama3" }
+func (p *endlessChatProvider) Timeout() time.Duration { return 0 }
+
+func (p *endlessChatProvider) Stream(ctx context.Context, _ ai.Prompt, onChunk func(ctx context.Context, chunk []byte) error, _ ...llms.CallOption) error {
+	for {
+		if err := onChunk(ctx, []byte("token ")); err != nil {
+			p.stopped <- err
+			return err
+		}
+		p.tokens.Add(1)
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestUnifiedSearchStream_StopsGenerationWhenClientLeaves(t *testing.T) {
+	notes := []model.Note{{Title: "Garden", Slug: "garden", IsPublic: true, Content: "Tomatoes"}}
+	notesMap := map[string]model.Note{"garden": notes[0]}
+	notesService := engine.NewNotesService(&notesMap, engine.BuildTree(notes), engine.BuildTagIndex(notes))
+
+	provider := &endlessChatProvider{stopped: make(chan error, 1)}
+	cfg := &config.Config{}
+	server := &Server{NotesService: notesService, rs: template.NewResource(cfg), cfg: cfg, chatChain: NewChatChain([]ChatProvider{provider}, time.Minute)}
+	fuegoServer := fuego.NewServer()
+	server.registerRoutes(fuegoServer)
+	httpServer := httptest.NewServer(fuegoServer.Mux)
+	defer httpServer.Close()
+
+	ctx, cancel := context.WithCancel(t.Context())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, httpServer.URL+"/-/search-stream?q=garden", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET /-/search-stream error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// Leave once the answer streams
+	reader := bufio.NewReader(resp.Body)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("stream ended before the first token: %v", err)
+		}
+		if line == "event: token\n" {
+			break
+		}
+	}
+	cancel()
+
+	select {
+	case err := <-provider.stopped:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("the callback stopped the stream with %v, want the cancellation", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the generation went on after the client left")
+	}
+	tokens := provider.tokens.Load()
+	time.Sleep(20 * time.Millisecond)
+	if provider.tokens.Load() != tokens {
+		t.Error("the callback accepted tokens after stopping the stream")
+	}
+}
+
+func TestUnifiedSearch_QueryInScript(t *testing.T) {
+	notes := []model.Note{{Title: "Garden", Slug: "garden", IsPublic: true, Content: "Tomatoes"}}
+	notesMap := map[string]model.Note{"garden": notes[0]}
+	notesService := engine.NewNotesService(&notesMap, engine.BuildTree(notes), engine.BuildTagIndex(notes))
+
+	cfg := &config.Config{}
+	server := &Server{NotesService: notesService, rs: template.NewResource(cfg), cfg: cfg}
+	fuegoServer := fuego.NewServer()
+	server.registerRoutes(fuegoServer)
+	get := func(query string) string {
+		w := httptest.NewRecorder()
+		fuegoServer.Mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/-/search?q="+url.QueryEscape(query), nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%q: status = %d, want 200", query, w.Code)
+		}
+		return w.Body.String()
+	}
+
+	scripts := strings.Count(get("garden"), "<script")
+	page := get("</script><script>alert(1)</script>")
+	if strings.Contains(page, "<script>alert(1)") || strings.Count(page, "<script") != scripts {
+		t.Errorf("the query should not close the script of the page and open another one:\n%s", page)
+	}
+	if !strings.Contains(page, `"\u003c/script\u003e\u003cscript\u003ealert(1)\u003c/script\u003e"`) {
+		t.Error("the script should get the query escaped")
+	}
+}
+
+func TestUnifiedSearchStream_ChatModel(t *testing.T) {
+	notes := []model.Note{{Title: "Garden", Slug: "garden", IsPublic: true, Content: "Tomatoes"}}
+	notesMap := map[string]model.Note{"garden": notes[0]}
+	notesService := engine.NewNotesService(&notesMap, engine.BuildTree(notes), engine.BuildTagIndex(notes))
+
+	cfg := &config.Config{ChatModel: "tinyllama", ChatModelChoices: []string{"llama3"}}
+	provider := &fakeChatProvider{name: "ollama/tinyllama", chunks: []string{"Hi"}}
+	server := &Server{NotesService: notesService, rs: template.NewResource(cfg), cfg: cfg, chatChain: NewChatChain([]ChatProvider{provider}, time.Minute)}
+	fuegoServer := fuego.NewServer()
+	server.registerRoutes(fuegoServer)
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		fuegoServer.Mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	if w := get("/-/search-stream?q=garden&model=llama3"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "event: provider\ndata: ollama/llama3\n") {
+		t.Errorf("allowed model: status %d, want the answer of llama3:\n%s", w.Code, w.Body.String())
+	}
+	if w := get("/-/search-stream?q=garden&model=gpt-4o"); w.Code != http.StatusBadRequest {
+		t.Errorf("model missing from CHAT_MODELS: status %d, want 400", w.Code)
+	}
+
+	// The search page streams with the model it was asked for, or the default one
+	page := get("/-/search?q=garden&model=llama3").Body.String()
+	if !strings.Contains(page, "/-/search-stream?model=llama3") || !strings.Contains(page, `data-model="llama3"`) || !strings.Contains(page, `<option value="llama3" selected>`) {
+		t.Errorf("expected the stream and follow-ups of llama3 and the model selected:\n%s", page)
+	}
+	if page := get("/-/search?q=garden&model=gpt-4o").Body.String(); strings.Contains(page, "model=gpt-4o") || strings.Contains(page, "data-model") {
+		t.Errorf("a model missing from CHAT_MODELS should fall back to the default one:\n%s", page)
+	}
+}
+
+func TestSearchChat_FollowUp(t *testing.T) {
+	notes := []model.Note{
+		{Title: "Garden", Slug: "garden", IsPublic: true, Content: "Tomatoes"},
+		{Title: "Garden tools", Slug: "garden-tools", IsPublic: true, Content: "A spade"},
+	}
+	notesMap := map[string]model.Note{"garden": notes[0], "garden-tools": notes[1]}
+	notesService := engine.NewNotesService(&notesMap, engine.BuildTree(notes), engine.BuildTagIndex(notes))
+
+	provider := &fakeChatProvider{name: "ollama/llama3", chunks: []string{"Use a ", "spade [", "2]."}}
+	post := func(chain *ChatChain, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		cfg := &config.Config{}
+		server := &Server{NotesService: notesService, rs: template.NewResource(cfg), cfg: cfg, chatChain: chain}
+		fuegoServer := fuego.NewServer()
+		server.registerRoutes(fuegoServer)
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/-/search-chat", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		fuegoServer.Mux.ServeHTTP(w, r)
+		return w
+	}
+	chain := NewChatChain([]ChatProvider{provider}, time.Minute)
+
+	var history []string
+	for i := range ai.MaxHistoryTurns + 1 {
+		history = append(history, fmt.Sprintf(`{"question":"question %d","answer":"answer %d"}`, i, i))
+	}
+	w := post(chain, `{"question":"garden","seen":["garden"],"history":[`+strings.Join(history, ",")+`]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200:\n%s", w.Code, w.Body.String())
+	}
+
+	// The results event only has the notes the page doesn't show, before the answer
+	body := w.Body.String()
+	results, answer, found := strings.Cut(body, "event: token\n")
+	if !found || !strings.Contains(results, "event: results\n") || !strings.Contains(results, `data-slug="garden-tools"`) || strings.Contains(results, `data-slug="garden"`) {
+		t.Errorf("expected the new sources then the answer, got:\n%s", body)
+	}
+	if !strings.Contains(results, "event: sources\ndata: "+`[{"slug":"garden","title":"Garden","url":"/garden"},{"slug":"garden-tools","title":"Garden tools","url":"/garden-tools"}]`) {
+		t.Errorf("expected the numbered sources before the answer, got:\n%s", body)
+	}
+	if !strings.Contains(answer, `data: <a href="/garden-tools" class="citation`) || !strings.HasSuffix(body, "event: done\ndata: Complete\n\n") {
+		t.Errorf("expected the streamed answer with its citation then done, got:\n%s", body)
+	}
+
+	// Notes already shown are still given as context, after the last turns of the conversation
+	if turns := provider.prompt.History; len(turns) != ai.MaxHistoryTurns || turns[0].Question != "question 1" {
+		t.Errorf("history = %+v, want the last %d turns", turns, ai.MaxHistoryTurns)
+	}
+	if !strings.Contains(provider.prompt.User, "Tomatoes") || !strings.Contains(provider.prompt.User, "A spade") {
+		t.Errorf("prompt = %q, want both notes as context", provider.prompt.User)
+	}
+
+	if w := post(chain, `{"question":"  "}`); w.Code != http.StatusBadRequest {
+		t.Errorf("empty question: status %d, want 400", w.Code)
+	}
+	if w := post(nil, `{"question":"garden"}`); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("without chat provider: status %d, want 503", w.Code)
+	}
+}
+
+// fixedStore finds the same chunks whatever the query
+type fixedStore struct {
+	recordingStore
+	docs []schema.Document
+}
+
+func (s *fixedStore) SimilaritySearch(context.Context, string, int, ...vectorstores.Option) ([]schema.Document, error) {
+	return s.docs, nil
+}
+
+func TestSearch_NeverShowsPrivateNotes(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "Garden.md", "---\npublish: true\n---\n# Garden beds\nTomatoes")
+	writeTestFile(t, dir, "Hidden garden.md", "# Garden secrets\nTomatoes under glass")
+	cfg := &config.Config{Path: dir}
+	notesMap, tree, tagIndex, err := loadNotes(dir, cfg)
+	if err != nil {
+		t.Fatalf("loadNotes() error: %v", err)
+	}
+	notesService := engine.NewNotesService(notesMap, tree, tagIndex)
+
+	// The vector store may still have the chunks of a note made private
+	hidden := model.Note{Title: "Hidden garden", Slug: "hidden-garden", Path: "Hidden garden.md", Content: "# Garden secrets\nTomatoes under glass"}
+	store := &fixedStore{docs: append(noteChunkDocuments(hidden), noteChunkDocuments((*notesMap)["garden"])...)}
+	manager := NewEmbeddingsManager(t.Context(), store, NewEmbeddingProgress(), notesService, "", "", nil)
+	manager.initOnce.Do(func() {}) // The chunks of the store are the ones searched
+	provider := &fakeChatProvider{name: "ollama/llama3", chunks: []string{"Tomatoes [1]."}}
+	server := &Server{NotesService: notesService, rs: template.NewResource(cfg), cfg: cfg, chatChain: NewChatChain([]ChatProvider{provider}, time.Minute), embeddingsManager: manager}
+	fuegoServer := fuego.NewServer()
+	server.registerRoutes(fuegoServer)
+
+	requests := []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/-/search?q=garden", nil),
+		httptest.NewRequest(http.MethodGet, "/-/search?q=tomatoes", nil),
+		httptest.NewRequest(http.MethodGet, "/-/search?q=tomatoes&mode=phrase", nil),
+		httptest.NewRequest(http.MethodGet, "/-/search-stream?q=garden", nil),
+		httptest.NewRequest(http.MethodPost, "/-/search-chat", strings.NewReader(`{"question":"garden"}`)),
+		httptest.NewRequest(http.MethodGet, "/-/quick-search?q=garden", nil),
+		httptest.NewRequest(http.MethodGet, "/-/switcher?q=garden", nil),
+	}
+	for _, r := range requests {
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		fuegoServer.Mux.ServeHTTP(w, r)
+
+		body := w.Body.String()
+		if w.Code != http.StatusOK || !strings.Contains(body, "garden") {
+			t.Errorf("%s %s: status %d, want the public note:\n%s", r.Method, r.URL, w.Code, body)
+		}
+		if strings.Contains(body, "Hidden garden") || strings.Contains(body, "hidden-garden") || strings.Contains(body, "secrets") {
+			t.Errorf("%s %s shows the private note:\n%s", r.Method, r.URL, body)
+		}
+	}
+	if strings.Contains(provider.prompt.User, "glass") {
+		t.Errorf("prompt = %q, want only the public note as context", provider.prompt.User)
+	}
+}
+
+func TestUnifiedSearch_QueryOperators(t *testing.T) {
+	notes := []model.Note{
+		{Title: "Tomato garden", Slug: "tomato-garden", IsPublic: true, Metadata: map[string]any{"tags": []any{"garden"}}, Content: "Tomatoes"},
+		{Title: "Tomato soup", Slug: "tomato-soup", IsPublic: true, Content: "Tomatoes"},
+	}
+	notesMap := map[string]model.Note{"tomato-garden": notes[0], "tomato-soup": notes[1]}
+	notesService := engine.NewNotesService(&notesMap, engine.BuildTree(notes), engine.BuildTagIndex(notes))
+
+	cfg := &config.Config{}
+	provider := &fakeChatProvider{name: "ollama/llama3", chunks: []string{"Hi"}}
+	server := &Server{NotesService: notesService, rs: template.NewResource(cfg), cfg: cfg, chatChain: NewChatChain([]ChatProvider{provider}, time.Minute)}
+	fuegoServer := fuego.NewServer()
+	server.registerRoutes(fuegoServer)
+	get := func(path string) string {
+		w := httptest.NewRecorder()
+		fuegoServer.Mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Body.String()
+	}
+
+	page := get("/-/search?q=" + url.QueryEscape("tag:garden tomato"))
+	if !strings.Contains(page, `data-slug="tomato-garden"`) || strings.Contains(page, `data-slug="tomato-soup"`) {
+		t.Errorf("expected only the note with the tag:\n%s", page)
+	}
+
+	// The AI answers the free text, with the notes passing the operators as context
+	stream := get("/-/search-stream?q=" + url.QueryEscape("tag:garden tomato"))
+	if !strings.Contains(provider.prompt.User, "Question: tomato\n") || strings.Contains(provider.prompt.User, "Tomato soup") || !strings.Contains(stream, "event: done") {
+		t.Errorf("prompt = %q, want the free text and the tagged note only", provider.prompt.User)
+	}
+
+	// Operators alone have nothing to answer
+	provider.prompt = ai.Prompt{}
+	if stream := get("/-/search-stream?q=" + url.QueryEscape("tag:garden")); provider.prompt.User != "" || !strings.Contains(stream, "event: semantic-skipped") || !strings.Contains(stream, "event: done") {
+		t.Errorf("operators only should skip the semantic search and the AI answer:\n%s", stream)
+	}
+}
+
+func TestGetNote_NotFoundPages(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "Garden.md", "---\npublish: true\n---\nTomatoes")
+	writeTestFile(t, dir, "Secret.md", "Secret content")
+	writeTestFile(t, dir, "_pluie/404.md", "# Lost\n\nNothing here, try the [[Garden]].")
+
+	cfg := &config.Config{Path: dir, SiteTitle: "Pluie"}
+	notesMap, tree, tagIndex, privateSlugs, err := loadNotesWithPrivateSlugs(dir, cfg)
+	if err != nil {
+		t.Fatalf("loadNotes() error: %v", err)
+	}
+	server := &Server{NotesService: engine.NewNotesService(notesMap, tree, tagIndex), rs: template.NewResource(cfg), cfg: cfg}
+	server.NotesService.SetPrivateSlugs(privateSlugs)
+	fuegoServer := fuego.NewServer()
+	server.registerRoutes(fuegoServer)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		fuegoServer.Mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	missing := get("/nowhere")
+	if missing.Code != http.StatusNotFound {
+		t.Errorf("missing note: status %d, want 404", missing.Code)
+	}
+	if body := missing.Body.String(); !strings.Contains(body, "Nothing here, try the") || !strings.Contains(body, `href="/garden"`) || strings.Contains(body, "does not exist or is private") {
+		t.Errorf("expected _pluie/404.md with its wikilinks, got:\n%s", body)
+	}
+
+	// Nothing tells a private note from a missing one
+	private := get("/secret")
+	if private.Code != http.StatusNotFound || private.Body.String() != get("/nowhere").Body.String() {
+		t.Errorf("private note: status %d, want the same 404 as a missing note", private.Code)
+	}
+	if w := get("/_pluie/404"); w.Code != http.StatusNotFound {
+		t.Errorf("system notes are not notes, /_pluie/404: status %d", w.Code)
+	}
+
+	// Unless the vault has a private page
+	writeTestFile(t, dir, "_pluie/private.md", "This note is private.")
+	if _, err := server.reloadNotes(dir, cfg, true); err != nil {
+		t.Fatalf("reloadNotes() error: %v", err)
+	}
+	private = get("/secret")
+	if body := private.Body.String(); private.Code != http.StatusNotFound || !strings.Contains(body, "This note is private.") || strings.Contains(body, "Secret content") {
+		t.Errorf("expected _pluie/private.md with a 404, got %d:\n%s", private.Code, body)
+	}
+	if body := get("/nowhere").Body.String(); !strings.Contains(body, "Nothing here, try the") {
+		t.Errorf("missing notes should still get _pluie/404.md, got:\n%s", body)
+	}
+}
+
+func TestGetNote_AliasRedirect(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "notes/Real.md", "---\npublish: true\naliases: [Other Name]\n---\nThe real note")
+	writeTestFile(t, dir, "Index.md", "---\npublish: true\n---\nSee [[Other Name]]")
+
+	cfg := &config.Config{Path: dir, SiteTitle: "Pluie"}
+	notesMap, tree, tagIndex, err := loadNotes(dir, cfg)
+	if err != nil {
+		t.Fatalf("loadNotes() error: %v", err)
+	}
+	server := &Server{NotesService: engine.NewNotesService(notesMap, tree, tagIndex), rs: template.NewResource(cfg), cfg: cfg}
+	fuegoServer := fuego.NewServer()
+	server.registerRoutes(fuegoServer)
+
+	w := httptest.NewRecorder()
+	fuegoServer.Mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/notes/other-name?search=x", nil))
+	if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "/notes/real?search=x" {
+		t.Errorf("alias: status %d to %q, want a 301 to the note", w.Code, w.Header().Get("Location"))
+	}
+
+	w = httptest.NewRecorder()
+	fuegoServer.Mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/index", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `href="/notes/real"`) {
+		t.Errorf("the wikilink to the alias should point to the note, status %d", w.Code)
+	}
+}
+
+func TestGetTasks(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "Garden.md", "---\npublish: true\n---\n- [ ] Water the tomatoes\n  - [ ] Buy a can\n- [x] Plant seeds")
+	writeTestFile(t, dir, "Diary.md", "---\npublish: false\n---\n- [ ] Secret errand")
+
+	cfg := &config.Config{Path: dir, SiteTitle: "Pluie"}
+	notesMap, tree, tagIndex, err := loadNotes(dir, cfg)
+	if err != nil {
+		t.Fatalf("loadNotes() error: %v", err)
+	}
+	server := &Server{NotesService: engine.NewNotesService(notesMap, tree, tagIndex), rs: template.NewResource(cfg), cfg: cfg}
+	fuegoServer := fuego.NewServer()
+	server.registerRoutes(fuegoServer)
+
+	w := httptest.NewRecorder()
+	fuegoServer.Mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/-/tasks", nil))
+	body := w.Body.String()
+	if w.Code != http.StatusOK || !strings.Contains(body, "Water the tomatoes") || !strings.Contains(body, "Buy a can") || !strings.Contains(body, `href="/garden"`) {
+		t.Errorf("expected the open tasks of the garden note, status %d:\n%s", w.Code, body)
+	}
+	if strings.Contains(body, "Plant seeds") || strings.Contains(body, "Secret errand") {
+		t.Error("done tasks and tasks of private notes should not be listed")
+	}
+
+	// The note itself shows real checkboxes
+	w = httptest.NewRecorder()
+	fuegoServer.Mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/garden", nil))
+	if !strings.Contains(w.Body.String(), `<li class="task-list-item"><input type="checkbox" checked disabled> Plant seeds`) {
+		t.Errorf("expected a checked checkbox for the done task:\n%s", w.Body.String())
+	}
+}
+
+func TestGetNote_FolderIndex(t *testing.T) {
+	notes := []model.Note{
+		{Title: "Kickoff", Slug: "projects/kickoff", Path: "Projects/Kickoff.md", IsPublic: true},
+		{Title: "Budget", Slug: "projects/budget", Path: "Projects/Budget.md"},
+		{Title: "Meeting", Slug: "projects/client-x/meeting", Path: "Projects/Client X/Meeting.md", IsPublic: true},
+		{Title: "Plan", Slug: "secret/plan", Path: "Secret/Plan.md"},
+	}
+	notesMap := make(map[string]model.Note)
+	for _, note := range notes {
+		notesMap[note.Slug] = note
+	}
+	tree := engine.BuildTreeWithFolders(notes, map[string]engine.FolderSettings{"Projects": {Description: "Client work"}})
+
+	cfg := &config.Config{SiteTitle: "Pluie"}
+	server := &Server{NotesService: engine.NewNotesService(&notesMap, tree, nil), rs: template.NewResource(cfg), cfg: cfg}
+	fuegoServer := fuego.NewServer()
+	server.registerRoutes(fuegoServer)
+
+	get := func(path string) (int, string) {
+		w := httptest.NewRecorder()
+		fuegoServer.Mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Code, w.Body.String()
+	}
+
+	code, body := get("/projects")
+	if code != http.StatusOK || !strings.Contains(body, `id="folder-index"`) || !strings.Contains(body, "Client work") {
+		t.Fatalf("expected the index page of the folder, status %d:\n%s", code, body)
+	}
+	if !strings.Contains(body, `href="/projects/kickoff"`) || !strings.Contains(body, `href="/projects/client-x"`) {
+		t.Error("expected the notes and subfolders of the folder")
+	}
+	// The sidebar shows the tree, private notes are left out of it when loading the vault
+	if strings.Contains(body[strings.Index(body, `id="folder-index"`):], "Budget") {
+		t.Error("private notes should not be listed")
+	}
+
+	if _, body := get("/projects/client-x"); !strings.Contains(body, `href="/projects/client-x/meeting"`) || !strings.Contains(body, `href="/projects"`) {
+		t.Error("expected the nested folder page, with breadcrumbs to its parent")
+	}
+	if _, body := get("/secret"); strings.Contains(body, `id="folder-index"`) || !strings.Contains(body, "404") {
+		t.Error("folders with only private notes should not be found")
+	}
+}
+
+func TestGetRobots(t *testing.T) {
+	cfg := &config.Config{SiteTitle: "Pluie", SiteURL: "https://notes.example.com", RobotsDisallow: "/drafts/"}
+	notesMap := map[string]model.Note{}
+	server := &Server{NotesService: engine.NewNotesService(&notesMap, engine.BuildTree(nil), nil), rs: template.NewResource(cfg), cfg: cfg}
+	fuegoServer := fuego.NewServer()
+	server.registerRoutes(fuegoServer)
+
+	w := httptest.NewRecorder()
+	fuegoServer.Mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/robots.txt", nil))
+	expected := "User-agent: *\nDisallow: /-/search\nDisallow: /drafts/\n\nSitemap: https://notes.example.com/sitemap.xml\n"
+	if w.Code != http.StatusOK || w.Body.String() != expected || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") {
+		t.Errorf("unexpected robots.txt, status %d, content type %q:\n%s", w.Code, w.Header().Get("Content-Type"), w.Body.String())
+	}
+}
+
+func TestGetNote_NoIndex(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "Hidden.md", "---\nnoindex: true\n---\nNot for search engines")
+	writeTestFile(t, dir, "Listed.md", "For everyone")
+
+	cfg := &config.Config{Path: dir, SiteTitle: "Pluie", PublicByDefault: true}
+	notesMap, tree, tagIndex, err := loadNotes(dir, cfg)
+	if err != nil {
+		t.Fatalf("loadNotes() error: %v", err)
+	}
+	server := &Server{NotesService: engine.NewNotesService(notesMap, tree, tagIndex), rs: template.NewResource(cfg), cfg: cfg}
+	fuegoServer := fuego.NewServer()
+	server.registerRoutes(fuegoServer)
+
+	get := func(path string) string {
+		w := httptest.NewRecorder()
+		fuegoServer.Mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Body.String()
+	}
+
+	if !strings.Contains(get("/hidden"), `<meta name="robots" content="noindex">`) {
+		t.Error("expected a robots noindex tag on the noindex note")
+	}
+	if strings.Contains(get("/listed"), `name="robots"`) {
+		t.Error("other notes should not get a robots tag")
+	}
+	if sitemap := get("/sitemap.xml"); strings.Contains(sitemap, "/hidden") || !strings.Contains(sitemap, "/listed") {
+		t.Errorf("the noindex note should be left out of the sitemap:\n%s", sitemap)
+	}
+	if feed := get("/-/feed.xml"); strings.Contains(feed, "/hidden") {
+		t.Errorf("the noindex note should be left out of the feed:\n%s", feed)
+	}
+}
+
+func TestDrafts(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "Garden.md", "---\npublish: true\n---\nTomatoes")
+	writeTestFile(t, dir, "Post.md", "---\npublish: false\ndraft: true\n---\nWork in progress")
+	writeTestFile(t, dir, "Ideas/.pluie", "---\ndraft: true\n---\n")
+	writeTestFile(t, dir, "Ideas/Boat.md", "---\npublish: true\n---\nA boat")
+	writeTestFile(t, dir, "Ideas/Done.md", "---\npublish: true\ndraft: false\n---\nFinished")
+
+	newServer := func(cfg *config.Config) (func(path string) (int, string), *engine.NotesService) {
+		notesMap, tree, tagIndex, err := loadNotes(dir, cfg)
+		if err != nil {
+			t.Fatalf("loadNotes() error: %v", err)
+		}
+		notesService := engine.NewNotesService(notesMap, tree, tagIndex)
+		server := &Server{NotesService: notesService, rs: template.NewResource(cfg), cfg: cfg}
+		fuegoServer := fuego.NewServer()
+		server.registerRoutes(fuegoServer)
+		return func(path string) (int, string) {
+			w := httptest.NewRecorder()
+			fuegoServer.Mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+			return w.Code, w.Body.String()
+		}, notesService
+	}
+
+	get, _ := newServer(&config.Config{Path: dir, SiteTitle: "Pluie", Mode: "server", ShowDrafts: true})
+	for _, path := range []string{"/post", "/ideas/boat"} {
+		if code, body := get(path); code != http.StatusOK || !strings.Contains(body, `class="draft-badge`) {
+			t.Errorf("%s: status = %d, want the draft shown with its badge", path, code)
+		}
+	}
+	// Only the sidebar entries of the two drafts have a badge
+	if code, body := get("/ideas/done"); code != http.StatusOK || strings.Count(body, `class="draft-badge`) != 2 {
+		t.Errorf("a note setting draft: false in a drafts folder should not be a draft")
+	}
+	if _, sitemap := get("/sitemap.xml"); strings.Contains(sitemap, "/post") || strings.Contains(sitemap, "/ideas/boat") || !strings.Contains(sitemap, "/ideas/done") {
+		t.Errorf("drafts should be left out of the sitemap:\n%s", sitemap)
+	}
+	if _, feed := get("/-/feed.xml"); strings.Contains(feed, "/post") || strings.Contains(feed, "/ideas/boat") {
+		t.Errorf("drafts should be left out of the feed:\n%s", feed)
+	}
+	if _, notes := get("/-/api/notes"); strings.Contains(notes, `"post"`) || strings.Contains(notes, "ideas/boat") {
+		t.Errorf("drafts should be left out of the API:\n%s", notes)
+	}
+	if code, _ := get("/-/api/notes/post"); code != http.StatusNotFound {
+		t.Errorf("API draft: status = %d, want 404", code)
+	}
+
+	// Without SHOW_DRAFTS, and in static sites whatever it says, drafts are not loaded at all
+	for _, cfg := range []*config.Config{
+		{Path: dir, SiteTitle: "Pluie", Mode: "server"},
+		{Path: dir, SiteTitle: "Pluie", Mode: "static", ShowDrafts: true},
+	} {
+		_, notesService := newServer(cfg)
+		for _, slug := range []string{"post", "ideas/boat"} {
+			if _, ok := notesService.GetNote(slug); ok {
+				t.Errorf("mode %s: draft %s should not be loaded", cfg.Mode, slug)
+			}
+		}
+		if _, ok := notesService.GetNote("ideas/done"); !ok {
+			t.Errorf("mode %s: the published note of the drafts folder should be loaded", cfg.Mode)
+		}
+	}
+}
+
+func TestGetSitemap(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "Garden.md", "---\npublish: true\ndate: 2024-02-01\n---\n#plants")
+	writeTestFile(t, dir, "Diary.md", "---\npublish: false\n---\n#private-life")
+
+	newServer := func(siteURL string) *fuego.Server {
+		cfg := &config.Config{Path: dir, SiteTitle: "Pluie", SiteURL: siteURL}
+		notesMap, tree, tagIndex, err := loadNotes(dir, cfg)
+		if err != nil {
+			t.Fatalf("loadNotes() error: %v", err)
+		}
+		server := &Server{NotesService: engine.NewNotesService(notesMap, tree, tagIndex), rs: template.NewResource(cfg), cfg: cfg}
+		fuegoServer := fuego.NewServer()
+		server.registerRoutes(fuegoServer)
+		return fuegoServer
+	}
+	get := func(server *fuego.Server) string {
+		t.Helper()
+		w := httptest.NewRecorder()
+		server.Mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://wiki.local/sitemap.xml", nil))
+		if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "application/xml") {
+			t.Fatalf("expected the sitemap, got %d %q", w.Code, w.Header().Get("Content-Type"))
+		}
+		return w.Body.String()
+	}
+
+	sitemap := get(newServer("https://notes.example.com"))
+	for _, loc := range []string{
+		"<loc>https://notes.example.com/</loc>",
+		"<loc>https://notes.example.com/garden</loc>\n    <lastmod>2024-02-01T00:00:00+00:00</lastmod>",
+		"<loc>https://notes.example.com/-/tag/plants</loc>",
+	} {
+		if !strings.Contains(sitemap, loc) {
+			t.Errorf("expected %q in sitemap:\n%s", loc, sitemap)
+		}
+	}
+	if strings.Contains(sitemap, "diary") || strings.Contains(sitemap, "private-life") {
+		t.Errorf("private notes and their tags should not be listed:\n%s", sitemap)
+	}
+
+	// Without SITE_URL, the host of the request is used
+	if sitemap := get(newServer("")); !strings.Contains(sitemap, "<loc>http://wiki.local/garden</loc>") {
+		t.Errorf("expected URLs on the request host:\n%s", sitemap)
+	}
+}
+
+func TestGetFeed(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "Garden.md", "---\npublish: true\ndate: 2024-02-01\n---\nTomatoes & <peppers> grow well.")
+	writeTestFile(t, dir, "Recent.md", "---\npublish: true\nmodified: 2024-05-01\n---\nThe most recent note of all.")
+	writeTestFile(t, dir, "Diary.md", "---\npublish: false\nmodified: 2025-01-01\n---\nDear diary, today...")
+
+	cfg := &config.Config{Path: dir, SiteTitle: "Pluie", SiteURL: "https://notes.example.com", FeedSize: 20}
+	notesMap, tree, tagIndex, err := loadNotes(dir, cfg)
+	if err != nil {
+		t.Fatalf("loadNotes() error: %v", err)
+	}
+	server := &Server{NotesService: engine.NewNotesService(notesMap, tree, tagIndex), rs: template.NewResource(cfg), cfg: cfg}
+	fuegoServer := fuego.NewServer()
+	server.registerRoutes(fuegoServer)
+
+	w := httptest.NewRecorder()
+	fuegoServer.Mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/-/feed.xml", nil))
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "application/rss+xml") {
+		t.Fatalf("expected the feed, got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+
+	feed := w.Body.String()
+	recent := strings.Index(feed, "<link>https://notes.example.com/recent</link>")
+	garden := strings.Index(feed, "<link>https://notes.example.com/garden</link>")
+	if recent < 0 || garden < 0 || recent > garden {
+		t.Errorf("expected the recent note before the garden one:\n%s", feed)
+	}
+	if !strings.Contains(feed, "Tomatoes &amp;amp; &amp;lt;peppers&amp;gt; grow well.") {
+		t.Errorf("descriptions should be escaped for HTML in XML:\n%s", feed)
+	}
+	if strings.Contains(feed, "diary") {
+		t.Errorf("private notes should not be listed:\n%s", feed)
+	}
+}
+
+func TestGetAttachment(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "Trip.md", "---\npublish: true\n---\n![[beach.png|300]]")
+	writeTestFile(t, dir, "Diary.md", "---\npublish: false\n---\n![[secret.png]]")
+	writeTestFile(t, dir, "photos/beach.png", "beach pixels")
+	writeTestFile(t, dir, "secret.png", "secret pixels")
+	writeTestFile(t, dir, "unused.png", "unused pixels")
+
+	cfg := &config.Config{Path: dir, SiteTitle: "Pluie"}
+	notesMap, tree, tagIndex, err := loadNotes(dir, cfg)
+	if err != nil {
+		t.Fatalf("loadNotes() error: %v", err)
+	}
+	server := &Server{NotesService: engine.NewNotesService(notesMap, tree, tagIndex), rs: template.NewResource(cfg), cfg: cfg}
+	fuegoServer := fuego.NewServer()
+	server.registerRoutes(fuegoServer)
+	get := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		fuegoServer.Mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		return w
+	}
+
+	if w := get("/trip"); !strings.Contains(w.Body.String(), `<img src="/-/attachments/photos/beach.png" loading="lazy" width="300" alt="beach"`) {
+		t.Errorf("expected the embedded image in the note:\n%s", w.Body.String())
+	}
+	if w := get("/-/attachments/photos/beach.png"); w.Code != http.StatusOK || w.Body.String() != "beach pixels" {
+		t.Errorf("expected the attachment, got %d %q", w.Code, w.Body.String())
+	}
+
+	// Only the attachments of public notes are served
+	for _, target := range []string{"/-/attachments/secret.png", "/-/attachments/unused.png", "/-/attachments/Trip.md", "/-/attachments/../Diary.md"} {
+		if w := get(target); w.Code == http.StatusOK && !strings.Contains(w.Body.String(), "<html") {
+			t.Errorf("%s should not be served, got %q", target, w.Body.String())
+		}
+	}
+}
+
+func TestGetAttachment_MarkdownLinks(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "journal/2024/Trip.md", "---\npublish: true\n---\n![](../../attachments/beach.png) [guide](/attachments/guide.pdf) ![](../../attachments/song.mp3) ![](../../private/secret.png)")
+	writeTestFile(t, dir, "attachments/beach.png", "beach pixels")
+	writeTestFile(t, dir, "attachments/guide.pdf", "pdf bytes")
+	writeTestFile(t, dir, "attachments/song.mp3", "mp3 bytes")
+	writeTestFile(t, dir, "private/.pluie", "---\npublish: false\n---\n")
+	writeTestFile(t, dir, "private/secret.png", "secret pixels")
+
+	cfg := &config.Config{Path: dir, SiteTitle: "Pluie"}
+	notesMap, tree, tagIndex, err := loadNotes(dir, cfg)
+	if err != nil {
+		t.Fatalf("loadNotes() error: %v", err)
+	}
+	server := &Server{NotesService: engine.NewNotesService(notesMap, tree, tagIndex), rs: template.NewResource(cfg), cfg: cfg}
+	fuegoServer := fuego.NewServer()
+	server.registerRoutes(fuegoServer)
+	get := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		fuegoServer.Mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		return w
+	}
+
+	page := get("/journal/2024/trip").Body.String()
+	for _, expected := range []string{
+		`<img src="/-/attachments/attachments/beach.png" loading="lazy"`,
+		`href="/-/attachments/attachments/guide.pdf"`,
+		`<audio controls preload="metadata" src="/-/attachments/attachments/song.mp3"></audio>`,
+	} {
+		if !strings.Contains(page, expected) {
+			t.Errorf("expected %s in the note:\n%s", expected, page)
+		}
+	}
+
+	for target, contentType := range map[string]string{
+		"/-/attachments/attachments/beach.png": "image/png",
+		"/-/attachments/attachments/guide.pdf": "application/pdf",
+		"/-/attachments/attachments/song.mp3":  "audio/mpeg",
+	} {
+		w := get(target)
+		if w.Code != http.StatusOK || w.Header().Get("Content-Type") != contentType || w.Header().Get("Cache-Control") != "public, max-age=3600" {
+			t.Errorf("%s: got %d, Content-Type %q, Cache-Control %q", target, w.Code, w.Header().Get("Content-Type"), w.Header().Get("Cache-Control"))
+		}
+	}
+
+	// Attachments of private folders are not served, even linked by a public note
+	if w := get("/-/attachments/private/secret.png"); w.Code == http.StatusOK && !strings.Contains(w.Body.String(), "<html") {
+		t.Errorf("the attachment of a private folder should not be served, got %q", w.Body.String())
+	}
+}
+
+func TestGetUnifiedSearch_ContentMatches(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "Compost.md", "---\npublish: true\n---\nCompost feeds the garden.")
+	writeTestFile(t, dir, "Tomatoes.md", "---\npublish: true\n---\n## Compost\n\nTomatoes love compost.")
+	writeTestFile(t, dir, "Soil.md", "---\npublish: true\n---\nGood soil starts with compost and worms.")
+	writeTestFile(t, dir, "Diary.md", "---\npublish: false\n---\nSecret compost recipe.")
+
+	cfg := &config.Config{Path: dir, SiteTitle: "Pluie"}
+	notesMap, tree, tagIndex, err := loadNotes(dir, cfg)
+	if err != nil {
+		t.Fatalf("loadNotes() error: %v", err)
+	}
+	server := &Server{NotesService: engine.NewNotesService(notesMap, tree, tagIndex), rs: template.NewResource(cfg), cfg: cfg}
+	fuegoServer := fuego.NewServer()
+	server.registerRoutes(fuegoServer)
+
+	w := httptest.NewRecorder()
+	fuegoServer.Mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/-/search?q=compost", nil))
+	page := w.Body.String()
+
+	_, contentSection, found := strings.Cut(page, `id="content-results"`)
+	if !found {
+		t.Fatalf("expected a content results section:\n%s", page)
+	}
+	contentSection, _, _ = strings.Cut(contentSection, `id="ai-section"`)
+	if !strings.Contains(contentSection, `href="/soil"`) || !strings.Contains(contentSection, `<mark class="bg-yellow-200 rounded-sm dark:bg-yellow-700 dark:text-white">compost</mark>`) {
+		t.Errorf("expected the soil note with its highlighted snippet:\n%s", contentSection)
+	}
+	// Title and heading matches are not repeated, private notes are never found
+	for _, slug := range []string{"compost", "tomatoes", "diary"} {
+		if strings.Contains(contentSection, `href="/`+slug+`"`) {
+			t.Errorf("%s should not be a content match", slug)
+		}
+	}
+	if !strings.Contains(page, "seen=compost%2Ctomatoes%2Csoil") {
+		t.Error("content matches should be excluded from the semantic results")
+	}
+}
+
+func TestGetGraph(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "Garden.md", "---\npublish: true\ntags: [plants]\n---\nSee [[Recipes]].")
+	writeTestFile(t, dir, "Recipes.md", "---\npublish: true\n---\nBack to the [[Garden]], and my [[Diary]].")
+	writeTestFile(t, dir, "Diary.md", "---\npublish: false\n---\nAbout the [[Garden]].")
+
+	cfg := &config.Config{Path: dir, SiteTitle: "Pluie"}
+	notesMap, tree, tagIndex, err := loadNotes(dir, cfg)
+	if err != nil {
+		t.Fatalf("loadNotes() error: %v", err)
+	}
+	server := &Server{NotesService: engine.NewNotesService(notesMap, tree, tagIndex), rs: template.NewResource(cfg), cfg: cfg}
+	fuegoServer := fuego.NewServer()
+	server.registerRoutes(fuegoServer)
+
+	w := httptest.NewRecorder()
+	fuegoServer.Mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/-/graph.json", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	var response api.Envelope[api.Graph]
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	graph := response.Data
+	if response.Version != api.Version || len(graph.Nodes) != 2 || len(graph.Edges) != 2 {
+		t.Fatalf("expected the 2 public notes linking each other, got %+v", response)
+	}
+	if graph.Nodes[0].Slug != "garden" || strings.Join(graph.Nodes[0].Tags, ",") != "plants" || graph.Nodes[1].Tags == nil {
+		t.Errorf("unexpected nodes %+v", graph.Nodes)
+	}
+	if strings.Contains(w.Body.String(), "diary") {
+		t.Errorf("private notes should not be in the graph: %+v", graph)
+	}
+
+	w = httptest.NewRecorder()
+	fuegoServer.Mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/-/graph", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `id="graph-canvas"`) || !strings.Contains(w.Body.String(), "/static/graph.js") {
+		t.Errorf("expected the graph page, got %d:\n%s", w.Code, w.Body.String())
+	}
+}
+
+func TestGetTag_Index(t *testing.T) {
+	notes := []model.Note{
+		{Title: "Go", Slug: "go", IsPublic: true, Content: "#golang/web and #shared"},
+		{Title: "Recipes", Slug: "recipes", IsPublic: true, Content: "#shared"},
+	}
+	notesMap := map[string]model.Note{}
+	for _, note := range notes {
+		notesMap[note.Slug] = note
+	}
+	cfg := &config.Config{}
+	server := &Server{
+		NotesService: engine.NewNotesService(&notesMap, engine.BuildTree(notes), engine.BuildTagIndex(notes)),
+		rs:           template.NewResource(cfg),
+		cfg:          cfg,
+	}
+	fuegoServer := fuego.NewServer()
+	server.registerRoutes(fuegoServer)
+
+	w := httptest.NewRecorder()
+	fuegoServer.Mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/-/tag/", nil))
+	body := w.Body.String()
+	if w.Code != http.StatusOK || !strings.Contains(body, `id="tag-index"`) {
+		t.Fatalf("expected the tag index, got %d:\n%s", w.Code, body)
+	}
+	if !strings.Contains(body, `href="/-/tag/golang/web"`) || !strings.Contains(body, `#shared<span class="text-gray-500 dark:text-gray-400">2</span>`) {
+		t.Errorf("expected every tag with its count:\n%s", body)
+	}
+
+	w = httptest.NewRecorder()
+	fuegoServer.Mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/-/tags", nil))
+	if cloud := w.Body.String(); w.Code != http.StatusOK || cloud != body {
+		t.Errorf("/-/tags should render the same tag cloud as /-/tag/, got %d:\n%s", w.Code, cloud)
+	}
+
+	// golang has no note of its own, it groups golang/web and has no page. The most used tag is
+	// the largest.
+	if !strings.Contains(body, `<span class="px-3 py-1 text-sm text-gray-500 dark:text-gray-400">#golang</span>`) ||
+		strings.Contains(body, `href="/-/tag/golang"`) {
+		t.Errorf("expected golang as a plain group of its nested tags:\n%s", body)
+	}
+	if !strings.Contains(body, `py-1 text-2xl bg-gray-100`) {
+		t.Errorf("expected #shared in the largest size:\n%s", body)
+	}
+
+	w = httptest.NewRecorder()
+	fuegoServer.Mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/-/tag/shared", nil))
+	if !strings.Contains(w.Body.String(), `id="all-tags-link" href="/-/tags"`) {
+		t.Errorf("tag pages should link to the tag cloud:\n%s", w.Body.String())
+	}
+}
+
+func TestGetTag_NestedTags(t *testing.T) {
+	notes := []model.Note{
+		{Title: "Intro", Slug: "intro", IsPublic: true, Content: "#golang"},
+		{Title: "Server", Slug: "server", IsPublic: true, Content: "#golang #golang/web"},
+		{Title: "Htmx", Slug: "htmx", IsPublic: true, Content: "#golang/web/htmx"},
+	}
+	notesMap := map[string]model.Note{}
+	for _, note := range notes {
+		notesMap[note.Slug] = note
+	}
+	cfg := &config.Config{}
+	server := &Server{
+		NotesService: engine.NewNotesService(&notesMap, engine.BuildTree(notes), engine.BuildTagIndex(notes)),
+		rs:           template.NewResource(cfg),
+		cfg:          cfg,
+	}
+	fuegoServer := fuego.NewServer()
+	server.registerRoutes(fuegoServer)
+
+	serve := func(path string) string {
+		w := httptest.NewRecorder()
+		fuegoServer.Mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s: status = %d, want 200", path, w.Code)
+		}
+		return w.Body.String()
+	}
+
+	// The sidebar lists every note, only the main content matters
+	_, body, _ := strings.Cut(serve("/-/tag/golang"), "Tag: #golang")
+	if !strings.HasPrefix(body, " (3 notes)") {
+		t.Errorf("the parent tag page should count the notes of its nested tags once:\n%s", body)
+	}
+	for _, header := range []string{">#golang</a></h2>", ">#golang/web</a></h2>", ">#golang/web/htmx</a></h2>"} {
+		if !strings.Contains(body, header) {
+			t.Errorf("expected the %s group header:\n%s", header, body)
+		}
+	}
+	if before, after, _ := strings.Cut(body, ">#golang/web</a></h2>"); !strings.Contains(before, `href="/intro"`) || !strings.Contains(after, `href="/server"`) {
+		t.Errorf("server should be in the golang/web group, intro in the golang one:\n%s", body)
+	}
+	if !strings.Contains(body, `id="exact-tag-link" href="/-/tag/golang?exact=1"`) {
+		t.Errorf("expected a link to the notes of the tag alone:\n%s", body)
+	}
+
+	_, exact, _ := strings.Cut(serve("/-/tag/golang?exact=1"), "Tag: #golang")
+	if !strings.HasPrefix(exact, " (2 notes)") || strings.Contains(exact, `href="/htmx"`) || strings.Contains(exact, "</a></h2>") {
+		t.Errorf("?exact=1 should only list the notes tagged golang, without headers:\n%s", exact)
+	}
+}
+
+func TestGetUnlinkedMentions(t *testing.T) {
+	garden := model.Note{Title: "Garden", Slug: "garden", IsPublic: true, Content: "Tomatoes",
+		ReferencedBy: []model.NoteReference{{Slug: "linked", Title: "Linked"}}}
+	notes := []model.Note{
+		garden,
+		{Title: "Diary", Slug: "diary", IsPublic: true, Content: "Worked in the garden, the Garden is green."},
+		{Title: "Linked", Slug: "linked", IsPublic: true, Content: "See [[Garden]], the garden."},
+		{Title: "Secret", Slug: "secret", Content: "My secret garden."},
+	}
+	notesMap := map[string]model.Note{}
+	for _, note := range notes {
+		notesMap[note.Slug] = note
+	}
+	cfg := &config.Config{}
+	server := &Server{
+		NotesService: engine.NewNotesService(&notesMap, engine.BuildTree(notes), engine.BuildTagIndex(notes)),
+		rs:           template.NewResource(cfg),
+		cfg:          cfg,
+	}
+	fuegoServer := fuego.NewServer()
+	server.registerRoutes(fuegoServer)
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		fuegoServer.Mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	// The note page loads the mentions after the page
+	if page := serve("/garden").Body.String(); !strings.Contains(page, `<div id="unlinked-mentions" hx-get="/-/mentions/garden" hx-trigger="load" hx-swap="outerHTML"></div>`) {
+		t.Errorf("the note page should load its unlinked mentions lazily:\n%s", page)
+	}
+
+	w := serve("/-/mentions/garden")
+	body := w.Body.String()
+	if w.Code != http.StatusOK || !strings.HasPrefix(body, `<details id="unlinked-mentions"`) || strings.Contains(body, "<html") {
+		t.Fatalf("expected the unlinked mentions fragment, got %d:\n%s", w.Code, body)
+	}
+	if !strings.Contains(body, `Unlinked mentions<span class="ml-2 px-2 py-0.5 text-xs font-medium bg-gray-100 text-gray-600 rounded-full dark:bg-gray-800 dark:text-gray-400">1</span>`) ||
+		!strings.Contains(body, `href="/diary"`) || !strings.Contains(body, "Worked in the <mark") {
+		t.Errorf("expected the diary mention with a count and a snippet:\n%s", body)
+	}
+	if strings.Contains(body, "/linked") || strings.Contains(body, "secret") {
+		t.Errorf("linking and private notes should not be listed:\n%s", body)
+	}
+
+	if w := serve("/-/mentions/diary"); w.Code != http.StatusOK || w.Body.Len() != 0 {
+		t.Errorf("a note without mentions should get nothing, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := serve("/-/mentions/secret"); w.Code != http.StatusNotFound {
+		t.Errorf("private note: status = %d, want 404", w.Code)
+	}
+}
+
+func TestGetTreeFolder(t *testing.T) {
+	notes := []model.Note{
+		{Title: "Deep", Slug: "a/b/c/deep", Path: "A/B/C/Deep.md", IsPublic: true},
+		{Title: "Other", Slug: "x/y/z/other", Path: "X/Y/Z/Other.md", IsPublic: true},
+		{Title: "Sibling", Slug: "x/y/sibling", Path: "X/Y/Sibling.md", IsPublic: true},
+	}
+	notesMap := map[string]model.Note{}
+	for _, note := range notes {
+		notesMap[note.Slug] = note
+	}
+	cfg := &config.Config{SidebarLazy: true}
+	server := &Server{
+		NotesService: engine.NewNotesService(&notesMap, engine.BuildTreeWithFolders(notes, nil), nil),
+		rs:           template.NewResource(cfg),
+		cfg:          cfg,
+	}
+	fuegoServer := fuego.NewServer()
+	server.registerRoutes(fuegoServer)
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		fuegoServer.Mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	// Deep links show their folders, the other deep folders are loaded when opened
+	page := serve("/a/b/c/deep").Body.String()
+	if !strings.Contains(page, `href="/a/b/c/deep"`) || strings.Contains(page, `href="/x/y/sibling"`) || !strings.Contains(page, `hx-get="/-/tree?path=X%2FY"`) {
+		t.Errorf("expected the sidebar with the folder of the note only:\n%s", page)
+	}
+
+	w := serve("/-/tree?path=X%2FY")
+	body := w.Body.String()
+	if w.Code != http.StatusOK || !strings.HasPrefix(body, `<ul class="ml-4" id="folder-X/Y" style="display: block;">`) || strings.Contains(body, "<html") {
+		t.Fatalf("expected the folder fragment, got %d:\n%s", w.Code, body)
+	}
+	if !strings.Contains(body, `href="/x/y/sibling"`) || !strings.Contains(body, `hx-get="/-/tree?path=X%2FY%2FZ"`) {
+		t.Errorf("expected the notes of the folder and its subfolder to load:\n%s", body)
+	}
+
+	for _, path := range []string{"/-/tree?path=Missing", "/-/tree?path=X%2FY%2FSibling", "/-/tree"} {
+		if w := serve(path); w.Code != http.StatusNotFound {
+			t.Errorf("GET %s = %d, want 404", path, w.Code)
+		}
+	}
+}
+
+func TestGetNote_SharedFolder(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "Clients/Acme/.pluie", "---\naccess: private\n---\n")
+	writeTestFile(t, dir, "Clients/Acme/Report.md", "---\ntags: [quarterly]\n---\n# Acme report\n\nRevenue is up.\n\n![[chart.png]]\n")
+	writeTestFile(t, dir, "Clients/Acme/chart.png", "png")
+	writeTestFile(t, dir, "Clients/Globex/.pluie", "---\naccess: private\n---\n")
+	writeTestFile(t, dir, "Clients/Globex/Plan.md", "Globex plan\n")
+	writeTestFile(t, dir, "Welcome.md", "---\ntags: [quarterly]\n---\nHello\n")
+
+	cfg := &config.Config{Path: dir, SiteTitle: "Pluie", PublicByDefault: true, ShareKeyFolders: map[string]string{"Clients/Acme": "abc123"}}
+	notesMap, tree, tagIndex, err := loadNotes(dir, cfg)
+	if err != nil {
+		t.Fatalf("loadNotes() error: %v", err)
+	}
+	server := &Server{NotesService: engine.NewNotesService(notesMap, tree, tagIndex), rs: template.NewResource(cfg), cfg: cfg}
+	fuegoServer := fuego.NewServer()
+	server.registerRoutes(fuegoServer)
+
+	serve := func(path string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		for _, cookie := range cookies {
+			r.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		fuegoServer.Mux.ServeHTTP(w, r)
+		return w
+	}
+
+	t.Run("hidden without the key", func(t *testing.T) {
+		for _, path := range []string{"/clients/acme/report", "/clients/acme/report?key=wrong"} {
+			if body := serve(path).Body.String(); strings.Contains(body, "Revenue is up.") {
+				t.Errorf("%s: shared note served without its key", path)
+			}
+		}
+	})
+
+	var shareCookie *http.Cookie
+	t.Run("the key unlocks the note and is remembered", func(t *testing.T) {
+		w := serve("/clients/acme/report?key=abc123")
+		body := w.Body.String()
+		if !strings.Contains(body, "Revenue is up.") {
+			t.Fatalf("shared note not served with its key:\n%s", body)
+		}
+		if !strings.Contains(body, `<meta name="robots" content="noindex">`) {
+			t.Error("expected a robots noindex tag on the shared note")
+		}
+		if got := w.Header().Get("Referrer-Policy"); got != "no-referrer" {
+			t.Errorf("Referrer-Policy = %q, want no-referrer", got)
+		}
+		for _, cookie := range w.Result().Cookies() {
+			if cookie.Name == shareCookieName {
+				shareCookie = cookie
+			}
+		}
+		if shareCookie == nil || !shareCookie.HttpOnly {
+			t.Fatalf("expected an HttpOnly share cookie, got %v", w.Result().Cookies())
+		}
+		if body := serve("/clients/acme/report", shareCookie).Body.String(); !strings.Contains(body, "Revenue is up.") {
+			t.Error("shared note not served with the share cookie")
+		}
+	})
+
+	t.Run("the key only unlocks its folder", func(t *testing.T) {
+		if body := serve("/clients/globex/plan?key=abc123").Body.String(); strings.Contains(body, "Globex plan") {
+			t.Error("a folder without share key should not be served")
+		}
+	})
+
+	t.Run("attachments of the shared notes need the key", func(t *testing.T) {
+		if w := serve(engine.AttachmentsPrefix + "Clients/Acme/chart.png"); w.Code != http.StatusNotFound {
+			t.Errorf("attachment without the key: status = %d, want 404", w.Code)
+		}
+		w := serve(engine.AttachmentsPrefix+"Clients/Acme/chart.png", shareCookie)
+		if w.Code != http.StatusOK {
+			t.Fatalf("attachment with the share cookie: status = %d, want 200", w.Code)
+		}
+		if got := w.Header().Get("Cache-Control"); !strings.HasPrefix(got, "private") {
+			t.Errorf("Cache-Control = %q, want private", got)
+		}
+	})
+
+	t.Run("left out of the listings", func(t *testing.T) {
+		for _, path := range []string{"/welcome", "/-/search?q=Revenue", "/-/search?q=acme", "/sitemap.xml", "/-/feed.xml", "/-/tag/quarterly", "/-/graph.json"} {
+			if body := serve(path, shareCookie).Body.String(); strings.Contains(body, "Acme report") || strings.Contains(body, "clients/acme/report") {
+				t.Errorf("%s lists the shared note", path)
+			}
+		}
+	})
+}
+
+func TestGetNote_PrintView(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "Report.md", "---\npublish: true\nauthor: Ada\n---\n# Report\n\nThe findings.\n")
+	writeTestFile(t, dir, "Diary.md", "---\npublish: false\n---\nSecret\n")
+
+	cfg := &config.Config{Path: dir, SiteTitle: "Pluie", Mode: "server"}
+	notesMap, tree, tagIndex, err := loadNotes(dir, cfg)
+	if err != nil {
+		t.Fatalf("loadNotes() error: %v", err)
+	}
+	server := &Server{NotesService: engine.NewNotesService(notesMap, tree, tagIndex), rs: template.NewResource(cfg), cfg: cfg}
+	fuegoServer := fuego.NewServer()
+	server.registerRoutes(fuegoServer)
+
+	get := func(path string) string {
+		w := httptest.NewRecorder()
+		fuegoServer.Mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Body.String()
+	}
+
+	page := get("/report")
+	if !strings.Contains(page, `href="/report?print=1"`) || strings.Contains(page, `id="print-view"`) {
+		t.Error("expected the note page with a link to its print view")
+	}
+
+	printView := get("/report?print=1")
+	for _, expected := range []string{`id="print-view"`, "The findings.", `id="print-properties"`, "Ada"} {
+		if !strings.Contains(printView, expected) {
+			t.Errorf("expected %q in the print view", expected)
+		}
+	}
+	if strings.Contains(printView, `id="toc-sidebar"`) || strings.Contains(printView, `id="mobile-sidebar"`) {
+		t.Error("the print view should have no sidebar")
+	}
+
+	if diary := get("/diary?print=1"); strings.Contains(diary, "Secret") {
+		t.Error("private notes should have no print view")
+	}
+}
+
+func TestGetRawNote(t *testing.T) {
+	dir := t.TempDir()
+	raw := "---\npublish: true\ntitle: \"Q&A\"\ntags:\n  - go\n---\n# Questions\n\nSee [[Other]] and #go.\r\nTrailing spaces  \n"
+	writeTestFile(t, dir, "Notes/Q&A.md", raw)
+	writeTestFile(t, dir, "Diary.md", "---\npublish: false\n---\nSecret\n")
+
+	cfg := &config.Config{Path: dir, SiteTitle: "Pluie"}
+	notesMap, tree, tagIndex, err := loadNotes(dir, cfg)
+	if err != nil {
+		t.Fatalf("loadNotes() error: %v", err)
+	}
+	server := &Server{NotesService: engine.NewNotesService(notesMap, tree, tagIndex), rs: template.NewResource(cfg), cfg: cfg}
+	fuegoServer := fuego.NewServer()
+	server.registerRoutes(fuegoServer)
+
+	var slug string
+	for _, note := range *notesMap {
+		slug = note.Slug
+	}
+
+	w := httptest.NewRecorder()
+	fuegoServer.Mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/-/raw/"+slug, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	if got := w.Body.String(); got != raw {
+		t.Errorf("body = %q, want the file byte for byte %q", got, raw)
+	}
+	if got := w.Header().Get("Content-Type"); got != "text/markdown; charset=utf-8" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	if _, params, err := mime.ParseMediaType(w.Header().Get("Content-Disposition")); err != nil || params["filename"] != "Q&A.md" {
+		t.Errorf("Content-Disposition = %q, want the file name", w.Header().Get("Content-Disposition"))
+	}
+
+	for _, path := range []string{"/-/raw/diary", "/-/raw/missing"} {
+		w := httptest.NewRecorder()
+		fuegoServer.Mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusNotFound || strings.Contains(w.Body.String(), "Secret") {
+			t.Errorf("%s: status = %d, want 404", path, w.Code)
+		}
+	}
+}
+
+func TestGetRandomNote(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "Garden.md", "---\npublish: true\ntags: [plants]\n---\nTomatoes\n")
+	writeTestFile(t, dir, "Bread.md", "---\npublish: true\n---\nFlour\n")
+	writeTestFile(t, dir, "Diary.md", "---\npublish: false\ntags: [plants]\n---\nSecret\n")
+
+	cfg := &config.Config{Path: dir, SiteTitle: "Pluie"}
+	notesMap, tree, tagIndex, err := loadNotes(dir, cfg)
+	if err != nil {
+		t.Fatalf("loadNotes() error: %v", err)
+	}
+	server := &Server{NotesService: engine.NewNotesService(notesMap, tree, tagIndex), rs: template.NewResource(cfg), cfg: cfg}
+	fuegoServer := fuego.NewServer()
+	server.registerRoutes(fuegoServer)
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		fuegoServer.Mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	for range 20 {
+		w := serve("/-/random")
+		if w.Code != http.StatusFound {
+			t.Fatalf("status = %d, want 302", w.Code)
+		}
+		if location := w.Header().Get("Location"); location != "/garden" && location != "/bread" {
+			t.Errorf("Location = %q, want a public note", location)
+		}
+		if w.Header().Get("Cache-Control") != "no-store" {
+			t.Error("random redirects should not be cached")
+		}
+	}
+
+	if location := serve("/-/random?tag=plants").Header().Get("Location"); location != "/garden" {
+		t.Errorf("Location with tag = %q, want the only public note with the tag", location)
+	}
+	if w := serve("/-/random?tag=unknown"); w.Code != http.StatusNotFound {
+		t.Errorf("unknown tag: status = %d, want 404", w.Code)
+	}
+}
+
+func TestGetQuickSearch(t *testing.T) {
+	notes := []model.Note{
+		{Title: "Garden", Slug: "garden", IsPublic: true, Content: "# Garden\n## Garden tools"},
+		{Title: "Diary", Slug: "diary", IsPublic: true, Content: "## Garden work\n## Garden again"},
+		{Title: "Recipes", Slug: "recipes", IsPublic: true, Content: "## Tomatoes"},
+	}
+	for i := range 10 {
+		notes = append(notes, model.Note{Title: fmt.Sprintf("Gardening %d", i), Slug: fmt.Sprintf("gardening/%d", i), IsPublic: true})
+	}
+	notesMap := map[string]model.Note{}
+	for _, note := range notes {
+		notesMap[note.Slug] = note
+	}
+	cfg := &config.Config{}
+	server := &Server{
+		NotesService: engine.NewNotesService(&notesMap, engine.BuildTree(notes), nil),
+		rs:           template.NewResource(cfg),
+		cfg:          cfg,
+	}
+	fuegoServer := fuego.NewServer()
+	server.registerRoutes(fuegoServer)
+
+	serve := func(path string) string {
+		w := httptest.NewRecorder()
+		fuegoServer.Mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s = %d", path, w.Code)
+		}
+		return w.Body.String()
+	}
+
+	// The sidebar has the search input
+	if page := serve("/garden"); !strings.Contains(page, `hx-get="/-/quick-search"`) {
+		t.Error("expected the sidebar search input")
+	}
+
+	// At most 8 matches, titles first
+	body := serve("/-/quick-search?q=garden")
+	if count := strings.Count(body, `role="option"`); count != 8 {
+		t.Errorf("expected 8 matches, got %d:\n%s", count, body)
+	}
+	if strings.Contains(body, "<html") || !strings.Contains(body, `href="/garden"`) {
+		t.Errorf("expected a fragment with the title matches:\n%s", body)
+	}
+
+	// Headings of notes not matched by title fill the remaining places, one per note
+	body = serve("/-/quick-search?q=tomato")
+	if !strings.Contains(body, `href="/recipes#tomatoes"`) || strings.Count(body, `role="option"`) != 1 {
+		t.Errorf("expected the heading match linking to its section:\n%s", body)
+	}
+	body = serve("/-/quick-search?q=garden+again")
+	if !strings.Contains(body, `href="/diary#garden-again"`) || strings.Contains(body, `href="/garden"`) {
+		t.Errorf("expected the heading match:\n%s", body)
+	}
+	body = serve("/-/quick-search?q=" + url.QueryEscape("garden w"))
+	if strings.Count(body, `href="/diary`) != 1 {
+		t.Errorf("expected one match per note:\n%s", body)
+	}
+
+	// The query is escaped in the fragment
+	body = serve("/-/quick-search?q=" + url.QueryEscape("<script>alert(1)</script>"))
+	if strings.Contains(body, "<script>") || !strings.Contains(body, "&lt;script&gt;") {
+		t.Errorf("expected the query to be escaped:\n%s", body)
+	}
+
+	if body := serve("/-/quick-search?q=+"); strings.TrimSpace(body) != "" {
+		t.Errorf("expected nothing without a query, got %q", body)
+	}
+	if body := serve("/-/quick-search?q=" + strings.Repeat("é", 200)); !strings.Contains(body, "No notes found") {
+		t.Errorf("expected long queries to be cut, got %q", body)
+	}
+}
+
+func TestNoteHistory(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "Garden.md", "---\npublish: true\n---\nTomatoes\n")
+	writeTestFile(t, dir, "Diary.md", "---\npublish: false\n---\nSecret\n")
+	newServer := func() *fuego.Server {
+		cfg := &config.Config{Path: dir, SiteTitle: "Pluie"}
+		notesMap, tree, tagIndex, err := loadNotes(dir, cfg)
+		if err != nil {
+			t.Fatalf("loadNotes() error: %v", err)
+		}
+		server := &Server{NotesService: engine.NewNotesService(notesMap, tree, tagIndex), rs: template.NewResource(cfg), cfg: cfg}
+		fuegoServer := fuego.NewServer()
+		server.registerRoutes(fuegoServer)
+		return fuegoServer
+	}
+	get := func(server *fuego.Server, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		server.Mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	// Without git, no history and no link to it
+	if w := get(newServer(), "/-/history/garden"); w.Code != http.StatusNotFound {
+		t.Errorf("history outside a git repository: status = %d, want 404", w.Code)
+	}
+	if w := get(newServer(), "/garden"); strings.Contains(w.Body.String(), "history-link") {
+		t.Error("the note page should not link to a history outside a git repository")
+	}
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=Ada", "-c", "user.email=ada@example.com"}, args...)...)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, output)
+		}
+	}
+	git("init", "-q")
+	git("add", "-A")
+	git("commit", "-q", "-m", "Plant tomatoes")
+	writeTestFile(t, dir, "Garden.md", "---\npublish: true\n---\nTomatoes\nBeans\n")
+	git("commit", "-q", "-a", "-m", "Plant beans")
+	server := newServer()
+
+	if w := get(server, "/garden"); !strings.Contains(w.Body.String(), `href="/-/history/garden"`) {
+		t.Error("the note page should link to its history")
+	}
+
+	w := get(server, "/-/history/garden")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Plant tomatoes") || !strings.Contains(w.Body.String(), "Ada") {
+		t.Fatalf("history: status = %d, want the commits with their author, got:\n%s", w.Code, w.Body.String())
+	}
+	revisions, err := engine.FileHistory(dir, "Garden.md")
+	if err != nil || len(revisions) != 2 {
+		t.Fatalf("FileHistory() = %+v, %v", revisions, err)
+	}
+	first := revisions[1].Hash
+
+	w = get(server, "/-/diff/garden?rev="+first)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "+ Beans") || !strings.Contains(w.Body.String(), "diff-unified") {
+		t.Errorf("unified diff: status = %d, want the added line, got:\n%s", w.Code, w.Body.String())
+	}
+	w = get(server, "/-/diff/garden?view=split&rev="+first)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "diff-split") || !strings.Contains(w.Body.String(), "Beans") {
+		t.Errorf("split diff: status = %d, want the side by side diff", w.Code)
+	}
+
+	// Private notes have no history, and only the commits of the note can be compared
+	for _, path := range []string{"/-/history/diary", "/-/diff/diary?rev=" + first, "/-/diff/garden?rev=HEAD", "/-/diff/garden?rev=--help", "/-/diff/garden"} {
+		if w := get(server, path); w.Code != http.StatusNotFound || strings.Contains(w.Body.String(), "Secret") {
+			t.Errorf("%s: status = %d, want 404", path, w.Code)
+		}
+	}
+}
+
+func TestServer_ShutdownClosesSSE(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "garden.md", "# Garden\nTomatoes\n")
+	port, err := findListenPort("0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{Port: port, PublicByDefault: true, ShutdownTimeoutSeconds: 5}
+	notesMap, tree, tagIndex, err := loadNotes(dir, cfg)
+	if err != nil {
+		t.Fatalf("loadNotes() error: %v", err)
+	}
+	// The answer never comes, only the shutdown ends the search stream
+	slow := &fakeChatProvider{name: "ollama/llama3", chunks: []string{"late"}, delay: time.Minute}
+	server := &Server{
+		NotesService:      engine.NewNotesService(notesMap, tree, tagIndex),
+		rs:                template.NewResource(cfg),
+		cfg:               cfg,
+		chatChain:         NewChatChain([]ChatProvider{slow}, time.Minute),
+		embeddingsManager: &EmbeddingsManager{progress: NewEmbeddingProgress()},
+	}
+	if _, err := server.Start(t.Context()); err != nil {
+		t.Fatalf("Start() error: %v", err)
+	}
+
+	// Opens a stream and waits for its first event, so it is running when the signal comes
+	open := func(path, firstEvent string) *bufio.Reader {
+		t.Helper()
+		resp, err := http.Get("http://127.0.0.1:" + port + path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		t.Cleanup(func() { _ = resp.Body.Close() })
+		reader := bufio.NewReader(resp.Body)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				t.Fatalf("%s: no %q before %v", path, firstEvent, err)
+			}
+			if strings.HasPrefix(line, firstEvent) {
+				return reader
+			}
+		}
+	}
+	streams := map[string]*bufio.Reader{
+		"/-/embedding-progress":     open("/-/embedding-progress", "data: "),
+		"/-/search-stream?q=garden": open("/-/search-stream?q=garden", "event: semantic-skipped"),
+	}
+
+	process, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := process.Signal(syscall.SIGTERM); err != nil {
+		t.Fatalf("sending SIGTERM: %v", err)
+	}
+
+	for path, reader := range streams {
+		rest, err := io.ReadAll(reader)
+		if err != nil {
+			t.Errorf("%s: reading until the end: %v", path, err)
+		}
+		if !strings.Contains(string(rest), "event: shutdown\n") {
+			t.Errorf("%s: want the shutdown event before the end of the stream, got %q", path, rest)
+		}
+		if strings.Contains(string(rest), "event: token") || strings.Contains(string(rest), "event: done") {
+			t.Errorf("%s: nothing is sent after the shutdown event, got %q", path, rest)
+		}
+	}
+
+	if err := server.Wait(); err != nil {
+		t.Errorf("Wait() = %v, want a graceful shutdown", err)
+	}
+}
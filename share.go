@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/EwenQuim/pluie/model"
+)
+
+const (
+	// shareKeyParam is the query parameter of the share links, like /clients/acme/report?key=abc123
+	shareKeyParam = "key"
+	// shareCookieName remembers the share keys of a visitor, so the other notes and the
+	// attachments of the shared folder open without the key in their URL
+	shareCookieName = "pluie_share"
+	// maxShareKeys is how many share keys the cookie remembers, the most recent ones
+	maxShareKeys = 10
+)
+
+// shareKeys returns the share keys of the request: the one of the URL, then the remembered ones
+func shareKeys(r *http.Request) []string {
+	var keys []string
+	if key := r.URL.Query().Get(shareKeyParam); key != "" {
+		keys = append(keys, key)
+	}
+	if cookie, err := r.Cookie(shareCookieName); err == nil {
+		for key := range strings.SplitSeq(cookie.Value, ",") {
+			if key != "" && !slices.Contains(keys, key) {
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys
+}
+
+// sharedNote returns the note at slug of a folder with "access: private" when the request has
+// its share key. A key from the URL is remembered in a cookie, and the page keeps it out of
+// caches and of the Referer header of the links it has.
+func (s *Server) sharedNote(w http.ResponseWriter, r *http.Request, slug string) (model.Note, bool) {
+	keys := shareKeys(r)
+	note, ok := s.NotesService.GetSharedNote(slug, keys)
+	if !ok {
+		return model.Note{}, false
+	}
+
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	if r.URL.Query().Get(shareKeyParam) == note.ShareKey {
+		http.SetCookie(w, &http.Cookie{
+			Name:     shareCookieName,
+			Value:    strings.Join(keys[:min(len(keys), maxShareKeys)], ","),
+			Path:     "/",
+			MaxAge:   int(sessionDuration.Seconds()),
+			HttpOnly: true,
+			Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+	return note, true
+}
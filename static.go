@@ -1,12 +1,19 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"maps"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/EwenQuim/pluie/config"
 	"github.com/EwenQuim/pluie/engine"
@@ -15,6 +22,24 @@ import (
 	"github.com/EwenQuim/pluie/template"
 )
 
+// loadStaticComments reads the comments of the notes from dir, none when it can't be read
+func loadStaticComments(dir string) map[string][]model.Comment {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return nil
+	}
+	store, err := NewFileCommentStore(dir)
+	if err != nil {
+		slog.Warn("Failed to open comments, the static site has none", "dir", dir, "error", err)
+		return nil
+	}
+	comments, err := store.All()
+	if err != nil {
+		slog.Warn("Failed to read comments, the static site has none", "dir", dir, "error", err)
+		return nil
+	}
+	return comments
+}
+
 // validateOutputPath checks that the output path is safe to use with os.RemoveAll
 func validateOutputPath(outputPath string) error {
 	absPath, err := filepath.Abs(outputPath)
@@ -36,30 +61,60 @@ func validateOutputPath(outputPath string) error {
 	return nil
 }
 
-// generateStaticSite generates a static version of the site in the output folder
+// generateStaticSite generates a static version of the site in the output folder. The note pages
+// whose content didn't change since the last generation are kept, see staticManifest, unless
+// cfg.Force is set.
 func generateStaticSite(notesService *engine.NotesService, cfg *config.Config) error {
-	rs := template.NewResource(cfg)
+	start := time.Now()
+
+	// Static sites have no server to load the folders of the sidebar from
+	staticCfg := *cfg
+	staticCfg.SidebarLazy = false
+	rs := template.NewResource(&staticCfg)
+	if cfg.Minify {
+		// The pages load the notes tree of the sidebar, written once
+		rs = rs.WithSharedSidebar("/" + staticSidebarFile)
+	}
+
+	// Static sites show the comments posted on the server, without the form
+	var comments map[string][]model.Comment
+	if cfg.CommentsEnabled {
+		comments = loadStaticComments(cfg.CommentsDir)
+		rs = rs.WithComments(comments)
+	}
 
 	// Validate output path before removing
 	if err := validateOutputPath(cfg.Output); err != nil {
 		return fmt.Errorf("unsafe output path: %w", err)
 	}
 
-	// Create output folder
-	if err := os.RemoveAll(cfg.Output); err != nil {
-		return fmt.Errorf("failed to remove existing output folder: %w", err)
-	}
-	if err := os.MkdirAll(cfg.Output, 0755); err != nil {
-		return fmt.Errorf("failed to create output folder: %w", err)
+	// Keep the pages of the last generation when only notes changed
+	manifest := newStaticManifest(staticSiteHash(notesService, &staticCfg))
+	previous := readStaticManifest(cfg.Output)
+	incremental := previous != nil && previous.Site == manifest.Site && !cfg.Force
+
+	if !incremental {
+		// Create output folder
+		if err := os.RemoveAll(cfg.Output); err != nil {
+			return fmt.Errorf("failed to remove existing output folder: %w", err)
+		}
+		if err := os.MkdirAll(cfg.Output, 0755); err != nil {
+			return fmt.Errorf("failed to create output folder: %w", err)
+		}
 	}
 
-	slog.Info("Generating static site", "folder", cfg.Output)
+	slog.Info("Generating static site", "folder", cfg.Output, "incremental", incremental)
 
 	// Copy static assets
 	if err := copyStaticAssets(cfg); err != nil {
 		return fmt.Errorf("failed to copy static assets: %w", err)
 	}
 
+	// Copy the attachments embedded by the notes
+	if err := copyAttachments(notesService, cfg); err != nil {
+		return fmt.Errorf("failed to copy attachments: %w", err)
+	}
+
 	// Get home note slug
 	homeNoteSlug := notesService.GetHomeSlug(cfg.HomeNoteSlug)
 
@@ -69,16 +124,95 @@ func generateStaticSite(notesService *engine.NotesService, cfg *config.Config) e
 	}
 
 	// Generate all note pages
-	if err := generateNotePages(notesService, rs, cfg); err != nil {
+	var kept *staticManifest // Pages that may be kept
+	if incremental {
+		kept = previous
+	}
+	stats, err := generateNotePages(notesService, rs, cfg, kept, manifest, comments)
+	if err != nil {
 		return fmt.Errorf("failed to generate note pages: %w", err)
 	}
+	stats.deleted, err = deleteStaleNotePages(cfg.Output, previous, manifest, incremental)
+	if err != nil {
+		return fmt.Errorf("failed to delete note pages: %w", err)
+	}
+
+	// Generate folder index pages
+	if err := generateFolderPages(notesService, rs, cfg); err != nil {
+		return fmt.Errorf("failed to generate folder pages: %w", err)
+	}
 
 	// Generate tag pages
 	if err := generateTagPages(notesService, rs, cfg); err != nil {
 		return fmt.Errorf("failed to generate tag pages: %w", err)
 	}
 
-	slog.Info("Static site generation complete")
+	// Generate the notes tree the pages load with -minify
+	if cfg.Minify {
+		if err := generateSidebarPage(notesService, rs, cfg); err != nil {
+			return fmt.Errorf("failed to generate sidebar: %w", err)
+		}
+	}
+
+	// Generate the search page
+	if err := generateSearchPage(notesService, rs, cfg); err != nil {
+		return fmt.Errorf("failed to generate search page: %w", err)
+	}
+
+	// Generate the tasks page
+	if err := generateTasksPage(notesService, rs, cfg); err != nil {
+		return fmt.Errorf("failed to generate tasks page: %w", err)
+	}
+
+	// Generate the random note page
+	if err := generateRandomPage(notesService, rs, cfg); err != nil {
+		return fmt.Errorf("failed to generate random note page: %w", err)
+	}
+
+	// Generate 404.html, served by static hosts for the pages that don't exist
+	if err := generateNotFoundPage(notesService, rs, cfg); err != nil {
+		return fmt.Errorf("failed to generate 404 page: %w", err)
+	}
+
+	// Generate sitemap.xml, robots.txt and feed.xml
+	if err := generateSitemap(notesService, cfg); err != nil {
+		return fmt.Errorf("failed to generate sitemap: %w", err)
+	}
+	if err := generateRobots(cfg); err != nil {
+		return fmt.Errorf("failed to generate robots.txt: %w", err)
+	}
+	if err := generateFeed(notesService, cfg); err != nil {
+		return fmt.Errorf("failed to generate feed: %w", err)
+	}
+
+	if err := manifest.write(cfg.Output); err != nil {
+		return err
+	}
+
+	summary := []any{"generated", stats.generated, "skipped", stats.skipped, "deleted", stats.deleted}
+	var minified int64 // Bytes removed by the minification, of the kept note pages too
+	if cfg.Minify {
+		saved, err := minifyStaticPages(cfg.Output)
+		if err != nil {
+			return fmt.Errorf("failed to minify pages: %w", err)
+		}
+		minified = saved
+		for _, page := range manifest.Notes {
+			minified += page.Minified
+		}
+	}
+
+	// Total size of the output, before and after the minification
+	size, err := outputSize(cfg.Output)
+	if err != nil {
+		return fmt.Errorf("failed to measure output folder: %w", err)
+	}
+	if cfg.Minify {
+		summary = append(summary, "size_before_minify", size+minified)
+	}
+	summary = append(summary, "size", size, "in", time.Since(start).String())
+
+	slog.Info("Static site generation complete", summary...)
 	return nil
 }
 
@@ -109,50 +243,204 @@ func generateHomePage(notesService *engine.NotesService, rs template.Resource, h
 	return nil
 }
 
-// generateNotePages generates HTML pages for all public notes
-func generateNotePages(notesService *engine.NotesService, rs template.Resource, cfg *config.Config) error {
+// staticNoteStats counts the note pages of a static site generation
+type staticNoteStats struct {
+	generated int // Rendered
+	skipped   int // Kept from the last generation
+	deleted   int // Of notes that are gone
+}
+
+// generateNotePages generates HTML pages for all public notes, on every CPU. The pages of kept
+// with the same hash are not rendered again, a nil kept renders every page. Every page is
+// recorded in manifest.
+func generateNotePages(notesService *engine.NotesService, rs template.Resource, cfg *config.Config, kept, manifest *staticManifest, comments map[string][]model.Comment) (staticNoteStats, error) {
+	var stats staticNoteStats
 	notes := notesService.GetAllNotes()
 	if len(notes) == 0 {
 		slog.Warn("No notes found, skipping note pages")
-		return nil
+		return stats, nil
 	}
 
 	slog.Info("Generating note pages", "count", len(notes))
 
+	var mu sync.Mutex // Guards stats and errs
+	var errs []error
+	jobs := make(chan model.Note)
+	var wg sync.WaitGroup
+	for range runtime.GOMAXPROCS(0) {
+		wg.Go(func() {
+			for note := range jobs {
+				generated, err := generateNotePage(notesService, rs, cfg, note, kept, manifest, comments[note.Slug])
+				mu.Lock()
+				switch {
+				case err != nil:
+					errs = append(errs, err)
+				case generated:
+					stats.generated++
+				default:
+					stats.skipped++
+				}
+				mu.Unlock()
+			}
+		})
+	}
 	for _, note := range notes {
 		// Skip private notes if not public by default
 		if !cfg.PublicByDefault && !note.IsPublic {
 			slog.Debug("Skipping private note", "slug", note.Slug)
 			continue
 		}
+		jobs <- note
+	}
+	close(jobs)
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return stats, err
+	}
 
-		// Render the note page
-		node, err := rs.NoteWithList(notesService, &note, "")
-		if err != nil {
-			return fmt.Errorf("failed to render note %s: %w", note.Slug, err)
+	slog.Info("Note pages generated", "generated", stats.generated, "skipped", stats.skipped)
+	return stats, nil
+}
+
+// generateNotePage writes the page of a note and its print view, unless kept has them with the
+// same hash. It reports whether they were rendered.
+func generateNotePage(notesService *engine.NotesService, rs template.Resource, cfg *config.Config, note model.Note, kept, manifest *staticManifest, comments []model.Comment) (bool, error) {
+	// Write to {slug}/index.html, the print view goes next to it, at {slug}/print.html
+	page := staticManifestNote{
+		Hash: staticNoteHash(notesService, rs, note, comments, cfg.PublicByDefault),
+		Path: path.Join(staticSlugPath(note.Slug), "index.html"),
+	}
+	notePath := filepath.Join(cfg.Output, filepath.FromSlash(page.Path))
+	noteDir := filepath.Dir(notePath)
+	printPath := filepath.Join(noteDir, "print.html")
+
+	if kept.unchanged(note.Slug, page.Hash) && fileExists(notePath) && fileExists(printPath) {
+		page.Minified = kept.Notes[note.Slug].Minified
+		manifest.record(note.Slug, page)
+		slog.Debug("Note page unchanged", "slug", note.Slug)
+		return false, nil
+	}
+
+	// Render the note page
+	node, err := rs.NoteWithList(notesService, &note, "")
+	if err != nil {
+		return false, fmt.Errorf("failed to render note %s: %w", note.Slug, err)
+	}
+
+	// Create directory if needed
+	if err := os.MkdirAll(noteDir, 0755); err != nil {
+		return false, fmt.Errorf("failed to create directory for note %s: %w", note.Slug, err)
+	}
+
+	if err := writeNodeToFile(node, notePath); err != nil {
+		return false, fmt.Errorf("failed to write note %s: %w", note.Slug, err)
+	}
+
+	printNode, err := rs.NotePrintView(notesService, &note)
+	if err != nil {
+		return false, fmt.Errorf("failed to render print view of note %s: %w", note.Slug, err)
+	}
+	if err := writeNodeToFile(printNode, printPath); err != nil {
+		return false, fmt.Errorf("failed to write print view of note %s: %w", note.Slug, err)
+	}
+
+	// Minified right away, to record the size the minification removed
+	if cfg.Minify {
+		for _, file := range []string{notePath, printPath} {
+			saved, err := minifyStaticPage(file)
+			if err != nil {
+				return false, err
+			}
+			page.Minified += saved
 		}
+	}
 
-		// Write to {slug}/index.html or {slug}.html
-		notePath := filepath.Join(cfg.Output, note.Slug, "index.html")
+	manifest.record(note.Slug, page)
+	slog.Debug("Note page generated", "slug", note.Slug, "path", notePath)
+	return true, nil
+}
 
-		// Create directory if needed
-		noteDir := filepath.Dir(notePath)
-		if err := os.MkdirAll(noteDir, 0755); err != nil {
-			return fmt.Errorf("failed to create directory for note %s: %w", note.Slug, err)
+// deleteStaleNotePages removes the pages of the notes of previous that manifest doesn't have,
+// and counts them. A full generation emptied the output folder already.
+func deleteStaleNotePages(output string, previous, manifest *staticManifest, incremental bool) (int, error) {
+	if previous == nil {
+		return 0, nil
+	}
+	deleted := 0
+	for slug, page := range previous.Notes {
+		if _, ok := manifest.Notes[slug]; ok {
+			continue
+		}
+		deleted++
+		if !incremental {
+			continue
+		}
+		notePath := filepath.Join(output, filepath.FromSlash(page.Path))
+		for _, path := range []string{notePath, filepath.Join(filepath.Dir(notePath), "print.html")} {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return deleted, fmt.Errorf("failed to delete page of note %s: %w", slug, err)
+			}
 		}
+		_ = os.Remove(filepath.Dir(notePath)) // Only when empty, a folder may have its page there
+		slog.Debug("Note page deleted", "slug", slug)
+	}
+	return deleted, nil
+}
+
+// fileExists reports whether path is a file
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
 
-		if err := writeNodeToFile(node, notePath); err != nil {
-			return fmt.Errorf("failed to write note %s: %w", note.Slug, err)
+// generateFolderPages generates the index page of each folder at /output/{folder slug}/index.html.
+// A note with the slug of a folder keeps its page.
+func generateFolderPages(notesService *engine.NotesService, rs template.Resource, cfg *config.Config) error {
+	count := 0
+	var generate func(node *engine.TreeNode) error
+	generate = func(node *engine.TreeNode) error {
+		for _, child := range node.Children {
+			if !child.IsFolder {
+				continue
+			}
+			if err := generate(child); err != nil {
+				return err
+			}
+
+			slug := engine.FolderSlug(child)
+			subfolders, notes := engine.FolderContents(child, cfg.PublicByDefault)
+			if _, isNote := notesService.GetNote(slug); slug == "" || isNote || (len(subfolders) == 0 && len(notes) == 0) {
+				continue
+			}
+
+			node, err := rs.FolderIndex(notesService, child, subfolders, notes, "")
+			if err != nil {
+				return fmt.Errorf("failed to render folder %s: %w", child.Path, err)
+			}
+			folderPath := filepath.Join(cfg.Output, filepath.FromSlash(staticSlugPath(slug)), "index.html")
+			if err := os.MkdirAll(filepath.Dir(folderPath), 0755); err != nil {
+				return fmt.Errorf("failed to create directory for folder %s: %w", child.Path, err)
+			}
+			if err := writeNodeToFile(node, folderPath); err != nil {
+				return fmt.Errorf("failed to write folder %s: %w", child.Path, err)
+			}
+			count++
 		}
+		return nil
+	}
 
-		slog.Debug("Note page generated", "slug", note.Slug, "path", notePath)
+	if tree := notesService.GetTree(); tree != nil {
+		if err := generate(tree); err != nil {
+			return err
+		}
 	}
 
-	slog.Info("Note pages generated", "count", len(notes))
+	slog.Info("Folder pages generated", "count", count)
 	return nil
 }
 
-// generateTagPages generates HTML pages for all tags
+// generateTagPages generates HTML pages for all tags, and the tag cloud at /output/-/tags/index.html
+// and /output/-/tag/index.html
 func generateTagPages(notesService *engine.NotesService, rs template.Resource, cfg *config.Config) error {
 	tagIndex := notesService.GetTagIndex()
 	allTags := tagIndex.GetAllTags()
@@ -160,19 +448,19 @@ func generateTagPages(notesService *engine.NotesService, rs template.Resource, c
 	slog.Info("Generating tag pages", "count", len(allTags))
 
 	for _, tag := range allTags {
-		// Get all notes that contain this tag
-		notesWithTag := tagIndex.GetNotesWithTag(tag)
+		if !isSafeStaticTag(tag) {
+			slog.Warn("Skipping tag page, the tag is not a valid path", "tag", tag)
+			continue
+		}
 
-		// Render the tag page
-		node, err := rs.TagList(notesService, tag, notesWithTag)
+		// Render the tag page, with the notes of its nested tags
+		node, err := rs.TagList(notesService, tag, tagIndex.GetNotesWithTagOrChildren(tag), false)
 		if err != nil {
 			return fmt.Errorf("failed to render tag %s: %w", tag, err)
 		}
 
-		// Write to /-/tag/{tag}/index.html
-		// Sanitize tag for file path
-		sanitizedTag := strings.ReplaceAll(tag, "/", "-")
-		tagPath := filepath.Join(cfg.Output, "-", "tag", sanitizedTag, "index.html")
+		// Write to /-/tag/{tag}/index.html, nested tags get nested folders
+		tagPath := filepath.Join(cfg.Output, filepath.FromSlash(staticTagPath(tag)), "index.html")
 
 		// Create directory if needed
 		tagDir := filepath.Dir(tagPath)
@@ -187,10 +475,197 @@ func generateTagPages(notesService *engine.NotesService, rs template.Resource, c
 		slog.Debug("Tag page generated", "tag", tag, "path", tagPath)
 	}
 
+	// Tag cloud, at /-/tags and /-/tag/ like in server mode, without the tags that have no page
+	safeTags := maps.Clone(tagIndex)
+	maps.DeleteFunc(safeTags, func(tag string, _ []model.Note) bool { return !isSafeStaticTag(tag) })
+	node, err := rs.TagCloud(notesService, safeTags)
+	if err != nil {
+		return fmt.Errorf("failed to render tag index: %w", err)
+	}
+	for _, folder := range []string{"tags", "tag"} {
+		indexPath := filepath.Join(cfg.Output, "-", folder, "index.html")
+		if err := os.MkdirAll(filepath.Dir(indexPath), 0755); err != nil {
+			return fmt.Errorf("failed to create directory for tag index: %w", err)
+		}
+		if err := writeNodeToFile(node, indexPath); err != nil {
+			return fmt.Errorf("failed to write tag index: %w", err)
+		}
+	}
+
 	slog.Info("Tag pages generated", "count", len(allTags))
 	return nil
 }
 
+// staticSlugPath returns the folder of the page of a note or folder slug in the output folder, as
+// a slash-separated path. Slugs keep some characters URL-encoded, like the accents of v1 slugs,
+// and static hosts look for the file of the decoded URL path.
+func staticSlugPath(slug string) string {
+	if decoded, err := url.PathUnescape(slug); err == nil {
+		return decoded
+	}
+	return slug
+}
+
+// staticTagPath is the URL path of a tag page of the static site, the same as in server mode
+// so that tag links work in both
+func staticTagPath(tag string) string {
+	return "/-/tag/" + tag
+}
+
+// isSafeStaticTag reports whether the tag page can be written inside its folder: metadata tags
+// are free text, and a tag like "../x" would escape /output/-/tag
+func isSafeStaticTag(tag string) bool {
+	if strings.ContainsAny(tag, "\\\x00") {
+		return false
+	}
+	for segment := range strings.SplitSeq(tag, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// staticSidebarFile is the notes tree of the sidebar of a static site generated with -minify,
+// at the root of the output folder
+const staticSidebarFile = "sidebar.html"
+
+// generateSidebarPage writes the notes tree of the sidebar the pages load to /output/sidebar.html
+func generateSidebarPage(notesService *engine.NotesService, rs template.Resource, cfg *config.Config) error {
+	if err := writeNodeToFile(rs.SharedSidebar(notesService), filepath.Join(cfg.Output, staticSidebarFile)); err != nil {
+		return fmt.Errorf("failed to write sidebar: %w", err)
+	}
+	return nil
+}
+
+// generateSearchPage generates the search page at /output/-/search/index.html. Live search
+// needs the server, the page only filters note titles in the browser.
+func generateSearchPage(notesService *engine.NotesService, rs template.Resource, cfg *config.Config) error {
+	searchPath := filepath.Join(cfg.Output, "-", "search", "index.html")
+	if err := os.MkdirAll(filepath.Dir(searchPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for search page: %w", err)
+	}
+	if err := writeNodeToFile(rs.StaticSearch(notesService), searchPath); err != nil {
+		return fmt.Errorf("failed to write search page: %w", err)
+	}
+
+	slog.Info("Search page generated", "path", searchPath)
+	return nil
+}
+
+// generateTasksPage generates the open tasks page at /output/-/tasks/index.html
+func generateTasksPage(notesService *engine.NotesService, rs template.Resource, cfg *config.Config) error {
+	groups := engine.OpenTasks(notesService.GetAllNotes(), cfg.PublicByDefault)
+	node, err := rs.Tasks(notesService, groups)
+	if err != nil {
+		return fmt.Errorf("failed to render tasks page: %w", err)
+	}
+
+	tasksPath := filepath.Join(cfg.Output, "-", "tasks", "index.html")
+	if err := os.MkdirAll(filepath.Dir(tasksPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for tasks page: %w", err)
+	}
+	if err := writeNodeToFile(node, tasksPath); err != nil {
+		return fmt.Errorf("failed to write tasks page: %w", err)
+	}
+
+	slog.Info("Tasks page generated", "notes", len(groups))
+	return nil
+}
+
+// generateRandomPage generates the random note page at /output/-/random/index.html, which picks
+// the note in the browser, there is no server to redirect
+func generateRandomPage(notesService *engine.NotesService, rs template.Resource, cfg *config.Config) error {
+	node, err := rs.StaticRandom(notesService)
+	if err != nil {
+		return fmt.Errorf("failed to render random note page: %w", err)
+	}
+
+	randomPath := filepath.Join(cfg.Output, "-", "random", "index.html")
+	if err := os.MkdirAll(filepath.Dir(randomPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for random note page: %w", err)
+	}
+	if err := writeNodeToFile(node, randomPath); err != nil {
+		return fmt.Errorf("failed to write random note page: %w", err)
+	}
+	return nil
+}
+
+// generateNotFoundPage writes /output/404.html, _pluie/404.md when the vault has one
+func generateNotFoundPage(notesService *engine.NotesService, rs template.Resource, cfg *config.Config) error {
+	node, err := rs.NoteWithList(notesService, nil, "")
+	if err != nil {
+		return fmt.Errorf("failed to render 404 page: %w", err)
+	}
+	if err := writeNodeToFile(node, filepath.Join(cfg.Output, "404.html")); err != nil {
+		return fmt.Errorf("failed to write 404 page: %w", err)
+	}
+	return nil
+}
+
+// generateSitemap writes /output/sitemap.xml. Sitemap URLs must be absolute, so it needs SITE_URL.
+func generateSitemap(notesService *engine.NotesService, cfg *config.Config) error {
+	if cfg.SiteURL == "" {
+		slog.Warn("SITE_URL is not set, skipping sitemap.xml")
+		return nil
+	}
+
+	loc := cfg.Location()
+	entries := engine.BuildSitemap(notesService.GetAllNotes(), cfg.PublicByDefault, loc, staticTagPath)
+
+	file, err := os.Create(filepath.Join(cfg.Output, "sitemap.xml"))
+	if err != nil {
+		return fmt.Errorf("failed to create sitemap.xml: %w", err)
+	}
+	if err := engine.WriteSitemap(file, cfg.SiteURL, entries, loc); err != nil {
+		_ = file.Close() // The write error matters more
+		return fmt.Errorf("failed to write sitemap.xml: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to close sitemap.xml: %w", err)
+	}
+
+	slog.Info("Sitemap generated", "urls", len(entries))
+	return nil
+}
+
+// generateRobots writes /output/robots.txt, the same as in server mode
+func generateRobots(cfg *config.Config) error {
+	robotsPath := filepath.Join(cfg.Output, "robots.txt")
+	if err := os.WriteFile(robotsPath, []byte(engine.BuildRobots(cfg.SiteURL, cfg.RobotsDisallow)), 0644); err != nil {
+		return fmt.Errorf("failed to write robots.txt: %w", err)
+	}
+
+	slog.Info("Robots generated", "path", robotsPath)
+	return nil
+}
+
+// generateFeed writes the RSS feed to /output/feed.xml. Feed links must be absolute, so it needs SITE_URL.
+func generateFeed(notesService *engine.NotesService, cfg *config.Config) error {
+	if cfg.SiteURL == "" {
+		slog.Warn("SITE_URL is not set, skipping feed.xml")
+		return nil
+	}
+
+	loc := cfg.Location()
+	items := engine.RecentNotes(notesService.GetAllNotes(), cfg.PublicByDefault, cfg.FeedSize, loc)
+
+	file, err := os.Create(filepath.Join(cfg.Output, "feed.xml"))
+	if err != nil {
+		return fmt.Errorf("failed to create feed.xml: %w", err)
+	}
+	if err := engine.WriteRSSFeed(file, cfg.SiteTitle, cfg.SiteDescription, cfg.SiteURL, "/feed.xml", items, loc); err != nil {
+		_ = file.Close() // The write error matters more
+		return fmt.Errorf("failed to write feed.xml: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to close feed.xml: %w", err)
+	}
+
+	slog.Info("Feed generated", "items", len(items))
+	return nil
+}
+
 // copyStaticAssets copies the static assets to /output/static
 func copyStaticAssets(cfg *config.Config) error {
 	slog.Info("Copying static assets")
@@ -230,6 +705,29 @@ func copyStaticAssets(cfg *config.Config) error {
 	return nil
 }
 
+// copyAttachments copies the attachments embedded by the public notes from the vault to
+// /output/-/attachments, the URL path they are served at in server mode
+func copyAttachments(notesService *engine.NotesService, cfg *config.Config) error {
+	attachments := notesService.Attachments()
+	for _, vaultPath := range attachments {
+		content, err := os.ReadFile(filepath.Join(cfg.Path, filepath.FromSlash(vaultPath)))
+		if err != nil {
+			return fmt.Errorf("failed to read attachment %s: %w", vaultPath, err)
+		}
+
+		destPath := filepath.Join(cfg.Output, filepath.FromSlash(engine.AttachmentsPrefix), filepath.FromSlash(vaultPath))
+		if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
+			return fmt.Errorf("failed to create directory for attachment %s: %w", vaultPath, err)
+		}
+		if err := os.WriteFile(destPath, content, 0644); err != nil {
+			return fmt.Errorf("failed to write attachment %s: %w", vaultPath, err)
+		}
+	}
+
+	slog.Info("Attachments copied", "count", len(attachments))
+	return nil
+}
+
 // writeNodeToFile renders a gomponents.Node to an HTML file
 func writeNodeToFile(node interface{ Render(io.Writer) error }, path string) error {
 	file, err := os.Create(path)
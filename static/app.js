@@ -1,11 +1,8 @@
 // @ts-check
 // Constants
-const SCROLL_HEADING_THRESHOLD = 100;
-const SCROLL_THROTTLE_MS = 100;
 const HTMX_RESTORE_DELAY_MS = 10;
-const HTMX_HEADING_DELAY_MS = 50;
-const HASH_SCROLL_DELAY_MS = 100;
 const MOBILE_BREAKPOINT = 768;
+const COPY_FEEDBACK_MS = 1500;
 
 // Helper functions for localStorage
 /**
@@ -37,6 +34,10 @@ function setFolderState(folderPath, isOpen) {
 	if (folderElement && chevronElement) {
 		folderElement.style.display = isOpen ? 'block' : 'none';
 		chevronElement.textContent = isOpen ? '▼' : '▶';
+		if (isOpen && folderElement.hasAttribute('hx-get')) {
+			// Rendered without its contents (SIDEBAR_LAZY): htmx loads them the first time
+			folderElement.dispatchEvent(new Event('load-folder'));
+		}
 	}
 }
 
@@ -105,157 +106,27 @@ function restoreFolderStates() {
 }
 
 /**
- * Handles clicks on table of contents links, providing smooth scrolling and updating active state.
- * @param {Event} event - The click event
- * @param {HTMLAnchorElement} tocLink - The clicked TOC link element
+ * Highlights the link to the current page in a notes tree shared by every page of a static
+ * site, and opens its folders, like the server renders them.
+ * @param {HTMLElement} list - The notes tree, with the classes of its links in data attributes
  */
-function handleTOCClick(event, tocLink) {
-	event.preventDefault();
-
-	const href = tocLink.getAttribute('href');
-	if (!href) return;
-
-	const targetId = href.substring(1); // Remove the #
-	const targetElement = document.getElementById(targetId);
-
-	if (targetElement) {
-		// Smooth scroll to target
-		targetElement.scrollIntoView({
-			behavior: 'smooth',
-			block: 'start'
-		});
-
-		// Update URL hash
-		history.pushState(null, '', `#${targetId}`);
-
-		// Update active state
-		updateActiveTocItem(tocLink);
+function showCurrentNote(list) {
+	const activeClass = list.dataset.activeClass;
+	if (!activeClass) {
+		return;
 	}
-}
-
-/**
- * Converts text to a URL-friendly slug format, matching the Go implementation.
- * Lowercases text, replaces non-alphanumeric characters with hyphens, and trims edge hyphens.
- * @param {string} text - The text to slugify
- * @returns {string} The slugified text
- */
-function slugify(text) {
-	return text
-		.toLowerCase()
-		.replace(/[^a-z0-9]+/g, '-')
-		.replace(/^-+|-+$/g, '');
-}
-
-/**
- * Adds unique IDs to all headings in the content area to match the TOC structure.
- * Handles duplicate slugs by appending numbers.
- */
-function addHeadingIds() {
-	const contentArea = document.querySelector('.prose');
-	if (!contentArea) return;
-
-	const headings = contentArea.querySelectorAll('h1, h2, h3, h4, h5, h6');
-	const usedSlugs = new Map(); // Track used slugs to handle duplicates
-
-	headings.forEach((heading) => {
-		if (!heading.id) {
-			const text = heading.textContent.trim();
-			const baseSlug = slugify(text);
-			let id = baseSlug;
-
-			// Handle duplicate slugs by appending a number
-			if (usedSlugs.has(baseSlug)) {
-				const count = usedSlugs.get(baseSlug) + 1;
-				usedSlugs.set(baseSlug, count);
-				id = `${baseSlug}-${count}`;
-			} else {
-				usedSlugs.set(baseSlug, 0);
-			}
-
-			heading.id = id;
-		}
-	});
-}
-
-/**
- * Updates the active state of TOC items based on scroll position or a specific item.
- * @param {Element|null} activeItem - The specific TOC link to set as active, or null to determine from scroll position
- */
-function updateActiveTocItem(activeItem = null) {
-	const tocLinks = document.querySelectorAll('#table-of-contents a');
-
-	// Remove active class from all items
-	tocLinks.forEach(link => {
-		link.classList.remove('text-purple-600', 'bg-purple-50', 'font-medium');
-		link.classList.add('text-gray-600');
-	});
-
-	if (activeItem) {
-		// Set specific item as active
-		activeItem.classList.remove('text-gray-600');
-		activeItem.classList.add('text-purple-600', 'bg-purple-50', 'font-medium');
-	} else {
-		// Find active item based on scroll position
-		const headings = document.querySelectorAll('.prose h1, .prose h2, .prose h3, .prose h4, .prose h5, .prose h6');
-		/** @type {Element|null} */
-		let activeHeading = null;
-
-		// Find the heading that's currently in view
-		headings.forEach(heading => {
-			const rect = heading.getBoundingClientRect();
-			if (rect.top <= SCROLL_HEADING_THRESHOLD && rect.top >= -SCROLL_HEADING_THRESHOLD) {
-				activeHeading = heading;
-			}
-		});
-
-		if (activeHeading) {
-			const headingId = /** @type {Element} */ (activeHeading).id;
-			const activeLink = document.querySelector(`#table-of-contents a[href="#${headingId}"]`);
-			if (activeLink) {
-				activeLink.classList.remove('text-gray-600');
-				activeLink.classList.add('text-purple-600', 'bg-purple-50', 'font-medium');
-			}
+	restoreFolderStates();
+	for (const link of list.querySelectorAll('a[href]')) {
+		if (/** @type {HTMLAnchorElement} */ (link).pathname !== location.pathname) {
+			continue;
 		}
-	}
-}
-
-/**
- * Handles URL hash navigation by smoothly scrolling to the target heading and updating TOC active state.
- * Checks if there's a hash in the URL and scrolls to the corresponding heading if found.
- */
-function handleHashNavigation() {
-	if (window.location.hash) {
-		const targetElement = document.querySelector(window.location.hash);
-		if (targetElement) {
-			targetElement.scrollIntoView({ behavior: 'smooth', block: 'start' });
-			const activeLink = document.querySelector(`#table-of-contents a[href="${window.location.hash}"]`);
-			if (activeLink) {
-				updateActiveTocItem(activeLink);
-			}
+		link.className = activeClass;
+		for (let folder = link.closest('[id^="folder-"]'); folder; folder = folder.parentElement && folder.parentElement.closest('[id^="folder-"]')) {
+			setFolderState(folder.id.replace('folder-', ''), true);
 		}
 	}
 }
 
-/**
- * Creates a debounced version of a function that delays its execution until after
- * the specified wait time has elapsed since the last invocation.
- * @param {(...args: any[]) => void} func - The function to debounce
- * @param {number} wait - The delay in milliseconds
- * @returns {(...args: any[]) => void} The debounced function
- */
-function debounce(func, wait) {
-	/** @type {number | undefined} */
-	let timeout;
-	return function executedFunction(...args) {
-		const later = () => {
-			clearTimeout(timeout);
-			func(...args);
-		};
-		clearTimeout(timeout);
-		timeout = setTimeout(later, wait);
-	};
-}
-
 // Mobile sidebar functionality
 /**
  * Retrieves all mobile sidebar-related DOM elements.
@@ -352,6 +223,17 @@ function handleMobileLinkClick() {
 	}
 }
 
+// Theme toggle functionality
+/**
+ * Switches between the light and dark themes and remembers the choice in localStorage.
+ * theme.js applies it before the next pages render.
+ */
+function toggleTheme() {
+	const isDark = document.documentElement.classList.toggle('dark');
+	localStorage.setItem('theme', isDark ? 'dark' : 'light');
+	window.dispatchEvent(new Event('themechange'));
+}
+
 // YAML front matter toggle functionality
 /**
  * Toggles the visibility of YAML front matter and persists the state to localStorage.
@@ -380,6 +262,109 @@ function toggleYamlFrontmatter() {
 	}
 }
 
+/**
+ * Copies the text of an element to the clipboard, like the content of a deleted note.
+ * The button shows "Copied" for a moment.
+ * @param {string} elementId - ID of the element holding the text
+ * @param {HTMLElement} button - The clicked button
+ */
+function copyElementText(elementId, button) {
+	const element = document.getElementById(elementId);
+	if (!element) return;
+
+	copyText(element.textContent || '', button);
+}
+
+/**
+ * Copies text to the clipboard. The button shows "Copied" for a moment, in its title when it
+ * has no text, like the heading anchors.
+ * @param {string} text - The text to copy
+ * @param {HTMLElement} button - The clicked button
+ */
+function copyText(text, button) {
+	if (!navigator.clipboard) return;
+
+	navigator.clipboard.writeText(text).then(() => {
+		const property = button.textContent ? 'textContent' : 'title';
+		const label = button[property];
+		button[property] = 'Copied';
+		setTimeout(() => {
+			button[property] = label;
+		}, COPY_FEEDBACK_MS);
+	});
+}
+
+/**
+ * Lists the sources cited by an AI answer below it, numbered like its citations.
+ * @param {HTMLElement} answer - The answer, with its citation links, see template/citations.go
+ * @param {{slug: string, title: string, heading?: string, url: string}[]} sources - The sources of the answer, in the order of their numbers
+ */
+function renderAISources(answer, sources) {
+	const cited = new Set(Array.from(answer.querySelectorAll('a.citation'), (link) => link.getAttribute('data-source')));
+	if (cited.size === 0) return;
+
+	const list = document.createElement('ol');
+	list.className = 'ai-sources text-xs mt-2 mb-0';
+	sources.forEach((source, i) => {
+		if (!cited.has(String(i + 1))) return;
+		const item = document.createElement('li');
+		item.value = i + 1;
+		const link = document.createElement('a');
+		link.href = source.url;
+		link.textContent = source.heading ? source.title + ' > ' + source.heading : source.title;
+		item.append(link);
+		list.append(item);
+	});
+	answer.after(list);
+}
+
+/**
+ * Returns the absolute URL of the current note, without the section of the heading.
+ * @returns {string}
+ */
+function noteURL() {
+	return window.location.origin + window.location.pathname + window.location.search;
+}
+
+/**
+ * Copies the absolute URL of a heading section. The link is still followed, updating the hash.
+ * @param {MouseEvent} event - The click event
+ * @param {HTMLAnchorElement} link - The anchor of the heading, see template/copy.go
+ */
+function copyHeadingLink(event, link) {
+	// Keep opening links in a new tab working
+	if (event.metaKey || event.ctrlKey || event.shiftKey || event.button !== 0) return;
+
+	copyText(noteURL() + link.hash, link);
+}
+
+/**
+ * Copies the absolute URL of the current note.
+ * @param {HTMLElement} button - The clicked button
+ */
+function copyNoteLink(button) {
+	copyText(noteURL(), button);
+}
+
+/**
+ * Copies the markdown of the note, fetched from its raw endpoint, or from the template of the
+ * page on static sites. The source is in the data-source attribute of the button.
+ * @param {HTMLElement} button - The clicked button
+ */
+function copyNoteMarkdown(button) {
+	const source = button.dataset.source || '';
+	if (source.startsWith('#')) {
+		const template = /** @type {HTMLTemplateElement|null} */ (document.querySelector(source));
+		if (template) copyText(template.content.textContent || '', button);
+		return;
+	}
+
+	fetch(source)
+		.then((response) => (response.ok ? response.text() : Promise.reject(new Error(response.statusText))))
+		.then((markdown) => copyText(markdown, button))
+		.catch((error) => console.error('Could not copy the markdown of the note:', error));
+}
+
 /**
  * Restores the YAML front matter visibility state from localStorage on page load.
  * Sets the initial visibility and button text based on saved preferences.
@@ -402,23 +387,124 @@ function restoreYamlFrontmatterState() {
 	}
 }
 
+// Sidebar search as you type
+/**
+ * Shows or hides the matches of the sidebar search.
+ * @param {boolean} isOpen - Whether the matches should be shown
+ */
+function setQuickSearchOpen(isOpen) {
+	const input = document.getElementById('quick-search-input');
+	const results = document.getElementById('quick-search-results');
+	if (!input || !results) return;
+
+	const hasResults = results.children.length > 0;
+	results.hidden = !(isOpen && hasResults);
+	input.setAttribute('aria-expanded', String(!results.hidden));
+}
+
+/**
+ * Highlights one of the matches of the sidebar search, the first or last one past the ends.
+ * @param {number} index - Position of the match, -1 for none
+ */
+function selectQuickSearchItem(index) {
+	const items = document.querySelectorAll('#quick-search-results .quick-search-item');
+	items.forEach((item, i) => {
+		item.setAttribute('aria-selected', String(i === index));
+		if (i === index) item.scrollIntoView({ block: 'nearest' });
+	});
+}
+
+/**
+ * Moves the highlighted match of the sidebar search with the arrow keys, opens it with Enter
+ * and closes the matches with Escape. Enter without a highlighted match opens the search page.
+ * @param {KeyboardEvent} event - The keydown event of the search input
+ */
+function handleQuickSearchKey(event) {
+	const items = Array.from(document.querySelectorAll('#quick-search-results .quick-search-item'));
+	const selected = items.findIndex((item) => item.getAttribute('aria-selected') === 'true');
+	const results = document.getElementById('quick-search-results');
+	const isOpen = results !== null && !results.hidden;
+
+	switch (event.key) {
+		case 'ArrowDown':
+		case 'ArrowUp': {
+			if (items.length === 0) return;
+			event.preventDefault();
+			setQuickSearchOpen(true);
+			const step = event.key === 'ArrowDown' ? 1 : -1;
+			selectQuickSearchItem(!isOpen || selected === -1
+				? (step === 1 ? 0 : items.length - 1)
+				: (selected + step + items.length) % items.length);
+			break;
+		}
+		case 'Enter':
+			if (isOpen && selected !== -1) {
+				event.preventDefault();
+				setQuickSearchOpen(false);
+				/** @type {HTMLElement} */ (items[selected]).click();
+			}
+			break;
+		case 'Escape':
+			if (isOpen) {
+				event.preventDefault();
+				selectQuickSearchItem(-1);
+				setQuickSearchOpen(false);
+			}
+			break;
+	}
+}
+
+// Static site search
+/**
+ * Filters the notes of the static search page, whose titles contain every word of the query.
+ * @param {string} query - Filter typed by the user
+ */
+function filterStaticSearch(query) {
+	const words = query.toLowerCase().split(/\s+/).filter(Boolean);
+	const items = document.querySelectorAll('#static-search-results li');
+	let shown = 0;
+
+	items.forEach((item) => {
+		const title = item.getAttribute('data-title') || '';
+		const matches = words.every((word) => title.includes(word));
+		/** @type {HTMLElement} */ (item).hidden = !matches;
+		if (matches) shown++;
+	});
+
+	const empty = document.getElementById('static-search-empty');
+	if (empty) empty.hidden = shown > 0;
+}
+
+// Static site random note
+/**
+ * Opens a random note of the static random page, one with the ?tag= tag when given.
+ */
+function openStaticRandomNote() {
+	const data = document.getElementById('random-notes');
+	if (!data) return;
+
+	const tag = (new URLSearchParams(location.search).get('tag') || '').trim().toLowerCase();
+	/** @type {{slug: string, tags: string[]}[]} */
+	const notes = JSON.parse(data.textContent || '[]').filter((note) => !tag || note.tags.includes(tag));
+	if (notes.length === 0) {
+		const empty = document.getElementById('random-empty');
+		if (empty) empty.hidden = false;
+		return;
+	}
+	location.replace('/' + notes[Math.floor(Math.random() * notes.length)].slug);
+}
+
 // Keyboard shortcuts
 document.addEventListener('DOMContentLoaded', function () {
+	// Static random note page
+	openStaticRandomNote();
+
 	// Restore folder states when page loads
 	restoreFolderStates();
 
 	// Restore YAML front matter state when page loads
 	restoreYamlFrontmatterState();
 
-	// Add IDs to headings to match TOC structure
-	addHeadingIds();
-
-	// Update active TOC item on scroll
-	const mainContent = document.querySelector('.flex-1.overflow-y-auto');
-	if (mainContent) {
-		mainContent.addEventListener('scroll', debounce(updateActiveTocItem, SCROLL_THROTTLE_MS));
-	}
-
 	// Handle cmd+K (or ctrl+K on Windows/Linux) to navigate to search page
 	document.addEventListener('keydown', function (event) {
 		// Check for cmd+K on Mac or ctrl+K on Windows/Linux
@@ -426,7 +512,7 @@ document.addEventListener('DOMContentLoaded', function () {
 			event.preventDefault();
 
 			// Check if we're already on the search page
-			if (window.location.pathname === '/-/search') {
+			if (window.location.pathname.replace(/\/$/, '') === '/-/search') {
 				// Focus the search input on the search page
 				const searchInput = /** @type {HTMLInputElement|null} */ (document.querySelector('input[name="q"]'));
 				if (searchInput) {
@@ -449,19 +535,53 @@ document.addEventListener('DOMContentLoaded', function () {
 		}
 	});
 
-	// Add heading IDs after HTMX content updates
+	// Restore the states of the subfolders of a folder loaded on demand (SIDEBAR_LAZY)
+	document.body.addEventListener('htmx:load', function (event) {
+		const target = /** @type {Element|null} */ (event.target);
+		if (target && target.id.startsWith('folder-') && target.closest('#notes-list')) {
+			restoreFolderStates();
+		}
+	});
+
+	// Highlight the current note in the notes tree shared by the pages of a static site
+	document.body.addEventListener('htmx:load', function (event) {
+		const target = /** @type {HTMLElement|null} */ (event.target);
+		if (target && target.id === 'notes-list') {
+			showCurrentNote(target);
+		}
+	});
+
+	// Show the new matches of the sidebar search, none highlighted
 	document.body.addEventListener('htmx:afterSwap', function (event) {
-		// Check if the main content was updated
 		const target = /** @type {Element|null} */ (event.target);
-		if (target && (target.closest('.prose') || target.classList.contains('prose'))) {
-			setTimeout(() => {
-				addHeadingIds();
-				// Handle hash in URL on page load
-				setTimeout(handleHashNavigation, HASH_SCROLL_DELAY_MS);
-			}, HTMX_HEADING_DELAY_MS);
+		if (target && target.id === 'quick-search-results') {
+			setQuickSearchOpen(document.activeElement === document.getElementById('quick-search-input'));
+		}
+	});
+
+	// Close the matches of the sidebar search when clicking elsewhere or opening one
+	document.addEventListener('click', function (event) {
+		const target = /** @type {Element|null} */ (event.target);
+		if (target && (!target.closest('form[role="search"]') || target.closest('.quick-search-item'))) {
+			setQuickSearchOpen(false);
+		}
+	});
+
+	// Show the matches again when coming back to the sidebar search
+	document.addEventListener('focusin', function (event) {
+		const target = /** @type {Element|null} */ (event.target);
+		if (target && target.id === 'quick-search-input') {
+			setQuickSearchOpen(true);
 		}
 	});
 
-	// Handle hash in URL on initial page load
-	setTimeout(handleHashNavigation, HASH_SCROLL_DELAY_MS);
+	// Missing and private notes are served with a 404 and the not found page, which htmx
+	// does not swap by default: boosted links to them would do nothing
+	document.body.addEventListener('htmx:beforeSwap', function (event) {
+		const detail = /** @type {CustomEvent} */ (event).detail;
+		if (detail.boosted && detail.xhr.status === 404) {
+			detail.shouldSwap = true;
+			detail.isError = false;
+		}
+	});
 });
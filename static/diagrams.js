@@ -0,0 +1,23 @@
+// @ts-check
+// Diagrams: draws the .mermaid blocks of a note with the bundled mermaid library.
+// Only the pages with diagrams load this script, it loads the library the first time.
+
+(function () {
+	function render() {
+		const mermaid = /** @type {any} */ (window).mermaid;
+		mermaid.initialize({
+			startOnLoad: false,
+			theme: document.documentElement.classList.contains('dark') ? 'dark' : 'default',
+		});
+		mermaid.run({ querySelector: '.mermaid:not([data-processed])' });
+	}
+
+	if (/** @type {any} */ (window).mermaid) {
+		render();
+		return;
+	}
+	const script = document.createElement('script');
+	script.src = '/static/mermaid.min.js';
+	script.onload = render;
+	document.head.appendChild(script);
+})();
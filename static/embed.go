@@ -1,15 +1,56 @@
 package static
 
 import (
+	"crypto/sha256"
 	"embed"
+	"encoding/hex"
+	"io/fs"
 	"net/http"
+	"strings"
+	"sync"
 )
 
 //go:embed *
 var StaticFiles embed.FS
 
 // Handler returns a http.Handler that will serve files from
-// the given file system.
+// the given file system. Files requested with their version from URL are cached for a year,
+// the others are revalidated on each use.
 func Handler() http.Handler {
-	return http.FileServer(http.FS(StaticFiles))
+	files := http.FileServer(http.FS(StaticFiles))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if version := r.URL.Query().Get("v"); version != "" && version == versions()[strings.TrimPrefix(r.URL.Path, "/")] {
+			w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		} else {
+			w.Header().Set("Cache-Control", "no-cache")
+		}
+		files.ServeHTTP(w, r)
+	})
 }
+
+// URL returns the URL of the static file name, like "/static/app.js?v=1a2b3c4d5e6f": the
+// version changes with its content, so browsers can cache it for good
+func URL(name string) string {
+	if version, ok := versions()[name]; ok {
+		return "/static/" + name + "?v=" + version
+	}
+	return "/static/" + name
+}
+
+// versions maps each embedded file to the start of the hash of its content
+var versions = sync.OnceValue(func() map[string]string {
+	versions := make(map[string]string)
+	_ = fs.WalkDir(StaticFiles, ".", func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		content, err := StaticFiles.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(content)
+		versions[path] = hex.EncodeToString(sum[:6])
+		return nil
+	})
+	return versions
+})
@@ -4,6 +4,7 @@ import (
 	"embed"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -28,6 +29,11 @@ func TestStaticFilesServing(t *testing.T) {
 			path:           "/app.js",
 			expectedStatus: http.StatusOK,
 		},
+		{
+			name:           "Serve graph.js",
+			path:           "/graph.js",
+			expectedStatus: http.StatusOK,
+		},
 		{
 			name:           "Serve favicon.ico",
 			path:           "/favicon.ico",
@@ -89,3 +95,28 @@ func TestStaticFilesEmbedded(t *testing.T) {
 		})
 	}
 }
+
+func TestURL(t *testing.T) {
+	handler := Handler()
+
+	url := URL("app.js")
+	path, version, found := strings.Cut(url, "?v=")
+	if path != "/static/app.js" || !found || len(version) != 12 {
+		t.Fatalf("URL(app.js) = %q, want a versioned URL", url)
+	}
+	if missing := URL("missing.js"); missing != "/static/missing.js" {
+		t.Errorf("URL(missing.js) = %q, want no version", missing)
+	}
+
+	for target, cacheControl := range map[string]string{
+		"/app.js?v=" + version: "public, max-age=31536000, immutable",
+		"/app.js?v=outdated":   "no-cache",
+		"/app.js":              "no-cache",
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		if w.Code != http.StatusOK || w.Header().Get("Cache-Control") != cacheControl {
+			t.Errorf("%s: status %d, Cache-Control %q, want %q", target, w.Code, w.Header().Get("Cache-Control"), cacheControl)
+		}
+	}
+}
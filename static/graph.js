@@ -0,0 +1,340 @@
+// @ts-check
+// Graph view: a force-directed layout of the notes (nodes) and their wikilinks (edges),
+// drawn on the #graph-canvas of the /-/graph page from the JSON at its data-src.
+
+(function () {
+	// Layout constants
+	const REPULSION = 1200;
+	const REPULSION_CUTOFF = 250;
+	const SPRING_LENGTH = 60;
+	const SPRING_STRENGTH = 0.02;
+	const CENTER_GRAVITY = 0.002;
+	const DAMPING = 0.85;
+	const MIN_ENERGY = 0.05;
+	const NODE_RADIUS = 4;
+	const CLICK_SLOP_PX = 4;
+
+	// Colors of the light and dark themes, see theme.js
+	const COLORS = {
+		light: { highlight: '#2563eb', edge: '#d1d5db', fadedEdge: '#e5e7eb', node: '#6b7280', fadedNode: '#d1d5db', title: '#111827' },
+		dark: { highlight: '#60a5fa', edge: '#4b5563', fadedEdge: '#374151', node: '#9ca3af', fadedNode: '#4b5563', title: '#f3f4f6' },
+	};
+
+	/**
+	 * @typedef {{slug: string, title: string, tags: string[], x: number, y: number, vx: number, vy: number, degree: number}} GraphNode
+	 * @typedef {{from: GraphNode, to: GraphNode}} GraphEdge
+	 */
+
+	const canvas = /** @type {HTMLCanvasElement | null} */ (document.getElementById('graph-canvas'));
+	const status = document.getElementById('graph-status');
+	if (!canvas || !status) {
+		return;
+	}
+	const context = canvas.getContext('2d');
+	if (!context) {
+		return;
+	}
+	const ctx = context;
+
+	/** @type {GraphNode[]} */
+	let nodes = [];
+	/** @type {GraphEdge[]} */
+	let edges = [];
+	let view = { x: 0, y: 0, scale: 1 };
+	/** @type {GraphNode | null} */
+	let hovered = null;
+	/** @type {GraphNode | null} */
+	let dragged = null;
+	let panning = false;
+	let moved = 0;
+	let lastPointer = { x: 0, y: 0 };
+	let running = false;
+
+	fetch(canvas.dataset.src || '/-/graph.json')
+		.then((response) => {
+			if (!response.ok) {
+				throw new Error(response.statusText);
+			}
+			return response.json();
+		})
+		.then((envelope) => {
+			load(envelope.data);
+			status.style.display = 'none';
+			resize();
+			start();
+		})
+		.catch(() => {
+			status.textContent = 'Failed to load the graph.';
+		});
+
+	/**
+	 * Builds the nodes and edges from the graph JSON, nodes on a circle to start with.
+	 * @param {{nodes: {slug: string, title: string, tags: string[]}[], edges: {from: string, to: string}[]}} data
+	 */
+	function load(data) {
+		/** @type {Map<string, GraphNode>} */
+		const bySlug = new Map();
+		const count = data.nodes.length;
+		data.nodes.forEach((node, i) => {
+			const angle = (2 * Math.PI * i) / Math.max(count, 1);
+			const radius = 10 * Math.sqrt(count);
+			const graphNode = { ...node, x: radius * Math.cos(angle), y: radius * Math.sin(angle), vx: 0, vy: 0, degree: 0 };
+			bySlug.set(node.slug, graphNode);
+			nodes.push(graphNode);
+		});
+		for (const edge of data.edges) {
+			const from = bySlug.get(edge.from);
+			const to = bySlug.get(edge.to);
+			if (from && to) {
+				from.degree++;
+				to.degree++;
+				edges.push({ from, to });
+			}
+		}
+		if (count === 0) {
+			status.textContent = 'No notes to show.';
+			status.style.display = '';
+		}
+	}
+
+	/**
+	 * Radius of a node, growing with its number of links.
+	 * @param {GraphNode} node
+	 */
+	function radius(node) {
+		return NODE_RADIUS + Math.sqrt(node.degree) * 1.5;
+	}
+
+	/** One step of the force simulation, returns the kinetic energy left. */
+	function step() {
+		// Repulsion between nearby nodes, with a grid so far away nodes are skipped
+		/** @type {Map<string, GraphNode[]>} */
+		const grid = new Map();
+		for (const node of nodes) {
+			const key = Math.floor(node.x / REPULSION_CUTOFF) + ',' + Math.floor(node.y / REPULSION_CUTOFF);
+			const cell = grid.get(key);
+			if (cell) {
+				cell.push(node);
+			} else {
+				grid.set(key, [node]);
+			}
+		}
+		for (const node of nodes) {
+			const cx = Math.floor(node.x / REPULSION_CUTOFF);
+			const cy = Math.floor(node.y / REPULSION_CUTOFF);
+			for (let dx = -1; dx <= 1; dx++) {
+				for (let dy = -1; dy <= 1; dy++) {
+					for (const other of grid.get(cx + dx + ',' + (cy + dy)) || []) {
+						if (other === node) {
+							continue;
+						}
+						const x = node.x - other.x;
+						const y = node.y - other.y;
+						const distanceSquared = Math.max(x * x + y * y, 1);
+						if (distanceSquared > REPULSION_CUTOFF * REPULSION_CUTOFF) {
+							continue;
+						}
+						const force = REPULSION / distanceSquared;
+						const distance = Math.sqrt(distanceSquared);
+						node.vx += (x / distance) * force;
+						node.vy += (y / distance) * force;
+					}
+				}
+			}
+		}
+
+		// Links pull their notes together
+		for (const edge of edges) {
+			const x = edge.to.x - edge.from.x;
+			const y = edge.to.y - edge.from.y;
+			const distance = Math.max(Math.sqrt(x * x + y * y), 1);
+			const force = (distance - SPRING_LENGTH) * SPRING_STRENGTH;
+			edge.from.vx += (x / distance) * force;
+			edge.from.vy += (y / distance) * force;
+			edge.to.vx -= (x / distance) * force;
+			edge.to.vy -= (y / distance) * force;
+		}
+
+		let energy = 0;
+		for (const node of nodes) {
+			if (node === dragged) {
+				node.vx = node.vy = 0;
+				continue;
+			}
+			// Gravity keeps orphan notes and separate clusters on screen
+			node.vx = (node.vx - node.x * CENTER_GRAVITY) * DAMPING;
+			node.vy = (node.vy - node.y * CENTER_GRAVITY) * DAMPING;
+			node.x += node.vx;
+			node.y += node.vy;
+			energy += node.vx * node.vx + node.vy * node.vy;
+		}
+		return nodes.length ? energy / nodes.length : 0;
+	}
+
+	/** Runs the simulation until the layout settles, or the page is left. */
+	function start() {
+		if (running) {
+			return;
+		}
+		running = true;
+		const frame = () => {
+			if (!canvas.isConnected) {
+				running = false;
+				return;
+			}
+			const energy = step();
+			draw();
+			if (energy < MIN_ENERGY && !dragged) {
+				running = false;
+				return;
+			}
+			requestAnimationFrame(frame);
+		};
+		requestAnimationFrame(frame);
+	}
+
+	function draw() {
+		const ratio = window.devicePixelRatio || 1;
+		ctx.setTransform(ratio, 0, 0, ratio, 0, 0);
+		ctx.clearRect(0, 0, canvas.clientWidth, canvas.clientHeight);
+		ctx.translate(canvas.clientWidth / 2 + view.x, canvas.clientHeight / 2 + view.y);
+		ctx.scale(view.scale, view.scale);
+
+		const neighbors = new Set();
+		if (hovered) {
+			for (const edge of edges) {
+				if (edge.from === hovered) neighbors.add(edge.to);
+				if (edge.to === hovered) neighbors.add(edge.from);
+			}
+		}
+
+		const colors = document.documentElement.classList.contains('dark') ? COLORS.dark : COLORS.light;
+		ctx.lineWidth = 1 / view.scale;
+		for (const edge of edges) {
+			const highlighted = edge.from === hovered || edge.to === hovered;
+			ctx.strokeStyle = highlighted ? colors.highlight : hovered ? colors.fadedEdge : colors.edge;
+			ctx.beginPath();
+			ctx.moveTo(edge.from.x, edge.from.y);
+			ctx.lineTo(edge.to.x, edge.to.y);
+			ctx.stroke();
+		}
+
+		for (const node of nodes) {
+			const faded = hovered && node !== hovered && !neighbors.has(node);
+			ctx.fillStyle = node === hovered ? colors.highlight : faded ? colors.fadedNode : colors.node;
+			ctx.beginPath();
+			ctx.arc(node.x, node.y, radius(node), 0, 2 * Math.PI);
+			ctx.fill();
+		}
+
+		// Titles of the hovered note and its neighbors, or of every note when zoomed in
+		ctx.font = 12 / view.scale + 'px sans-serif';
+		ctx.textAlign = 'center';
+		ctx.fillStyle = colors.title;
+		for (const node of nodes) {
+			if (node === hovered || neighbors.has(node) || (!hovered && view.scale > 1.5)) {
+				ctx.fillText(node.title, node.x, node.y - radius(node) - 4 / view.scale);
+			}
+		}
+	}
+
+	function resize() {
+		const ratio = window.devicePixelRatio || 1;
+		canvas.width = canvas.clientWidth * ratio;
+		canvas.height = canvas.clientHeight * ratio;
+		draw();
+	}
+
+	/**
+	 * Converts a pointer position to graph coordinates.
+	 * @param {MouseEvent} event
+	 */
+	function toGraph(event) {
+		const rect = canvas.getBoundingClientRect();
+		return {
+			x: (event.clientX - rect.left - canvas.clientWidth / 2 - view.x) / view.scale,
+			y: (event.clientY - rect.top - canvas.clientHeight / 2 - view.y) / view.scale,
+		};
+	}
+
+	/**
+	 * Returns the node under the pointer, if any.
+	 * @param {MouseEvent} event
+	 */
+	function nodeAt(event) {
+		const point = toGraph(event);
+		const slop = 3 / view.scale;
+		for (let i = nodes.length - 1; i >= 0; i--) {
+			const node = nodes[i];
+			const r = radius(node) + slop;
+			if ((node.x - point.x) ** 2 + (node.y - point.y) ** 2 <= r * r) {
+				return node;
+			}
+		}
+		return null;
+	}
+
+	canvas.addEventListener('pointerdown', (event) => {
+		canvas.setPointerCapture(event.pointerId);
+		dragged = nodeAt(event);
+		panning = !dragged;
+		moved = 0;
+		lastPointer = { x: event.clientX, y: event.clientY };
+		canvas.style.cursor = 'grabbing';
+	});
+
+	canvas.addEventListener('pointermove', (event) => {
+		const dx = event.clientX - lastPointer.x;
+		const dy = event.clientY - lastPointer.y;
+		lastPointer = { x: event.clientX, y: event.clientY };
+		moved += Math.abs(dx) + Math.abs(dy);
+
+		if (dragged) {
+			const point = toGraph(event);
+			dragged.x = point.x;
+			dragged.y = point.y;
+			start();
+		} else if (panning) {
+			view.x += dx;
+			view.y += dy;
+		} else {
+			hovered = nodeAt(event);
+			canvas.style.cursor = hovered ? 'pointer' : 'grab';
+			canvas.title = hovered ? hovered.title : '';
+		}
+		draw();
+	});
+
+	canvas.addEventListener('pointerup', (event) => {
+		const clicked = moved < CLICK_SLOP_PX ? nodeAt(event) : null;
+		dragged = null;
+		panning = false;
+		canvas.style.cursor = 'grab';
+		if (clicked) {
+			window.location.href = '/' + clicked.slug;
+		}
+	});
+
+	canvas.addEventListener('pointerleave', () => {
+		hovered = null;
+		draw();
+	});
+
+	canvas.addEventListener(
+		'wheel',
+		(event) => {
+			event.preventDefault();
+			const before = toGraph(event);
+			view.scale = Math.min(8, Math.max(0.1, view.scale * Math.exp(-event.deltaY * 0.001)));
+			const after = toGraph(event);
+			// Zoom around the pointer
+			view.x += (after.x - before.x) * view.scale;
+			view.y += (after.y - before.y) * view.scale;
+			draw();
+		},
+		{ passive: false },
+	);
+
+	window.addEventListener('resize', resize);
+	window.addEventListener('themechange', draw);
+})();
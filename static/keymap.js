@@ -0,0 +1,134 @@
+// @ts-check
+// Keyboard shortcuts, listed in the help dialog of the layout (?)
+const KEY_SEQUENCE_TIMEOUT_MS = 1000;
+
+/** @type {string} First key of a two keys shortcut, like "g" of "g h" */
+let pendingKey = '';
+/** @type {ReturnType<typeof setTimeout>|undefined} */
+let pendingKeyTimer;
+
+/**
+ * Reports whether the user is typing in a field, where shortcuts don't fire.
+ * @param {EventTarget|null} target - Target of the key event
+ * @returns {boolean}
+ */
+function isTypingTarget(target) {
+	if (!(target instanceof HTMLElement)) return false;
+	return target.isContentEditable || ['INPUT', 'TEXTAREA', 'SELECT'].includes(target.tagName);
+}
+
+/**
+ * Focuses the search box of the sidebar, or of the search page of static sites, else opens it.
+ */
+function focusSearchBox() {
+	const input = /** @type {HTMLInputElement|null} */ (
+		document.getElementById('quick-search-input') || document.getElementById('static-search-input')
+	);
+	if (!input) {
+		window.location.assign('/-/search');
+		return;
+	}
+	input.focus();
+	input.select();
+}
+
+/**
+ * Focuses the first entry of the table of contents of the note.
+ * @returns {boolean} Whether the note has a table of contents
+ */
+function focusTableOfContents() {
+	const link = /** @type {HTMLElement|null} */ (document.querySelector('#table-of-contents a'));
+	if (!link) return false;
+	link.scrollIntoView({ block: 'nearest' });
+	link.focus();
+	return true;
+}
+
+/**
+ * Opens the dialog listing the shortcuts.
+ * @returns {boolean} Whether the page has the dialog
+ */
+function openShortcutsHelp() {
+	const dialog = /** @type {HTMLDialogElement|null} */ (document.getElementById('shortcuts-help'));
+	if (!dialog || dialog.open) return false;
+	dialog.showModal();
+	return true;
+}
+
+/**
+ * Opens the URL of a data attribute of the body, like data-next.
+ * @param {string} name - Name of the data attribute, like "next"
+ * @returns {boolean} Whether the body has the attribute
+ */
+function followBodyLink(name) {
+	const url = document.body.dataset[name];
+	if (!url) return false;
+	window.location.assign(url);
+	return true;
+}
+
+/**
+ * Runs the shortcut of a key, see the shortcuts of the help dialog.
+ * @param {KeyboardEvent} event - The keydown event
+ */
+function handleShortcut(event) {
+	if (event.defaultPrevented || event.metaKey || event.ctrlKey || event.altKey || isTypingTarget(event.target)) {
+		return;
+	}
+
+	const previousKey = pendingKey;
+	pendingKey = '';
+	clearTimeout(pendingKeyTimer);
+
+	let handled = false;
+	if (previousKey === 'g') {
+		if (event.key === 'h') {
+			window.location.assign('/');
+			handled = true;
+		}
+	} else {
+		switch (event.key) {
+			case '/':
+				focusSearchBox();
+				handled = true;
+				break;
+			case '[':
+				handled = followBodyLink('prev');
+				break;
+			case ']':
+				handled = followBodyLink('next');
+				break;
+			case 't':
+				handled = focusTableOfContents();
+				break;
+			case '?':
+				handled = openShortcutsHelp();
+				break;
+			case 'g':
+				pendingKey = 'g';
+				pendingKeyTimer = setTimeout(() => { pendingKey = ''; }, KEY_SEQUENCE_TIMEOUT_MS);
+				handled = true;
+				break;
+		}
+	}
+
+	if (handled) event.preventDefault();
+}
+
+document.addEventListener('keydown', handleShortcut);
+
+// Boosted navigation only swaps the content of the body: take the links of the new page
+document.addEventListener('htmx:beforeSwap', function (event) {
+	const detail = /** @type {CustomEvent} */ (event).detail;
+	if (!detail.boosted || !detail.shouldSwap || typeof detail.serverResponse !== 'string') return;
+
+	const body = new DOMParser().parseFromString(detail.serverResponse, 'text/html').body;
+	for (const name of ['prev', 'next']) {
+		const url = body.dataset[name];
+		if (url) {
+			document.body.dataset[name] = url;
+		} else {
+			delete document.body.dataset[name];
+		}
+	}
+});
@@ -0,0 +1,48 @@
+// @ts-check
+// Live reload: refreshes the page when the server reloaded the notes, keeping the scroll
+// position and the open folders. Included by the layout only when the server watches the vault.
+const LIVE_RELOAD_DELAY_MS = 300; // A burst of reloads refreshes the page once
+
+(function () {
+	/** @type {ReturnType<typeof setTimeout>|undefined} */
+	let timer;
+
+	function scheduleRefresh() {
+		clearTimeout(timer);
+		timer = setTimeout(refresh, LIVE_RELOAD_DELAY_MS);
+	}
+
+	function refresh() {
+		// Don't lose what is being typed, like a comment: refresh once the field is left
+		const active = document.activeElement;
+		if (active && active.matches('input, textarea, select, [contenteditable]')) {
+			active.addEventListener('focusout', scheduleRefresh, { once: true });
+			return;
+		}
+		// @ts-ignore - htmx is loaded globally
+		if (typeof htmx === 'undefined') {
+			window.location.reload();
+			return;
+		}
+
+		const scrollables = () => document.querySelectorAll('main .overflow-y-auto');
+		const scrolled = Array.from(scrollables(), (element) => element.scrollTop);
+		// @ts-ignore - htmx is loaded globally
+		htmx.ajax('GET', window.location.pathname + window.location.search, { target: 'main', select: 'main > *', swap: 'innerHTML' }).then(
+			function () {
+				scrollables().forEach((element, i) => {
+					if (i < scrolled.length) element.scrollTop = scrolled[i];
+				});
+				// @ts-ignore - defined by app.js
+				if (typeof restoreFolderStates === 'function') restoreFolderStates();
+			},
+			function () {
+				window.location.reload();
+			},
+		);
+	}
+
+	// EventSource reconnects by itself when the server restarts
+	const source = new EventSource('/-/live-reload');
+	source.addEventListener('reload', scheduleRefresh);
+})();
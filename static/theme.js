@@ -0,0 +1,21 @@
+// @ts-check
+// Theme: loaded before the stylesheet so the page never renders with the wrong colors.
+// The dark class on the html element switches the tailwind dark: variants. The choice of
+// the toggle button (toggleTheme in app.js) is kept in localStorage, the system preference
+// applies until then.
+
+(function () {
+	const media = window.matchMedia('(prefers-color-scheme: dark)');
+
+	function applyTheme() {
+		const theme = localStorage.getItem('theme');
+		const isDark = theme ? theme === 'dark' : media.matches;
+		document.documentElement.classList.toggle('dark', isDark);
+	}
+
+	applyTheme();
+	media.addEventListener('change', () => {
+		applyTheme();
+		window.dispatchEvent(new Event('themechange'));
+	});
+})();
@@ -0,0 +1,114 @@
+// @ts-check
+// Table of contents: smooth scrolling to the headings and highlight of the current section.
+// The headings are found from the links of the table of contents, whose ids are set by the
+// server (template/note.go), so this works again on the content swapped by htmx.
+const TOC_ACTIVE_OFFSET_PX = 100;
+
+/** @type {IntersectionObserver|undefined} */
+let tocObserver;
+
+/**
+ * Returns the links of the table of contents with their heading, in the order of the page.
+ * @returns {{link: HTMLAnchorElement, heading: HTMLElement}[]}
+ */
+function tocEntries() {
+	/** @type {{link: HTMLAnchorElement, heading: HTMLElement}[]} */
+	const entries = [];
+	document.querySelectorAll('#table-of-contents a[href^="#"]').forEach((element) => {
+		const link = /** @type {HTMLAnchorElement} */ (element);
+		const heading = document.getElementById(decodeURIComponent(link.hash.slice(1)));
+		if (heading) entries.push({ link, heading });
+	});
+	return entries;
+}
+
+/**
+ * Highlights a link of the table of contents, and only this one.
+ * @param {HTMLAnchorElement|null} activeLink - The link of the current section, null for none
+ */
+function setActiveTocLink(activeLink) {
+	document.querySelectorAll('#table-of-contents a').forEach((link) => {
+		const active = link === activeLink;
+		link.classList.toggle('active', active);
+		if (active) {
+			link.setAttribute('aria-current', 'location');
+		} else {
+			link.removeAttribute('aria-current');
+		}
+	});
+}
+
+/**
+ * Highlights the section being read: the last heading scrolled above the top of the page.
+ */
+function updateActiveTocLink() {
+	const entries = tocEntries();
+	let active = null;
+	for (const entry of entries) {
+		if (entry.heading.getBoundingClientRect().top > TOC_ACTIVE_OFFSET_PX) break;
+		active = entry;
+	}
+	setActiveTocLink(active ? active.link : null);
+}
+
+/**
+ * Watches the headings of the table of contents of the page, replacing the previous watch.
+ * The current section changes when a heading crosses the top of the page.
+ */
+function observeTocHeadings() {
+	if (tocObserver) tocObserver.disconnect();
+
+	const entries = tocEntries();
+	if (entries.length === 0) return;
+
+	tocObserver = new IntersectionObserver(updateActiveTocLink, {
+		rootMargin: `-${TOC_ACTIVE_OFFSET_PX}px 0px 0px 0px`,
+	});
+	entries.forEach((entry) => tocObserver?.observe(entry.heading));
+	updateActiveTocLink();
+}
+
+/**
+ * Scrolls to the heading of the hash of the URL, highlighting its link.
+ */
+function scrollToHashHeading() {
+	if (!window.location.hash) return;
+
+	const heading = document.getElementById(decodeURIComponent(window.location.hash.slice(1)));
+	if (!heading) return;
+	heading.scrollIntoView({ behavior: 'smooth', block: 'start' });
+
+	const link = tocEntries().find((entry) => entry.heading === heading);
+	if (link) setActiveTocLink(link.link);
+}
+
+// Links of the table of contents scroll smoothly to their heading
+document.addEventListener('click', function (event) {
+	const target = /** @type {Element|null} */ (event.target);
+	const link = /** @type {HTMLAnchorElement|null} */ (target && target.closest('#table-of-contents a[href^="#"]'));
+	if (!link || event.metaKey || event.ctrlKey || event.shiftKey || event.button !== 0) return;
+
+	const heading = document.getElementById(decodeURIComponent(link.hash.slice(1)));
+	if (!heading) return;
+
+	event.preventDefault();
+	heading.scrollIntoView({ behavior: 'smooth', block: 'start' });
+	history.pushState(null, '', link.hash);
+	setActiveTocLink(link);
+});
+
+window.addEventListener('hashchange', scrollToHashHeading);
+
+document.addEventListener('DOMContentLoaded', function () {
+	observeTocHeadings();
+	scrollToHashHeading();
+});
+
+// Boosted navigation and swaps bring new headings or a new table of contents
+document.addEventListener('htmx:load', function (event) {
+	const target = /** @type {Element|null} */ (event.target);
+	if (target && (target === document.body || target.querySelector('#table-of-contents, h1[id], h2[id], h3[id], h4[id], h5[id], h6[id]'))) {
+		observeTocHeadings();
+		scrollToHashHeading();
+	}
+});
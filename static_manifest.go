@@ -0,0 +1,151 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/EwenQuim/pluie/config"
+	"github.com/EwenQuim/pluie/engine"
+	"github.com/EwenQuim/pluie/model"
+	"github.com/EwenQuim/pluie/static"
+	"github.com/EwenQuim/pluie/template"
+)
+
+// staticManifestFile is the manifest of the last generation, in the output folder
+const staticManifestFile = ".pluie-manifest.json"
+
+// staticManifest records what a static site generation wrote, so the next one only renders the
+// note pages that changed. Every page shows the sidebar, so when the site hash differs, like
+// after a note was added, renamed or deleted, every page is generated again.
+type staticManifest struct {
+	Site  string                        `json:"site"`  // Hash of what every page shows, see staticSiteHash
+	Notes map[string]staticManifestNote `json:"notes"` // Note pages, by slug
+
+	mu sync.Mutex // Note pages are recorded concurrently
+}
+
+// staticManifestNote is the page of a note in a staticManifest
+type staticManifestNote struct {
+	Hash string `json:"hash"` // See staticNoteHash
+	Path string `json:"path"` // Page of the note, relative to the output folder, its print view next to it
+
+	Minified int64 `json:"minified,omitempty"` // Bytes -minify removed from the page and its print view
+}
+
+// newStaticManifest creates the manifest of a generation of the site with the site hash
+func newStaticManifest(site string) *staticManifest {
+	return &staticManifest{Site: site, Notes: make(map[string]staticManifestNote)}
+}
+
+// readStaticManifest reads the manifest of the output folder, nil when there is none or it is invalid
+func readStaticManifest(output string) *staticManifest {
+	data, err := os.ReadFile(filepath.Join(output, staticManifestFile))
+	if err != nil {
+		return nil
+	}
+	var manifest staticManifest
+	if err := json.Unmarshal(data, &manifest); err != nil || manifest.Notes == nil {
+		return nil
+	}
+	return &manifest
+}
+
+// write writes the manifest to the output folder
+func (m *staticManifest) write(output string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(output, staticManifestFile), data, 0644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return nil
+}
+
+// record adds the page of a note to the manifest
+func (m *staticManifest) record(slug string, page staticManifestNote) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Notes[slug] = page
+}
+
+// unchanged reports whether the page of a note was generated with hash, a nil manifest has no page
+func (m *staticManifest) unchanged(slug, hash string) bool {
+	if m == nil {
+		return false
+	}
+	page, ok := m.Notes[slug]
+	return ok && page.Hash == hash
+}
+
+// staticSiteHash hashes what every page shows: the binary, the static assets, the configuration
+// and the sidebar, and the tags of the notes: a tag page that lost its last note is removed
+func staticSiteHash(notesService *engine.NotesService, cfg *config.Config) string {
+	h := sha256.New()
+	enc := json.NewEncoder(h)
+
+	_ = enc.Encode(version)
+	entries, _ := static.StaticFiles.ReadDir(".")
+	for _, entry := range entries {
+		_ = enc.Encode(static.URL(entry.Name())) // Versioned with the content of the asset
+	}
+	_ = enc.Encode(cfg.String())
+	_ = enc.Encode(cfg.Minify) // The pages hold the sidebar or load it
+
+	// The tree of the notes and folders, with what the sidebar and the links show of the notes
+	var encodeTree func(node *engine.TreeNode)
+	encodeTree = func(node *engine.TreeNode) {
+		_ = enc.Encode([]any{node.Name, node.Path, node.IsFolder, node.Icon, node.Order, node.Description})
+		if note := node.Note; note != nil {
+			_ = enc.Encode([]any{note.Slug, note.Title, note.IsPublic, note.IsDraft, note.Status, note.Access, note.Aliases, note.Permalink})
+		}
+		for _, child := range node.Children {
+			encodeTree(child)
+		}
+	}
+	if tree := notesService.GetTree(); tree != nil {
+		encodeTree(tree)
+	}
+	_ = enc.Encode(notesService.Attachments())
+
+	// The notes of each tag, the tag pages list them
+	tagIndex := notesService.GetTagIndex()
+	for _, count := range tagIndex.Counts() {
+		slugs := make([]string, 0, count.Count)
+		for _, note := range tagIndex[count.Tag] {
+			slugs = append(slugs, note.Slug)
+		}
+		slices.Sort(slugs)
+		_ = enc.Encode([]any{count.Tag, slugs})
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// staticNoteHash hashes what the page of a note shows beside the site: the note with its
+// backlinks, its comments, the content of the notes it links to, which it may transclude, and
+// its unlinked mentions. Notes transcluded by the transcluded notes are not followed, -force
+// generates their pages again.
+func staticNoteHash(notesService *engine.NotesService, rs template.Resource, note model.Note, comments []model.Comment, publicByDefault bool) string {
+	h := sha256.New()
+	enc := json.NewEncoder(h)
+
+	_ = enc.Encode(note)
+	_ = enc.Encode(comments)
+	for _, link := range engine.OutgoingLinks(note, notesService.GetTree(), publicByDefault) {
+		if !link.Broken() {
+			_ = enc.Encode([]any{link.Note.Slug, link.Note.Content})
+		}
+	}
+	_ = rs.UnlinkedMentions(notesService, note).Render(h)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/EwenQuim/pluie/engine"
+)
+
+func TestGenerateStaticSiteIncremental(t *testing.T) {
+	vaultDir := t.TempDir()
+	outputDir := filepath.Join(t.TempDir(), "output")
+	writeTestFile(t, vaultDir, "Index.md", "# Welcome")
+	writeTestFile(t, vaultDir, "Garden.md", "Tomatoes grow here.")
+	writeTestFile(t, vaultDir, "Kitchen.md", "Soup.")
+	writeTestFile(t, vaultDir, "Cellar.md", "Wine.")
+
+	generate := func(force bool) {
+		t.Helper()
+		cfg := testStaticConfig(vaultDir, outputDir)
+		cfg.Force = force
+		notesMap, tree, tagIndex, err := loadNotes(vaultDir, cfg)
+		if err != nil {
+			t.Fatalf("loadNotes error: %v", err)
+		}
+		if err := generateStaticSite(engine.NewNotesService(notesMap, tree, tagIndex), cfg); err != nil {
+			t.Fatalf("generateStaticSite error: %v", err)
+		}
+	}
+	page := func(slug string) string {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(outputDir, slug, "index.html"))
+		if err != nil {
+			t.Fatalf("reading page of %s: %v", slug, err)
+		}
+		return string(data)
+	}
+	// Pages marked as generated long ago: generating them again overwrites the mark
+	mark := func(slugs ...string) {
+		t.Helper()
+		for _, slug := range slugs {
+			if err := os.WriteFile(filepath.Join(outputDir, slug, "index.html"), []byte("kept"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	generate(false)
+	manifest := readStaticManifest(outputDir)
+	if manifest == nil || len(manifest.Notes) != 4 || manifest.Notes["garden"].Path != "garden/index.html" {
+		t.Fatalf("expected the manifest of the 4 notes, got %+v", manifest)
+	}
+
+	// Nothing changed: every note page is kept
+	mark("garden", "kitchen", "cellar")
+	generate(false)
+	for _, slug := range []string{"garden", "kitchen", "cellar"} {
+		if page(slug) != "kept" {
+			t.Errorf("expected the page of %s to be kept", slug)
+		}
+	}
+
+	// A note changed: its page, and the page of the note it now links to, are generated again
+	writeTestFile(t, vaultDir, "Garden.md", "Tomatoes grow here, cooked in the [[Kitchen]].")
+	generate(false)
+	if !strings.Contains(page("garden"), "cooked in the") {
+		t.Error("expected the changed note to be generated again")
+	}
+	if !strings.Contains(page("kitchen"), "Soup.") {
+		t.Error("expected the linked note to be generated again with its backlink")
+	}
+	if page("cellar") != "kept" {
+		t.Error("expected the unchanged note to be kept")
+	}
+
+	// A note lost its last tag: its tag page is removed
+	writeTestFile(t, vaultDir, "Cellar.md", "Wine. #drinks")
+	generate(false)
+	if !strings.Contains(page("-/tag/drinks"), "Cellar") {
+		t.Fatal("expected the page of the new tag")
+	}
+	writeTestFile(t, vaultDir, "Cellar.md", "Wine.")
+	generate(false)
+	if _, err := os.Stat(filepath.Join(outputDir, "-", "tag", "drinks")); !os.IsNotExist(err) {
+		t.Errorf("expected the page of the tag without notes to be removed, got %v", err)
+	}
+
+	// -force generates every page
+	generate(true)
+	if page("cellar") == "kept" {
+		t.Error("expected -force to generate every page")
+	}
+
+	// A deleted note changes the sidebar of every page, and its page is removed
+	mark("garden", "kitchen")
+	if err := os.Remove(filepath.Join(vaultDir, "Cellar.md")); err != nil {
+		t.Fatal(err)
+	}
+	generate(false)
+	if _, err := os.Stat(filepath.Join(outputDir, "cellar")); !os.IsNotExist(err) {
+		t.Errorf("expected the page of the deleted note to be removed, got %v", err)
+	}
+	if page("garden") == "kept" || page("kitchen") == "kept" {
+		t.Error("expected every page to be generated again after a deletion")
+	}
+	if manifest := readStaticManifest(outputDir); manifest == nil || len(manifest.Notes) != 3 {
+		t.Errorf("expected the manifest of the 3 notes left, got %+v", manifest)
+	}
+}
+
+func TestDeleteStaleNotePages(t *testing.T) {
+	output := t.TempDir()
+	writeTestFile(t, output, "Gone/index.html", "old")
+	writeTestFile(t, output, "Gone/print.html", "old")
+	writeTestFile(t, output, "Kept/index.html", "kept")
+
+	previous := newStaticManifest("site")
+	previous.record("Gone", staticManifestNote{Hash: "a", Path: "Gone/index.html"})
+	previous.record("Kept", staticManifestNote{Hash: "b", Path: "Kept/index.html"})
+	manifest := newStaticManifest("site")
+	manifest.record("Kept", staticManifestNote{Hash: "b", Path: "Kept/index.html"})
+
+	deleted, err := deleteStaleNotePages(output, previous, manifest, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 1 {
+		t.Errorf("deleted = %d, want 1", deleted)
+	}
+	if _, err := os.Stat(filepath.Join(output, "Gone")); !os.IsNotExist(err) {
+		t.Errorf("expected the folder of the deleted page to be removed, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(output, "Kept", "index.html")); err != nil {
+		t.Errorf("expected the kept page to stay: %v", err)
+	}
+
+	if deleted, err := deleteStaleNotePages(output, nil, manifest, true); err != nil || deleted != 0 {
+		t.Errorf("expected nothing to delete without a previous manifest, got %d and %v", deleted, err)
+	}
+}
+
+func TestReadStaticManifest(t *testing.T) {
+	output := t.TempDir()
+	if manifest := readStaticManifest(output); manifest != nil {
+		t.Errorf("expected no manifest, got %+v", manifest)
+	}
+
+	writeTestFile(t, output, staticManifestFile, "{not json")
+	if manifest := readStaticManifest(output); manifest != nil {
+		t.Errorf("expected an invalid manifest to be ignored, got %+v", manifest)
+	}
+
+	manifest := newStaticManifest("site")
+	manifest.record("note", staticManifestNote{Hash: "abc", Path: "note/index.html"})
+	if err := manifest.write(output); err != nil {
+		t.Fatal(err)
+	}
+	read := readStaticManifest(output)
+	if read == nil || read.Site != "site" || !read.unchanged("note", "abc") || read.unchanged("note", "def") || read.unchanged("other", "abc") {
+		t.Errorf("expected the written manifest back, got %+v", read)
+	}
+}
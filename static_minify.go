@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/html"
+)
+
+// minifyBlockTags are the elements around which whitespace is not rendered, so the whitespace
+// only text next to them can go
+var minifyBlockTags = []string{
+	"html", "head", "body", "main", "header", "footer", "nav", "section", "article", "aside",
+	"div", "p", "ul", "ol", "li", "dl", "dt", "dd", "h1", "h2", "h3", "h4", "h5", "h6",
+	"table", "thead", "tbody", "tfoot", "tr", "th", "td", "blockquote", "hr", "form", "dialog",
+	"details", "summary", "figure", "figcaption", "title", "meta", "link", "script", "style",
+	"svg", "g", "path", "br", "pre", "template",
+}
+
+// minifyRawTags are the elements whose whitespace is kept as is
+var minifyRawTags = []string{"pre", "textarea", "script", "style"}
+
+// minifyRawClasses are the classes of the elements whose whitespace is kept as is: the text
+// shown with white-space: pre, and the mermaid diagrams, whose syntax is made of lines
+var minifyRawClasses = []string{"whitespace-pre", "mermaid"}
+
+// minifyHTML removes the comments of an HTML page and collapses its whitespace: runs become a
+// single space, whitespace only text next to a block element goes. Preformatted elements, see
+// minifyRawTags and minifyRawClasses, are kept as is, and so are the tags and their attributes.
+func minifyHTML(w io.Writer, r io.Reader) error {
+	type element struct {
+		name string
+		raw  bool
+	}
+	var open []element // Elements with an end tag, to know when a raw one ends
+	raw := 0           // Number of raw elements in open
+	var out bytes.Buffer
+	var space []byte // Whitespace only text, written if the next tag is not a block
+	lastTag := ""    // Name of the last tag written, "" at the start of the page
+
+	z := html.NewTokenizer(r)
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			if err := z.Err(); err != io.EOF {
+				return err
+			}
+			break
+		}
+
+		switch tt {
+		case html.CommentToken:
+			continue
+		case html.DoctypeToken:
+			out.Write(z.Raw())
+			space = nil
+			lastTag = "html"
+			continue
+		case html.TextToken:
+			text := z.Raw()
+			if raw > 0 {
+				out.Write(text)
+				continue
+			}
+			collapsed := collapseWhitespace(text)
+			if len(bytes.TrimSpace(collapsed)) == 0 {
+				if len(collapsed) > 0 && !slices.Contains(minifyBlockTags, lastTag) {
+					space = collapsed
+				}
+				continue
+			}
+			out.Write(space)
+			space = nil
+			out.Write(collapsed)
+			lastTag = "#text"
+			continue
+		}
+
+		name, hasAttr := z.TagName()
+		tag := string(name)
+		if !slices.Contains(minifyBlockTags, tag) {
+			out.Write(space)
+		}
+		space = nil
+		// Written before reading the attributes, which unescapes them in place
+		out.Write(z.Raw())
+		lastTag = tag
+
+		switch tt {
+		case html.StartTagToken:
+			if isVoidElement(tag) {
+				continue
+			}
+			e := element{name: tag, raw: slices.Contains(minifyRawTags, tag)}
+			for hasAttr && !e.raw {
+				var key, val []byte
+				key, val, hasAttr = z.TagAttr()
+				if string(key) == "class" {
+					e.raw = slices.ContainsFunc(minifyRawClasses, func(class string) bool {
+						return bytes.Contains(val, []byte(class))
+					})
+				}
+			}
+			open = append(open, e)
+			if e.raw {
+				raw++
+			}
+		case html.EndTagToken:
+			// Close the elements the tag closes, end tags may be omitted
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i].name != tag {
+					continue
+				}
+				for _, e := range open[i:] {
+					if e.raw {
+						raw--
+					}
+				}
+				open = open[:i]
+				break
+			}
+		}
+	}
+
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+// collapseWhitespace replaces every run of HTML whitespace of text by a single space
+func collapseWhitespace(text []byte) []byte {
+	collapsed := make([]byte, 0, len(text))
+	inSpace := false
+	for _, c := range text {
+		if strings.IndexByte(" \t\n\r\f", c) == -1 {
+			collapsed = append(collapsed, c)
+			inSpace = false
+			continue
+		}
+		if !inSpace {
+			collapsed = append(collapsed, ' ')
+		}
+		inSpace = true
+	}
+	return collapsed
+}
+
+// isVoidElement reports whether an element has no end tag
+func isVoidElement(tag string) bool {
+	switch tag {
+	case "area", "base", "br", "col", "embed", "hr", "img", "input", "link", "meta", "source", "track", "wbr":
+		return true
+	}
+	return false
+}
+
+// minifyStaticPages minifies every HTML page of the output folder, on every CPU, and returns the
+// bytes it removed. The note pages are minified already, see generateNotePage, and left as is.
+func minifyStaticPages(output string) (int64, error) {
+	var pages []string
+	err := filepath.WalkDir(output, func(file string, entry fs.DirEntry, err error) error {
+		if err == nil && !entry.IsDir() && filepath.Ext(file) == ".html" {
+			pages = append(pages, file)
+		}
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list pages: %w", err)
+	}
+
+	var mu sync.Mutex // Guards saved and errs
+	var saved int64
+	var errs []error
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for range runtime.GOMAXPROCS(0) {
+		wg.Go(func() {
+			for page := range jobs {
+				pageSaved, err := minifyStaticPage(page)
+				mu.Lock()
+				saved += pageSaved
+				if err != nil {
+					errs = append(errs, err)
+				}
+				mu.Unlock()
+			}
+		})
+	}
+	for _, page := range pages {
+		jobs <- page
+	}
+	close(jobs)
+	wg.Wait()
+	return saved, errors.Join(errs...)
+}
+
+// minifyStaticPage minifies an HTML page in place and returns the bytes it removed
+func minifyStaticPage(page string) (int64, error) {
+	content, err := os.ReadFile(page)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read page %s: %w", page, err)
+	}
+	var minified bytes.Buffer
+	if err := minifyHTML(&minified, bytes.NewReader(content)); err != nil {
+		return 0, fmt.Errorf("failed to minify page %s: %w", page, err)
+	}
+	if bytes.Equal(minified.Bytes(), content) {
+		return 0, nil
+	}
+	if err := os.WriteFile(page, minified.Bytes(), 0644); err != nil {
+		return 0, fmt.Errorf("failed to write page %s: %w", page, err)
+	}
+	return int64(len(content) - minified.Len()), nil
+}
+
+// outputSize returns the size of the files of the output folder
+func outputSize(output string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(output, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	return size, err
+}
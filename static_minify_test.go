@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/EwenQuim/pluie/engine"
+)
+
+func TestMinifyHTML(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "whitespace between blocks",
+			input:    "<!DOCTYPE html>\n<html>\n  <body>\n    <p>One</p>\n    <ul>\n      <li>Two</li>\n    </ul>\n  </body>\n</html>\n",
+			expected: "<!DOCTYPE html><html><body><p>One</p><ul><li>Two</li></ul></body></html>",
+		},
+		{
+			name:     "whitespace runs in text",
+			input:    "<p>Some   words\n\tover  lines </p>",
+			expected: "<p>Some words over lines </p>",
+		},
+		{
+			name:     "space between inline elements",
+			input:    "<p><b>bold</b>\n  <i>italic</i></p>",
+			expected: "<p><b>bold</b> <i>italic</i></p>",
+		},
+		{
+			name:     "comments",
+			input:    "<div><!-- a comment --><p>Kept</p></div>",
+			expected: "<div><p>Kept</p></div>",
+		},
+		{
+			name:     "preformatted",
+			input:    "<pre><code>func main() {\n\tfmt.Println(\"hi\")\n}</code></pre>\n<textarea>  a\n  b</textarea>",
+			expected: "<pre><code>func main() {\n\tfmt.Println(\"hi\")\n}</code></pre><textarea>  a\n  b</textarea>",
+		},
+		{
+			name:     "scripts",
+			input:    "<script>\n  if (a  <  b) { go() }\n</script>",
+			expected: "<script>\n  if (a  <  b) { go() }\n</script>",
+		},
+		{
+			name:     "mermaid and pre classes",
+			input:    "<div class=\"mermaid\">graph TD\n  A --> B</div>\n<p class=\"mt-1 whitespace-pre-line\">line one\nline two</p><p>after  it</p>",
+			expected: "<div class=\"mermaid\">graph TD\n  A --> B</div><p class=\"mt-1 whitespace-pre-line\">line one\nline two</p><p>after it</p>",
+		},
+		{
+			name:     "nested raw element",
+			input:    "<div class=\"whitespace-pre-wrap\"><div>a  b</div>  c</div><p>d  e</p>",
+			expected: "<div class=\"whitespace-pre-wrap\"><div>a  b</div>  c</div><p>d e</p>",
+		},
+		{
+			name:     "attributes and entities kept",
+			input:    "<a href=\"/a?x=1&amp;y=2\"  title='Two  spaces'>A &amp;  B</a>",
+			expected: "<a href=\"/a?x=1&amp;y=2\"  title='Two  spaces'>A &amp; B</a>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sb strings.Builder
+			if err := minifyHTML(&sb, strings.NewReader(tt.input)); err != nil {
+				t.Fatalf("minifyHTML() returned error: %v", err)
+			}
+			if sb.String() != tt.expected {
+				t.Errorf("minifyHTML() =\n%q\nwant\n%q", sb.String(), tt.expected)
+			}
+
+			// Minifying again changes nothing, kept pages are left as is
+			var again strings.Builder
+			if err := minifyHTML(&again, strings.NewReader(sb.String())); err != nil || again.String() != sb.String() {
+				t.Errorf("minifying again gave %q, %v", again.String(), err)
+			}
+		})
+	}
+}
+
+func TestGenerateStaticSiteMinify(t *testing.T) {
+	vaultDir := t.TempDir()
+	writeTestFile(t, vaultDir, "Index.md", "# Welcome\n\nSee the [[Recipe]].\n\n- one\n- two\n")
+	writeTestFile(t, vaultDir, "Kitchen/Recipe.md", "# Recipe\n\n```go\nfunc cook() {\n\treturn\n}\n```\n")
+	for i := range 20 {
+		writeTestFile(t, vaultDir, filepath.Join("Pantry", strings.Repeat("x", i+1)+".md"), "A jar.")
+	}
+
+	generate := func(minify bool) (string, int64) {
+		t.Helper()
+		outputDir := filepath.Join(t.TempDir(), "output")
+		cfg := testStaticConfig(vaultDir, outputDir)
+		cfg.Minify = minify
+		notesMap, tree, tagIndex, err := loadNotes(vaultDir, cfg)
+		if err != nil {
+			t.Fatalf("loadNotes error: %v", err)
+		}
+		if err := generateStaticSite(engine.NewNotesService(notesMap, tree, tagIndex), cfg); err != nil {
+			t.Fatalf("generateStaticSite error: %v", err)
+		}
+		size, err := outputSize(outputDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return outputDir, size
+	}
+	read := func(outputDir, file string) string {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(outputDir, filepath.FromSlash(file)))
+		if err != nil {
+			t.Fatalf("reading %s: %v", file, err)
+		}
+		return string(data)
+	}
+
+	fullDir, fullSize := generate(false)
+	if _, err := os.Stat(filepath.Join(fullDir, staticSidebarFile)); !os.IsNotExist(err) {
+		t.Errorf("expected no shared sidebar without -minify, got %v", err)
+	}
+	if !strings.Contains(read(fullDir, "kitchen/recipe/index.html"), `href="/pantry/xxx"`) {
+		t.Error("without -minify, the pages should hold the notes tree")
+	}
+
+	minDir, minSize := generate(true)
+	if minSize >= fullSize {
+		t.Errorf("expected -minify to make the output smaller, got %d bytes from %d", minSize, fullSize)
+	}
+
+	page := read(minDir, "kitchen/recipe/index.html")
+	if strings.Contains(page, `href="/pantry/xxx"`) || !strings.Contains(page, `hx-get="/sidebar.html"`) {
+		t.Error("with -minify, the pages should load the shared sidebar")
+	}
+	if !strings.Contains(page, "\treturn\n") {
+		t.Error("the code blocks should keep their whitespace")
+	}
+	if home := read(minDir, "index.html"); strings.Contains(home, "\n<") || !strings.Contains(home, "<ul><li>one</li><li>two</li></ul>") {
+		t.Errorf("expected the whitespace between the tags to be removed, got %s", home)
+	}
+
+	sidebar := read(minDir, staticSidebarFile)
+	if !strings.HasPrefix(sidebar, `<div id="notes-list"`) || !strings.Contains(sidebar, `href="/pantry/xxx"`) || !strings.Contains(sidebar, `href="/kitchen/recipe"`) {
+		t.Errorf("expected the shared sidebar to list every note, got %s", sidebar)
+	}
+}
+
+func TestGenerateStaticSiteMinifySizes(t *testing.T) {
+	vaultDir := t.TempDir()
+	outputDir := filepath.Join(t.TempDir(), "output")
+	writeTestFile(t, vaultDir, "Index.md", "# Welcome\n\nSee the [[Recipe]].")
+	writeTestFile(t, vaultDir, "Recipe.md", "# Recipe\n\n- flour\n- water\n")
+
+	var logs strings.Builder
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelInfo})))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	// generate returns the sizes of the generation complete log
+	generate := func() (before, after int64) {
+		t.Helper()
+		logs.Reset()
+		cfg := testStaticConfig(vaultDir, outputDir)
+		cfg.Minify = true
+		notesMap, tree, tagIndex, err := loadNotes(vaultDir, cfg)
+		if err != nil {
+			t.Fatalf("loadNotes error: %v", err)
+		}
+		if err := generateStaticSite(engine.NewNotesService(notesMap, tree, tagIndex), cfg); err != nil {
+			t.Fatalf("generateStaticSite error: %v", err)
+		}
+		for line := range strings.Lines(logs.String()) {
+			var entry struct {
+				Msg    string `json:"msg"`
+				Before int64  `json:"size_before_minify"`
+				Size   int64  `json:"size"`
+			}
+			if json.Unmarshal([]byte(line), &entry) == nil && entry.Msg == "Static site generation complete" {
+				return entry.Before, entry.Size
+			}
+		}
+		t.Fatalf("no generation complete log in:\n%s", logs.String())
+		return 0, 0
+	}
+
+	fullBefore, fullAfter := generate()
+	if fullBefore <= fullAfter {
+		t.Errorf("expected the minification to save bytes, got %d from %d", fullAfter, fullBefore)
+	}
+
+	// The kept note pages are minified already, the bytes removed from them still count
+	keptBefore, keptAfter := generate()
+	if keptBefore != fullBefore || keptAfter != fullAfter {
+		t.Errorf("expected the sizes of the full generation %d and %d when the pages are kept, got %d and %d", fullBefore, fullAfter, keptBefore, keptAfter)
+	}
+}
@@ -1,12 +1,20 @@
 package main
 
 import (
+	"fmt"
+	"io/fs"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 
 	"github.com/EwenQuim/pluie/config"
 	"github.com/EwenQuim/pluie/engine"
+	"github.com/EwenQuim/pluie/static"
+	"golang.org/x/net/html"
 )
 
 func testStaticConfig(vaultDir, outputDir string) *config.Config {
@@ -89,6 +97,7 @@ Some content here.
 	expectedFiles := []string{
 		"index.html",
 		"static",
+		"static/theme.js",
 	}
 	for _, f := range expectedFiles {
 		path := filepath.Join(outputDir, f)
@@ -105,4 +114,500 @@ Some content here.
 	if len(indexHTML) == 0 {
 		t.Error("index.html should not be empty")
 	}
+	if !strings.Contains(string(indexHTML), `<script src="`+static.URL("theme.js")+`"></script>`) || !strings.Contains(string(indexHTML), `onclick="toggleTheme()"`) {
+		t.Error("index.html should load the theme script and show the theme toggle")
+	}
+}
+
+func TestGenerateStaticSiteSitemap(t *testing.T) {
+	vaultDir := t.TempDir()
+	writeTestFile(t, vaultDir, "Index.md", "---\npublish: true\nmodified: 2024-03-10\n---\n# Welcome\n\n#garden/vegetables")
+	writeTestFile(t, vaultDir, "Secret.md", "---\npublish: false\n---\n#hidden")
+
+	generate := func(siteURL string) string {
+		t.Helper()
+		outputDir := filepath.Join(t.TempDir(), "output")
+		cfg := testStaticConfig(vaultDir, outputDir)
+		cfg.PublicByDefault = false
+		cfg.SiteURL = siteURL
+		notesMap, tree, tagIndex, err := loadNotes(vaultDir, cfg)
+		if err != nil {
+			t.Fatalf("loadNotes error: %v", err)
+		}
+		if err := generateStaticSite(engine.NewNotesService(notesMap, tree, tagIndex), cfg); err != nil {
+			t.Fatalf("generateStaticSite error: %v", err)
+		}
+		return outputDir
+	}
+
+	outputDir := generate("https://notes.example.com")
+	data, err := os.ReadFile(filepath.Join(outputDir, "sitemap.xml"))
+	if err != nil {
+		t.Fatalf("sitemap.xml should be generated: %v", err)
+	}
+	sitemap := string(data)
+	for _, loc := range []string{
+		"<loc>https://notes.example.com/index</loc>\n    <lastmod>2024-03-10T00:00:00+00:00</lastmod>",
+		"<loc>https://notes.example.com/-/tag/garden/vegetables</loc>",
+	} {
+		if !strings.Contains(sitemap, loc) {
+			t.Errorf("expected %q in sitemap:\n%s", loc, sitemap)
+		}
+	}
+	if strings.Contains(sitemap, "secret") || strings.Contains(sitemap, "hidden") {
+		t.Errorf("private notes and their tags should not be listed:\n%s", sitemap)
+	}
+
+	// Every listed tag page exists
+	if _, err := os.Stat(filepath.Join(outputDir, "-", "tag", "garden", "vegetables", "index.html")); err != nil {
+		t.Errorf("the tag page of the sitemap should exist: %v", err)
+	}
+
+	// The feed links to the pages of the static site
+	data, err = os.ReadFile(filepath.Join(outputDir, "feed.xml"))
+	if err != nil {
+		t.Fatalf("feed.xml should be generated: %v", err)
+	}
+	feed := string(data)
+	if !strings.Contains(feed, "<link>https://notes.example.com/index</link>") || !strings.Contains(feed, `href="https://notes.example.com/feed.xml"`) {
+		t.Errorf("unexpected feed:\n%s", feed)
+	}
+	if strings.Contains(feed, "secret") {
+		t.Errorf("private notes should not be listed:\n%s", feed)
+	}
+
+	// robots.txt points to the sitemap
+	data, err = os.ReadFile(filepath.Join(outputDir, "robots.txt"))
+	if err != nil || !strings.Contains(string(data), "Disallow: /-/search\n") || !strings.Contains(string(data), "Sitemap: https://notes.example.com/sitemap.xml") {
+		t.Errorf("unexpected robots.txt %q: %v", data, err)
+	}
+
+	// Without SITE_URL, no absolute URLs can be written
+	emptyOutput := generate("")
+	for _, name := range []string{"sitemap.xml", "feed.xml"} {
+		if _, err := os.Stat(filepath.Join(emptyOutput, name)); !os.IsNotExist(err) {
+			t.Errorf("%s should not be generated without SITE_URL, got %v", name, err)
+		}
+	}
+	if data, err := os.ReadFile(filepath.Join(emptyOutput, "robots.txt")); err != nil || strings.Contains(string(data), "Sitemap") {
+		t.Errorf("robots.txt should be generated without its sitemap, got %q: %v", data, err)
+	}
+}
+
+func TestGenerateStaticSiteAttachments(t *testing.T) {
+	vaultDir := t.TempDir()
+	writeTestFile(t, vaultDir, "Trip.md", "---\npublish: true\n---\n![[beach.png]] and ![[guide.pdf]]")
+	writeTestFile(t, vaultDir, "journal/Walk.md", "---\npublish: true\n---\n![](../attachments/path.jpg)")
+	writeTestFile(t, vaultDir, "attachments/path.jpg", "path pixels")
+	writeTestFile(t, vaultDir, "Diary.md", "---\npublish: false\n---\n![[secret.png]]")
+	writeTestFile(t, vaultDir, "photos/beach.png", "beach pixels")
+	writeTestFile(t, vaultDir, "guide.pdf", "pdf bytes")
+	writeTestFile(t, vaultDir, "secret.png", "secret pixels")
+
+	outputDir := filepath.Join(t.TempDir(), "output")
+	cfg := testStaticConfig(vaultDir, outputDir)
+	cfg.PublicByDefault = false
+	notesMap, tree, tagIndex, err := loadNotes(vaultDir, cfg)
+	if err != nil {
+		t.Fatalf("loadNotes error: %v", err)
+	}
+	if err := generateStaticSite(engine.NewNotesService(notesMap, tree, tagIndex), cfg); err != nil {
+		t.Fatalf("generateStaticSite error: %v", err)
+	}
+
+	for name, content := range map[string]string{"photos/beach.png": "beach pixels", "guide.pdf": "pdf bytes", "attachments/path.jpg": "path pixels"} {
+		data, err := os.ReadFile(filepath.Join(outputDir, "-", "attachments", filepath.FromSlash(name)))
+		if err != nil || string(data) != content {
+			t.Errorf("attachment %s should be copied, got %q, %v", name, data, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "-", "attachments", "secret.png")); !os.IsNotExist(err) {
+		t.Errorf("attachments of private notes should not be copied, got %v", err)
+	}
+
+	page, err := os.ReadFile(filepath.Join(outputDir, "trip", "index.html"))
+	if err != nil {
+		t.Fatalf("the note page should be generated: %v", err)
+	}
+	if !strings.Contains(string(page), `src="/-/attachments/photos/beach.png"`) || !strings.Contains(string(page), `href="/-/attachments/guide.pdf"`) {
+		t.Errorf("the page should link to the copied attachments:\n%s", page)
+	}
+	walk, err := os.ReadFile(filepath.Join(outputDir, "journal", "walk", "index.html"))
+	if err != nil || !strings.Contains(string(walk), `src="/-/attachments/attachments/path.jpg"`) {
+		t.Errorf("relative markdown links should point to the copied attachment, got %v:\n%s", err, walk)
+	}
+}
+
+func TestGenerateStaticSiteTagPages(t *testing.T) {
+	vaultDir := t.TempDir()
+	writeTestFile(t, vaultDir, "Go.md", "---\npublish: true\ntags: [golang, \"../escape\"]\n---\n#golang/web and #shared")
+	writeTestFile(t, vaultDir, "Recipes.md", "---\npublish: true\n---\n#shared #cooking")
+	writeTestFile(t, vaultDir, "Diary.md", "---\npublish: false\n---\n#hidden and #shared")
+
+	outputDir := filepath.Join(t.TempDir(), "output")
+	cfg := testStaticConfig(vaultDir, outputDir)
+	cfg.PublicByDefault = false
+	notesMap, tree, tagIndex, err := loadNotes(vaultDir, cfg)
+	if err != nil {
+		t.Fatalf("loadNotes error: %v", err)
+	}
+	if err := generateStaticSite(engine.NewNotesService(notesMap, tree, tagIndex), cfg); err != nil {
+		t.Fatalf("generateStaticSite error: %v", err)
+	}
+
+	// A page per tag of the index, in nested folders for nested tags
+	for _, tag := range tagIndex.GetAllTags() {
+		if !isSafeStaticTag(tag) {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(outputDir, "-", "tag", filepath.FromSlash(tag), "index.html")); err != nil {
+			t.Errorf("the page of tag %q should exist: %v", tag, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "-", "tag", "golang", "web", "index.html")); err != nil {
+		t.Errorf("nested tags should get nested folders: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "-", "tag", "hidden")); !os.IsNotExist(err) {
+		t.Errorf("tags of private notes only should be skipped, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "-", "escape")); !os.IsNotExist(err) {
+		t.Errorf("tags should not escape the tag folder, got %v", err)
+	}
+
+	index, err := os.ReadFile(filepath.Join(outputDir, "-", "tag", "index.html"))
+	if err != nil {
+		t.Fatalf("the tag index should be generated: %v", err)
+	}
+	if cloud, err := os.ReadFile(filepath.Join(outputDir, "-", "tags", "index.html")); err != nil || string(cloud) != string(index) {
+		t.Errorf("the tag cloud should be generated at /-/tags too: %v", err)
+	}
+	if !strings.Contains(string(index), `href="/-/tag/golang/web"`) || !strings.Contains(string(index), "#shared") ||
+		strings.Contains(string(index), "#hidden") || strings.Contains(string(index), "escape") {
+		t.Errorf("unexpected tag index:\n%s", index)
+	}
+
+	search, err := os.ReadFile(filepath.Join(outputDir, "-", "search", "index.html"))
+	if err != nil {
+		t.Fatalf("the search page should be generated: %v", err)
+	}
+	if !strings.Contains(string(search), `id="static-search-results"`) || !strings.Contains(string(search), `href="/recipes"`) || strings.Contains(string(search), "diary") {
+		t.Errorf("unexpected search page:\n%s", search)
+	}
+}
+
+func TestGenerateStaticSiteTasksPage(t *testing.T) {
+	vaultDir := t.TempDir()
+	writeTestFile(t, vaultDir, "Garden.md", "---\npublish: true\n---\n- [ ] Water the tomatoes\n- [x] Plant seeds")
+	writeTestFile(t, vaultDir, "Diary.md", "---\npublish: false\n---\n- [ ] Secret errand")
+
+	outputDir := filepath.Join(t.TempDir(), "output")
+	cfg := testStaticConfig(vaultDir, outputDir)
+	cfg.PublicByDefault = false
+	notesMap, tree, tagIndex, err := loadNotes(vaultDir, cfg)
+	if err != nil {
+		t.Fatalf("loadNotes error: %v", err)
+	}
+	if err := generateStaticSite(engine.NewNotesService(notesMap, tree, tagIndex), cfg); err != nil {
+		t.Fatalf("generateStaticSite error: %v", err)
+	}
+
+	tasks, err := os.ReadFile(filepath.Join(outputDir, "-", "tasks", "index.html"))
+	if err != nil {
+		t.Fatalf("the tasks page should be generated: %v", err)
+	}
+	page := string(tasks)
+	if !strings.Contains(page, "Water the tomatoes") || !strings.Contains(page, `href="/garden"`) ||
+		strings.Contains(page, "Plant seeds") || strings.Contains(page, "Secret errand") {
+		t.Errorf("unexpected tasks page:\n%s", page)
+	}
+}
+
+func TestGenerateStaticSiteFolderPages(t *testing.T) {
+	vaultDir := t.TempDir()
+	writeTestFile(t, vaultDir, "Projects/.pluie", "---\ndescription: Client work\n---\n")
+	writeTestFile(t, vaultDir, "Projects/Kickoff.md", "---\npublish: true\n---\n# Kickoff")
+	writeTestFile(t, vaultDir, "Projects/Client X/Meeting.md", "---\npublish: true\n---\n# Meeting")
+	writeTestFile(t, vaultDir, "Projects/Secret/Plan.md", "---\npublish: false\n---\n# Plan")
+	writeTestFile(t, vaultDir, "Archive.md", "---\npublish: true\n---\n# Archive")
+	writeTestFile(t, vaultDir, "Archive/Old.md", "---\npublish: true\n---\n# Old")
+
+	outputDir := filepath.Join(t.TempDir(), "output")
+	cfg := testStaticConfig(vaultDir, outputDir)
+	cfg.PublicByDefault = false
+	notesMap, tree, tagIndex, err := loadNotes(vaultDir, cfg)
+	if err != nil {
+		t.Fatalf("loadNotes error: %v", err)
+	}
+	if err := generateStaticSite(engine.NewNotesService(notesMap, tree, tagIndex), cfg); err != nil {
+		t.Fatalf("generateStaticSite error: %v", err)
+	}
+
+	projects, err := os.ReadFile(filepath.Join(outputDir, "projects", "index.html"))
+	if err != nil {
+		t.Fatalf("the folder page should be generated: %v", err)
+	}
+	page := string(projects)
+	for _, expected := range []string{"Client work", `href="/projects/kickoff"`, `href="/projects/client-x"`} {
+		if !strings.Contains(page, expected) {
+			t.Errorf("expected the folder page to contain %q", expected)
+		}
+	}
+	if strings.Contains(page, `href="/projects/secret"`) {
+		t.Error("folders with only private notes should not be listed")
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "projects", "client-x", "index.html")); err != nil {
+		t.Errorf("nested folders should get a page too: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "projects", "secret", "index.html")); err == nil {
+		t.Error("folders with only private notes should get no page")
+	}
+
+	archive, err := os.ReadFile(filepath.Join(outputDir, "archive", "index.html"))
+	if err != nil || !strings.Contains(string(archive), "<h1") || strings.Contains(string(archive), `id="folder-index"`) {
+		t.Error("a note with the slug of a folder should keep its page")
+	}
+}
+
+func TestGenerateStaticSiteLinks(t *testing.T) {
+	vaultDir := t.TempDir()
+	writeTestFile(t, vaultDir, "Index.md", "# Welcome\n\nSee [[Café]] and [[Kickoff]].")
+	writeTestFile(t, vaultDir, "Projects.md", "# Projects\n\nThe note of the folder.")
+	writeTestFile(t, vaultDir, "Projects/Kickoff.md", "# Kickoff\n\n#work")
+	writeTestFile(t, vaultDir, "Projects/Client X/Meeting.md", "# Meeting")
+	writeTestFile(t, vaultDir, "Café.md", "---\ntags: [thé]\n---\n# Café\n\n#work/coffee")
+	writeTestFile(t, vaultDir, "Archive/Deep/Old notes.md", "# Old notes")
+
+	// With -minify, the sidebar links are in the shared sidebar.html
+	for _, minify := range []bool{false, true} {
+		t.Run(fmt.Sprintf("minify=%v", minify), func(t *testing.T) {
+			outputDir := filepath.Join(t.TempDir(), "output")
+			cfg := testStaticConfig(vaultDir, outputDir)
+			cfg.Minify = minify
+			notesMap, tree, tagIndex, err := loadNotes(vaultDir, cfg)
+			if err != nil {
+				t.Fatalf("loadNotes error: %v", err)
+			}
+			if err := generateStaticSite(engine.NewNotesService(notesMap, tree, tagIndex), cfg); err != nil {
+				t.Fatalf("generateStaticSite error: %v", err)
+			}
+
+			// Every site link of every page is a file of the output, the way static hosts serve them:
+			// the decoded path, its index.html when it has no extension
+			var linked []string
+			err = filepath.WalkDir(outputDir, func(file string, entry fs.DirEntry, err error) error {
+				if err != nil || entry.IsDir() || filepath.Ext(file) != ".html" {
+					return err
+				}
+				content, err := os.Open(file)
+				if err != nil {
+					return err
+				}
+				defer content.Close()
+				doc, err := html.Parse(content)
+				if err != nil {
+					return err
+				}
+				for node := range doc.Descendants() {
+					if node.Type != html.ElementNode || node.Data != "a" {
+						continue
+					}
+					for _, attr := range node.Attr {
+						if attr.Key != "href" || !strings.HasPrefix(attr.Val, "/") || strings.HasPrefix(attr.Val, "//") {
+							continue
+						}
+						link, err := url.Parse(attr.Val)
+						if err != nil {
+							t.Errorf("invalid link %q in %s", attr.Val, file)
+							continue
+						}
+						target := filepath.Join(outputDir, filepath.FromSlash(link.Path))
+						if path.Ext(link.Path) == "" {
+							target = filepath.Join(target, "index.html")
+						}
+						if _, err := os.Stat(target); err != nil {
+							rel, _ := filepath.Rel(outputDir, file)
+							t.Errorf("link %q of %s has no file", attr.Val, rel)
+						}
+						linked = append(linked, link.Path)
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("walking the output: %v", err)
+			}
+
+			// The sidebar links to every note, the note of a folder keeps its slug
+			for _, expected := range []string{"/index", "/projects", "/projects/kickoff", "/projects/client-x/meeting", "/café", "/archive/deep/old-notes", "/-/tag/work/coffee", "/-/tag/thé"} {
+				if !slices.Contains(linked, expected) {
+					t.Errorf("expected a link to %s, got %v", expected, linked)
+				}
+			}
+			projects, err := os.ReadFile(filepath.Join(outputDir, "projects", "index.html"))
+			if err != nil || strings.Contains(string(projects), `id="folder-index"`) || !strings.Contains(string(projects), "The note of the folder.") {
+				t.Error("the note with the slug of a folder should keep its page")
+			}
+		})
+	}
+}
+
+func TestGenerateStaticSiteOpenGraph(t *testing.T) {
+	vaultDir := t.TempDir()
+	writeTestFile(t, vaultDir, "Trip.md", "---\ncover: \"[[beach.png]]\"\n---\nTwo weeks by the sea")
+	writeTestFile(t, vaultDir, "photos/beach.png", "beach pixels")
+
+	outputDir := filepath.Join(t.TempDir(), "output")
+	cfg := testStaticConfig(vaultDir, outputDir)
+	cfg.SiteURL = "https://notes.example.com"
+	notesMap, tree, tagIndex, err := loadNotes(vaultDir, cfg)
+	if err != nil {
+		t.Fatalf("loadNotes error: %v", err)
+	}
+	if err := generateStaticSite(engine.NewNotesService(notesMap, tree, tagIndex), cfg); err != nil {
+		t.Fatalf("generateStaticSite error: %v", err)
+	}
+
+	page, err := os.ReadFile(filepath.Join(outputDir, "trip", "index.html"))
+	if err != nil {
+		t.Fatalf("the note page should be generated: %v", err)
+	}
+	for _, expected := range []string{
+		`<meta property="og:url" content="https://notes.example.com/trip">`,
+		`<meta property="og:image" content="https://notes.example.com/-/attachments/photos/beach.png">`,
+		`<meta name="twitter:card" content="summary_large_image">`,
+	} {
+		if !strings.Contains(string(page), expected) {
+			t.Errorf("expected %s in the static page", expected)
+		}
+	}
+	// The cover is copied like the embedded attachments
+	if _, err := os.Stat(filepath.Join(outputDir, "-", "attachments", "photos", "beach.png")); err != nil {
+		t.Errorf("the cover should be copied: %v", err)
+	}
+}
+
+func TestIsSafeStaticTag(t *testing.T) {
+	for tag, expected := range map[string]bool{
+		"golang":     true,
+		"golang/web": true,
+		"../escape":  false,
+		"a/../b":     false,
+		"/root":      false,
+		"trailing/":  false,
+		".":          false,
+		`back\slash`: false,
+	} {
+		if got := isSafeStaticTag(tag); got != expected {
+			t.Errorf("isSafeStaticTag(%q) = %v, want %v", tag, got, expected)
+		}
+	}
+}
+
+func TestGenerateStaticSitePrintViews(t *testing.T) {
+	vaultDir := t.TempDir()
+	outputDir := filepath.Join(t.TempDir(), "output")
+	os.WriteFile(filepath.Join(vaultDir, "Index.md"), []byte("# Welcome\n\nHello."), 0644)
+	os.MkdirAll(filepath.Join(vaultDir, "Notes"), 0755)
+	os.WriteFile(filepath.Join(vaultDir, "Notes", "Report.md"), []byte("# Report\n\nThe findings."), 0644)
+
+	cfg := testStaticConfig(vaultDir, outputDir)
+	notesMap, tree, tagIndex, err := loadNotes(vaultDir, cfg)
+	if err != nil {
+		t.Fatalf("loadNotes error: %v", err)
+	}
+	if err := generateStaticSite(engine.NewNotesService(notesMap, tree, tagIndex), cfg); err != nil {
+		t.Fatalf("generateStaticSite error: %v", err)
+	}
+
+	printView, err := os.ReadFile(filepath.Join(outputDir, "notes", "report", "print.html"))
+	if err != nil {
+		t.Fatalf("expected a print view next to the note: %v", err)
+	}
+	if !strings.Contains(string(printView), `id="print-view"`) || !strings.Contains(string(printView), "The findings.") {
+		t.Error("print.html should hold the print view of the note")
+	}
+
+	page, err := os.ReadFile(filepath.Join(outputDir, "notes", "report", "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(page), `href="/notes/report/print.html"`) {
+		t.Error("the note page should link to its print.html")
+	}
+}
+
+func TestGenerateStaticSiteRandomPage(t *testing.T) {
+	vaultDir := t.TempDir()
+	outputDir := filepath.Join(t.TempDir(), "output")
+	os.WriteFile(filepath.Join(vaultDir, "Garden.md"), []byte("---\ntags: [Plants]\n---\nTomatoes"), 0644)
+
+	cfg := testStaticConfig(vaultDir, outputDir)
+	notesMap, tree, tagIndex, err := loadNotes(vaultDir, cfg)
+	if err != nil {
+		t.Fatalf("loadNotes error: %v", err)
+	}
+	if err := generateStaticSite(engine.NewNotesService(notesMap, tree, tagIndex), cfg); err != nil {
+		t.Fatalf("generateStaticSite error: %v", err)
+	}
+
+	page, err := os.ReadFile(filepath.Join(outputDir, "-", "random", "index.html"))
+	if err != nil {
+		t.Fatalf("expected a random note page: %v", err)
+	}
+	if !strings.Contains(string(page), `<script id="random-notes" type="application/json">[{"slug":"garden","tags":["plants"]}]</script>`) {
+		t.Errorf("the random note page should embed the notes to pick from, got %s", page)
+	}
+}
+
+func TestGenerateStaticSiteUnlinkedMentions(t *testing.T) {
+	vaultDir := t.TempDir()
+	outputDir := filepath.Join(t.TempDir(), "output")
+	os.WriteFile(filepath.Join(vaultDir, "Garden.md"), []byte("Tomatoes"), 0644)
+	os.WriteFile(filepath.Join(vaultDir, "Diary.md"), []byte("Worked in the garden."), 0644)
+
+	cfg := testStaticConfig(vaultDir, outputDir)
+	notesMap, tree, tagIndex, err := loadNotes(vaultDir, cfg)
+	if err != nil {
+		t.Fatalf("loadNotes error: %v", err)
+	}
+	if err := generateStaticSite(engine.NewNotesService(notesMap, tree, tagIndex), cfg); err != nil {
+		t.Fatalf("generateStaticSite error: %v", err)
+	}
+
+	// No server to load them from, the mentions are in the page
+	page, err := os.ReadFile(filepath.Join(outputDir, "garden", "index.html"))
+	if err != nil {
+		t.Fatalf("expected the garden page: %v", err)
+	}
+	if !strings.Contains(string(page), `<details id="unlinked-mentions"`) || !strings.Contains(string(page), `href="/diary"`) || strings.Contains(string(page), "hx-get") {
+		t.Errorf("the unlinked mentions should be rendered in static pages, got %s", page)
+	}
+}
+
+func TestGenerateStaticSiteNotFoundPage(t *testing.T) {
+	vaultDir := t.TempDir()
+	outputDir := filepath.Join(t.TempDir(), "output")
+	os.WriteFile(filepath.Join(vaultDir, "Garden.md"), []byte("Tomatoes"), 0644)
+	os.MkdirAll(filepath.Join(vaultDir, "_pluie"), 0755)
+	os.WriteFile(filepath.Join(vaultDir, "_pluie", "404.md"), []byte("Lost? Try the [[Garden]]."), 0644)
+
+	cfg := testStaticConfig(vaultDir, outputDir)
+	notesMap, tree, tagIndex, err := loadNotes(vaultDir, cfg)
+	if err != nil {
+		t.Fatalf("loadNotes error: %v", err)
+	}
+	if err := generateStaticSite(engine.NewNotesService(notesMap, tree, tagIndex), cfg); err != nil {
+		t.Fatalf("generateStaticSite error: %v", err)
+	}
+
+	page, err := os.ReadFile(filepath.Join(outputDir, "404.html"))
+	if err != nil {
+		t.Fatalf("expected 404.html: %v", err)
+	}
+	if !strings.Contains(string(page), "Lost? Try the") || !strings.Contains(string(page), `href="/garden"`) {
+		t.Errorf("404.html should render _pluie/404.md, got %s", page)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "_pluie")); !os.IsNotExist(err) {
+		t.Errorf("system notes should not get pages, stat error: %v", err)
+	}
 }
@@ -0,0 +1,44 @@
+package template
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/EwenQuim/pluie/engine"
+)
+
+// attachmentImageRegex matches the images of attachments rendered from markdown, with their size hint fragment
+var attachmentImageRegex = regexp.MustCompile(`<img src="(` + regexp.QuoteMeta(engine.AttachmentsPrefix) + `[^"#]*)(?:#([^"]*))?"`)
+
+// attachmentImageTagRegex matches the whole image tags of attachments rendered from markdown
+var attachmentImageTagRegex = regexp.MustCompile(`<img src="(` + regexp.QuoteMeta(engine.AttachmentsPrefix) + `[^"]*)"[^>]*>`)
+
+// embedAttachmentAudio turns the audio attachments of a rendered note, rendered as images
+// by engine.ParseAttachmentEmbeds, into audio players
+func embedAttachmentAudio(renderedHTML string) string {
+	return attachmentImageTagRegex.ReplaceAllStringFunc(renderedHTML, func(match string) string {
+		src := attachmentImageTagRegex.FindStringSubmatch(match)[1]
+		if before, _, _ := strings.Cut(html.UnescapeString(src), "#"); !engine.IsAudioAttachment(before) {
+			return match
+		}
+		return `<audio controls preload="metadata" src="` + src + `"></audio>`
+	})
+}
+
+// sizeAttachmentImages turns the size hints of the attachment images of a rendered note,
+// see engine.ParseAttachmentEmbeds, into width and height attributes
+func sizeAttachmentImages(renderedHTML string) string {
+	return attachmentImageRegex.ReplaceAllStringFunc(renderedHTML, func(match string) string {
+		parts := attachmentImageRegex.FindStringSubmatch(match)
+		img := `<img src="` + parts[1] + `" loading="lazy"`
+		if width, height, ok := engine.AttachmentSize(parts[2]); ok {
+			img += ` width="` + strconv.Itoa(width) + `"`
+			if height > 0 {
+				img += ` height="` + strconv.Itoa(height) + `"`
+			}
+		}
+		return img
+	})
+}
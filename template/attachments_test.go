@@ -0,0 +1,24 @@
+package template
+
+import "testing"
+
+func TestSizeAttachmentImages(t *testing.T) {
+	tests := []struct {
+		name     string
+		html     string
+		expected string
+	}{
+		{name: "width", html: `<img src="/-/attachments/a.png#300" alt="a">`, expected: `<img src="/-/attachments/a.png" loading="lazy" width="300" alt="a">`},
+		{name: "width and height", html: `<img src="/-/attachments/a.png#300x200" alt="a">`, expected: `<img src="/-/attachments/a.png" loading="lazy" width="300" height="200" alt="a">`},
+		{name: "no hint", html: `<img src="/-/attachments/a.png" alt="a">`, expected: `<img src="/-/attachments/a.png" loading="lazy" alt="a">`},
+		{name: "other images untouched", html: `<img src="https://example.com/a.png#300" alt="a">`, expected: `<img src="https://example.com/a.png#300" alt="a">`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sizeAttachmentImages(tt.html); got != tt.expected {
+				t.Errorf("sizeAttachmentImages() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
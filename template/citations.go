@@ -0,0 +1,84 @@
+package template
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/EwenQuim/pluie/ai"
+	g "github.com/maragudk/gomponents"
+	. "github.com/maragudk/gomponents/html"
+)
+
+// citationRegex matches the [n] citations of the AI answers, see ai.DefaultPromptTemplate
+var citationRegex = regexp.MustCompile(`\[(\d{1,3})\]`)
+
+// citationMaxDigits is the longest number of a citation, see citationRegex
+const citationMaxDigits = 3
+
+// LinkCitations returns an AI answer as HTML, with its [n] citations linking to the nth of
+// sources. Other numbers stay plain text, like the whole answer.
+func LinkCitations(answer string, sources []ai.Source) string {
+	var sb strings.Builder
+	last := 0
+	for _, match := range citationRegex.FindAllStringSubmatchIndex(answer, -1) {
+		number, _ := strconv.Atoi(answer[match[2]:match[3]])
+		if number < 1 || number > len(sources) {
+			continue
+		}
+		source := sources[number-1]
+		_ = g.Text(answer[last:match[0]]).Render(&sb)
+		_ = A(
+			Href(source.URL),
+			Class("citation "+textLinkClass),
+			g.Attr("data-source", strconv.Itoa(number)),
+			Title(source.Label()),
+			g.Textf("[%d]", number),
+		).Render(&sb)
+		last = match[1]
+	}
+	_ = g.Text(answer[last:]).Render(&sb)
+	return sb.String()
+}
+
+// CitationLinker applies LinkCitations to an answer as it is streamed: a chunk ending with what
+// could be the start of a citation, like "[1", is only written with the next one
+type CitationLinker struct {
+	sources []ai.Source
+	pending string
+}
+
+// NewCitationLinker returns a CitationLinker linking the citations to sources
+func NewCitationLinker(sources []ai.Source) *CitationLinker {
+	return &CitationLinker{sources: sources}
+}
+
+// Write returns the HTML of chunk, without a citation it may end with the start of
+func (l *CitationLinker) Write(chunk string) string {
+	text := l.pending + chunk
+	l.pending = ""
+	if i := strings.LastIndexByte(text, '['); i >= 0 && isCitationStart(text[i+1:]) {
+		text, l.pending = text[:i], text[i:]
+	}
+	return LinkCitations(text, l.sources)
+}
+
+// Flush returns the HTML of the end of the answer kept by Write
+func (l *CitationLinker) Flush() string {
+	text := l.pending
+	l.pending = ""
+	return LinkCitations(text, l.sources)
+}
+
+// isCitationStart reports whether the text after a "[" could still become a citation
+func isCitationStart(text string) bool {
+	if len(text) > citationMaxDigits {
+		return false
+	}
+	for _, c := range text {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
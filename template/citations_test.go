@@ -0,0 +1,73 @@
+package template
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/EwenQuim/pluie/ai"
+)
+
+var citationSources = []ai.Source{
+	{Slug: "garden", Title: "Garden", URL: "/garden"},
+	{Slug: "garden", Title: "Garden", Heading: "Tomatoes", URL: "/garden#tomatoes"},
+}
+
+func TestLinkCitations(t *testing.T) {
+	tests := []struct {
+		name, answer, expected string
+	}{
+		{
+			name:     "citations",
+			answer:   "Water daily [2], in the garden [1].",
+			expected: `Water daily <a href="/garden#tomatoes" class="citation ` + textLinkClass + `" data-source="2" title="Garden &gt; Tomatoes">[2]</a>, in the garden <a href="/garden" class="citation ` + textLinkClass + `" data-source="1" title="Garden">[1]</a>.`,
+		},
+		{
+			name:     "out of range",
+			answer:   "See [0], [3] and [1234].",
+			expected: "See [0], [3] and [1234].",
+		},
+		{
+			name:     "not citations",
+			answer:   "A [link](url), [1 ] and [x].",
+			expected: "A [link](url), [1 ] and [x].",
+		},
+		{
+			name:     "escaped text",
+			answer:   "<script>alert(1)</script> & co",
+			expected: "&lt;script&gt;alert(1)&lt;/script&gt; &amp; co",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := LinkCitations(tt.answer, citationSources); got != tt.expected {
+				t.Errorf("LinkCitations(%q) =\n%s\nwant\n%s", tt.answer, got, tt.expected)
+			}
+		})
+	}
+
+	if got := LinkCitations("Cited [1]", nil); got != "Cited [1]" {
+		t.Errorf("LinkCitations() without sources = %q, want plain text", got)
+	}
+}
+
+func TestCitationLinker(t *testing.T) {
+	linker := NewCitationLinker(citationSources)
+	var chunks []string
+	for _, chunk := range []string{"Water [", "2", "] daily", ", see [1", "0] and [", "1]", " [2"} {
+		chunks = append(chunks, linker.Write(chunk))
+	}
+	chunks = append(chunks, linker.Flush())
+
+	// Chunks are written whole once their citations are complete
+	if chunks[0] != "Water " || chunks[1] != "" {
+		t.Errorf("chunks = %q, want the start of the citation kept", chunks)
+	}
+	got := strings.Join(chunks, "")
+	if want := LinkCitations("Water [2] daily, see [10] and [1] [2", citationSources); got != want {
+		t.Errorf("streamed answer =\n%s\nwant\n%s", got, want)
+	}
+	if strings.Count(got, `class="citation`) != 2 || !strings.HasSuffix(got, " [2") {
+		t.Errorf("streamed answer = %s, want 2 citations and the unfinished one as text", got)
+	}
+}
@@ -0,0 +1,179 @@
+package template
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/EwenQuim/pluie/engine"
+	"github.com/EwenQuim/pluie/model"
+	g "github.com/maragudk/gomponents"
+	. "github.com/maragudk/gomponents/html"
+)
+
+// WithComments returns the resource showing the comments of the notes, by slug, read-only.
+// Static sites have no server to load them from.
+func (rs Resource) WithComments(comments map[string][]model.Comment) Resource {
+	rs.comments = comments
+	return rs
+}
+
+// renderCommentsSlot renders the place of the comments of a public note with COMMENTS_ENABLED.
+// The server loads them after the page, from /-/comments/, so cached pages show the new ones.
+// Static sites get them at generation time, without the form.
+func (rs Resource) renderCommentsSlot(note *model.Note) g.Node {
+	if note == nil || !rs.cfg.CommentsEnabled || !engine.IsVisible(*note, rs.cfg.PublicByDefault) {
+		return nil
+	}
+	if rs.cfg.Mode == "static" || rs.cfg.Mode == "export" {
+		if len(rs.comments[note.Slug]) == 0 {
+			return nil
+		}
+		return rs.Comments(*note, rs.comments[note.Slug], true, "")
+	}
+	return Div(
+		ID("comments"),
+		g.Attr("hx-get", "/-/comments/"+note.Slug),
+		g.Attr("hx-trigger", "load"),
+		g.Attr("hx-swap", "outerHTML"),
+	)
+}
+
+// Comments renders the comments of a note, oldest first, then the form to post one unless
+// readOnly. message is shown above the form, like why the last comment was refused.
+func (rs Resource) Comments(note model.Note, comments []model.Comment, readOnly bool, message string) g.Node {
+	return Section(
+		ID("comments"),
+		Class("mt-8 pt-6 border-t border-gray-200 dark:border-gray-700"),
+		H3(
+			Class("text-lg font-semibold mb-3 text-gray-700 dark:text-gray-300"),
+			g.Text("Comments"),
+			g.If(len(comments) > 0, Span(Class("ml-2 text-sm font-normal text-gray-500 dark:text-gray-400"), g.Textf("%d", len(comments)))),
+		),
+		g.If(len(comments) == 0 && !readOnly,
+			P(Class("mb-4 text-sm text-gray-500 dark:text-gray-400"), g.Text("No comments yet.")),
+		),
+		Ol(
+			Class("space-y-4 mb-6"),
+			g.Group(g.Map(comments, func(comment model.Comment) g.Node {
+				return Li(rs.renderComment(comment))
+			})),
+		),
+		g.If(!readOnly, renderCommentForm(note.Slug, message)),
+	)
+}
+
+// renderComment renders a comment as plain text, its line breaks kept
+func (rs Resource) renderComment(comment model.Comment) g.Node {
+	return Article(
+		Class("comment"),
+		P(
+			Class("text-sm text-gray-500 dark:text-gray-400"),
+			Span(Class("font-semibold text-gray-800 dark:text-gray-200"), g.Text(comment.Name)),
+			g.Text(" on "),
+			Time(DateTime(engine.FormatRFC3339(comment.CreatedAt, rs.cfg.Location())), g.Text(engine.FormatDate(comment.CreatedAt, rs.cfg.Location()))),
+		),
+		P(Class("mt-1 whitespace-pre-line text-gray-800 dark:text-gray-200"), g.Text(comment.Text)),
+	)
+}
+
+// renderCommentForm renders the form posting a comment, replacing the comments section with
+// htmx. The website field is a honeypot, hidden from readers.
+func renderCommentForm(slug, message string) g.Node {
+	action := "/-/comments/" + slug
+	return Form(
+		ID("comment-form"),
+		Method("post"),
+		Action(action),
+		g.Attr("hx-post", action),
+		g.Attr("hx-target", "#comments"),
+		g.Attr("hx-swap", "outerHTML"),
+		Class("space-y-3"),
+		g.If(message != "", P(Class("text-sm text-red-600 dark:text-red-400"), Role("alert"), g.Text(message))),
+		Label(
+			Class("block text-sm text-gray-700 dark:text-gray-300"),
+			g.Text("Name"),
+			Input(Type("text"), Name("name"), MaxLength("80"), Placeholder("Anonymous"), AutoComplete("name"), Class(commentInputClass)),
+		),
+		Label(
+			Class("block text-sm text-gray-700 dark:text-gray-300"),
+			g.Text("Comment"),
+			Textarea(Name("text"), Required(), MaxLength("2000"), Rows("4"), Class(commentInputClass)),
+		),
+		Div(
+			Class("hidden"),
+			g.Attr("aria-hidden", "true"),
+			Label(g.Text("Website"), Input(Type("text"), Name("website"), TabIndex("-1"), AutoComplete("off"))),
+		),
+		Button(Type("submit"), Class(secondaryButtonClass), g.Text("Post comment")),
+	)
+}
+
+const commentInputClass = "mt-1 block w-full rounded-md border border-gray-300 px-3 py-2 text-gray-900 dark:border-gray-600 dark:bg-gray-800 dark:text-gray-100"
+
+// CommentsAdmin renders every comment, grouped by note, newest note activity first, each with a
+// button deleting it
+func (rs Resource) CommentsAdmin(notesService *engine.NotesService, all map[string][]model.Comment) (g.Node, error) {
+	slugs := make([]string, 0, len(all))
+	for slug := range all {
+		slugs = append(slugs, slug)
+	}
+	latest := func(slug string) time.Time { return all[slug][len(all[slug])-1].CreatedAt }
+	slices.SortFunc(slugs, func(a, b string) int {
+		if c := latest(b).Compare(latest(a)); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	})
+
+	mainContent := Div(
+		Class("flex-1 container overflow-y-auto p-4 md:px-8"),
+		H1(
+			Class("text-3xl md:text-4xl font-bold mb-4 mt-2"),
+			g.Text("Comments"),
+		),
+		g.If(len(slugs) == 0,
+			P(Class("text-gray-600 dark:text-gray-400"), g.Text("No comments yet.")),
+		),
+		g.Group(g.Map(slugs, func(slug string) g.Node {
+			title := slug
+			if note, ok := notesService.GetNote(slug); ok {
+				title = note.Title
+			}
+			return Section(
+				Class("comments-group mb-6"),
+				H2(
+					Class("text-lg font-semibold mb-2"),
+					A(Href("/"+slug), Class(textLinkClass), g.Text(title)),
+				),
+				Ul(
+					Class("space-y-3"),
+					g.Group(g.Map(all[slug], func(comment model.Comment) g.Node {
+						return Li(
+							Class("flex items-start justify-between gap-4"),
+							rs.renderComment(comment),
+							Button(
+								Type("button"),
+								Class(secondaryButtonClass),
+								g.Attr("hx-delete", "/-/comments/"+slug+"?"+url.Values{"id": {comment.ID}}.Encode()),
+								g.Attr("hx-confirm", fmt.Sprintf("Delete the comment of %s?", comment.Name)),
+								g.Attr("hx-target", "closest li"),
+								g.Attr("hx-swap", "outerHTML"),
+								g.Text("Delete"),
+							),
+						)
+					})),
+				),
+			)
+		})),
+	)
+
+	return rs.Layout(
+		nil,
+		rs.renderWithNavbar(notesService, navbarConfig{
+			mainContent: mainContent,
+		}),
+	), nil
+}
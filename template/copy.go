@@ -0,0 +1,74 @@
+package template
+
+import (
+	"fmt"
+
+	"github.com/EwenQuim/pluie/engine"
+	"github.com/EwenQuim/pluie/model"
+	g "github.com/maragudk/gomponents"
+	. "github.com/maragudk/gomponents/html"
+)
+
+// headingAnchorIcon is the link icon of the heading anchors, without text so that the text of
+// the headings stays their title
+const headingAnchorIcon = `<svg class="w-4 h-4 inline" fill="none" stroke="currentColor" viewBox="0 0 24 24" aria-hidden="true"><path stroke-linecap="round" stroke-linejoin="round" stroke-width="2" d="M13.828 10.172a4 4 0 00-5.656 0l-4 4a4 4 0 105.656 5.656l1.102-1.101m-.758-4.899a4 4 0 005.656 0l4-4a4 4 0 00-5.656-5.656l-1.1 1.1"/></svg>`
+
+// addHeadingAnchors adds to the headings of a rendered note a link to their section, copied to
+// the clipboard when clicked. The ids are the ones of setHeadingIDs, like the table of contents.
+func addHeadingAnchors(renderedHTML string) string {
+	return identifiedHeadingRegex.ReplaceAllStringFunc(renderedHTML, func(match string) string {
+		parts := identifiedHeadingRegex.FindStringSubmatch(match)
+		return fmt.Sprintf(`<h%s id="%s">%s<a class="heading-anchor" href="#%s" title="Copy link to this section" aria-label="Copy link to this section" onclick="copyHeadingLink(event, this)">%s</a></h%s>`,
+			parts[1], parts[2], parts[3], parts[2], headingAnchorIcon, parts[1])
+	})
+}
+
+// renderNoteToolbar renders the buttons copying the link of the note and its markdown, next to
+// the print link. Static sites have no raw endpoint: the markdown is in a template of the page.
+func (rs Resource) renderNoteToolbar(note *model.Note) g.Node {
+	static := rs.cfg.Mode == "static" || rs.cfg.Mode == "export"
+	markdownSource := "/-/raw/" + note.Slug
+	if static {
+		markdownSource = "#note-markdown"
+	}
+
+	return Div(
+		ID("note-toolbar"),
+		Class("float-right mt-4 ml-4 flex items-center gap-3 text-sm"),
+		Button(
+			Type("button"),
+			Class(textLinkClass),
+			Title("Copy the link of this note"),
+			g.Attr("onclick", "copyNoteLink(this)"),
+			g.Text("Copy link"),
+		),
+		Button(
+			Type("button"),
+			Class(textLinkClass),
+			Title("Copy the markdown of this note"),
+			g.Attr("onclick", "copyNoteMarkdown(this)"),
+			g.Attr("data-source", markdownSource),
+			g.Text("Copy as Markdown"),
+		),
+		g.If(static, g.El("template", ID("note-markdown"), g.Text(note.Content))),
+		A(
+			ID("print-link"),
+			Href(rs.printURL(note.Slug)),
+			Rel("nofollow"),
+			Class(textLinkClass),
+			Title("Print or save as PDF"),
+			g.Text("Print"),
+		),
+		// Notes of a git vault, the server hides the history of private notes
+		g.If(!static && note.LastCommitHash != "" && engine.IsVisible(*note, rs.cfg.PublicByDefault),
+			A(
+				ID("history-link"),
+				Href("/-/history/"+note.Slug),
+				Rel("nofollow"),
+				Class(textLinkClass),
+				Title("Past versions of this note"),
+				g.Text("History"),
+			),
+		),
+	)
+}
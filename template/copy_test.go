@@ -0,0 +1,70 @@
+package template
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/EwenQuim/pluie/config"
+	"github.com/EwenQuim/pluie/engine"
+	"github.com/EwenQuim/pluie/model"
+	"github.com/go-fuego/fuego/extra/markdown"
+)
+
+func TestAddHeadingAnchors(t *testing.T) {
+	content := "## Setup\n\nText\n\n## Setup\n\n### *Usage* notes"
+	rendered := setHeadingIDs(string(markdown.Markdown(content)))
+	html := addHeadingAnchors(rendered)
+
+	// Same ids as the table of contents, duplicates included
+	for _, item := range extractHeadings(rendered) {
+		if !strings.Contains(html, `id="`+item.ID+`"`) || !strings.Contains(html, `href="#`+item.ID+`"`) {
+			t.Errorf("heading %q should link to #%s, got %s", item.Text, item.ID, html)
+		}
+	}
+	if strings.Count(html, `class="heading-anchor"`) != 3 {
+		t.Errorf("every heading should have an anchor, got %s", html)
+	}
+	if !strings.Contains(html, `<h3 id="usage-notes"><em>Usage</em> notes<a class="heading-anchor"`) {
+		t.Errorf("the anchor should follow the text of the heading, got %s", html)
+	}
+	if html := addHeadingAnchors("<p>No heading</p>"); html != "<p>No heading</p>" {
+		t.Errorf("addHeadingAnchors() = %q, want the html untouched", html)
+	}
+}
+
+func TestNoteWithList_CopyButtons(t *testing.T) {
+	note := model.Note{Title: "Guide", Slug: "guide", Path: "Guide.md", Content: "## Install\n\nRun <it>.\n\n## Install"}
+	notesMap := map[string]model.Note{"guide": note}
+	notesService := engine.NewNotesService(&notesMap, engine.BuildTreeWithFolders([]model.Note{note}, nil), nil)
+
+	render := func(rs Resource) string {
+		result, err := rs.NoteWithList(notesService, &note, "")
+		if err != nil {
+			t.Fatalf("NoteWithList() returned error: %v", err)
+		}
+		var sb strings.Builder
+		if err := result.Render(&sb); err != nil {
+			t.Fatalf("Render() returned error: %v", err)
+		}
+		return sb.String()
+	}
+
+	page := render(testResource())
+	if !strings.Contains(page, `href="#install"`) || !strings.Contains(page, `href="#install-2"`) {
+		t.Error("the heading anchors should link to the ids of the table of contents")
+	}
+	if !strings.Contains(page, `onclick="copyNoteLink(this)"`) || !strings.Contains(page, `data-source="/-/raw/guide"`) {
+		t.Error("the note toolbar should copy the link and the raw markdown of the note")
+	}
+	if strings.Contains(page, `id="note-markdown"`) {
+		t.Error("servers should not embed the markdown in the page")
+	}
+
+	static := render(NewResource(&config.Config{SiteTitle: "Pluie", Mode: "static"}))
+	if !strings.Contains(static, `data-source="#note-markdown"`) || !strings.Contains(static, `<template id="note-markdown">## Install`) {
+		t.Error("static sites should copy the markdown from a template of the page")
+	}
+	if !strings.Contains(static, "Run &lt;it&gt;.") {
+		t.Error("the markdown of the template should be escaped")
+	}
+}
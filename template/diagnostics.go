@@ -0,0 +1,153 @@
+package template
+
+import (
+	"fmt"
+
+	"github.com/EwenQuim/pluie/engine"
+	"github.com/EwenQuim/pluie/model"
+	g "github.com/maragudk/gomponents"
+	. "github.com/maragudk/gomponents/html"
+)
+
+// diagnosticKindTitles are the section titles of the diagnostics page, in display order
+var diagnosticKindTitles = []struct {
+	kind  string
+	title string
+}{
+	{kind: engine.DiagnosticDuplicate, title: "Near-duplicate notes"},
+	{kind: engine.DiagnosticStatus, title: "Unknown statuses"},
+	{kind: engine.DiagnosticOrder, title: "Duplicate order values"},
+}
+
+// Diagnostics renders the vault health page listing every diagnostic by kind, then the recently deleted notes
+func (rs Resource) Diagnostics(notesService *engine.NotesService, diagnostics []engine.Diagnostic, trashed []model.TrashedNote) (g.Node, error) {
+	byKind := make(map[string][]engine.Diagnostic)
+	for _, diagnostic := range diagnostics {
+		byKind[diagnostic.Kind] = append(byKind[diagnostic.Kind], diagnostic)
+	}
+
+	var content g.Node
+	if len(diagnostics) == 0 {
+		content = P(
+			Class("text-gray-600 dark:text-gray-400"),
+			g.Text("No problems found in your notes."),
+		)
+	} else {
+		var sections []g.Node
+		for _, kind := range diagnosticKindTitles {
+			if len(byKind[kind.kind]) == 0 {
+				continue
+			}
+			sections = append(sections, renderDiagnosticSection(kind.title, byKind[kind.kind]))
+		}
+		content = g.Group(sections)
+	}
+
+	mainContent := Div(
+		Class("flex-1 container overflow-y-auto p-4 md:px-8"),
+		H1(
+			Class("text-3xl md:text-4xl font-bold mb-4 mt-2"),
+			g.Text("Diagnostics"),
+		),
+		content,
+		g.If(len(trashed) > 0, rs.renderTrashSection(trashed)),
+	)
+
+	return rs.Layout(
+		nil,
+		rs.renderWithNavbar(notesService, navbarConfig{
+			mainContent: mainContent,
+		}),
+	), nil
+}
+
+// renderDiagnosticSection renders the diagnostics of one kind as a list of warnings
+func renderDiagnosticSection(title string, diagnostics []engine.Diagnostic) g.Node {
+	return Section(
+		Class("mb-8"),
+		H2(
+			Class("text-xl font-semibold mb-3"),
+			g.Textf("%s (%d)", title, len(diagnostics)),
+		),
+		Ul(
+			Class("space-y-2"),
+			g.Group(g.Map(diagnostics, func(diagnostic engine.Diagnostic) g.Node {
+				return Li(
+					Class("bg-amber-50 border border-amber-200 rounded-lg px-4 py-3 dark:bg-amber-950 dark:border-amber-800"),
+					P(
+						Class("text-sm font-medium text-amber-900 dark:text-amber-100"),
+						g.Text(diagnostic.Message),
+					),
+					Ul(
+						Class("mt-1 text-sm font-mono"),
+						g.Group(g.Map(indexes(diagnostic.Paths), func(i int) g.Node {
+							if i < len(diagnostic.Slugs) && diagnostic.Slugs[i] != "" {
+								return Li(A(
+									Href("/"+diagnostic.Slugs[i]),
+									Class("text-blue-700 hover:underline dark:text-blue-300"),
+									g.Text(diagnostic.Paths[i]),
+								))
+							}
+							return Li(g.Text(diagnostic.Paths[i]))
+						})),
+					),
+				)
+			})),
+		),
+	)
+}
+
+// renderTrashSection lists the deleted notes still readable at their URL, with a button copying their markdown
+func (rs Resource) renderTrashSection(trashed []model.TrashedNote) g.Node {
+	loc := rs.cfg.Location()
+	return Section(
+		Class("mb-8"),
+		ID("trash"),
+		H2(
+			Class("text-xl font-semibold mb-3"),
+			g.Textf("Recently deleted (%d)", len(trashed)),
+		),
+		Ul(
+			Class("space-y-2"),
+			g.Group(g.Map(indexes(trashed), func(i int) g.Node {
+				entry := trashed[i]
+				contentID := fmt.Sprintf("trash-content-%d", i)
+				return Li(
+					Class("flex items-center justify-between gap-4 bg-gray-50 border border-gray-200 rounded-lg px-4 py-3 dark:bg-gray-800 dark:border-gray-700"),
+					Div(
+						A(
+							Href("/"+entry.Note.Slug),
+							Class("text-sm font-medium text-blue-700 hover:underline dark:text-blue-300"),
+							g.Text(entry.Note.Title),
+						),
+						P(
+							Class("text-xs text-gray-500 font-mono dark:text-gray-400"),
+							g.Textf("%s, deleted on %s, purged on %s", entry.Note.Path, engine.FormatDate(entry.DeletedAt, loc), engine.FormatDate(entry.ExpiresAt, loc)),
+						),
+					),
+					Textarea(
+						ID(contentID),
+						Class("hidden"),
+						g.Attr("readonly"),
+						g.Text(entry.Note.Content),
+					),
+					Button(
+						Type("button"),
+						Class("flex-none text-sm text-gray-700 bg-white border border-gray-300 hover:bg-gray-100 rounded px-3 py-1 dark:text-gray-300 dark:bg-gray-900 dark:border-gray-600 dark:hover:bg-gray-800"),
+						g.Attr("onclick", fmt.Sprintf("copyElementText('%s', this)", contentID)),
+						g.Text("Copy markdown"),
+					),
+				)
+			})),
+		),
+	)
+}
+
+// indexes returns the indexes of a slice, to map over two parallel slices
+func indexes[T any](items []T) []int {
+	result := make([]int, len(items))
+	for i := range items {
+		result[i] = i
+	}
+	return result
+}
@@ -0,0 +1,49 @@
+package template
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/EwenQuim/pluie/engine"
+	"github.com/EwenQuim/pluie/model"
+)
+
+func TestDiagnostics(t *testing.T) {
+	notesMap := make(map[string]model.Note)
+	notesService := engine.NewNotesService(&notesMap, &engine.TreeNode{Name: "root", IsFolder: true}, nil)
+
+	render := func(diagnostics []engine.Diagnostic) string {
+		result, err := testResource().Diagnostics(notesService, diagnostics, nil)
+		if err != nil {
+			t.Fatalf("Diagnostics() returned error: %v", err)
+		}
+		var sb strings.Builder
+		if err := result.Render(&sb); err != nil {
+			t.Fatalf("Render() returned error: %v", err)
+		}
+		return sb.String()
+	}
+
+	if html := render(nil); !strings.Contains(html, "No problems found") {
+		t.Error("expected the empty state without diagnostics")
+	}
+
+	html := render([]engine.Diagnostic{{
+		Kind:    engine.DiagnosticDuplicate,
+		Message: "Near-duplicate notes (97% similar)",
+		Paths:   []string{"Meeting notes.md", "Meeting notes 1.md"},
+		Slugs:   []string{"meeting-notes", "meeting-notes-1"},
+	}})
+
+	for _, expected := range []string{
+		"Near-duplicate notes (1)",
+		"Near-duplicate notes (97% similar)",
+		`href="/meeting-notes"`,
+		`href="/meeting-notes-1"`,
+		"Meeting notes 1.md",
+	} {
+		if !strings.Contains(html, expected) {
+			t.Errorf("expected diagnostics page to contain %q", expected)
+		}
+	}
+}
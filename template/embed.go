@@ -0,0 +1,84 @@
+package template
+
+import (
+	"regexp"
+
+	"github.com/EwenQuim/pluie/engine"
+	"github.com/EwenQuim/pluie/model"
+	"github.com/EwenQuim/pluie/static"
+	g "github.com/maragudk/gomponents"
+	. "github.com/maragudk/gomponents/html"
+)
+
+// NoteEmbed renders a note without any chrome, to be framed by another website.
+// The note content may already be scoped to one section: heading is then its anchor, used to link back to it.
+// A nil note renders a not found message. No script is loaded.
+func (rs Resource) NoteEmbed(notesService *engine.NotesService, note *model.Note, heading, linkTarget string) (g.Node, error) {
+	title := "Not found"
+	content := "This note does not exist or is private."
+	fullNoteURL := "/"
+	slug := ""
+	var attachments map[string]string
+	if note != nil {
+		title = note.Title
+		content = note.Content
+		slug = note.Slug
+		attachments = note.Attachments
+		fullNoteURL = "/" + slug
+		if heading != "" {
+			fullNoteURL += "#" + engine.SlugifyHeading(heading)
+		}
+	}
+
+	html := renderNoteHTML(notesService, prepareNoteContent(notesService, content, attachments), slug)
+
+	return HTML(
+		Lang("en"),
+		Head(
+			Meta(Charset("utf-8")),
+			Meta(Name("viewport"), Content("width=device-width, initial-scale=1")),
+			Meta(Name("robots"), Content("noindex")),
+			TitleEl(g.Textf("%s - %s", title, rs.cfg.SiteTitle)),
+			Link(Rel("stylesheet"), Type("text/css"), Href(static.URL("tailwind.min.css"))),
+		),
+		Body(
+			Class("bg-white text-gray-900"),
+			Article(
+				Class("p-4"),
+				// A section embed starts with its own heading
+				g.If(note == nil || heading == "",
+					H1(
+						Class("text-xl font-bold mb-2"),
+						g.Text(title),
+					),
+				),
+				Div(
+					Class("prose prose-sm max-w-none"),
+					g.Raw(setLinkTargets(html, linkTarget)),
+				),
+			),
+			Footer(
+				Class("px-4 pb-3 text-xs text-gray-500"),
+				A(
+					Href(fullNoteURL),
+					Target(linkTarget),
+					g.If(linkTarget == "_blank", Rel("noopener")),
+					Class("hover:underline"),
+					g.Textf("via %s", rs.cfg.SiteTitle),
+				),
+			),
+		),
+	), nil
+}
+
+// linkRegex matches the opening of rendered links, except anchors within the page
+var linkRegex = regexp.MustCompile(`<a href="([^"#][^"]*)"`)
+
+// setLinkTargets makes the links of rendered markdown open outside the frame
+func setLinkTargets(html, target string) string {
+	attributes := ` target="` + target + `"`
+	if target == "_blank" {
+		attributes += ` rel="noopener"`
+	}
+	return linkRegex.ReplaceAllString(html, `<a href="$1"`+attributes)
+}
@@ -0,0 +1,83 @@
+package template
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/EwenQuim/pluie/engine"
+	"github.com/EwenQuim/pluie/model"
+)
+
+func TestSetLinkTargets(t *testing.T) {
+	tests := []struct {
+		name     string
+		html     string
+		target   string
+		expected string
+	}{
+		{
+			name:     "note link opens in the parent page",
+			html:     `<p><a href="/other">Other</a></p>`,
+			target:   "_top",
+			expected: `<p><a href="/other" target="_top">Other</a></p>`,
+		},
+		{
+			name:     "new tab without opener",
+			html:     `<a href="https://example.com" title="Example">x</a>`,
+			target:   "_blank",
+			expected: `<a href="https://example.com" target="_blank" rel="noopener" title="Example">x</a>`,
+		},
+		{
+			name:     "anchors stay in the frame",
+			html:     `<a href="#fn:1">1</a>`,
+			target:   "_top",
+			expected: `<a href="#fn:1">1</a>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := setLinkTargets(tt.html, tt.target); got != tt.expected {
+				t.Errorf("setLinkTargets() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNoteEmbed(t *testing.T) {
+	notes := []model.Note{{Title: "Lesson", Slug: "lesson", Content: "See [[Other]]."}, {Title: "Other", Slug: "other"}}
+	notesMap := map[string]model.Note{"lesson": notes[0], "other": notes[1]}
+	notesService := engine.NewNotesService(&notesMap, engine.BuildTree(notes), nil)
+
+	render := func(note *model.Note, heading string) string {
+		result, err := testResource().NoteEmbed(notesService, note, heading, "_top")
+		if err != nil {
+			t.Fatalf("NoteEmbed() returned error: %v", err)
+		}
+		var sb strings.Builder
+		if err := result.Render(&sb); err != nil {
+			t.Fatalf("Render() returned error: %v", err)
+		}
+		return sb.String()
+	}
+
+	html := render(&notes[0], "")
+	for _, expected := range []string{"<h1", "Lesson", `href="/other" target="_top"`, `href="/lesson"`, "via Pluie"} {
+		if !strings.Contains(html, expected) {
+			t.Errorf("expected %q in the embed", expected)
+		}
+	}
+	if strings.Contains(html, "<script") || strings.Contains(html, "<nav") {
+		t.Error("embed should not contain scripts nor navigation")
+	}
+
+	section := notes[0]
+	section.Content = "## Getting started\n\nInstall it."
+	if html := render(&section, "getting-started"); strings.Contains(html, "<h1") || !strings.Contains(html, `href="/lesson#getting-started"`) {
+		t.Error("section embed should start with its heading and link back to it")
+	}
+
+	if html := render(nil, ""); !strings.Contains(html, "does not exist or is private") {
+		t.Error("expected the not found message")
+	}
+}
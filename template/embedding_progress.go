@@ -9,39 +9,67 @@ import (
 
 // EmbeddingProgressData holds the data for rendering embedding progress
 type EmbeddingProgressData struct {
-	Embedded    int
+	Embedded    int // Embedded by the current pass
+	Skipped     int // Already embedded with their content, from the cache
 	Total       int
 	IsEmbedding bool
+	IsPaused    bool
+	Rate        float64 // Notes embedded during the last minute
 }
 
 // RenderEmbeddingProgressContent renders the inner content that gets swapped by SSE
 // This is used both for initial render and SSE updates
 func RenderEmbeddingProgressContent(data EmbeddingProgressData) g.Node {
 	// Calculate percentage
+	done := data.Embedded + data.Skipped
 	percentage := 0
 	if data.Total > 0 {
-		percentage = (data.Embedded * 100) / data.Total
+		percentage = (done * 100) / data.Total
 	}
 
 	// Determine bar color based on status
 	barColor := "bg-purple-600"
-	if !data.IsEmbedding && data.Embedded == data.Total && data.Total > 0 {
+	if data.IsPaused {
+		barColor = "bg-amber-500"
+	} else if !data.IsEmbedding && done == data.Total && data.Total > 0 {
 		barColor = "bg-green-600"
 	}
 
 	return h.Div(
-		h.Class("text-xs text-gray-500 px-2"),
+		h.Class("text-xs text-gray-500 px-2 dark:text-gray-400"),
 		h.Div(
 			h.Class("flex items-center justify-between mb-1"),
-			h.Span(g.Text("Embeddings:")),
+			h.Span(
+				g.Text("Embeddings:"),
+				g.If(data.IsPaused,
+					h.Span(
+						h.ID("embedding-progress-paused"),
+						h.Class("ml-1 text-amber-600 font-medium dark:text-amber-400"),
+						g.Text("paused"),
+					),
+				),
+			),
 			h.Span(
 				h.ID("embedding-progress-text"),
 				h.Class("font-mono"),
-				g.Textf("%d/%d", data.Embedded, data.Total),
+				g.Textf("%d/%d", done, data.Total),
+				g.If(data.IsEmbedding && !data.IsPaused && data.Rate > 0,
+					h.Span(
+						h.Class("ml-1 text-gray-400 dark:text-gray-500"),
+						g.Textf("(%.0f/min)", data.Rate),
+					),
+				),
+			),
+		),
+		g.If(data.Skipped > 0,
+			h.Div(
+				h.ID("embedding-progress-skipped"),
+				h.Class("mb-1 text-gray-400 dark:text-gray-500"),
+				g.Textf("%d embedded, %d skipped (cached)", data.Embedded, data.Skipped),
 			),
 		),
 		h.Div(
-			h.Class("w-full bg-gray-200 rounded-full h-1.5"),
+			h.Class("w-full bg-gray-200 rounded-full h-1.5 dark:bg-gray-700"),
 			h.Div(
 				h.ID("embedding-progress-bar"),
 				h.Class(fmt.Sprintf("%s h-1.5 rounded-full transition-all duration-300", barColor)),
@@ -62,7 +90,7 @@ func RenderEmbeddingProgressIndicator() g.Node {
 	}
 
 	return h.Div(
-		h.Class("mt-auto pt-4 border-t border-gray-200"),
+		h.Class("mt-auto pt-4 border-t border-gray-200 dark:border-gray-700"),
 		g.Attr("hx-ext", "sse"),
 		g.Attr("sse-connect", "/-/embedding-progress"),
 		g.Attr("sse-swap", "message"),
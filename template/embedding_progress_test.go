@@ -0,0 +1,35 @@
+package template
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderEmbeddingProgressContent(t *testing.T) {
+	render := func(data EmbeddingProgressData) string {
+		var sb strings.Builder
+		if err := RenderEmbeddingProgressContent(data).Render(&sb); err != nil {
+			t.Fatalf("Render() returned error: %v", err)
+		}
+		return sb.String()
+	}
+
+	html := render(EmbeddingProgressData{Embedded: 3, Total: 10, IsEmbedding: true, Rate: 12})
+	if !strings.Contains(html, "3/10") || !strings.Contains(html, "(12/min)") || strings.Contains(html, "paused") {
+		t.Errorf("running progress should show the count and the rate, got %s", html)
+	}
+
+	html = render(EmbeddingProgressData{Embedded: 3, Total: 10, IsEmbedding: true, IsPaused: true, Rate: 12})
+	if !strings.Contains(html, ">paused</span>") || !strings.Contains(html, "bg-amber-500") || strings.Contains(html, "/min") {
+		t.Errorf("paused progress should show paused without rate, got %s", html)
+	}
+
+	if html := render(EmbeddingProgressData{Embedded: 10, Total: 10}); !strings.Contains(html, "bg-green-600") {
+		t.Error("completed progress should be green")
+	}
+
+	html = render(EmbeddingProgressData{Embedded: 2, Skipped: 8, Total: 10})
+	if !strings.Contains(html, "10/10") || !strings.Contains(html, "2 embedded, 8 skipped (cached)") || !strings.Contains(html, "bg-green-600") {
+		t.Errorf("notes skipped from the cache should be counted apart, got %s", html)
+	}
+}
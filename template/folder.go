@@ -0,0 +1,68 @@
+package template
+
+import (
+	"github.com/EwenQuim/pluie/engine"
+	"github.com/EwenQuim/pluie/model"
+	g "github.com/maragudk/gomponents"
+	. "github.com/maragudk/gomponents/html"
+)
+
+// FolderIndex displays the subfolders and notes of a folder, in the sidebar order, under
+// the description of its .pluie file. Private notes must already be left out, see
+// engine.FolderContents.
+func (rs Resource) FolderIndex(notesService *engine.NotesService, folder *engine.TreeNode, subfolders []*engine.TreeNode, notes []model.Note, searchQuery string) (g.Node, error) {
+	displayTree := notesService.GetTree()
+	if searchQuery != "" {
+		displayTree = notesService.FilterTreeBySearch(searchQuery)
+	}
+
+	mainContent := Div(
+		Class("flex-1 container overflow-y-auto p-4 md:px-8"),
+		ID("folder-index"),
+		renderBreadcrumbs(engine.FolderBreadcrumbs(folder, notesService.GetTree())),
+		H1(
+			Class("flex items-center text-3xl md:text-4xl font-bold mb-4 mt-2"),
+			renderFolderIcon(folder.Icon),
+			g.Text(folder.Name),
+		),
+		g.If(folder.Description != "",
+			P(
+				Class("text-gray-600 mb-6 dark:text-gray-400"),
+				g.Text(folder.Description),
+			),
+		),
+		g.If(len(subfolders) > 0,
+			Ul(
+				Class("flex flex-wrap gap-2 mb-6"),
+				g.Group(g.Map(subfolders, func(subfolder *engine.TreeNode) g.Node {
+					return Li(
+						A(
+							Href("/"+engine.FolderSlug(subfolder)),
+							Class("flex items-center "+secondaryButtonClass),
+							g.Attr("hx-boost", "true"),
+							renderFolderIcon(subfolder.Icon),
+							g.Text(subfolder.Name),
+						),
+					)
+				})),
+			),
+		),
+		g.If(len(notes) > 0,
+			Div(
+				Class("grid gap-4 md:grid-cols-2 lg:grid-cols-3"),
+				g.Group(g.Map(notes, func(note model.Note) g.Node {
+					return rs.renderNoteCard(note, "")
+				})),
+			),
+		),
+	)
+
+	return rs.Layout(
+		nil,
+		rs.renderWithNavbar(notesService, navbarConfig{
+			searchQuery: searchQuery,
+			displayTree: displayTree,
+			mainContent: mainContent,
+		}),
+	), nil
+}
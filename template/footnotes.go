@@ -0,0 +1,59 @@
+package template
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// footnoteRefRegex matches the footnote markers rendered by the markdown footnotes extension
+var footnoteRefRegex = regexp.MustCompile(`<sup class="footnote-ref" id="fnref:([^"]+)">`)
+
+// footnoteItemRegex matches the opening of the footnote definitions, listed at the bottom of the note
+var footnoteItemRegex = regexp.MustCompile(`<li id="fn:([^"]+)">`)
+
+// footnotesStart opens the footnotes section the markdown renderer appends to the note
+const footnotesStart = `<div class="footnotes">`
+
+// linkFootnotes adds a link back to its first marker at the end of each footnote of a
+// rendered note. Markers of a footnote used several times get unique ids.
+func linkFootnotes(renderedHTML string) string {
+	body, footnotes, found := strings.Cut(renderedHTML, footnotesStart)
+	if !found {
+		return renderedHTML
+	}
+
+	seen := make(map[string]int)
+	body = footnoteRefRegex.ReplaceAllStringFunc(body, func(match string) string {
+		id := footnoteRefRegex.FindStringSubmatch(match)[1]
+		seen[id]++
+		if seen[id] == 1 {
+			return match
+		}
+		return `<sup class="footnote-ref" id="fnref:` + id + `:` + strconv.Itoa(seen[id]) + `">`
+	})
+
+	// Each footnote ends at the last </li> before the next one
+	starts := footnoteItemRegex.FindAllStringSubmatchIndex(footnotes, -1)
+	var sb strings.Builder
+	previous := 0
+	for i, start := range starts {
+		sb.WriteString(footnotes[previous:start[0]])
+		end := len(footnotes)
+		if i+1 < len(starts) {
+			end = starts[i+1][0]
+		}
+		item := footnotes[start[0]:end]
+		id := footnotes[start[2]:start[3]]
+		if closing := strings.LastIndex(item, "</li>"); closing != -1 && seen[id] > 0 {
+			before := strings.TrimSuffix(item[:closing], "</p>")
+			backLink := ` <a href="#fnref:` + id + `" class="footnote-backref" aria-label="Back to the text">↩</a>`
+			item = before + backLink + item[len(before):]
+		}
+		sb.WriteString(item)
+		previous = end
+	}
+	sb.WriteString(footnotes[previous:])
+
+	return body + footnotesStart + sb.String()
+}
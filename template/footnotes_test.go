@@ -0,0 +1,61 @@
+package template
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-fuego/fuego/extra/markdown"
+)
+
+func TestLinkFootnotes(t *testing.T) {
+	tests := []struct {
+		name     string
+		markdown string
+		contains []string
+		excludes []string
+	}{
+		{
+			name:     "back-link",
+			markdown: "Text[^1]\n\n[^1]: First.\n",
+			contains: []string{`<sup class="footnote-ref" id="fnref:1"><a href="#fn:1">1</a></sup>`, `<li id="fn:1">First. <a href="#fnref:1" class="footnote-backref" aria-label="Back to the text">↩</a></li>`},
+		},
+		{
+			name:     "named footnote with paragraphs",
+			markdown: "Text[^long]\n\n[^long]: First line\n    continued\n",
+			contains: []string{`<li id="fn:long"><p>First line` + "\n" + `continued <a href="#fnref:long" class="footnote-backref" aria-label="Back to the text">↩</a></p></li>`},
+		},
+		{
+			name:     "repeated marker gets a unique id",
+			markdown: "One[^1] two[^1]\n\n[^1]: Shared.\n",
+			contains: []string{`id="fnref:1"><a`, `id="fnref:1:2"><a`, `href="#fnref:1" class="footnote-backref"`},
+		},
+		{
+			name:     "undefined marker stays raw",
+			markdown: "Missing[^nope].\n",
+			contains: []string{"Missing[^nope]."},
+			excludes: []string{"footnote"},
+		},
+		{
+			name:     "definition in code block ignored",
+			markdown: "```\n[^1]: in code\n```\n",
+			contains: []string{"[^1]: in code"},
+			excludes: []string{"footnote"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := linkFootnotes(string(markdown.Markdown(tt.markdown)))
+			for _, expected := range tt.contains {
+				if !strings.Contains(got, expected) {
+					t.Errorf("linkFootnotes() = %q, want it to contain %q", got, expected)
+				}
+			}
+			for _, unexpected := range tt.excludes {
+				if strings.Contains(got, unexpected) {
+					t.Errorf("linkFootnotes() = %q, should not contain %q", got, unexpected)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package template
+
+import (
+	"github.com/EwenQuim/pluie/engine"
+	"github.com/EwenQuim/pluie/static"
+	g "github.com/maragudk/gomponents"
+	. "github.com/maragudk/gomponents/html"
+)
+
+// Graph renders the /-/graph page: the notes and their wikilinks, drawn by static/graph.js
+// from /-/graph.json
+func (rs Resource) Graph(notesService *engine.NotesService) (g.Node, error) {
+	mainContent := Div(
+		Class("flex-1 flex flex-col overflow-hidden p-4 md:px-8"),
+		H1(
+			Class("text-3xl md:text-4xl font-bold mb-2 mt-2"),
+			g.Text("Graph"),
+		),
+		P(
+			Class("text-sm text-gray-500 mb-4 dark:text-gray-400"),
+			g.Text("Drag to move around, scroll to zoom, click a note to open it."),
+		),
+		Div(
+			ID("graph-container"),
+			Class("relative flex-1 min-h-96 border border-gray-200 rounded-lg bg-gray-50 overflow-hidden dark:border-gray-700 dark:bg-gray-800"),
+			Canvas(
+				ID("graph-canvas"),
+				Class("absolute inset-0 w-full h-full cursor-grab"),
+				g.Attr("data-src", "/-/graph.json"),
+				g.Attr("aria-label", "Graph of the notes and their links"),
+			),
+			P(
+				ID("graph-status"),
+				Class("absolute inset-0 flex items-center justify-center text-gray-500 dark:text-gray-400"),
+				g.Text("Loading graph…"),
+			),
+		),
+		Script(Src(static.URL("graph.js"))),
+	)
+
+	return rs.Layout(
+		nil,
+		rs.renderWithNavbar(notesService, navbarConfig{
+			mainContent: mainContent,
+		}),
+	), nil
+}
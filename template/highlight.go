@@ -0,0 +1,77 @@
+package template
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	g "github.com/maragudk/gomponents"
+	. "github.com/maragudk/gomponents/html"
+)
+
+// HighlightMatches returns text with the occurrences of each word of query in a mark, ignoring
+// case. Overlapping occurrences share a mark, and text without any is returned as is.
+func HighlightMatches(text, query string) g.Node {
+	ranges := matchRanges(text, strings.Fields(query))
+	if len(ranges) == 0 {
+		return g.Text(text)
+	}
+
+	nodes := make([]g.Node, 0, 2*len(ranges)+1)
+	last := 0
+	for _, r := range ranges {
+		if r[0] > last {
+			nodes = append(nodes, g.Text(text[last:r[0]]))
+		}
+		nodes = append(nodes, Mark(Class(highlightClass), g.Text(text[r[0]:r[1]])))
+		last = r[1]
+	}
+	if last < len(text) {
+		nodes = append(nodes, g.Text(text[last:]))
+	}
+	return g.Group(nodes)
+}
+
+// matchRanges returns the byte ranges of text matching one of terms, ignoring case, sorted and
+// merged when they overlap or touch
+func matchRanges(text string, terms []string) [][2]int {
+	var ranges [][2]int
+	for i := 0; i < len(text); {
+		for _, term := range terms {
+			if n := foldPrefixLen(text[i:], term); n > 0 {
+				ranges = append(ranges, [2]int{i, i + n})
+			}
+		}
+		_, size := utf8.DecodeRuneInString(text[i:])
+		i += size
+	}
+
+	slices.SortFunc(ranges, func(a, b [2]int) int { return a[0] - b[0] })
+	var merged [][2]int
+	for _, r := range ranges {
+		if n := len(merged); n > 0 && r[0] <= merged[n-1][1] {
+			merged[n-1][1] = max(merged[n-1][1], r[1])
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// foldPrefixLen returns the length in bytes of the prefix of s equal to term ignoring case, 0
+// when s doesn't start with term
+func foldPrefixLen(s, term string) int {
+	if term == "" {
+		return 0
+	}
+	n := 0
+	for _, want := range term {
+		got, size := utf8.DecodeRuneInString(s[n:])
+		if size == 0 || (got != want && unicode.ToLower(got) != unicode.ToLower(want)) {
+			return 0
+		}
+		n += size
+	}
+	return n
+}
@@ -0,0 +1,103 @@
+package template
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/EwenQuim/pluie/config"
+	"github.com/EwenQuim/pluie/engine"
+	"github.com/EwenQuim/pluie/model"
+)
+
+func TestHighlightMatches(t *testing.T) {
+	mark := func(text string) string { return `<mark class="` + highlightClass + `">` + text + "</mark>" }
+	tests := []struct {
+		name, text, query, expected string
+	}{
+		{
+			name:     "every word, ignoring case",
+			text:     "Tomato Garden and garden tools",
+			query:    "garden TOMATO",
+			expected: mark("Tomato") + " " + mark("Garden") + " and " + mark("garden") + " tools",
+		},
+		{
+			name:     "no match",
+			text:     "Bread",
+			query:    "garden",
+			expected: "Bread",
+		},
+		{
+			name:     "empty query",
+			text:     "Garden",
+			query:    "  ",
+			expected: "Garden",
+		},
+		{
+			name:     "overlapping words share a mark",
+			text:     "Gardening",
+			query:    "garden dening",
+			expected: mark("Gardening"),
+		},
+		{
+			name:     "repeated letters",
+			text:     "aaaa b",
+			query:    "aa",
+			expected: mark("aaaa") + " b",
+		},
+		{
+			name:     "accents",
+			text:     "Été à la CRÈME",
+			query:    "été crème",
+			expected: mark("Été") + " à la " + mark("CRÈME"),
+		},
+		{
+			name:     "escaped text and query",
+			text:     "<b>Bold</b> & <script>alert(1)</script>",
+			query:    "<script> &",
+			expected: "&lt;b&gt;Bold&lt;/b&gt; " + mark("&amp;") + " " + mark("&lt;script&gt;") + "alert(1)&lt;/script&gt;",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sb strings.Builder
+			if err := HighlightMatches(tt.text, tt.query).Render(&sb); err != nil {
+				t.Fatal(err)
+			}
+			if sb.String() != tt.expected {
+				t.Errorf("HighlightMatches(%q, %q) = %s, want %s", tt.text, tt.query, sb.String(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestSearchCards_HighlightQuery(t *testing.T) {
+	rs := NewResource(&config.Config{})
+	note := model.Note{Title: "Garden <script>", Slug: "garden", Content: "Tomatoes of the garden"}
+
+	var sb strings.Builder
+	if err := rs.renderNoteCard(note, "garden <script>").Render(&sb); err != nil {
+		t.Fatal(err)
+	}
+	if html := sb.String(); !strings.Contains(html, ">Garden</mark> <mark") || !strings.Contains(html, ">&lt;script&gt;</mark>") || !strings.Contains(html, "the <mark") || strings.Contains(html, "<script>") {
+		t.Errorf("note card should highlight the title and description, escaped:\n%s", html)
+	}
+
+	sb.Reset()
+	match := engine.HeadingMatch{Note: note, Heading: "Garden beds", Context: "Raised garden beds"}
+	if err := rs.renderHeadingCard(match, "beds").Render(&sb); err != nil {
+		t.Fatal(err)
+	}
+	if html := sb.String(); strings.Count(html, ">beds</mark>") != 2 {
+		t.Errorf("heading card should highlight the heading and its context:\n%s", html)
+	}
+
+	// Names of the sidebar filtered by a search
+	sb.Reset()
+	rs.filter = "gar"
+	if err := rs.renderTreeNode(&engine.TreeNode{Name: "Garden", Path: "garden", Note: &note}, "").Render(&sb); err != nil {
+		t.Fatal(err)
+	}
+	if html := sb.String(); !strings.Contains(html, ">Gar</mark>den") {
+		t.Errorf("sidebar note should highlight the search:\n%s", html)
+	}
+}
@@ -0,0 +1,246 @@
+package template
+
+import (
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/EwenQuim/pluie/engine"
+	"github.com/EwenQuim/pluie/model"
+	g "github.com/maragudk/gomponents"
+	. "github.com/maragudk/gomponents/html"
+)
+
+// diffContextLines is the number of unchanged lines shown around the changes of a diff
+const diffContextLines = 3
+
+const (
+	diffDeleteClass = "bg-red-50 text-red-900 dark:bg-red-950 dark:text-red-200"
+	diffInsertClass = "bg-green-50 text-green-900 dark:bg-green-950 dark:text-green-200"
+	diffNumberClass = "px-2 text-right text-gray-400 select-none align-top"
+	diffTextClass   = "px-2 whitespace-pre-wrap break-all"
+)
+
+// NoteHistory renders the commits changing a note, newest first, each linking to its diff with
+// the current content
+func (rs Resource) NoteHistory(notesService *engine.NotesService, note *model.Note, revisions []engine.GitRevision) (g.Node, error) {
+	mainContent := Div(
+		Class("flex-1 container overflow-y-auto p-4 md:px-8"),
+		renderHistoryTitle("History of ", note),
+		g.If(len(revisions) == 0,
+			P(
+				Class("text-gray-600 dark:text-gray-400"),
+				g.Text("This note is not committed yet."),
+			),
+		),
+		Ol(
+			ID("note-history"),
+			Class("divide-y divide-gray-200 dark:divide-gray-700"),
+			g.Group(g.Map(revisions, func(revision engine.GitRevision) g.Node {
+				return Li(
+					Class("py-3 flex flex-wrap items-baseline gap-x-3 gap-y-1"),
+					A(
+						Href(diffURL(note.Slug, revision.Hash, "")),
+						Class(textLinkClass+" font-medium"),
+						g.Text(revision.Message),
+					),
+					Span(
+						Class("text-sm text-gray-500 dark:text-gray-400"),
+						g.Text(revision.Author+" on "),
+						Time(DateTime(revision.Date.Format(time.RFC3339)), g.Text(engine.FormatDate(revision.Date, rs.cfg.Location()))),
+					),
+					Code(Class("text-xs text-gray-500 dark:text-gray-400"), g.Text(shortHash(revision.Hash))),
+				)
+			})),
+		),
+	)
+
+	return rs.Layout(
+		nil,
+		rs.renderWithNavbar(notesService, navbarConfig{
+			mainContent: mainContent,
+		}),
+	), nil
+}
+
+// NoteDiff renders the diff between a revision of a note and its current content, unified or
+// side by side when split is true
+func (rs Resource) NoteDiff(notesService *engine.NotesService, note *model.Note, revision engine.GitRevision, lines []engine.DiffLine, split bool) (g.Node, error) {
+	var diff g.Node
+	switch {
+	case !engine.DiffChanged(lines):
+		diff = P(Class("text-gray-600 dark:text-gray-400"), g.Text("No changes since this revision."))
+	case split:
+		diff = renderSplitDiff(diffHunks(lines, diffContextLines))
+	default:
+		diff = renderUnifiedDiff(diffHunks(lines, diffContextLines))
+	}
+
+	viewLink := func(label, view string, active bool) g.Node {
+		if active {
+			return Span(Class("font-semibold"), g.Text(label))
+		}
+		return A(Href(diffURL(note.Slug, revision.Hash, view)), Class(textLinkClass), g.Text(label))
+	}
+
+	mainContent := Div(
+		Class("flex-1 container overflow-y-auto p-4 md:px-8"),
+		renderHistoryTitle("Changes to ", note),
+		P(
+			Class("mb-4 text-sm text-gray-600 dark:text-gray-400"),
+			g.Text("Since "),
+			Code(g.Text(shortHash(revision.Hash))),
+			g.Text(" "+revision.Message+", by "+revision.Author+" on "),
+			Time(DateTime(revision.Date.Format(time.RFC3339)), g.Text(engine.FormatDate(revision.Date, rs.cfg.Location()))),
+			g.Text(" · "),
+			A(Href("/-/history/"+note.Slug), Class(textLinkClass), g.Text("All revisions")),
+		),
+		P(
+			Class("mb-4 flex gap-3 text-sm"),
+			viewLink("Unified", "", !split),
+			viewLink("Side by side", "split", split),
+		),
+		Div(ID("note-diff"), Class("overflow-x-auto"), diff),
+	)
+
+	return rs.Layout(
+		nil,
+		rs.renderWithNavbar(notesService, navbarConfig{
+			mainContent: mainContent,
+		}),
+	), nil
+}
+
+// renderHistoryTitle renders the title of the history pages, linking to the note
+func renderHistoryTitle(prefix string, note *model.Note) g.Node {
+	return H1(
+		Class("text-3xl md:text-4xl font-bold mb-4 mt-2"),
+		g.Text(prefix),
+		A(Href("/"+note.Slug), Class(textLinkClass), g.Attr("hx-boost", "true"), g.Text(note.Title)),
+	)
+}
+
+// diffURL returns the link of the diff of a note with a revision, view is "split" or empty
+func diffURL(slug, hash, view string) string {
+	query := url.Values{"rev": {hash}}
+	if view != "" {
+		query.Set("view", view)
+	}
+	return "/-/diff/" + slug + "?" + query.Encode()
+}
+
+// shortHash returns the abbreviated commit hash shown to readers
+func shortHash(hash string) string {
+	return hash[:min(7, len(hash))]
+}
+
+// diffHunks returns the changed lines of a diff with context unchanged lines around them,
+// grouping the changes closer than twice the context
+func diffHunks(lines []engine.DiffLine, context int) [][]engine.DiffLine {
+	var hunks [][]engine.DiffLine
+	start, end := -1, -1 // Lines of the current hunk
+	for i, line := range lines {
+		if line.Op == engine.DiffEqual {
+			continue
+		}
+		if start >= 0 && i-context <= end {
+			end = min(len(lines), i+context+1)
+			continue
+		}
+		if start >= 0 {
+			hunks = append(hunks, lines[start:end])
+		}
+		start, end = max(0, i-context), min(len(lines), i+context+1)
+	}
+	if start >= 0 {
+		hunks = append(hunks, lines[start:end])
+	}
+	return hunks
+}
+
+// renderUnifiedDiff renders the hunks of a diff in a single column, deleted lines above the
+// inserted ones
+func renderUnifiedDiff(hunks [][]engine.DiffLine) g.Node {
+	var rows []g.Node
+	for i, hunk := range hunks {
+		if i > 0 {
+			rows = append(rows, renderDiffSeparator(3))
+		}
+		for _, line := range hunk {
+			class, sign := "", " "
+			switch line.Op {
+			case engine.DiffDelete:
+				class, sign = diffDeleteClass, "-"
+			case engine.DiffInsert:
+				class, sign = diffInsertClass, "+"
+			}
+			rows = append(rows, Tr(
+				g.If(class != "", Class(class)),
+				Td(Class(diffNumberClass), g.Text(lineNumber(line.OldLine))),
+				Td(Class(diffNumberClass), g.Text(lineNumber(line.NewLine))),
+				Td(Class(diffTextClass), g.Text(sign+" "+line.Text)),
+			))
+		}
+	}
+	return Table(Class("diff-unified w-full font-mono text-sm border-collapse"), TBody(rows...))
+}
+
+// renderSplitDiff renders the hunks of a diff side by side, the revision on the left and the
+// current content on the right, deleted and inserted lines facing each other
+func renderSplitDiff(hunks [][]engine.DiffLine) g.Node {
+	var rows []g.Node
+	side := func(line *engine.DiffLine, number int, class string) g.Node {
+		if line == nil {
+			return g.Group([]g.Node{Td(Class(diffNumberClass)), Td(Class("bg-gray-50 dark:bg-gray-900"))})
+		}
+		return g.Group([]g.Node{
+			Td(Class(diffNumberClass), g.Text(lineNumber(number))),
+			Td(Class(diffTextClass+" w-1/2 "+class), g.Text(line.Text)),
+		})
+	}
+	for i, hunk := range hunks {
+		if i > 0 {
+			rows = append(rows, renderDiffSeparator(4))
+		}
+		for j := 0; j < len(hunk); {
+			if hunk[j].Op == engine.DiffEqual {
+				rows = append(rows, Tr(side(&hunk[j], hunk[j].OldLine, ""), side(&hunk[j], hunk[j].NewLine, "")))
+				j++
+				continue
+			}
+
+			// A change block: its deleted lines, then its inserted lines
+			var deleted, inserted []*engine.DiffLine
+			for ; j < len(hunk) && hunk[j].Op == engine.DiffDelete; j++ {
+				deleted = append(deleted, &hunk[j])
+			}
+			for ; j < len(hunk) && hunk[j].Op == engine.DiffInsert; j++ {
+				inserted = append(inserted, &hunk[j])
+			}
+			for k := range max(len(deleted), len(inserted)) {
+				left, right := g.Node(side(nil, 0, "")), g.Node(side(nil, 0, ""))
+				if k < len(deleted) {
+					left = side(deleted[k], deleted[k].OldLine, diffDeleteClass)
+				}
+				if k < len(inserted) {
+					right = side(inserted[k], inserted[k].NewLine, diffInsertClass)
+				}
+				rows = append(rows, Tr(left, right))
+			}
+		}
+	}
+	return Table(Class("diff-split w-full font-mono text-sm border-collapse"), TBody(rows...))
+}
+
+// renderDiffSeparator renders the row between two hunks, where unchanged lines are left out
+func renderDiffSeparator(columns int) g.Node {
+	return Tr(Td(ColSpan(strconv.Itoa(columns)), Class("px-2 text-center text-gray-400 select-none"), g.Text("⋯")))
+}
+
+// lineNumber formats a line number of a diff, empty for the lines missing on a side
+func lineNumber(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
@@ -0,0 +1,52 @@
+package template
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/EwenQuim/pluie/engine"
+)
+
+func TestDiffHunks(t *testing.T) {
+	var old, new []string
+	for i := range 20 {
+		line := string(rune('a' + i))
+		old = append(old, line)
+		if i != 2 && i != 4 && i != 15 {
+			new = append(new, line)
+		}
+	}
+	lines := engine.DiffLines(strings.Join(old, "\n"), strings.Join(new, "\n"))
+
+	// The changes of lines 3 and 5 share a hunk, the one of line 16 is apart
+	hunks := diffHunks(lines, 3)
+	if len(hunks) != 2 {
+		t.Fatalf("diffHunks() = %d hunks, want 2", len(hunks))
+	}
+	if first := hunks[0]; first[0].OldLine != 1 || first[len(first)-1].OldLine != 8 {
+		t.Errorf("first hunk = lines %d to %d, want 1 to 8", first[0].OldLine, first[len(first)-1].OldLine)
+	}
+	if second := hunks[1]; second[0].OldLine != 13 || second[len(second)-1].OldLine != 19 {
+		t.Errorf("second hunk = lines %d to %d, want 13 to 19", second[0].OldLine, second[len(second)-1].OldLine)
+	}
+
+	if hunks := diffHunks(engine.DiffLines("a\nb", "a\nb"), 3); len(hunks) != 0 {
+		t.Errorf("diffHunks() = %v without changes, want none", hunks)
+	}
+}
+
+func TestRenderSplitDiff(t *testing.T) {
+	lines := engine.DiffLines("a\nold <b>\nc\n", "a\nnew\nadded\nc\n")
+
+	var sb strings.Builder
+	if err := renderSplitDiff(diffHunks(lines, 3)).Render(&sb); err != nil {
+		t.Fatal(err)
+	}
+	html := sb.String()
+	if strings.Count(html, "<tr>") != 4 {
+		t.Errorf("split diff should have a row per kept line and per change pair, got:\n%s", html)
+	}
+	if !strings.Contains(html, "old &lt;b&gt;</td><td") || !strings.Contains(html, ">new</td>") {
+		t.Errorf("the deleted line should face the inserted one, escaped, got:\n%s", html)
+	}
+}
@@ -5,18 +5,25 @@ import (
 	"strings"
 
 	"github.com/EwenQuim/pluie/model"
+	"github.com/EwenQuim/pluie/static"
 	g "github.com/maragudk/gomponents"
 	. "github.com/maragudk/gomponents/html"
 )
 
 func (rs Resource) Layout(note *model.Note, node ...g.Node) g.Node {
+	return rs.layout(note, "", nil, node...)
+}
+
+// layout renders a page like Layout, with bodyClass added to the classes of its body, like the
+// "layout-wide" class of the note layouts for custom CSS, and bodyAttrs on it, like the
+// data-prev and data-next attributes of the keyboard shortcuts
+func (rs Resource) layout(note *model.Note, bodyClass string, bodyAttrs []g.Node, node ...g.Node) g.Node {
 	// Get base site configuration from Config
 	baseSiteTitle := rs.cfg.SiteTitle
 	siteIcon := rs.cfg.SiteIcon
-	baseSiteDescription := rs.cfg.SiteDescription
 
 	// Compute SEO data using the separated function
-	seoData := ComputeSEOData(note, baseSiteTitle, baseSiteDescription)
+	seoData := rs.seoData(note)
 
 	return HTML(
 		Head(
@@ -33,6 +40,9 @@ func (rs Resource) Layout(note *model.Note, node ...g.Node) g.Node {
 				Meta(Name("description"), Content(seoData.Description)),
 			),
 			Meta(Name("msapplication-TileImage"), Content(siteIcon)),
+			g.If(seoData.NoIndex,
+				Meta(Name("robots"), Content("noindex")),
+			),
 
 			// Keywords meta tag
 			g.If(len(seoData.Keywords) > 0,
@@ -54,18 +64,18 @@ func (rs Resource) Layout(note *model.Note, node ...g.Node) g.Node {
 				Meta(g.Attr("property", "og:url"), Content(seoData.CanonicalURL)),
 			),
 			Meta(g.Attr("property", "og:site_name"), Content(baseSiteTitle)),
-			g.If(siteIcon != "",
-				Meta(g.Attr("property", "og:image"), Content(siteIcon)),
+			g.If(seoData.ImageURL != "",
+				Meta(g.Attr("property", "og:image"), Content(seoData.ImageURL)),
 			),
 
 			// Twitter Card meta tags
-			Meta(Name("twitter:card"), Content("summary")),
+			Meta(Name("twitter:card"), Content(seoData.TwitterCard)),
 			Meta(Name("twitter:title"), Content(seoData.PageTitle)),
 			g.If(seoData.Description != "",
 				Meta(Name("twitter:description"), Content(seoData.Description)),
 			),
-			g.If(siteIcon != "",
-				Meta(Name("twitter:image"), Content(siteIcon)),
+			g.If(seoData.ImageURL != "",
+				Meta(Name("twitter:image"), Content(seoData.ImageURL)),
 			),
 
 			// Additional SEO meta tags for articles
@@ -80,20 +90,29 @@ func (rs Resource) Layout(note *model.Note, node ...g.Node) g.Node {
 					g.If(seoData.ModifiedMeta != nil,
 						Meta(g.Attr("property", "article:modified_time"), Content(fmt.Sprintf("%v", seoData.ModifiedMeta))),
 					),
+					RenderJSONLD(seoData, rs.cfg.SiteURL),
 				}),
 			),
 
-			Link(Rel("stylesheet"), Type("text/css"), Href("/static/tailwind.min.css")),
-			Script(Defer(), Src("/static/htmx.js")),
-			Script(Defer(), Src("/static/sse.js")),
-			Script(Defer(), Src("/static/app.js")),
+			// Not deferred: the theme is applied before the page renders
+			Script(Src(static.URL("theme.js"))),
+			Link(Rel("stylesheet"), Type("text/css"), Href(static.URL("tailwind.min.css"))),
+			Script(Defer(), Src(static.URL("htmx.js"))),
+			Script(Defer(), Src(static.URL("sse.js"))),
+			Script(Defer(), Src(static.URL("app.js"))),
+			Script(Defer(), Src(static.URL("toc.js"))),
+			Script(Defer(), Src(static.URL("keymap.js"))),
+			// Refreshes the page when the watcher reloads the notes, never in static output
+			g.If(rs.cfg.Watch && rs.cfg.Mode == "server", Script(Defer(), Src(static.URL("livereload.js")))),
 		),
 		Body(
 			ID("app"),
-			Class("scroll-smooth"),
+			Class(strings.TrimSpace("scroll-smooth "+pageClass+" "+bodyClass)),
+			g.Group(bodyAttrs),
 			Main(
 				node...,
 			),
+			renderShortcutsHelp(),
 		),
 	)
 }
@@ -1,10 +1,13 @@
 package template
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/EwenQuim/pluie/config"
+	"github.com/EwenQuim/pluie/engine"
 	"github.com/EwenQuim/pluie/model"
+	"github.com/EwenQuim/pluie/static"
 )
 
 func TestLayout(t *testing.T) {
@@ -75,3 +78,143 @@ func TestLayout(t *testing.T) {
 		})
 	}
 }
+
+func TestLayout_Theme(t *testing.T) {
+	var sb strings.Builder
+	if err := testResource().Layout(nil).Render(&sb); err != nil {
+		t.Fatalf("Render() returned error: %v", err)
+	}
+	html := sb.String()
+
+	// The theme is applied before the stylesheet, so pages never flash in the other theme
+	themeScript := strings.Index(html, `<script src="`+static.URL("theme.js")+`"></script>`)
+	stylesheet := strings.Index(html, `href="`+static.URL("tailwind.min.css")+`"`)
+	if themeScript == -1 || themeScript > stylesheet {
+		t.Errorf("theme.js should be loaded, without defer, before the stylesheet:\n%s", html)
+	}
+	if !strings.Contains(html, `class="scroll-smooth `+pageClass+`"`) {
+		t.Errorf("the body should have the page colors of both themes:\n%s", html)
+	}
+}
+
+func TestLayout_LiveReload(t *testing.T) {
+	for _, tt := range []struct {
+		mode     string
+		watch    bool
+		expected bool
+	}{
+		{mode: "server", watch: true, expected: true},
+		{mode: "server", watch: false, expected: false},
+		{mode: "static", watch: true, expected: false},
+		{mode: "export", watch: true, expected: false},
+	} {
+		var sb strings.Builder
+		if err := NewResource(&config.Config{Mode: tt.mode, Watch: tt.watch}).Layout(nil).Render(&sb); err != nil {
+			t.Fatalf("Render() returned error: %v", err)
+		}
+		if got := strings.Contains(sb.String(), static.URL("livereload.js")); got != tt.expected {
+			t.Errorf("live reload script in mode %s with WATCH=%v: %v, want %v", tt.mode, tt.watch, got, tt.expected)
+		}
+	}
+}
+
+func TestLayout_Shortcuts(t *testing.T) {
+	notes := []model.Note{
+		{Title: "A", Slug: "a", Path: "A.md"},
+		{Title: "B", Slug: "b", Path: "B.md"},
+		{Title: "C", Slug: "c", Path: "C.md"},
+	}
+	notesMap := make(map[string]model.Note)
+	for _, note := range notes {
+		notesMap[note.Slug] = note
+	}
+	notesService := engine.NewNotesService(&notesMap, engine.BuildTree(notes), nil)
+	rs := NewResource(&config.Config{PublicByDefault: true})
+
+	render := func(note *model.Note) string {
+		result, err := rs.NoteWithList(notesService, note, "")
+		if err != nil {
+			t.Fatalf("NoteWithList() returned error: %v", err)
+		}
+		var sb strings.Builder
+		if err := result.Render(&sb); err != nil {
+			t.Fatalf("Render() returned error: %v", err)
+		}
+		return sb.String()
+	}
+
+	html := render(&notes[1])
+	if !strings.Contains(html, `<script defer src="`+static.URL("keymap.js")+`"></script>`) {
+		t.Error("expected the keymap script")
+	}
+	if !strings.Contains(html, `<body id="app" class="scroll-smooth `+pageClass+` layout-default" data-prev="/a" data-next="/c">`) {
+		t.Errorf("expected the adjacent notes on the body:\n%s", html)
+	}
+	if !strings.Contains(html, `<dialog id="shortcuts-help"`) || !strings.Contains(html, "Go to the home note") || !strings.Contains(html, "<kbd") {
+		t.Error("expected the shortcuts help dialog")
+	}
+
+	if html := render(&notes[0]); strings.Contains(html, "data-prev") || !strings.Contains(html, `data-next="/b"`) {
+		t.Error("the first note has no previous note")
+	}
+	if html := render(nil); strings.Contains(html, "data-prev") || strings.Contains(html, "data-next") {
+		t.Error("the not found page has no adjacent notes")
+	}
+}
+
+func TestLayout_OpenGraph(t *testing.T) {
+	render := func(cfg *config.Config, note *model.Note) string {
+		var sb strings.Builder
+		if err := NewResource(cfg).Layout(note).Render(&sb); err != nil {
+			t.Fatalf("Render() returned error: %v", err)
+		}
+		return sb.String()
+	}
+
+	note := &model.Note{
+		Title:       "Trip",
+		Slug:        "travel/trip",
+		Metadata:    map[string]any{"description": "Two weeks by the sea", "cover": "[[beach.png]]"},
+		Attachments: map[string]string{"beach.png": "photos/beach.png"},
+	}
+	html := render(&config.Config{SiteTitle: "Pluie", SiteIcon: "/static/pluie.webp", SiteURL: "https://notes.example.com"}, note)
+	for _, expected := range []string{
+		`<meta property="og:title" content="Trip | Pluie">`,
+		`<meta property="og:description" content="Two weeks by the sea">`,
+		`<meta property="og:type" content="article">`,
+		`<meta property="og:url" content="https://notes.example.com/travel/trip">`,
+		`<link rel="canonical" href="https://notes.example.com/travel/trip">`,
+		`<meta property="og:image" content="https://notes.example.com/-/attachments/photos/beach.png">`,
+		`<meta name="twitter:card" content="summary_large_image">`,
+		`<meta name="twitter:image" content="https://notes.example.com/-/attachments/photos/beach.png">`,
+		`<script type="application/ld+json">{"@context":"https://schema.org","@type":"WebPage","headline":"Trip"`,
+	} {
+		if !strings.Contains(html, expected) {
+			t.Errorf("expected %s in the head:\n%s", expected, html)
+		}
+	}
+
+	// Without SITE_URL nor cover: relative URLs and the site icon
+	html = render(&config.Config{SiteTitle: "Pluie", SiteIcon: "/static/pluie.webp"}, &model.Note{Title: "Trip", Slug: "travel/trip"})
+	for _, expected := range []string{
+		`<meta property="og:url" content="/travel/trip">`,
+		`<meta property="og:image" content="/static/pluie.webp">`,
+		`<meta name="twitter:card" content="summary">`,
+	} {
+		if !strings.Contains(html, expected) {
+			t.Errorf("expected %s in the head:\n%s", expected, html)
+		}
+	}
+
+	// Raw slugs are escaped in the absolute URLs, encoded ones kept
+	cfg := &config.Config{SiteTitle: "Pluie", SiteURL: "https://notes.example.com"}
+	for slug, canonical := range map[string]string{
+		"旅行/東京":                "https://notes.example.com/%E6%97%85%E8%A1%8C/%E6%9D%B1%E4%BA%AC",
+		"My%20Notes/caf%C3%A9": "https://notes.example.com/My%20Notes/caf%C3%A9",
+	} {
+		html = render(cfg, &model.Note{Title: "Trip", Slug: slug})
+		if expected := `<link rel="canonical" href="` + canonical + `">`; !strings.Contains(html, expected) {
+			t.Errorf("expected %s in the head:\n%s", expected, html)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package template
+
+import (
+	"html"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/EwenQuim/pluie/engine"
+)
+
+// Classes of the links of rendered notes, styled in src/input.css
+const (
+	linkInternalNote        = "internal-note"         // Another note
+	linkInternalSection     = "internal-section"      // A section of the current note
+	linkInternalNoteSection = "internal-note-section" // A section of another note
+	linkExternal            = "external"              // Another website
+)
+
+// anchorRegex matches the opening of the links rendered from markdown
+var anchorRegex = regexp.MustCompile(`<a href="([^"]*)"`)
+
+// annotateLinks tags the links of a note rendered to HTML with their kind (see the link classes).
+// Links to existing notes other than currentSlug also get a data-preview-slug attribute, for hover previews.
+// Footnotes, assets and app pages (/-/...) are left untouched.
+func annotateLinks(renderedHTML, currentSlug string, notesService *engine.NotesService) string {
+	return anchorRegex.ReplaceAllStringFunc(renderedHTML, func(match string) string {
+		href := anchorRegex.FindStringSubmatch(match)[1]
+		class, previewSlug := classifyLink(html.UnescapeString(href), currentSlug, notesService)
+		if class == "" {
+			return match
+		}
+
+		attributes := ` class="` + class + `"`
+		if previewSlug != "" {
+			attributes += ` data-preview-slug="` + html.EscapeString(previewSlug) + `"`
+		}
+		return match + attributes
+	})
+}
+
+// classifyLink returns the class of a link and the slug of the note to preview, if any
+func classifyLink(href, currentSlug string, notesService *engine.NotesService) (class, previewSlug string) {
+	target, fragment, hasFragment := strings.Cut(href, "#")
+
+	if target == "" {
+		if !hasFragment || strings.HasPrefix(fragment, "fn:") || strings.HasPrefix(fragment, "fnref:") {
+			return "", ""
+		}
+		return linkInternalSection, ""
+	}
+
+	parsed, err := url.Parse(target)
+	if err != nil {
+		return "", ""
+	}
+	if parsed.Scheme != "" || parsed.Host != "" {
+		return linkExternal, ""
+	}
+
+	// Relative links are resolved from the folder of the current note, like the browser does
+	linkPath := parsed.Path
+	if !strings.HasPrefix(linkPath, "/") {
+		linkPath = path.Join("/", path.Dir(currentSlug), linkPath)
+	}
+	if strings.HasPrefix(linkPath, "/-/") || strings.HasPrefix(linkPath, "/static/") {
+		return "", ""
+	}
+
+	slug := strings.Trim(linkPath, "/")
+	_, exists := notesService.GetNote(slug)
+	if !exists && path.Ext(slug) != "" {
+		return "", "" // Attachment, not a note
+	}
+
+	switch {
+	case slug == currentSlug && hasFragment:
+		return linkInternalSection, ""
+	case hasFragment:
+		class = linkInternalNoteSection
+	default:
+		class = linkInternalNote
+	}
+	if exists && slug != currentSlug {
+		previewSlug = slug
+	}
+	return class, previewSlug
+}
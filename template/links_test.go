@@ -0,0 +1,128 @@
+package template
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/EwenQuim/pluie/engine"
+	"github.com/EwenQuim/pluie/model"
+)
+
+func linksNotesService() *engine.NotesService {
+	notes := []model.Note{
+		{Title: "Current", Slug: "folder/current", Path: "folder/current.md"},
+		{Title: "Sibling", Slug: "folder/sibling", Path: "folder/sibling.md"},
+		{Title: "Other", Slug: "other", Path: "other.md", Content: "## Setup\n\nSteps."},
+	}
+	notesMap := make(map[string]model.Note)
+	for _, note := range notes {
+		notesMap[note.Slug] = note
+	}
+	return engine.NewNotesService(&notesMap, buildTestTree(notes), nil)
+}
+
+func TestAnnotateLinks(t *testing.T) {
+	notesService := linksNotesService()
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "other note",
+			input:    `<a href="/other">Other</a>`,
+			expected: `<a href="/other" class="internal-note" data-preview-slug="other">Other</a>`,
+		},
+		{
+			name:     "relative link to a sibling note",
+			input:    `<a href="sibling">Sibling</a>`,
+			expected: `<a href="sibling" class="internal-note" data-preview-slug="folder/sibling">Sibling</a>`,
+		},
+		{
+			name:     "missing note",
+			input:    `<a href="/missing">Missing</a>`,
+			expected: `<a href="/missing" class="internal-note">Missing</a>`,
+		},
+		{
+			name:     "section of the current note",
+			input:    `<a href="#intro">Intro</a>`,
+			expected: `<a href="#intro" class="internal-section">Intro</a>`,
+		},
+		{
+			name:     "section of the current note by slug",
+			input:    `<a href="/folder/current#intro">Intro</a>`,
+			expected: `<a href="/folder/current#intro" class="internal-section">Intro</a>`,
+		},
+		{
+			name:     "section of another note",
+			input:    `<a href="/other#intro">Other > Intro</a>`,
+			expected: `<a href="/other#intro" class="internal-note-section" data-preview-slug="other">Other > Intro</a>`,
+		},
+		{
+			name:     "external link",
+			input:    `<a href="https://example.com/page?a=1&amp;b=2">Example</a>`,
+			expected: `<a href="https://example.com/page?a=1&amp;b=2" class="external">Example</a>`,
+		},
+		{
+			name:     "mail link",
+			input:    `<a href="mailto:me@example.com">Mail</a>`,
+			expected: `<a href="mailto:me@example.com" class="external">Mail</a>`,
+		},
+		{
+			name:     "footnote",
+			input:    `<sup class="footnote-ref" id="fnref:1"><a href="#fn:1">1</a></sup>`,
+			expected: `<sup class="footnote-ref" id="fnref:1"><a href="#fn:1">1</a></sup>`,
+		},
+		{
+			name:     "tag page",
+			input:    `<a href="/-/tag/go">#go</a>`,
+			expected: `<a href="/-/tag/go">#go</a>`,
+		},
+		{
+			name:     "attachment",
+			input:    `<a href="/files/report.pdf">Report</a>`,
+			expected: `<a href="/files/report.pdf">Report</a>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := annotateLinks(tt.input, "folder/current", notesService)
+			if result != tt.expected {
+				t.Errorf("annotateLinks() = %s, want %s", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestAnnotateLinks_NotePage(t *testing.T) {
+	rs := testResource()
+	notesService := linksNotesService()
+	note := model.Note{
+		Title:   "Current",
+		Slug:    "folder/current",
+		Content: "See [[Other]], [[Other#Setup]], [[#Usage]] and [Go](https://go.dev).\n\n## Usage\n\nText.",
+	}
+
+	page, err := rs.NoteWithList(notesService, &note, "")
+	if err != nil {
+		t.Fatalf("NoteWithList() returned error: %v", err)
+	}
+	var sb strings.Builder
+	if err := page.Render(&sb); err != nil {
+		t.Fatalf("Render() returned error: %v", err)
+	}
+	html := sb.String()
+
+	for _, expected := range []string{
+		`<a href="/other" class="internal-note" data-preview-slug="other">Other</a>`,
+		`<a href="/other#setup" class="internal-note-section" data-preview-slug="other">Other &gt; Setup</a>`,
+		`<a href="#usage" class="internal-section">Usage</a>`,
+		`<a href="https://go.dev" class="external">Go</a>`,
+	} {
+		if !strings.Contains(html, expected) {
+			t.Errorf("expected the note page to contain %s", expected)
+		}
+	}
+}
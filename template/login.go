@@ -0,0 +1,50 @@
+package template
+
+import (
+	g "github.com/maragudk/gomponents"
+	. "github.com/maragudk/gomponents/html"
+)
+
+// loginStyle styles the login page inline: the stylesheet under /static needs a credential too
+const loginStyle = `body{font-family:system-ui,sans-serif;background:#f9fafb;color:#111827;display:flex;justify-content:center;padding-top:15vh;margin:0}` +
+	`form{background:#fff;border:1px solid #e5e7eb;border-radius:.5rem;padding:1.5rem;width:100%;max-width:20rem;display:flex;flex-direction:column;gap:.75rem}` +
+	`h1{font-size:1.25rem;margin:0 0 .25rem}label{font-size:.875rem;display:flex;flex-direction:column;gap:.25rem}` +
+	`input{padding:.5rem;border:1px solid #d1d5db;border-radius:.375rem;font-size:1rem}` +
+	`button{padding:.5rem;border:0;border-radius:.375rem;background:#2563eb;color:#fff;font-size:1rem;cursor:pointer}` +
+	`p{color:#b91c1c;font-size:.875rem;margin:0}`
+
+// Login renders the page asking for the site credential: the access token when withToken, the
+// basic auth user and password when withPassword. next is where a successful login redirects.
+func (rs Resource) Login(next, errorMessage string, withToken, withPassword bool) g.Node {
+	return HTML(
+		Lang("en"),
+		Head(
+			Meta(Charset("utf-8")),
+			Meta(Name("viewport"), Content("width=device-width, initial-scale=1")),
+			Meta(Name("robots"), Content("noindex")),
+			TitleEl(g.Textf("Sign in - %s", rs.cfg.SiteTitle)),
+			StyleEl(g.Raw(loginStyle)),
+		),
+		Body(
+			FormEl(
+				ID("login"),
+				Method("post"),
+				Action("/-/login"),
+				H1(g.Text(rs.cfg.SiteTitle)),
+				g.If(errorMessage != "", P(g.Text(errorMessage))),
+				Input(Type("hidden"), Name("next"), Value(next)),
+				g.If(withPassword, g.Group([]g.Node{
+					Label(g.Text("Username"), Input(Type("text"), Name("username"), AutoComplete("username"))),
+					Label(g.Text("Password"), Input(Type("password"), Name("password"), AutoComplete("current-password"))),
+				})),
+				g.If(withToken && !withPassword,
+					Label(g.Text("Access token"), Input(Type("password"), Name("token"), AutoComplete("current-password"), AutoFocus())),
+				),
+				g.If(withToken && withPassword,
+					Label(g.Text("Or access token"), Input(Type("password"), Name("token"), AutoComplete("off"))),
+				),
+				Button(Type("submit"), g.Text("Sign in")),
+			),
+		),
+	)
+}
@@ -0,0 +1,79 @@
+package template
+
+import (
+	"fmt"
+
+	"github.com/EwenQuim/pluie/engine"
+	"github.com/EwenQuim/pluie/model"
+	g "github.com/maragudk/gomponents"
+	. "github.com/maragudk/gomponents/html"
+)
+
+// unlinkedMentionsLimit is the number of unlinked mentions listed under a note
+const unlinkedMentionsLimit = 20
+
+// renderUnlinkedMentionsSlot renders the place of the "Unlinked mentions" section of a note. The
+// mentions read every note, so the server loads them after the page, from /-/mentions/. Static
+// sites have no server and get them at generation time.
+func (rs Resource) renderUnlinkedMentionsSlot(notesService *engine.NotesService, note *model.Note) g.Node {
+	if note == nil {
+		return nil
+	}
+	if rs.cfg.Mode == "static" {
+		return rs.UnlinkedMentions(notesService, *note)
+	}
+	return Div(
+		ID("unlinked-mentions"),
+		g.Attr("hx-get", "/-/mentions/"+note.Slug),
+		g.Attr("hx-trigger", "load"),
+		g.Attr("hx-swap", "outerHTML"),
+	)
+}
+
+// UnlinkedMentions renders the "Unlinked mentions" section of a note, collapsed: the notes
+// mentioning its title without linking to it. Nothing when there are none.
+func (rs Resource) UnlinkedMentions(notesService *engine.NotesService, note model.Note) g.Node {
+	mentions := notesService.UnlinkedMentions(note, unlinkedMentionsLimit, rs.cfg.PublicByDefault)
+	if len(mentions) == 0 {
+		return g.Group(nil)
+	}
+
+	count := fmt.Sprintf("%d", len(mentions))
+	if len(mentions) == unlinkedMentionsLimit {
+		count += "+"
+	}
+
+	return Details(
+		ID("unlinked-mentions"),
+		Class("mt-8 pt-6 border-t border-gray-200 dark:border-gray-700"),
+		Summary(
+			Class("text-lg font-semibold cursor-pointer text-gray-700 dark:text-gray-300"),
+			g.Text("Unlinked mentions"),
+			Span(
+				Class("ml-2 px-2 py-0.5 text-xs font-medium bg-gray-100 text-gray-600 rounded-full dark:bg-gray-800 dark:text-gray-400"),
+				g.Text(count),
+			),
+		),
+		Ul(
+			Class("mt-3 space-y-2"),
+			g.Group(g.Map(mentions, func(mention engine.UnlinkedMention) g.Node {
+				return Li(
+					A(
+						Href("/"+mention.Note.Slug),
+						Class(textLinkClass),
+						g.Attr("hx-boost", "true"),
+						g.Text(mention.Note.Title),
+					),
+					g.If(mention.Snippet != "",
+						P(
+							Class("text-sm text-gray-600 dark:text-gray-400"),
+							g.Text(mention.Snippet[:mention.Start]),
+							Mark(Class(highlightClass), g.Text(mention.Snippet[mention.Start:mention.End])),
+							g.Text(mention.Snippet[mention.End:]),
+						),
+					),
+				)
+			})),
+		),
+	)
+}
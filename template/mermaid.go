@@ -0,0 +1,28 @@
+package template
+
+import (
+	"regexp"
+
+	"github.com/EwenQuim/pluie/static"
+	g "github.com/maragudk/gomponents"
+	. "github.com/maragudk/gomponents/html"
+)
+
+// mermaidBlockRegex matches the ```mermaid code blocks of rendered markdown, their content HTML-escaped
+var mermaidBlockRegex = regexp.MustCompile(`(?s)<pre><code class="language-mermaid">(.*?)</code></pre>`)
+
+// renderMermaidBlocks turns the mermaid code blocks of a rendered note into the divs
+// static/diagrams.js draws, and reports whether there was any. The content stays escaped:
+// mermaid reads the text of the div.
+func renderMermaidBlocks(renderedHTML string) (string, bool) {
+	if !mermaidBlockRegex.MatchString(renderedHTML) {
+		return renderedHTML, false
+	}
+	return mermaidBlockRegex.ReplaceAllString(renderedHTML, `<div class="mermaid">$1</div>`), true
+}
+
+// mermaidScript loads the diagrams script, which loads the bundled mermaid library.
+// It is in the body, not the head, so that htmx runs it on boosted navigations too.
+func mermaidScript() g.Node {
+	return Script(Src(static.URL("diagrams.js")))
+}
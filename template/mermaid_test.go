@@ -0,0 +1,59 @@
+package template
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/EwenQuim/pluie/config"
+	"github.com/EwenQuim/pluie/engine"
+	"github.com/EwenQuim/pluie/model"
+	"github.com/EwenQuim/pluie/static"
+)
+
+func TestRenderMermaidBlocks(t *testing.T) {
+	html, found := renderMermaidBlocks("<p>Flow</p>\n<pre><code class=\"language-mermaid\">graph TD\n  A--&gt;B &amp; C\n</code></pre>\n<pre><code class=\"language-go\">x := 1\n</code></pre>")
+	expected := "<p>Flow</p>\n<div class=\"mermaid\">graph TD\n  A--&gt;B &amp; C\n</div>\n<pre><code class=\"language-go\">x := 1\n</code></pre>"
+	if !found || html != expected {
+		t.Errorf("renderMermaidBlocks() = %q, %v, want %q, true", html, found, expected)
+	}
+
+	if html, found := renderMermaidBlocks("<pre><code>graph TD</code></pre>"); found || html != "<pre><code>graph TD</code></pre>" {
+		t.Errorf("renderMermaidBlocks() = %q, %v, want the html untouched", html, found)
+	}
+}
+
+func TestNoteWithList_Mermaid(t *testing.T) {
+	notes := []model.Note{
+		{Title: "Flow", Slug: "flow", Content: "```mermaid\ngraph TD\n  A-->B\n```\n"},
+		{Title: "Plain", Slug: "plain", Content: "No diagram, only `mermaid` text."},
+	}
+	notesMap := map[string]model.Note{"flow": notes[0], "plain": notes[1]}
+	notesService := engine.NewNotesService(&notesMap, engine.BuildTree(notes), nil)
+
+	render := func(cfg *config.Config, note model.Note) string {
+		result, err := NewResource(cfg).NoteWithList(notesService, &note, "")
+		if err != nil {
+			t.Fatalf("NoteWithList() returned error: %v", err)
+		}
+		var sb strings.Builder
+		if err := result.Render(&sb); err != nil {
+			t.Fatalf("Render() returned error: %v", err)
+		}
+		return sb.String()
+	}
+	script := `<script src="` + static.URL("diagrams.js") + `"></script>`
+
+	flow := render(&config.Config{Mermaid: true}, notes[0])
+	if !strings.Contains(flow, "<div class=\"mermaid\">graph TD\n  A--&gt;B\n</div>") || !strings.Contains(flow, script) {
+		t.Errorf("expected the diagram and its script:\n%s", flow)
+	}
+
+	if plain := render(&config.Config{Mermaid: true}, notes[1]); strings.Contains(plain, script) {
+		t.Error("pages without diagrams should not load the script")
+	}
+
+	disabled := render(&config.Config{Mermaid: false}, notes[0])
+	if strings.Contains(disabled, script) || !strings.Contains(disabled, `<code class="language-mermaid">`) {
+		t.Error("with MERMAID=false, diagrams should stay code blocks")
+	}
+}
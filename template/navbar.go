@@ -2,27 +2,41 @@ package template
 
 import (
 	"fmt"
+	"path"
+	"strings"
 
 	"github.com/EwenQuim/pluie/engine"
 	g "github.com/maragudk/gomponents"
 	. "github.com/maragudk/gomponents/html"
 )
 
+const burgerLineClass = "w-5 h-0.5 bg-gray-600 transition-all duration-300 ease-in-out dark:bg-gray-300"
+
 // navbarConfig holds configuration for rendering the navbar
 type navbarConfig struct {
 	currentSlug string           // Current note slug for search form action
 	searchQuery string           // Current search query value
 	displayTree *engine.TreeNode // Optional filtered tree to display (if nil, uses full tree from notesService)
 	mainContent g.Node           // Main content area
+	hideSidebar bool             // Render without the notes tree sidebar (minimal layout)
 }
 
 // renderWithNavbar renders a page with consistent navbar structure
 func (rs Resource) renderWithNavbar(notesService *engine.NotesService, config navbarConfig) g.Node {
 
+	if config.hideSidebar {
+		return Div(
+			Class("flex flex-col md:flex-row md:gap-2 h-screen w-screen justify-between"),
+			// Mobile top bar without burger menu, there is no sidebar to open
+			rs.renderMobileTopBar(rs.cfg.SiteTitle, rs.cfg.SiteIcon, false),
+			config.mainContent,
+		)
+	}
+
 	return Div(
 		Class("flex flex-col md:flex-row md:gap-2 h-screen w-screen justify-between"),
 		// Mobile top bar (hidden on desktop)
-		rs.renderMobileTopBar(rs.cfg.SiteTitle, rs.cfg.SiteIcon),
+		rs.renderMobileTopBar(rs.cfg.SiteTitle, rs.cfg.SiteIcon, true),
 		// Mobile sidebar overlay
 		rs.renderMobileSidebarOverlay(),
 		// Left sidebar with notes list
@@ -33,9 +47,9 @@ func (rs Resource) renderWithNavbar(notesService *engine.NotesService, config na
 }
 
 // renderMobileTopBar renders the mobile top navigation bar
-func (rs Resource) renderMobileTopBar(siteTitle, siteIcon string) g.Node {
+func (rs Resource) renderMobileTopBar(siteTitle, siteIcon string, withMenu bool) g.Node {
 	return Div(
-		Class("md:hidden bg-white border-b border-gray-200 p-4 flex items-center justify-between z-50"),
+		Class("md:hidden bg-white border-b border-gray-200 p-4 flex items-center justify-between z-50 dark:bg-gray-900 dark:border-gray-700"),
 		// Site title and icon
 		Div(
 			Class("flex items-center gap-3"),
@@ -47,26 +61,43 @@ func (rs Resource) renderMobileTopBar(siteTitle, siteIcon string) g.Node {
 				),
 			),
 			H1(
-				Class("text-lg font-bold text-gray-900"),
+				Class("text-lg font-bold text-gray-900 dark:text-gray-100"),
 				g.Text(siteTitle),
 			),
 		),
-		// Burger menu button
-		Button(
-			Class("p-2 rounded-md hover:bg-gray-100 focus:outline-none focus:ring-2 focus:ring-gray-200"),
-			ID("burger-menu"),
-			g.Attr("onclick", "toggleMobileSidebar()"),
-			g.Attr("aria-label", "Toggle navigation menu"),
-			Div(
-				Class("w-6 h-6 flex flex-col justify-center items-center space-y-1"),
-				Div(Class("w-5 h-0.5 bg-gray-600 transition-all duration-300 ease-in-out"), ID("burger-line-1")),
-				Div(Class("w-5 h-0.5 bg-gray-600 transition-all duration-300 ease-in-out"), ID("burger-line-2")),
-				Div(Class("w-5 h-0.5 bg-gray-600 transition-all duration-300 ease-in-out"), ID("burger-line-3")),
-			),
+		Div(
+			Class("flex items-center gap-1"),
+			renderThemeToggle(),
+			// Burger menu button
+			g.If(withMenu, Button(
+				Class("p-2 rounded-md hover:bg-gray-100 focus:outline-none focus:ring-2 focus:ring-gray-200 dark:hover:bg-gray-800 dark:focus:ring-gray-700"),
+				ID("burger-menu"),
+				g.Attr("onclick", "toggleMobileSidebar()"),
+				g.Attr("aria-label", "Toggle navigation menu"),
+				Div(
+					Class("w-6 h-6 flex flex-col justify-center items-center space-y-1"),
+					Div(Class(burgerLineClass), ID("burger-line-1")),
+					Div(Class(burgerLineClass), ID("burger-line-2")),
+					Div(Class(burgerLineClass), ID("burger-line-3")),
+				),
+			)),
 		),
 	)
 }
 
+// renderThemeToggle renders the button switching between the light and dark themes
+func renderThemeToggle() g.Node {
+	return Button(
+		Type("button"),
+		Class("p-2 rounded-md text-gray-600 hover:bg-gray-100 focus:outline-none focus:ring-2 focus:ring-gray-200 cursor-pointer dark:text-gray-300 dark:hover:bg-gray-800 dark:focus:ring-gray-700"),
+		g.Attr("onclick", "toggleTheme()"),
+		g.Attr("aria-label", "Toggle dark mode"),
+		Title("Toggle dark mode"),
+		Span(Class("dark:hidden"), g.Text("☾")),
+		Span(Class("hidden dark:inline"), g.Text("☀")),
+	)
+}
+
 // renderMobileSidebarOverlay renders the overlay for mobile sidebar
 func (rs Resource) renderMobileSidebarOverlay() g.Node {
 	return Div(
@@ -78,8 +109,14 @@ func (rs Resource) renderMobileSidebarOverlay() g.Node {
 
 // renderLeftSidebar renders the left sidebar with navigation
 func (rs Resource) renderLeftSidebar(notesService *engine.NotesService, config navbarConfig) g.Node {
+	// Search results are a filtered tree, rendered whole
+	if rs.cfg.SidebarLazy && config.searchQuery == "" {
+		rs.lazy = &lazySidebar{expanded: currentNoteFolders(notesService, config.currentSlug)}
+	}
+	rs.filter = config.searchQuery
+
 	return Div(
-		Class("w-3/4 md:w-1/4 max-w-md bg-white border-r border-gray-200 p-4 flex flex-col h-full md:relative fixed top-0 left-0 z-50 md:z-auto -translate-x-full md:translate-x-0 transition-transform duration-300 ease-in-out"),
+		Class("w-3/4 md:w-1/4 max-w-md bg-white border-r border-gray-200 p-4 flex flex-col h-full md:relative fixed top-0 left-0 z-50 md:z-auto -translate-x-full md:translate-x-0 transition-transform duration-300 ease-in-out dark:bg-gray-900 dark:border-gray-700"),
 		ID("mobile-sidebar"),
 		// Site header with title and icon
 		Div(
@@ -96,14 +133,19 @@ func (rs Resource) renderLeftSidebar(notesService *engine.NotesService, config n
 				),
 				// Site title
 				H1(
-					Class("text-xl font-bold text-gray-900"),
+					Class("text-xl font-bold text-gray-900 dark:text-gray-100"),
 					g.Text(rs.cfg.SiteTitle),
 				),
+				// Theme toggle, the mobile top bar has its own
+				Div(
+					Class("ml-auto hidden md:block"),
+					renderThemeToggle(),
+				),
 			),
 			// Site description
 			g.If(rs.cfg.SiteDescription != "",
 				P(
-					Class("text-sm text-gray-500 italic"),
+					Class(emptyStateClass),
 					g.Text(rs.cfg.SiteDescription),
 				),
 			),
@@ -111,24 +153,26 @@ func (rs Resource) renderLeftSidebar(notesService *engine.NotesService, config n
 		// Navigation links
 		Div(
 			Class("mb-6"),
-			// Simple search link
+			// Search as you type, Enter opens the search page
+			rs.renderQuickSearch(),
 			A(
-				Href("/-/search"),
-				Class("w-full inline-flex border border-gray-300 items-center gap-2 px-3 py-2 text-sm text-gray-700 hover:text-gray-900 hover:bg-gray-50 rounded-md transition-colors"),
-				g.Attr("hx-boost", "true"),
-				Span(g.Text("🔍")),
-				g.Text("Search (c+K)"),
+				ID("random-note-link"),
+				Href("/-/random"),
+				Rel("nofollow"),
+				Class("w-full inline-flex items-center gap-2 mt-2 px-3 py-2 text-sm text-gray-700 hover:text-gray-900 hover:bg-gray-50 rounded-md transition-colors dark:text-gray-300 dark:hover:text-gray-100 dark:hover:bg-gray-800"),
+				Span(g.Text("🎲")),
+				g.Text("Random note"),
 			),
 		),
 		Div(
 			Class("mb-4 flex gap-2"),
 			Button(
-				Class("px-3 py-1 text-sm bg-gray-200 hover:bg-gray-300 text-gray-700 rounded-md transition-colors cursor-pointer"),
+				Class(secondaryButtonClass),
 				g.Attr("onclick", "expandAllFolders()"),
 				g.Text("Expand All"),
 			),
 			Button(
-				Class("px-3 py-1 text-sm bg-gray-200 hover:bg-gray-300 text-gray-700 rounded-md transition-colors cursor-pointer"),
+				Class(secondaryButtonClass),
 				g.Attr("onclick", "collapseAllFolders()"),
 				g.Text("Collapse All"),
 			),
@@ -148,27 +192,16 @@ func (rs Resource) renderLeftSidebar(notesService *engine.NotesService, config n
 					// Results info (only show if search query is present)
 					g.If(config.searchQuery != "",
 						P(
-							Class("text-gray-600 mb-2 text-sm"),
+							Class("text-gray-600 mb-2 text-sm dark:text-gray-400"),
 							g.Text(fmt.Sprintf("Found %d notes matching \"%s\"", countNotesInTree(tree), config.searchQuery)),
 						),
 					),
 					// Notes tree
-					Div(
-						ID("notes-list"),
-						Class(""),
-						g.If(tree != nil && len(tree.Children) > 0,
-							Ul(
-								Class(""),
-								g.Group(g.Map(tree.Children, func(child *engine.TreeNode) g.Node {
-									return rs.renderTreeNode(child, config.currentSlug)
-								})),
-							),
-						),
-					),
+					rs.renderNotesList(tree, config.currentSlug, config.searchQuery == ""),
 					// Show message if no results found
 					g.If(config.searchQuery != "" && (tree == nil || len(tree.Children) == 0),
 						P(
-							Class("text-gray-500 mt-4 text-sm"),
+							Class("text-gray-500 mt-4 text-sm dark:text-gray-400"),
 							g.Text("No notes found matching your search."),
 						),
 					),
@@ -177,3 +210,67 @@ func (rs Resource) renderLeftSidebar(notesService *engine.NotesService, config n
 		),
 	)
 }
+
+// renderNotesList renders the notes tree of the sidebar. With a shared sidebar, see
+// WithSharedSidebar, the whole tree is loaded from it instead, unless it is filtered.
+func (rs Resource) renderNotesList(tree *engine.TreeNode, currentSlug string, wholeTree bool) g.Node {
+	if rs.sharedSidebar != "" && wholeTree {
+		return Div(
+			ID("notes-list"),
+			g.Attr("hx-get", rs.sharedSidebar),
+			g.Attr("hx-trigger", "load"),
+			g.Attr("hx-swap", "outerHTML"),
+		)
+	}
+	return Div(
+		ID("notes-list"),
+		Class(""),
+		rs.renderTree(tree, currentSlug),
+	)
+}
+
+// renderTree renders the children of the root of a notes tree, nothing for an empty tree
+func (rs Resource) renderTree(tree *engine.TreeNode, currentSlug string) g.Node {
+	if tree == nil || len(tree.Children) == 0 {
+		return nil
+	}
+	return Ul(
+		Class(""),
+		g.Group(g.Map(tree.Children, func(child *engine.TreeNode) g.Node {
+			return rs.renderTreeNode(child, currentSlug)
+		})),
+	)
+}
+
+// SharedSidebar renders the notes tree of the sidebar without a current note, for the pages
+// of a static site to load, see WithSharedSidebar. The class of the active link lets the pages
+// highlight their note once it is loaded.
+func (rs Resource) SharedSidebar(notesService *engine.NotesService) g.Node {
+	return Div(
+		ID("notes-list"),
+		g.Attr("data-active-class", activeLinkClass),
+		rs.renderTree(notesService.GetTree(), ""),
+	)
+}
+
+// WithSharedSidebar returns the resource rendering pages that load the notes tree of their
+// sidebar from url, see SharedSidebar, rather than each holding the whole tree. Static sites
+// write it once.
+func (rs Resource) WithSharedSidebar(url string) Resource {
+	rs.sharedSidebar = url
+	return rs
+}
+
+// currentNoteFolders returns the paths of the folders of the current note, like "a" and "a/b"
+// for "a/b/Note.md"
+func currentNoteFolders(notesService *engine.NotesService, currentSlug string) map[string]bool {
+	folders := make(map[string]bool)
+	note, ok := notesService.GetNote(currentSlug)
+	if !ok {
+		return folders
+	}
+	for folder := path.Dir(strings.Trim(note.Path, "/")); folder != "." && folder != "/"; folder = path.Dir(folder) {
+		folders[folder] = true
+	}
+	return folders
+}
@@ -2,9 +2,13 @@ package template
 
 import (
 	"fmt"
+	"html"
+	"net/url"
 	"regexp"
 	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/EwenQuim/pluie/config"
 	"github.com/EwenQuim/pluie/engine"
@@ -16,12 +20,24 @@ import (
 )
 
 type Resource struct {
-	cfg *config.Config
+	cfg           *config.Config
+	statuses      engine.StatusSet           // Parsed from cfg.NoteStatuses, for the status badges
+	lazy          *lazySidebar               // Set while rendering a sidebar with cfg.SidebarLazy
+	filter        string                     // Search query of the sidebar being rendered, highlighted in its names
+	comments      map[string][]model.Comment // Comments of the notes by slug, shown by static sites, see WithComments
+	sharedSidebar string                     // URL of the notes tree the pages load instead of rendering it, see WithSharedSidebar
+}
+
+// lazySidebar renders the folders of the sidebar below the top two levels without their
+// contents, loaded from TreeFolder when opened. The folders of the current note are rendered
+// open, so deep links keep their context.
+type lazySidebar struct {
+	expanded map[string]bool // Paths of the folders of the current note
 }
 
 // NewResource creates a new Resource with the given configuration
 func NewResource(cfg *config.Config) Resource {
-	return Resource{cfg: cfg}
+	return Resource{cfg: cfg, statuses: engine.ParseStatuses(cfg.NoteStatuses, cfg.PrivateStatuses)}
 }
 
 // MapMapSorted creates nodes from a map with keys sorted alphabetically
@@ -50,14 +66,6 @@ func sortKeysCaseInsensitive(keys []string) {
 	})
 }
 
-// CSS class constants for consistent styling
-const (
-	folderButtonClass = "flex items-center text-left w-full px-2 py-1 text-gray-900 hover:text-black hover:bg-gray-50"
-	chevronClass      = "mr-2 transition-transform duration-200 text-gray-400 text-xs"
-	activeLinkClass   = "flex items-center px-2 py-1 text-purple-600 bg-purple-50 border-l border-purple-600 font-medium"
-	inactiveLinkClass = "flex items-center px-2 py-1 text-gray-600 hover:text-gray-900 hover:bg-gray-50 border-l border-gray-300 hover:border-gray-800"
-)
-
 // renderTreeNode renders a single tree node with its children
 func (rs Resource) renderTreeNode(node *engine.TreeNode, currentSlug string) g.Node {
 	if node == nil {
@@ -80,13 +88,33 @@ func (rs Resource) renderFolderNode(node *engine.TreeNode, currentSlug string) g
 				Class(folderButtonClass),
 				g.Attr("onclick", fmt.Sprintf("toggleFolder('%s')", node.Path)),
 				rs.renderChevronIcon(node),
-				Span(g.Text(node.Name)),
+				renderFolderIcon(node.Icon),
+				Span(HighlightMatches(node.Name, rs.filter)),
 			),
 		),
 		rs.renderFolderChildren(node, currentSlug),
 	)
 }
 
+// renderFolderIcon renders the icon of a folder set in its .pluie file: an image for URLs,
+// the text of emojis
+func renderFolderIcon(icon string) g.Node {
+	if icon == "" {
+		return nil
+	}
+	if engine.IsImageIcon(icon) {
+		return Img(
+			Src(icon),
+			Alt(""),
+			Class("folder-icon w-4 h-4 mr-2 object-contain"),
+		)
+	}
+	return Span(
+		Class("folder-icon mr-2"),
+		g.Text(icon),
+	)
+}
+
 // renderNoteNode renders a note tree node
 func (rs Resource) renderNoteNode(node *engine.TreeNode, currentSlug string) g.Node {
 	isActive := node.Note != nil && node.Note.Slug == currentSlug
@@ -101,32 +129,52 @@ func (rs Resource) renderNoteNode(node *engine.TreeNode, currentSlug string) g.N
 			Class(linkClass),
 			g.Attr("hx-boost", "true"),
 			g.Attr("onclick", "handleMobileLinkClick()"),
-			g.Text(node.Name),
+			HighlightMatches(node.Name, rs.filter),
+			g.If(node.Note != nil && node.Note.IsDraft, Span(Class("ml-2"), DraftBadge())),
 		),
 	)
 }
 
 // renderChevronIcon renders the folder chevron icon
 func (rs Resource) renderChevronIcon(node *engine.TreeNode) g.Node {
+	open := rs.folderOpen(node)
 	return Span(
 		Class(chevronClass),
 		ID("chevron-"+node.Path),
-		g.If(node.IsOpen, g.Text("▼")),
-		g.If(!node.IsOpen, g.Text("▶")),
+		g.If(open, g.Text("▼")),
+		g.If(!open, g.Text("▶")),
 	)
 }
 
+// folderOpen reports whether a folder of the sidebar is rendered open
+func (rs Resource) folderOpen(node *engine.TreeNode) bool {
+	return node.IsOpen || (rs.lazy != nil && rs.lazy.expanded[node.Path])
+}
+
 // renderFolderChildren renders the children container for a folder
 func (rs Resource) renderFolderChildren(node *engine.TreeNode, currentSlug string) g.Node {
 	if len(node.Children) == 0 {
 		return g.Text("")
 	}
 
+	open := rs.folderOpen(node)
 	displayStyle := "display: none;"
-	if node.IsOpen {
+	if open {
 		displayStyle = "display: block;"
 	}
 
+	// Below the top two levels, closed folders load their contents when opened
+	if rs.lazy != nil && !open && strings.Contains(node.Path, "/") {
+		return Ul(
+			Class("ml-4"),
+			ID("folder-"+node.Path),
+			g.Attr("style", displayStyle),
+			g.Attr("hx-get", "/-/tree?path="+url.QueryEscape(node.Path)),
+			g.Attr("hx-trigger", "load-folder once"),
+			g.Attr("hx-swap", "outerHTML"),
+		)
+	}
+
 	return Ul(
 		Class("ml-4"),
 		ID("folder-"+node.Path),
@@ -137,13 +185,18 @@ func (rs Resource) renderFolderChildren(node *engine.TreeNode, currentSlug strin
 	)
 }
 
-// TOCItem represents a table of contents item
-type TOCItem struct {
-	ID    string
-	Text  string
-	Level int
+// TreeFolder renders the contents of a folder of the sidebar, open, for the folders rendered
+// without them with cfg.SidebarLazy. Its subfolders are rendered without their contents too.
+func (rs Resource) TreeFolder(node *engine.TreeNode) g.Node {
+	rs.lazy = &lazySidebar{}
+	folder := *node
+	folder.IsOpen = true
+	return rs.renderFolderChildren(&folder, "")
 }
 
+// TOCItem represents a table of contents item
+type TOCItem = engine.TOCItem
+
 // removeObsidianCallouts removes Obsidian callout notations from content
 func removeObsidianCallouts(content string) string {
 	// Regular expression to match Obsidian callout lines: > [!KEYWORD].*
@@ -153,43 +206,103 @@ func removeObsidianCallouts(content string) string {
 	return calloutRegex.ReplaceAllString(content, "")
 }
 
-// extractHeadings extracts headings from markdown content and returns TOC items
-func extractHeadings(content string) []TOCItem {
+// extractHeadings returns the table of contents of a rendered note: its headings with the ids
+// of setHeadingIDs, the ones of callouts included. Embedded notes have no heading ids, their
+// headings are not part of it.
+func extractHeadings(renderedHTML string) []TOCItem {
 	var tocItems []TOCItem
-	usedSlugs := make(map[string]int) // Track used slugs to handle duplicates
+	for _, parts := range identifiedHeadingRegex.FindAllStringSubmatch(renderedHTML, -1) {
+		level, _ := strconv.Atoi(parts[1])
+		tocItems = append(tocItems, TOCItem{
+			ID:    html.UnescapeString(parts[2]),
+			Text:  headingText(parts[3]),
+			Level: level,
+		})
+	}
 
-	// Regular expression to match markdown headings
-	headingRegex := regexp.MustCompile(`^(#{1,6})\s+(.+)$`)
+	return tocItems
+}
 
-	lines := strings.Split(content, "\n")
+// renderedHeadingRegex matches the headings of a note rendered from markdown, with their text
+var renderedHeadingRegex = regexp.MustCompile(`<h([1-6]) id="[^"]*">(.*?)</h[1-6]>`)
 
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if matches := headingRegex.FindStringSubmatch(line); matches != nil {
-			level := len(matches[1]) // Number of # characters
-			text := strings.TrimSpace(matches[2])
+// htmlTagRegex matches the tags inside a rendered heading
+var htmlTagRegex = regexp.MustCompile(`<[^>]*>`)
 
-			// Generate a slug from the heading text
-			baseSlug := engine.SlugifyHeading(text)
-			id := baseSlug
+// identifiedHeadingRegex matches the headings with an id of a rendered note, see setHeadingIDs
+var identifiedHeadingRegex = regexp.MustCompile(`<h([1-6]) id="([^"]+)">(.*?)</h[1-6]>`)
 
-			// Handle duplicate slugs by appending a number
-			if count, exists := usedSlugs[baseSlug]; exists {
-				usedSlugs[baseSlug] = count + 1
-				id = fmt.Sprintf("%s-%d", baseSlug, count+1)
-			} else {
-				usedSlugs[baseSlug] = 1
-			}
+// headingText is the plain text of a rendered heading, without its formatting
+func headingText(renderedHeading string) string {
+	return strings.TrimSpace(html.UnescapeString(htmlTagRegex.ReplaceAllString(renderedHeading, "")))
+}
 
-			tocItems = append(tocItems, TOCItem{
-				ID:    id,
-				Text:  text,
-				Level: level,
-			})
-		}
+// setHeadingIDs replaces the heading ids of rendered markdown with engine.HeadingIDs anchors,
+// the ones of the table of contents and of [[Note#Heading]] links
+func setHeadingIDs(renderedHTML string) string {
+	ids := engine.HeadingIDs{}
+	return renderedHeadingRegex.ReplaceAllStringFunc(renderedHTML, func(match string) string {
+		parts := renderedHeadingRegex.FindStringSubmatch(match)
+		return fmt.Sprintf(`<h%s id="%s">%s</h%s>`, parts[1], html.EscapeString(ids.Next(headingText(parts[2]))), parts[2], parts[1])
+	})
+}
+
+// removeHeadingIDs removes the heading ids of a rendered note embedded in another one: its
+// anchors belong to its own page, and would clash with the ones of the page note
+func removeHeadingIDs(renderedHTML string) string {
+	return identifiedHeadingRegex.ReplaceAllString(renderedHTML, `<h$1>$3</h$1>`)
+}
+
+// renderNoteHTML renders the markdown of prepareNoteContent to HTML, with heading anchors,
+// footnote back-links, task checkboxes, sized attachment images, audio players, annotated links
+// and the embedded notes
+func renderNoteHTML(notesService *engine.NotesService, parsedContent, slug string) string {
+	return renderNoteHTMLIn(notesService, parsedContent, slug, []string{slug}, false)
+}
+
+// renderNoteHTMLIn is renderNoteHTML for a note embedded in the notes of chain, see renderTransclusions
+func renderNoteHTMLIn(notesService *engine.NotesService, parsedContent, slug string, chain []string, withoutDrafts bool) string {
+	return renderTransclusions(notesService, renderNoteSource(notesService, parsedContent, slug), chain, withoutDrafts)
+}
+
+// renderNoteSource is renderNoteHTML before the embedded notes are expanded: the HTML of the
+// note's own content, its embeds left as transclusion tokens
+func renderNoteSource(notesService *engine.NotesService, parsedContent, slug string) string {
+	rendered := setHeadingIDs(string(markdown.Markdown(parsedContent)))
+	return annotateLinks(sizeAttachmentImages(embedAttachmentAudio(renderTaskCheckboxes(linkFootnotes(rendered)))), slug, notesService)
+}
+
+// NoteHTML renders the content of the note to HTML like its page, without running the mermaid
+// diagrams, which need the scripts of the page
+func (rs Resource) NoteHTML(notesService *engine.NotesService, note *model.Note) string {
+	return renderNoteContent(notesService, note, note.Content, note.Attachments, note.Slug).HTML
+}
+
+// NoteAPIHTML is NoteHTML for the JSON API, which never gives drafts: the drafts shown by the
+// server are not embedded. Those pages skip the render cache.
+func (rs Resource) NoteAPIHTML(notesService *engine.NotesService, note *model.Note) string {
+	if !rs.cfg.DraftsShown() {
+		return rs.NoteHTML(notesService, note)
 	}
+	parsedContent := prepareNoteContent(notesService, note.Content, note.Attachments)
+	return renderNoteHTMLIn(notesService, parsedContent, note.Slug, []string{note.Slug}, true)
+}
 
-	return tocItems
+// renderNoteContent renders the content of a page to HTML, with its table of contents. The
+// ones of notes come from the render cache of notesService, see engine.NotesService.RenderNote.
+func renderNoteContent(notesService *engine.NotesService, note *model.Note, content string, attachments map[string]string, slug string) engine.RenderedNote {
+	render := func() engine.RenderedNote {
+		source := renderNoteSource(notesService, prepareNoteContent(notesService, content, attachments), slug)
+		return engine.RenderedNote{
+			Source: source,
+			HTML:   renderTransclusions(notesService, source, []string{slug}, false),
+			TOC:    extractHeadings(source),
+		}
+	}
+	if note == nil {
+		return render()
+	}
+	return notesService.RenderNote(note, render)
 }
 
 // renderTOC renders the table of contents as HTML nodes
@@ -197,7 +310,7 @@ func renderTOC(tocItems []TOCItem) []g.Node {
 	if len(tocItems) == 0 {
 		return []g.Node{
 			P(
-				Class("text-sm text-gray-500 italic"),
+				Class(emptyStateClass),
 				g.Text("No headings found"),
 			),
 		}
@@ -239,10 +352,21 @@ func renderTOC(tocItems []TOCItem) []g.Node {
 			fontWeightClass = "font-normal"
 		}
 
+		// Embedded headings link to the page of their note, toc.js only follows the "#" links
+		if item.Source != "" {
+			nodes = append(nodes, A(
+				Href("/"+item.Source+"#"+item.ID),
+				Class(fmt.Sprintf("toc-embedded block py-1 px-2 italic text-gray-500 hover:text-gray-700 hover:bg-gray-50 rounded-md transition-colors dark:text-gray-500 dark:hover:text-gray-300 dark:hover:bg-gray-800 %s %s", indentClass, textSizeClass)),
+				Title("Embedded from /"+item.Source),
+				Span(g.Attr("aria-hidden", "true"), g.Text("↪ ")),
+				g.Text(item.Text),
+			))
+			continue
+		}
+
 		node := A(
 			Href("#"+item.ID),
-			Class(fmt.Sprintf("block py-1 px-2 text-gray-600 hover:text-gray-700 hover:bg-gray-50 rounded-md transition-colors [&.active]:text-purple-600 [&.active]:bg-gray-100 [&.active]:font-medium %s %s %s", indentClass, textSizeClass, fontWeightClass)),
-			g.Attr("onclick", "handleTOCClick(event, this)"),
+			Class(fmt.Sprintf("block py-1 px-2 text-gray-600 hover:text-gray-700 hover:bg-gray-50 rounded-md transition-colors [&.active]:text-purple-600 [&.active]:bg-gray-100 [&.active]:font-medium dark:text-gray-400 dark:hover:text-gray-300 dark:hover:bg-gray-800 dark:[&.active]:text-purple-400 dark:[&.active]:bg-gray-800 %s %s %s", indentClass, textSizeClass, fontWeightClass)),
 			g.Text(item.Text),
 		)
 
@@ -252,24 +376,111 @@ func renderTOC(tocItems []TOCItem) []g.Node {
 	return nodes
 }
 
+// renderLocalGraph renders the "Connections" panel: the notes one link away from note,
+// outgoing links first, broken ones greyed out, then the backlinks. Nothing without links.
+func (rs Resource) renderLocalGraph(note *model.Note, notesService *engine.NotesService) g.Node {
+	if note == nil {
+		return nil
+	}
+	outgoing := engine.OutgoingLinks(*note, notesService.GetTree(), rs.cfg.PublicByDefault)
+	if len(outgoing) == 0 && len(note.ReferencedBy) == 0 {
+		return nil
+	}
+
+	connectionClass := "flex items-center gap-1 py-1 px-2 text-sm rounded-md"
+	return Div(
+		ID("local-graph"),
+		Class("mt-4 pt-4 border-t border-gray-200 dark:border-gray-700"),
+		H3(
+			Class("text-sm font-semibold text-gray-900 uppercase tracking-wide mb-2 dark:text-gray-100"),
+			g.Text("Connections"),
+		),
+		g.If(len(outgoing) > 0,
+			Ul(
+				Class("mb-2"),
+				g.Group(g.Map(outgoing, func(link engine.OutgoingLink) g.Node {
+					if link.Broken() {
+						return Li(
+							Class(connectionClass+" broken-link text-gray-400 line-through dark:text-gray-500"),
+							Title("No note with this title"),
+							Span(g.Text("→")),
+							g.Text(link.Title),
+						)
+					}
+					return Li(
+						A(
+							Href("/"+link.Note.Slug),
+							Class(connectionClass+" text-gray-600 hover:text-gray-900 hover:bg-gray-50 transition-colors dark:text-gray-400 dark:hover:text-gray-100 dark:hover:bg-gray-800"),
+							Title("Linked from this note"),
+							Span(Class("text-gray-400 dark:text-gray-500"), g.Text("→")),
+							g.Text(link.Title),
+						),
+					)
+				})),
+			),
+		),
+		g.If(len(note.ReferencedBy) > 0,
+			Ul(
+				g.Group(g.Map(note.ReferencedBy, func(ref model.NoteReference) g.Node {
+					return Li(
+						A(
+							Href("/"+ref.Slug),
+							Class(connectionClass+" text-gray-600 hover:text-gray-900 hover:bg-gray-50 transition-colors dark:text-gray-400 dark:hover:text-gray-100 dark:hover:bg-gray-800"),
+							Title("Links to this note"),
+							Span(Class("text-gray-400 dark:text-gray-500"), g.Text("←")),
+							g.Text(ref.Title),
+						),
+					)
+				})),
+			),
+		),
+	)
+}
+
 // renderYamlProperty renders a YAML property with appropriate HTML based on its type
-func renderYamlProperty(key string, value any) g.Node {
+func (rs Resource) renderYamlProperty(key string, value any) g.Node {
 	return Div(
-		Class("flex flex-row items-center py-3 border-b border-gray-100 last:border-b-0 transition-colors duration-150 hover:bg-gray-50"),
+		Class("flex flex-row items-center py-3 border-b border-gray-100 last:border-b-0 transition-colors duration-150 hover:bg-gray-50 dark:border-gray-800 dark:hover:bg-gray-800"),
 		Dt(
-			Class("text-sm font-medium text-gray-700 ml-4 mb-2 sm:mb-0 sm:w-1/3"),
+			Class("text-sm font-medium text-gray-700 ml-4 mb-2 sm:mb-0 sm:w-1/3 dark:text-gray-300"),
 			g.Text(key),
 		),
 		Dd(
 			Class("sm:w-2/3 mr-4 sm:mr-2"),
-			renderYamlValue(value),
+			rs.renderYamlValue(value),
+		),
+	)
+}
+
+const datePillClass = "inline-flex items-center gap-1 text-sm text-indigo-700 bg-indigo-50 px-3 py-1 rounded border border-indigo-200 dark:text-indigo-300 dark:bg-indigo-950 dark:border-indigo-800"
+
+// renderDatePill renders a date in the site timezone, keeping the raw frontmatter value as tooltip
+func (rs Resource) renderDatePill(date time.Time, dateOnly bool, raw string) g.Node {
+	loc := rs.cfg.Location()
+	text := engine.FormatDateTime(date, loc)
+	if dateOnly {
+		text = engine.FormatDate(date, loc)
+	}
+
+	return Div(
+		Class(datePillClass),
+		Span(
+			Class("text-xs"),
+			g.Text("📅"),
+		),
+		Time(
+			DateTime(engine.FormatRFC3339(date, loc)),
+			Title(raw),
+			g.Text(text),
 		),
 	)
 }
 
 // renderYamlValue renders a YAML value with appropriate HTML based on its type
-func renderYamlValue(value any) g.Node {
+func (rs Resource) renderYamlValue(value any) g.Node {
 	switch v := value.(type) {
+	case time.Time:
+		return rs.renderDatePill(v, false, v.String())
 	case bool:
 		// Render boolean as a checkbox-style indicator
 		return Div(
@@ -278,9 +489,9 @@ func renderYamlValue(value any) g.Node {
 				Class(fmt.Sprintf("w-4 h-4 rounded border-2 flex items-center justify-center %s",
 					func() string {
 						if v {
-							return "bg-green-100 border-green-500 text-green-700"
+							return "bg-green-100 border-green-500 text-green-700 dark:bg-green-900/40 dark:text-green-300"
 						}
-						return "bg-gray-100 border-gray-300 text-gray-400"
+						return "bg-gray-100 border-gray-300 text-gray-400 dark:bg-gray-800 dark:border-gray-600 dark:text-gray-500"
 					}())),
 				g.If(v, g.Text("✓")),
 			),
@@ -289,7 +500,7 @@ func renderYamlValue(value any) g.Node {
 		// Render array as pills/tags
 		if len(v) == 0 {
 			return Span(
-				Class("text-sm text-gray-500 italic"),
+				Class(emptyStateClass),
 				g.Text("(empty list)"),
 			)
 		}
@@ -300,12 +511,12 @@ func renderYamlValue(value any) g.Node {
 				// Check if the item contains markdown links (parsed wikilinks)
 				if strings.Contains(itemStr, "](") && strings.Contains(itemStr, "[") {
 					return Span(
-						Class("inline-flex items-center px-2 py-1 rounded-full text-xs font-medium bg-blue-100 text-blue-800 border border-blue-200"),
+						Class("inline-flex items-center px-2 py-1 rounded-full text-xs font-medium bg-blue-100 text-blue-800 border border-blue-200 dark:bg-blue-900/40 dark:text-blue-200 dark:border-blue-800"),
 						g.Raw(string(markdown.Markdown(itemStr))),
 					)
 				}
 				return Span(
-					Class("inline-flex items-center px-2 py-1 rounded-full text-xs font-medium bg-gray-100 text-gray-800 border border-gray-200"),
+					Class("inline-flex items-center px-2 py-1 rounded-full text-xs font-medium bg-gray-100 text-gray-800 border border-gray-200 dark:bg-gray-800 dark:text-gray-200 dark:border-gray-700"),
 					g.Text(itemStr),
 				)
 			})),
@@ -314,23 +525,23 @@ func renderYamlValue(value any) g.Node {
 		// Render object as nested key-value pairs
 		if len(v) == 0 {
 			return Span(
-				Class("text-sm text-gray-500 italic"),
+				Class(emptyStateClass),
 				g.Text("(empty object)"),
 			)
 		}
 		return Div(
-			Class("bg-gray-50 border border-gray-200 rounded-md p-3"),
+			Class("bg-gray-50 border border-gray-200 rounded-md p-3 dark:bg-gray-800 dark:border-gray-700"),
 			Dl(
 				Class("space-y-2"),
 				g.Group(MapMapSorted(v, func(nestedKey string, nestedValue any) g.Node {
 					return Div(
 						Class("flex flex-col sm:flex-row sm:items-center"),
 						Dt(
-							Class("text-xs font-medium text-gray-600 sm:w-1/3"),
+							Class("text-xs font-medium text-gray-600 sm:w-1/3 dark:text-gray-400"),
 							g.Text(nestedKey),
 						),
 						Dd(
-							Class("text-xs text-gray-800 font-mono bg-white px-2 py-1 rounded border sm:w-2/3 mt-1 sm:mt-0"),
+							Class("text-xs text-gray-800 font-mono bg-white px-2 py-1 rounded border sm:w-2/3 mt-1 sm:mt-0 dark:text-gray-200 dark:bg-gray-900"),
 							g.Text(fmt.Sprintf("%v", nestedValue)),
 						),
 					)
@@ -342,7 +553,7 @@ func renderYamlValue(value any) g.Node {
 		str := strings.TrimSpace(v)
 		if str == "" {
 			return Span(
-				Class("text-sm text-gray-500 italic"),
+				Class(emptyStateClass),
 				g.Text("(empty)"),
 			)
 		}
@@ -350,7 +561,7 @@ func renderYamlValue(value any) g.Node {
 		// Check if the string contains markdown links (parsed wikilinks)
 		if strings.Contains(str, "](") && strings.Contains(str, "[") {
 			return Div(
-				Class("text-sm text-gray-900 bg-slate-50 hover:bg-slate-100 px-3 py-1 rounded border border-slate-200 hover:border-slate-300 font-mono transition-all duration-150"),
+				Class(propertyValueClass),
 				g.Raw(string(markdown.Markdown(str))),
 			)
 		}
@@ -361,7 +572,7 @@ func renderYamlValue(value any) g.Node {
 				Href(str),
 				Target("_blank"),
 				Rel("noopener noreferrer"),
-				Class("inline-flex items-center gap-1 text-sm text-blue-600 hover:text-blue-800 hover:underline bg-blue-50 px-3 py-1 rounded border border-blue-200 transition-colors"),
+				Class("inline-flex items-center gap-1 text-sm text-blue-600 hover:text-blue-800 hover:underline bg-blue-50 px-3 py-1 rounded border border-blue-200 transition-colors dark:text-blue-400 dark:hover:text-blue-200 dark:bg-blue-950 dark:border-blue-800"),
 				g.Text(str),
 				Span(
 					Class("text-xs"),
@@ -374,7 +585,7 @@ func renderYamlValue(value any) g.Node {
 		if strings.Contains(str, "@") && strings.Contains(str, ".") {
 			return A(
 				Href("mailto:"+str),
-				Class("inline-flex items-center gap-1 text-sm text-purple-600 hover:text-purple-800 hover:underline bg-purple-50 px-3 py-1 rounded border border-purple-200 transition-colors"),
+				Class("inline-flex items-center gap-1 text-sm text-purple-600 hover:text-purple-800 hover:underline bg-purple-50 px-3 py-1 rounded border border-purple-200 transition-colors dark:text-purple-400 dark:hover:text-purple-200 dark:bg-purple-950 dark:border-purple-800"),
 				g.Text(str),
 				Span(
 					Class("text-xs"),
@@ -385,8 +596,11 @@ func renderYamlValue(value any) g.Node {
 
 		// Check if it's a date-like string
 		if regexp.MustCompile(`^\d{4}-\d{2}-\d{2}`).MatchString(str) {
+			if date, dateOnly, ok := engine.ParseDate(str, rs.cfg.Location()); ok {
+				return rs.renderDatePill(date, dateOnly, str)
+			}
 			return Div(
-				Class("inline-flex items-center gap-1 text-sm text-indigo-700 bg-indigo-50 px-3 py-1 rounded border border-indigo-200"),
+				Class(datePillClass),
 				Span(
 					Class("text-xs"),
 					g.Text("📅"),
@@ -397,13 +611,13 @@ func renderYamlValue(value any) g.Node {
 
 		// Regular string
 		return Div(
-			Class("text-sm text-gray-900 bg-slate-50 hover:bg-slate-100 px-3 py-1 rounded border border-slate-200 hover:border-slate-300 font-mono transition-all duration-150"),
+			Class(propertyValueClass),
 			g.Text(str),
 		)
 	case int, int32, int64, float32, float64:
 		// Render numbers with special styling
 		return Div(
-			Class("inline-flex items-center gap-1 text-sm text-orange-700 bg-orange-50 px-3 py-1 rounded border border-orange-200 font-mono"),
+			Class("inline-flex items-center gap-1 text-sm text-orange-700 bg-orange-50 px-3 py-1 rounded border border-orange-200 font-mono dark:text-orange-300 dark:bg-orange-950 dark:border-orange-800"),
 			Span(
 				Class("text-xs"),
 				g.Text("#"),
@@ -413,7 +627,7 @@ func renderYamlValue(value any) g.Node {
 	default:
 		// Fallback for unknown types
 		return Div(
-			Class("text-sm text-gray-900 bg-slate-50 hover:bg-slate-100 px-3 py-1 rounded border border-slate-200 hover:border-slate-300 font-mono transition-all duration-150"),
+			Class(propertyValueClass),
 			g.Text(fmt.Sprintf("%v", value)),
 		)
 	}
@@ -421,12 +635,43 @@ func renderYamlValue(value any) g.Node {
 
 // NoteWithList displays a note with the list of all notes on the left side
 func (rs Resource) NoteWithList(notesService *engine.NotesService, note *model.Note, searchQuery string) (g.Node, error) {
+	return rs.noteWithList(notesService, note, searchQuery, nil, engine.SystemNoteNotFound)
+}
+
+// PrivateNote displays the page of a note that exists but is private: _pluie/private.md, else
+// the not found page, so that by default nothing tells it exists
+func (rs Resource) PrivateNote(notesService *engine.NotesService, searchQuery string) (g.Node, error) {
+	return rs.noteWithList(notesService, nil, searchQuery, nil, engine.SystemNotePrivate)
+}
+
+// DeletedNote displays the last known version of a deleted note, under a banner telling when it was deleted
+func (rs Resource) DeletedNote(notesService *engine.NotesService, trashed model.TrashedNote, searchQuery string) (g.Node, error) {
+	loc := rs.cfg.Location()
+	banner := Div(
+		Class("deleted-banner mb-6 bg-red-50 border border-red-200 text-red-900 rounded-lg px-4 py-3 dark:bg-red-950 dark:border-red-800 dark:text-red-100"),
+		g.Attr("role", "alert"),
+		P(
+			Class("font-semibold"),
+			g.Textf("This note was deleted on %s", engine.FormatDate(trashed.DeletedAt, loc)),
+		),
+		P(
+			Class("text-sm"),
+			g.Textf("It stays readable here until %s, then this page will not exist anymore.", engine.FormatDate(trashed.ExpiresAt, loc)),
+		),
+	)
+	return rs.noteWithList(notesService, &trashed.Note, searchQuery, banner, "")
+}
+
+// noteWithList renders a note page, with an optional banner above the title. Without note, it
+// renders the system note named missingPage, see systemPage.
+func (rs Resource) noteWithList(notesService *engine.NotesService, note *model.Note, searchQuery string, banner g.Node, missingPage string) (g.Node, error) {
 
 	matter := map[string]any{}
 	var content []byte
 	var slug string
 	var title string
 	var referencedBy []model.NoteReference
+	var attachments map[string]string
 
 	if note != nil {
 		// Parse wikilinks in metadata before using it
@@ -436,25 +681,39 @@ func (rs Resource) NoteWithList(notesService *engine.NotesService, note *model.N
 		slug = note.Slug
 		title = note.Title
 		referencedBy = note.ReferencedBy
+		attachments = note.Attachments
 		content = []byte(note.Content)
+	} else if len(notesService.GetAllNotes()) == 0 {
+		// Empty folder, like a preview started in the wrong directory
+		title = "No notes yet"
+		content = []byte("No markdown files were found in this folder. Add a `.md` file and it will show up here.")
+	} else if page, ok := systemPage(notesService, missingPage); ok {
+		title = page.Title
+		attachments = page.Attachments
+		content = []byte(page.Content)
 	} else {
 		title = "404 : Not found"
 		content = []byte("This note does not exist or is private.")
 	}
 
-	// Parse wiki-style links before markdown processing
-	parsedContent := notesService.ParseWikiLinks(string(content))
-
-	// Parse hashtags to clickable links
-	parsedContent = engine.ParseHashtagLinks(parsedContent)
-
-	parsedContent = engine.ProcessMarkdownLinks(parsedContent)
+	rendered := renderNoteContent(notesService, note, string(content), attachments, slug)
+	noteHTML := rendered.HTML
+	hasDiagrams := false
+	if rs.cfg.Mermaid {
+		noteHTML, hasDiagrams = renderMermaidBlocks(noteHTML)
+	}
+	if note != nil {
+		noteHTML = addHeadingAnchors(noteHTML)
+	}
 
-	// Remove Obsidian callout notations from the content
-	parsedContent = removeObsidianCallouts(parsedContent)
+	// Headings for table of contents, the ones of the embedded notes too with TOC_EMBEDS
+	tocItems := rendered.TOC
+	if rs.cfg.TOCEmbeds && note != nil {
+		tocItems = tocWithEmbeds(notesService, rendered.Source, slug)
+	}
 
-	// Extract headings for table of contents
-	tocItems := extractHeadings(parsedContent)
+	// Resolve the page layout (note > folder > default, see model.Note.DetermineLayout)
+	layout := resolveLayout(note)
 
 	// Filter tree based on search query
 	displayTree := notesService.GetTree()
@@ -462,30 +721,56 @@ func (rs Resource) NoteWithList(notesService *engine.NotesService, note *model.N
 		displayTree = notesService.FilterTreeBySearch(searchQuery)
 	}
 
+	// Previous and next notes, in the sidebar order, also followed with the [ and ] keys
+	var prev, next *model.Note
+	if note != nil {
+		prev, next = notesService.AdjacentNotes(slug, rs.cfg.PublicByDefault)
+	}
+
 	// Main content with note and TOC sidebar
 	mainContent := g.Group([]g.Node{
 		// Main content area with the note
 		Div(
-			Class("flex-1 container overflow-y-auto p-4 md:px-8"),
+			Class(noteContentClass(layout)),
+			g.Iff(note != nil, func() g.Node {
+				return renderBreadcrumbs(engine.BreadcrumbsForSlug(slug, notesService.GetTree()))
+			}),
+			banner,
+			g.Iff(note != nil, func() g.Node {
+				return rs.renderNoteToolbar(note)
+			}),
 			H1(
 				Class("text-3xl md:text-4xl font-bold mb-4 mt-2"),
 				g.If(title != "", g.Text(title)),
+				g.Iff(note != nil && note.Status != "", func() g.Node {
+					return Span(Class("ml-3 text-base font-normal"), rs.StatusBadge(note.Status))
+				}),
+				g.If(note != nil && note.IsDraft, Span(Class("ml-3 text-base font-normal"), DraftBadge())),
 			),
+			g.Iff(note != nil, func() g.Node {
+				return renderReadingStats(engine.ReadingStats(note.Content, rs.cfg.ReadingWPM))
+			}),
+			g.Iff(note != nil, func() g.Node {
+				return rs.renderLastCommit(note)
+			}),
+			g.Iff(note != nil && rs.cfg.ShowViewCount, func() g.Node {
+				return renderViewCount(note.ViewCount)
+			}),
 			g.If(len(matter) > 0 && !rs.cfg.HideYamlFrontmatter,
 				Div(
 					Class("mb-6 opacity-80"),
 					// YAML front matter header with toggle button
 					Div(
-						Class("flex items-center justify-between bg-gradient-to-br from-slate-50 to-slate-100 hover:from-slate-100 hover:to-slate-200 border border-slate-200 rounded-t-lg px-4 py-3 transition-all duration-200"),
+						Class("flex items-center justify-between bg-gradient-to-br from-slate-50 to-slate-100 hover:from-slate-100 hover:to-slate-200 border border-slate-200 rounded-t-lg px-4 py-3 transition-all duration-200 dark:from-slate-800 dark:to-slate-700 dark:border-slate-700"),
 						Div(
 							Class("flex items-center gap-2"),
 							Span(
-								Class("text-xs font-mono text-gray-500 uppercase tracking-wide"),
+								Class("text-xs font-mono text-gray-500 uppercase tracking-wide dark:text-gray-400"),
 								g.Textf("%d properties", len(matter)),
 							),
 						),
 						Button(
-							Class("flex items-center gap-1 text-sm text-gray-600 hover:text-gray-900 transition-colors"),
+							Class("flex items-center gap-1 text-sm text-gray-600 hover:text-gray-900 transition-colors dark:text-gray-400 dark:hover:text-gray-100"),
 							g.Attr("onclick", "toggleYamlFrontmatter()"),
 							g.Attr("id", "yaml-toggle-btn"),
 							Span(g.Text("Show")),
@@ -493,14 +778,14 @@ func (rs Resource) NoteWithList(notesService *engine.NotesService, note *model.N
 					),
 					// YAML front matter content (hidden by default)
 					Div(
-						Class("bg-white border-l border-r border-b border-gray-200 rounded-b-lg transition-all duration-300 overflow-hidden"),
+						Class("bg-white border-l border-r border-b border-gray-200 rounded-b-lg transition-all duration-300 overflow-hidden dark:bg-gray-900 dark:border-gray-700"),
 						g.Attr("id", "yaml-content"),
 						g.Attr("style", "display: none;"),
 						Div(
 							Dl(
 								Class("grid grid-cols-1"),
 								g.Group(MapMapSorted(matter, func(key string, value any) g.Node {
-									return renderYamlProperty(key, value)
+									return rs.renderYamlProperty(key, value)
 								})),
 							),
 						),
@@ -508,15 +793,18 @@ func (rs Resource) NoteWithList(notesService *engine.NotesService, note *model.N
 				),
 			),
 			Div(
-				Class("prose max-w-none"),
-				g.Raw(string(markdown.Markdown(parsedContent))),
+				Class(proseClass),
+				g.Raw(noteHTML),
 			),
+			g.If(hasDiagrams, mermaidScript()),
+			// Previous and next notes, in the sidebar order
+			renderPrevNext(prev, next),
 			// Referenced By section
 			g.If(len(referencedBy) > 0,
 				Div(
-					Class("mt-8 pt-6 border-t border-gray-200"),
+					Class("mt-8 pt-6 border-t border-gray-200 dark:border-gray-700"),
 					H3(
-						Class("text-lg font-semibold mb-3 text-gray-700"),
+						Class("text-lg font-semibold mb-3 text-gray-700 dark:text-gray-300"),
 						g.Text("Referenced by"),
 					),
 					Ul(
@@ -525,7 +813,7 @@ func (rs Resource) NoteWithList(notesService *engine.NotesService, note *model.N
 							return Li(
 								A(
 									Href("/"+ref.Slug),
-									Class("text-blue-600 hover:text-blue-800 hover:underline"),
+									Class(textLinkClass),
 									g.Text(ref.Title),
 								),
 							)
@@ -533,15 +821,17 @@ func (rs Resource) NoteWithList(notesService *engine.NotesService, note *model.N
 					),
 				),
 			),
+			rs.renderUnlinkedMentionsSlot(notesService, note),
+			rs.renderCommentsSlot(note),
 		),
-		// Right sidebar with "On this page" table of contents
-		Div(
-			Class("w-64 bg-white border-l border-gray-200 p-4 hidden md:flex flex-col h-full"),
+		// Right sidebar with "On this page" table of contents (default layout only)
+		g.If(layout == model.LayoutDefault, Div(
+			Class("w-64 bg-white border-l border-gray-200 p-4 hidden md:flex flex-col h-full dark:bg-gray-900 dark:border-gray-700"),
 			ID("toc-sidebar"),
 			Div(
 				Class("mb-4"),
 				H3(
-					Class("text-sm font-semibold text-gray-900 uppercase tracking-wide"),
+					Class("text-sm font-semibold text-gray-900 uppercase tracking-wide dark:text-gray-100"),
 					g.Text("On this page"),
 				),
 			),
@@ -552,21 +842,223 @@ func (rs Resource) NoteWithList(notesService *engine.NotesService, note *model.N
 					Class("space-y-1"),
 					g.Group(renderTOC(tocItems)),
 				),
+				rs.renderLocalGraph(note, notesService),
 			),
-		),
+		)),
 	})
 
-	return rs.Layout(
+	return rs.layout(
 		note,
+		"layout-"+layout,
+		adjacentNoteAttrs(prev, next),
 		rs.renderWithNavbar(notesService, navbarConfig{
 			currentSlug: slug,
 			searchQuery: searchQuery,
 			displayTree: displayTree,
 			mainContent: mainContent,
+			hideSidebar: layout == model.LayoutMinimal,
 		}),
 	), nil
 }
 
+// systemPage returns the system note named name, falling back to the not found one like
+// engine.SystemNotePrivate does
+func systemPage(notesService *engine.NotesService, name string) (model.Note, bool) {
+	if page, ok := notesService.SystemNote(name); ok {
+		return page, true
+	}
+	if name != engine.SystemNoteNotFound {
+		return notesService.SystemNote(engine.SystemNoteNotFound)
+	}
+	return model.Note{}, false
+}
+
+// renderBreadcrumbs renders the folders above the title of a page, each one linking to its index page
+func renderBreadcrumbs(crumbs []engine.Crumb) g.Node {
+	if len(crumbs) == 0 {
+		return nil
+	}
+
+	return Nav(
+		ID("breadcrumbs"),
+		g.Attr("aria-label", "Breadcrumb"),
+		Class("mb-2 text-sm text-gray-500 dark:text-gray-400"),
+		Ol(
+			Class("flex flex-wrap items-center gap-1"),
+			g.Group(g.Map(crumbs, func(crumb engine.Crumb) g.Node {
+				return Li(
+					Class("flex items-center gap-1"),
+					g.If(crumb.Slug != crumbs[0].Slug,
+						Span(Class("text-gray-400 dark:text-gray-600"), g.Attr("aria-hidden", "true"), g.Text("/")),
+					),
+					A(
+						Href("/"+crumb.Slug),
+						g.Attr("hx-boost", "true"),
+						Class("hover:text-blue-600 hover:underline dark:hover:text-blue-400"),
+						g.Text(crumb.Name),
+					),
+				)
+			})),
+		),
+	)
+}
+
+// renderReadingStats renders the word count and reading time of a note, like "1,240 words · 6 min read"
+func renderReadingStats(words, minutes int) g.Node {
+	if words == 0 {
+		return nil
+	}
+	unit := "words"
+	if words == 1 {
+		unit = "word"
+	}
+	return P(
+		ID("reading-stats"),
+		Class("-mt-2 mb-4 text-sm text-gray-500 dark:text-gray-400"),
+		g.Textf("%s %s · %d min read", formatThousands(words), unit, minutes),
+	)
+}
+
+// renderViewCount renders the views of a note, like "1,240 views"
+func renderViewCount(views int64) g.Node {
+	if views == 0 {
+		return nil
+	}
+	unit := "views"
+	if views == 1 {
+		unit = "view"
+	}
+	return P(
+		ID("view-count"),
+		Class("-mt-3 mb-4 text-sm text-gray-500 dark:text-gray-400"),
+		g.Textf("%s %s", formatThousands(int(views)), unit),
+	)
+}
+
+// renderLastCommit renders the date of the last commit of a note, linked to the commit page
+// when GIT_WEB_URL is set, like "Last updated on Jun 2, 2024"
+func (rs Resource) renderLastCommit(note *model.Note) g.Node {
+	if note.LastCommitHash == "" {
+		return nil
+	}
+	date := Time(DateTime(note.LastCommitDate.Format(time.RFC3339)), g.Text(engine.FormatDate(note.LastCommitDate, rs.cfg.Location())))
+	if rs.cfg.GitWebURL != "" {
+		date = A(
+			Href(strings.ReplaceAll(rs.cfg.GitWebURL, "{hash}", note.LastCommitHash)),
+			Class(textLinkClass),
+			Title("Commit "+note.LastCommitHash[:min(7, len(note.LastCommitHash))]),
+			date,
+		)
+	}
+	return P(
+		ID("last-updated"),
+		Class("-mt-3 mb-4 text-sm text-gray-500 dark:text-gray-400"),
+		g.Text("Last updated on "),
+		date,
+	)
+}
+
+// formatThousands formats n with comma thousands separators, like "1,240"
+func formatThousands(n int) string {
+	digits := strconv.Itoa(n)
+	var sb strings.Builder
+	for i, digit := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteRune(digit)
+	}
+	return sb.String()
+}
+
+// adjacentNoteAttrs returns the data-prev and data-next attributes of the page body, the URLs
+// of the previous and next notes for the [ and ] keys, see keymap.js
+func adjacentNoteAttrs(prev, next *model.Note) []g.Node {
+	var attrs []g.Node
+	if prev != nil {
+		attrs = append(attrs, g.Attr("data-prev", "/"+prev.Slug))
+	}
+	if next != nil {
+		attrs = append(attrs, g.Attr("data-next", "/"+next.Slug))
+	}
+	return attrs
+}
+
+// renderPrevNext renders the links to the previous and next notes, if any
+func renderPrevNext(prev, next *model.Note) g.Node {
+	if prev == nil && next == nil {
+		return nil
+	}
+
+	link := func(note *model.Note, label, align string) g.Node {
+		if note == nil {
+			return Span()
+		}
+		return A(
+			Href("/"+note.Slug),
+			Class("flex flex-col "+align+" text-blue-600 hover:text-blue-800 dark:text-blue-400 dark:hover:text-blue-200"),
+			Span(Class("text-xs text-gray-500 uppercase tracking-wide dark:text-gray-400"), g.Text(label)),
+			Span(Class("hover:underline"), g.Text(note.Title)),
+		)
+	}
+
+	return Nav(
+		ID("prev-next"),
+		Class("mt-8 pt-6 border-t border-gray-200 flex justify-between gap-4 dark:border-gray-700"),
+		link(prev, "← Previous", "items-start"),
+		link(next, "Next →", "items-end text-right"),
+	)
+}
+
+// prepareNoteContent turns Obsidian flavoured markdown into standard markdown:
+// attachment embeds become images, wikilinks, hashtags and note links become regular links,
+// links to attachments point to their URL, callout notations are removed. attachments are
+// the resolved attachments of the note.
+func prepareNoteContent(notesService *engine.NotesService, content string, attachments map[string]string) string {
+	// Attachment embeds first, wikilinks would take their [[...]] part
+	parsedContent := engine.ParseAttachmentEmbeds(content, attachments)
+
+	// Then ![[Note]] embeds, rendered by renderTransclusions
+	parsedContent = engine.ProcessTransclusions(parsedContent, notesService.GetTree(), transclusionToken)
+
+	// Parse wiki-style links before markdown processing
+	parsedContent = notesService.ParseWikiLinks(parsedContent)
+
+	// Parse hashtags to clickable links
+	parsedContent = engine.ParseHashtagLinks(parsedContent)
+
+	parsedContent = engine.ProcessMarkdownLinks(parsedContent, attachments)
+
+	// Remove Obsidian callout notations from the content
+	return removeObsidianCallouts(parsedContent)
+}
+
+// resolveLayout returns the layout to render a note with, LayoutDefault when unset
+func resolveLayout(note *model.Note) string {
+	if note == nil {
+		return model.LayoutDefault
+	}
+
+	switch note.Layout {
+	case model.LayoutWide, model.LayoutMinimal:
+		return note.Layout
+	default:
+		return model.LayoutDefault
+	}
+}
+
+// noteContentClass returns the classes of the main content column for a layout
+func noteContentClass(layout string) string {
+	switch layout {
+	case model.LayoutWide:
+		return "flex-1 w-full overflow-y-auto p-4 md:px-8"
+	case model.LayoutMinimal:
+		return "flex-1 container mx-auto overflow-y-auto p-4 md:px-8"
+	default:
+		return "flex-1 container overflow-y-auto p-4 md:px-8"
+	}
+}
+
 // countNotesInTree counts the total number of notes in a template tree
 func countNotesInTree(node *engine.TreeNode) int {
 	if node == nil {
@@ -585,40 +1077,85 @@ func countNotesInTree(node *engine.TreeNode) int {
 	return count
 }
 
-// TagList displays all notes that contain a specific tag
-func (rs Resource) TagList(notesService *engine.NotesService, tag string, notes []model.Note) (g.Node, error) {
+// TagList displays the notes of a tag, grouped by tag when they include the notes of its nested
+// tags. exact is for the page of the tag alone, see getTag.
+func (rs Resource) TagList(notesService *engine.NotesService, tag string, groups []engine.TagGroup, exact bool) (g.Node, error) {
 	var title string
 	var content g.Node
 
+	notesCount := 0
+	for _, group := range groups {
+		notesCount += len(group.Notes)
+	}
+	// Headers are only needed when nested tags bring notes
+	nested := len(groups) > 1 || (len(groups) == 1 && !strings.EqualFold(groups[0].Tag, tag))
+
 	if tag == "" {
 		title = "Tag not found"
 		content = Div(
-			Class("prose max-w-none"),
+			Class(proseClass),
 			P(g.Text("No tag specified.")),
 		)
-	} else if len(notes) == 0 {
+	} else if notesCount == 0 {
 		title = fmt.Sprintf("Tag: #%s", tag)
 		content = Div(
-			Class("prose max-w-none"),
+			Class(proseClass),
 			P(g.Textf("No notes found with tag #%s.", tag)),
 		)
 	} else {
-		title = fmt.Sprintf("Tag: #%s (%d notes)", tag, len(notes))
+		title = fmt.Sprintf("Tag: #%s (%d notes)", tag, notesCount)
 		content = Div(
-			Class("prose max-w-none"),
+			Class(proseClass),
 			P(
-				Class("text-gray-600 mb-6"),
-				g.Textf("Found %d notes with tag #%s:", len(notes), tag),
-			),
-			Div(
-				Class("grid gap-4 md:grid-cols-2 lg:grid-cols-3"),
-				g.Group(g.Map(notes, func(note model.Note) g.Node {
-					return rs.renderNoteCard(note)
-				})),
+				Class("text-gray-600 mb-6 dark:text-gray-400"),
+				g.Textf("Found %d notes with tag #%s", notesCount, tag),
+				g.If(nested, g.Text(" or a nested tag")),
+				g.Text(":"),
 			),
+			g.Group(g.Map(groups, func(group engine.TagGroup) g.Node {
+				return Section(
+					Class("mb-8"),
+					g.If(nested, H2(
+						Class("text-xl font-semibold mb-3"),
+						A(
+							Href("/-/tag/"+group.Tag),
+							Class("no-underline hover:underline"),
+							g.Attr("hx-boost", "true"),
+							g.Text("#"+group.Tag),
+						),
+					)),
+					Div(
+						Class("grid gap-4 md:grid-cols-2 lg:grid-cols-3"),
+						g.Group(g.Map(group.Notes, func(note model.Note) g.Node {
+							return rs.renderNoteCard(note, "")
+						})),
+					),
+				)
+			})),
 		)
 	}
 
+	// Switching between the tag alone and its nested tags needs the server
+	var exactLink g.Node
+	if tag != "" && rs.cfg.Mode != "static" {
+		if exact {
+			exactLink = A(
+				Href("/-/tag/"+tag),
+				Class("text-blue-600 hover:underline dark:text-blue-400"),
+				g.Attr("hx-boost", "true"),
+				g.Textf("Include nested tags of #%s", tag),
+			)
+		} else if nested {
+			exactLink = A(
+				ID("exact-tag-link"),
+				Href("/-/tag/"+tag+"?exact=1"),
+				Class("text-blue-600 hover:underline dark:text-blue-400"),
+				g.Attr("hx-boost", "true"),
+				g.Textf("Only notes tagged #%s", tag),
+			)
+		}
+	}
+
 	// Main content area
 	mainContent := Div(
 		Class("flex-1 container overflow-y-auto p-4 md:px-8"),
@@ -627,6 +1164,17 @@ func (rs Resource) TagList(notesService *engine.NotesService, tag string, notes
 			g.Text(title),
 		),
 		content,
+		P(
+			Class("mt-6 text-sm flex gap-4"),
+			A(
+				ID("all-tags-link"),
+				Href("/-/tags"),
+				Class("text-blue-600 hover:underline dark:text-blue-400"),
+				g.Attr("hx-boost", "true"),
+				g.Text("All tags"),
+			),
+			exactLink,
+		),
 	)
 
 	return rs.Layout(
@@ -637,55 +1185,31 @@ func (rs Resource) TagList(notesService *engine.NotesService, tag string, notes
 	), nil
 }
 
-// renderNoteCard renders a single note as a card for the tag list view
-func (rs Resource) renderNoteCard(note model.Note) g.Node {
+// renderNoteCard renders a single note as a card for the tag list view, with the words of query
+// highlighted in its title and description
+func (rs Resource) renderNoteCard(note model.Note, query string) g.Node {
 	// Extract first few lines of content for description
-	description := extractDescription(note.Content)
+	description := engine.ExtractDescription(note.Content)
 
 	return Div(
-		Class("bg-white border border-gray-200 rounded-lg p-4 hover:shadow-md transition-shadow"),
+		Class("bg-white border border-gray-200 rounded-lg p-4 hover:shadow-md transition-shadow dark:bg-gray-900 dark:border-gray-700"),
+		g.Attr("data-slug", note.Slug),
 		A(
 			Href("/"+note.Slug),
 			Class("block"),
 			g.Attr("hx-boost", "true"),
 			H3(
-				Class("text-lg font-semibold text-gray-900 mb-2 hover:text-blue-600"),
-				g.Text(note.Title),
+				Class("text-lg font-semibold text-gray-900 mb-2 hover:text-blue-600 dark:text-gray-100 dark:hover:text-blue-400"),
+				HighlightMatches(note.Title, query),
+				g.If(note.Status != "", Span(Class("ml-2"), rs.StatusBadge(note.Status))),
+				g.If(note.IsDraft, Span(Class("ml-2"), DraftBadge())),
 			),
 			g.If(description != "",
 				P(
-					Class("text-sm text-gray-600 line-clamp-3"),
-					g.Text(description),
+					Class("text-sm text-gray-600 line-clamp-3 dark:text-gray-400"),
+					HighlightMatches(description, query),
 				),
 			),
 		),
 	)
 }
-
-// extractDescription extracts the first few lines of content for a description
-func extractDescription(content string) string {
-	// Remove markdown headers and get first paragraph
-	lines := strings.Split(content, "\n")
-	var description strings.Builder
-
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		// Skip empty lines and headers
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
-		}
-		// Stop at first substantial line and use it as description
-		if len(line) > 10 {
-			description.WriteString(line)
-			break
-		}
-	}
-
-	desc := description.String()
-	// Truncate if too long
-	if len(desc) > 150 {
-		desc = desc[:150] + "..."
-	}
-
-	return desc
-}
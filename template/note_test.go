@@ -2,13 +2,17 @@ package template
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/EwenQuim/pluie/config"
 	"github.com/EwenQuim/pluie/engine"
 	"github.com/EwenQuim/pluie/model"
+	"github.com/go-fuego/fuego/extra/markdown"
 	g "github.com/maragudk/gomponents"
 )
 
@@ -581,7 +585,7 @@ func TestRenderYamlValue(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := renderYamlValue(tt.input)
+			result := testResource().renderYamlValue(tt.input)
 			if result == nil {
 				t.Errorf("renderYamlValue() returned nil for input %v", tt.input)
 			}
@@ -589,6 +593,57 @@ func TestRenderYamlValue(t *testing.T) {
 	}
 }
 
+func TestRenderYamlValue_Dates(t *testing.T) {
+	tests := []struct {
+		zone     string
+		value    any
+		expected []string
+	}{
+		{
+			zone:     "UTC",
+			value:    "2024-03-11",
+			expected: []string{`datetime="2024-03-11T00:00:00+00:00"`, "Mar 11, 2024", `title="2024-03-11"`},
+		},
+		{
+			zone:     "Europe/Paris",
+			value:    "2024-03-11",
+			expected: []string{`datetime="2024-03-11T00:00:00+01:00"`, "Mar 11, 2024"},
+		},
+		{
+			zone:     "Asia/Kolkata",
+			value:    "2024-03-10T23:30:00Z",
+			expected: []string{`datetime="2024-03-11T05:00:00+05:30"`, "Mar 11, 2024 05:00 IST"},
+		},
+		{
+			zone:     "Europe/Paris",
+			value:    time.Date(2024, 7, 1, 10, 0, 0, 0, time.UTC),
+			expected: []string{`datetime="2024-07-01T12:00:00+02:00"`, "Jul 1, 2024 12:00 CEST"},
+		},
+		{
+			zone:     "UTC",
+			value:    "2024-99-99",
+			expected: []string{"2024-99-99"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s %v", tt.zone, tt.value), func(t *testing.T) {
+			rs := NewResource(&config.Config{SiteTimezone: tt.zone})
+
+			var sb strings.Builder
+			if err := rs.renderYamlValue(tt.value).Render(&sb); err != nil {
+				t.Fatalf("Render() returned error: %v", err)
+			}
+
+			for _, expected := range tt.expected {
+				if !strings.Contains(sb.String(), expected) {
+					t.Errorf("expected %q in %s", expected, sb.String())
+				}
+			}
+		})
+	}
+}
+
 func TestRenderYamlProperty(t *testing.T) {
 	tests := []struct {
 		name  string
@@ -619,7 +674,7 @@ func TestRenderYamlProperty(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := renderYamlProperty(tt.key, tt.value)
+			result := testResource().renderYamlProperty(tt.key, tt.value)
 			if result == nil {
 				t.Errorf("renderYamlProperty() returned nil for key %q, value %v", tt.key, tt.value)
 			}
@@ -730,6 +785,25 @@ func TestRenderFolderNode(t *testing.T) {
 	}
 }
 
+func TestRenderFolderNode_Icon(t *testing.T) {
+	rs := testResource()
+
+	for icon, expected := range map[string]string{
+		"📚":                 `<span class="folder-icon mr-2">📚</span><span>Books</span>`,
+		"/static/books.png": `<img src="/static/books.png" alt="" class="folder-icon w-4 h-4 mr-2 object-contain"><span>Books</span>`,
+		"":                  `</span><span>Books</span>`,
+	} {
+		var sb strings.Builder
+		node := &engine.TreeNode{Name: "Books", Path: "books", IsFolder: true, Icon: icon}
+		if err := rs.renderFolderNode(node, "").Render(&sb); err != nil {
+			t.Fatalf("Render() error: %v", err)
+		}
+		if !strings.Contains(sb.String(), expected) {
+			t.Errorf("icon %q: expected %s in:\n%s", icon, expected, sb.String())
+		}
+	}
+}
+
 func TestRenderNoteNode(t *testing.T) {
 	rs := testResource()
 
@@ -883,6 +957,131 @@ func TestRenderFolderChildren(t *testing.T) {
 	}
 }
 
+func TestNoteWithList_LazySidebar(t *testing.T) {
+	notes := []model.Note{
+		{Title: "Deep", Slug: "a/b/c/deep", Path: "A/B/C/Deep.md"},
+		{Title: "Shallow", Slug: "a/b/shallow", Path: "A/B/Shallow.md"},
+		{Title: "Other", Slug: "x/y/z/other", Path: "X/Y/Z/Other.md"},
+		{Title: "Sibling", Slug: "x/y/sibling", Path: "X/Y/Sibling.md"},
+	}
+	notesMap := make(map[string]model.Note)
+	for _, note := range notes {
+		notesMap[note.Slug] = note
+	}
+	notesService := engine.NewNotesService(&notesMap, engine.BuildTreeWithFolders(notes, nil), nil)
+
+	render := func(rs Resource, slug string) string {
+		note := notesMap[slug]
+		result, err := rs.NoteWithList(notesService, &note, "")
+		if err != nil {
+			t.Fatalf("NoteWithList() returned error: %v", err)
+		}
+		var sb strings.Builder
+		if err := result.Render(&sb); err != nil {
+			t.Fatalf("Render() returned error: %v", err)
+		}
+		return sb.String()
+	}
+
+	full := render(testResource(), "a/b/c/deep")
+	if !strings.Contains(full, `href="/x/y/z/other"`) || strings.Contains(full, "/-/tree") {
+		t.Error("without SidebarLazy, every note should be in the sidebar")
+	}
+
+	rs := NewResource(&config.Config{SiteTitle: "Pluie", SidebarLazy: true})
+	lazy := render(rs, "a/b/c/deep")
+
+	// The folders of the current note are rendered open, another deep folder is loaded when opened
+	if !strings.Contains(lazy, `id="folder-A/B/C" style="display: block;"`) || !strings.Contains(lazy, `href="/a/b/c/deep"`) {
+		t.Error("the folders of the current note should be rendered open")
+	}
+	if !strings.Contains(lazy, `id="folder-X/Y" style="display: none;" hx-get="/-/tree?path=X%2FY" hx-trigger="load-folder once" hx-swap="outerHTML"`) {
+		t.Error("the closed folder below the top two levels should load on demand")
+	}
+	if strings.Contains(lazy, `href="/x/y/z/other"`) || strings.Contains(lazy, `href="/x/y/sibling"`) {
+		t.Error("the contents of a closed deep folder should not be rendered")
+	}
+	if !strings.Contains(lazy, `id="chevron-A/B">▼`) || !strings.Contains(lazy, `id="chevron-X/Y">▶`) {
+		t.Error("the chevrons should follow the open folders")
+	}
+}
+
+func TestNoteWithList_SharedSidebar(t *testing.T) {
+	notes := []model.Note{
+		{Title: "Deep", Slug: "a/b/deep", Path: "A/B/Deep.md"},
+		{Title: "Other", Slug: "other", Path: "Other.md"},
+	}
+	notesMap := make(map[string]model.Note)
+	for _, note := range notes {
+		notesMap[note.Slug] = note
+	}
+	notesService := engine.NewNotesService(&notesMap, engine.BuildTreeWithFolders(notes, nil), nil)
+	rs := NewResource(&config.Config{SiteTitle: "Pluie", PublicByDefault: true}).WithSharedSidebar("/sidebar.html")
+
+	render := func(node interface{ Render(io.Writer) error }) string {
+		var sb strings.Builder
+		if err := node.Render(&sb); err != nil {
+			t.Fatalf("Render() returned error: %v", err)
+		}
+		return sb.String()
+	}
+
+	note := notesMap["a/b/deep"]
+	result, err := rs.NoteWithList(notesService, &note, "")
+	if err != nil {
+		t.Fatalf("NoteWithList() returned error: %v", err)
+	}
+	page := render(result)
+	if !strings.Contains(page, `<div id="notes-list" hx-get="/sidebar.html" hx-trigger="load" hx-swap="outerHTML"></div>`) {
+		t.Errorf("expected the page to load the shared sidebar:\n%s", page)
+	}
+	if strings.Contains(page, `id="folder-A"`) {
+		t.Error("the page should not hold the notes tree")
+	}
+
+	// The shared tree has every note, none active
+	sidebar := render(rs.SharedSidebar(notesService))
+	if !strings.HasPrefix(sidebar, `<div id="notes-list" data-active-class="`+activeLinkClass+`">`) {
+		t.Errorf("expected the notes list with the active link class, got %s", sidebar)
+	}
+	if !strings.Contains(sidebar, `href="/a/b/deep"`) || !strings.Contains(sidebar, `href="/other"`) || strings.Contains(sidebar, `" class="`+activeLinkClass+`"`) {
+		t.Errorf("expected every note without an active one, got %s", sidebar)
+	}
+
+	// Search results are a filtered tree, rendered in the page
+	searched, err := rs.NoteWithList(notesService, &note, "other")
+	if err != nil {
+		t.Fatalf("NoteWithList() returned error: %v", err)
+	}
+	if html := render(searched); strings.Contains(html, "/sidebar.html") {
+		t.Error("the search results should not load the shared sidebar")
+	}
+}
+
+func TestTreeFolder(t *testing.T) {
+	rs := NewResource(&config.Config{SidebarLazy: true})
+	tree := engine.BuildTreeWithFolders([]model.Note{
+		{Title: "Other", Slug: "x/y/z/other", Path: "X/Y/Z/Other.md"},
+		{Title: "Sibling", Slug: "x/y/sibling", Path: "X/Y/Sibling.md"},
+	}, nil)
+
+	var sb strings.Builder
+	if err := rs.TreeFolder(engine.FindFolderByPath(tree, "X/Y")).Render(&sb); err != nil {
+		t.Fatalf("Render() returned error: %v", err)
+	}
+	html := sb.String()
+
+	if !strings.HasPrefix(html, `<ul class="ml-4" id="folder-X/Y" style="display: block;">`) {
+		t.Errorf("expected the open folder list, got %s", html)
+	}
+	if !strings.Contains(html, `href="/x/y/sibling"`) {
+		t.Error("expected the notes of the folder")
+	}
+	if strings.Contains(html, `href="/x/y/z/other"`) || !strings.Contains(html, `hx-get="/-/tree?path=X%2FY%2FZ"`) {
+		t.Error("subfolders should load on demand too")
+	}
+}
+
 func TestNoteWithList(t *testing.T) {
 	// Create a simple tree for testing
 	tree := &engine.TreeNode{
@@ -1005,6 +1204,551 @@ func TestNoteWithList(t *testing.T) {
 	}
 }
 
+func TestNoteWithList_EmptyFolder(t *testing.T) {
+	rs := testResource()
+	render := func(notesService *engine.NotesService) string {
+		result, err := rs.NoteWithList(notesService, nil, "")
+		if err != nil {
+			t.Fatalf("NoteWithList() returned error: %v", err)
+		}
+		var sb strings.Builder
+		if err := result.Render(&sb); err != nil {
+			t.Fatalf("Render() returned error: %v", err)
+		}
+		return sb.String()
+	}
+
+	emptyMap := make(map[string]model.Note)
+	empty := render(engine.NewNotesService(&emptyMap, &engine.TreeNode{Name: "root", IsFolder: true}, nil))
+	if !strings.Contains(empty, "No notes yet") || strings.Contains(empty, "404") {
+		t.Error("expected the empty-state page for a folder without notes")
+	}
+
+	notes := []model.Note{{Title: "Note", Slug: "note"}}
+	notesMap := map[string]model.Note{"note": notes[0]}
+	missing := render(engine.NewNotesService(&notesMap, engine.BuildTree(notes), nil))
+	if !strings.Contains(missing, "404") {
+		t.Error("expected the not-found page when notes exist")
+	}
+}
+
+func TestNoteWithList_Layouts(t *testing.T) {
+	notesMap := make(map[string]model.Note)
+	notesService := engine.NewNotesService(&notesMap, &engine.TreeNode{Name: "root", IsFolder: true}, nil)
+	rs := testResource()
+
+	tests := []struct {
+		layout         string
+		expectedClass  string
+		expectTOC      bool
+		expectSidebar  bool
+		expectedColumn string
+	}{
+		{layout: "", expectedClass: "layout-default", expectTOC: true, expectSidebar: true, expectedColumn: "flex-1 container overflow-y-auto"},
+		{layout: model.LayoutDefault, expectedClass: "layout-default", expectTOC: true, expectSidebar: true, expectedColumn: "flex-1 container overflow-y-auto"},
+		{layout: model.LayoutWide, expectedClass: "layout-wide", expectTOC: false, expectSidebar: true, expectedColumn: "flex-1 w-full overflow-y-auto"},
+		{layout: model.LayoutMinimal, expectedClass: "layout-minimal", expectTOC: false, expectSidebar: false, expectedColumn: "flex-1 container mx-auto overflow-y-auto"},
+	}
+
+	for _, tt := range tests {
+		t.Run("layout "+tt.layout, func(t *testing.T) {
+			note := &model.Note{
+				Title:   "Layout Note",
+				Slug:    "layout-note",
+				Content: "## Section\n\nBody.",
+				Layout:  tt.layout,
+			}
+
+			result, err := rs.NoteWithList(notesService, note, "")
+			if err != nil {
+				t.Fatalf("NoteWithList() returned error: %v", err)
+			}
+
+			var sb strings.Builder
+			if err := result.Render(&sb); err != nil {
+				t.Fatalf("Render() returned error: %v", err)
+			}
+			html := sb.String()
+
+			if !strings.Contains(html, `<body id="app" class="scroll-smooth `+pageClass+" "+tt.expectedClass+`"`) {
+				t.Errorf("expected layout class %q on the body", tt.expectedClass)
+			}
+			if strings.Count(html, tt.expectedClass) != 1 {
+				t.Errorf("expected layout class %q on the body only", tt.expectedClass)
+			}
+			if !strings.Contains(html, tt.expectedColumn) {
+				t.Errorf("expected content column class %q", tt.expectedColumn)
+			}
+			if got := strings.Contains(html, "On this page"); got != tt.expectTOC {
+				t.Errorf("TOC sidebar rendered = %v, want %v", got, tt.expectTOC)
+			}
+			if got := strings.Contains(html, `id="mobile-sidebar"`); got != tt.expectSidebar {
+				t.Errorf("notes sidebar rendered = %v, want %v", got, tt.expectSidebar)
+			}
+			if got := strings.Contains(html, `id="burger-menu"`); got != tt.expectSidebar {
+				t.Errorf("burger button rendered = %v, want %v", got, tt.expectSidebar)
+			}
+		})
+	}
+}
+
+func TestNoteWithList_SiteSettings(t *testing.T) {
+	notesMap := make(map[string]model.Note)
+	notesService := engine.NewNotesService(&notesMap, &engine.TreeNode{Name: "root", IsFolder: true}, nil)
+	note := &model.Note{
+		Title:    "Settings Note",
+		Slug:     "settings-note",
+		Content:  "Body.",
+		Metadata: map[string]any{"author": "Ada"},
+	}
+
+	tests := []struct {
+		name       string
+		cfg        config.Config
+		expected   []string
+		unexpected []string
+	}{
+		{
+			name:     "site title",
+			cfg:      config.Config{SiteTitle: "My Garden"},
+			expected: []string{"<title>Settings Note | My Garden</title>", `<meta property="og:site_name" content="My Garden">`},
+		},
+		{
+			name:     "site icon",
+			cfg:      config.Config{SiteTitle: "Pluie", SiteIcon: "/icons/garden.png"},
+			expected: []string{`src="/icons/garden.png"`, `<link rel="icon" href="/icons/garden.png"`},
+		},
+		{
+			name:     "site description",
+			cfg:      config.Config{SiteTitle: "Pluie", SiteDescription: "Notes about plants"},
+			expected: []string{">Notes about plants</"},
+		},
+		{
+			name:     "frontmatter shown",
+			cfg:      config.Config{SiteTitle: "Pluie"},
+			expected: []string{"1 properties"},
+		},
+		{
+			name:       "frontmatter hidden",
+			cfg:        config.Config{SiteTitle: "Pluie", HideYamlFrontmatter: true},
+			unexpected: []string{"1 properties"},
+		},
+		{
+			name:     "site URL",
+			cfg:      config.Config{SiteTitle: "Pluie", SiteURL: "https://garden.example.com"},
+			expected: []string{`<link rel="canonical" href="https://garden.example.com/settings-note">`},
+		},
+		{
+			name:       "no site URL",
+			cfg:        config.Config{SiteTitle: "Pluie"},
+			expected:   []string{`<link rel="canonical" href="/settings-note">`},
+			unexpected: []string{"https://garden.example.com"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := NewResource(&tt.cfg).NoteWithList(notesService, note, "")
+			if err != nil {
+				t.Fatalf("NoteWithList() returned error: %v", err)
+			}
+			var sb strings.Builder
+			if err := result.Render(&sb); err != nil {
+				t.Fatalf("Render() returned error: %v", err)
+			}
+			html := sb.String()
+
+			for _, expected := range tt.expected {
+				if !strings.Contains(html, expected) {
+					t.Errorf("expected %s in:\n%s", expected, html)
+				}
+			}
+			for _, unexpected := range tt.unexpected {
+				if strings.Contains(html, unexpected) {
+					t.Errorf("unexpected %s in:\n%s", unexpected, html)
+				}
+			}
+		})
+	}
+
+	// Pages without a note get the site settings too
+	result, err := NewResource(&config.Config{SiteTitle: "My Garden", SiteIcon: "/icons/garden.png"}).TagList(notesService, "plants", nil, false)
+	if err != nil {
+		t.Fatalf("TagList() returned error: %v", err)
+	}
+	var sb strings.Builder
+	if err := result.Render(&sb); err != nil {
+		t.Fatalf("Render() returned error: %v", err)
+	}
+	if html := sb.String(); !strings.Contains(html, "<title>My Garden</title>") || !strings.Contains(html, `src="/icons/garden.png"`) {
+		t.Errorf("expected the site title and icon on the tag page:\n%s", html)
+	}
+}
+
+func TestNoteWithList_PrevNext(t *testing.T) {
+	notes := []model.Note{
+		{Title: "Introduction", Slug: "guide/introduction", Path: "guide/introduction.md", Metadata: map[string]any{"order": 1}},
+		{Title: "Appendix", Slug: "guide/appendix", Path: "guide/appendix.md"},
+		{Title: "Setup", Slug: "guide/setup", Path: "guide/setup.md", Metadata: map[string]any{"order": 2}},
+		{Title: "Overview", Slug: "overview", Path: "overview.md"},
+	}
+	notesMap := make(map[string]model.Note)
+	for _, note := range notes {
+		notesMap[note.Slug] = note
+	}
+	notesService := engine.NewNotesService(&notesMap, engine.BuildTree(notes), nil)
+	rs := testResource()
+	rs.cfg.PublicByDefault = true
+
+	searchQuery := ""
+	render := func(note model.Note) string {
+		result, err := rs.NoteWithList(notesService, &note, searchQuery)
+		if err != nil {
+			t.Fatalf("NoteWithList() returned error: %v", err)
+		}
+		var sb strings.Builder
+		if err := result.Render(&sb); err != nil {
+			t.Fatalf("Render() returned error: %v", err)
+		}
+		return sb.String()
+	}
+
+	html := render(notes[2])
+	prevNext := html[strings.Index(html, `id="prev-next"`):]
+	prevNext = prevNext[:strings.Index(prevNext, "</nav>")]
+	if !strings.Contains(prevNext, `href="/guide/introduction"`) || !strings.Contains(prevNext, `href="/guide/appendix"`) {
+		t.Error("expected links to the previous and next notes in the folder order")
+	}
+
+	if html := render(notes[0]); strings.Contains(html, "← Previous") || !strings.Contains(html, "Next →") {
+		t.Error("the first note should only link to the next note")
+	}
+
+	// The navigation goes on past the folder, even when a search hides the note from the sidebar
+	searchQuery = "setup"
+	html = render(notes[1])
+	prevNext = html[strings.Index(html, `id="prev-next"`):]
+	if !strings.Contains(prevNext, `href="/overview"`) {
+		t.Error("the last note of a folder should link to the next note of the sidebar")
+	}
+}
+
+func TestNoteWithList_Breadcrumbs(t *testing.T) {
+	notes := []model.Note{
+		{Title: "Kickoff", Slug: "projects/client-x/kickoff", Path: "Projects/Client X/Kickoff.md"},
+		{Title: "Index", Slug: "index", Path: "Index.md"},
+	}
+	notesMap := make(map[string]model.Note)
+	for _, note := range notes {
+		notesMap[note.Slug] = note
+	}
+	notesService := engine.NewNotesService(&notesMap, engine.BuildTree(notes), nil)
+	rs := testResource()
+
+	render := func(note model.Note) string {
+		result, err := rs.NoteWithList(notesService, &note, "")
+		if err != nil {
+			t.Fatalf("NoteWithList() returned error: %v", err)
+		}
+		var sb strings.Builder
+		if err := result.Render(&sb); err != nil {
+			t.Fatalf("Render() returned error: %v", err)
+		}
+		return sb.String()
+	}
+
+	html := render(notes[0])
+	start := strings.Index(html, `id="breadcrumbs"`)
+	if start == -1 {
+		t.Fatal("expected breadcrumbs for a note in a folder")
+	}
+	breadcrumbs := html[start:]
+	breadcrumbs = breadcrumbs[:strings.Index(breadcrumbs, "</nav>")]
+	for _, expected := range []string{
+		`href="/projects"`,
+		`href="/projects/client-x"`,
+		">Client X</a>",
+	} {
+		if !strings.Contains(breadcrumbs, expected) {
+			t.Errorf("expected breadcrumbs to contain %q, got %s", expected, breadcrumbs)
+		}
+	}
+	if strings.Contains(breadcrumbs, "Kickoff") {
+		t.Error("the note itself should not be in its breadcrumbs")
+	}
+	if strings.Index(html, `id="breadcrumbs"`) > strings.Index(html, `<h1 class="text-3xl`) {
+		t.Error("expected the breadcrumbs above the title")
+	}
+
+	if html := render(notes[1]); strings.Contains(html, `id="breadcrumbs"`) {
+		t.Error("notes at the root should have no breadcrumbs")
+	}
+}
+
+func TestNoteWithList_ReadingStats(t *testing.T) {
+	note := model.Note{Title: "Essay", Slug: "essay", Content: strings.Repeat("word ", 1240) + "\n```\nnot counted\n```"}
+	notesMap := map[string]model.Note{note.Slug: note}
+	notesService := engine.NewNotesService(&notesMap, engine.BuildTree([]model.Note{note}), nil)
+	rs := testResource()
+
+	render := func() string {
+		result, err := rs.NoteWithList(notesService, &note, "")
+		if err != nil {
+			t.Fatalf("NoteWithList() returned error: %v", err)
+		}
+		var sb strings.Builder
+		if err := result.Render(&sb); err != nil {
+			t.Fatalf("Render() returned error: %v", err)
+		}
+		return sb.String()
+	}
+
+	// Without READING_WPM, the default rate of 200 words per minute
+	if html := render(); !strings.Contains(html, "1,240 words · 6 min read") {
+		t.Error("expected the word count and the reading time under the title")
+	}
+
+	rs.cfg.ReadingWPM = 100
+	if html := render(); !strings.Contains(html, "1,240 words · 12 min read") {
+		t.Error("expected the reading time at the configured rate")
+	}
+
+	note.Content = ""
+	if html := render(); strings.Contains(html, `id="reading-stats"`) {
+		t.Error("empty notes should have no reading stats")
+	}
+}
+
+func TestNoteWithList_LastCommit(t *testing.T) {
+	note := model.Note{Title: "Essay", Slug: "essay", Content: "Hello", LastCommitHash: "8ea8e2772c7f5880c53946e929c3ad67562f43f7", LastCommitDate: time.Date(2024, 6, 2, 10, 0, 0, 0, time.UTC)}
+	notesMap := map[string]model.Note{note.Slug: note}
+	notesService := engine.NewNotesService(&notesMap, engine.BuildTree([]model.Note{note}), nil)
+	rs := testResource()
+
+	render := func() string {
+		result, err := rs.NoteWithList(notesService, &note, "")
+		if err != nil {
+			t.Fatalf("NoteWithList() returned error: %v", err)
+		}
+		var sb strings.Builder
+		if err := result.Render(&sb); err != nil {
+			t.Fatalf("Render() returned error: %v", err)
+		}
+		return sb.String()
+	}
+
+	html := render()
+	if !strings.Contains(html, `Last updated on <time datetime="2024-06-02T10:00:00Z">Jun 2, 2024</time>`) {
+		t.Error("expected the date of the last commit under the title")
+	}
+	if strings.Contains(html, "/commit/") {
+		t.Error("no commit link without GIT_WEB_URL")
+	}
+
+	rs.cfg.GitWebURL = "https://github.com/me/vault/commit/{hash}"
+	if html := render(); !strings.Contains(html, `href="https://github.com/me/vault/commit/8ea8e2772c7f5880c53946e929c3ad67562f43f7"`) {
+		t.Error("expected the date linked to the commit page")
+	}
+
+	note.LastCommitHash = ""
+	if html := render(); strings.Contains(html, `id="last-updated"`) {
+		t.Error("notes outside a git repository should have no last updated date")
+	}
+}
+
+func TestFormatThousands(t *testing.T) {
+	for n, expected := range map[int]string{0: "0", 999: "999", 1000: "1,000", 1240: "1,240", 1234567: "1,234,567"} {
+		if got := formatThousands(n); got != expected {
+			t.Errorf("formatThousands(%d) = %q, want %q", n, got, expected)
+		}
+	}
+}
+
+func TestNoteWithList_LocalGraph(t *testing.T) {
+	notes := engine.BuildBackreferences([]model.Note{
+		{Title: "Home", Slug: "home", IsPublic: true, Content: "See [[Garden]] and [[Nowhere]]", Metadata: map[string]any{"up": "[[Index]]"}},
+		{Title: "Garden", Slug: "garden", IsPublic: true},
+		{Title: "Index", Slug: "index", IsPublic: true},
+		{Title: "Journal", Slug: "journal", IsPublic: true, Content: "Back [[Home]]"},
+		{Title: "Lonely", Slug: "lonely", IsPublic: true},
+	})
+	notesMap := make(map[string]model.Note)
+	for _, note := range notes {
+		notesMap[note.Slug] = note
+	}
+	notesService := engine.NewNotesService(&notesMap, engine.BuildTree(notes), nil)
+	rs := testResource()
+
+	render := func(note model.Note) string {
+		result, err := rs.NoteWithList(notesService, &note, "")
+		if err != nil {
+			t.Fatalf("NoteWithList() returned error: %v", err)
+		}
+		var sb strings.Builder
+		if err := result.Render(&sb); err != nil {
+			t.Fatalf("Render() returned error: %v", err)
+		}
+		return sb.String()
+	}
+
+	html := render(notes[0])
+	start := strings.Index(html, `id="local-graph"`)
+	if start < 0 {
+		t.Fatal("expected the connections panel")
+	}
+	panel := html[start:]
+	for _, expected := range []string{`href="/garden"`, `href="/index"`, `href="/journal"`, "Nowhere", `class="flex items-center gap-1 py-1 px-2 text-sm rounded-md broken-link`} {
+		if !strings.Contains(panel, expected) {
+			t.Errorf("connections panel should contain %q", expected)
+		}
+	}
+	if strings.Index(panel, `href="/garden"`) > strings.Index(panel, `href="/journal"`) {
+		t.Error("outgoing links should come before the backlinks")
+	}
+
+	if html := render(notes[4]); strings.Contains(html, `id="local-graph"`) {
+		t.Error("a note without links should have no connections panel")
+	}
+}
+
+func TestSetHeadingIDs(t *testing.T) {
+	rendered := `<h2 id="setup">Setup</h2><p>x</p><h2 id="setup-1">Setup!</h2><h3 id="été-amp-co">Été &amp; <em>co</em></h3>`
+	expected := `<h2 id="setup">Setup</h2><p>x</p><h2 id="setup-2">Setup!</h2><h3 id="t-co">Été &amp; <em>co</em></h3>`
+	if got := setHeadingIDs(rendered); got != expected {
+		t.Errorf("setHeadingIDs() = %q, want %q", got, expected)
+	}
+}
+
+func TestNoteWithList_HeadingAnchors(t *testing.T) {
+	rs := testResource()
+	note := model.Note{Title: "Guide", Slug: "guide", Content: "[[#Setup!]]\n\n## Setup\n\n```sh\n# not a heading\n```\n\n## Setup!"}
+	notesMap := map[string]model.Note{note.Slug: note}
+	notesService := engine.NewNotesService(&notesMap, buildTestTree([]model.Note{note}), nil)
+
+	page, err := rs.NoteWithList(notesService, &note, "")
+	if err != nil {
+		t.Fatalf("NoteWithList() returned error: %v", err)
+	}
+	var sb strings.Builder
+	if err := page.Render(&sb); err != nil {
+		t.Fatalf("Render() returned error: %v", err)
+	}
+	html := sb.String()
+
+	// The section link, the table of contents and the heading agree on the anchor
+	for _, expected := range []string{`<a href="#setup-2" class="internal-section">Setup!</a>`, `href="#setup-2"`, `<h2 id="setup-2">Setup!<a class="heading-anchor" href="#setup-2"`} {
+		if !strings.Contains(html, expected) {
+			t.Errorf("expected the note page to contain %s", expected)
+		}
+	}
+	if strings.Contains(html, "not-a-heading") {
+		t.Error("comments of code blocks are not headings")
+	}
+}
+
+func TestNoteWithList_TOCAnchorsUnique(t *testing.T) {
+	notes := []model.Note{
+		{Title: "Guide", Slug: "guide", Path: "Guide.md", Content: "## Setup\n\n> [!TIP]\n> ## Setup\n\n## Install\n\n![[Other]]\n\n## Setup"},
+		{Title: "Other", Slug: "other", Path: "Other.md", Content: "## Setup\n\n## Install"},
+	}
+	notesMap := map[string]model.Note{"guide": notes[0], "other": notes[1]}
+	notesService := engine.NewNotesService(&notesMap, engine.BuildTreeWithFolders(notes, nil), nil)
+
+	note := notesMap["guide"]
+	page, err := testResource().NoteWithList(notesService, &note, "")
+	if err != nil {
+		t.Fatalf("NoteWithList() returned error: %v", err)
+	}
+	var sb strings.Builder
+	if err := page.Render(&sb); err != nil {
+		t.Fatalf("Render() returned error: %v", err)
+	}
+	html := sb.String()
+
+	toc := renderNoteContent(notesService, &note, note.Content, note.Attachments, note.Slug).TOC
+	if len(toc) != 4 {
+		t.Fatalf("expected the 4 headings of the note in the table of contents, got %v", toc)
+	}
+	// Each entry of the table of contents leads to exactly one heading, the embedded ones have no id
+	for _, item := range toc {
+		if count := strings.Count(html, `id="`+item.ID+`"`); count != 1 {
+			t.Errorf("id %q is in the page %d times, want once", item.ID, count)
+		}
+		if !strings.Contains(html, `href="#`+item.ID+`" class="block`) {
+			t.Errorf("the table of contents should link to #%s", item.ID)
+		}
+	}
+	if strings.Contains(html, "handleTOCClick") {
+		t.Error("the table of contents should not use inline scripts")
+	}
+}
+
+func TestNoteWithList_EmbedsTOC(t *testing.T) {
+	notes := []model.Note{
+		{Title: "Host", Slug: "host", Path: "Host.md", Content: "## Intro\n\nOne two three.\n\n![[Embedded]]\n\n![[Embedded#Rollback]]\n\n## After"},
+		{Title: "Embedded", Slug: "embedded", Path: "Embedded.md", Content: "## Deployment Checklist\n\nSteps to deploy the whole service.\n\n## Rollback\n\n### Rollback Steps"},
+	}
+	notesMap := map[string]model.Note{"host": notes[0], "embedded": notes[1]}
+	notesService := engine.NewNotesService(&notesMap, engine.BuildTreeWithFolders(notes, nil), nil)
+	note := notesMap["host"]
+
+	render := func(rs Resource) string {
+		t.Helper()
+		page, err := rs.NoteWithList(notesService, &note, "")
+		if err != nil {
+			t.Fatalf("NoteWithList() returned error: %v", err)
+		}
+		var sb strings.Builder
+		if err := page.Render(&sb); err != nil {
+			t.Fatalf("Render() returned error: %v", err)
+		}
+		return sb.String()
+	}
+
+	t.Run("default", func(t *testing.T) {
+		html := render(testResource())
+		if !strings.Contains(html, "Steps to deploy the whole service.") {
+			t.Fatal("the page should show the embedded note")
+		}
+		if strings.Contains(html, "toc-embedded") {
+			t.Error("the table of contents should not list the embedded headings by default")
+		}
+		// Intro, One, two, three, After: the words of the embedded note are on its own page
+		if !strings.Contains(html, ">5 words · 1 min read<") {
+			t.Error("the word count should not include the embedded note")
+		}
+	})
+
+	t.Run("with embeds", func(t *testing.T) {
+		rs := testResource()
+		rs.cfg.TOCEmbeds = true
+		html := render(rs)
+
+		toc := tocWithEmbeds(notesService, renderNoteContent(notesService, &note, note.Content, note.Attachments, note.Slug).Source, note.Slug)
+		expected := []TOCItem{
+			{ID: "intro", Text: "Intro", Level: 2},
+			{ID: "deployment-checklist", Text: "Deployment Checklist", Level: 2, Source: "embedded"},
+			{ID: "rollback", Text: "Rollback", Level: 2, Source: "embedded"},
+			{ID: "rollback-steps", Text: "Rollback Steps", Level: 3, Source: "embedded"},
+			{ID: "rollback", Text: "Rollback", Level: 2, Source: "embedded"},
+			{ID: "rollback-steps", Text: "Rollback Steps", Level: 3, Source: "embedded"},
+			{ID: "after", Text: "After", Level: 2},
+		}
+		if !reflect.DeepEqual(toc, expected) {
+			t.Fatalf("tocWithEmbeds() = %+v, want %+v", toc, expected)
+		}
+		if !strings.Contains(html, `href="/embedded#deployment-checklist" class="toc-embedded`) {
+			t.Error("the embedded headings should link to the page of their note, marked as embedded")
+		}
+		if strings.Contains(html, `href="#deployment-checklist"`) || strings.Contains(html, `id="deployment-checklist"`) {
+			t.Error("the embedded headings should have no anchor on the page")
+		}
+		if !strings.Contains(html, `href="#intro" class="block`) {
+			t.Error("the headings of the note should still link to their anchor")
+		}
+	})
+}
+
 func TestExtractHeadings(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -1056,6 +1800,18 @@ func TestExtractHeadings(t *testing.T) {
 			input:    "",
 			expected: []TOCItem{},
 		},
+		{
+			name: "Headings in callouts and formatted headings",
+			input: `## Setup
+> [!NOTE]
+> ## Setup
+## *Setup* [again](/again)`,
+			expected: []TOCItem{
+				{ID: "setup", Text: "Setup", Level: 2},
+				{ID: "setup-2", Text: "Setup", Level: 2},
+				{ID: "setup-again", Text: "Setup again", Level: 2},
+			},
+		},
 		{
 			name: "Headings with special characters",
 			input: `# API & SDK Guide
@@ -1071,7 +1827,7 @@ func TestExtractHeadings(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := extractHeadings(tt.input)
+			result := extractHeadings(setHeadingIDs(string(markdown.Markdown(tt.input))))
 
 			if len(result) != len(tt.expected) {
 				t.Errorf("extractHeadings() returned %d items, want %d", len(result), len(tt.expected))
@@ -1088,3 +1844,69 @@ func TestExtractHeadings(t *testing.T) {
 		})
 	}
 }
+
+func TestNoteWithList_RenderCache(t *testing.T) {
+	note := model.Note{Title: "Garden", Slug: "garden", IsPublic: true, Content: "# Plan\n\nSee [[Tomatoes]]."}
+	notesMap := map[string]model.Note{note.Slug: note}
+	notesService := engine.NewNotesService(&notesMap, buildTestTree([]model.Note{note}), nil)
+
+	render := func() string {
+		var sb strings.Builder
+		node, err := testResource().NoteWithList(notesService, &note, "")
+		if err != nil {
+			t.Fatalf("NoteWithList() returned error: %v", err)
+		}
+		if err := node.Render(&sb); err != nil {
+			t.Fatalf("Render() returned error: %v", err)
+		}
+		return sb.String()
+	}
+
+	first := render()
+	if second := render(); second != first {
+		t.Error("the cached render should give the same page")
+	}
+	if !strings.Contains(first, `href="#plan"`) {
+		t.Errorf("the cached table of contents should be rendered:\n%s", first)
+	}
+
+	// The linked note now exists: the page is rendered again with the new notes
+	tomatoes := model.Note{Title: "Tomatoes", Slug: "tomatoes", IsPublic: true}
+	notesMap[tomatoes.Slug] = tomatoes
+	notesService.UpdateData(&notesMap, buildTestTree([]model.Note{note, tomatoes}), nil)
+	if page := render(); page == first || !strings.Contains(page, `href="/tomatoes"`) {
+		t.Errorf("a reload should render the note again:\n%s", page)
+	}
+}
+
+func BenchmarkNoteWithList(b *testing.B) {
+	var content strings.Builder
+	notes := []model.Note{}
+	for i := range 200 {
+		notes = append(notes, model.Note{Title: fmt.Sprintf("Note %d", i), Slug: fmt.Sprintf("note-%d", i), IsPublic: true})
+		fmt.Fprintf(&content, "## Section %d\n\n> [!note] Callout\n> See [[Note %d]] and #tag%d, with **bold** and `code`.\n\n- [ ] Task %d\n\n", i, i, i, i)
+	}
+	note := model.Note{Title: "Big", Slug: "big", IsPublic: true, Content: content.String()}
+	notes = append(notes, note)
+
+	notesMap := make(map[string]model.Note)
+	for _, n := range notes {
+		notesMap[n.Slug] = n
+	}
+	rs := testResource()
+
+	for _, size := range []int{0, engine.DefaultRenderCacheSize} {
+		name := "uncached"
+		if size > 0 {
+			name = "cached"
+		}
+		b.Run(name, func(b *testing.B) {
+			notesService := engine.NewNotesService(&notesMap, buildTestTree(notes), nil)
+			notesService.SetRenderCacheSize(size)
+			for b.Loop() {
+				node, _ := rs.NoteWithList(notesService, &note, "")
+				_ = node.Render(io.Discard)
+			}
+		})
+	}
+}
@@ -47,10 +47,11 @@ func buildTestTree(notes []model.Note) *engine.TreeNode {
 func TestParseWikiLinks(t *testing.T) {
 	// Create sample notes for testing
 	notes := []model.Note{
-		{Title: "Test Note", Slug: "test-note"},
+		{Title: "Test Note", Slug: "test-note", Content: "## Getting Started\n\n## Setup\n\n## Setup!"},
 		{Title: "Another Note", Slug: "another-note"},
 		{Title: "Special Characters & Symbols", Slug: "special-characters-symbols"},
 		{Title: "articles/Hello World", Slug: "articles/hello-world"},
+		{Title: "C# Tips", Slug: "c-tips"},
 	}
 
 	// Build tree from notes
@@ -143,7 +144,17 @@ func TestParseWikiLinks(t *testing.T) {
 		{
 			name:     "Wiki link with whitespace",
 			input:    "This [[ Test Note ]] has extra spaces.",
-			expected: "This  Test Note  has extra spaces.",
+			expected: "This [Test Note](/test-note) has extra spaces.",
+		},
+		{
+			name:     "Wiki link in another case",
+			input:    "See [[test note]] and [[TEST NOTE|the note]].",
+			expected: "See [test note](/test-note) and [the note](/test-note).",
+		},
+		{
+			name:     "Wiki link with several spaces and the extension",
+			input:    "See [[Test   Note]] and [[Test Note.md]].",
+			expected: "See [Test   Note](/test-note) and [Test Note.md](/test-note).",
 		},
 		{
 			name:     "Complex markdown with wiki links",
@@ -206,6 +217,51 @@ func TestParseWikiLinks(t *testing.T) {
 			input:    "See [[articles/Hello World|My Article]].",
 			expected: "See [My Article](/articles/hello-world).",
 		},
+		{
+			name:     "Link to a section of another note",
+			input:    "See [[Test Note#Getting Started]].",
+			expected: "See [Test Note > Getting Started](/test-note#getting-started).",
+		},
+		{
+			name:     "Link to a section with custom display name",
+			input:    "See [[Test Note#Getting Started|the intro]].",
+			expected: "See [the intro](/test-note#getting-started).",
+		},
+		{
+			name:     "Link to a section of the current note",
+			input:    "See [[#Getting Started]] below.",
+			expected: "See [Getting Started](#getting-started) below.",
+		},
+		{
+			name:     "Link to a heading with a duplicate anchor",
+			input:    "See [[Test Note#Setup!]].",
+			expected: "See [Test Note > Setup!](/test-note#setup-2).",
+		},
+		{
+			name:     "Link to a missing section links to the note",
+			input:    "See [[Test Note#Nowhere]].",
+			expected: "See [Test Note > Nowhere](/test-note).",
+		},
+		{
+			name:     "Block reference links to the note",
+			input:    "See [[Test Note#^a1b2c3]] and [[Test Note#^a1b2c3|this quote]].",
+			expected: "See [Test Note](/test-note) and [this quote](/test-note).",
+		},
+		{
+			name:     "Link to a section of a non-existent note",
+			input:    "See [[Missing#Intro]].",
+			expected: "See Missing > Intro.",
+		},
+		{
+			name:     "Link to a note with an empty heading",
+			input:    "See [[Test Note#]].",
+			expected: "See [Test Note](/test-note).",
+		},
+		{
+			name:     "Note title containing a hash",
+			input:    "See [[C# Tips]].",
+			expected: "See [C# Tips](/c-tips).",
+		},
 	}
 
 	for _, tt := range tests {
@@ -351,7 +407,7 @@ func TestProcessMarkdownLinks(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := engine.ProcessMarkdownLinks(tt.input)
+			result := engine.ProcessMarkdownLinks(tt.input, nil)
 			if result != tt.expected {
 				t.Errorf("ProcessMarkdownLinks() = %q, want %q", result, tt.expected)
 			}
@@ -382,3 +438,28 @@ func BenchmarkParseWikiLinks(b *testing.B) {
 		notesService.ParseWikiLinks(content)
 	}
 }
+
+// BenchmarkParseWikiLinks_5000Notes is BenchmarkParseWikiLinks in a vault of 5,000 notes
+func BenchmarkParseWikiLinks_5000Notes(b *testing.B) {
+	notes := []model.Note{
+		{Title: "Test Note", Slug: "test-note", Path: "Test Note.md"},
+		{Title: "Another Note", Slug: "another-note", Path: "Another Note.md"},
+	}
+	for i := range 4998 {
+		title := fmt.Sprintf("Note %d", i)
+		notes = append(notes, model.Note{Title: title, Slug: fmt.Sprintf("folder-%d/note-%d", i%50, i), Path: fmt.Sprintf("folder-%d/%s.md", i%50, title)})
+	}
+
+	notesMap := make(map[string]model.Note)
+	for _, note := range notes {
+		notesMap[note.Slug] = note
+	}
+
+	notesService := engine.NewNotesService(&notesMap, engine.BuildTreeWithFolders(notes, nil), nil)
+
+	content := "This is a [[Test Note]] with [[Another Note]] and some [[Missing Link]] content."
+
+	for b.Loop() {
+		notesService.ParseWikiLinks(content)
+	}
+}
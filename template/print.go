@@ -0,0 +1,91 @@
+package template
+
+import (
+	"github.com/EwenQuim/pluie/engine"
+	"github.com/EwenQuim/pluie/model"
+	"github.com/EwenQuim/pluie/static"
+	g "github.com/maragudk/gomponents"
+	. "github.com/maragudk/gomponents/html"
+)
+
+// printStyle hides the print button on paper and starts the sections on a new page
+const printStyle = `@media print {` +
+	`.no-print { display: none !important; }` +
+	`body { font-size: 11pt; }` +
+	`#print-content h1, #print-content h2 { break-before: page; }` +
+	`#print-content > :first-child { break-before: avoid; }` +
+	`h1, h2, h3, h4 { break-after: avoid; }` +
+	`pre, blockquote, table, figure, img { break-inside: avoid; }` +
+	`a { color: inherit; text-decoration: none; }` +
+	`}`
+
+// printURL returns the URL of the print view of the note at slug: the print.html page written
+// next to the note by static generation, the print query parameter on a server
+func (rs Resource) printURL(slug string) string {
+	if rs.cfg.Mode == "static" || rs.cfg.Mode == "export" {
+		return "/" + slug + "/print.html"
+	}
+	return "/" + slug + "?print=1"
+}
+
+// NotePrintView renders a note for printing or saving as PDF: the content of the note page,
+// with its properties expanded, but no navbar, sidebars, table of contents or backlinks
+func (rs Resource) NotePrintView(notesService *engine.NotesService, note *model.Note) (g.Node, error) {
+	matter := engine.ParseTagLinksInMetadata(notesService.ParseWikiLinksInMetadata(note.Metadata))
+
+	noteHTML := renderNoteHTML(notesService, prepareNoteContent(notesService, note.Content, note.Attachments), note.Slug)
+	hasDiagrams := false
+	if rs.cfg.Mermaid {
+		noteHTML, hasDiagrams = renderMermaidBlocks(noteHTML)
+	}
+
+	return HTML(
+		Lang("en"),
+		Head(
+			Meta(Charset("utf-8")),
+			Meta(Name("viewport"), Content("width=device-width, initial-scale=1")),
+			Meta(Name("robots"), Content("noindex")),
+			TitleEl(g.Textf("%s - %s", note.Title, rs.cfg.SiteTitle)),
+			Link(Rel("canonical"), Href(absoluteURL(rs.cfg.SiteURL, "/"+note.Slug))),
+			Link(Rel("stylesheet"), Type("text/css"), Href(static.URL("tailwind.min.css"))),
+			StyleEl(g.Raw(printStyle)),
+		),
+		Body(
+			Class("bg-white text-gray-900"),
+			Article(
+				ID("print-view"),
+				Class("max-w-3xl mx-auto p-8"),
+				Div(
+					Class("no-print flex justify-end gap-4 mb-6 text-sm"),
+					A(Href("/"+note.Slug), Class(textLinkClass), g.Text("Back to the note")),
+					Button(
+						Type("button"),
+						Class(secondaryButtonClass),
+						g.Attr("onclick", "window.print()"),
+						g.Text("Print"),
+					),
+				),
+				H1(
+					Class("text-3xl font-bold mb-2"),
+					g.Text(note.Title),
+				),
+				renderReadingStats(engine.ReadingStats(note.Content, rs.cfg.ReadingWPM)),
+				g.If(len(matter) > 0 && !rs.cfg.HideYamlFrontmatter,
+					Dl(
+						ID("print-properties"),
+						Class("grid grid-cols-1 mb-6 border border-gray-200 rounded-lg"),
+						g.Group(MapMapSorted(matter, func(key string, value any) g.Node {
+							return rs.renderYamlProperty(key, value)
+						})),
+					),
+				),
+				Div(
+					ID("print-content"),
+					Class("prose max-w-none"),
+					g.Raw(noteHTML),
+				),
+				g.If(hasDiagrams, mermaidScript()),
+			),
+		),
+	), nil
+}
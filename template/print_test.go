@@ -0,0 +1,62 @@
+package template
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/EwenQuim/pluie/engine"
+	"github.com/EwenQuim/pluie/model"
+)
+
+func TestNotePrintView(t *testing.T) {
+	notes := []model.Note{
+		{Title: "Report", Slug: "report", Content: "Intro with [[Other]] and #project.\n\n## Results\n\n> [!note] Callout\n> Inside.\n", Metadata: map[string]any{"author": "Ada"}},
+		{Title: "Other", Slug: "other"},
+	}
+	notesMap := map[string]model.Note{"report": notes[0], "other": notes[1]}
+	notesService := engine.NewNotesService(&notesMap, engine.BuildTree(notes), nil)
+
+	result, err := testResource().NotePrintView(notesService, &notes[0])
+	if err != nil {
+		t.Fatalf("NotePrintView() returned error: %v", err)
+	}
+	var sb strings.Builder
+	if err := result.Render(&sb); err != nil {
+		t.Fatalf("Render() returned error: %v", err)
+	}
+	html := sb.String()
+
+	for _, expected := range []string{
+		`id="print-view"`,
+		">Report</h1>",
+		`id="print-properties"`, "author", "Ada",
+		`href="/other"`,
+		`href="/-/tag/project"`,
+		`id="results"`,
+		"Inside.",
+		"break-before: page",
+		`onclick="window.print()"`,
+		`<meta name="robots" content="noindex">`,
+		`<link rel="canonical" href="/report">`,
+	} {
+		if !strings.Contains(html, expected) {
+			t.Errorf("expected %q in the print view", expected)
+		}
+	}
+	for _, unexpected := range []string{"[!note]", `id="toc-sidebar"`, `id="yaml-toggle-btn"`, "<nav"} {
+		if strings.Contains(html, unexpected) {
+			t.Errorf("print view should not contain %q", unexpected)
+		}
+	}
+}
+
+func TestPrintURL(t *testing.T) {
+	rs := testResource()
+	if got := rs.printURL("notes/report"); got != "/notes/report?print=1" {
+		t.Errorf("server printURL() = %q", got)
+	}
+	rs.cfg.Mode = "static"
+	if got := rs.printURL("notes/report"); got != "/notes/report/print.html" {
+		t.Errorf("static printURL() = %q", got)
+	}
+}
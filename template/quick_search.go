@@ -0,0 +1,103 @@
+package template
+
+import (
+	"strings"
+
+	"github.com/EwenQuim/pluie/engine"
+	"github.com/EwenQuim/pluie/model"
+	g "github.com/maragudk/gomponents"
+	. "github.com/maragudk/gomponents/html"
+)
+
+const quickSearchItemClass = "quick-search-item block px-3 py-2 text-sm text-gray-700 hover:bg-gray-50 aria-selected:bg-purple-50 aria-selected:text-purple-700 dark:text-gray-300 dark:hover:bg-gray-800 dark:aria-selected:bg-purple-950 dark:aria-selected:text-purple-300"
+
+// renderQuickSearch renders the search input of the sidebar, listing the best matches below it
+// as you type, see QuickSearchResults. Enter without a selected match opens the search page.
+// Static sites have no server to search: a link to their search page.
+func (rs Resource) renderQuickSearch() g.Node {
+	if rs.cfg.Mode == "static" {
+		return A(
+			Href("/-/search"),
+			Class("w-full inline-flex border border-gray-300 items-center gap-2 px-3 py-2 text-sm text-gray-700 hover:text-gray-900 hover:bg-gray-50 rounded-md transition-colors dark:border-gray-600 dark:text-gray-300 dark:hover:text-gray-100 dark:hover:bg-gray-800"),
+			g.Attr("hx-boost", "true"),
+			Span(g.Text("🔍")),
+			g.Text("Search (c+K)"),
+		)
+	}
+	return Form(
+		Class("relative"),
+		Action("/-/search"),
+		Method("get"),
+		g.Attr("role", "search"),
+		Input(
+			ID("quick-search-input"),
+			Type("search"),
+			Name("q"),
+			Placeholder("🔍 Search (c+K)"),
+			AutoComplete("off"),
+			Class("w-full px-3 py-2 text-sm border border-gray-300 rounded-md focus:outline-none focus:ring-2 focus:ring-purple-500 dark:bg-gray-800 dark:border-gray-600 dark:text-gray-100"),
+			g.Attr("role", "combobox"),
+			g.Attr("aria-autocomplete", "list"),
+			g.Attr("aria-controls", "quick-search-results"),
+			g.Attr("aria-expanded", "false"),
+			g.Attr("hx-get", "/-/quick-search"),
+			g.Attr("hx-trigger", "input changed delay:150ms, search"),
+			g.Attr("hx-target", "#quick-search-results"),
+			g.Attr("hx-swap", "innerHTML"),
+			g.Attr("hx-sync", "this:replace"),
+			g.Attr("onkeydown", "handleQuickSearchKey(event)"),
+		),
+		Div(
+			ID("quick-search-results"),
+			Class("absolute left-0 right-0 mt-1 z-50 bg-white border border-gray-200 rounded-md shadow-lg overflow-hidden dark:bg-gray-900 dark:border-gray-700"),
+			g.Attr("role", "listbox"),
+			g.Attr("hidden", ""),
+		),
+	)
+}
+
+// QuickSearchResults renders the matches of the sidebar search: the notes by title, then the
+// headings of other notes. Nothing when there is no query.
+func (rs Resource) QuickSearchResults(query string, titleMatches []model.Note, headingMatches []engine.HeadingMatch) g.Node {
+	if query == "" {
+		return g.Group(nil)
+	}
+	if len(titleMatches) == 0 && len(headingMatches) == 0 {
+		return P(
+			Class("px-3 py-2 "+emptyStateClass),
+			g.Text("No notes found matching \""+query+"\""),
+		)
+	}
+
+	return g.Group([]g.Node{
+		g.Group(g.Map(titleMatches, func(note model.Note) g.Node {
+			return A(
+				Href("/"+note.Slug),
+				Class(quickSearchItemClass),
+				g.Attr("role", "option"),
+				g.Attr("aria-selected", "false"),
+				g.Attr("hx-boost", "true"),
+				Div(Class("font-medium"), g.Text(note.Title)),
+				// The folders tell notes of the same title apart
+				g.If(strings.Contains(note.Slug, "/"),
+					Div(Class("text-xs text-gray-500 truncate dark:text-gray-400"), g.Text(note.Slug)),
+				),
+			)
+		})),
+		g.Group(g.Map(headingMatches, func(match engine.HeadingMatch) g.Node {
+			href := "/" + match.Note.Slug
+			if anchor, ok := engine.HeadingAnchor(match.Note.Content, match.Heading); ok {
+				href += "#" + anchor
+			}
+			return A(
+				Href(href),
+				Class(quickSearchItemClass),
+				g.Attr("role", "option"),
+				g.Attr("aria-selected", "false"),
+				g.Attr("hx-boost", "true"),
+				Div(Class("font-medium"), g.Text(match.Heading)),
+				Div(Class("text-xs text-gray-500 truncate dark:text-gray-400"), g.Text(match.Note.Title)),
+			)
+		})),
+	})
+}
@@ -0,0 +1,50 @@
+package template
+
+import (
+	"encoding/json"
+
+	"github.com/EwenQuim/pluie/engine"
+	g "github.com/maragudk/gomponents"
+	. "github.com/maragudk/gomponents/html"
+)
+
+// randomNote is a note the static random page can pick
+type randomNote struct {
+	Slug string   `json:"slug"`
+	Tags []string `json:"tags"`
+}
+
+// StaticRandom renders the /-/random page of static sites: the public notes with their tags, a
+// random one opened by openStaticRandomNote in static/app.js, among the ?tag= ones when given
+func (rs Resource) StaticRandom(notesService *engine.NotesService) (g.Node, error) {
+	notes := []randomNote{}
+	for _, note := range notesService.GetVisibleNotes(rs.cfg.PublicByDefault) {
+		notes = append(notes, randomNote{Slug: note.Slug, Tags: engine.NoteTags(note)})
+	}
+	// Escapes <, > and &, so the list cannot close the script element
+	data, err := json.Marshal(notes)
+	if err != nil {
+		return nil, err
+	}
+
+	return rs.Layout(
+		nil, // No specific note for layout
+		rs.renderWithNavbar(notesService, navbarConfig{
+			mainContent: Div(
+				Class("flex-1 container overflow-y-auto p-4 md:px-8"),
+				H1(
+					Class("text-3xl md:text-4xl font-bold mb-4 mt-2"),
+					g.Text("Random note"),
+				),
+				Script(ID("random-notes"), Type("application/json"), g.Raw(string(data))),
+				NoScript(P(Class(emptyStateClass), g.Text("Picking a random note needs JavaScript."))),
+				P(
+					ID("random-empty"),
+					Class(emptyStateClass),
+					g.Attr("hidden", "true"),
+					g.Text("No note to pick from."),
+				),
+			),
+		}),
+	), nil
+}
@@ -1,10 +1,17 @@
 package template
 
 import (
+	"encoding/json"
 	"fmt"
+	"log/slog"
+	"net/url"
 	"strings"
+	"time"
 
+	"github.com/EwenQuim/pluie/engine"
 	"github.com/EwenQuim/pluie/model"
+	g "github.com/maragudk/gomponents"
+	. "github.com/maragudk/gomponents/html"
 )
 
 // SEOData contains all the computed SEO properties for a page
@@ -17,6 +24,14 @@ type SEOData struct {
 	AuthorMeta   interface{}
 	DateMeta     interface{}
 	ModifiedMeta interface{}
+	NoIndex      bool // The note asks search engines not to index it, see engine.IsNoIndex, or is a shared note
+
+	WordCount      int // Words of the note, see engine.ReadingStats
+	ReadingMinutes int
+
+	ImageURL    string // Cover of the note, else the site icon, absolute when SITE_URL is set
+	TwitterCard string // "summary_large_image" for notes with a cover, else "summary"
+	Headline    string // Title of the note, without the site title
 }
 
 // ComputeSEOData extracts and computes SEO properties from a note and site configuration
@@ -82,6 +97,8 @@ func ComputeSEOData(note *model.Note, baseSiteTitle, baseSiteDescription string)
 			seoData.CanonicalURL = fmt.Sprintf("/%s", note.Slug)
 		}
 
+		seoData.NoIndex = engine.IsNoIndex(*note) || note.Access == model.AccessPrivate
+
 		// Set Open Graph type
 		seoData.OGType = "article"
 	} else {
@@ -93,3 +110,135 @@ func ComputeSEOData(note *model.Note, baseSiteTitle, baseSiteDescription string)
 
 	return seoData
 }
+
+// seoData computes the SEO properties of a page with the site settings: the reading stats of
+// the note, its image, and absolute URLs when SITE_URL is set. Server and static pages get
+// the same tags.
+func (rs Resource) seoData(note *model.Note) SEOData {
+	seoData := ComputeSEOData(note, rs.cfg.SiteTitle, rs.cfg.SiteDescription)
+	seoData.CanonicalURL = absoluteURL(rs.cfg.SiteURL, seoData.CanonicalURL)
+	seoData.ImageURL = ImageURL(note, rs.cfg.SiteURL, rs.cfg.SiteIcon)
+	seoData.TwitterCard = "summary"
+	if note != nil {
+		seoData.Headline = note.Title
+		seoData.WordCount, seoData.ReadingMinutes = engine.ReadingStats(note.Content, rs.cfg.ReadingWPM)
+		if engine.CoverURL(*note) != "" {
+			seoData.TwitterCard = "summary_large_image"
+		}
+	}
+	return seoData
+}
+
+// ImageURL returns the image of a page for link previews: the cover of the note, see
+// engine.CoverURL, else the site icon. Site paths are made absolute on siteURL, when set.
+func ImageURL(note *model.Note, siteURL, siteIcon string) string {
+	image := siteIcon
+	if note != nil {
+		if cover := engine.CoverURL(*note); cover != "" {
+			image = cover
+		}
+	}
+	return absoluteURL(siteURL, image)
+}
+
+// absoluteURL prefixes a site path like "/notes/a" with siteURL, escaping what the slug left
+// raw, like the CJK of v2 slugs, and keeping what it already escaped. Empty paths, absolute URLs
+// and paths with no siteURL are kept.
+func absoluteURL(siteURL, path string) string {
+	if siteURL == "" || !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") {
+		return path
+	}
+	if u, err := url.Parse(path); err == nil {
+		path = u.String()
+	}
+	return siteURL + path
+}
+
+// jsonLD is the schema.org description of a note, see RenderJSONLD
+type jsonLD struct {
+	Context       string         `json:"@context"`
+	Type          string         `json:"@type"`
+	Headline      string         `json:"headline,omitempty"`
+	Description   string         `json:"description,omitempty"`
+	URL           string         `json:"url,omitempty"`
+	Image         string         `json:"image,omitempty"`
+	Author        []jsonLDPerson `json:"author,omitempty"`
+	DatePublished string         `json:"datePublished,omitempty"`
+	DateModified  string         `json:"dateModified,omitempty"`
+	Keywords      string         `json:"keywords,omitempty"`
+	WordCount     int            `json:"wordCount,omitempty"`
+}
+
+type jsonLDPerson struct {
+	Type string `json:"@type"`
+	Name string `json:"name"`
+}
+
+// RenderJSONLD renders the structured data of a note page for search engines: an Article,
+// or a WebPage when the note has no date. URLs are made absolute on siteURL, when set.
+// Empty fields are left out.
+func RenderJSONLD(seo SEOData, siteURL string) g.Node {
+	data := jsonLD{
+		Context:       "https://schema.org",
+		Type:          "WebPage",
+		Headline:      seo.Headline,
+		Description:   seo.Description,
+		URL:           absoluteURL(siteURL, seo.CanonicalURL),
+		Image:         absoluteURL(siteURL, seo.ImageURL),
+		Author:        jsonLDAuthors(seo.AuthorMeta),
+		DatePublished: jsonLDDate(seo.DateMeta),
+		DateModified:  jsonLDDate(seo.ModifiedMeta),
+		Keywords:      strings.Join(seo.Keywords, ", "),
+		WordCount:     seo.WordCount,
+	}
+	if data.Headline == "" {
+		data.Headline = seo.PageTitle
+	}
+	if data.DatePublished != "" {
+		data.Type = "Article"
+	}
+
+	// json.Marshal escapes <, > and &, so values can't close the script
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		slog.Error("Failed to encode structured data", "error", err)
+		return nil
+	}
+	return Script(Type("application/ld+json"), g.Raw(string(encoded)))
+}
+
+// jsonLDAuthors returns the authors of the "author" frontmatter, a name or a list of names
+func jsonLDAuthors(author any) []jsonLDPerson {
+	var names []string
+	switch v := author.(type) {
+	case string:
+		names = append(names, v)
+	case []any:
+		for _, name := range v {
+			if name, ok := name.(string); ok {
+				names = append(names, name)
+			}
+		}
+	}
+
+	var persons []jsonLDPerson
+	for _, name := range names {
+		if name = strings.TrimSpace(name); name != "" {
+			persons = append(persons, jsonLDPerson{Type: "Person", Name: name})
+		}
+	}
+	return persons
+}
+
+// jsonLDDate formats a frontmatter date in ISO 8601: the day alone for dates without time,
+// else with its offset, UTC when it has none. Values that are not dates are left out.
+func jsonLDDate(value any) string {
+	t, dateOnly, ok := engine.ParseDate(value, time.UTC)
+	if !ok {
+		return ""
+	}
+	if dateOnly {
+		return t.Format("2006-01-02")
+	}
+	return t.Format(time.RFC3339)
+}
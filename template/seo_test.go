@@ -1,8 +1,11 @@
 package template
 
 import (
+	"encoding/json"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/EwenQuim/pluie/model"
 )
@@ -230,6 +233,16 @@ func TestComputeSEOData_EdgeCases(t *testing.T) {
 		}
 	})
 
+	t.Run("note with noindex frontmatter", func(t *testing.T) {
+		note := &model.Note{Title: "Test", Slug: "test", Metadata: map[string]any{"noindex": true}}
+		if result := ComputeSEOData(note, "Site", "Description"); !result.NoIndex {
+			t.Error("Expected NoIndex with noindex: true")
+		}
+		if result := ComputeSEOData(nil, "Site", "Description"); result.NoIndex {
+			t.Error("Expected pages without note to be indexed")
+		}
+	})
+
 	t.Run("note with non-string description should be ignored", func(t *testing.T) {
 		note := &model.Note{
 			Title: "Test",
@@ -244,3 +257,137 @@ func TestComputeSEOData_EdgeCases(t *testing.T) {
 		}
 	})
 }
+
+func TestImageURL(t *testing.T) {
+	withCover := func(cover string) *model.Note {
+		return &model.Note{Slug: "trip", Metadata: map[string]any{"cover": cover}, Attachments: map[string]string{"beach.png": "photos/beach.png"}}
+	}
+
+	tests := []struct {
+		name     string
+		note     *model.Note
+		siteURL  string
+		expected string
+	}{
+		{name: "no note", note: nil, siteURL: "https://notes.example.com", expected: "https://notes.example.com/static/pluie.webp"},
+		{name: "missing cover falls back to the site icon", note: &model.Note{Slug: "trip"}, expected: "/static/pluie.webp"},
+		{name: "attachment cover, relative without SITE_URL", note: withCover("[[beach.png]]"), expected: "/-/attachments/photos/beach.png"},
+		{name: "attachment cover, absolute with SITE_URL", note: withCover("[[beach.png]]"), siteURL: "https://notes.example.com", expected: "https://notes.example.com/-/attachments/photos/beach.png"},
+		{name: "absolute cover is kept", note: withCover("https://cdn.example.com/cover.png"), siteURL: "https://notes.example.com", expected: "https://cdn.example.com/cover.png"},
+		{name: "unresolved cover falls back to the site icon", note: withCover("[[missing.png]]"), siteURL: "https://notes.example.com", expected: "https://notes.example.com/static/pluie.webp"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ImageURL(tt.note, tt.siteURL, "/static/pluie.webp"); got != tt.expected {
+				t.Errorf("ImageURL() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestAbsoluteURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		siteURL  string
+		path     string
+		expected string
+	}{
+		{name: "no site URL", path: "/travel/trip", expected: "/travel/trip"},
+		{name: "site path", siteURL: "https://notes.example.com", path: "/travel/trip", expected: "https://notes.example.com/travel/trip"},
+		{name: "encoded v1 slug is kept", siteURL: "https://notes.example.com", path: "/My%20Notes/caf%C3%A9", expected: "https://notes.example.com/My%20Notes/caf%C3%A9"},
+		{name: "encoded slash is kept", siteURL: "https://notes.example.com", path: "/a%2Fb", expected: "https://notes.example.com/a%2Fb"},
+		{name: "raw slug is escaped", siteURL: "https://notes.example.com", path: "/日記/東京", expected: "https://notes.example.com/%E6%97%A5%E8%A8%98/%E6%9D%B1%E4%BA%AC"},
+		{name: "query is kept", siteURL: "https://notes.example.com", path: "/static/pluie.webp?v=abc", expected: "https://notes.example.com/static/pluie.webp?v=abc"},
+		{name: "absolute URL is kept", siteURL: "https://notes.example.com", path: "https://cdn.example.com/a.png", expected: "https://cdn.example.com/a.png"},
+		{name: "protocol-relative URL is kept", siteURL: "https://notes.example.com", path: "//cdn.example.com/a.png", expected: "//cdn.example.com/a.png"},
+		{name: "empty path", siteURL: "https://notes.example.com", path: "", expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := absoluteURL(tt.siteURL, tt.path); got != tt.expected {
+				t.Errorf("absoluteURL(%q, %q) = %q, want %q", tt.siteURL, tt.path, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRenderJSONLD(t *testing.T) {
+	decode := func(t *testing.T, seo SEOData, siteURL string) map[string]any {
+		t.Helper()
+		var sb strings.Builder
+		if err := RenderJSONLD(seo, siteURL).Render(&sb); err != nil {
+			t.Fatalf("Render() returned error: %v", err)
+		}
+		script := sb.String()
+		encoded, ok := strings.CutPrefix(script, `<script type="application/ld+json">`)
+		if !ok || !strings.HasSuffix(encoded, "</script>") {
+			t.Fatalf("expected a JSON-LD script, got %s", script)
+		}
+		var data map[string]any
+		if err := json.Unmarshal([]byte(strings.TrimSuffix(encoded, "</script>")), &data); err != nil {
+			t.Fatalf("invalid JSON-LD %s: %v", encoded, err)
+		}
+		return data
+	}
+
+	t.Run("article", func(t *testing.T) {
+		data := decode(t, SEOData{
+			PageTitle:    "Trip | Pluie",
+			Headline:     "Trip",
+			Description:  "Two weeks by the sea",
+			CanonicalURL: "/travel/trip",
+			ImageURL:     "/-/attachments/beach.png",
+			Keywords:     []string{"travel", "sea"},
+			AuthorMeta:   []any{"Ada", 3, "Grace"},
+			DateMeta:     "2024-02-01",
+			ModifiedMeta: time.Date(2024, 3, 4, 10, 30, 0, 0, time.UTC),
+			WordCount:    120,
+		}, "https://notes.example.com")
+
+		expected := map[string]any{
+			"@context":      "https://schema.org",
+			"@type":         "Article",
+			"headline":      "Trip",
+			"description":   "Two weeks by the sea",
+			"url":           "https://notes.example.com/travel/trip",
+			"image":         "https://notes.example.com/-/attachments/beach.png",
+			"datePublished": "2024-02-01",
+			"dateModified":  "2024-03-04T10:30:00Z",
+			"keywords":      "travel, sea",
+			"wordCount":     float64(120),
+		}
+		for key, value := range expected {
+			if data[key] != value {
+				t.Errorf("%s = %v, want %v", key, data[key], value)
+			}
+		}
+		authors, ok := data["author"].([]any)
+		if !ok || len(authors) != 2 || authors[0].(map[string]any)["name"] != "Ada" || authors[1].(map[string]any)["@type"] != "Person" {
+			t.Errorf("unexpected authors %v", data["author"])
+		}
+	})
+
+	t.Run("web page without date, empty fields left out", func(t *testing.T) {
+		data := decode(t, SEOData{PageTitle: "Notes | Pluie", DateMeta: "not a date"}, "")
+		if data["@type"] != "WebPage" || data["headline"] != "Notes | Pluie" {
+			t.Errorf("unexpected structured data %v", data)
+		}
+		for _, key := range []string{"description", "url", "image", "author", "datePublished", "dateModified", "keywords", "wordCount"} {
+			if _, present := data[key]; present {
+				t.Errorf("%s should be left out when empty, got %v", key, data[key])
+			}
+		}
+	})
+
+	t.Run("values can't close the script", func(t *testing.T) {
+		var sb strings.Builder
+		if err := RenderJSONLD(SEOData{Headline: "</script><script>alert(1)</script>"}, "").Render(&sb); err != nil {
+			t.Fatalf("Render() returned error: %v", err)
+		}
+		if strings.Count(sb.String(), "</script>") != 1 {
+			t.Errorf("the headline should be escaped, got %s", sb.String())
+		}
+	})
+}
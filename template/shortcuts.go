@@ -0,0 +1,71 @@
+package template
+
+import (
+	g "github.com/maragudk/gomponents"
+	. "github.com/maragudk/gomponents/html"
+)
+
+// shortcut is a key of keymap.js and what it does
+type shortcut struct {
+	Keys        []string // Pressed one after the other, like "g" then "h"
+	Description string
+}
+
+// shortcuts are the keys of keymap.js, listed in the help dialog. They don't fire while typing.
+var shortcuts = []shortcut{
+	{Keys: []string{"/"}, Description: "Focus the search box"},
+	{Keys: []string{"g", "h"}, Description: "Go to the home note"},
+	{Keys: []string{"["}, Description: "Previous note"},
+	{Keys: []string{"]"}, Description: "Next note"},
+	{Keys: []string{"t"}, Description: "Go to the table of contents"},
+	{Keys: []string{"?"}, Description: "Show this help"},
+	{Keys: []string{"Esc"}, Description: "Close this help"},
+}
+
+// renderShortcutsHelp renders the dialog listing the keyboard shortcuts, opened with the ? key
+func renderShortcutsHelp() g.Node {
+	return g.El("dialog",
+		ID("shortcuts-help"),
+		Class("w-full max-w-sm m-auto p-6 rounded-lg shadow-xl bg-white text-gray-900 backdrop:bg-black/50 dark:bg-gray-900 dark:text-gray-100"),
+		g.Attr("aria-labelledby", "shortcuts-help-title"),
+		Div(
+			Class("flex items-center justify-between mb-4"),
+			H2(
+				ID("shortcuts-help-title"),
+				Class("text-lg font-semibold"),
+				g.Text("Keyboard shortcuts"),
+			),
+			Form(
+				Method("dialog"),
+				Button(
+					Class("text-gray-500 hover:text-gray-900 cursor-pointer dark:text-gray-400 dark:hover:text-gray-100"),
+					g.Attr("aria-label", "Close"),
+					g.Text("✕"),
+				),
+			),
+		),
+		Table(
+			Class("w-full text-sm"),
+			TBody(
+				g.Group(g.Map(shortcuts, func(shortcut shortcut) g.Node {
+					return Tr(
+						Td(
+							Class("py-1 pr-4 whitespace-nowrap"),
+							g.Group(g.Map(shortcut.Keys, func(key string) g.Node {
+								return Kbd(
+									Class("inline-block min-w-6 mr-1 px-1.5 py-0.5 text-center text-xs font-mono border border-gray-300 rounded bg-gray-50 dark:border-gray-600 dark:bg-gray-800"),
+									g.Text(key),
+								)
+							})),
+						),
+						Td(Class("py-1 text-gray-700 dark:text-gray-300"), g.Text(shortcut.Description)),
+					)
+				})),
+			),
+		),
+		P(
+			Class("mt-4 "+emptyStateClass),
+			g.Text("Shortcuts don't work while typing in a field."),
+		),
+	)
+}
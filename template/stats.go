@@ -0,0 +1,63 @@
+package template
+
+import (
+	"github.com/EwenQuim/pluie/engine"
+	"github.com/EwenQuim/pluie/model"
+	g "github.com/maragudk/gomponents"
+	. "github.com/maragudk/gomponents/html"
+)
+
+// ViewStats renders the table of the views of the notes, in the order given, their ViewCount set
+func (rs Resource) ViewStats(notesService *engine.NotesService, notes []model.Note) (g.Node, error) {
+	var total int64
+	for _, note := range notes {
+		total += note.ViewCount
+	}
+
+	mainContent := Div(
+		Class("flex-1 container overflow-y-auto p-4 md:px-8"),
+		H1(
+			Class("text-3xl md:text-4xl font-bold mb-4 mt-2"),
+			g.Text("Views"),
+		),
+		g.If(len(notes) == 0,
+			P(
+				Class("text-gray-600 dark:text-gray-400"),
+				g.Text("No views yet."),
+			),
+		),
+		g.If(len(notes) > 0,
+			Table(
+				ID("view-stats"),
+				Class("w-full max-w-2xl text-left"),
+				THead(
+					Tr(
+						Class("border-b border-gray-200 dark:border-gray-700"),
+						Th(Class("py-2 font-semibold"), g.Text("Note")),
+						Th(Class("py-2 font-semibold text-right"), g.Text("Views")),
+					),
+				),
+				TBody(g.Group(g.Map(notes, func(note model.Note) g.Node {
+					return Tr(
+						Class("border-b border-gray-100 dark:border-gray-800"),
+						Td(Class("py-2"), A(Href("/"+note.Slug), Class(textLinkClass), g.Attr("hx-boost", "true"), g.Text(note.Title))),
+						Td(Class("py-2 text-right tabular-nums"), g.Text(formatThousands(int(note.ViewCount)))),
+					)
+				}))),
+				TFoot(
+					Tr(
+						Td(Class("py-2 font-semibold"), g.Text("Total")),
+						Td(Class("py-2 text-right font-semibold tabular-nums"), g.Text(formatThousands(int(total)))),
+					),
+				),
+			),
+		),
+	)
+
+	return rs.Layout(
+		nil,
+		rs.renderWithNavbar(notesService, navbarConfig{
+			mainContent: mainContent,
+		}),
+	), nil
+}
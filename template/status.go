@@ -0,0 +1,150 @@
+package template
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/EwenQuim/pluie/engine"
+	"github.com/EwenQuim/pluie/model"
+	g "github.com/maragudk/gomponents"
+	. "github.com/maragudk/gomponents/html"
+)
+
+// statusColorClasses are the badge classes of every engine.StatusColors color.
+// Written in full so Tailwind picks them up.
+var statusColorClasses = map[string]string{
+	"gray":    "bg-gray-100 text-gray-800 border-gray-200 dark:bg-gray-800 dark:text-gray-200 dark:border-gray-700",
+	"red":     "bg-red-100 text-red-800 border-red-200 dark:bg-red-900/40 dark:text-red-200 dark:border-red-800",
+	"orange":  "bg-orange-100 text-orange-800 border-orange-200 dark:bg-orange-900/40 dark:text-orange-200 dark:border-orange-800",
+	"amber":   "bg-amber-100 text-amber-800 border-amber-200 dark:bg-amber-900/40 dark:text-amber-200 dark:border-amber-800",
+	"yellow":  "bg-yellow-100 text-yellow-800 border-yellow-200 dark:bg-yellow-900/40 dark:text-yellow-200 dark:border-yellow-800",
+	"lime":    "bg-lime-100 text-lime-800 border-lime-200 dark:bg-lime-900/40 dark:text-lime-200 dark:border-lime-800",
+	"green":   "bg-green-100 text-green-800 border-green-200 dark:bg-green-900/40 dark:text-green-200 dark:border-green-800",
+	"emerald": "bg-emerald-100 text-emerald-800 border-emerald-200 dark:bg-emerald-900/40 dark:text-emerald-200 dark:border-emerald-800",
+	"teal":    "bg-teal-100 text-teal-800 border-teal-200 dark:bg-teal-900/40 dark:text-teal-200 dark:border-teal-800",
+	"sky":     "bg-sky-100 text-sky-800 border-sky-200 dark:bg-sky-900/40 dark:text-sky-200 dark:border-sky-800",
+	"blue":    "bg-blue-100 text-blue-800 border-blue-200 dark:bg-blue-900/40 dark:text-blue-200 dark:border-blue-800",
+	"indigo":  "bg-indigo-100 text-indigo-800 border-indigo-200 dark:bg-indigo-900/40 dark:text-indigo-200 dark:border-indigo-800",
+	"purple":  "bg-purple-100 text-purple-800 border-purple-200 dark:bg-purple-900/40 dark:text-purple-200 dark:border-purple-800",
+	"pink":    "bg-pink-100 text-pink-800 border-pink-200 dark:bg-pink-900/40 dark:text-pink-200 dark:border-pink-800",
+}
+
+// StatusBadge renders the badge of a recognized status, nothing for an empty or unknown one
+func (rs Resource) StatusBadge(name string) g.Node {
+	status, ok := rs.statuses.Get(name)
+	if !ok {
+		return nil
+	}
+
+	return Span(
+		Class("status-badge inline-flex items-center px-2 py-0.5 rounded-full text-xs font-medium border align-middle "+statusColorClasses[status.Color]),
+		g.Text(status.Name),
+	)
+}
+
+// DraftBadge renders the badge of the notes with "draft: true", only shown by the server with
+// SHOW_DRAFTS
+func DraftBadge() g.Node {
+	return Span(
+		Class("draft-badge inline-flex items-center px-2 py-0.5 rounded-full text-xs font-medium border align-middle uppercase "+statusColorClasses["amber"]),
+		g.Text("Draft"),
+	)
+}
+
+// StatusOverview renders the /-/status page: one column of note cards per status.
+// Columns listed in oldestFirst are sorted oldest modified first.
+func (rs Resource) StatusOverview(notesService *engine.NotesService, oldestFirst map[string]bool) (g.Node, error) {
+	columns := engine.StatusColumns(notesService.GetAllNotes(), rs.statuses, oldestFirst, rs.cfg.Location())
+
+	mainContent := Div(
+		Class("flex-1 overflow-y-auto p-4 md:px-8"),
+		H1(
+			Class("text-3xl md:text-4xl font-bold mb-4 mt-2"),
+			g.Text("Status"),
+		),
+		g.If(len(columns) == 0,
+			P(
+				Class("text-gray-600 dark:text-gray-400"),
+				g.Text("No statuses are configured."),
+			),
+		),
+		Div(
+			Class("flex gap-4 overflow-x-auto pb-4"),
+			g.Group(g.Map(columns, func(column engine.StatusColumn) g.Node {
+				return rs.renderStatusColumn(column, columns)
+			})),
+		),
+	)
+
+	return rs.Layout(
+		nil,
+		rs.renderWithNavbar(notesService, navbarConfig{
+			mainContent: mainContent,
+		}),
+	), nil
+}
+
+// renderStatusColumn renders the header and the note cards of one status
+func (rs Resource) renderStatusColumn(column engine.StatusColumn, columns []engine.StatusColumn) g.Node {
+	sortLabel := "Newest first"
+	if column.OldestFirst {
+		sortLabel = "Oldest first"
+	}
+
+	return Section(
+		Class("status-column flex-none w-72 bg-gray-50 border border-gray-200 rounded-lg p-3 dark:bg-gray-800 dark:border-gray-700"),
+		ID("status-"+column.Status.Name),
+		Div(
+			Class("flex items-center justify-between mb-3"),
+			H2(
+				Class("flex items-center gap-2 font-semibold"),
+				rs.StatusBadge(column.Status.Name),
+				Span(
+					Class("text-sm text-gray-500 dark:text-gray-400"),
+					g.Textf("%d", len(column.Notes)),
+				),
+			),
+			A(
+				Href(statusSortURL(columns, column.Status.Name)),
+				Class("text-xs text-blue-600 hover:underline dark:text-blue-400"),
+				Title("Sort by modified date"),
+				g.Text(sortLabel),
+			),
+		),
+		g.If(len(column.Notes) == 0,
+			P(
+				Class(emptyStateClass),
+				g.Text("No notes"),
+			),
+		),
+		Div(
+			Class("space-y-3"),
+			g.Group(g.Map(column.Notes, func(note model.Note) g.Node {
+				modified, ok := engine.NoteModified(note, rs.cfg.Location())
+				return Div(
+					rs.renderNoteCard(note, ""),
+					g.If(ok,
+						P(
+							Class("mt-1 text-xs text-gray-500 dark:text-gray-400"),
+							g.Textf("Modified %s", engine.FormatDate(modified, rs.cfg.Location())),
+						),
+					),
+				)
+			})),
+		),
+	)
+}
+
+// statusSortURL returns the overview URL with the sort order of one column reversed, keeping the others
+func statusSortURL(columns []engine.StatusColumn, toggled string) string {
+	var oldest []string
+	for _, column := range columns {
+		if column.OldestFirst != (column.Status.Name == toggled) {
+			oldest = append(oldest, column.Status.Name)
+		}
+	}
+	if len(oldest) == 0 {
+		return "/-/status"
+	}
+	return "/-/status?oldest=" + url.QueryEscape(strings.Join(oldest, ","))
+}
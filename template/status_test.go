@@ -0,0 +1,147 @@
+package template
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/EwenQuim/pluie/config"
+	"github.com/EwenQuim/pluie/engine"
+	"github.com/EwenQuim/pluie/model"
+)
+
+func statusResource() Resource {
+	return NewResource(&config.Config{
+		SiteTitle:    "Pluie",
+		NoteStatuses: "seed:lime,draft:gray,evergreen:emerald",
+	})
+}
+
+func TestStatusBadge(t *testing.T) {
+	rs := statusResource()
+
+	tests := []struct {
+		name     string
+		status   string
+		expected string
+	}{
+		{name: "recognized status", status: "seed", expected: `<span class="status-badge inline-flex items-center px-2 py-0.5 rounded-full text-xs font-medium border align-middle bg-lime-100 text-lime-800 border-lime-200 dark:bg-lime-900/40 dark:text-lime-200 dark:border-lime-800">seed</span>`},
+		{name: "other color", status: "evergreen", expected: "bg-emerald-100"},
+		{name: "unknown status", status: "wip", expected: ""},
+		{name: "no status", status: "", expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			badge := rs.StatusBadge(tt.status)
+			if tt.expected == "" {
+				if badge != nil {
+					t.Errorf("StatusBadge(%q) should render nothing", tt.status)
+				}
+				return
+			}
+
+			var sb strings.Builder
+			if err := badge.Render(&sb); err != nil {
+				t.Fatalf("Render() returned error: %v", err)
+			}
+			if !strings.Contains(sb.String(), tt.expected) {
+				t.Errorf("StatusBadge(%q) = %s, want it to contain %s", tt.status, sb.String(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestStatusBadge_NoteAndCard(t *testing.T) {
+	rs := statusResource()
+	notesMap := make(map[string]model.Note)
+	notesService := engine.NewNotesService(&notesMap, &engine.TreeNode{Name: "root", IsFolder: true}, nil)
+	note := model.Note{Title: "Garden", Slug: "garden", Content: "Some garden thoughts here.", Status: "seed"}
+
+	page, err := rs.NoteWithList(notesService, &note, "")
+	if err != nil {
+		t.Fatalf("NoteWithList() returned error: %v", err)
+	}
+	var sb strings.Builder
+	if err := page.Render(&sb); err != nil {
+		t.Fatalf("Render() returned error: %v", err)
+	}
+	if !strings.Contains(sb.String(), `bg-lime-100 text-lime-800 border-lime-200 dark:bg-lime-900/40 dark:text-lime-200 dark:border-lime-800">seed</span>`) {
+		t.Error("the note page should show the status badge next to the title")
+	}
+
+	sb.Reset()
+	if err := rs.renderNoteCard(note, "").Render(&sb); err != nil {
+		t.Fatalf("Render() returned error: %v", err)
+	}
+	if !strings.Contains(sb.String(), ">seed</span>") {
+		t.Error("the note card should show the status badge")
+	}
+
+	sb.Reset()
+	note.Status = ""
+	if err := rs.renderNoteCard(note, "").Render(&sb); err != nil {
+		t.Fatalf("Render() returned error: %v", err)
+	}
+	if strings.Contains(sb.String(), "status-badge") {
+		t.Error("notes without status should have no badge")
+	}
+}
+
+func TestStatusOverview(t *testing.T) {
+	rs := statusResource()
+	notes := []model.Note{
+		{Title: "Old seed", Slug: "old-seed", Path: "old-seed.md", Status: "seed", Metadata: map[string]any{"modified": "2024-01-01"}},
+		{Title: "New seed", Slug: "new-seed", Path: "new-seed.md", Status: "seed", Metadata: map[string]any{"modified": "2024-06-01"}},
+		{Title: "Tree", Slug: "tree", Path: "tree.md", Status: "evergreen"},
+	}
+	notesMap := make(map[string]model.Note)
+	for _, note := range notes {
+		notesMap[note.Slug] = note
+	}
+	notesService := engine.NewNotesService(&notesMap, engine.BuildTree(notes), nil)
+
+	render := func(oldestFirst map[string]bool) string {
+		page, err := rs.StatusOverview(notesService, oldestFirst)
+		if err != nil {
+			t.Fatalf("StatusOverview() returned error: %v", err)
+		}
+		var sb strings.Builder
+		if err := page.Render(&sb); err != nil {
+			t.Fatalf("Render() returned error: %v", err)
+		}
+		return sb.String()
+	}
+
+	// The tree sidebar lists the notes too, only look at the columns
+	columns := func(html string) string {
+		return html[strings.Index(html, `id="status-seed"`):]
+	}
+
+	html := render(nil)
+	for _, expected := range []string{
+		`id="status-seed"`,
+		`id="status-draft"`,
+		`id="status-evergreen"`,
+		"No notes", // Empty draft column
+		"Modified Jun 1, 2024",
+		`href="/-/status?oldest=seed"`,
+	} {
+		if !strings.Contains(html, expected) {
+			t.Errorf("expected the overview to contain %q", expected)
+		}
+	}
+	if strings.Index(html, `id="status-seed"`) > strings.Index(html, `id="status-draft"`) {
+		t.Error("columns should follow the configured status order")
+	}
+	if board := columns(html); strings.Index(board, "New seed") > strings.Index(board, "Old seed") {
+		t.Error("columns should list the most recently modified notes first")
+	}
+
+	html = render(map[string]bool{"seed": true})
+	if board := columns(html); strings.Index(board, "Old seed") > strings.Index(board, "New seed") {
+		t.Error("the seed column should list the oldest notes first")
+	}
+	if !strings.Contains(html, `href="/-/status"`) || !strings.Contains(html, "Oldest first") {
+		t.Error("the seed column should link back to the default order")
+	}
+}
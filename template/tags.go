@@ -0,0 +1,85 @@
+package template
+
+import (
+	"github.com/EwenQuim/pluie/engine"
+	g "github.com/maragudk/gomponents"
+	. "github.com/maragudk/gomponents/html"
+)
+
+// tagCloudSizes are the text sizes of the tag cloud, from the least to the most used tags
+var tagCloudSizes = []string{"text-sm", "text-base", "text-lg", "text-xl", "text-2xl"}
+
+// TagCloud renders the /-/tags page: every tag with its number of notes, sized by frequency and
+// linking to its tag page. Nested tags are grouped under their top-level tag.
+func (rs Resource) TagCloud(notesService *engine.NotesService, tagIndex engine.TagIndex) (g.Node, error) {
+	var content g.Node
+	if len(tagIndex) == 0 {
+		content = P(
+			Class("text-gray-600 dark:text-gray-400"),
+			g.Text("No tags found in your notes."),
+		)
+	} else {
+		maxCount := tagIndex.AllTags()[0].Count
+		content = Ul(
+			ID("tag-index"),
+			Class("space-y-3"),
+			g.Group(g.Map(tagIndex.ChildTags(""), func(root engine.TagCount) g.Node {
+				return Li(
+					Class("flex flex-wrap items-baseline gap-2"),
+					g.Group(renderTagCloudLevel(tagIndex, root, maxCount)),
+				)
+			})),
+		)
+	}
+
+	mainContent := Div(
+		Class("flex-1 container overflow-y-auto p-4 md:px-8"),
+		H1(
+			Class("text-3xl md:text-4xl font-bold mb-4 mt-2"),
+			g.Text("Tags"),
+		),
+		content,
+	)
+
+	return rs.Layout(
+		nil,
+		rs.renderWithNavbar(notesService, navbarConfig{
+			mainContent: mainContent,
+		}),
+	), nil
+}
+
+// renderTagCloudLevel renders the tag followed by the tags nested under it, depth first
+func renderTagCloudLevel(tagIndex engine.TagIndex, tag engine.TagCount, maxCount int) []g.Node {
+	nodes := []g.Node{renderTagCloudTag(tag, maxCount)}
+	for _, child := range tagIndex.ChildTags(tag.Tag) {
+		nodes = append(nodes, renderTagCloudLevel(tagIndex, child, maxCount)...)
+	}
+	return nodes
+}
+
+// renderTagCloudTag renders a tag of the cloud. A level no note is tagged with, only holding
+// nested tags, has no page and is not a link.
+func renderTagCloudTag(tag engine.TagCount, maxCount int) g.Node {
+	if tag.Count == 0 {
+		return Span(
+			Class("px-3 py-1 text-sm text-gray-500 dark:text-gray-400"),
+			g.Text("#"+tag.Tag),
+		)
+	}
+
+	size := tagCloudSizes[0]
+	if maxCount > 1 {
+		size = tagCloudSizes[(tag.Count-1)*(len(tagCloudSizes)-1)/(maxCount-1)]
+	}
+	return A(
+		Href("/-/tag/"+tag.Tag),
+		Class("inline-flex items-center gap-1 px-3 py-1 "+size+" bg-gray-100 hover:bg-gray-200 text-gray-800 rounded-full transition-colors dark:bg-gray-800 dark:hover:bg-gray-700 dark:text-gray-200"),
+		g.Attr("hx-boost", "true"),
+		g.Text("#"+tag.Tag),
+		Span(
+			Class("text-gray-500 dark:text-gray-400"),
+			g.Textf("%d", tag.Count),
+		),
+	)
+}
@@ -0,0 +1,95 @@
+package template
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/EwenQuim/pluie/engine"
+	g "github.com/maragudk/gomponents"
+	. "github.com/maragudk/gomponents/html"
+)
+
+// taskItemRegex matches the list items of rendered markdown starting with a "[ ]" or "[x]" checkbox,
+// the item text being in a paragraph in loose lists
+var taskItemRegex = regexp.MustCompile(`<li>(<p>)?\[([ xX])\]\s`)
+
+// renderTaskCheckboxes turns the "[ ]" and "[x]" of the task list items of a rendered note
+// into disabled checkboxes
+func renderTaskCheckboxes(renderedHTML string) string {
+	return taskItemRegex.ReplaceAllStringFunc(renderedHTML, func(match string) string {
+		parts := taskItemRegex.FindStringSubmatch(match)
+		checkbox := `<input type="checkbox" disabled>`
+		if parts[2] != " " {
+			checkbox = `<input type="checkbox" checked disabled>`
+		}
+		return `<li class="task-list-item">` + parts[1] + checkbox + " "
+	})
+}
+
+// Tasks renders the /-/tasks page: the open tasks of the notes, grouped by note
+func (rs Resource) Tasks(notesService *engine.NotesService, groups []engine.NoteTasks) (g.Node, error) {
+	mainContent := Div(
+		Class("flex-1 container overflow-y-auto p-4 md:px-8"),
+		H1(
+			Class("text-3xl md:text-4xl font-bold mb-4 mt-2"),
+			g.Text("Tasks"),
+		),
+		g.If(len(groups) == 0,
+			P(
+				Class("text-gray-600 dark:text-gray-400"),
+				g.Text("No open tasks in your notes."),
+			),
+		),
+		g.Group(g.Map(groups, func(group engine.NoteTasks) g.Node {
+			return Section(
+				Class("task-group mb-6"),
+				H2(
+					Class("text-lg font-semibold mb-2"),
+					A(
+						Href("/"+group.Note.Slug),
+						Class(textLinkClass),
+						g.Attr("hx-boost", "true"),
+						g.Text(group.Note.Title),
+					),
+				),
+				Ul(
+					Class("space-y-1"),
+					g.Group(g.Map(group.Tasks, func(task engine.Task) g.Node {
+						return Li(
+							Class("flex items-start gap-2 text-gray-800 dark:text-gray-200 "+taskIndentClass(task.Depth)),
+							Input(Type("checkbox"), Disabled(), Class("mt-1")),
+							A(
+								Href("/"+group.Note.Slug),
+								Class("hover:underline"),
+								g.Attr("hx-boost", "true"),
+								Title(fmt.Sprintf("Line %d", task.Line)),
+								g.Text(engine.WikiLinksToText(task.Text)),
+							),
+						)
+					})),
+				),
+			)
+		})),
+	)
+
+	return rs.Layout(
+		nil,
+		rs.renderWithNavbar(notesService, navbarConfig{
+			mainContent: mainContent,
+		}),
+	), nil
+}
+
+// taskIndentClass indents nested tasks, like their list in the note
+func taskIndentClass(depth int) string {
+	switch depth {
+	case 0:
+		return ""
+	case 1:
+		return "ml-6"
+	case 2:
+		return "ml-12"
+	default:
+		return "ml-18"
+	}
+}
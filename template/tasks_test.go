@@ -0,0 +1,26 @@
+package template
+
+import "testing"
+
+func TestRenderTaskCheckboxes(t *testing.T) {
+	tests := []struct {
+		name     string
+		html     string
+		expected string
+	}{
+		{name: "open", html: "<li>[ ] Water</li>", expected: `<li class="task-list-item"><input type="checkbox" disabled> Water</li>`},
+		{name: "done", html: "<li>[x] Plant</li>", expected: `<li class="task-list-item"><input type="checkbox" checked disabled> Plant</li>`},
+		{name: "uppercase done", html: "<li>[X] Plant</li>", expected: `<li class="task-list-item"><input type="checkbox" checked disabled> Plant</li>`},
+		{name: "loose list", html: "<li><p>[ ] Water</p></li>", expected: `<li class="task-list-item"><p><input type="checkbox" disabled> Water</p></li>`},
+		{name: "plain item untouched", html: "<li>Water [ ] later</li>", expected: "<li>Water [ ] later</li>"},
+		{name: "code untouched", html: "<pre><code>- [ ] Water\n</code></pre>", expected: "<pre><code>- [ ] Water\n</code></pre>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := renderTaskCheckboxes(tt.html); got != tt.expected {
+				t.Errorf("renderTaskCheckboxes() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,20 @@
+package template
+
+// CSS class constants for consistent styling. Each color has its dark: variant, applied when
+// the html element has the dark class (see static/theme.js). Tailwind only finds the classes
+// written out in full, so they are never assembled from parts.
+const (
+	pageClass            = "bg-white text-gray-900 dark:bg-gray-900 dark:text-gray-100"
+	proseClass           = "prose max-w-none dark:prose-invert"
+	emptyStateClass      = "text-sm text-gray-500 italic dark:text-gray-400"
+	textLinkClass        = "text-blue-600 hover:text-blue-800 hover:underline dark:text-blue-400 dark:hover:text-blue-200"
+	highlightClass       = "bg-yellow-200 rounded-sm dark:bg-yellow-700 dark:text-white"
+	secondaryButtonClass = "px-3 py-1 text-sm bg-gray-200 hover:bg-gray-300 text-gray-700 rounded-md transition-colors cursor-pointer dark:bg-gray-700 dark:hover:bg-gray-600 dark:text-gray-300"
+	propertyValueClass   = "text-sm text-gray-900 bg-slate-50 hover:bg-slate-100 px-3 py-1 rounded border border-slate-200 hover:border-slate-300 font-mono transition-all duration-150 dark:text-gray-100 dark:bg-slate-800 dark:hover:bg-slate-700 dark:border-slate-700 dark:hover:border-slate-600"
+
+	// Notes tree
+	folderButtonClass = "flex items-center text-left w-full px-2 py-1 text-gray-900 hover:text-black hover:bg-gray-50 dark:text-gray-100 dark:hover:text-white dark:hover:bg-gray-800"
+	chevronClass      = "mr-2 transition-transform duration-200 text-gray-400 text-xs dark:text-gray-500"
+	activeLinkClass   = "flex items-center px-2 py-1 text-purple-600 bg-purple-50 border-l border-purple-600 font-medium dark:text-purple-400 dark:bg-purple-950"
+	inactiveLinkClass = "flex items-center px-2 py-1 text-gray-600 hover:text-gray-900 hover:bg-gray-50 border-l border-gray-300 hover:border-gray-800 dark:text-gray-400 dark:hover:text-gray-100 dark:hover:bg-gray-800 dark:border-gray-600 dark:hover:border-gray-200"
+)
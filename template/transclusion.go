@@ -0,0 +1,153 @@
+package template
+
+import (
+	"encoding/hex"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/EwenQuim/pluie/engine"
+	"github.com/EwenQuim/pluie/model"
+	g "github.com/maragudk/gomponents"
+	. "github.com/maragudk/gomponents/html"
+)
+
+// transclusionTokenPrefix marks an embedded note in the markdown of a note, until
+// renderTransclusions renders it: the markdown renderer skips raw HTML. The slug and heading
+// are hex encoded, hex digits go through markdown untouched.
+const transclusionTokenPrefix = "pluie-transclusion-"
+
+// maxTransclusionDepth is the number of nested embeds rendered, like A embeds B embeds C
+const maxTransclusionDepth = 5
+
+// transclusionTokenRegex matches a transclusionToken, alone in its paragraph or inline
+var transclusionTokenRegex = regexp.MustCompile(`<p>` + transclusionTokenPrefix + `([0-9a-f]*)-</p>|` + transclusionTokenPrefix + `([0-9a-f]*)-`)
+
+// transclusionToken returns the token standing for the embed of note, or of its section under heading
+func transclusionToken(note *model.Note, heading string) string {
+	return transclusionTokenPrefix + hex.EncodeToString([]byte(note.Slug+"#"+heading)) + "-"
+}
+
+// renderTransclusions replaces the transclusion tokens of renderedHTML with the rendered embedded
+// notes. chain is the slugs of the notes being rendered, from the page note: embedding one of
+// them again would never end, it renders a warning instead. withoutDrafts leaves out the drafts
+// shown by the server, like missing notes.
+func renderTransclusions(notesService *engine.NotesService, renderedHTML string, chain []string, withoutDrafts bool) string {
+	if !strings.Contains(renderedHTML, transclusionTokenPrefix) {
+		return renderedHTML
+	}
+
+	return transclusionTokenRegex.ReplaceAllStringFunc(renderedHTML, func(match string) string {
+		slug, heading, ok := decodeTransclusionToken(match)
+		if !ok {
+			return match
+		}
+		node := engine.FindNoteInTree(notesService.GetTree(), slug)
+		if node == nil || node.Note == nil || (withoutDrafts && node.Note.IsDraft) {
+			return ""
+		}
+		note := node.Note
+
+		var rendered strings.Builder
+		if slices.Contains(chain, slug) || len(chain) > maxTransclusionDepth {
+			_ = renderTransclusionWarning(note, heading, "Embedding it here again would never end.").Render(&rendered)
+			return rendered.String()
+		}
+
+		content := note.Content
+		if heading != "" {
+			section, ok := engine.SectionByHeading(note.Content, heading)
+			if !ok {
+				_ = renderTransclusionWarning(note, heading, "This section does not exist.").Render(&rendered)
+				return rendered.String()
+			}
+			content = section
+		}
+
+		parsedContent := prepareNoteContent(notesService, content, note.Attachments)
+		innerHTML := removeHeadingIDs(renderNoteHTMLIn(notesService, parsedContent, note.Slug, append(slices.Clone(chain), slug), withoutDrafts))
+		_ = Div(
+			Class("transclusion not-prose my-4 border-l-4 border-gray-300 bg-gray-50 rounded-r-lg px-4 py-2 dark:border-gray-600 dark:bg-gray-800"),
+			A(
+				Class("transclusion-source block text-sm text-gray-500 hover:text-blue-600 hover:underline dark:text-gray-400 dark:hover:text-blue-400"),
+				Href(transclusionSourceURL(note, heading)),
+				g.Text(transclusionSourceText(note, heading)),
+			),
+			Div(Class(proseClass), g.Raw(innerHTML)),
+		).Render(&rendered)
+		return rendered.String()
+	})
+}
+
+// decodeTransclusionToken returns the slug and heading of a transclusionToken
+func decodeTransclusionToken(token string) (slug, heading string, ok bool) {
+	parts := transclusionTokenRegex.FindStringSubmatch(token)
+	decoded, err := hex.DecodeString(parts[1] + parts[2])
+	if err != nil {
+		return "", "", false
+	}
+	slug, heading, _ = strings.Cut(string(decoded), "#")
+	return slug, heading, true
+}
+
+// tocWithEmbeds returns the table of contents of source, a note rendered by renderNoteSource,
+// with the headings of its embedded notes in place, or of their embedded sections. They link
+// to the page of their note. The notes these embed in turn, and a note embedding itself, add none.
+func tocWithEmbeds(notesService *engine.NotesService, source, slug string) []TOCItem {
+	var tocItems []TOCItem
+	headings := identifiedHeadingRegex.FindAllStringIndex(source, -1)
+	embeds := transclusionTokenRegex.FindAllStringIndex(source, -1)
+	for len(headings) > 0 || len(embeds) > 0 {
+		if len(embeds) == 0 || (len(headings) > 0 && headings[0][0] < embeds[0][0]) {
+			tocItems = append(tocItems, extractHeadings(source[headings[0][0]:headings[0][1]])...)
+			headings = headings[1:]
+			continue
+		}
+		token := source[embeds[0][0]:embeds[0][1]]
+		embeds = embeds[1:]
+		embeddedSlug, heading, ok := decodeTransclusionToken(token)
+		if !ok || embeddedSlug == slug {
+			continue
+		}
+		node := engine.FindNoteInTree(notesService.GetTree(), embeddedSlug)
+		if node == nil || node.Note == nil {
+			continue
+		}
+		for _, item := range engine.SectionHeadings(node.Note.Content, heading) {
+			item.Source = embeddedSlug
+			tocItems = append(tocItems, item)
+		}
+	}
+	return tocItems
+}
+
+// renderTransclusionWarning renders a note that cannot be embedded, like one embedding itself
+func renderTransclusionWarning(note *model.Note, heading, reason string) g.Node {
+	return Div(
+		Class("transclusion-warning not-prose my-4 bg-yellow-50 border border-yellow-200 text-yellow-900 rounded-lg px-4 py-3 text-sm dark:bg-yellow-950 dark:border-yellow-800 dark:text-yellow-100"),
+		g.Attr("role", "note"),
+		g.Text("Cannot embed "),
+		A(
+			Class("underline"),
+			Href(transclusionSourceURL(note, heading)),
+			g.Text(transclusionSourceText(note, heading)),
+		),
+		g.Text(". "+reason),
+	)
+}
+
+// transclusionSourceURL is the URL of the embedded note, or of its section
+func transclusionSourceURL(note *model.Note, heading string) string {
+	if anchor, ok := engine.HeadingAnchor(note.Content, heading); ok && heading != "" {
+		return "/" + note.Slug + "#" + anchor
+	}
+	return "/" + note.Slug
+}
+
+// transclusionSourceText is the text of the link to the embedded note, like "Note > Heading"
+func transclusionSourceText(note *model.Note, heading string) string {
+	if heading == "" {
+		return note.Title
+	}
+	return note.Title + " > " + heading
+}
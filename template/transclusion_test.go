@@ -0,0 +1,55 @@
+package template
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/EwenQuim/pluie/engine"
+	"github.com/EwenQuim/pluie/model"
+)
+
+func TestNoteHTML_Transclusions(t *testing.T) {
+	notes := []model.Note{
+		{Title: "Menu", Slug: "menu", Path: "menu.md", Content: "Tonight:\n\n![[Recipe#Steps]]\n\nAnd ![[Recipe#Missing]]"},
+		{Title: "Recipe", Slug: "recipe", Path: "recipe.md", Content: "## Ingredients\n\nFlour\n\n## Steps\n\nBake with [[Oven]].\n\n### Tip\n\nPreheat.\n\n## Serving\n\nWarm."},
+		{Title: "Oven", Slug: "oven", Path: "oven.md", Content: "Hot."},
+		{Title: "Loop A", Slug: "loop-a", Path: "loop-a.md", Content: "A says ![[Loop B]]"},
+		{Title: "Loop B", Slug: "loop-b", Path: "loop-b.md", Content: "B says ![[Loop A]]"},
+	}
+	notesMap := make(map[string]model.Note)
+	for _, note := range notes {
+		notesMap[note.Slug] = note
+	}
+	notesService := engine.NewNotesService(&notesMap, engine.BuildTree(notes), nil)
+	rs := testResource()
+
+	menu := rs.NoteHTML(notesService, &notes[0])
+	embed, _, found := strings.Cut(menu, `<div class="transclusion `)
+	if !found {
+		t.Fatalf("expected the Steps section embedded:\n%s", menu)
+	}
+	if !strings.Contains(embed, "Tonight:") {
+		t.Errorf("the embed should be in place, after the text before it:\n%s", menu)
+	}
+	section := menu[len(embed):]
+	section = section[:strings.Index(section, "transclusion-warning")]
+	if !strings.Contains(section, `href="/recipe#steps">Recipe &gt; Steps</a>`) {
+		t.Errorf("the embed should link to its source section:\n%s", section)
+	}
+	if !strings.Contains(section, "Preheat.") || !strings.Contains(section, `href="/oven"`) || strings.Contains(section, "Flour") || strings.Contains(section, "Warm.") {
+		t.Errorf("only the Steps section, with its subsections and links, should be embedded:\n%s", section)
+	}
+	if !strings.Contains(menu, "This section does not exist.") {
+		t.Errorf("a missing section should render a warning:\n%s", menu)
+	}
+	if strings.Contains(menu, transclusionTokenPrefix) {
+		t.Errorf("no token should be left:\n%s", menu)
+	}
+
+	// A embeds B embeds A: A is rendered once, then the cycle is stopped
+	loop := rs.NoteHTML(notesService, &notes[3])
+	if strings.Count(loop, "B says") != 1 || strings.Count(loop, "A says") != 1 ||
+		!strings.Contains(loop, `Cannot embed <a class="underline" href="/loop-a">Loop A</a>. Embedding it here again would never end.`) {
+		t.Errorf("expected the cycle to be stopped with a warning:\n%s", loop)
+	}
+}
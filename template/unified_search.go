@@ -1,44 +1,75 @@
 package template
 
 import (
+	"cmp"
+	"encoding/json"
 	"fmt"
 	"log/slog"
+	"net/url"
+	"slices"
 	"strings"
 
+	"github.com/EwenQuim/pluie/ai"
 	"github.com/EwenQuim/pluie/engine"
 	"github.com/EwenQuim/pluie/model"
 	g "github.com/maragudk/gomponents"
 	. "github.com/maragudk/gomponents/html"
 )
 
+// Advanced search modes, also available with the "re:" prefix and double quotes
+const (
+	SearchModePhrase = "phrase"
+	SearchModeRegex  = "regex"
+)
+
+// searchMode is an option of the search mode selector
+type searchMode struct {
+	value string
+	label string
+}
+
+// searchModes are the options of the search mode selector, "" being the default search
+var searchModes = []searchMode{
+	{value: "", label: "Everything"},
+	{value: SearchModePhrase, label: "Exact phrase"},
+	{value: SearchModeRegex, label: "Regex"},
+}
+
 // unifiedSearchForm creates the search form component with live search
-func unifiedSearchForm(query string, autofocus bool) g.Node {
+func (rs Resource) unifiedSearchForm(query, mode, chatModel string, autofocus bool) g.Node {
+	// HTMX live search attributes, shared by the query input and the mode selector
+	liveSearch := func(trigger string) g.Node {
+		return g.Group([]g.Node{
+			g.Attr("hx-get", "/-/search"),
+			g.Attr("hx-trigger", trigger),
+			g.Attr("hx-include", "closest form"),
+			g.Attr("hx-target", "#search-results-container"),
+			g.Attr("hx-select", "#search-results-container"),
+			g.Attr("hx-swap", "outerHTML"),
+			g.Attr("hx-push-url", "true"),
+			g.Attr("hx-indicator", "#search-indicator"),
+		})
+	}
+
 	return Form(
 		Method("GET"),
 		Action("/-/search"),
-		Class("max-w-2xl mb-8"),
+		Class("max-w-2xl mb-8 flex gap-2"),
 		Div(
-			Class("relative"),
+			Class("relative flex-1"),
 			Input(
 				Type("text"),
 				Name("q"),
 				Placeholder("Search titles, headings, and content..."),
 				g.If(query != "", Value(query)),
-				Class("block w-full pl-10 pr-3 py-3 border border-gray-300 rounded-lg leading-5 bg-white placeholder-gray-500 focus:outline-none focus:placeholder-gray-400 focus:ring-2 focus:ring-blue-500 focus:border-blue-500 text-base"),
+				Class("block w-full pl-10 pr-3 py-3 border border-gray-300 rounded-lg leading-5 bg-white placeholder-gray-500 focus:outline-none focus:placeholder-gray-400 focus:ring-2 focus:ring-blue-500 focus:border-blue-500 text-base dark:border-gray-600 dark:bg-gray-900 dark:placeholder-gray-400"),
 				g.If(autofocus, g.Attr("autofocus", "true")),
-				// HTMX live search attributes
-				g.Attr("hx-get", "/-/search"),
-				g.Attr("hx-trigger", "input changed delay:300ms, search"),
-				g.Attr("hx-target", "#search-results-container"),
-				g.Attr("hx-select", "#search-results-container"),
-				g.Attr("hx-swap", "outerHTML"),
-				g.Attr("hx-push-url", "true"),
-				g.Attr("hx-indicator", "#search-indicator"),
+				liveSearch("input changed delay:300ms, search"),
 			),
 			Div(
 				Class("absolute inset-y-0 left-0 pl-3 flex items-center pointer-events-none"),
 				Span(
-					Class("text-gray-400 text-lg"),
+					Class("text-gray-400 text-lg dark:text-gray-500"),
 					g.Text("🔍"),
 				),
 			),
@@ -51,6 +82,39 @@ func unifiedSearchForm(query string, autofocus bool) g.Node {
 				),
 			),
 		),
+		Select(
+			Name("mode"),
+			g.Attr("aria-label", "Search mode"),
+			Class("border border-gray-300 rounded-lg bg-white px-2 text-sm text-gray-700 focus:outline-none focus:ring-2 focus:ring-blue-500 dark:border-gray-600 dark:bg-gray-900 dark:text-gray-300"),
+			liveSearch("change"),
+			g.Group(g.Map(searchModes, func(option searchMode) g.Node {
+				if option.value == SearchModeRegex && !rs.cfg.RegexSearch {
+					return nil
+				}
+				return Option(
+					Value(option.value),
+					g.If(option.value == mode, Selected()),
+					g.Text(option.label),
+				)
+			})),
+		),
+		// Chat model of the AI summary, to compare the CHAT_MODELS
+		g.If(len(rs.cfg.ChatModelChoices) > 0,
+			Select(
+				Name("model"),
+				g.Attr("aria-label", "Chat model"),
+				Class("border border-gray-300 rounded-lg bg-white px-2 text-sm text-gray-700 focus:outline-none focus:ring-2 focus:ring-blue-500 dark:border-gray-600 dark:bg-gray-900 dark:text-gray-300"),
+				liveSearch("change"),
+				Option(Value(""), g.Text(rs.cfg.ChatModel)),
+				g.Group(g.Map(rs.cfg.ChatModelChoices, func(model string) g.Node {
+					return Option(
+						Value(model),
+						g.If(model == chatModel, Selected()),
+						g.Text(model),
+					)
+				})),
+			),
+		),
 	)
 }
 
@@ -58,8 +122,10 @@ func unifiedSearchForm(query string, autofocus bool) g.Node {
 func (rs Resource) UnifiedSearchResults(
 	notesService *engine.NotesService,
 	query string,
+	chatModel string, // Of the AI summary, allowed by config.Config.ChatModelAllowed, empty for the default one
 	titleMatches []model.Note,
 	headingMatches []engine.HeadingMatch,
+	contentMatches []engine.ContentMatch,
 	seenSlugs []string,
 ) (g.Node, error) {
 	var title string
@@ -69,8 +135,8 @@ func (rs Resource) UnifiedSearchResults(
 		// Empty state
 		title = "Search"
 		content = Div(
-			Class("prose max-w-none"),
-			unifiedSearchForm("", true),
+			Class(proseClass),
+			rs.unifiedSearchForm("", "", "", true),
 			Div(
 				P(
 					Class("text-sm italic mt-4"),
@@ -88,13 +154,18 @@ func (rs Resource) UnifiedSearchResults(
 		content = Div(
 			Class("max-w-none"),
 			// Search form at top
-			unifiedSearchForm(query, false),
+			rs.unifiedSearchForm(query, "", chatModel, false),
 
 			// Results container (HTMX target)
-			rs.renderSearchResultsContainer(query, titleMatches, headingMatches, seenParam),
+			rs.renderSearchResultsContainer(query, chatModel, titleMatches, headingMatches, contentMatches, seenParam),
 		)
 	}
 
+	return rs.searchPage(notesService, title, content), nil
+}
+
+// searchPage wraps search page content with its title and the navbar
+func (rs Resource) searchPage(notesService *engine.NotesService, title string, content g.Node) g.Node {
 	// Main content area
 	mainContent := Div(
 		Class("flex-1 container overflow-y-auto p-4 md:px-8 flex flex-col"),
@@ -115,11 +186,74 @@ func (rs Resource) UnifiedSearchResults(
 		rs.renderWithNavbar(notesService, navbarConfig{
 			mainContent: mainContent,
 		}),
-	), nil
+	)
+}
+
+// StaticSearch renders the search page of the static site: live search needs the server,
+// so it filters the titles of the notes in the browser instead
+func (rs Resource) StaticSearch(notesService *engine.NotesService) g.Node {
+	notes := notesService.GetAllNotes()
+	slices.SortFunc(notes, func(a, b model.Note) int {
+		return strings.Compare(strings.ToLower(a.Title), strings.ToLower(b.Title))
+	})
+
+	content := Div(
+		Class("max-w-2xl"),
+		P(
+			Class("text-sm text-gray-600 mb-4 dark:text-gray-400"),
+			g.Text("This is a static copy of the notes: headings, content and semantic search need the pluie server. Titles can still be filtered below."),
+		),
+		Input(
+			Type("search"),
+			Name("q"),
+			ID("static-search-input"),
+			Placeholder("Filter notes by title..."),
+			Class("block w-full px-3 py-3 mb-6 border border-gray-300 rounded-lg bg-white placeholder-gray-500 focus:outline-none focus:ring-2 focus:ring-blue-500 focus:border-blue-500 text-base dark:border-gray-600 dark:bg-gray-900 dark:placeholder-gray-400"),
+			g.Attr("autofocus", "true"),
+			g.Attr("oninput", "filterStaticSearch(this.value)"),
+		),
+		Ul(
+			ID("static-search-results"),
+			Class("space-y-1"),
+			g.Group(g.Map(notes, func(note model.Note) g.Node {
+				return Li(
+					g.Attr("data-title", strings.ToLower(note.Title)),
+					A(
+						Href("/"+note.Slug),
+						Class(textLinkClass),
+						g.Text(note.Title),
+					),
+				)
+			})),
+		),
+		P(
+			ID("static-search-empty"),
+			Class("text-sm italic text-gray-500 dark:text-gray-400"),
+			g.Attr("hidden", "true"),
+			g.Text("No notes match this filter."),
+		),
+	)
+
+	return rs.Layout(
+		nil, // No specific note for layout
+		rs.renderWithNavbar(notesService, navbarConfig{
+			mainContent: Div(
+				Class("flex-1 container overflow-y-auto p-4 md:px-8"),
+				H1(
+					Class("text-3xl md:text-4xl font-bold mb-4 mt-2"),
+					g.Text("Search"),
+				),
+				content,
+			),
+		}),
+	)
 }
 
 // renderSearchResultsContainer wraps the search results for HTMX targeting
-func (rs Resource) renderSearchResultsContainer(query string, titleMatches []model.Note, headingMatches []engine.HeadingMatch, seenParam string) g.Node {
+func (rs Resource) renderSearchResultsContainer(query, chatModel string, titleMatches []model.Note, headingMatches []engine.HeadingMatch, contentMatches []engine.ContentMatch, seenParam string) g.Node {
+	// The words searched, without the operators of the query
+	highlight := engine.ParseSearchQuery(query).SemanticText()
+
 	return Div(
 		ID("search-results-container"),
 
@@ -131,8 +265,9 @@ func (rs Resource) renderSearchResultsContainer(query string, titleMatches []mod
 					ID("combined-results"),
 					Class("grid gap-4 md:grid-cols-2 lg:grid-cols-3"),
 					g.Group(g.Map(titleMatches, func(note model.Note) g.Node {
-						return rs.renderNoteCard(note)
+						return rs.renderNoteCard(note, highlight)
 					})),
+					renderSearchSkeletons(),
 				),
 			),
 		),
@@ -143,7 +278,7 @@ func (rs Resource) renderSearchResultsContainer(query string, titleMatches []mod
 				ID("search-loading"),
 				Class("flex justify-center py-4 mb-4"),
 				Div(
-					Class("animate-spin h-4 w-4 border-2 border-gray-400 border-t-transparent rounded-full"),
+					Class("animate-spin h-4 w-4 border-2 border-gray-400 border-t-transparent rounded-full dark:border-gray-500"),
 				),
 			),
 		),
@@ -153,6 +288,7 @@ func (rs Resource) renderSearchResultsContainer(query string, titleMatches []mod
 			Div(
 				ID("combined-results"),
 				Class("grid gap-4 md:grid-cols-2 lg:grid-cols-3 mb-8"),
+				renderSearchSkeletons(),
 			),
 		),
 		g.If(len(titleMatches) == 0,
@@ -160,7 +296,7 @@ func (rs Resource) renderSearchResultsContainer(query string, titleMatches []mod
 				ID("search-loading"),
 				Class("flex justify-center py-8"),
 				Div(
-					Class("animate-spin h-5 w-5 border-2 border-gray-400 border-t-transparent rounded-full"),
+					Class("animate-spin h-5 w-5 border-2 border-gray-400 border-t-transparent rounded-full dark:border-gray-500"),
 				),
 			),
 		),
@@ -170,7 +306,18 @@ func (rs Resource) renderSearchResultsContainer(query string, titleMatches []mod
 			Div(
 				Class("mb-8 space-y-2"),
 				g.Group(g.Map(headingMatches, func(match engine.HeadingMatch) g.Node {
-					return rs.renderHeadingCard(match)
+					return rs.renderHeadingCard(match, highlight)
+				})),
+			),
+		),
+
+		// Content matches section, below the headings
+		g.If(len(contentMatches) > 0,
+			Div(
+				ID("content-results"),
+				Class("mb-8 space-y-2"),
+				g.Group(g.Map(contentMatches, func(match engine.ContentMatch) g.Node {
+					return renderContentCard(match)
 				})),
 			),
 		),
@@ -180,60 +327,138 @@ func (rs Resource) renderSearchResultsContainer(query string, titleMatches []mod
 			ID("ai-section"),
 			Class("hidden mb-8"),
 			H2(
-				Class("text-lg font-medium mb-2 text-gray-700"),
+				Class("text-lg font-medium mb-2 text-gray-700 dark:text-gray-300"),
 				g.Text("Summary"),
 			),
 			Div(
-				Class("bg-gray-50 border border-gray-200 rounded-lg p-4"),
+				Class("bg-gray-50 border border-gray-200 rounded-lg p-4 dark:bg-gray-800 dark:border-gray-700"),
 				Div(
 					ID("ai-content"),
-					Class("prose prose-sm max-w-none text-gray-700"),
+					Class("prose prose-sm max-w-none text-gray-700 dark:prose-invert dark:text-gray-300"),
 				),
+				// Follow-up questions and their answers, see the follow-up form
+				Div(ID("ai-conversation"), Class("space-y-3 mt-3")),
 				// Disclaimer
 				P(
 					ID("ai-disclaimer"),
-					Class("hidden text-xs text-gray-500 italic mt-3 mb-0"),
-					g.Text("AI generated, might not be accurate. Model: "+rs.cfg.ChatModel),
+					Class("hidden text-xs text-gray-500 italic mt-3 mb-0 dark:text-gray-400"),
+					g.Text("AI generated, might not be accurate. Model: "),
+					// Replaced by the provider that answered, see the "provider" event
+					Span(ID("ai-model"), g.Text(cmp.Or(chatModel, rs.cfg.ChatModel))),
+				),
+			),
+			// Shown once the summary is complete
+			Form(
+				ID("ai-followup"),
+				g.Attr("data-question", query),
+				g.If(chatModel != "", g.Attr("data-model", chatModel)),
+				Class("hidden mt-3 flex gap-2"),
+				Input(
+					Type("text"),
+					Name("question"),
+					Placeholder("Ask a follow-up question..."),
+					g.Attr("aria-label", "Follow-up question"),
+					g.Attr("autocomplete", "off"),
+					Class("flex-1 px-3 py-2 border border-gray-300 rounded-lg bg-white placeholder-gray-500 text-sm focus:outline-none focus:ring-2 focus:ring-blue-500 dark:border-gray-600 dark:bg-gray-900 dark:placeholder-gray-400"),
+				),
+				Button(
+					Type("submit"),
+					Class("px-3 py-2 text-sm rounded-lg border border-gray-300 text-gray-700 hover:bg-gray-100 dark:border-gray-600 dark:text-gray-300 dark:hover:bg-gray-800"),
+					g.Text("Ask"),
 				),
 			),
 		),
 
 		// SSE EventSource JavaScript
-		rs.renderSSEScript(query, seenParam),
+		rs.renderSSEScript(query, chatModel, seenParam),
+		renderFollowUpScript(),
 	)
 }
 
-// renderHeadingCard renders a single heading match as a minimal card
-func (rs Resource) renderHeadingCard(match engine.HeadingMatch) g.Node {
+// searchSkeletonCount is the number of placeholder cards shown while semantic results load
+const searchSkeletonCount = 3
+
+// renderSearchSkeletons renders the placeholder cards of the semantic results,
+// removed by the SSE script when the semantic phase ends
+func renderSearchSkeletons() g.Node {
+	skeletons := make([]g.Node, 0, searchSkeletonCount)
+	for range searchSkeletonCount {
+		skeletons = append(skeletons, Div(
+			Class("search-skeleton animate-pulse border border-gray-200 rounded-lg p-4 space-y-3 dark:border-gray-700"),
+			g.Attr("aria-hidden", "true"),
+			Div(Class("h-4 bg-gray-200 rounded w-2/3 dark:bg-gray-700")),
+			Div(Class("h-3 bg-gray-200 rounded dark:bg-gray-700")),
+			Div(Class("h-3 bg-gray-200 rounded w-5/6 dark:bg-gray-700")),
+		))
+	}
+	return g.Group(skeletons)
+}
+
+// renderHeadingCard renders a single heading match as a minimal card, with the words of query
+// highlighted in the heading and its context
+func (rs Resource) renderHeadingCard(match engine.HeadingMatch, query string) g.Node {
 	return A(
 		Href("/"+match.Note.Slug),
-		Class("block border-l-2 border-gray-300 pl-3 py-2 hover:border-gray-400 hover:bg-gray-50 transition-colors"),
+		Class("block border-l-2 border-gray-300 pl-3 py-2 hover:border-gray-400 hover:bg-gray-50 transition-colors dark:border-gray-600 dark:hover:border-gray-500 dark:hover:bg-gray-800"),
 		g.Attr("hx-boost", "true"),
 
 		// Note title
 		Div(
-			Class("text-xs text-gray-500 mb-0.5"),
+			Class("text-xs text-gray-500 mb-0.5 dark:text-gray-400"),
 			g.Text(match.Note.Title),
 		),
 
 		// Heading text (no level badge shown, but still sorted by level)
 		Div(
-			Class("text-sm font-medium text-gray-700 hover:text-gray-900"),
-			g.Text(match.Heading),
+			Class("text-sm font-medium text-gray-700 hover:text-gray-900 dark:text-gray-300 dark:hover:text-gray-100"),
+			HighlightMatches(match.Heading, query),
 		),
 
 		// Context snippet
 		g.If(match.Context != "",
 			P(
-				Class("text-xs text-gray-600 line-clamp-1 mt-0.5 mb-0"),
-				g.Text(match.Context),
+				Class("text-xs text-gray-600 line-clamp-1 mt-0.5 mb-0 dark:text-gray-400"),
+				HighlightMatches(match.Context, query),
+			),
+		),
+	)
+}
+
+// renderContentCard renders a content match as a minimal card, with the match highlighted in its snippet
+func renderContentCard(match engine.ContentMatch) g.Node {
+	return A(
+		Href("/"+match.Note.Slug),
+		Class("block border-l-2 border-gray-200 pl-3 py-2 hover:border-gray-400 hover:bg-gray-50 transition-colors dark:border-gray-700 dark:hover:border-gray-500 dark:hover:bg-gray-800"),
+		g.Attr("hx-boost", "true"),
+
+		// Note title
+		Div(
+			Class("text-sm font-medium text-gray-700 hover:text-gray-900 dark:text-gray-300 dark:hover:text-gray-100"),
+			g.Text(match.Note.Title),
+		),
+
+		// Snippet with the match highlighted
+		g.If(match.Snippet != "",
+			P(
+				Class("text-xs text-gray-600 line-clamp-2 mt-0.5 mb-0 dark:text-gray-400"),
+				g.Text(match.Snippet[:match.Start]),
+				Mark(Class(highlightClass), g.Text(match.Snippet[match.Start:match.End])),
+				g.Text(match.Snippet[match.End:]),
 			),
 		),
 	)
 }
 
 // renderSSEScript renders the EventSource JavaScript for SSE streaming with cleanup
-func (rs Resource) renderSSEScript(query string, seenParam string) g.Node {
+func (rs Resource) renderSSEScript(query, chatModel, seenParam string) g.Node {
+	params := url.Values{"q": {query}, "seen": {seenParam}}
+	if chatModel != "" {
+		params.Set("model", chatModel)
+	}
+	// Escapes <, > and &, so the query cannot close the script element. Strings always encode.
+	queryJSON, _ := json.Marshal(query)
+	streamJSON, _ := json.Marshal("/-/search-stream?" + params.Encode())
+
 	return Script(
 		g.Raw(fmt.Sprintf(`
 (function() {
@@ -244,11 +469,11 @@ func (rs Resource) renderSSEScript(query string, seenParam string) g.Node {
 	}
 
 	// Don't start SSE for empty queries
-	if (!%q) {
+	if (!%s) {
 		return;
 	}
 
-	const evtSource = new EventSource('/-/search-stream?q=%s&seen=%s');
+	const evtSource = new EventSource(%s);
 	window.currentSearchSSE = evtSource; // Store globally for cleanup
 
 	const loading = document.getElementById('search-loading');
@@ -256,55 +481,364 @@ func (rs Resource) renderSSEScript(query string, seenParam string) g.Node {
 	const aiSection = document.getElementById('ai-section');
 	const aiContent = document.getElementById('ai-content');
 	const disclaimer = document.getElementById('ai-disclaimer');
+	const aiModel = document.getElementById('ai-model');
+	const followUp = document.getElementById('ai-followup');
+
+	// Ends the loading state: removes the spinner and the placeholder cards
+	function stopLoading() {
+		if (loading) loading.remove();
+		document.querySelectorAll('#combined-results .search-skeleton').forEach(function(skeleton) {
+			skeleton.remove();
+		});
+	}
 
 	evtSource.addEventListener('semantic-results', function(e) {
-		if (loading) loading.classList.add('hidden');
+		stopLoading();
 		if (combinedResults && e.data) {
 			// Append semantic results to the combined grid
 			combinedResults.insertAdjacentHTML('beforeend', e.data);
 		}
 	});
 
+	evtSource.addEventListener('semantic-skipped', function(e) {
+		console.info('Semantic search skipped:', e.data);
+		stopLoading();
+	});
+
+	// Numbered like the citations of the answer, which link to them
+	let sources = [];
+	evtSource.addEventListener('sources', function(e) {
+		sources = JSON.parse(e.data);
+	});
+
+	evtSource.addEventListener('provider', function(e) {
+		if (aiModel) aiModel.textContent = e.data;
+	});
+
 	evtSource.addEventListener('token', function(e) {
 		if (aiSection && aiSection.classList.contains('hidden')) {
 			aiSection.classList.remove('hidden');
 		}
 		if (aiContent) {
-			aiContent.insertAdjacentText('beforeend', e.data);
+			aiContent.insertAdjacentHTML('beforeend', e.data);
 		}
 	});
 
 	evtSource.addEventListener('done', function(e) {
+		stopLoading();
 		if (disclaimer) disclaimer.classList.remove('hidden');
+		if (aiContent) renderAISources(aiContent, sources);
+		if (followUp && aiContent && aiContent.textContent.trim()) followUp.classList.remove('hidden');
+		evtSource.close();
+		window.currentSearchSSE = null;
+	});
+
+	// The server is stopping: close rather than reconnect, which would run the search again
+	evtSource.addEventListener('shutdown', function(e) {
+		console.info('Search stream closed by the server:', e.data);
+		stopLoading();
 		evtSource.close();
 		window.currentSearchSSE = null;
 	});
 
 	evtSource.addEventListener('error', function(e) {
 		console.error('SSE error:', e);
-		if (loading) loading.classList.add('hidden');
+		stopLoading();
 		evtSource.close();
 		window.currentSearchSSE = null;
 	});
 })();
-		`, query, query, seenParam)),
+		`, queryJSON, streamJSON)),
+	)
+}
+
+// renderFollowUpScript renders the JavaScript of the follow-up questions of the AI summary. The
+// conversation lives in the page: every question is posted with the previous turns, and its
+// answer is read from the SSE stream of the response, since EventSource can't post.
+func renderFollowUpScript() g.Node {
+	return Script(
+		g.Raw(fmt.Sprintf(`
+(function() {
+	const form = document.getElementById('ai-followup');
+	const conversation = document.getElementById('ai-conversation');
+	const aiContent = document.getElementById('ai-content');
+	const aiModel = document.getElementById('ai-model');
+	const combinedResults = document.getElementById('combined-results');
+	if (!form || !conversation || !aiContent) {
+		return;
+	}
+
+	const history = [];
+	let lastQuestion = form.dataset.question;
+	let lastAnswer = aiContent;
+
+	// Calls onEvent with the name and data of every event of an SSE response
+	async function readEvents(response, onEvent) {
+		const reader = response.body.getReader();
+		const decoder = new TextDecoder();
+		let buffer = '';
+		for (;;) {
+			const { done, value } = await reader.read();
+			if (done) return;
+			buffer += decoder.decode(value, { stream: true });
+			let end;
+			while ((end = buffer.indexOf('\n\n')) >= 0) {
+				const block = buffer.slice(0, end);
+				buffer = buffer.slice(end + 2);
+				let event = '';
+				const data = [];
+				block.split('\n').forEach(function(line) {
+					if (line.startsWith('event: ')) event = line.slice(7);
+					else if (line.startsWith('data: ')) data.push(line.slice(6));
+				});
+				if (event) onEvent(event, data.join('\n'));
+			}
+		}
+	}
+
+	form.addEventListener('submit', async function(e) {
+		e.preventDefault();
+		const input = form.elements.question;
+		const question = input.value.trim();
+		if (!question || form.dataset.busy) return;
+		form.dataset.busy = 'true';
+		input.value = '';
+
+		// Failed answers are not part of the conversation
+		if (!lastAnswer.dataset.failed) {
+			history.push({ question: lastQuestion, answer: lastAnswer.textContent });
+		}
+
+		const asked = document.createElement('p');
+		asked.className = 'font-medium text-gray-900 mb-1 dark:text-gray-100';
+		asked.textContent = question;
+		const answer = document.createElement('div');
+		conversation.append(asked, answer);
+		lastQuestion = question;
+		lastAnswer = answer;
+
+		let sources = [];
+
+		// New notes retrieved for the question are added to the results, once
+		const seen = Array.from(document.querySelectorAll('#combined-results [data-slug]'), function(card) {
+			return card.dataset.slug;
+		});
+
+		try {
+			const response = await fetch('/-/search-chat', {
+				method: 'POST',
+				headers: { 'Content-Type': 'application/json' },
+				body: JSON.stringify({ question: question, model: form.dataset.model || '', history: history.slice(-%d), seen: seen }),
+			});
+			if (!response.ok) throw new Error(response.status + ' ' + response.statusText);
+			await readEvents(response, function(event, data) {
+				if (event === 'results' && data && combinedResults) {
+					combinedResults.insertAdjacentHTML('beforeend', data);
+				} else if (event === 'sources') {
+					sources = JSON.parse(data);
+				} else if (event === 'provider' && aiModel) {
+					aiModel.textContent = data;
+				} else if (event === 'token') {
+					answer.insertAdjacentHTML('beforeend', data);
+				} else if (event === 'done') {
+					renderAISources(answer, sources);
+				} else if (event === 'error') {
+					answer.textContent = data;
+					answer.dataset.failed = 'true';
+				} else if (event === 'shutdown') {
+					answer.textContent = 'The server is restarting, ask again in a moment';
+					answer.dataset.failed = 'true';
+				}
+			});
+		} catch (err) {
+			console.error('Follow-up question failed:', err);
+			answer.textContent = 'AI generation failed';
+			answer.dataset.failed = 'true';
+		}
+		delete form.dataset.busy;
+		input.focus();
+	});
+})();
+		`, ai.MaxHistoryTurns)),
 	)
 }
 
 // RenderSemanticResultsHTML renders semantic search results as HTML for SSE streaming
-// Returns individual note cards to be appended to the combined results grid
-func RenderSemanticResultsHTML(rs Resource, notes []model.Note) string {
-	if len(notes) == 0 {
+// Returns individual note cards to be appended to the combined results grid, with the heading
+// of the chunk that matched
+func RenderSemanticResultsHTML(rs Resource, matches []engine.ChunkMatch) string {
+	if len(matches) == 0 {
 		return ""
 	}
 
 	// Build note cards that will be appended to the existing grid
+	var html strings.Builder
+	for _, match := range matches {
+		if err := rs.renderChunkCard(match).Render(&html); err != nil {
+			slog.Error("failed to render note card", "slug", match.Note.Slug, "error", err)
+		}
+	}
+
+	return html.String()
+}
+
+// RenderSourcesHTML renders the notes retrieved for a follow-up question as HTML for SSE
+// streaming, to be appended to the combined results grid: the semantic matches with the heading
+// of their best chunk, like RenderSemanticResultsHTML
+func RenderSourcesHTML(rs Resource, notes []model.Note, matchedChunks map[string][]engine.Chunk) string {
 	var html strings.Builder
 	for _, note := range notes {
-		if err := rs.renderNoteCard(note).Render(&html); err != nil {
+		card := rs.renderNoteCard(note, "")
+		if chunks := matchedChunks[note.Slug]; len(chunks) > 0 {
+			card = rs.renderChunkCard(engine.ChunkMatch{Note: note, Chunk: chunks[0]})
+		}
+		if err := card.Render(&html); err != nil {
 			slog.Error("failed to render note card", "slug", note.Slug, "error", err)
 		}
 	}
-
 	return html.String()
 }
+
+// renderChunkCard renders a semantic match like renderNoteCard, with the heading of its chunk as
+// a subtitle linking to its section, and the chunk text as description
+func (rs Resource) renderChunkCard(match engine.ChunkMatch) g.Node {
+	note := match.Note
+	href := "/" + note.Slug
+	if anchor := engine.SlugifyHeading(match.Chunk.Heading); anchor != "" {
+		href += "#" + anchor
+	}
+	description := engine.ExtractDescription(match.Chunk.Text)
+	if description == "" {
+		description = engine.ExtractDescription(note.Content)
+	}
+
+	return Div(
+		Class("bg-white border border-gray-200 rounded-lg p-4 hover:shadow-md transition-shadow dark:bg-gray-900 dark:border-gray-700"),
+		g.Attr("data-slug", note.Slug),
+		A(
+			Href(href),
+			Class("block"),
+			g.Attr("hx-boost", "true"),
+			H3(
+				Class("text-lg font-semibold text-gray-900 mb-2 hover:text-blue-600 dark:text-gray-100 dark:hover:text-blue-400"),
+				g.Text(note.Title),
+				g.If(note.Status != "", Span(Class("ml-2"), rs.StatusBadge(note.Status))),
+				g.If(note.IsDraft, Span(Class("ml-2"), DraftBadge())),
+			),
+			g.If(match.Chunk.Heading != "",
+				P(
+					Class("semantic-heading text-sm font-medium text-gray-700 mb-1 dark:text-gray-300"),
+					g.Text(match.Chunk.Heading),
+				),
+			),
+			g.If(description != "",
+				P(
+					Class("text-sm text-gray-600 line-clamp-3 dark:text-gray-400"),
+					g.Text(description),
+				),
+			),
+		),
+	)
+}
+
+// PatternSearchResults renders the unified search page with exact phrase or regex matches, grouped by note
+func (rs Resource) PatternSearchResults(
+	notesService *engine.NotesService,
+	query string,
+	mode string,
+	results []engine.PatternResult,
+	searchErr error,
+) (g.Node, error) {
+	var resultsContent g.Node
+	switch {
+	case searchErr != nil:
+		resultsContent = P(
+			Class("text-sm text-red-700 bg-red-50 border border-red-200 rounded-lg px-4 py-3 dark:text-red-300 dark:bg-red-950 dark:border-red-800"),
+			g.Textf("Invalid search: %s", searchErr.Error()),
+		)
+	case len(results) == 0:
+		resultsContent = P(
+			Class("text-sm italic text-gray-600 dark:text-gray-400"),
+			g.Text("No matches found."),
+		)
+	default:
+		resultsContent = g.Group(g.Map(results, rs.renderPatternResult))
+	}
+
+	content := Div(
+		Class("max-w-none"),
+		rs.unifiedSearchForm(query, mode, "", false),
+		Div(
+			ID("search-results-container"),
+			Class("space-y-6"),
+			resultsContent,
+		),
+	)
+
+	return rs.searchPage(notesService, fmt.Sprintf("Search: %s", query), content), nil
+}
+
+// renderPatternResult renders the matching lines of one note with their context
+func (rs Resource) renderPatternResult(result engine.PatternResult) g.Node {
+	return Section(
+		Div(
+			Class("flex items-baseline gap-2 mb-2"),
+			A(
+				Href("/"+result.Note.Slug),
+				Class("font-medium text-blue-700 hover:underline dark:text-blue-300"),
+				g.Attr("hx-boost", "true"),
+				g.Text(result.Note.Title),
+			),
+			Span(
+				Class("text-xs text-gray-500 font-mono dark:text-gray-400"),
+				g.Text(result.Note.Path),
+			),
+		),
+		Div(
+			Class("space-y-2"),
+			g.Group(g.Map(result.Matches, func(match engine.PatternMatch) g.Node {
+				return renderPatternMatch(match)
+			})),
+		),
+		g.If(result.Truncated,
+			P(Class("text-xs text-gray-500 mt-1 dark:text-gray-400"), g.Text("More matches in this note are not shown.")),
+		),
+		g.If(result.TimedOut,
+			P(Class("text-xs text-amber-700 mt-1 dark:text-amber-300"), g.Text("Search stopped early in this note, the pattern is too slow.")),
+		),
+	)
+}
+
+// renderPatternMatch renders a matched line, highlighted, between its context lines
+func renderPatternMatch(match engine.PatternMatch) g.Node {
+	contextLine := func(lineNum int, line string) g.Node {
+		return Div(
+			Class("flex gap-3 text-gray-500 dark:text-gray-400"),
+			Span(Class("select-none w-10 text-right shrink-0"), g.Textf("%d", lineNum+1)),
+			Span(Class("whitespace-pre-wrap break-all"), g.Text(line)),
+		)
+	}
+
+	var lines []g.Node
+	for i, line := range match.Before {
+		lines = append(lines, contextLine(match.LineNum-len(match.Before)+i, line))
+	}
+	lines = append(lines, Div(
+		Class("flex gap-3 bg-yellow-50 text-gray-900 dark:bg-yellow-950 dark:text-gray-100"),
+		Span(Class("select-none w-10 text-right shrink-0 text-gray-500 dark:text-gray-400"), g.Textf("%d", match.LineNum+1)),
+		Span(
+			Class("whitespace-pre-wrap break-all"),
+			g.Text(match.Line[:match.Start]),
+			Mark(Class(highlightClass), g.Text(match.Line[match.Start:match.End])),
+			g.Text(match.Line[match.End:]),
+		),
+	))
+	for i, line := range match.After {
+		lines = append(lines, contextLine(match.LineNum+1+i, line))
+	}
+
+	return Div(
+		Class("border border-gray-200 rounded-lg py-2 pr-3 font-mono text-xs overflow-x-auto dark:border-gray-700"),
+		g.Group(lines),
+	)
+}
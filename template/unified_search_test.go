@@ -0,0 +1,96 @@
+package template
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/EwenQuim/pluie/config"
+	"github.com/EwenQuim/pluie/engine"
+	"github.com/EwenQuim/pluie/model"
+)
+
+func TestPatternSearchResults(t *testing.T) {
+	notesMap := make(map[string]model.Note)
+	notesService := engine.NewNotesService(&notesMap, &engine.TreeNode{Name: "root", IsFolder: true}, nil)
+
+	render := func(rs Resource, results []engine.PatternResult, searchErr error) string {
+		result, err := rs.PatternSearchResults(notesService, "re:TODO", SearchModeRegex, results, searchErr)
+		if err != nil {
+			t.Fatalf("PatternSearchResults() returned error: %v", err)
+		}
+		var sb strings.Builder
+		if err := result.Render(&sb); err != nil {
+			t.Fatalf("Render() returned error: %v", err)
+		}
+		return sb.String()
+	}
+
+	results := []engine.PatternResult{{
+		Note: model.Note{Title: "Alpha", Slug: "alpha", Path: "alpha.md"},
+		Matches: []engine.PatternMatch{{
+			LineNum: 3,
+			Line:    "fix TODO(ewen) soon",
+			Start:   4,
+			End:     14,
+			Before:  []string{"before"},
+			After:   []string{"after"},
+		}},
+		Truncated: true,
+	}}
+
+	rs := NewResource(&config.Config{SiteTitle: "Pluie", RegexSearch: true})
+	html := render(rs, results, nil)
+	for _, expected := range []string{
+		`href="/alpha"`,
+		"alpha.md",
+		"fix <mark",
+		">TODO(ewen)</mark> soon",
+		">3</span>", // Context line before, numbered from 1
+		">4</span>",
+		">5</span>",
+		"More matches in this note are not shown.",
+		`<option value="regex" selected>`,
+	} {
+		if !strings.Contains(html, expected) {
+			t.Errorf("expected %q in the results", expected)
+		}
+	}
+
+	if html := render(rs, nil, errors.New("missing closing )")); !strings.Contains(html, "Invalid search: missing closing )") {
+		t.Error("expected the search error")
+	}
+	if html := render(rs, nil, nil); !strings.Contains(html, "No matches found.") {
+		t.Error("expected the empty state")
+	}
+
+	disabled := NewResource(&config.Config{SiteTitle: "Pluie", RegexSearch: false})
+	if html := render(disabled, results, nil); strings.Contains(html, `value="regex"`) {
+		t.Error("regex mode should not be offered when disabled")
+	}
+}
+
+func TestUnifiedSearchResults_Skeletons(t *testing.T) {
+	notesMap := make(map[string]model.Note)
+	notesService := engine.NewNotesService(&notesMap, &engine.TreeNode{Name: "root", IsFolder: true}, nil)
+	rs := NewResource(&config.Config{})
+
+	for _, titleMatches := range [][]model.Note{nil, {{Title: "Garden", Slug: "garden"}}} {
+		result, err := rs.UnifiedSearchResults(notesService, "garden", "", titleMatches, nil, nil, nil)
+		if err != nil {
+			t.Fatalf("UnifiedSearchResults() returned error: %v", err)
+		}
+		var sb strings.Builder
+		if err := result.Render(&sb); err != nil {
+			t.Fatalf("Render() returned error: %v", err)
+		}
+		html := sb.String()
+
+		if count := strings.Count(html, `class="search-skeleton `); count != searchSkeletonCount {
+			t.Errorf("expected %d skeleton cards with %d title matches, got %d", searchSkeletonCount, len(titleMatches), count)
+		}
+		if !strings.Contains(html, "addEventListener('semantic-skipped'") {
+			t.Error("the script should end the loading state when semantic search is skipped")
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/EwenQuim/pluie/model"
+)
+
+// trashMaxEntries bounds the trash, the notes deleted first are purged first
+const trashMaxEntries = 100
+
+// Trash keeps the notes deleted from the vault for a grace period: their URL keeps showing them
+// with a banner, and their content can be recovered from the diagnostics page.
+// Trashed notes are not in the NotesService, so they are excluded from the tree, search and tags.
+// A nil Trash keeps nothing.
+type Trash struct {
+	mu          sync.Mutex
+	basePath    string
+	gracePeriod time.Duration
+	file        string // JSON file persisting the trash across restarts, "" to keep it in memory only
+	entries     map[string]model.TrashedNote
+	now         func() time.Time // Replaced in tests
+}
+
+// NewTrash creates a trash for the notes of basePath, loading file if set
+func NewTrash(basePath string, gracePeriod time.Duration, file string) *Trash {
+	t := &Trash{
+		basePath:    basePath,
+		gracePeriod: gracePeriod,
+		file:        file,
+		entries:     make(map[string]model.TrashedNote),
+		now:         time.Now,
+	}
+
+	if file != "" {
+		if err := t.load(); err != nil {
+			slog.Warn("Failed to load trash, starting empty", "file", file, "error", err)
+		}
+	}
+	return t
+}
+
+// Capture trashes the notes of previous that are not in current anymore because their file was deleted.
+// Notes made private or moved (same content under another slug) are not trashed, and notes whose file is back leave the trash.
+func (t *Trash) Capture(previous, current map[string]model.Note) {
+	if t == nil {
+		return
+	}
+
+	contents := make(map[string]bool, len(current))
+	for _, note := range current {
+		contents[note.Content] = true
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	changed := false
+	for slug, trashed := range t.entries {
+		if _, ok := current[slug]; ok || !t.isDeleted(trashed.Note) {
+			delete(t.entries, slug)
+			changed = true
+		}
+	}
+	for slug, note := range previous {
+		if _, ok := current[slug]; ok || (note.Content != "" && contents[note.Content]) {
+			continue
+		}
+		if !t.isDeleted(note) {
+			continue
+		}
+
+		t.entries[slug] = model.TrashedNote{Note: note, DeletedAt: now, ExpiresAt: now.Add(t.gracePeriod)}
+		slog.Info("Note deleted, kept in the trash", "slug", slug, "path", note.Path, "grace_period", t.gracePeriod.String())
+		changed = true
+	}
+
+	if t.purge(now) || changed {
+		t.save()
+	}
+}
+
+// isDeleted reports whether the file of a note no longer exists
+func (t *Trash) isDeleted(note model.Note) bool {
+	_, err := os.Stat(filepath.Join(t.basePath, note.Path))
+	return errors.Is(err, fs.ErrNotExist)
+}
+
+// Get returns the trashed note at slug, if deleted less than the grace period ago
+func (t *Trash) Get(slug string) (model.TrashedNote, bool) {
+	if t == nil {
+		return model.TrashedNote{}, false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.purge(t.now()) {
+		t.save()
+	}
+	trashed, ok := t.entries[slug]
+	return trashed, ok
+}
+
+// List returns the trashed notes, most recently deleted first
+func (t *Trash) List() []model.TrashedNote {
+	if t == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.purge(t.now()) {
+		t.save()
+	}
+	return sortedTrash(t.entries)
+}
+
+// purge removes the expired entries and the oldest ones beyond trashMaxEntries.
+// Must be called with mu held. Returns whether entries were removed.
+func (t *Trash) purge(now time.Time) bool {
+	purged := false
+	for slug, trashed := range t.entries {
+		if !now.Before(trashed.ExpiresAt) {
+			slog.Info("Trashed note purged", "slug", slug)
+			delete(t.entries, slug)
+			purged = true
+		}
+	}
+
+	if len(t.entries) > trashMaxEntries {
+		for _, trashed := range sortedTrash(t.entries)[trashMaxEntries:] {
+			delete(t.entries, trashed.Note.Slug)
+		}
+		purged = true
+	}
+	return purged
+}
+
+// sortedTrash returns the entries, most recently deleted first
+func sortedTrash(entries map[string]model.TrashedNote) []model.TrashedNote {
+	list := make([]model.TrashedNote, 0, len(entries))
+	for _, trashed := range entries {
+		list = append(list, trashed)
+	}
+	slices.SortFunc(list, func(a, b model.TrashedNote) int {
+		if c := b.DeletedAt.Compare(a.DeletedAt); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Note.Slug, b.Note.Slug)
+	})
+	return list
+}
+
+// load reads the trash file, a missing file is an empty trash
+func (t *Trash) load() error {
+	data, err := os.ReadFile(t.file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("reading trash file: %w", err)
+	}
+
+	var list []model.TrashedNote
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("parsing trash file: %w", err)
+	}
+	for _, trashed := range list {
+		t.entries[trashed.Note.Slug] = trashed
+	}
+
+	slog.Info("Loaded trash", "trashed_notes", len(t.entries))
+	return nil
+}
+
+// save writes the trash file, if any. Must be called with mu held.
+func (t *Trash) save() {
+	if t.file == "" {
+		return
+	}
+
+	data, err := json.MarshalIndent(sortedTrash(t.entries), "", "  ")
+	if err != nil {
+		slog.Error("Failed to marshal trash", "error", err)
+		return
+	}
+	if err := os.WriteFile(t.file, data, 0644); err != nil {
+		slog.Error("Failed to write trash file", "file", t.file, "error", err)
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/EwenQuim/pluie/config"
+	"github.com/EwenQuim/pluie/engine"
+	"github.com/EwenQuim/pluie/model"
+	"github.com/EwenQuim/pluie/template"
+	"github.com/go-fuego/fuego"
+)
+
+// testClock is a settable clock for the trash
+type testClock struct{ now time.Time }
+
+func (c *testClock) Now() time.Time { return c.now }
+
+func newTestTrash(t *testing.T, basePath, file string) (*Trash, *testClock) {
+	t.Helper()
+	clock := &testClock{now: time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)}
+	trash := NewTrash(basePath, 7*24*time.Hour, file)
+	trash.now = clock.Now
+	return trash, clock
+}
+
+func trashNotes(notes ...model.Note) map[string]model.Note {
+	notesMap := make(map[string]model.Note)
+	for _, note := range notes {
+		notesMap[note.Slug] = note
+	}
+	return notesMap
+}
+
+func TestTrash_Capture(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "private.md", "Still here")
+	writeTestFile(t, dir, "new-place.md", "Moved content")
+
+	deleted := model.Note{Title: "Deleted", Slug: "deleted", Path: "deleted.md", Content: "Deleted content"}
+	private := model.Note{Title: "Private", Slug: "private", Path: "private.md", Content: "Still here"}
+	moved := model.Note{Title: "Moved", Slug: "old-place", Path: "old-place.md", Content: "Moved content"}
+	kept := model.Note{Title: "Kept", Slug: "kept", Path: "kept.md", Content: "Kept"}
+
+	trash, clock := newTestTrash(t, dir, "")
+	trash.Capture(
+		trashNotes(deleted, private, moved, kept),
+		trashNotes(kept, model.Note{Title: "Moved", Slug: "new-place", Path: "new-place.md", Content: "Moved content"}),
+	)
+
+	trashed, ok := trash.Get("deleted")
+	if !ok {
+		t.Fatal("the deleted note should be in the trash")
+	}
+	if trashed.Note.Content != "Deleted content" || !trashed.DeletedAt.Equal(clock.now) || !trashed.ExpiresAt.Equal(clock.now.Add(7*24*time.Hour)) {
+		t.Errorf("unexpected trashed note %+v", trashed)
+	}
+	for _, slug := range []string{"private", "old-place", "kept"} {
+		if _, ok := trash.Get(slug); ok {
+			t.Errorf("%q should not be in the trash", slug)
+		}
+	}
+
+	// The file is back: the note leaves the trash
+	writeTestFile(t, dir, "deleted.md", "Deleted content")
+	trash.Capture(trashNotes(kept), trashNotes(kept, deleted))
+	if _, ok := trash.Get("deleted"); ok {
+		t.Error("a restored note should leave the trash")
+	}
+}
+
+func TestTrash_Purge(t *testing.T) {
+	dir := t.TempDir()
+	trash, clock := newTestTrash(t, dir, "")
+
+	first := model.Note{Slug: "first", Path: "first.md"}
+	trash.Capture(trashNotes(first), nil)
+	clock.now = clock.now.Add(3 * 24 * time.Hour)
+	second := model.Note{Slug: "second", Path: "second.md"}
+	trash.Capture(trashNotes(second), nil)
+
+	if list := trash.List(); len(list) != 2 || list[0].Note.Slug != "second" || list[1].Note.Slug != "first" {
+		t.Fatalf("List() = %+v, want the most recently deleted first", list)
+	}
+
+	// 7 days after the first deletion, only the second note is left
+	clock.now = clock.now.Add(4 * 24 * time.Hour)
+	if _, ok := trash.Get("first"); ok {
+		t.Error("first should be purged after the grace period")
+	}
+	if _, ok := trash.Get("second"); !ok {
+		t.Error("second is still in its grace period")
+	}
+
+	clock.now = clock.now.Add(3 * 24 * time.Hour)
+	if list := trash.List(); len(list) != 0 {
+		t.Errorf("List() = %+v, want an empty trash", list)
+	}
+
+	// Bounded: the notes deleted first go away
+	for i := range trashMaxEntries + 5 {
+		clock.now = clock.now.Add(time.Second)
+		trash.Capture(trashNotes(model.Note{Slug: strings.Repeat("n", i+1), Path: "gone.md"}), nil)
+	}
+	list := trash.List()
+	if len(list) != trashMaxEntries || list[len(list)-1].Note.Slug != strings.Repeat("n", 6) {
+		t.Errorf("the trash should keep the %d most recently deleted notes, got %d", trashMaxEntries, len(list))
+	}
+}
+
+func TestTrash_Persistence(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(t.TempDir(), "trash.json")
+
+	trash, _ := newTestTrash(t, dir, file)
+	trash.Capture(trashNotes(model.Note{Title: "Gone", Slug: "gone", Path: "gone.md", Content: "Gone content"}), nil)
+	if _, err := os.Stat(file); err != nil {
+		t.Fatalf("the trash file should be written: %v", err)
+	}
+
+	reloaded, _ := newTestTrash(t, dir, file)
+	trashed, ok := reloaded.Get("gone")
+	if !ok || trashed.Note.Content != "Gone content" {
+		t.Errorf("the trash should survive a restart, got %+v", trashed)
+	}
+}
+
+func TestServer_DeletedNote(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "kept.md", "---\npublish: true\n---\nKept content")
+	writeTestFile(t, dir, "gone.md", "---\npublish: true\ntags: [recipes]\n---\n# Gone\n\nGone content")
+
+	cfg := &config.Config{Path: dir, SiteTitle: "Pluie", SiteTimezone: "UTC", RegexSearch: true}
+	notesMap, tree, tagIndex, err := loadNotes(dir, cfg)
+	if err != nil {
+		t.Fatalf("loadNotes() error: %v", err)
+	}
+	trash, _ := newTestTrash(t, dir, "")
+	server := &Server{
+		NotesService: engine.NewNotesService(notesMap, tree, tagIndex),
+		rs:           template.NewResource(cfg),
+		cfg:          cfg,
+		trash:        trash,
+	}
+	fuegoServer := fuego.NewServer()
+	server.registerRoutes(fuegoServer)
+
+	get := func(url string) string {
+		w := httptest.NewRecorder()
+		fuegoServer.Mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+		return w.Body.String()
+	}
+
+	// Delete the file, like the watcher reloading after a delete event
+	if err := os.Remove(filepath.Join(dir, "gone.md")); err != nil {
+		t.Fatal(err)
+	}
+	notesMap, tree, tagIndex, err = loadNotes(dir, cfg)
+	if err != nil {
+		t.Fatalf("loadNotes() error: %v", err)
+	}
+	server.UpdateData(notesMap, tree, tagIndex)
+
+	page := get("/gone")
+	if !strings.Contains(page, "This note was deleted on Mar 10, 2024") || !strings.Contains(page, "Gone content") {
+		t.Error("the deleted note should still be served, with a banner")
+	}
+
+	// Not in the tree, search or tags anymore
+	if page := get("/kept"); strings.Contains(page, `href="/gone"`) {
+		t.Error("the deleted note should not be listed in the tree")
+	}
+	if page := get("/-/search?q=%22Gone+content%22"); strings.Contains(page, `href="/gone`) {
+		t.Error("the deleted note should not be found by search")
+	}
+	if notes := server.NotesService.GetTagIndex().GetNotesWithTag("recipes"); len(notes) != 0 {
+		t.Errorf("the deleted note should not be tagged, got %v", notes)
+	}
+
+	diagnostics := get("/-/diagnostics")
+	if !strings.Contains(diagnostics, "Recently deleted (1)") || !strings.Contains(diagnostics, "Copy markdown") {
+		t.Error("the diagnostics page should list the deleted note with a copy button")
+	}
+
+	// After the grace period the URL is a regular 404 page
+	trash.now = func() time.Time { return time.Date(2024, 3, 17, 12, 0, 0, 0, time.UTC) }
+	if page := get("/gone"); strings.Contains(page, "Gone content") || !strings.Contains(page, "does not exist") {
+		t.Error("the deleted note should not be served after the grace period")
+	}
+}
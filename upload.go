@@ -0,0 +1,444 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/EwenQuim/pluie/config"
+)
+
+// RemoteObject describes a file already present at the upload destination
+type RemoteObject struct {
+	Key  string
+	ETag string // MD5 hex digest of the content for single-part uploads
+	Size int64
+}
+
+// Uploader abstracts the remote storage the static site is synced to
+type Uploader interface {
+	// List returns all remote objects under the prefix, keyed by object key
+	List(ctx context.Context, prefix string) (map[string]RemoteObject, error)
+	// Put uploads (or overwrites) a single object
+	Put(ctx context.Context, key string, body []byte, contentType, cacheControl string) error
+	// Delete removes a single object
+	Delete(ctx context.Context, key string) error
+}
+
+// UploadSummary reports what a sync did
+type UploadSummary struct {
+	Uploaded int
+	Skipped  int
+	Deleted  int
+	Bytes    int64 // Bytes uploaded
+}
+
+// uploadStaticSite syncs the generated static site in cfg.Output to cfg.Upload
+func uploadStaticSite(ctx context.Context, cfg *config.Config) error {
+	bucket, prefix, err := parseS3URL(cfg.Upload)
+	if err != nil {
+		return err
+	}
+
+	client, err := newS3Client(cfg, bucket)
+	if err != nil {
+		return fmt.Errorf("creating s3 client: %w", err)
+	}
+
+	slog.Info("Uploading static site", "bucket", bucket, "prefix", prefix, "prune", cfg.Prune)
+
+	summary, err := syncDirectory(ctx, client, cfg.Output, prefix, cfg.Prune)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("Static site uploaded",
+		"uploaded", summary.Uploaded,
+		"skipped", summary.Skipped,
+		"deleted", summary.Deleted,
+		"bytes", summary.Bytes)
+	return nil
+}
+
+// parseS3URL splits "s3://bucket/some/prefix" into its bucket and key prefix
+func parseS3URL(raw string) (bucket, prefix string, err error) {
+	rest, ok := strings.CutPrefix(raw, "s3://")
+	if !ok {
+		return "", "", fmt.Errorf("invalid upload destination %q: must start with s3://", raw)
+	}
+
+	bucket, prefix, _ = strings.Cut(rest, "/")
+	if bucket == "" {
+		return "", "", fmt.Errorf("invalid upload destination %q: missing bucket", raw)
+	}
+
+	return bucket, strings.Trim(prefix, "/"), nil
+}
+
+// syncDirectory uploads every file of dir whose content differs from the remote copy.
+// With prune, remote objects under prefix that no longer exist locally are deleted.
+func syncDirectory(ctx context.Context, uploader Uploader, dir, prefix string, prune bool) (UploadSummary, error) {
+	var summary UploadSummary
+
+	remote, err := uploader.List(ctx, prefix)
+	if err != nil {
+		return summary, fmt.Errorf("listing remote objects: %w", err)
+	}
+
+	localKeys := make(map[string]bool)
+	err = filepath.WalkDir(dir, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, filePath)
+		if err != nil {
+			return err
+		}
+		relSlash := filepath.ToSlash(rel)
+		key := path.Join(prefix, relSlash)
+		localKeys[key] = true
+
+		content, err := os.ReadFile(filePath)
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", filePath, err)
+		}
+
+		sum := md5.Sum(content)
+		if existing, ok := remote[key]; ok && existing.ETag == hex.EncodeToString(sum[:]) {
+			summary.Skipped++
+			return nil
+		}
+
+		if err := uploader.Put(ctx, key, content, contentTypeFor(relSlash), cacheControlFor(relSlash)); err != nil {
+			return fmt.Errorf("uploading %s: %w", key, err)
+		}
+		slog.Debug("Uploaded file", "key", key, "bytes", len(content))
+		summary.Uploaded++
+		summary.Bytes += int64(len(content))
+		return nil
+	})
+	if err != nil {
+		return summary, err
+	}
+
+	if !prune {
+		return summary, nil
+	}
+
+	// Delete in a stable order so logs are reproducible
+	staleKeys := make([]string, 0)
+	for key := range remote {
+		if !localKeys[key] {
+			staleKeys = append(staleKeys, key)
+		}
+	}
+	sort.Strings(staleKeys)
+
+	for _, key := range staleKeys {
+		if err := uploader.Delete(ctx, key); err != nil {
+			return summary, fmt.Errorf("deleting %s: %w", key, err)
+		}
+		slog.Debug("Deleted remote file", "key", key)
+		summary.Deleted++
+	}
+
+	return summary, nil
+}
+
+// contentTypeFor returns the Content-Type to serve a generated file with
+func contentTypeFor(name string) string {
+	// Known types first so the result doesn't depend on the system mime database
+	switch strings.ToLower(path.Ext(name)) {
+	case ".html":
+		return "text/html; charset=utf-8"
+	case ".css":
+		return "text/css; charset=utf-8"
+	case ".js":
+		return "text/javascript; charset=utf-8"
+	case ".json":
+		return "application/json"
+	case ".xml":
+		return "application/xml"
+	case ".txt":
+		return "text/plain; charset=utf-8"
+	case ".md":
+		return "text/markdown; charset=utf-8"
+	case ".webp":
+		return "image/webp"
+	case ".ico":
+		return "image/x-icon"
+	}
+
+	if contentType := mime.TypeByExtension(path.Ext(name)); contentType != "" {
+		return contentType
+	}
+	return "application/octet-stream"
+}
+
+// cacheControlFor returns the Cache-Control header for a generated file.
+// Pages must revalidate so edits show up; bundled assets can be cached longer.
+func cacheControlFor(name string) string {
+	if strings.HasPrefix(name, "static/") {
+		return "public, max-age=86400"
+	}
+	if strings.HasSuffix(name, ".html") {
+		return "public, max-age=0, must-revalidate"
+	}
+	return "public, max-age=3600"
+}
+
+// s3Client is a minimal S3 REST client (path-style requests signed with SigV4)
+type s3Client struct {
+	endpoint        string // Scheme and host, like "https://s3.us-east-1.amazonaws.com"
+	bucket          string
+	region          string
+	accessKeyID     string
+	secretAccessKey string
+	httpClient      *http.Client
+	now             func() time.Time
+}
+
+// newS3Client creates an S3 client from the configured credentials
+func newS3Client(cfg *config.Config, bucket string) (*s3Client, error) {
+	if cfg.S3AccessKeyID == "" || cfg.S3SecretAccessKey == "" {
+		return nil, fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are required for s3 upload")
+	}
+
+	endpoint := cfg.S3Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", cfg.S3Region)
+	}
+
+	return &s3Client{
+		endpoint:        strings.TrimSuffix(endpoint, "/"),
+		bucket:          bucket,
+		region:          cfg.S3Region,
+		accessKeyID:     cfg.S3AccessKeyID,
+		secretAccessKey: cfg.S3SecretAccessKey,
+		httpClient:      &http.Client{Timeout: 60 * time.Second},
+		now:             time.Now,
+	}, nil
+}
+
+// listBucketResult is the subset of the ListObjectsV2 response we use
+type listBucketResult struct {
+	Contents []struct {
+		Key  string `xml:"Key"`
+		ETag string `xml:"ETag"`
+		Size int64  `xml:"Size"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// List implements Uploader using ListObjectsV2, following continuation tokens
+func (c *s3Client) List(ctx context.Context, prefix string) (map[string]RemoteObject, error) {
+	objects := make(map[string]RemoteObject)
+	token := ""
+
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		if prefix != "" {
+			query.Set("prefix", prefix+"/")
+		}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		body, err := c.do(ctx, http.MethodGet, "", query, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var result listBucketResult
+		if err := xml.Unmarshal(body, &result); err != nil {
+			return nil, fmt.Errorf("parsing list response: %w", err)
+		}
+
+		for _, object := range result.Contents {
+			objects[object.Key] = RemoteObject{
+				Key:  object.Key,
+				ETag: strings.Trim(object.ETag, `"`),
+				Size: object.Size,
+			}
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// Put implements Uploader
+func (c *s3Client) Put(ctx context.Context, key string, body []byte, contentType, cacheControl string) error {
+	_, err := c.do(ctx, http.MethodPut, key, nil, body, map[string]string{
+		"Content-Type":  contentType,
+		"Cache-Control": cacheControl,
+	})
+	return err
+}
+
+// Delete implements Uploader
+func (c *s3Client) Delete(ctx context.Context, key string) error {
+	_, err := c.do(ctx, http.MethodDelete, key, nil, nil, nil)
+	return err
+}
+
+// do sends a signed request for the given object key (empty for bucket-level requests)
+func (c *s3Client) do(ctx context.Context, method, key string, query url.Values, body []byte, headers map[string]string) ([]byte, error) {
+	canonicalURI := "/" + awsURIEncode(c.bucket, false)
+	if key != "" {
+		canonicalURI += "/" + awsURIEncode(key, true)
+	}
+	canonicalQuery := canonicalQueryString(query)
+
+	rawURL := c.endpoint + canonicalURI
+	if canonicalQuery != "" {
+		rawURL += "?" + canonicalQuery
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	payloadHash := sha256.Sum256(body)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payloadHash[:]))
+	signV4(req, canonicalURI, canonicalQuery, hex.EncodeToString(payloadHash[:]), "s3", c.region, c.accessKeyID, c.secretAccessKey, c.now())
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("s3 %s %s: %w", method, canonicalURI, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading s3 response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("s3 %s %s: status %d: %s", method, canonicalURI, resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	return respBody, nil
+}
+
+// signV4 adds an AWS Signature Version 4 Authorization header to the request.
+// The host header and every x-amz-* header already set on the request are signed.
+func signV4(req *http.Request, canonicalURI, canonicalQuery, payloadHash, service, region, accessKeyID, secretAccessKey string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	headerValues := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-") {
+			headerValues[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+
+	headerNames := make([]string, 0, len(headerValues))
+	for name := range headerValues {
+		headerNames = append(headerNames, name)
+	}
+	sort.Strings(headerNames)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range headerNames {
+		canonicalHeaders.WriteString(name + ":" + headerValues[name] + "\n")
+	}
+	signedHeaders := strings.Join(headerNames, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI,
+		canonicalQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	signingKey := hmacSHA256([]byte("AWS4"+secretAccessKey), date)
+	signingKey = hmacSHA256(signingKey, region)
+	signingKey = hmacSHA256(signingKey, service)
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKeyID, scope, signedHeaders, signature))
+}
+
+// hmacSHA256 computes HMAC-SHA256(key, data)
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQueryString encodes query parameters sorted by key, as SigV4 requires
+func canonicalQueryString(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, key := range keys {
+		for _, value := range query[key] {
+			parts = append(parts, awsURIEncode(key, false)+"="+awsURIEncode(value, false))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// awsURIEncode percent-encodes everything except unreserved characters (and slashes if keepSlash)
+func awsURIEncode(s string, keepSlash bool) string {
+	var result strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case ch >= 'A' && ch <= 'Z', ch >= 'a' && ch <= 'z', ch >= '0' && ch <= '9',
+			ch == '-', ch == '_', ch == '.', ch == '~':
+			result.WriteByte(ch)
+		case ch == '/' && keepSlash:
+			result.WriteByte(ch)
+		default:
+			fmt.Fprintf(&result, "%%%02X", ch)
+		}
+	}
+	return result.String()
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/EwenQuim/pluie/config"
+)
+
+// fakeS3Object is an object stored by fakeS3
+type fakeS3Object struct {
+	body         []byte
+	contentType  string
+	cacheControl string
+}
+
+// fakeS3 is an in-memory S3 server supporting ListObjectsV2, PUT and DELETE
+type fakeS3 struct {
+	mu      sync.Mutex
+	bucket  string
+	objects map[string]fakeS3Object
+	puts    []string
+	deletes []string
+}
+
+func newFakeS3(t *testing.T, bucket string) (*fakeS3, *httptest.Server) {
+	fake := &fakeS3{bucket: bucket, objects: make(map[string]fakeS3Object)}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, server
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+		http.Error(w, "missing signature", http.StatusForbidden)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := strings.TrimPrefix(r.URL.Path, "/"+f.bucket)
+	key = strings.TrimPrefix(key, "/")
+
+	switch r.Method {
+	case http.MethodGet:
+		prefix := r.URL.Query().Get("prefix")
+		var result listBucketResultXML
+		for objectKey, object := range f.objects {
+			if strings.HasPrefix(objectKey, prefix) {
+				sum := md5.Sum(object.body)
+				result.Contents = append(result.Contents, listEntryXML{
+					Key:  objectKey,
+					ETag: `"` + hex.EncodeToString(sum[:]) + `"`,
+					Size: int64(len(object.body)),
+				})
+			}
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		_ = xml.NewEncoder(w).Encode(result)
+	case http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		f.objects[key] = fakeS3Object{
+			body:         body,
+			contentType:  r.Header.Get("Content-Type"),
+			cacheControl: r.Header.Get("Cache-Control"),
+		}
+		f.puts = append(f.puts, key)
+	case http.MethodDelete:
+		delete(f.objects, key)
+		f.deletes = append(f.deletes, key)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+type listEntryXML struct {
+	Key  string `xml:"Key"`
+	ETag string `xml:"ETag"`
+	Size int64  `xml:"Size"`
+}
+
+type listBucketResultXML struct {
+	XMLName  xml.Name       `xml:"ListBucketResult"`
+	Contents []listEntryXML `xml:"Contents"`
+}
+
+func (f *fakeS3) resetCalls() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.puts = nil
+	f.deletes = nil
+}
+
+func testS3Client(t *testing.T, endpoint, bucket string) *s3Client {
+	client, err := newS3Client(&config.Config{
+		S3Endpoint:        endpoint,
+		S3Region:          "auto",
+		S3AccessKeyID:     "AKID",
+		S3SecretAccessKey: "secret",
+	}, bucket)
+	if err != nil {
+		t.Fatalf("newS3Client error: %v", err)
+	}
+	return client
+}
+
+func writeTestFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	fullPath := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseS3URL(t *testing.T) {
+	tests := []struct {
+		raw        string
+		wantBucket string
+		wantPrefix string
+		wantErr    bool
+	}{
+		{raw: "s3://bucket", wantBucket: "bucket"},
+		{raw: "s3://bucket/", wantBucket: "bucket"},
+		{raw: "s3://bucket/site/prod/", wantBucket: "bucket", wantPrefix: "site/prod"},
+		{raw: "s3://", wantErr: true},
+		{raw: "https://bucket/prefix", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			bucket, prefix, err := parseS3URL(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseS3URL(%q) error = %v, wantErr %v", tt.raw, err, tt.wantErr)
+			}
+			if bucket != tt.wantBucket || prefix != tt.wantPrefix {
+				t.Errorf("parseS3URL(%q) = (%q, %q), want (%q, %q)", tt.raw, bucket, prefix, tt.wantBucket, tt.wantPrefix)
+			}
+		})
+	}
+}
+
+func TestContentTypeFor(t *testing.T) {
+	tests := map[string]string{
+		"index.html":            "text/html; charset=utf-8",
+		"static/app.js":         "text/javascript; charset=utf-8",
+		"static/tailwind.css":   "text/css; charset=utf-8",
+		"static/pluie.webp":     "image/webp",
+		"sitemap.xml":           "application/xml",
+		"unknown.extensionless": "application/octet-stream",
+	}
+
+	for name, expected := range tests {
+		if got := contentTypeFor(name); got != expected {
+			t.Errorf("contentTypeFor(%q) = %q, want %q", name, got, expected)
+		}
+	}
+}
+
+func TestSyncDirectory(t *testing.T) {
+	fake, server := newFakeS3(t, "site")
+	client := testS3Client(t, server.URL, "site")
+	dir := t.TempDir()
+
+	writeTestFile(t, dir, "index.html", "<html>home</html>")
+	writeTestFile(t, dir, "notes/a/index.html", "<html>a</html>")
+	writeTestFile(t, dir, "static/app.js", "console.log(1)")
+
+	t.Run("first sync uploads everything", func(t *testing.T) {
+		summary, err := syncDirectory(t.Context(), client, dir, "prod", false)
+		if err != nil {
+			t.Fatalf("syncDirectory error: %v", err)
+		}
+		if summary.Uploaded != 3 || summary.Skipped != 0 || summary.Deleted != 0 {
+			t.Errorf("unexpected summary %+v", summary)
+		}
+		if summary.Bytes != int64(len("<html>home</html>")+len("<html>a</html>")+len("console.log(1)")) {
+			t.Errorf("unexpected uploaded bytes %d", summary.Bytes)
+		}
+
+		page := fake.objects["prod/notes/a/index.html"]
+		if page.contentType != "text/html; charset=utf-8" {
+			t.Errorf("unexpected page content type %q", page.contentType)
+		}
+		if page.cacheControl != "public, max-age=0, must-revalidate" {
+			t.Errorf("unexpected page cache control %q", page.cacheControl)
+		}
+		asset := fake.objects["prod/static/app.js"]
+		if asset.contentType != "text/javascript; charset=utf-8" || asset.cacheControl != "public, max-age=86400" {
+			t.Errorf("unexpected asset headers %+v", asset)
+		}
+	})
+
+	t.Run("unchanged files are skipped", func(t *testing.T) {
+		fake.resetCalls()
+		writeTestFile(t, dir, "index.html", "<html>home v2</html>")
+
+		summary, err := syncDirectory(t.Context(), client, dir, "prod", false)
+		if err != nil {
+			t.Fatalf("syncDirectory error: %v", err)
+		}
+		if summary.Uploaded != 1 || summary.Skipped != 2 {
+			t.Errorf("unexpected summary %+v", summary)
+		}
+		if len(fake.puts) != 1 || fake.puts[0] != "prod/index.html" {
+			t.Errorf("expected only prod/index.html to be uploaded, got %v", fake.puts)
+		}
+	})
+
+	t.Run("removed files are kept without prune", func(t *testing.T) {
+		fake.resetCalls()
+		if err := os.RemoveAll(filepath.Join(dir, "notes")); err != nil {
+			t.Fatal(err)
+		}
+
+		summary, err := syncDirectory(t.Context(), client, dir, "prod", false)
+		if err != nil {
+			t.Fatalf("syncDirectory error: %v", err)
+		}
+		if summary.Deleted != 0 || len(fake.deletes) != 0 {
+			t.Errorf("expected no deletions without prune, got %v", fake.deletes)
+		}
+	})
+
+	t.Run("prune deletes files missing locally", func(t *testing.T) {
+		fake.resetCalls()
+		// Objects outside the prefix must never be touched
+		fake.objects["other/index.html"] = fakeS3Object{body: []byte("x")}
+
+		summary, err := syncDirectory(t.Context(), client, dir, "prod", true)
+		if err != nil {
+			t.Fatalf("syncDirectory error: %v", err)
+		}
+		if summary.Deleted != 1 || summary.Uploaded != 0 {
+			t.Errorf("unexpected summary %+v", summary)
+		}
+		if len(fake.deletes) != 1 || fake.deletes[0] != "prod/notes/a/index.html" {
+			t.Errorf("unexpected deletions %v", fake.deletes)
+		}
+		if _, ok := fake.objects["other/index.html"]; !ok {
+			t.Error("object outside the prefix was deleted")
+		}
+
+		var keys []string
+		for key := range fake.objects {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		expected := []string{"other/index.html", "prod/index.html", "prod/static/app.js"}
+		if strings.Join(keys, ",") != strings.Join(expected, ",") {
+			t.Errorf("remote objects = %v, want %v", keys, expected)
+		}
+	})
+}
+
+func TestSignV4(t *testing.T) {
+	// "get-vanilla" case from the AWS Signature Version 4 test suite
+	req, err := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	emptyHash := "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+
+	signV4(req, "/", "", emptyHash, "service", "us-east-1", "AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", now)
+
+	expected := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date, " +
+		"Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != expected {
+		t.Errorf("Authorization =\n%s\nwant\n%s", got, expected)
+	}
+}
+
+func TestAWSURIEncode(t *testing.T) {
+	tests := []struct {
+		input     string
+		keepSlash bool
+		expected  string
+	}{
+		{input: "notes/hello world.html", keepSlash: true, expected: "notes/hello%20world.html"},
+		{input: "notes/hello world.html", keepSlash: false, expected: "notes%2Fhello%20world.html"},
+		{input: "Q&A!", keepSlash: true, expected: "Q%26A%21"},
+		{input: "café~_-.", keepSlash: true, expected: "caf%C3%A9~_-."},
+	}
+
+	for _, tt := range tests {
+		if got := awsURIEncode(tt.input, tt.keepSlash); got != tt.expected {
+			t.Errorf("awsURIEncode(%q, %v) = %q, want %q", tt.input, tt.keepSlash, got, tt.expected)
+		}
+	}
+}
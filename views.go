@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// viewsFlushInterval is how often the view counts are written to their file
+const viewsFlushInterval = time.Minute
+
+// botUserAgents are parts of the user agents of crawlers, link previews and scripts, lowercase.
+// Their visits are not views.
+var botUserAgents = []string{
+	"bot", "crawl", "spider", "slurp", "preview", "monitor", "headless", "lighthouse",
+	"facebookexternalhit", "curl", "wget", "python-requests", "go-http-client", "okhttp", "httpclient",
+}
+
+// ViewCounter counts the views of the notes, by slug, in memory. Run writes them to a JSON file
+// from time to time and Flush at shutdown, so they survive restarts. A nil ViewCounter counts
+// nothing.
+type ViewCounter struct {
+	mu      sync.Mutex
+	counts  map[string]int64
+	file    string     // JSON file of the counts, "" to keep them in memory only
+	dirty   bool       // Counts changed since the last write
+	flushMu sync.Mutex // Held while writing the file, so writes never interleave
+}
+
+// NewViewCounter creates a view counter, loading file if set
+func NewViewCounter(file string) *ViewCounter {
+	v := &ViewCounter{counts: make(map[string]int64), file: file}
+	if file != "" {
+		if err := v.load(); err != nil {
+			slog.Warn("Failed to load view counts, starting from zero", "file", file, "error", err)
+		}
+	}
+	return v
+}
+
+// Record counts a view of the note at slug, unless the request comes from a bot or isn't a
+// page load, like HEAD requests
+func (v *ViewCounter) Record(r *http.Request, slug string) {
+	if v == nil || r.Method != http.MethodGet || isBot(r.UserAgent()) {
+		return
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.counts[slug]++
+	v.dirty = true
+}
+
+// Count returns the views of the note at slug
+func (v *ViewCounter) Count(slug string) int64 {
+	if v == nil {
+		return 0
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.counts[slug]
+}
+
+// Counts returns a copy of the views of every note seen, by slug
+func (v *ViewCounter) Counts() map[string]int64 {
+	if v == nil {
+		return nil
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return maps.Clone(v.counts)
+}
+
+// Run writes the counts every interval until ctx is done
+func (v *ViewCounter) Run(ctx context.Context, interval time.Duration) {
+	if v == nil || v.file == "" {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			v.Flush()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Flush writes the counts to the file when they changed, through a temporary file so a crash
+// never leaves half of it
+func (v *ViewCounter) Flush() {
+	if v == nil || v.file == "" {
+		return
+	}
+	v.flushMu.Lock()
+	defer v.flushMu.Unlock()
+
+	v.mu.Lock()
+	if !v.dirty {
+		v.mu.Unlock()
+		return
+	}
+	data, err := json.MarshalIndent(v.counts, "", "  ")
+	v.dirty = false
+	v.mu.Unlock()
+	if err != nil {
+		slog.Error("Failed to marshal view counts", "error", err)
+		return
+	}
+
+	tmp := v.file + ".tmp"
+	err = os.WriteFile(tmp, data, 0644)
+	if err == nil {
+		err = os.Rename(tmp, v.file)
+	}
+	if err != nil {
+		slog.Error("Failed to write view counts", "file", v.file, "error", err)
+		v.mu.Lock()
+		v.dirty = true // Tried again at the next flush
+		v.mu.Unlock()
+	}
+}
+
+// load reads the counts file, a missing file is no views
+func (v *ViewCounter) load() error {
+	data, err := os.ReadFile(v.file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("reading view counts: %w", err)
+	}
+	if err := json.Unmarshal(data, &v.counts); err != nil {
+		return fmt.Errorf("parsing view counts: %w", err)
+	}
+	if v.counts == nil {
+		v.counts = make(map[string]int64)
+	}
+	slog.Info("Loaded view counts", "notes", len(v.counts))
+	return nil
+}
+
+// isBot reports whether a user agent is a crawler or a script, requests without one included
+func isBot(userAgent string) bool {
+	userAgent = strings.ToLower(userAgent)
+	if userAgent == "" {
+		return true
+	}
+	for _, bot := range botUserAgents {
+		if strings.Contains(userAgent, bot) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/EwenQuim/pluie/config"
+	"github.com/EwenQuim/pluie/engine"
+	"github.com/EwenQuim/pluie/template"
+	"github.com/go-fuego/fuego"
+)
+
+func viewRequest(method, userAgent string) *http.Request {
+	r := httptest.NewRequest(method, "/garden", nil)
+	r.Header.Set("User-Agent", userAgent)
+	return r
+}
+
+func TestViewCounter(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "views.json")
+	views := NewViewCounter(file)
+
+	browser := "Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0"
+	views.Record(viewRequest(http.MethodGet, browser), "garden")
+	for _, r := range []*http.Request{
+		viewRequest(http.MethodGet, "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"),
+		viewRequest(http.MethodGet, "curl/8.5.0"),
+		viewRequest(http.MethodGet, ""),
+		viewRequest(http.MethodHead, browser),
+	} {
+		views.Record(r, "garden")
+	}
+	if got := views.Count("garden"); got != 1 {
+		t.Errorf("Count() = %d, want 1 without the bots and HEAD requests", got)
+	}
+
+	// Concurrent views are all counted
+	var wg sync.WaitGroup
+	for range 100 {
+		wg.Go(func() { views.Record(viewRequest(http.MethodGet, browser), "garden") })
+	}
+	wg.Wait()
+	if got := views.Count("garden"); got != 101 {
+		t.Errorf("Count() = %d after concurrent views, want 101", got)
+	}
+
+	// A restart finds the flushed counts
+	views.Flush()
+	if got := NewViewCounter(file).Count("garden"); got != 101 {
+		t.Errorf("Count() after a restart = %d, want 101", got)
+	}
+
+	var nilViews *ViewCounter
+	nilViews.Record(viewRequest(http.MethodGet, browser), "garden")
+	if nilViews.Count("garden") != 0 || nilViews.Counts() != nil {
+		t.Error("a nil ViewCounter should count nothing")
+	}
+}
+
+func TestViewStats(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "Garden.md", "---\npublish: true\n---\nTomatoes\n")
+	writeTestFile(t, dir, "Kitchen.md", "---\npublish: true\n---\nBread\n")
+	cfg := &config.Config{Path: dir, SiteTitle: "Pluie", ShowViewCount: true}
+	notesMap, tree, tagIndex, err := loadNotes(dir, cfg)
+	if err != nil {
+		t.Fatalf("loadNotes() error: %v", err)
+	}
+	views := NewViewCounter("")
+	server := &Server{NotesService: engine.NewNotesService(notesMap, tree, tagIndex), rs: template.NewResource(cfg), cfg: cfg, views: views}
+	fuegoServer := fuego.NewServer()
+	server.registerRoutes(fuegoServer)
+	get := func(path string) string {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.Header.Set("User-Agent", "Mozilla/5.0 Firefox/128.0")
+		w := httptest.NewRecorder()
+		fuegoServer.Mux.ServeHTTP(w, r)
+		return w.Body.String()
+	}
+
+	get("/garden")
+	if page := get("/garden"); !strings.Contains(page, "2 views") {
+		t.Errorf("the note page should show its views with SHOW_VIEW_COUNT, got:\n%s", page)
+	}
+	get("/kitchen")
+	get("/-/tags")
+	get("/static/app.js")
+	views.counts["secret"] = 1000 // A note made private since
+
+	page := get("/-/stats")
+	garden, kitchen := strings.Index(page, `href="/garden"`), strings.Index(page, `href="/kitchen"`)
+	if garden < 0 || kitchen < 0 || garden > kitchen {
+		t.Errorf("the stats should list the most viewed notes first, got:\n%s", page)
+	}
+	if strings.Contains(page, "secret") || len(views.Counts()) != 3 {
+		t.Errorf("only note pages of public notes should be listed, counts: %v", views.Counts())
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/EwenQuim/pluie/config"
@@ -15,21 +16,85 @@ import (
 
 // loadNotes loads all notes from the given path, processes them, and returns the data structures
 func loadNotes(basePath string, cfg *config.Config) (*map[string]model.Note, *engine.TreeNode, engine.TagIndex, error) {
+	notesMap, tree, tagIndex, _, err := loadNotesWithPrivateSlugs(basePath, cfg)
+	return notesMap, tree, tagIndex, err
+}
+
+// loadNotesWithPrivateSlugs is loadNotes, also returning the slugs of the notes left out because
+// they are private, for NotesService.SetPrivateSlugs
+func loadNotesWithPrivateSlugs(basePath string, cfg *config.Config) (*map[string]model.Note, *engine.TreeNode, engine.TagIndex, []string, error) {
 	start := time.Now()
 
-	explorer := Explorer{
-		BasePath: basePath,
-	}
+	explorer := newExplorer(basePath, cfg)
 
 	notes, err := explorer.getFolderNotes("")
 	if err != nil {
-		return nil, nil, nil, err
+		return nil, nil, nil, nil, err
+	}
+	resolveDuplicatePermalinks(notes)
+
+	folders, err := explorer.getFolderSettings()
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	attachments, err := explorer.getAttachments(engine.ParseAttachmentExtensions(cfg.AttachmentExtensions))
+	if err != nil {
+		return nil, nil, nil, nil, err
 	}
 
 	slog.Info("Processed files", "in", time.Since(start).String())
 
-	// Filter out private notes
-	publicNotes := filterPublicNotes(notes, cfg.PublicByDefault)
+	// Last commit of each note, with a single git log when the vault is a git repository
+	engine.ApplyLastCommits(notes, engine.LastCommits(basePath))
+
+	// Resolve "status" frontmatter values against the configured statuses
+	statuses := engine.ParseStatuses(cfg.NoteStatuses, cfg.PrivateStatuses)
+	engine.ResolveStatuses(notes, statuses)
+
+	// In preview mode every note is shown, whatever its frontmatter says
+	if cfg.ForcePublic {
+		notes = forcePublicNotes(notes)
+	}
+
+	// The notes of _pluie/ customize pages like the 404 one, they are not notes of the site
+	systemNotes, notes := engine.SplitSystemNotes(notes)
+
+	// Notes of the "access: private" folders are only served with their share key, see
+	// NotesService.GetSharedNote. In preview mode they are shown like the others.
+	var sharedNotes []model.Note
+	if !cfg.ForcePublic {
+		sharedNotes, notes = engine.SplitSharedNotes(notes, cfg.ShareKeyFolders)
+	}
+
+	// Filter out private notes, including the ones private because of their status, and the
+	// drafts outside of the local server
+	publicNotes := filterVisibleNotes(notes, cfg.PublicByDefault, cfg.DraftsShown())
+	if !cfg.ForcePublic {
+		publicNotes = engine.HideStatusPrivateNotes(publicNotes, statuses)
+	}
+
+	// The other notes are only known by their slug, to render _pluie/private.md for them
+	privateNotes := engine.PrivateNoteStubs(notes, publicNotes)
+
+	// Resolve the attachment embeds and links, only the attachments of public notes are served,
+	// and never the ones of private folders
+	if !cfg.ForcePublic {
+		attachments = explorer.filterPrivateAttachments(attachments)
+	}
+	engine.ResolveAttachmentEmbeds(publicNotes, attachments)
+	engine.ResolveAttachmentEmbeds(sharedNotes, attachments)
+
+	// Slugs without URL-encoded punctuation, or accents, v1 URLs are redirected by the server
+	if cfg.SlugScheme == config.SlugSchemeV2 {
+		engine.ApplySlugSchemeV2(publicNotes)
+		engine.ApplySlugSchemeV2(sharedNotes)
+		engine.ApplySlugSchemeV2(privateNotes)
+	} else if cfg.SlugTransliterate {
+		engine.ApplySlugTransliteration(publicNotes)
+		engine.ApplySlugTransliteration(sharedNotes)
+		engine.ApplySlugTransliteration(privateNotes)
+	}
 
 	// Build backreferences for public notes only
 	publicNotes = engine.BuildBackreferences(publicNotes)
@@ -39,16 +104,36 @@ func loadNotes(basePath string, cfg *config.Config) (*map[string]model.Note, *en
 	for _, note := range publicNotes {
 		notesMap[note.Slug] = note
 	}
+	// The shared notes stay out of the tree and the tag index
+	for _, note := range sharedNotes {
+		if _, exists := notesMap[note.Slug]; !exists {
+			notesMap[note.Slug] = note
+		}
+	}
+	// So do the system notes, kept apart by NewNotesService
+	for _, note := range systemNotes {
+		if _, exists := notesMap[note.Slug]; !exists {
+			notesMap[note.Slug] = note
+		}
+	}
+	privateSlugs := make([]string, 0, len(privateNotes))
+	for _, note := range privateNotes {
+		privateSlugs = append(privateSlugs, note.Slug)
+	}
 
-	// Build tree structure with public notes only
-	tree := engine.BuildTree(publicNotes)
+	// Build tree structure with public notes only, ordered and decorated by the .pluie files of the folders
+	tree := engine.BuildTreeWithFolders(publicNotes, folders)
 
 	// Build tag index with public notes only
 	tagIndex := engine.BuildTagIndex(publicNotes)
 
+	notesLoaded.Set(float64(len(publicNotes)))
+	notesReloadDuration.Observe(time.Since(start).Seconds())
+	notesLastReloadDuration.Set(time.Since(start).Seconds())
+	notesLastReloadTimestamp.Set(float64(time.Now().Unix()))
 	slog.Info("Loaded notes", "total_time", time.Since(start).String(), "count", len(publicNotes))
 
-	return &notesMap, tree, tagIndex, nil
+	return &notesMap, tree, tagIndex, privateSlugs, nil
 }
 
 // watchFiles sets up a file watcher that monitors changes in the vault directory
@@ -67,13 +152,18 @@ func watchFiles(ctx context.Context, server *Server, basePath string, cfg *confi
 		return nil, err
 	}
 
+	explorer := newExplorer(basePath, cfg)
+
 	// Start watching in a goroutine
 	go func() {
-		// Debounce timer to avoid reloading too frequently
-		var debounceTimer *time.Timer
-		debounceDuration := 500 * time.Millisecond
+		// Changes are batched: the notes are reloaded once no file changed for the debounce window
+		debounce := cfg.WatchDebounce()
+		timer := time.NewTimer(debounce)
+		timer.Stop()
+		changed := make(map[string]bool)
 
 		defer func() {
+			timer.Stop()
 			if err := watcher.Close(); err != nil {
 				slog.Error("failed to close watcher", "error", err)
 			}
@@ -90,35 +180,33 @@ func watchFiles(ctx context.Context, server *Server, basePath string, cfg *confi
 				}
 
 				// Only reload on write, create, remove, or rename events
-				if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Remove|fsnotify.Rename) != 0 {
-					slog.Info("File change detected", "file", event.Name, "op", event.Op.String())
-
-					// If a new directory was created, add it to the watcher
-					if event.Op&fsnotify.Create != 0 {
-						if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
-							if err := addDirectoryRecursive(watcher, event.Name); err != nil {
-								slog.Error("failed to add directory to watcher", "path", event.Name, "error", err)
-							}
-						}
-					}
-
-					// Debounce: reset the timer on each event
-					if debounceTimer != nil {
-						debounceTimer.Stop()
-					}
+				if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Remove|fsnotify.Rename) == 0 || isIgnoredWatchPath(explorer, event.Name) {
+					continue
+				}
+				slog.Debug("File change detected", "file", event.Name, "op", event.Op.String())
 
-					debounceTimer = time.AfterFunc(debounceDuration, func() {
-						slog.Info("Reloading notes due to file changes")
-						notesMap, tree, tagIndex, err := loadNotes(basePath, cfg)
-						if err != nil {
-							slog.Error("Error reloading notes", "error", err)
-							return
+				// If a new directory was created, add it to the watcher
+				if event.Op&fsnotify.Create != 0 {
+					if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+						if err := addDirectoryRecursive(watcher, event.Name); err != nil {
+							slog.Error("failed to add directory to watcher", "path", event.Name, "error", err)
 						}
+					}
+				}
 
-						server.UpdateData(notesMap, tree, tagIndex)
-						slog.Info("Notes reloaded successfully")
-					})
+				// Debounce: restart the window on each event
+				changed[event.Name] = true
+				timer.Reset(debounce)
+
+			case <-timer.C:
+				// Same path as POST /-/reload, waiting for a reload it may be running
+				reload, err := server.reloadNotes(basePath, cfg, true)
+				if err != nil {
+					slog.Error("Error reloading notes", "error", err, "changed_files", len(changed))
+				} else {
+					slog.Info("Notes reloaded", "changed_files", len(changed), "in", (time.Duration(reload.DurationMS) * time.Millisecond).String())
 				}
+				clear(changed)
 
 			case err, ok := <-watcher.Errors:
 				if !ok {
@@ -133,6 +221,31 @@ func watchFiles(ctx context.Context, server *Server, basePath string, cfg *confi
 	return watcher, nil
 }
 
+// isIgnoredWatchPath reports whether a change to the file doesn't need a reload: files in
+// folders skipped by the explorer, like .obsidian/ or .git/, excluded paths, and editor
+// temporary files, like "note.md~" or ".note.md.swp". Folder metadata files (.pluie) of
+// explored folders are never ignored.
+func isIgnoredWatchPath(explorer Explorer, path string) bool {
+	relPath, err := filepath.Rel(explorer.BasePath, path)
+	if err != nil {
+		return false
+	}
+	relPath = filepath.ToSlash(relPath)
+
+	dir, name := "", relPath
+	if i := strings.LastIndexByte(relPath, '/'); i != -1 {
+		dir, name = relPath[:i], relPath[i+1:]
+	}
+	if explorer.shouldSkipPath(dir) || explorer.Exclude.Match(relPath) || name == "node_modules" {
+		return true
+	}
+	if strings.HasSuffix(name, ".pluie") {
+		return false
+	}
+	return strings.HasPrefix(name, ".") || strings.HasSuffix(name, "~") ||
+		strings.HasSuffix(name, ".swp") || strings.HasSuffix(name, ".swx")
+}
+
 // addDirectoryRecursive adds a directory and all its subdirectories to the watcher
 func addDirectoryRecursive(watcher *fsnotify.Watcher, path string) error {
 	watchCount := 0
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
@@ -44,6 +45,51 @@ func TestLoadNotes(t *testing.T) {
 	}
 }
 
+func TestLoadNotes_StatusVisibility(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "Draft.md", "---\nstatus: draft\n---\nNot ready yet.")
+	writeTestFile(t, dir, "Explicit.md", "---\nstatus: draft\npublish: true\n---\nPublished anyway.")
+	writeTestFile(t, dir, "Evergreen.md", "---\nstatus: evergreen\n---\nDone.")
+	writeTestFile(t, dir, "blog/.pluie", "---\npublish: true\n---\n")
+	writeTestFile(t, dir, "blog/Folder draft.md", "---\nstatus: draft\n---\nPublic folder, draft note.")
+
+	cfg := &config.Config{
+		PublicByDefault: true,
+		NoteStatuses:    "draft:gray,evergreen:emerald",
+		PrivateStatuses: "draft",
+	}
+
+	notesMap, _, _, err := loadNotes(dir, cfg)
+	if err != nil {
+		t.Fatalf("loadNotes error: %v", err)
+	}
+
+	for slug, visible := range map[string]bool{
+		"draft":             false,
+		"explicit":          true, // Explicit publish: true wins over the status
+		"evergreen":         true,
+		"blog/folder-draft": false,
+	} {
+		note, ok := (*notesMap)[slug]
+		if ok != visible {
+			t.Errorf("%s: visible = %v, want %v", slug, ok, visible)
+		}
+		if ok && note.Status == "" {
+			t.Errorf("%s: status should be resolved", slug)
+		}
+	}
+
+	// Preview mode shows every note, whatever its status
+	cfg.ForcePublic = true
+	notesMap, _, _, err = loadNotes(dir, cfg)
+	if err != nil {
+		t.Fatalf("loadNotes error: %v", err)
+	}
+	if _, ok := (*notesMap)["draft"]; !ok {
+		t.Error("drafts should be visible in preview mode")
+	}
+}
+
 func TestFileWatcherIntegration(t *testing.T) {
 	// Create a temporary directory for testing
 	tempDir := t.TempDir()
@@ -167,3 +213,77 @@ This is a new note.
 		t.Errorf("Expected 2 notes in tree after adding new note, got %d", len(allNotes))
 	}
 }
+
+func TestIsIgnoredWatchPath(t *testing.T) {
+	base := filepath.Join("vault")
+	explorer := Explorer{BasePath: base, Exclude: engine.NewExcludeMatcher([]string{"Archive/**", "*.excalidraw.md"}, false)}
+	for path, ignored := range map[string]bool{
+		"note.md":                     false,
+		"folder/note.md":              false,
+		"folder/.pluie":               false,
+		"folder/blog.pluie":           false,
+		"images/cat.png":              false,
+		".obsidian/workspace.json":    true,
+		".obsidian":                   true,
+		"folder/.git/index":           true,
+		"node_modules/pkg/readme.md":  true,
+		"note.md~":                    true,
+		"folder/.note.md.swp":         true,
+		"folder/.note.md.swx":         true,
+		"folder/.DS_Store":            true,
+		"folder/sub/.hidden/draft.md": true,
+		"Archive/2023/old.md":         true,
+		"Archive/.pluie":              true,
+		"folder/plan.excalidraw.md":   true,
+		"archive/kept.md":             false,
+	} {
+		if got := isIgnoredWatchPath(explorer, filepath.Join(base, filepath.FromSlash(path))); got != ignored {
+			t.Errorf("isIgnoredWatchPath(%q) = %v, want %v", path, got, ignored)
+		}
+	}
+}
+
+func TestFileWatcherBatchesChanges(t *testing.T) {
+	tempDir := t.TempDir()
+	writeTestFile(t, tempDir, "first.md", "---\npublish: true\n---\n# First")
+
+	cfg := &config.Config{WatchDebounceMS: 300}
+	notesMap, tree, tagIndex, err := loadNotes(tempDir, cfg)
+	if err != nil {
+		t.Fatalf("Failed to load notes: %v", err)
+	}
+	server := &Server{NotesService: engine.NewNotesService(notesMap, tree, tagIndex), rs: template.NewResource(cfg), cfg: cfg}
+
+	watcher, err := watchFiles(t.Context(), server, tempDir, cfg)
+	if err != nil {
+		t.Fatalf("Failed to start file watcher: %v", err)
+	}
+	defer watcher.Close()
+	time.Sleep(100 * time.Millisecond)
+
+	// A burst of changes, with a folder created during the debounce window
+	for i := range 20 {
+		writeTestFile(t, tempDir, fmt.Sprintf("note-%d.md", i), "---\npublish: true\n---\nBurst")
+	}
+	if err := os.Mkdir(filepath.Join(tempDir, "sub"), 0755); err != nil {
+		t.Fatalf("Failed to create folder: %v", err)
+	}
+	writeTestFile(t, tempDir, ".obsidian/workspace.json", "{}")
+
+	time.Sleep(150 * time.Millisecond)
+	if count := len(server.NotesService.GetNotesMap()); count != 1 {
+		t.Errorf("notes should not be reloaded before the end of the debounce window, got %d notes", count)
+	}
+
+	time.Sleep(600 * time.Millisecond)
+	if count := len(server.NotesService.GetNotesMap()); count != 21 {
+		t.Errorf("expected the 21 notes after the burst, got %d", count)
+	}
+
+	// The folder created during the window is watched
+	writeTestFile(t, tempDir, "sub/nested.md", "---\npublish: true\n---\n# Nested")
+	time.Sleep(800 * time.Millisecond)
+	if _, ok := server.NotesService.GetNotesMap()["sub/nested"]; !ok {
+		t.Error("changes in a folder created during the debounce window should be picked up")
+	}
+}
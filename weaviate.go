@@ -1,17 +1,28 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
+	"net/http"
+	"slices"
+	"time"
 
 	"github.com/EwenQuim/pluie/config"
 	"github.com/tmc/langchaingo/embeddings"
 	"github.com/tmc/langchaingo/llms/mistral"
 	"github.com/tmc/langchaingo/llms/ollama"
 	"github.com/tmc/langchaingo/llms/openai"
+	"github.com/tmc/langchaingo/schema"
+	"github.com/tmc/langchaingo/vectorstores"
 	"github.com/tmc/langchaingo/vectorstores/weaviate"
+	wvclient "github.com/weaviate/weaviate-go-client/v5/weaviate"
+	"github.com/weaviate/weaviate-go-client/v5/weaviate/filters"
 )
 
+// weaviateReadyTimeout is how long VECTOR_STORE=auto waits for Weaviate at startup
+const weaviateReadyTimeout = 2 * time.Second
+
 // createEmbeddingClient creates an LLM client for the configured embedding provider
 func createEmbeddingClient(cfg *config.Config) (embeddings.EmbedderClient, error) {
 	switch cfg.EmbeddingProvider {
@@ -41,8 +52,108 @@ func createEmbeddingClient(cfg *config.Config) (embeddings.EmbedderClient, error
 	}
 }
 
+// createEmbedder creates the embedder of the configured embedding provider, for the vector stores
+func createEmbedder(cfg *config.Config) (embeddings.Embedder, error) {
+	embeddingsClient, err := createEmbeddingClient(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("creating embedding client: %w", err)
+	}
+
+	emb, err := embeddings.NewEmbedder(embeddingsClient)
+	if err != nil {
+		return nil, fmt.Errorf("creating embedder: %w", err)
+	}
+	return emb, nil
+}
+
+// initializeVectorStore creates the vector store chosen by VECTOR_STORE. With "auto", Weaviate
+// is used when it answers, else the embedded store, so semantic search works without it.
+func initializeVectorStore(cfg *config.Config) (VectorStore, error) {
+	useWeaviate := cfg.VectorStore == config.VectorStoreWeaviate
+	if cfg.VectorStore == config.VectorStoreAuto {
+		useWeaviate = weaviateReady(cfg)
+		if !useWeaviate {
+			slog.Info("Weaviate is not reachable, using the embedded vector store", "host", cfg.WeaviateHost)
+		}
+	}
+
+	if useWeaviate {
+		wvStore, err := initializeWeaviateStore(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return wvStore, nil
+	}
+	return initializeEmbeddedStore(cfg)
+}
+
+// weaviateReady reports whether the Weaviate server answers its readiness probe
+func weaviateReady(cfg *config.Config) bool {
+	client := http.Client{Timeout: weaviateReadyTimeout}
+	resp, err := client.Get(cfg.WeaviateScheme + "://" + cfg.WeaviateHost + "/v1/.well-known/ready")
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
+}
+
+// initializeEmbeddedStore creates the embedded store, with the vectors saved by the previous runs
+func initializeEmbeddedStore(cfg *config.Config) (*EmbeddedStore, error) {
+	slog.Info("Initializing embedded vector store",
+		"file", cfg.EmbeddingsStoreFile,
+		"embedding_provider", cfg.EmbeddingProvider,
+		"embedding_model", cfg.EmbeddingModel)
+
+	emb, err := createEmbedder(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := NewEmbeddedStore(emb, cfg.EmbeddingsStoreFile, cfg.EmbeddingModel)
+	if err != nil {
+		return nil, fmt.Errorf("creating embedded store: %w", err)
+	}
+	return store, nil
+}
+
+// WeaviateStore is the Weaviate vector store, with the deletion of the documents of notes that
+// langchaingo lacks: the documents added for a note replace its previous ones, like EmbeddedStore
+type WeaviateStore struct {
+	weaviate.Store
+	client *wvclient.Client
+	index  string
+}
+
+// AddDocuments deletes the previous documents of the notes of docs, then adds docs
+func (s *WeaviateStore) AddDocuments(ctx context.Context, docs []schema.Document, options ...vectorstores.Option) ([]string, error) {
+	var paths []string
+	for _, doc := range docs {
+		if path, ok := doc.Metadata["path"].(string); ok && !slices.Contains(paths, path) {
+			paths = append(paths, path)
+		}
+	}
+	// The index doesn't exist before the first document, searches ignore stale chunks anyway
+	if err := s.DeleteDocuments(ctx, paths); err != nil {
+		slog.Debug("Failed to delete the previous documents of notes", "notes", paths, "error", err)
+	}
+	return s.Store.AddDocuments(ctx, docs, options...)
+}
+
+// DeleteDocuments deletes the documents of the notes at paths
+func (s *WeaviateStore) DeleteDocuments(ctx context.Context, paths []string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+	where := filters.Where().WithPath([]string{"path"}).WithOperator(filters.ContainsAny).WithValueText(paths...)
+	if _, err := s.client.Batch().ObjectsBatchDeleter().WithClassName(s.index).WithOutput("minimal").WithWhere(where).Do(ctx); err != nil {
+		return fmt.Errorf("deleting weaviate documents: %w", err)
+	}
+	return nil
+}
+
 // initializeWeaviateStore creates and initializes the Weaviate store
-func initializeWeaviateStore(cfg *config.Config) (*weaviate.Store, error) {
+func initializeWeaviateStore(cfg *config.Config) (*WeaviateStore, error) {
 	slog.Info("Initializing Weaviate store",
 		"host", cfg.WeaviateHost,
 		"scheme", cfg.WeaviateScheme,
@@ -50,14 +161,9 @@ func initializeWeaviateStore(cfg *config.Config) (*weaviate.Store, error) {
 		"embedding_provider", cfg.EmbeddingProvider,
 		"embedding_model", cfg.EmbeddingModel)
 
-	embeddingsClient, err := createEmbeddingClient(cfg)
+	emb, err := createEmbedder(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("creating embedding client: %w", err)
-	}
-
-	emb, err := embeddings.NewEmbedder(embeddingsClient)
-	if err != nil {
-		return nil, fmt.Errorf("creating embedder: %w", err)
+		return nil, err
 	}
 
 	// Create Weaviate store
@@ -67,13 +173,17 @@ func initializeWeaviateStore(cfg *config.Config) (*weaviate.Store, error) {
 		weaviate.WithHost(cfg.WeaviateHost),
 		weaviate.WithIndexName(cfg.WeaviateIndex),
 		// Specify which metadata fields to retrieve during similarity search
-		weaviate.WithQueryAttrs([]string{"text", "nameSpace", "title", "path", "slug"}),
+		weaviate.WithQueryAttrs([]string{"text", "nameSpace", "title", "path", "slug", "heading", "start", "end", "hash"}),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("creating weaviate store: %w", err)
 	}
+	client, err := wvclient.NewClient(wvclient.Config{Scheme: cfg.WeaviateScheme, Host: cfg.WeaviateHost})
+	if err != nil {
+		return nil, fmt.Errorf("creating weaviate client: %w", err)
+	}
 
 	slog.Info("Weaviate store initialized successfully - embeddings will be created on first search access")
 
-	return &wvStore, nil
+	return &WeaviateStore{Store: wvStore, client: client, index: cfg.WeaviateIndex}, nil
 }